@@ -224,6 +224,10 @@ type Batch struct {
 	// every time a RANGEKEYSET, RANGEKEYUNSET or RANGEKEYDEL key is added.
 	countRangeKeys uint64
 
+	// The count of records in the batch by kind, maintained alongside count.
+	// LogData records are not counted.
+	countByKind [InternalKeyKindMax + 1]uint32
+
 	// A deferredOp struct, stored in the Batch so that a pointer can be returned
 	// from the *Deferred() methods rather than a value.
 	deferredOp DeferredBatchOp
@@ -343,12 +347,14 @@ func (b *Batch) refreshMemTableSize() {
 
 	b.countRangeDels = 0
 	b.countRangeKeys = 0
+	b.countByKind = [InternalKeyKindMax + 1]uint32{}
 	for r := b.Reader(); ; {
 		kind, key, value, ok := r.Next()
 		if !ok {
 			break
 		}
 		b.memTableSize += memTableEntrySize(len(key), len(value))
+		b.countKind(kind)
 		switch kind {
 		case InternalKeyKindRangeDelete:
 			b.countRangeDels++
@@ -377,6 +383,9 @@ func (b *Batch) Apply(batch *Batch, _ *WriteOptions) error {
 	b.data = append(b.data, batch.data[batchHeaderLen:]...)
 
 	b.setCount(b.Count() + batch.Count())
+	for kind, n := range batch.countByKind {
+		b.countByKind[kind] += n
+	}
 
 	if b.db != nil || b.index != nil {
 		// Only iterate over the new entries if we need to track memTableSize or in
@@ -442,6 +451,7 @@ func (b *Batch) prepareDeferredKeyValueRecord(keyLen, valueLen int, kind Interna
 		b.init(keyLen + valueLen + 2*binary.MaxVarintLen64 + batchHeaderLen)
 	}
 	b.count++
+	b.countKind(kind)
 	b.memTableSize += memTableEntrySize(keyLen, valueLen)
 
 	pos := len(b.data)
@@ -491,6 +501,7 @@ func (b *Batch) prepareDeferredKeyRecord(keyLen int, kind InternalKeyKind) {
 		b.init(keyLen + binary.MaxVarintLen64 + batchHeaderLen)
 	}
 	b.count++
+	b.countKind(kind)
 	b.memTableSize += memTableEntrySize(keyLen, 0)
 
 	pos := len(b.data)
@@ -670,6 +681,60 @@ func (b *Batch) DeleteRangeDeferred(startLen, endLen int) *DeferredBatchOp {
 	return &b.deferredOp
 }
 
+// DeleteRangeMulti deletes all of the point keys (and values) in each of the
+// provided key ranges, as if by calling DeleteRange for each range. Every range
+// is validated before the batch is modified: if any range's Start is not less
+// than its End, an error is returned and nothing is added to the batch.
+// Overlapping and adjacent ranges are merged, so that a single range deletion
+// is added for each disjoint span of deleted keys.
+//
+// Ranges are validated and merged using the Compare function of the Comparer
+// of the DB that created the batch, or DefaultComparer if the batch was not
+// created by a DB.
+//
+// It is safe to modify the contents of the arguments after DeleteRangeMulti
+// returns.
+func (b *Batch) DeleteRangeMulti(ranges []KeyRange, _ *WriteOptions) error {
+	cmp, formatKey := DefaultComparer.Compare, DefaultComparer.FormatKey
+	if b.cmp != nil {
+		cmp, formatKey = b.cmp, b.formatKey
+	} else if b.db != nil {
+		cmp, formatKey = b.db.cmp, b.db.opts.Comparer.FormatKey
+	}
+	for i := range ranges {
+		if cmp(ranges[i].Start, ranges[i].End) >= 0 {
+			return errors.Errorf("pebble: invalid range %d: start %s is not less than end %s",
+				errors.Safe(i), formatKey(ranges[i].Start), formatKey(ranges[i].End))
+		}
+	}
+
+	merged := append([]KeyRange(nil), ranges...)
+	sort.Slice(merged, func(i, j int) bool {
+		return cmp(merged[i].Start, merged[j].Start) < 0
+	})
+	n := 0
+	for i := 1; i < len(merged); i++ {
+		if cmp(merged[i].Start, merged[n].End) <= 0 {
+			if cmp(merged[i].End, merged[n].End) > 0 {
+				merged[n].End = merged[i].End
+			}
+			continue
+		}
+		n++
+		merged[n] = merged[i]
+	}
+	if len(merged) > 0 {
+		merged = merged[:n+1]
+	}
+
+	for i := range merged {
+		if err := b.DeleteRange(merged[i].Start, merged[i].End, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // RangeKeySet sets a range key mapping the key range [start, end) at the MVCC
 // timestamp suffix to value. The suffix is optional. If any portion of the key
 // range [start, end) is already set by a range key with the same suffix value,
@@ -830,10 +895,10 @@ func (b *Batch) SetRepr(data []byte) error {
 	}
 	b.data = data
 	b.count = uint64(binary.LittleEndian.Uint32(b.countData()))
-	if b.db != nil {
-		// Only track memTableSize for batches that will be committed to the DB.
-		b.refreshMemTableSize()
-	}
+	// NB: This also recomputes the counts by kind, which are maintained for
+	// all batches, so memTableSize is computed even for batches that won't be
+	// committed to the DB.
+	b.refreshMemTableSize()
 	return nil
 }
 
@@ -848,7 +913,7 @@ func (b *Batch) NewIter(o *IterOptions) *Iterator {
 	if b.index == nil {
 		return &Iterator{err: ErrNotIndexed}
 	}
-	return b.db.newIterInternal(b, nil /* snapshot */, o)
+	return b.db.newIterInternal(b, nil /* snapshot */, o, 0 /* minSeqNum */)
 }
 
 // newInternalIter creates a new internalIterator that iterates over the
@@ -1060,6 +1125,7 @@ func (b *Batch) Reset() {
 	b.count = 0
 	b.countRangeDels = 0
 	b.countRangeKeys = 0
+	b.countByKind = [InternalKeyKindMax + 1]uint32{}
 	b.memTableSize = 0
 	b.deferredOp = DeferredBatchOp{}
 	b.tombstones = nil
@@ -1146,6 +1212,28 @@ func (b *Batch) Count() uint32 {
 	return uint32(b.count)
 }
 
+// KindCounts returns the number of operations of each kind in this batch,
+// omitting the kinds of which the batch holds none. Sets, merges, point
+// deletions, single deletions, range deletions and range key sets, unsets and
+// deletions are counted under their respective InternalKeyKinds. LogData
+// operations are not counted, so the counts sum to Count. The counts are
+// maintained as operations are added to the batch.
+func (b *Batch) KindCounts() map[InternalKeyKind]uint32 {
+	counts := make(map[InternalKeyKind]uint32)
+	for kind, n := range b.countByKind {
+		if n > 0 {
+			counts[InternalKeyKind(kind)] = n
+		}
+	}
+	return counts
+}
+
+func (b *Batch) countKind(kind InternalKeyKind) {
+	if kind <= InternalKeyKindMax && kind != InternalKeyKindLogData {
+		b.countByKind[kind]++
+	}
+}
+
 // Reader returns a BatchReader for the current batch contents. If the batch is
 // mutated, the new entries will not be visible to the reader.
 func (b *Batch) Reader() BatchReader {
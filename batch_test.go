@@ -169,6 +169,46 @@ func TestBatchLen(t *testing.T) {
 	requireLenAndReprEq(43)
 }
 
+func TestBatchKindCounts(t *testing.T) {
+	var b Batch
+	require.Equal(t, map[InternalKeyKind]uint32{}, b.KindCounts())
+	require.NoError(t, b.Set([]byte("a"), []byte("1"), nil))
+	require.NoError(t, b.Set([]byte("b"), []byte("2"), nil))
+	require.NoError(t, b.Merge([]byte("c"), []byte("3"), nil))
+	require.NoError(t, b.Delete([]byte("d"), nil))
+	require.NoError(t, b.SingleDelete([]byte("e"), nil))
+	require.NoError(t, b.DeleteRange([]byte("f"), []byte("g"), nil))
+	require.NoError(t, b.RangeKeySet([]byte("h"), []byte("i"), nil, []byte("4"), nil))
+	require.NoError(t, b.RangeKeyUnset([]byte("h"), []byte("i"), nil, nil))
+	require.NoError(t, b.RangeKeyDelete([]byte("j"), []byte("k"), nil))
+	require.NoError(t, b.LogData([]byte("l"), nil))
+	expected := map[InternalKeyKind]uint32{
+		InternalKeyKindSet:            2,
+		InternalKeyKindMerge:          1,
+		InternalKeyKindDelete:         1,
+		InternalKeyKindSingleDelete:   1,
+		InternalKeyKindRangeDelete:    1,
+		InternalKeyKindRangeKeySet:    1,
+		InternalKeyKindRangeKeyUnset:  1,
+		InternalKeyKindRangeKeyDelete: 1,
+	}
+	require.Equal(t, expected, b.KindCounts())
+	require.Equal(t, uint32(9), b.Count())
+
+	// The counts are carried over by Apply and SetRepr.
+	var b2 Batch
+	require.NoError(t, b2.Set([]byte("m"), []byte("5"), nil))
+	require.NoError(t, b2.Apply(&b, nil))
+	expected[InternalKeyKindSet]++
+	require.Equal(t, expected, b2.KindCounts())
+	var b3 Batch
+	require.NoError(t, b3.SetRepr(b2.Repr()))
+	require.Equal(t, expected, b3.KindCounts())
+
+	b.Reset()
+	require.Equal(t, map[InternalKeyKind]uint32{}, b.KindCounts())
+}
+
 func TestBatchEmpty(t *testing.T) {
 	var b Batch
 	require.True(t, b.Empty())
@@ -842,6 +882,71 @@ func TestBatchRangeOps(t *testing.T) {
 	})
 }
 
+func TestBatchDeleteRangeMulti(t *testing.T) {
+	ranges := func(bounds ...string) []KeyRange {
+		var krs []KeyRange
+		for i := 0; i < len(bounds); i += 2 {
+			krs = append(krs, KeyRange{Start: []byte(bounds[i]), End: []byte(bounds[i+1])})
+		}
+		return krs
+	}
+	contents := func(b *Batch) string {
+		var buf strings.Builder
+		r := b.Reader()
+		for {
+			kind, key, value, ok := r.Next()
+			if !ok {
+				break
+			}
+			fmt.Fprintf(&buf, "%s:[%s-%s)\n", kind, key, value)
+		}
+		return buf.String()
+	}
+
+	// Invalid ranges are rejected without modifying the batch.
+	var b Batch
+	require.NoError(t, b.Set([]byte("a"), nil, nil))
+	for _, krs := range [][]KeyRange{
+		ranges("a", "b", "d", "c"),
+		ranges("a", "b", "c", "c"),
+	} {
+		err := b.DeleteRangeMulti(krs, nil)
+		require.Regexp(t, `pebble: invalid range 1: start . is not less than end .`, err)
+		require.Equal(t, uint32(1), b.Count())
+	}
+
+	// Overlapping and adjacent ranges are merged.
+	b.Reset()
+	require.NoError(t, b.DeleteRangeMulti(ranges("g", "h", "b", "c", "a", "b", "c", "e", "d", "da"), nil))
+	require.Equal(t, "RANGEDEL:[a-e)\nRANGEDEL:[g-h)\n", contents(&b))
+	b.Reset()
+	require.NoError(t, b.DeleteRangeMulti(nil, nil))
+	require.True(t, b.Empty())
+
+	// Ranges are validated using the DB's comparer.
+	d, err := Open("", &Options{
+		FS: vfs.NewMem(),
+		Comparer: &Comparer{
+			Compare:        func(a, b []byte) int { return bytes.Compare(b, a) },
+			Equal:          bytes.Equal,
+			AbbreviatedKey: func(key []byte) uint64 { return 0 },
+			FormatKey:      DefaultComparer.FormatKey,
+			Separator:      func(dst, a, b []byte) []byte { return append(dst, a...) },
+			Successor:      func(dst, a []byte) []byte { return append(dst, a...) },
+			Name:           "pebble.reverse",
+		},
+	})
+	require.NoError(t, err)
+	defer func() { require.NoError(t, d.Close()) }()
+	for _, newBatch := range []func() *Batch{d.NewBatch, d.NewIndexedBatch} {
+		b := newBatch()
+		require.Error(t, b.DeleteRangeMulti(ranges("a", "b"), nil))
+		require.NoError(t, b.DeleteRangeMulti(ranges("b", "a", "d", "c", "c", "b"), nil))
+		require.Equal(t, "RANGEDEL:[d-a)\n", contents(b))
+		require.NoError(t, b.Close())
+	}
+}
+
 func TestBatchTooLarge(t *testing.T) {
 	var b Batch
 	var result interface{}
@@ -141,6 +141,10 @@ func (d *DB) Checkpoint(
 	// Get the unflushed log files, the current version, and the current manifest
 	// file number.
 	memQueue := d.mu.mem.queue
+	logLocations := make([]walLocation, len(memQueue))
+	for i := range memQueue {
+		logLocations[i] = d.logLocationLocked(memQueue[i].logNum)
+	}
 	current := d.mu.versions.currentVersion()
 	formatVers := d.mu.formatVers.vers
 	manifestFileNum := d.mu.versions.manifestFileNum
@@ -252,7 +256,7 @@ func (d *DB) Checkpoint(
 	for l := range current.Levels {
 		iter := current.Levels[l].Iter()
 		for f := iter.First(); f != nil; f = iter.Next() {
-			srcPath := base.MakeFilepath(fs, d.dirname, fileTypeTable, f.FileNum)
+			srcPath := makeTableFilepath(fs, d.dirname, f.FileNum, f.Name)
 			destPath := fs.PathJoin(destDir, fs.PathBase(srcPath))
 			ckErr = vfs.LinkOrCopy(fs, srcPath, destPath)
 			if ckErr != nil {
@@ -269,9 +273,13 @@ func (d *DB) Checkpoint(
 		if logNum == 0 {
 			continue
 		}
-		srcPath := base.MakeFilepath(fs, d.walDirname, fileTypeLog, logNum)
-		destPath := fs.PathJoin(destDir, fs.PathBase(srcPath))
-		ckErr = vfs.Copy(fs, srcPath, destPath)
+		// WALs in the secondary WAL directory configured by
+		// Options.WALFailover are copied into the checkpoint alongside the
+		// other WALs.
+		loc := logLocations[i]
+		srcPath := base.MakeFilepath(loc.fs, loc.dirname, fileTypeLog, logNum)
+		destPath := fs.PathJoin(destDir, loc.fs.PathBase(srcPath))
+		ckErr = vfs.CopyAcrossFS(loc.fs, srcPath, fs, destPath)
 		if ckErr != nil {
 			return ckErr
 		}
@@ -14,3 +14,31 @@ type DeleteCleaner = base.DeleteCleaner
 
 // ArchiveCleaner exports the base.ArchiveCleaner type.
 type ArchiveCleaner = base.ArchiveCleaner
+
+// FileType exports the base.FileType type, the type of a file passed to a
+// Cleaner.
+type FileType = base.FileType
+
+// The types of the files passed to a Cleaner.
+const (
+	FileTypeLog      = base.FileTypeLog
+	FileTypeTable    = base.FileTypeTable
+	FileTypeManifest = base.FileTypeManifest
+	FileTypeOptions  = base.FileTypeOptions
+)
+
+// AsyncCleaner exports the base.AsyncCleaner type.
+type AsyncCleaner = base.AsyncCleaner
+
+// PolicyCleaner exports the base.PolicyCleaner type.
+type PolicyCleaner = base.PolicyCleaner
+
+// CleanAction exports the base.CleanAction type.
+type CleanAction = base.CleanAction
+
+// The actions a PolicyCleaner may take on an obsolete file.
+const (
+	CleanActionDelete  = base.CleanActionDelete
+	CleanActionArchive = base.CleanActionArchive
+	CleanActionHandOff = base.CleanActionHandOff
+)
@@ -8,7 +8,9 @@ import (
 	"fmt"
 	"sort"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/cockroachdb/pebble/internal/datadriven"
 	"github.com/cockroachdb/pebble/vfs"
@@ -109,3 +111,86 @@ func TestArchiveCleaner(t *testing.T) {
 		}
 	})
 }
+
+func TestPolicyCleaner(t *testing.T) {
+	mem := vfs.NewMem()
+	type handOff struct {
+		path string
+		done func(error)
+	}
+	handOffs := make(chan handOff, 100)
+	var mu sync.Mutex
+	var deletedTables []string
+	opts := &Options{
+		Cleaner: PolicyCleaner{
+			Policy: func(fileType FileType, path string) CleanAction {
+				switch fileType {
+				case FileTypeTable:
+					return CleanActionHandOff
+				case FileTypeLog:
+					return CleanActionArchive
+				default:
+					return CleanActionDelete
+				}
+			},
+			ArchiveDir: "archived-wal",
+			HandOff: func(fs vfs.FS, fileType FileType, path string, done func(error)) {
+				handOffs <- handOff{path: path, done: done}
+			},
+		},
+		EventListener: EventListener{
+			TableDeleted: func(info TableDeleteInfo) {
+				mu.Lock()
+				defer mu.Unlock()
+				deletedTables = append(deletedTables, mem.PathBase(info.Path))
+			},
+		},
+		FS:     mem,
+		WALDir: "wal",
+	}
+	d, err := Open("db", opts)
+	require.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		require.NoError(t, d.Set([]byte("a"), []byte(fmt.Sprint(i)), nil))
+		require.NoError(t, d.Flush())
+	}
+	require.NoError(t, d.Compact([]byte("a"), []byte("b"), false /* parallelize */))
+
+	// The compacted sstables are handed off, and remain in place until the
+	// hand-off completes.
+	var pending []handOff
+	for len(pending) < 2 {
+		pending = append(pending, <-handOffs)
+	}
+	sort.Slice(pending, func(i, j int) bool { return pending[i].path < pending[j].path })
+	for _, h := range pending {
+		_, err := mem.Stat(h.path)
+		require.NoError(t, err)
+	}
+	require.Equal(t, "db/000005.sst", pending[0].path)
+	require.Equal(t, "db/000007.sst", pending[1].path)
+
+	// The flushed WALs were archived.
+	archived, err := mem.List("archived-wal")
+	require.NoError(t, err)
+	require.NotEmpty(t, archived)
+
+	// Close waits for the hand-offs to complete.
+	require.NoError(t, mem.Remove(pending[0].path))
+	pending[0].done(nil)
+	closed := make(chan error, 1)
+	go func() { closed <- d.Close() }()
+	select {
+	case <-closed:
+		t.Fatal("Close returned before the hand-off completed")
+	case <-time.After(10 * time.Millisecond):
+	}
+	require.NoError(t, mem.Remove(pending[1].path))
+	pending[1].done(nil)
+	require.NoError(t, <-closed)
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Equal(t, []string{"000005.sst", "000007.sst"}, deletedTables)
+}
@@ -221,6 +221,15 @@ type commitPipeline struct {
 	// The mutex to use for synchronizing access to logSeqNum and serializing
 	// calls to commitEnv.write().
 	mu sync.Mutex
+	// published is used by CommitIf to wait for the batches sequenced before
+	// its batch to be published. publish broadcasts on cond after ratcheting
+	// the visible sequence number if there are waiters, so commits without a
+	// condition only pay for an atomic load of waiters.
+	published struct {
+		sync.Mutex
+		cond    sync.Cond
+		waiters int32
+	}
 }
 
 func newCommitPipeline(env commitEnv) *commitPipeline {
@@ -231,6 +240,7 @@ func newCommitPipeline(env commitEnv) *commitPipeline {
 		// and sync the WAL.
 		sem: make(chan struct{}, record.SyncConcurrency-1),
 	}
+	p.published.cond.L = &p.published.Mutex
 	return p
 }
 
@@ -238,8 +248,19 @@ func newCommitPipeline(env commitEnv) *commitPipeline {
 // WAL, and applying the batch to the memtable. Upon successful return the
 // batch's mutations will be visible for reading.
 func (p *commitPipeline) Commit(b *Batch, syncWAL bool) error {
+	_, err := p.CommitIf(b, syncWAL, nil)
+	return err
+}
+
+// CommitIf commits the batch as Commit does, but if cond is non-nil, only if
+// cond returns true. The cond callback is invoked with commitPipeline.mu held,
+// once every batch sequenced before b is visible, and before b is assigned a
+// sequence number, so no other batch is sequenced between the state observed
+// by cond and b. Holding commitPipeline.mu stalls all other commits, so cond
+// should return promptly. CommitIf returns whether the batch was committed.
+func (p *commitPipeline) CommitIf(b *Batch, syncWAL bool, cond func() bool) (bool, error) {
 	if b.Empty() {
-		return nil
+		return false, nil
 	}
 
 	p.sem <- struct{}{}
@@ -250,16 +271,20 @@ func (p *commitPipeline) Commit(b *Batch, syncWAL bool) error {
 	//
 	// NB: We set Batch.commitErr on error so that the batch won't be a candidate
 	// for reuse. See Batch.release().
-	mem, err := p.prepare(b, syncWAL)
+	mem, ok, err := p.prepare(b, syncWAL, cond)
 	if err != nil {
 		b.db = nil // prevent batch reuse on error
-		return err
+		return false, err
+	}
+	if !ok {
+		<-p.sem
+		return false, nil
 	}
 
 	// Apply the batch to the memtable.
 	if err := p.env.apply(b, mem); err != nil {
 		b.db = nil // prevent batch reuse on error
-		return err
+		return false, err
 	}
 
 	// Publish the batch sequence number.
@@ -270,7 +295,7 @@ func (p *commitPipeline) Commit(b *Batch, syncWAL bool) error {
 	if b.commitErr != nil {
 		b.db = nil // prevent batch reuse on error
 	}
-	return b.commitErr
+	return true, b.commitErr
 }
 
 // AllocateSeqNum allocates count sequence numbers, invokes the prepare
@@ -344,10 +369,12 @@ func (p *commitPipeline) AllocateSeqNum(count int, prepare func(), apply func(se
 	<-p.sem
 }
 
-func (p *commitPipeline) prepare(b *Batch, syncWAL bool) (*memTable, error) {
+func (p *commitPipeline) prepare(
+	b *Batch, syncWAL bool, cond func() bool,
+) (*memTable, bool, error) {
 	n := uint64(b.Count())
 	if n == invalidBatchCount {
-		return nil, ErrInvalidBatch
+		return nil, false, ErrInvalidBatch
 	}
 	count := 1
 	if syncWAL {
@@ -365,6 +392,17 @@ func (p *commitPipeline) prepare(b *Batch, syncWAL bool) (*memTable, error) {
 
 	p.mu.Lock()
 
+	if cond != nil {
+		// Wait for any outstanding writes to the memtable to complete, so that
+		// cond observes every batch sequenced before this one.
+		p.waitForPublished(atomic.LoadUint64(p.env.logSeqNum))
+		if !cond() {
+			p.mu.Unlock()
+			b.commit.Add(-count)
+			return nil, false, nil
+		}
+	}
+
 	// Enqueue the batch in the pending queue. Note that while the pending queue
 	// is lock-free, we want the order of batches to be the same as the sequence
 	// number order.
@@ -380,7 +418,7 @@ func (p *commitPipeline) prepare(b *Batch, syncWAL bool) (*memTable, error) {
 
 	p.mu.Unlock()
 
-	return mem, err
+	return mem, true, err
 }
 
 func (p *commitPipeline) publish(b *Batch) {
@@ -419,6 +457,11 @@ func (p *commitPipeline) publish(b *Batch) {
 			}
 			if atomic.CompareAndSwapUint64(p.env.visibleSeqNum, curSeqNum, newSeqNum) {
 				// We successfully published t's sequence number.
+				if atomic.LoadInt32(&p.published.waiters) > 0 {
+					p.published.Lock()
+					p.published.cond.Broadcast()
+					p.published.Unlock()
+				}
 				break
 			}
 		}
@@ -427,6 +470,25 @@ func (p *commitPipeline) publish(b *Batch) {
 	}
 }
 
+// waitForPublished waits for the visible sequence number to reach seqNum. It
+// must be called with commitPipeline.mu held, with seqNum no greater than
+// logSeqNum, so that the batches it waits for have already been sequenced.
+func (p *commitPipeline) waitForPublished(seqNum uint64) {
+	if atomic.LoadUint64(p.env.visibleSeqNum) >= seqNum {
+		return
+	}
+	// Register as a waiter before checking the visible sequence number again
+	// under the lock, so that a publish that ratchets the visible sequence
+	// number after the check observes the waiter and broadcasts.
+	atomic.AddInt32(&p.published.waiters, 1)
+	p.published.Lock()
+	for atomic.LoadUint64(p.env.visibleSeqNum) < seqNum {
+		p.published.cond.Wait()
+	}
+	p.published.Unlock()
+	atomic.AddInt32(&p.published.waiters, -1)
+}
+
 // ratchetSeqNum allocates and marks visible all sequence numbers less than
 // but excluding `nextSeqNum`.
 func (p *commitPipeline) ratchetSeqNum(nextSeqNum uint64) {
@@ -119,6 +119,51 @@ func TestCommitPipeline(t *testing.T) {
 	}
 }
 
+func TestCommitPipelineCommitIf(t *testing.T) {
+	var e testCommitEnv
+	p := newCommitPipeline(e.env())
+
+	n := 1000
+	if invariants.RaceEnabled {
+		n = 100
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(n)
+	var committed, unpublished uint64
+	for i := 0; i < n; i++ {
+		go func(i int) {
+			defer wg.Done()
+			var b Batch
+			_ = b.Set([]byte(fmt.Sprint(i)), nil, nil)
+			if i%2 == 0 {
+				_ = p.Commit(&b, false)
+				atomic.AddUint64(&committed, 1)
+				return
+			}
+			ok, err := p.CommitIf(&b, false, func() bool {
+				// Every batch sequenced before this one must be visible.
+				if atomic.LoadUint64(&e.visibleSeqNum) != atomic.LoadUint64(&e.logSeqNum) {
+					atomic.AddUint64(&unpublished, 1)
+				}
+				return i%4 == 1
+			})
+			require.NoError(t, err)
+			require.Equal(t, i%4 == 1, ok)
+			if ok {
+				atomic.AddUint64(&committed, 1)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	require.Zero(t, atomic.LoadUint64(&unpublished))
+	require.Equal(t, committed, atomic.LoadUint64(&e.writeCount))
+	require.Equal(t, committed, atomic.LoadUint64(&e.logSeqNum))
+	require.Equal(t, committed, atomic.LoadUint64(&e.visibleSeqNum))
+	require.Zero(t, atomic.LoadInt32(&p.published.waiters))
+}
+
 func TestCommitPipelineAllocateSeqNum(t *testing.T) {
 	var e testCommitEnv
 	p := newCommitPipeline(e.env())
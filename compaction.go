@@ -35,11 +35,19 @@ var compactLabels = pprof.Labels("pebble", "compact")
 var flushLabels = pprof.Labels("pebble", "flush")
 var gcLabels = pprof.Labels("pebble", "gc")
 
+// defaultMaxCompactionOverlapRatio is the default value of
+// Options.Experimental.MaxCompactionOverlapRatio.
+const defaultMaxCompactionOverlapRatio = 25
+
 // expandedCompactionByteSizeLimit is the maximum number of bytes in all
 // compacted files. We avoid expanding the lower level file set of a compaction
 // if it would make the total compaction cover more than this many bytes.
 func expandedCompactionByteSizeLimit(opts *Options, level int, availBytes uint64) uint64 {
-	v := uint64(25 * opts.Level(level).TargetFileSize)
+	ratio := opts.Experimental.MaxCompactionOverlapRatio
+	if ratio <= 0 {
+		ratio = defaultMaxCompactionOverlapRatio
+	}
+	v := uint64(ratio * float64(opts.Level(level).TargetFileSize))
 
 	// Never expand a compaction beyond half the available capacity, divided
 	// by the maximum number of concurrent compactions. Each of the concurrent
@@ -57,6 +65,9 @@ func expandedCompactionByteSizeLimit(opts *Options, level int, availBytes uint64
 // maxGrandparentOverlapBytes is the maximum bytes of overlap with level+1
 // before we stop building a single file in a level-1 to level compaction.
 func maxGrandparentOverlapBytes(opts *Options, level int) uint64 {
+	if opts.Experimental.MaxGrandparentOverlapBytes > 0 {
+		return uint64(opts.Experimental.MaxGrandparentOverlapBytes)
+	}
 	return uint64(10 * opts.Level(level).TargetFileSize)
 }
 
@@ -147,6 +158,28 @@ func (f *fileSizeSplitter) onNewOutput(key *InternalKey) []byte {
 	return nil
 }
 
+// keyCountSplitter is a compactionOutputSplitter that makes a determination
+// to split outputs based on the number of point keys in the current output.
+// Like fileSizeSplitter, it does not guarantee that it will advise splits
+// only at user key change boundaries.
+type keyCountSplitter struct {
+	maxKeys uint64
+}
+
+func (k *keyCountSplitter) shouldSplitBefore(
+	key *InternalKey, tw *sstable.Writer,
+) compactionSplitSuggestion {
+	if key.Kind() != InternalKeyKindRangeDelete && tw != nil &&
+		tw.NumPointKeys() >= k.maxKeys {
+		return splitNow
+	}
+	return noSplit
+}
+
+func (k *keyCountSplitter) onNewOutput(key *InternalKey) []byte {
+	return nil
+}
+
 type limitFuncSplitter struct {
 	c         *compaction
 	limitFunc func(userKey []byte) []byte
@@ -226,6 +259,34 @@ func (a *splitterGroup) onNewOutput(key *InternalKey) []byte {
 	return earliestLimit
 }
 
+// rangeKeySplitDeferrer is a compactionOutputSplitter that takes in a child
+// splitter, and defers the splits it advises while a pending range key spans
+// the split point, so that the range key isn't fragmented at the boundary
+// between the outputs, until the current output reaches maxFileSize. See
+// sstable.RangeKeyFragmentAvoidSplits. The child splitter must not return a
+// limit from onNewOutput, as a deferred split could otherwise fall beyond it.
+type rangeKeySplitDeferrer struct {
+	splitter    compactionOutputSplitter
+	frag        *keyspan.Fragmenter
+	maxFileSize uint64
+}
+
+func (r *rangeKeySplitDeferrer) shouldSplitBefore(
+	key *InternalKey, tw *sstable.Writer,
+) compactionSplitSuggestion {
+	if split := r.splitter.shouldSplitBefore(key, tw); split != splitNow {
+		return split
+	}
+	if tw != nil && tw.EstimatedSize() < r.maxFileSize && r.frag.CoversUserKey(key.UserKey) {
+		return noSplit
+	}
+	return splitNow
+}
+
+func (r *rangeKeySplitDeferrer) onNewOutput(key *InternalKey) []byte {
+	return r.splitter.onNewOutput(key)
+}
+
 // userKeyChangeSplitter is a compactionOutputSplitter that takes in a child
 // splitter, and splits when 1) that child splitter has advised a split, and 2)
 // the compaction output is at the boundary between two user keys (also
@@ -288,6 +349,7 @@ const (
 	compactionKindElisionOnly
 	compactionKindRead
 	compactionKindRewrite
+	compactionKindSpaceReclamation
 )
 
 func (k compactionKind) String() string {
@@ -306,6 +368,8 @@ func (k compactionKind) String() string {
 		return "read"
 	case compactionKindRewrite:
 		return "rewrite"
+	case compactionKindSpaceReclamation:
+		return "space-reclamation"
 	}
 	return "?"
 }
@@ -376,6 +440,14 @@ func rangeKeyCompactionTransform(
 // compaction is a table compaction from one level to the next, starting from a
 // given version.
 type compaction struct {
+	// The 64-bit fields accessed atomically are placed at the beginning of the
+	// struct to guarantee their alignment. See DB.atomic.
+	atomic struct {
+		// bytesProcessed is a copy of bytesIterated, periodically published by
+		// the goroutine running the compaction for DB.CompactionsInProgress.
+		bytesProcessed uint64
+	}
+
 	kind      compactionKind
 	cmp       Compare
 	equal     Equal
@@ -419,6 +491,20 @@ type compaction struct {
 	bytesIterated uint64
 	// bytesWritten contains the number of bytes that have been written to outputs.
 	bytesWritten int64
+	// inputBytes is the estimated number of bytes that will be iterated by the
+	// compaction, against which its progress is measured.
+	inputBytes uint64
+	// jobID and beganAt are set, with DB.mu held, when the compaction begins
+	// running. jobID is zero until then.
+	jobID   int
+	beganAt time.Time
+	// progress, if non-nil, is invoked periodically with the progress of the
+	// compaction. It is set for the manual compactions of
+	// DB.CompactWithProgress.
+	progress func(CompactionProgress)
+	// versionsElided is the number of versions elided by the compaction due to
+	// Options.Experimental.MaxVersionsPerKey.
+	versionsElided int64
 
 	// The boundaries of the input data.
 	smallest InternalKey
@@ -542,7 +628,8 @@ func newCompaction(pc *pickedCompaction, opts *Options) *compaction {
 	c.setupInuseKeyRanges()
 
 	c.kind = pc.kind
-	if c.kind == compactionKindDefault && c.outputLevel.files.Empty() && !c.hasExtraLevelData() &&
+	if (c.kind == compactionKindDefault || c.kind == compactionKindSpaceReclamation) &&
+		c.outputLevel.files.Empty() && !c.hasExtraLevelData() &&
 		c.startLevel.files.Len() == 1 && c.grandparents.SizeSum() <= c.maxOverlapBytes {
 		// This compaction can be converted into a trivial move from one level
 		// to the next. We avoid such a move if there is lots of overlapping
@@ -1288,6 +1375,16 @@ func (c *compaction) String() string {
 		}
 		fmt.Fprintf(&buf, "\n")
 	}
+	// The overlap of the inputs with the output level, relative to the size of
+	// the start level's inputs, and the overlap with the grandparent level.
+	startBytes := c.startLevel.files.SizeSum()
+	outputBytes := c.outputLevel.files.SizeSum()
+	var ratio float64
+	if startBytes > 0 {
+		ratio = float64(outputBytes) / float64(startBytes)
+	}
+	fmt.Fprintf(&buf, "overlap: output-level=%d (ratio %.2f) grandparents=%d (max %d per output)\n",
+		outputBytes, ratio, c.grandparents.SizeSum(), c.maxOverlapBytes)
 	return buf.String()
 }
 
@@ -1301,6 +1398,12 @@ type manualCompaction struct {
 	start       []byte
 	end         []byte
 	split       bool
+	// rebalance is true if the compaction rewrites the files overlapping
+	// [start, end] into the same level. See DB.RebalanceLevel.
+	rebalance bool
+	// progress is passed to the compaction picked for this manual compaction.
+	// See DB.CompactWithProgress.
+	progress func(CompactionProgress)
 }
 
 type readCompaction struct {
@@ -1317,6 +1420,7 @@ type readCompaction struct {
 
 func (d *DB) addInProgressCompaction(c *compaction) {
 	d.mu.compact.inProgress[c] = struct{}{}
+	c.inputBytes = c.estimatedInputBytes()
 	var isBase, isIntraL0 bool
 	for _, cl := range c.inputs {
 		iter := cl.files.Iter()
@@ -1428,6 +1532,9 @@ func (d *DB) maybeScheduleFlush() {
 	if d.mu.compact.flushing || d.closed.Load() != nil || d.opts.ReadOnly {
 		return
 	}
+	if d.mu.compact.backgroundErr != nil {
+		return
+	}
 	if len(d.mu.mem.queue) <= 1 {
 		return
 	}
@@ -1526,6 +1633,7 @@ func (d *DB) flush() {
 		if bytesFlushed, err = d.flush1(); err != nil {
 			// TODO(peter): count consecutive flush errors and backoff.
 			d.opts.EventListener.BackgroundError(err)
+			d.maybePauseBackgroundWorkLocked(err)
 		}
 		d.mu.compact.flushing = false
 		d.mu.compact.noOngoingFlushStartTime = time.Now()
@@ -1554,6 +1662,10 @@ func (d *DB) flush1() (bytesFlushed uint64, err error) {
 		if !d.mu.mem.queue[n].readyForFlush() {
 			break
 		}
+		if d.mu.mem.queue[n].flushBarrier {
+			n++
+			break
+		}
 	}
 	if n == 0 {
 		// None of the immutable memtables are ready for flushing.
@@ -1583,6 +1695,7 @@ func (d *DB) flush1() (bytesFlushed uint64, err error) {
 		Input: n,
 	})
 	startTime := d.timeNow()
+	c.jobID, c.beganAt = jobID, startTime
 
 	ve, pendingOutputs, err := d.runCompaction(jobID, c)
 
@@ -1698,6 +1811,25 @@ func (d *DB) maybeScheduleCompactionPicker(
 	if d.closed.Load() != nil || d.opts.ReadOnly {
 		return
 	}
+	if err := d.mu.compact.backgroundErr; err != nil {
+		// Background work is paused. Fail queued manual compactions rather
+		// than leaving their callers waiting.
+		for _, manual := range d.mu.compact.manual {
+			manual.done <- err
+		}
+		d.mu.compact.manual = nil
+		return
+	}
+	if d.mu.compact.replacing > 0 {
+		// ReplaceAll is waiting for in-progress compactions to complete. It
+		// schedules compactions once it's done.
+		return
+	}
+	if d.mu.compact.paused > 0 {
+		// Compactions are paused by PauseCompactions. They're scheduled once
+		// resumed.
+		return
+	}
 	maxConcurrentCompactions := d.opts.MaxConcurrentCompactions()
 	if d.mu.compact.compactingCount >= maxConcurrentCompactions {
 		if len(d.mu.compact.manual) > 0 {
@@ -1749,6 +1881,7 @@ func (d *DB) maybeScheduleCompactionPicker(
 		pc, retryLater := d.mu.versions.picker.pickManual(env, manual)
 		if pc != nil {
 			c := newCompaction(pc, d.opts)
+			c.progress = manual.progress
 			d.mu.compact.manual = d.mu.compact.manual[1:]
 			d.mu.compact.compactingCount++
 			d.addInProgressCompaction(c)
@@ -2058,11 +2191,17 @@ func checkDeleteCompactionHints(
 // compact runs one compaction and maybe schedules another call to compact.
 func (d *DB) compact(c *compaction, errChannel chan error) {
 	pprof.Do(context.Background(), compactLabels, func(context.Context) {
+		if d.compactionIOPriority.Class != IOPriorityDefault {
+			if restore, err := setThreadIOPriority(d.compactionIOPriority); err == nil {
+				defer restore()
+			}
+		}
 		d.mu.Lock()
 		defer d.mu.Unlock()
 		if err := d.compact1(c, errChannel); err != nil {
 			// TODO(peter): count consecutive compaction errors and backoff.
 			d.opts.EventListener.BackgroundError(err)
+			d.maybePauseBackgroundWorkLocked(err)
 		}
 		d.mu.compact.compactingCount--
 		// The previous compaction may have produced too many files in a
@@ -2088,6 +2227,7 @@ func (d *DB) compact1(c *compaction, errChannel chan error) (err error) {
 	info := c.makeInfo(jobID)
 	d.opts.EventListener.CompactionBegin(info)
 	startTime := d.timeNow()
+	c.jobID, c.beganAt = jobID, startTime
 
 	ve, pendingOutputs, err := d.runCompaction(jobID, c)
 
@@ -2117,6 +2257,19 @@ func (d *DB) compact1(c *compaction, errChannel chan error) (err error) {
 	d.removeInProgressCompaction(c)
 	d.mu.versions.incrementCompactions(c.kind, c.extraLevels)
 	d.mu.versions.incrementCompactionBytes(-c.bytesWritten)
+	if err == nil {
+		d.mu.versions.metrics.Compact.VersionsElided += c.versionsElided
+		var inputSize, outputSize uint64
+		for i := range c.inputs {
+			inputSize += c.inputs[i].files.SizeSum()
+		}
+		for i := range ve.NewFiles {
+			outputSize += ve.NewFiles[i].Meta.Size
+		}
+		if inputSize > outputSize {
+			d.mu.versions.metrics.Compact.ReclaimedBytes += inputSize - outputSize
+		}
+	}
 
 	info.TotalDuration = d.timeNow().Sub(startTime)
 	d.opts.EventListener.CompactionEnd(info)
@@ -2139,6 +2292,24 @@ func (d *DB) compact1(c *compaction, errChannel chan error) (err error) {
 //
 // d.mu must be held when calling this, but the mutex may be dropped and
 // re-acquired during the course of this method.
+// acquireWriteBuffer accounts for a write buffer of n bytes held by the
+// sstable writer of a flush or compaction, updating the peak observed.
+func (d *DB) acquireWriteBuffer(n int64) {
+	v := atomic.AddInt64(&d.atomic.writeBufferBytes, n)
+	for {
+		peak := atomic.LoadInt64(&d.atomic.peakWriteBufferBytes)
+		if v <= peak || atomic.CompareAndSwapInt64(&d.atomic.peakWriteBufferBytes, peak, v) {
+			return
+		}
+	}
+}
+
+// releaseWriteBuffer releases a write buffer of n bytes previously accounted
+// for by acquireWriteBuffer.
+func (d *DB) releaseWriteBuffer(n int64) {
+	atomic.AddInt64(&d.atomic.writeBufferBytes, -n)
+}
+
 func (d *DB) runCompaction(
 	jobID int, c *compaction,
 ) (ve *versionEdit, pendingOutputs []*fileMetadata, retErr error) {
@@ -2181,28 +2352,29 @@ func (d *DB) runCompaction(
 	// such a move if there is lots of overlapping grandparent data. Otherwise,
 	// the move could create a parent file that will require a very expensive
 	// merge later on.
+	//
+	// A move compaction usually moves a single table, but DB.TrivialMoveL0
+	// moves many L0 tables at once.
 	if c.kind == compactionKindMove {
-		iter := c.startLevel.files.Iter()
-		meta := iter.First()
+		startMetrics := &LevelMetrics{}
+		outputMetrics := &LevelMetrics{}
 		c.metrics = map[int]*LevelMetrics{
-			c.startLevel.level: {
-				NumFiles: -1,
-				Size:     -int64(meta.Size),
-			},
-			c.outputLevel.level: {
-				NumFiles:    1,
-				Size:        int64(meta.Size),
-				BytesMoved:  meta.Size,
-				TablesMoved: 1,
-			},
+			c.startLevel.level:  startMetrics,
+			c.outputLevel.level: outputMetrics,
 		}
 		ve := &versionEdit{
-			DeletedFiles: map[deletedFileEntry]*fileMetadata{
-				{Level: c.startLevel.level, FileNum: meta.FileNum}: meta,
-			},
-			NewFiles: []newFileEntry{
-				{Level: c.outputLevel.level, Meta: meta},
-			},
+			DeletedFiles: map[deletedFileEntry]*fileMetadata{},
+		}
+		iter := c.startLevel.files.Iter()
+		for meta := iter.First(); meta != nil; meta = iter.Next() {
+			startMetrics.NumFiles--
+			startMetrics.Size -= int64(meta.Size)
+			outputMetrics.NumFiles++
+			outputMetrics.Size += int64(meta.Size)
+			outputMetrics.BytesMoved += meta.Size
+			outputMetrics.TablesMoved++
+			ve.DeletedFiles[deletedFileEntry{Level: c.startLevel.level, FileNum: meta.FileNum}] = meta
+			ve.NewFiles = append(ve.NewFiles, newFileEntry{Level: c.outputLevel.level, Meta: meta})
 		}
 		return ve, nil, nil
 	}
@@ -2231,18 +2403,24 @@ func (d *DB) runCompaction(
 	c.allowedZeroSeqNum = c.allowZeroSeqNum()
 	iter := newCompactionIter(c.cmp, c.equal, c.formatKey, d.merge, iiter, snapshots,
 		&c.rangeDelFrag, &c.rangeKeyFrag, c.allowedZeroSeqNum, c.elideTombstone,
-		c.elideRangeTombstone, d.FormatMajorVersion())
+		c.elideRangeTombstone, atomic.LoadUint64(&d.atomic.gcFloorSeqNum), d.FormatMajorVersion(),
+		d.opts.Comparer.Split, d.opts.Experimental.MaxVersionsPerKey,
+		d.opts.Experimental.OnSingleDeleteRangeDel)
 
 	var (
 		filenames []string
 		tw        *sstable.Writer
+		// The size of the write buffer held by tw, if non-nil.
+		writeBufferSize = int64(d.opts.SSTableWriteBufferSize)
 	)
 	defer func() {
 		if iter != nil {
+			c.versionsElided = iter.versionsElided
 			retErr = firstError(retErr, iter.Close())
 		}
 		if tw != nil {
 			retErr = firstError(retErr, tw.Close())
+			d.releaseWriteBuffer(writeBufferSize)
 		}
 		if retErr != nil {
 			for _, filename := range filenames {
@@ -2282,6 +2460,7 @@ func (d *DB) runCompaction(
 		// Cannot yet write block properties.
 		writerOpts.BlockPropertyCollectors = nil
 	}
+	levelCompression := writerOpts.Compression
 
 	// prevPointKey is a sstable.WriterOption that provides access to
 	// the last point key written to a writer's sstable. When a new
@@ -2299,15 +2478,20 @@ func (d *DB) runCompaction(
 		}
 	}()
 
-	newOutput := func() error {
+	// newOutput starts a new output sstable, the smallest user key of which
+	// is lower.
+	newOutput := func(lower []byte) error {
 		fileMeta := &fileMetadata{}
 		d.mu.Lock()
 		fileNum := d.mu.versions.getNextFileNum()
 		fileMeta.FileNum = fileNum
+		if d.opts.SSTablePathFunc != nil {
+			fileMeta.Name = d.opts.SSTablePathFunc(fileNum)
+		}
 		pendingOutputs = append(pendingOutputs, fileMeta)
 		d.mu.Unlock()
 
-		filename := base.MakeFilepath(d.opts.FS, d.dirname, fileTypeTable, fileNum)
+		filename := makeTableFilepath(d.opts.FS, d.dirname, fileNum, fileMeta.Name)
 		file, err := d.opts.FS.Create(filename)
 		if err != nil {
 			return err
@@ -2340,7 +2524,14 @@ func (d *DB) runCompaction(
 		writerOpts.Parallelism =
 			d.opts.Experimental.MaxWriterConcurrency > 0 &&
 				(additionalCPUProcs > 0 || d.opts.Experimental.ForceWriterParallelism)
+		writerOpts.Compression = levelCompression
+		if fn := d.opts.Experimental.CompressionForKeyRange; fn != nil {
+			if compression := fn(lower, c.largest.UserKey); compression != DefaultCompression {
+				writerOpts.Compression = compression
+			}
+		}
 		tw = sstable.NewWriter(file, writerOpts, cacheOpts, internalTableOpt, &prevPointKey)
+		d.acquireWriteBuffer(writeBufferSize)
 
 		fileMeta.CreationTime = time.Now().Unix()
 		ve.NewFiles = append(ve.NewFiles, newFileEntry{
@@ -2392,7 +2583,7 @@ func (d *DB) runCompaction(
 		splitKey = append([]byte(nil), splitKey...)
 		for _, v := range iter.Tombstones(splitKey) {
 			if tw == nil {
-				if err := newOutput(); err != nil {
+				if err := newOutput(v.Start); err != nil {
 					return err
 				}
 			}
@@ -2427,7 +2618,7 @@ func (d *DB) runCompaction(
 		for _, v := range iter.RangeKeys(splitKey) {
 			// Same logic as for range tombstones, except added using tw.AddRangeKey.
 			if tw == nil {
-				if err := newOutput(); err != nil {
+				if err := newOutput(v.Start); err != nil {
 					return err
 				}
 			}
@@ -2440,7 +2631,9 @@ func (d *DB) runCompaction(
 			return nil
 		}
 
-		if err := tw.Close(); err != nil {
+		err := tw.Close()
+		d.releaseWriteBuffer(writeBufferSize)
+		if err != nil {
 			tw = nil
 			return err
 		}
@@ -2555,6 +2748,21 @@ func (d *DB) runCompaction(
 		return nil
 	}
 
+	var sizeSplitter compactionOutputSplitter = &fileSizeSplitter{maxFileSize: c.maxOutputFileSize}
+	if writerOpts.MaxKeysPerFile > 0 {
+		sizeSplitter = &splitterGroup{cmp: c.cmp, splitters: []compactionOutputSplitter{
+			sizeSplitter,
+			&keyCountSplitter{maxKeys: uint64(writerOpts.MaxKeysPerFile)},
+		}}
+	}
+	if writerOpts.RangeKeyFragmentPolicy == sstable.RangeKeyFragmentAvoidSplits {
+		sizeSplitter = &rangeKeySplitDeferrer{
+			splitter:    sizeSplitter,
+			frag:        &c.rangeKeyFrag,
+			maxFileSize: 2 * c.maxOutputFileSize,
+		}
+	}
+
 	// compactionOutputSplitters contain all logic to determine whether the
 	// compaction loop should stop writing to one output sstable and switch to
 	// a new one. Some splitters can wrap other splitters, and
@@ -2568,7 +2776,7 @@ func (d *DB) runCompaction(
 		// at a user key change boundary when doing a split.
 		&userKeyChangeSplitter{
 			cmp:      c.cmp,
-			splitter: &fileSizeSplitter{maxFileSize: c.maxOutputFileSize},
+			splitter: sizeSplitter,
 			unsafePrevUserKey: func() []byte {
 				// Return the largest point key written to tw or the start of
 				// the current range deletion in the fragmenter, whichever is
@@ -2595,6 +2803,7 @@ func (d *DB) runCompaction(
 	// to a grandparent file largest key, or nil. Taken together, these
 	// progress guarantees ensure that eventually the input iterator will be
 	// exhausted and the range tombstone fragments will all be flushed.
+	var reportedBytes uint64
 	for key, val := iter.First(); key != nil || !c.rangeDelFrag.Empty() || !c.rangeKeyFrag.Empty(); {
 		splitterSuggestion := splitter.onNewOutput(key)
 
@@ -2660,13 +2869,17 @@ func (d *DB) runCompaction(
 				continue
 			}
 			if tw == nil {
-				if err := newOutput(); err != nil {
+				if err := newOutput(key.UserKey); err != nil {
 					return nil, pendingOutputs, err
 				}
 			}
 			if err := tw.Add(*key, val); err != nil {
 				return nil, pendingOutputs, err
 			}
+			if c.bytesIterated-reportedBytes >= compactionProgressInterval {
+				reportedBytes = c.bytesIterated
+				d.reportCompactionProgress(c, false /* done */)
+			}
 		}
 
 		// A splitter requested a split, and we're ready to finish the output.
@@ -2713,10 +2926,20 @@ func (d *DB) runCompaction(
 		return nil, pendingOutputs, err
 	}
 
+	if d.opts.Experimental.ValidateOutputs {
+		for i := range ve.NewFiles {
+			if err := d.validateOutputTable(ve.NewFiles[i].Meta); err != nil {
+				atomic.AddInt64(&d.atomic.outputValidationFailures, 1)
+				return nil, pendingOutputs, errors.Wrapf(err, "pebble: validating output table %s", ve.NewFiles[i].Meta.FileNum)
+			}
+		}
+	}
+
 	// Refresh the disk available statistic whenever a compaction/flush
 	// completes, before re-acquiring the mutex.
 	_ = d.calculateDiskAvailableBytes()
 
+	d.reportCompactionProgress(c, true /* done */)
 	return ve, pendingOutputs, nil
 }
 
@@ -2749,6 +2972,84 @@ func validateVersionEdit(
 	return nil
 }
 
+// validateOutputTable reads back the sstable written for meta by a flush or
+// compaction, bypassing the block cache, and validates its block checksums,
+// the order of its point keys, and that its keys fall within meta's bounds. See
+// Options.Experimental.ValidateOutputs.
+func (d *DB) validateOutputTable(meta *fileMetadata) error {
+	f, err := d.opts.FS.Open(makeTableFilepath(d.opts.FS, d.dirname, meta.FileNum, meta.Name))
+	if err != nil {
+		return err
+	}
+	readerOpts := d.opts.MakeReaderOptions()
+	readerOpts.Cache = nil
+	r, err := sstable.NewReader(f, readerOpts)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	if err := r.ValidateBlockChecksums(); err != nil {
+		return err
+	}
+
+	format := d.opts.Comparer.FormatKey
+	outOfBounds := func(kind string, start, end InternalKey) error {
+		return base.CorruptionErrorf("%s [%s, %s] outside of table bounds [%s, %s]", kind,
+			start.Pretty(format), end.Pretty(format), meta.Smallest.Pretty(format), meta.Largest.Pretty(format))
+	}
+
+	iter, err := r.NewIter(nil /* lower */, nil /* upper */)
+	if err != nil {
+		return err
+	}
+	var prev InternalKey
+	var n int
+	for key, _ := iter.First(); key != nil; key, _ = iter.Next() {
+		if n > 0 && base.InternalCompare(d.cmp, prev, *key) >= 0 {
+			_ = iter.Close()
+			return base.CorruptionErrorf("keys out of order: %s, %s", prev.Pretty(format), key.Pretty(format))
+		}
+		if !meta.HasPointKeys || base.InternalCompare(d.cmp, *key, meta.SmallestPointKey) < 0 ||
+			base.InternalCompare(d.cmp, *key, meta.LargestPointKey) > 0 {
+			_ = iter.Close()
+			return outOfBounds("point key", *key, *key)
+		}
+		prev.UserKey = append(prev.UserKey[:0], key.UserKey...)
+		prev.Trailer = key.Trailer
+		n++
+	}
+	if err := iter.Close(); err != nil {
+		return err
+	}
+
+	validateSpans := func(kind string, iter keyspan.FragmentIterator, smallest, largest InternalKey, ok bool) error {
+		if iter == nil {
+			return nil
+		}
+		defer iter.Close()
+		for s := iter.First(); s != nil; s = iter.Next() {
+			start := s.SmallestKey()
+			end := s.LargestKey()
+			if !ok || base.InternalCompare(d.cmp, start, smallest) < 0 || base.InternalCompare(d.cmp, end, largest) > 0 {
+				return outOfBounds(kind, start, end)
+			}
+		}
+		return iter.Error()
+	}
+	rangeDelIter, err := r.NewRawRangeDelIter()
+	if err != nil {
+		return err
+	}
+	if err := validateSpans("range deletion", rangeDelIter, meta.SmallestPointKey, meta.LargestPointKey, meta.HasPointKeys); err != nil {
+		return err
+	}
+	rangeKeyIter, err := r.NewRawRangeKeyIter()
+	if err != nil {
+		return err
+	}
+	return validateSpans("range key", rangeKeyIter, meta.SmallestRangeKey, meta.LargestRangeKey, meta.HasRangeKeys)
+}
+
 // scanObsoleteFiles scans the filesystem for files that are no longer needed
 // and adds those to the internal lists of obsolete files. Note that the files
 // are not actually deleted by this method. A subsequent call to
@@ -2763,6 +3064,8 @@ func (d *DB) scanObsoleteFiles(list []string) {
 
 	liveFileNums := make(map[FileNum]struct{})
 	d.mu.versions.addLiveFileNums(liveFileNums)
+	liveTableNames := make(map[string]struct{})
+	d.mu.versions.addLiveTableNames(liveTableNames)
 	minUnflushedLogNum := d.mu.versions.minUnflushedLogNum
 	manifestFileNum := d.mu.versions.manifestFileNum
 
@@ -2774,6 +3077,21 @@ func (d *DB) scanObsoleteFiles(list []string) {
 	for _, filename := range list {
 		fileType, fileNum, ok := base.ParseFilename(d.opts.FS, filename)
 		if !ok {
+			// An sstable named by Options.SSTablePathFunc that isn't recorded in
+			// the manifest was left behind by a crash before its flush,
+			// compaction or ingestion was logged, or before it was deleted.
+			if d.opts.SSTablePathFunc == nil {
+				continue
+			}
+			if _, ok := liveTableNames[filename]; ok {
+				continue
+			}
+			if size, ok := d.isUnrecordedTable(filename); ok {
+				obsoleteTables = append(obsoleteTables, &fileMetadata{
+					Name: filename,
+					Size: size,
+				})
+			}
 			continue
 		}
 		switch fileType {
@@ -2829,6 +3147,33 @@ func (d *DB) scanObsoleteFiles(list []string) {
 	d.mu.versions.obsoleteOptions = merge(d.mu.versions.obsoleteOptions, obsoleteOptions)
 }
 
+// isUnrecordedTable returns whether the file with the given name in the DB
+// directory, which doesn't have the form of a DB file name, is an sstable,
+// along with its size. The DB directory must only hold sstables of the DB, so
+// such a file must have been named by Options.SSTablePathFunc.
+func (d *DB) isUnrecordedTable(filename string) (size uint64, ok bool) {
+	file, err := d.opts.FS.Open(d.opts.FS.PathJoin(d.dirname, filename))
+	if err != nil {
+		return 0, false
+	}
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return 0, false
+	}
+	// NewReader closes the file if it fails.
+	readerOpts := d.opts.MakeReaderOptions()
+	readerOpts.Cache = nil
+	r, err := sstable.NewReader(file, readerOpts)
+	if err != nil {
+		return 0, false
+	}
+	if err := r.Close(); err != nil {
+		return 0, false
+	}
+	return uint64(stat.Size()), true
+}
+
 // disableFileDeletions disables file deletions and then waits for any
 // in-progress deletion to finish. The caller is required to call
 // enableFileDeletions in order to enable file deletions again. It is ok for
@@ -2903,10 +3248,16 @@ func (d *DB) deleteObsoleteFiles(jobID int, waitForOngoing bool) {
 
 // obsoleteFile holds information about a file that needs to be deleted soon.
 type obsoleteFile struct {
+	fs       vfs.FS
 	dir      string
 	fileNum  base.FileNum
 	fileType fileType
 	fileSize uint64
+	// name is the name of an sstable named by Options.SSTablePathFunc, and
+	// is empty for files named by their file number. Sstables left behind by
+	// a crash before they were recorded in the manifest have a name but no
+	// file number.
+	name string
 }
 
 type fileInfo struct {
@@ -2917,11 +3268,11 @@ type fileInfo struct {
 // d.mu must be held when calling this, but the mutex may be dropped and
 // re-acquired during the course of this method.
 func (d *DB) doDeleteObsoleteFiles(jobID int) {
-	var obsoleteTables []fileInfo
+	var obsoleteTables []*fileMetadata
 
 	defer func() {
 		for _, tbl := range obsoleteTables {
-			delete(d.mu.versions.zombieTables, tbl.fileNum)
+			delete(d.mu.versions.zombieTables, tbl.FileNum)
 		}
 	}()
 
@@ -2938,13 +3289,30 @@ func (d *DB) doDeleteObsoleteFiles(jobID int) {
 			break
 		}
 	}
-
-	for _, table := range d.mu.versions.obsoleteTables {
-		obsoleteTables = append(obsoleteTables, fileInfo{
-			fileNum:  table.FileNum,
-			fileSize: table.Size,
-		})
+	// Logs in the secondary WAL directory configured by Options.WALFailover
+	// are deleted from that directory, and are never recycled.
+	var obsoleteSecondaryLogs []obsoleteFile
+	if len(d.mu.log.failover.secondaryLogs) > 0 {
+		var primaryLogs []fileInfo
+		for _, fi := range obsoleteLogs {
+			if _, ok := d.mu.log.failover.secondaryLogs[fi.fileNum]; !ok {
+				primaryLogs = append(primaryLogs, fi)
+				continue
+			}
+			delete(d.mu.log.failover.secondaryLogs, fi.fileNum)
+			loc := d.secondaryWALLocation()
+			obsoleteSecondaryLogs = append(obsoleteSecondaryLogs, obsoleteFile{
+				fs:       loc.fs,
+				dir:      loc.dirname,
+				fileNum:  fi.fileNum,
+				fileType: fileTypeLog,
+				fileSize: fi.fileSize,
+			})
+		}
+		obsoleteLogs = primaryLogs
 	}
+
+	obsoleteTables = d.mu.versions.obsoleteTables
 	d.mu.versions.obsoleteTables = nil
 
 	// Sort the manifests cause we want to delete some contiguous prefix
@@ -2977,13 +3345,31 @@ func (d *DB) doDeleteObsoleteFiles(jobID int) {
 		obsolete []fileInfo
 	}{
 		{fileTypeLog, obsoleteLogs},
-		{fileTypeTable, obsoleteTables},
+		{fileTypeTable, nil},
 		{fileTypeManifest, obsoleteManifests},
 		{fileTypeOptions, obsoleteOptions},
 	}
 	_, noRecycle := d.opts.Cleaner.(base.NeedsFileContents)
 	filesToDelete := make([]obsoleteFile, 0, len(files))
+	filesToDelete = append(filesToDelete, obsoleteSecondaryLogs...)
 	for _, f := range files {
+		if f.fileType == fileTypeTable {
+			// Sstables are kept as file metadata, since they may be named by
+			// Options.SSTablePathFunc. d.mu.versions.obsoleteTables is sorted by
+			// mergeFileMetas.
+			for _, table := range obsoleteTables {
+				d.tableCache.evict(table.FileNum)
+				filesToDelete = append(filesToDelete, obsoleteFile{
+					fs:       d.opts.FS,
+					dir:      d.dirname,
+					fileNum:  table.FileNum,
+					fileType: fileTypeTable,
+					fileSize: table.Size,
+					name:     table.Name,
+				})
+			}
+			continue
+		}
 		// We sort to make the order of deletions deterministic, which is nice for
 		// tests.
 		sort.Slice(f.obsolete, func(i, j int) bool {
@@ -3002,6 +3388,7 @@ func (d *DB) doDeleteObsoleteFiles(jobID int) {
 			}
 
 			filesToDelete = append(filesToDelete, obsoleteFile{
+				fs:       d.opts.FS,
 				dir:      dir,
 				fileNum:  fi.fileNum,
 				fileType: f.fileType,
@@ -3030,15 +3417,16 @@ func (d *DB) paceAndDeleteObsoleteFiles(jobID int, files []obsoleteFile) {
 	}
 
 	for _, of := range files {
-		path := base.MakeFilepath(d.opts.FS, of.dir, of.fileType, of.fileNum)
+		path := base.MakeFilepath(of.fs, of.dir, of.fileType, of.fileNum)
 		if of.fileType == fileTypeTable {
+			path = makeTableFilepath(of.fs, of.dir, of.fileNum, of.name)
 			_ = pacer.maybeThrottle(of.fileSize)
 			d.mu.Lock()
 			d.mu.versions.metrics.Table.ObsoleteCount--
 			d.mu.versions.metrics.Table.ObsoleteSize -= of.fileSize
 			d.mu.Unlock()
 		}
-		d.deleteObsoleteFile(of.fileType, jobID, path, of.fileNum)
+		d.deleteObsoleteFile(of.fs, of.fileType, jobID, path, of.fileNum)
 	}
 }
 
@@ -3067,10 +3455,30 @@ func (d *DB) maybeScheduleObsoleteTableDeletion() {
 }
 
 // deleteObsoleteFile deletes file that is no longer needed.
-func (d *DB) deleteObsoleteFile(fileType fileType, jobID int, path string, fileNum FileNum) {
+func (d *DB) deleteObsoleteFile(
+	fs vfs.FS, fileType fileType, jobID int, path string, fileNum FileNum,
+) {
+	// A cleaner that finishes asynchronously holds up Close until it's done
+	// with the file.
+	if c, ok := d.opts.Cleaner.(base.AsyncCleaner); ok {
+		d.deleters.Add(1)
+		c.CleanAsync(fs, fileType, path, func(err error) {
+			defer d.deleters.Done()
+			d.reportObsoleteFileCleaned(fileType, jobID, path, fileNum, err)
+		})
+		return
+	}
 	// TODO(peter): need to handle this error, probably by re-adding the
 	// file that couldn't be deleted to one of the obsolete slices map.
-	err := d.opts.Cleaner.Clean(d.opts.FS, fileType, path)
+	err := d.opts.Cleaner.Clean(fs, fileType, path)
+	d.reportObsoleteFileCleaned(fileType, jobID, path, fileNum, err)
+}
+
+// reportObsoleteFileCleaned notifies the event listener of the cleaning of
+// an obsolete file.
+func (d *DB) reportObsoleteFileCleaned(
+	fileType fileType, jobID int, path string, fileNum FileNum, err error,
+) {
 	if oserror.IsNotExist(err) {
 		return
 	}
@@ -3127,12 +3535,15 @@ func mergeFileMetas(a, b []*fileMetadata) []*fileMetadata {
 
 	a = append(a, b...)
 	sort.Slice(a, func(i, j int) bool {
-		return a[i].FileNum < a[j].FileNum
+		if a[i].FileNum != a[j].FileNum {
+			return a[i].FileNum < a[j].FileNum
+		}
+		return a[i].Name < a[j].Name
 	})
 
 	n := 0
 	for i := 0; i < len(a); i++ {
-		if n == 0 || a[i].FileNum != a[n-1].FileNum {
+		if n == 0 || a[i].FileNum != a[n-1].FileNum || a[i].Name != a[n-1].Name {
 			a[n] = a[i]
 			n++
 		}
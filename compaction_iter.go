@@ -214,9 +214,29 @@ type compactionIter struct {
 	allowZeroSeqNum     bool
 	elideTombstone      func(key []byte) bool
 	elideRangeTombstone func(start, end []byte) bool
+	// Point and range deletions with sequence numbers greater than or equal to
+	// gcFloorSeqNum are never elided. Zero if there is no such restriction. See
+	// Options.Experimental.GCFloorSeqNum.
+	gcFloorSeqNum uint64
 	// The on-disk format major version. This informs the types of keys that
 	// may be written to disk during a compaction.
 	formatVersion FormatMajorVersion
+	// If non-zero, the number of versions of each prefix, as determined by
+	// split, that are retained. See Options.Experimental.MaxVersionsPerKey.
+	maxVersionsPerKey int
+	split             Split
+	// The prefix and user key of the last user key considered for version
+	// elision, and the number of versions of the prefix visible to all
+	// snapshots returned so far.
+	versionPrefix  []byte
+	versionKeyBuf  []byte
+	versionCount   int
+	versionsElided int64
+	// onSingleDeleteRangeDel configures the handling of a SINGLEDEL whose
+	// next older entry is deleted by a range deletion. See
+	// Options.Experimental.OnSingleDeleteRangeDel.
+	onSingleDeleteRangeDel SingleDeleteRangeDelAction
+	formatKey              base.FormatKey
 }
 
 func newCompactionIter(
@@ -231,7 +251,11 @@ func newCompactionIter(
 	allowZeroSeqNum bool,
 	elideTombstone func(key []byte) bool,
 	elideRangeTombstone func(start, end []byte) bool,
+	gcFloorSeqNum uint64,
 	formatVersion FormatMajorVersion,
+	split Split,
+	maxVersionsPerKey int,
+	onSingleDeleteRangeDel SingleDeleteRangeDelAction,
 ) *compactionIter {
 	i := &compactionIter{
 		equal:               equal,
@@ -243,7 +267,13 @@ func newCompactionIter(
 		allowZeroSeqNum:     allowZeroSeqNum,
 		elideTombstone:      elideTombstone,
 		elideRangeTombstone: elideRangeTombstone,
+		gcFloorSeqNum:       gcFloorSeqNum,
 		formatVersion:       formatVersion,
+		split:               split,
+		maxVersionsPerKey:   maxVersionsPerKey,
+		formatKey:           formatKey,
+
+		onSingleDeleteRangeDel: onSingleDeleteRangeDel,
 	}
 	i.rangeDelFrag.Cmp = cmp
 	i.rangeDelFrag.Format = formatKey
@@ -333,11 +363,19 @@ func (i *compactionIter) Next() (*InternalKey, []byte) {
 			continue
 		}
 
+		if i.maxVersionsPerKey > 0 && i.elideVersion() {
+			i.versionsElided++
+			i.saveKey()
+			i.skipInStripe()
+			continue
+		}
+
 		switch i.iterKey.Kind() {
 		case InternalKeyKindDelete, InternalKeyKindSingleDelete:
 			// If we're at the last snapshot stripe and the tombstone can be elided
 			// skip skippable keys in the same stripe.
-			if i.curSnapshotIdx == 0 && i.elideTombstone(i.iterKey.UserKey) {
+			if i.curSnapshotIdx == 0 && i.belowGCFloor(i.iterKey.SeqNum()) &&
+				i.elideTombstone(i.iterKey.UserKey) {
 				i.saveKey()
 				i.skipInStripe()
 				continue
@@ -354,6 +392,8 @@ func (i *compactionIter) Next() (*InternalKey, []byte) {
 			case InternalKeyKindSingleDelete:
 				if i.singleDeleteNext() {
 					return &i.key, i.value
+				} else if i.err != nil {
+					return nil, nil
 				}
 
 				continue
@@ -717,6 +757,11 @@ func (i *compactionIter) mergeNext(valueMerger ValueMerger) stripeChangeType {
 	}
 }
 
+// ErrSingleDeleteRangeDel is wrapped by the error with which a compaction fails
+// when it finds a SINGLEDEL of a key deleted by a range deletion, if
+// Options.Experimental.OnSingleDeleteRangeDel is SingleDeleteRangeDelFail.
+var ErrSingleDeleteRangeDel = errors.New("pebble: SINGLEDEL of a key deleted by a range deletion")
+
 func (i *compactionIter) singleDeleteNext() bool {
 	// Save the current key.
 	i.saveKey()
@@ -731,6 +776,23 @@ func (i *compactionIter) singleDeleteNext() bool {
 		}
 
 		key := i.iterKey
+		if i.onSingleDeleteRangeDel != SingleDeleteRangeDelConsume &&
+			i.rangeDelFrag.Covers(*key, i.curSnapshotSeqNum) {
+			// The key was deleted by a range deletion sorting between it and
+			// the SingleDelete, which is itself not deleted by a range deletion
+			// since it would have been skipped otherwise.
+			if i.onSingleDeleteRangeDel == SingleDeleteRangeDelFail {
+				i.err = errors.Wrapf(ErrSingleDeleteRangeDel, "SINGLEDEL %s meets %s",
+					i.key.Pretty(i.formatKey), key.Pretty(i.formatKey))
+				i.valid = false
+				return false
+			}
+			// Transform the SingleDelete into a full Delete, which skips the
+			// rest of the stripe.
+			i.key.SetKind(InternalKeyKindDelete)
+			i.skip = true
+			return true
+		}
 		switch key.Kind() {
 		case InternalKeyKindDelete, InternalKeyKindMerge, InternalKeyKindSetWithDelete:
 			// We've hit a Delete, Merge or SetWithDelete, transform the
@@ -840,7 +902,8 @@ func (i *compactionIter) emitRangeDelChunk(fragmented keyspan.Span) {
 		if currentIdx == idx {
 			continue
 		}
-		if idx == 0 && i.elideRangeTombstone(fragmented.Start, fragmented.End) {
+		if idx == 0 && i.belowGCFloor(k.SeqNum()) &&
+			i.elideRangeTombstone(fragmented.Start, fragmented.End) {
 			// This is the last snapshot stripe and the range tombstone
 			// can be elided.
 			break
@@ -862,6 +925,49 @@ func (i *compactionIter) emitRangeDelChunk(fragmented keyspan.Span) {
 	}
 }
 
+// belowGCFloor returns true if a deletion with the provided sequence number is
+// permitted to be elided by the GC floor.
+func (i *compactionIter) belowGCFloor(seqNum uint64) bool {
+	return i.gcFloorSeqNum == 0 || seqNum < i.gcFloorSeqNum
+}
+
+// elideVersion returns true if the current key is a version of its prefix
+// that may be elided because at least maxVersionsPerKey newer versions of the
+// prefix have been returned, all of them visible to every snapshot. Only the
+// newest entry of each user key is considered: once a version is elided, all
+// of its older entries are skipped along with it. A version is counted if its
+// newest entry is a SET, SETWITHDEL or MERGE in the last snapshot stripe.
+func (i *compactionIter) elideVersion() bool {
+	key := i.iterKey
+	if i.versionKeyBuf != nil && i.equal(key.UserKey, i.versionKeyBuf) {
+		// An older entry of a user key that has already been considered.
+		return false
+	}
+	i.versionKeyBuf = append(i.versionKeyBuf[:0], key.UserKey...)
+	prefix := key.UserKey
+	if i.split != nil {
+		prefix = key.UserKey[:i.split(key.UserKey)]
+	}
+	if i.versionPrefix == nil || !i.equal(prefix, i.versionPrefix) {
+		i.versionPrefix = append(i.versionPrefix[:0], prefix...)
+		i.versionCount = 0
+	}
+	if i.curSnapshotIdx != 0 {
+		// Some snapshot cannot observe this entry, so it's neither counted
+		// nor elided.
+		return false
+	}
+	if i.versionCount >= i.maxVersionsPerKey && i.belowGCFloor(key.SeqNum()) &&
+		i.elideTombstone(key.UserKey) {
+		return true
+	}
+	switch key.Kind() {
+	case InternalKeyKindSet, InternalKeyKindSetWithDelete, InternalKeyKindMerge:
+		i.versionCount++
+	}
+	return false
+}
+
 func (i *compactionIter) emitRangeKeyChunk(fragmented keyspan.Span) {
 	// Elision of snapshot stripes happens in rangeKeyCompactionTransform, so no need to
 	// do that here.
@@ -81,6 +81,8 @@ func TestCompactionIter(t *testing.T) {
 	var snapshots []uint64
 	var elideTombstones bool
 	var allowZeroSeqnum bool
+	var gcFloorSeqNum uint64
+	var onSingleDeleteRangeDel SingleDeleteRangeDelAction
 	var interleavingIter *keyspan.InterleavingIter
 
 	// The input to the data-driven test is dependent on the format major
@@ -130,7 +132,11 @@ func TestCompactionIter(t *testing.T) {
 			func(_, _ []byte) bool {
 				return elideTombstones
 			},
+			gcFloorSeqNum,
 			formatVersion,
+			nil, /* split */
+			0,   /* maxVersionsPerKey */
+			onSingleDeleteRangeDel,
 		)
 	}
 
@@ -164,6 +170,8 @@ func TestCompactionIter(t *testing.T) {
 				snapshots = snapshots[:0]
 				elideTombstones = false
 				allowZeroSeqnum = false
+				gcFloorSeqNum = 0
+				onSingleDeleteRangeDel = SingleDeleteRangeDelConsume
 				for _, arg := range d.CmdArgs {
 					switch arg.Key {
 					case "snapshots":
@@ -186,6 +194,23 @@ func TestCompactionIter(t *testing.T) {
 						if err != nil {
 							return err.Error()
 						}
+					case "gc-floor":
+						var err error
+						gcFloorSeqNum, err = strconv.ParseUint(arg.Vals[0], 10, 64)
+						if err != nil {
+							return err.Error()
+						}
+					case "single-del-range-del":
+						switch arg.Vals[0] {
+						case "consume":
+							onSingleDeleteRangeDel = SingleDeleteRangeDelConsume
+						case "to-delete":
+							onSingleDeleteRangeDel = SingleDeleteRangeDelToDelete
+						case "fail":
+							onSingleDeleteRangeDel = SingleDeleteRangeDelFail
+						default:
+							return fmt.Sprintf("%s: unknown action: %s", d.Cmd, arg.Vals[0])
+						}
 					default:
 						return fmt.Sprintf("%s: unknown arg: %s", d.Cmd, arg.Key)
 					}
@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"math"
 	"sort"
+	"sync/atomic"
 
 	"github.com/cockroachdb/pebble/internal/base"
 	"github.com/cockroachdb/pebble/internal/humanize"
@@ -32,6 +33,7 @@ type compactionPicker interface {
 	getScores([]compactionInfo) [numLevels]float64
 	getBaseLevel() int
 	getEstimatedMaxWAmp() float64
+	getLevelMultiplier() float64
 	estimatedCompactionDebt(l0ExtraSize uint64) uint64
 	pickAuto(env compactionEnv) (pc *pickedCompaction)
 	pickManual(env compactionEnv, manual *manualCompaction) (c *pickedCompaction, retryLater bool)
@@ -579,12 +581,15 @@ type candidateLevelInfo struct {
 // compensatedSize returns f's file size, inflated according to compaction
 // priorities.
 func compensatedSize(f *fileMetadata) uint64 {
-	sz := f.Size
 	// Add in the estimate of disk space that may be reclaimed by compacting
 	// the file's tombstones.
-	sz += f.Stats.PointDeletionsBytesEstimate
-	sz += f.Stats.RangeDeletionsBytesEstimate
-	return sz
+	return f.Size + reclaimableSize(f)
+}
+
+// reclaimableSize returns the estimate of the disk space that may be
+// reclaimed by compacting f's tombstones.
+func reclaimableSize(f *fileMetadata) uint64 {
+	return f.Stats.PointDeletionsBytesEstimate + f.Stats.RangeDeletionsBytesEstimate
 }
 
 // compensatedSizeAnnotator implements manifest.Annotator, annotating B-Tree
@@ -647,6 +652,10 @@ type compactionPickerByScore struct {
 	// added to L0.
 	estimatedMaxWAmp float64
 
+	// levelMultiplier is the ratio between the max bytes of adjacent levels
+	// at and below the base level.
+	levelMultiplier float64
+
 	// levelMaxBytes holds the dynamically adjusted max bytes setting for each
 	// level.
 	levelMaxBytes [numLevels]int64
@@ -686,9 +695,19 @@ func (p *compactionPickerByScore) getBaseLevel() int {
 }
 
 func (p *compactionPickerByScore) getEstimatedMaxWAmp() float64 {
+	if p == nil {
+		return 0
+	}
 	return p.estimatedMaxWAmp
 }
 
+func (p *compactionPickerByScore) getLevelMultiplier() float64 {
+	if p == nil {
+		return 0
+	}
+	return p.levelMultiplier
+}
+
 // estimatedCompactionDebt estimates the number of bytes which need to be
 // compacted before the LSM tree becomes stable.
 func (p *compactionPickerByScore) estimatedCompactionDebt(l0ExtraSize uint64) uint64 {
@@ -728,6 +747,54 @@ func (p *compactionPickerByScore) estimatedCompactionDebt(l0ExtraSize uint64) ui
 	return compactionDebt
 }
 
+// smoothedLevelMultiplier returns the ratio between the max bytes of adjacent
+// levels that grows a base level of baseBytesMax bytes into a bottom level of
+// bottomLevelSize bytes.
+func smoothedLevelMultiplier(bottomLevelSize, baseBytesMax int64, baseLevel int) float64 {
+	if baseLevel >= numLevels-1 {
+		return 1.0
+	}
+	return math.Pow(float64(bottomLevelSize)/float64(baseBytesMax),
+		1.0/float64(numLevels-baseLevel-1))
+}
+
+// estimatedMaxWAmp returns the estimated maximum write amp per byte added to
+// L0, given the base level and the multiplier between adjacent levels. Each
+// byte is written once into each level from the base level down, and each
+// compaction into a level also rewrites the overlapping data of that level,
+// which is on average levelMultiplier times larger.
+func estimatedMaxWAmp(baseLevel int, levelMultiplier float64) float64 {
+	return float64(numLevels-baseLevel) * (levelMultiplier + 1)
+}
+
+// tuneBaseLevel chooses the base level, and with it the level multiplier, in
+// pursuit of Options.Experimental.TargetWriteAmp. Fewer levels imply a lower
+// read amp, but larger multipliers and a higher write amp, up to the point
+// where the multiplier falls below e. The deepest base level whose estimated
+// write amp is at most the target is chosen, or the base level minimizing the
+// estimated write amp if no level meets the target. The base level may not be
+// deeper than the first non-empty level, or the bottom level.
+func tuneBaseLevel(
+	bottomLevelSize, baseBytesMax int64, firstNonEmptyLevel int, target float64,
+) (baseLevel int, levelMultiplier float64) {
+	maxBaseLevel := firstNonEmptyLevel
+	if maxBaseLevel > numLevels-2 {
+		maxBaseLevel = numLevels - 2
+	}
+	var minWAmp float64
+	for level := maxBaseLevel; level >= 1; level-- {
+		m := smoothedLevelMultiplier(bottomLevelSize, baseBytesMax, level)
+		wamp := estimatedMaxWAmp(level, m)
+		if wamp <= target {
+			return level, m
+		}
+		if level == maxBaseLevel || wamp < minWAmp {
+			baseLevel, levelMultiplier, minWAmp = level, m, wamp
+		}
+	}
+	return baseLevel, levelMultiplier
+}
+
 func (p *compactionPickerByScore) initLevelMaxBytes(inProgressCompactions []compactionInfo) {
 	// The levelMaxBytes calculations here differ from RocksDB in two ways:
 	//
@@ -800,14 +867,14 @@ func (p *compactionPickerByScore) initLevelMaxBytes(inProgressCompactions []comp
 		curLevelSize = int64(float64(curLevelSize) / levelMultiplier)
 	}
 
-	smoothedLevelMultiplier := 1.0
-	if p.baseLevel < numLevels-1 {
-		smoothedLevelMultiplier = math.Pow(
-			float64(bottomLevelSize)/float64(baseBytesMax),
-			1.0/float64(numLevels-p.baseLevel-1))
+	smoothedLevelMultiplier := smoothedLevelMultiplier(bottomLevelSize, baseBytesMax, p.baseLevel)
+	if target := p.opts.Experimental.TargetWriteAmp; target > 0 && p.baseLevel < numLevels-1 {
+		p.baseLevel, smoothedLevelMultiplier = tuneBaseLevel(
+			bottomLevelSize, baseBytesMax, firstNonEmptyLevel, target)
 	}
 
-	p.estimatedMaxWAmp = float64(numLevels-p.baseLevel) * (smoothedLevelMultiplier + 1)
+	p.levelMultiplier = smoothedLevelMultiplier
+	p.estimatedMaxWAmp = estimatedMaxWAmp(p.baseLevel, smoothedLevelMultiplier)
 
 	levelSize := float64(baseBytesMax)
 	for level := p.baseLevel; level < numLevels; level++ {
@@ -978,6 +1045,9 @@ func (p *compactionPickerByScore) pickFile(
 
 	var file manifest.LevelFile
 	smallestRatio := uint64(math.MaxUint64)
+	// With read-driven compaction, the number of reads of the chosen file
+	// breaks ties between files with the same ratio.
+	var fileReads int64
 
 	outputFile := outputIter.First()
 
@@ -1022,9 +1092,19 @@ func (p *compactionPickerByScore) pickFile(
 			continue
 		}
 
-		scaledRatio := overlappingBytes * 1024 / compensatedSize(f)
-		if scaledRatio < smallestRatio && !f.Compacting {
+		size := compensatedSize(f)
+		if priority := p.opts.Experimental.SpaceReclamationPriority; priority > 0 {
+			size += uint64(priority * float64(reclaimableSize(f)))
+		}
+		scaledRatio := overlappingBytes * 1024 / size
+		var reads int64
+		if p.opts.Experimental.ReadDrivenCompaction {
+			reads = atomic.LoadInt64(&f.Atomic.ReadCount)
+		}
+		if (scaledRatio < smallestRatio || (scaledRatio == smallestRatio && reads > fileReads)) &&
+			!f.Compacting {
 			smallestRatio = scaledRatio
+			fileReads = reads
 			file = startIter.Take()
 		}
 	}
@@ -1127,7 +1207,8 @@ func (p *compactionPickerByScore) pickAuto(env compactionEnv) (pc *pickedCompact
 			pc = pickL0(env, p.opts, p.vers, p.baseLevel, p.diskAvailBytes)
 			// Fail-safe to protect against compacting the same sstable
 			// concurrently.
-			if pc != nil && !inputRangeAlreadyCompacting(env, pc) {
+			if pc != nil && !inputRangeAlreadyCompacting(env, pc) &&
+				!outputLevelAtConcurrencyLimit(env, p.opts, pc) {
 				pc.score = info.score
 				// TODO(peter): remove
 				if false {
@@ -1147,7 +1228,8 @@ func (p *compactionPickerByScore) pickAuto(env compactionEnv) (pc *pickedCompact
 
 		pc := pickAutoLPositive(env, p.opts, p.vers, *info, p.baseLevel, p.diskAvailBytes, p.levelMaxBytes)
 		// Fail-safe to protect against compacting the same sstable concurrently.
-		if pc != nil && !inputRangeAlreadyCompacting(env, pc) {
+		if pc != nil && !inputRangeAlreadyCompacting(env, pc) &&
+			!outputLevelAtConcurrencyLimit(env, p.opts, pc) {
 			pc.score = info.score
 			// TODO(peter): remove
 			if false {
@@ -1165,6 +1247,10 @@ func (p *compactionPickerByScore) pickAuto(env compactionEnv) (pc *pickedCompact
 		return pc
 	}
 
+	if pc := p.pickSpaceReclamationCompaction(env); pc != nil {
+		return pc
+	}
+
 	if pc := p.pickReadTriggeredCompaction(env); pc != nil {
 		return pc
 	}
@@ -1343,12 +1429,56 @@ func (p *compactionPickerByScore) pickElisionOnlyCompaction(
 	}
 	pc.smallest, pc.largest = manifest.KeyRange(pc.cmp, pc.startLevel.files.Iter())
 	// Fail-safe to protect against compacting the same sstable concurrently.
-	if !inputRangeAlreadyCompacting(env, pc) {
+	if !inputRangeAlreadyCompacting(env, pc) && !outputLevelAtConcurrencyLimit(env, p.opts, pc) {
 		return pc
 	}
 	return nil
 }
 
+// pickSpaceReclamationCompaction looks for a compaction of a file outside of L0
+// and the bottommost level whose tombstones are estimated to reclaim a large
+// fraction of its size, as configured by
+// Options.Experimental.SpaceReclamationPriority. The file with the most
+// reclaimable bytes is compacted into the next level.
+func (p *compactionPickerByScore) pickSpaceReclamationCompaction(
+	env compactionEnv,
+) (pc *pickedCompaction) {
+	priority := p.opts.Experimental.SpaceReclamationPriority
+	if priority <= 0 {
+		return nil
+	}
+	var candidate candidateLevelInfo
+	var candidateSize uint64
+	for level := p.baseLevel; level < numLevels-1; level++ {
+		iter := p.vers.Levels[level].Iter()
+		for f := iter.First(); f != nil; f = iter.Next() {
+			if f.Compacting || !f.StatsValidLocked() {
+				continue
+			}
+			size := reclaimableSize(f)
+			if size <= candidateSize || priority*float64(size) < float64(f.Size) {
+				continue
+			}
+			candidate = candidateLevelInfo{
+				level:       level,
+				outputLevel: level + 1,
+				file:        iter.Take(),
+			}
+			candidateSize = size
+		}
+	}
+	if candidate.file.FileMetadata == nil {
+		return nil
+	}
+	pc = pickAutoLPositive(env, p.opts, p.vers, candidate, p.baseLevel, p.diskAvailBytes, p.levelMaxBytes)
+	// Fail-safe to protect against compacting the same sstable concurrently.
+	if pc == nil || inputRangeAlreadyCompacting(env, pc) || outputLevelAtConcurrencyLimit(env, p.opts, pc) {
+		return nil
+	}
+	pc.kind = compactionKindSpaceReclamation
+	return pc
+}
+
 // pickRewriteCompaction attempts to construct a compaction that
 // rewrites a file marked for compaction. pickRewriteCompaction will
 // pull in adjacent files in the file's atomic compaction unit if
@@ -1402,7 +1532,7 @@ func (p *compactionPickerByScore) pickRewriteCompaction(env compactionEnv) (pc *
 		pc.smallest, pc.largest = manifest.KeyRange(pc.cmp, pc.startLevel.files.Iter())
 
 		// Fail-safe to protect against compacting the same sstable concurrently.
-		if !inputRangeAlreadyCompacting(env, pc) {
+		if !inputRangeAlreadyCompacting(env, pc) && !outputLevelAtConcurrencyLimit(env, p.opts, pc) {
 			if pc.startLevel.level == 0 {
 				pc.l0SublevelInfo = generateSublevelInfo(pc.cmp, pc.startLevel.files)
 			}
@@ -1518,6 +1648,9 @@ func (p *compactionPickerByScore) pickManual(
 	if p == nil {
 		return nil, false
 	}
+	if manual.rebalance {
+		return p.pickRebalance(env, manual)
+	}
 
 	outputLevel := manual.level + 1
 	if manual.level == 0 {
@@ -1557,6 +1690,36 @@ func (p *compactionPickerByScore) pickManual(
 	return pc, false
 }
 
+// pickRebalance picks a rewrite compaction of the files overlapping the
+// manual compaction's key range into the same level. See DB.RebalanceLevel.
+func (p *compactionPickerByScore) pickRebalance(
+	env compactionEnv, manual *manualCompaction,
+) (pc *pickedCompaction, retryLater bool) {
+	if conflictsWithInProgress(manual, manual.level, env.inProgressCompactions, p.opts.Comparer.Compare) {
+		return nil, true
+	}
+	files := p.vers.Overlaps(manual.level, p.opts.Comparer.Compare, manual.start, manual.end, false /* exclusiveEnd */)
+	if files.Empty() {
+		// The files were compacted out of the level.
+		return nil, false
+	}
+	inputs, isCompacting := expandToAtomicUnit(p.opts.Comparer.Compare, files, false /* disableIsCompacting */)
+	if isCompacting {
+		return nil, true
+	}
+	pc = newPickedCompaction(p.opts, p.vers, manual.level, manual.level, p.baseLevel)
+	pc.outputLevel.level = manual.level
+	pc.kind = compactionKindRewrite
+	pc.startLevel.files = inputs
+	pc.smallest, pc.largest = manifest.KeyRange(pc.cmp, pc.startLevel.files.Iter())
+	manual.outputLevel = manual.level
+	// Fail-safe to protect against compacting the same sstable concurrently.
+	if inputRangeAlreadyCompacting(env, pc) {
+		return nil, true
+	}
+	return pc, false
+}
+
 func pickManualHelper(
 	opts *Options,
 	manual *manualCompaction,
@@ -1633,7 +1796,7 @@ func pickReadTriggeredCompactionHelper(
 	if !pc.setupInputs(p.opts, p.diskAvailBytes(), pc.startLevel) {
 		return nil
 	}
-	if inputRangeAlreadyCompacting(env, pc) {
+	if inputRangeAlreadyCompacting(env, pc) || outputLevelAtConcurrencyLimit(env, p.opts, pc) {
 		return nil
 	}
 	pc.kind = compactionKindRead
@@ -1717,6 +1880,24 @@ func inputRangeAlreadyCompacting(env compactionEnv, pc *pickedCompaction) bool {
 	return false
 }
 
+// outputLevelAtConcurrencyLimit returns true if the number of in-progress
+// compactions outputting to the output level of pc has reached the level's
+// LevelOptions.MaxCompactionConcurrency. Manual compactions are not subject to
+// the limit.
+func outputLevelAtConcurrencyLimit(env compactionEnv, opts *Options, pc *pickedCompaction) bool {
+	limit := opts.Level(pc.outputLevel.level).MaxCompactionConcurrency
+	if limit <= 0 {
+		return false
+	}
+	var n int
+	for i := range env.inProgressCompactions {
+		if env.inProgressCompactions[i].outputLevel == pc.outputLevel.level {
+			n++
+		}
+	}
+	return n >= limit
+}
+
 // conflictsWithInProgress checks if there are any in-progress compactions with overlapping keyspace.
 func conflictsWithInProgress(
 	manual *manualCompaction, outputLevel int, inProgressCompactions []compactionInfo, cmp Compare,
@@ -505,9 +505,29 @@ func TestCompactionPickerL0(t *testing.T) {
 			}
 			return buf.String()
 		case "pick-auto":
+			// Per-level concurrency limits only apply to the pick-auto
+			// command specifying them.
+			opts.Levels = opts.Levels[:1]
+			opts.Levels[0].MaxCompactionConcurrency = 0
 			for _, arg := range td.CmdArgs {
 				var err error
 				switch arg.Key {
+				case "max_compaction_concurrency":
+					// max_compaction_concurrency=(<level>, <limit>)
+					if len(arg.Vals) != 2 {
+						return "max_compaction_concurrency requires a level and a limit"
+					}
+					level, err := strconv.Atoi(arg.Vals[0])
+					if err != nil {
+						return err.Error()
+					}
+					for len(opts.Levels) <= level {
+						opts.Levels = append(opts.Levels, opts.Level(len(opts.Levels)))
+					}
+					opts.Levels[level].MaxCompactionConcurrency, err = strconv.Atoi(arg.Vals[1])
+					if err != nil {
+						return err.Error()
+					}
 				case "l0_compaction_threshold":
 					opts.L0CompactionThreshold, err = strconv.Atoi(arg.Vals[0])
 					if err != nil {
@@ -741,9 +761,29 @@ func TestCompactionPickerConcurrency(t *testing.T) {
 			return buf.String()
 
 		case "pick-auto":
+			// Per-level concurrency limits only apply to the pick-auto
+			// command specifying them.
+			opts.Levels = opts.Levels[:1]
+			opts.Levels[0].MaxCompactionConcurrency = 0
 			for _, arg := range td.CmdArgs {
 				var err error
 				switch arg.Key {
+				case "max_compaction_concurrency":
+					// max_compaction_concurrency=(<level>, <limit>)
+					if len(arg.Vals) != 2 {
+						return "max_compaction_concurrency requires a level and a limit"
+					}
+					level, err := strconv.Atoi(arg.Vals[0])
+					if err != nil {
+						return err.Error()
+					}
+					for len(opts.Levels) <= level {
+						opts.Levels = append(opts.Levels, opts.Level(len(opts.Levels)))
+					}
+					opts.Levels[level].MaxCompactionConcurrency, err = strconv.Atoi(arg.Vals[1])
+					if err != nil {
+						return err.Error()
+					}
 				case "l0_compaction_threshold":
 					opts.L0CompactionThreshold, err = strconv.Atoi(arg.Vals[0])
 					if err != nil {
@@ -1249,6 +1289,117 @@ func TestCompactionOutputFileSize(t *testing.T) {
 	})
 }
 
+func TestCompactionPickerSpaceReclamation(t *testing.T) {
+	newFile := func(fileNum int, start, end string, size, reclaimable uint64) *fileMetadata {
+		m := (&fileMetadata{
+			FileNum: base.FileNum(fileNum),
+			Size:    size,
+		}).ExtendPointKeyBounds(
+			DefaultComparer.Compare,
+			base.ParseInternalKey(start),
+			base.ParseInternalKey(end),
+		)
+		m.SmallestSeqNum = m.Smallest.SeqNum()
+		m.LargestSeqNum = m.Largest.SeqNum()
+		m.Stats.NumEntries = 1
+		if reclaimable > 0 {
+			m.Stats.RangeDeletionsBytesEstimate = reclaimable
+			m.Stats.NumDeletions = 1
+		}
+		m.StatsMarkValid()
+		return m
+	}
+	pickAuto := func(priority float64, l5 ...*fileMetadata) *pickedCompaction {
+		opts := (&Options{}).EnsureDefaults()
+		opts.Experimental.SpaceReclamationPriority = priority
+		var files [numLevels][]*fileMetadata
+		files[5] = l5
+		files[6] = []*fileMetadata{newFile(1, "a.SET.1", "z.SET.1", 10<<20, 0)}
+		vers := newVersion(opts, files)
+		var sizes [numLevels]int64
+		for l := range sizes {
+			slice := vers.Levels[l].Slice()
+			sizes[l] = int64(slice.SizeSum())
+		}
+		p := newCompactionPicker(vers, opts, nil, sizes, diskAvailBytesInf).(*compactionPickerByScore)
+		return p.pickAuto(compactionEnv{
+			earliestUnflushedSeqNum: math.MaxUint64,
+			earliestSnapshotSeqNum:  math.MaxUint64,
+		})
+	}
+
+	// No level requires compaction, so the L5 files whose range deletions are
+	// estimated to delete data in L6 are only compacted under a space
+	// reclamation priority.
+	l5 := []*fileMetadata{
+		newFile(2, "b.RANGEDEL.10", "c.RANGEDEL.inf", 100, 5<<20),
+		newFile(3, "d.RANGEDEL.11", "e.RANGEDEL.inf", 100, 8<<20),
+		newFile(4, "f.SET.12", "g.SET.12", 1<<20, 0),
+	}
+	require.Nil(t, pickAuto(0, l5...))
+	pc := pickAuto(1, l5...)
+	require.NotNil(t, pc)
+	require.Equal(t, compactionKindSpaceReclamation, pc.kind)
+	require.Equal(t, 5, pc.startLevel.level)
+	require.Equal(t, 6, pc.outputLevel.level)
+	// The compaction is seeded with the file with the most reclaimable bytes,
+	// and its inputs expanded as usual.
+	require.Contains(t, fileNums(pc.startLevel.files), "000003")
+
+	// Files are only compacted if their reclaimable bytes, multiplied by the
+	// priority, are at least their size.
+	l5 = []*fileMetadata{newFile(2, "b.SET.10", "c.SET.10", 1000<<10, 100<<10)}
+	require.Nil(t, pickAuto(5, l5...))
+	pc = pickAuto(10, l5...)
+	require.NotNil(t, pc)
+	require.Equal(t, "000002", fileNums(pc.startLevel.files))
+}
+
+func TestCompactionPickerReadDriven(t *testing.T) {
+	newFile := func(fileNum int, start, end string, reads int64) *fileMetadata {
+		m := (&fileMetadata{
+			FileNum: base.FileNum(fileNum),
+			Size:    1 << 20,
+		}).ExtendPointKeyBounds(
+			DefaultComparer.Compare,
+			base.ParseInternalKey(start),
+			base.ParseInternalKey(end),
+		)
+		m.SmallestSeqNum = m.Smallest.SeqNum()
+		m.LargestSeqNum = m.Largest.SeqNum()
+		m.Atomic.ReadCount = reads
+		return m
+	}
+	pickFile := func(readDriven bool) string {
+		opts := (&Options{}).EnsureDefaults()
+		opts.Experimental.ReadDrivenCompaction = readDriven
+		var files [numLevels][]*fileMetadata
+		// The L5 files all overlap the same number of bytes in L6, so they
+		// tie on their overlapping ratio.
+		files[5] = []*fileMetadata{
+			newFile(1, "a.SET.10", "b.SET.10", 5),
+			newFile(2, "d.SET.11", "e.SET.11", 20),
+			newFile(3, "g.SET.12", "h.SET.12", 10),
+		}
+		files[6] = []*fileMetadata{
+			newFile(4, "a.SET.1", "b.SET.1", 0),
+			newFile(5, "d.SET.2", "e.SET.2", 0),
+			newFile(6, "g.SET.3", "h.SET.3", 0),
+		}
+		vers := newVersion(opts, files)
+		var sizes [numLevels]int64
+		p := newCompactionPicker(vers, opts, nil, sizes, diskAvailBytesInf).(*compactionPickerByScore)
+		f, ok := p.pickFile(5, 6, math.MaxUint64)
+		require.True(t, ok)
+		return f.FileNum.String()
+	}
+
+	// Ties are broken by the order of the files in the level, unless
+	// read-driven compaction prefers the most read file.
+	require.Equal(t, "000001", pickFile(false))
+	require.Equal(t, "000002", pickFile(true))
+}
+
 func fileNums(files manifest.LevelSlice) string {
 	var ss []string
 	files.Each(func(f *fileMetadata) {
@@ -1257,3 +1408,49 @@ func fileNums(files manifest.LevelSlice) string {
 	sort.Strings(ss)
 	return strings.Join(ss, ",")
 }
+
+func TestCompactionPickerTargetWriteAmp(t *testing.T) {
+	// A 100 GB bottom level grows from a 64 MB base level by a factor of
+	// 1440 across the levels below the base level.
+	var sizes [numLevels]int64
+	sizes[6] = 100 << 30
+	newPicker := func(target float64) *compactionPickerByScore {
+		opts := (&Options{}).EnsureDefaults()
+		opts.LBaseMaxBytes = 64 << 20
+		opts.Experimental.TargetWriteAmp = target
+		return newCompactionPicker(newVersion(opts, [numLevels][]*fileMetadata{}),
+			opts, nil, sizes, diskAvailBytesInf).(*compactionPickerByScore)
+	}
+
+	testCases := []struct {
+		target     float64
+		baseLevel  int
+		multiplier float64
+	}{
+		// By default, the ratio between levels is kept near 10.
+		{target: 0, baseLevel: 2, multiplier: 6.16},
+		{target: 200, baseLevel: 4, multiplier: 37.95},
+		{target: 50, baseLevel: 3, multiplier: 11.29},
+		{target: 40, baseLevel: 2, multiplier: 6.16},
+		{target: 32, baseLevel: 1, multiplier: 4.28},
+		// No level meets the target, and the level with the lowest estimated
+		// write amp is used.
+		{target: 10, baseLevel: 1, multiplier: 4.28},
+	}
+	for _, tc := range testCases {
+		t.Run(fmt.Sprint(tc.target), func(t *testing.T) {
+			p := newPicker(tc.target)
+			require.Equal(t, tc.baseLevel, p.getBaseLevel())
+			require.InDelta(t, tc.multiplier, p.getLevelMultiplier(), 0.01)
+			require.InDelta(t, float64(numLevels-tc.baseLevel)*(tc.multiplier+1),
+				p.getEstimatedMaxWAmp(), 0.1)
+			if tc.target > 0 && tc.baseLevel > 1 {
+				require.LessOrEqual(t, p.getEstimatedMaxWAmp(), tc.target)
+			}
+			for level := tc.baseLevel + 1; level < numLevels; level++ {
+				require.InDelta(t, p.getLevelMultiplier(),
+					float64(p.levelMaxBytes[level])/float64(p.levelMaxBytes[level-1]), 0.01)
+			}
+		})
+	}
+}
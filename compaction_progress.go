@@ -0,0 +1,106 @@
+// Copyright 2026 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package pebble
+
+import (
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// compactionProgressInterval is the number of bytes iterated by a compaction
+// between publications of its progress.
+const compactionProgressInterval = 1 << 20 // 1 MB
+
+// CompactionProgress describes the progress of a flush or compaction. The
+// byte counts are estimates derived from the sizes of the compaction's
+// inputs, and BytesProcessed may exceed the estimated total.
+type CompactionProgress struct {
+	// JobID is the ID of the flush or compaction job. It is zero if the
+	// compaction has been scheduled but has not yet begun running.
+	JobID int
+	// Reason is the kind of the compaction: "flush", "default", "move", etc.
+	Reason string
+	// StartLevel is the level being compacted, or -1 for a flush.
+	StartLevel int
+	// OutputLevel is the level to which the compaction's outputs are written.
+	OutputLevel int
+	// BytesProcessed is the number of input bytes that have been processed.
+	BytesProcessed uint64
+	// BytesRemaining is the estimated number of input bytes remaining to be
+	// processed.
+	BytesRemaining uint64
+	// Elapsed is the time since the compaction began running.
+	Elapsed time.Duration
+	// ETA is the estimated time remaining until the compaction completes,
+	// extrapolated from the rate at which it has processed its input so far.
+	// It is zero if the compaction has completed or no estimate is available.
+	ETA time.Duration
+}
+
+// CompactionsInProgress returns the progress of the flushes and compactions
+// in progress, ordered by job ID. The progress of a compaction is updated as
+// every megabyte of its input is processed.
+func (d *DB) CompactionsInProgress() []CompactionProgress {
+	now := d.timeNow()
+	d.mu.Lock()
+	progress := make([]CompactionProgress, 0, len(d.mu.compact.inProgress))
+	for c := range d.mu.compact.inProgress {
+		processed := atomic.LoadUint64(&c.atomic.bytesProcessed)
+		progress = append(progress, c.makeProgress(now, processed, false /* done */))
+	}
+	d.mu.Unlock()
+	sort.Slice(progress, func(i, j int) bool {
+		return progress[i].JobID < progress[j].JobID
+	})
+	return progress
+}
+
+// estimatedInputBytes returns the estimated number of bytes that will be
+// iterated by the compaction.
+func (c *compaction) estimatedInputBytes() uint64 {
+	var n uint64
+	for _, f := range c.flushing {
+		n += f.inuseBytes()
+	}
+	for i := range c.inputs {
+		n += c.inputs[i].files.SizeSum()
+	}
+	return n
+}
+
+// makeProgress returns the progress of the compaction, given the number of
+// input bytes processed.
+func (c *compaction) makeProgress(now time.Time, processed uint64, done bool) CompactionProgress {
+	p := CompactionProgress{
+		JobID:          c.jobID,
+		Reason:         c.kind.String(),
+		StartLevel:     c.startLevel.level,
+		OutputLevel:    c.outputLevel.level,
+		BytesProcessed: processed,
+	}
+	if c.jobID == 0 {
+		p.BytesRemaining = c.inputBytes
+		return p
+	}
+	p.Elapsed = now.Sub(c.beganAt)
+	if !done && c.inputBytes > processed {
+		p.BytesRemaining = c.inputBytes - processed
+		if processed > 0 {
+			p.ETA = time.Duration(float64(p.Elapsed) * float64(p.BytesRemaining) / float64(processed))
+		}
+	}
+	return p
+}
+
+// reportCompactionProgress publishes the progress of the compaction for
+// DB.CompactionsInProgress, and invokes the compaction's progress callback,
+// if any. It must be called from the goroutine running the compaction.
+func (d *DB) reportCompactionProgress(c *compaction, done bool) {
+	atomic.StoreUint64(&c.atomic.bytesProcessed, c.bytesIterated)
+	if c.progress != nil {
+		c.progress(c.makeProgress(d.timeNow(), c.bytesIterated, done))
+	}
+}
@@ -0,0 +1,118 @@
+// Copyright 2026 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package pebble
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/pebble/vfs"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/exp/rand"
+)
+
+func TestCompactionProgress(t *testing.T) {
+	d, err := Open("", &Options{
+		FS:                          vfs.NewMem(),
+		DisableAutomaticCompactions: true,
+	})
+	require.NoError(t, err)
+	defer func() { require.NoError(t, d.Close()) }()
+
+	// Write several megabytes of incompressible data so that the compaction
+	// reports its progress more than once.
+	rng := rand.New(rand.NewSource(1))
+	val := make([]byte, 1<<10)
+	for i := 0; i < 8<<10; i++ {
+		_, _ = rng.Read(val)
+		require.NoError(t, d.Set([]byte(fmt.Sprintf("%06d", i)), val, nil))
+	}
+	require.NoError(t, d.Flush())
+
+	var mu sync.Mutex
+	progressByJob := make(map[int][]CompactionProgress)
+	err = d.CompactWithProgress([]byte("0"), []byte("9"), false /* parallelize */, func(p CompactionProgress) {
+		// The progress reported to the callback is also published for
+		// CompactionsInProgress.
+		var found bool
+		for _, ip := range d.CompactionsInProgress() {
+			if ip.JobID == p.JobID {
+				require.Equal(t, p.BytesProcessed, ip.BytesProcessed)
+				found = true
+			}
+		}
+		require.True(t, found)
+
+		mu.Lock()
+		defer mu.Unlock()
+		progressByJob[p.JobID] = append(progressByJob[p.JobID], p)
+	})
+	require.NoError(t, err)
+
+	require.Len(t, progressByJob, 1)
+	for jobID, progress := range progressByJob {
+		require.NotZero(t, jobID)
+		require.Greater(t, len(progress), 2)
+		for i, p := range progress {
+			require.Equal(t, "default", p.Reason)
+			require.Equal(t, 0, p.StartLevel)
+			require.Equal(t, 6, p.OutputLevel)
+			if i > 0 {
+				require.GreaterOrEqual(t, p.BytesProcessed, progress[i-1].BytesProcessed)
+				require.LessOrEqual(t, p.BytesRemaining, progress[i-1].BytesRemaining)
+			}
+			if i < len(progress)-1 {
+				require.NotZero(t, p.BytesRemaining)
+			}
+		}
+		last := progress[len(progress)-1]
+		require.Zero(t, last.BytesRemaining)
+		require.Zero(t, last.ETA)
+	}
+	require.Empty(t, d.CompactionsInProgress())
+}
+
+func TestCompactionMakeProgress(t *testing.T) {
+	began := time.Unix(1000, 0)
+	c := &compaction{
+		kind:       compactionKindDefault,
+		inputs:     []compactionLevel{{level: 1}, {level: 2}},
+		inputBytes: 400,
+		beganAt:    began,
+	}
+	c.startLevel, c.outputLevel = &c.inputs[0], &c.inputs[1]
+
+	// A compaction that has not begun has no estimate.
+	require.Equal(t, CompactionProgress{
+		Reason:         "default",
+		StartLevel:     1,
+		OutputLevel:    2,
+		BytesRemaining: 400,
+	}, c.makeProgress(began.Add(time.Minute), 0, false /* done */))
+
+	c.jobID = 7
+	require.Equal(t, CompactionProgress{
+		JobID:          7,
+		Reason:         "default",
+		StartLevel:     1,
+		OutputLevel:    2,
+		BytesProcessed: 100,
+		BytesRemaining: 300,
+		Elapsed:        10 * time.Second,
+		ETA:            30 * time.Second,
+	}, c.makeProgress(began.Add(10*time.Second), 100, false /* done */))
+
+	// Processing more bytes than estimated leaves no estimate.
+	p := c.makeProgress(began.Add(20*time.Second), 500, false /* done */)
+	require.Zero(t, p.BytesRemaining)
+	require.Zero(t, p.ETA)
+
+	p = c.makeProgress(began.Add(30*time.Second), 300, true /* done */)
+	require.Equal(t, 30*time.Second, p.Elapsed)
+	require.Zero(t, p.BytesRemaining)
+	require.Zero(t, p.ETA)
+}
@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"math"
 	"math/rand"
+	"os"
 	"path/filepath"
 	"reflect"
 	"regexp"
@@ -18,16 +19,19 @@ import (
 	"strings"
 	"sync"
 	"sync/atomic"
+	"syscall"
 	"testing"
 	"time"
 
 	"github.com/cockroachdb/errors"
 	"github.com/cockroachdb/errors/oserror"
+	"github.com/cockroachdb/pebble/bloom"
 	"github.com/cockroachdb/pebble/internal/base"
 	"github.com/cockroachdb/pebble/internal/datadriven"
 	"github.com/cockroachdb/pebble/internal/errorfs"
 	"github.com/cockroachdb/pebble/internal/keyspan"
 	"github.com/cockroachdb/pebble/internal/manifest"
+	"github.com/cockroachdb/pebble/internal/testkeys"
 	"github.com/cockroachdb/pebble/sstable"
 	"github.com/cockroachdb/pebble/vfs"
 	"github.com/stretchr/testify/require"
@@ -64,6 +68,10 @@ func (p *compactionPickerForTesting) getEstimatedMaxWAmp() float64 {
 	return 0
 }
 
+func (p *compactionPickerForTesting) getLevelMultiplier() float64 {
+	return 0
+}
+
 func (p *compactionPickerForTesting) estimatedCompactionDebt(l0ExtraSize uint64) uint64 {
 	return 0
 }
@@ -2755,6 +2763,43 @@ func TestCompactionErrorOnUserKeyOverlap(t *testing.T) {
 // TestCompactionErrorCleanup tests an error encountered during a compaction
 // after some output tables have been created. It ensures that the pending
 // output tables are removed from the filesystem.
+func TestPauseCompactions(t *testing.T) {
+	d, err := Open("", &Options{
+		FS:                    vfs.NewMem(),
+		L0CompactionThreshold: 2,
+	})
+	require.NoError(t, err)
+	defer func() { require.NoError(t, d.Close()) }()
+
+	// Pauses nest, and resuming twice has no further effect.
+	resume1 := d.PauseCompactions()
+	resume2 := d.PauseCompactions()
+	resume1()
+	resume1()
+	for i := 0; i < 4; i++ {
+		require.NoError(t, d.Set([]byte(fmt.Sprint(i)), nil, nil))
+		require.NoError(t, d.Flush())
+	}
+	m := d.Metrics()
+	require.EqualValues(t, 4, m.Levels[0].NumFiles)
+	require.Zero(t, m.Compact.Count)
+
+	// A manual compaction waits for compactions to resume.
+	compacted := make(chan error, 1)
+	go func() { compacted <- d.Compact([]byte("0"), []byte("9"), false /* parallelize */) }()
+	select {
+	case err := <-compacted:
+		t.Fatalf("Compact returned while compactions were paused: %v", err)
+	case <-time.After(10 * time.Millisecond):
+	}
+	resume2()
+	require.NoError(t, <-compacted)
+
+	m = d.Metrics()
+	require.Zero(t, m.Levels[0].NumFiles)
+	require.NotZero(t, m.Compact.Count)
+}
+
 func TestCompactionErrorCleanup(t *testing.T) {
 	// protected by d.mu
 	var (
@@ -3272,6 +3317,346 @@ func TestFlushError(t *testing.T) {
 	require.NoError(t, d.Close())
 }
 
+func TestBackgroundErrorResume(t *testing.T) {
+	// Fail to create sstables with ENOSPC while the disk is full.
+	var diskFull int32 = 1
+	fs := errorfs.Wrap(vfs.NewMem(), errorfs.InjectorFunc(func(op errorfs.Op, path string) error {
+		if op == errorfs.OpCreate && filepath.Ext(path) == ".sst" && atomic.LoadInt32(&diskFull) == 1 {
+			return &os.PathError{Op: "create", Path: path, Err: syscall.ENOSPC}
+		}
+		return nil
+	}))
+	var bgErrs int32
+	d, err := Open("", &Options{
+		FS: fs,
+		EventListener: EventListener{
+			BackgroundError: func(err error) { atomic.AddInt32(&bgErrs, 1) },
+		},
+	})
+	require.NoError(t, err)
+	defer func() { require.NoError(t, d.Close()) }()
+	require.NoError(t, d.BackgroundError())
+	require.NoError(t, d.ResumeFromError())
+
+	// The failed flush pauses background work rather than being retried.
+	require.NoError(t, d.Set([]byte("a"), []byte("1"), nil))
+	err = d.Flush()
+	require.True(t, errors.Is(err, syscall.ENOSPC))
+	require.Equal(t, err, d.BackgroundError())
+	require.Equal(t, int32(1), atomic.LoadInt32(&bgErrs))
+	_, err = d.AsyncFlush()
+	require.Equal(t, d.BackgroundError(), err)
+	require.Equal(t, d.BackgroundError(), d.Compact([]byte("a"), []byte("b"), false))
+
+	// Reads and writes continue to be served from the memtables.
+	require.NoError(t, d.Set([]byte("b"), []byte("2"), nil))
+	v, closer, err := d.Get([]byte("a"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("1"), v)
+	require.NoError(t, closer.Close())
+
+	// Resuming while the disk is still full pauses background work again.
+	require.True(t, errors.Is(d.ResumeFromError(), syscall.ENOSPC))
+	require.Equal(t, int32(2), atomic.LoadInt32(&bgErrs))
+
+	// Once space is freed, resuming flushes the pending memtables.
+	atomic.StoreInt32(&diskFull, 0)
+	require.NoError(t, d.ResumeFromError())
+	require.NoError(t, d.BackgroundError())
+	require.NoError(t, d.Flush())
+	require.NoError(t, d.Compact([]byte("a"), []byte("c"), false))
+	require.Zero(t, d.Metrics().Levels[0].NumFiles)
+	require.Equal(t, int64(1), d.Metrics().Levels[6].NumFiles)
+}
+
+func TestReadOnlyDueToError(t *testing.T) {
+	// The filesystem is remounted read-only, failing the creation of sstables
+	// and, if walReadOnly is set, the sync of WALs.
+	var readOnly, walReadOnly int32
+	fs := errorfs.Wrap(vfs.NewMem(), errorfs.InjectorFunc(func(op errorfs.Op, path string) error {
+		if (op == errorfs.OpCreate && filepath.Ext(path) == ".sst" && atomic.LoadInt32(&readOnly) == 1) ||
+			(op == errorfs.OpFileSync && filepath.Ext(path) == ".log" && atomic.LoadInt32(&walReadOnly) == 1) {
+			return &os.PathError{Op: "write", Path: path, Err: syscall.EROFS}
+		}
+		return nil
+	}))
+	open := func() *DB {
+		d, err := Open("", &Options{
+			FS: fs,
+			// Archiving obsolete files disables WAL recycling, which would
+			// reuse WALs without errorfs wrapping them.
+			Cleaner: ArchiveCleaner{},
+			EventListener: EventListener{
+				BackgroundError: func(err error) {},
+			},
+		})
+		require.NoError(t, err)
+		return d
+	}
+	d := open()
+	require.False(t, d.IsReadOnlyDueToError())
+
+	// A failed flush makes the DB read-only.
+	require.NoError(t, d.Set([]byte("a"), []byte("1"), NoSync))
+	atomic.StoreInt32(&readOnly, 1)
+	err := d.Flush()
+	require.True(t, errors.Is(err, syscall.EROFS))
+	require.True(t, d.IsReadOnlyDueToError())
+	require.Equal(t, err, d.BackgroundError())
+
+	// Writes are rejected, and reads are served.
+	err = d.Set([]byte("b"), []byte("2"), NoSync)
+	require.True(t, errors.Is(err, ErrReadOnly))
+	require.True(t, errors.Is(err, syscall.EROFS))
+	v, closer, err := d.Get([]byte("a"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("1"), v)
+	require.NoError(t, closer.Close())
+
+	// Resuming while the filesystem is still read-only fails again.
+	require.True(t, errors.Is(d.ResumeFromError(), syscall.EROFS))
+	require.True(t, d.IsReadOnlyDueToError())
+
+	// Once the filesystem is writable, resuming flushes the memtable and
+	// accepts writes.
+	atomic.StoreInt32(&readOnly, 0)
+	require.NoError(t, d.ResumeFromError())
+	require.False(t, d.IsReadOnlyDueToError())
+	require.NoError(t, d.Set([]byte("b"), []byte("2"), NoSync))
+	require.NoError(t, d.Flush())
+
+	// A failed WAL sync makes the DB read-only. The write is visible, but it
+	// isn't durable until the DB resumes, rotating the WAL and flushing the
+	// memtable holding the write.
+	atomic.StoreInt32(&walReadOnly, 1)
+	err = d.Set([]byte("c"), []byte("3"), Sync)
+	require.True(t, errors.Is(err, syscall.EROFS))
+	require.True(t, d.IsReadOnlyDueToError())
+	require.True(t, errors.Is(d.Set([]byte("d"), []byte("4"), NoSync), ErrReadOnly))
+	v, closer, err = d.Get([]byte("c"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("3"), v)
+	require.NoError(t, closer.Close())
+	logNum := func() FileNum {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		return d.mu.log.queue[len(d.mu.log.queue)-1].fileNum
+	}
+	prevLogNum := logNum()
+	// The WAL is rotated even if the flush of the rotated memtable fails.
+	atomic.StoreInt32(&readOnly, 1)
+	atomic.StoreInt32(&walReadOnly, 0)
+	require.True(t, errors.Is(d.ResumeFromError(), syscall.EROFS))
+	require.True(t, d.IsReadOnlyDueToError())
+	require.Greater(t, uint64(logNum()), uint64(prevLogNum))
+	atomic.StoreInt32(&readOnly, 0)
+	require.NoError(t, d.ResumeFromError())
+	require.False(t, d.IsReadOnlyDueToError())
+	require.NoError(t, d.Set([]byte("d"), []byte("4"), Sync))
+	require.NoError(t, d.Close())
+	d = open()
+	for _, k := range []string{"a", "b", "c", "d"} {
+		_, closer, err := d.Get([]byte(k))
+		require.NoError(t, err, k)
+		require.NoError(t, closer.Close())
+	}
+	require.NoError(t, d.Close())
+
+	// A failure to close the WAL when rotating it for a flush also makes the
+	// DB read-only.
+	d = open()
+	require.NoError(t, d.Set([]byte("e"), []byte("5"), NoSync))
+	atomic.StoreInt32(&walReadOnly, 1)
+	require.True(t, errors.Is(d.Flush(), syscall.EROFS))
+	require.True(t, d.IsReadOnlyDueToError())
+	require.True(t, errors.Is(d.Set([]byte("f"), []byte("6"), NoSync), ErrReadOnly))
+	v, closer, err = d.Get([]byte("e"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("5"), v)
+	require.NoError(t, closer.Close())
+	atomic.StoreInt32(&walReadOnly, 0)
+	require.NoError(t, d.ResumeFromError())
+	require.False(t, d.IsReadOnlyDueToError())
+	require.NoError(t, d.Set([]byte("f"), []byte("6"), Sync))
+	require.NoError(t, d.Close())
+	d = open()
+	for _, k := range []string{"e", "f"} {
+		_, closer, err := d.Get([]byte(k))
+		require.NoError(t, err, k)
+		require.NoError(t, closer.Close())
+	}
+	require.NoError(t, d.Close())
+}
+
+// onceCorruptingFS corrupts the next sstable created on it once armed.
+type onceCorruptingFS struct {
+	vfs.FS
+	armed int32
+}
+
+func (fs *onceCorruptingFS) Create(name string) (vfs.File, error) {
+	f, err := fs.FS.Create(name)
+	if err != nil || filepath.Ext(name) != ".sst" || !atomic.CompareAndSwapInt32(&fs.armed, 1, 0) {
+		return f, err
+	}
+	return &corruptingFile{File: f}, nil
+}
+
+func TestValidateOutputs(t *testing.T) {
+	fs := &onceCorruptingFS{FS: vfs.NewMem()}
+	var mu sync.Mutex
+	var bgErrs []error
+	opts := &Options{
+		FS: fs,
+		EventListener: EventListener{
+			BackgroundError: func(err error) {
+				mu.Lock()
+				defer mu.Unlock()
+				bgErrs = append(bgErrs, err)
+			},
+		},
+	}
+	opts.Experimental.ValidateOutputs = true
+	d, err := Open("", opts)
+	require.NoError(t, err)
+	defer func() { require.NoError(t, d.Close()) }()
+
+	// A corrupted flush output fails validation, and the flush is retried.
+	require.NoError(t, d.Set([]byte("a"), []byte("1"), nil))
+	require.NoError(t, d.Set([]byte("b"), []byte("2"), nil))
+	atomic.StoreInt32(&fs.armed, 1)
+	require.NoError(t, d.Flush())
+	require.EqualValues(t, 1, d.Metrics().Compact.OutputValidationFailures)
+	prev := d.Metrics()
+
+	// A corrupted output fails a manual compaction, which succeeds once
+	// retried.
+	require.NoError(t, d.Set([]byte("c"), []byte("3"), nil))
+	require.NoError(t, d.Flush())
+	atomic.StoreInt32(&fs.armed, 1)
+	err = d.Compact([]byte("a"), []byte("d"), false)
+	require.True(t, errors.Is(err, base.ErrCorruption), "%+v", err)
+	require.NoError(t, d.Compact([]byte("a"), []byte("d"), false))
+	require.EqualValues(t, 2, d.Metrics().Compact.OutputValidationFailures)
+	require.EqualValues(t, 1, d.MetricsSince(prev).Compact.OutputValidationFailures)
+
+	mu.Lock()
+	require.Len(t, bgErrs, 2)
+	for _, err := range bgErrs {
+		require.True(t, errors.Is(err, base.ErrCorruption), "%+v", err)
+	}
+	mu.Unlock()
+
+	// The LSM only contains the validated outputs.
+	for _, k := range []string{"a", "b", "c"} {
+		_, closer, err := d.Get([]byte(k))
+		require.NoError(t, err)
+		require.NoError(t, closer.Close())
+	}
+	require.NoError(t, d.CheckLevels(nil))
+}
+
+func TestValidateOutputsSSTablePathFunc(t *testing.T) {
+	opts := &Options{
+		FS: vfs.NewMem(),
+		SSTablePathFunc: func(fileNum FileNum) string {
+			return fmt.Sprintf("table-%s", fileNum)
+		},
+	}
+	opts.Experimental.ValidateOutputs = true
+	d, err := Open("", opts)
+	require.NoError(t, err)
+	defer func() { require.NoError(t, d.Close()) }()
+
+	// The outputs of flushes and compactions are read back under the names
+	// given by SSTablePathFunc.
+	require.NoError(t, d.Set([]byte("a"), []byte("1"), nil))
+	require.NoError(t, d.Flush())
+	require.NoError(t, d.Set([]byte("b"), []byte("2"), nil))
+	require.NoError(t, d.Flush())
+	require.NoError(t, d.Compact([]byte("a"), []byte("c"), false /* parallelize */))
+	require.Zero(t, d.Metrics().Compact.OutputValidationFailures)
+}
+
+func TestCompactionLevelFilterPolicy(t *testing.T) {
+	// Filters are configured for every level except L6.
+	opts := &Options{
+		FS:                          vfs.NewMem(),
+		DisableAutomaticCompactions: true,
+		Levels:                      make([]LevelOptions, numLevels),
+	}
+	for i := 0; i < numLevels-1; i++ {
+		opts.Levels[i].FilterPolicy = bloom.FilterPolicy(10)
+	}
+	d, err := Open("", opts)
+	require.NoError(t, err)
+	defer func() { require.NoError(t, d.Close()) }()
+
+	filterPolicies := func() map[int][]string {
+		tables, err := d.SSTables(WithProperties())
+		require.NoError(t, err)
+		m := make(map[int][]string)
+		for level := range tables {
+			for _, table := range tables[level] {
+				m[level] = append(m[level], table.Properties.FilterPolicyName)
+			}
+		}
+		return m
+	}
+
+	// A flushed table has L0's filter, and keeps it when moved to L6.
+	require.NoError(t, d.Set([]byte("a"), []byte("1"), nil))
+	require.NoError(t, d.Flush())
+	require.Equal(t, map[int][]string{0: {"rocksdb.BuiltinBloomFilter"}}, filterPolicies())
+	require.NoError(t, d.Compact([]byte("a"), []byte("b"), false /* parallelize */))
+	require.Equal(t, map[int][]string{6: {"rocksdb.BuiltinBloomFilter"}}, filterPolicies())
+
+	// A table rewritten by a compaction into L6 has no filter.
+	require.NoError(t, d.Set([]byte("a"), []byte("2"), nil))
+	require.NoError(t, d.Flush())
+	require.NoError(t, d.Compact([]byte("a"), []byte("b"), false /* parallelize */))
+	require.Equal(t, map[int][]string{6: {""}}, filterPolicies())
+}
+
+func TestCompactionMaxKeysPerFile(t *testing.T) {
+	d, err := Open("", &Options{
+		FS:                          vfs.NewMem(),
+		DisableAutomaticCompactions: true,
+		Levels:                      []LevelOptions{{MaxKeysPerFile: 10}},
+	})
+	require.NoError(t, err)
+	defer func() { require.NoError(t, d.Close()) }()
+
+	numEntries := func(level int) []uint64 {
+		tables, err := d.SSTables(WithProperties())
+		require.NoError(t, err)
+		var n []uint64
+		for _, table := range tables[level] {
+			n = append(n, table.Properties.NumEntries)
+		}
+		return n
+	}
+
+	// The flush splits its output every 10 keys, well below the target file
+	// size.
+	for i := 0; i < 25; i++ {
+		require.NoError(t, d.Set([]byte(fmt.Sprintf("%02d", i)), nil, nil))
+	}
+	require.NoError(t, d.Flush())
+	require.Equal(t, []uint64{10, 10, 5}, numEntries(0))
+
+	// Outputs are only split between user keys: the versions of a key
+	// preserved by a snapshot stay in the same output, so the first output
+	// includes both versions of key 07.
+	snap := d.NewSnapshot()
+	defer func() { require.NoError(t, snap.Close()) }()
+	for i := 5; i < 15; i++ {
+		require.NoError(t, d.Set([]byte(fmt.Sprintf("%02d", i)), []byte("v"), nil))
+	}
+	require.NoError(t, d.Compact([]byte("00"), []byte("99"), false /* parallelize */))
+	require.Equal(t, []uint64{11, 10, 10, 4}, numEntries(6))
+}
+
 func TestAdjustGrandparentOverlapBytesForFlush(t *testing.T) {
 	// 500MB in Lbase
 	var lbaseFiles []*manifest.FileMetadata
@@ -3776,3 +4161,332 @@ func TestCompaction_LogAndApplyFails(t *testing.T) {
 		})
 	}
 }
+
+func TestCompactionGCFloorSeqNum(t *testing.T) {
+	opts := &Options{FS: vfs.NewMem()}
+	opts.DisableAutomaticCompactions = true
+	opts.Experimental.GCFloorSeqNum = 1
+	d, err := Open("", opts)
+	require.NoError(t, err)
+	defer func() { require.NoError(t, d.Close()) }()
+	require.Equal(t, uint64(1), d.GCFloorSeqNum())
+
+	require.NoError(t, d.Set([]byte("a"), []byte("a"), nil))
+	require.NoError(t, d.Delete([]byte("a"), nil))
+	require.NoError(t, d.Set([]byte("b"), []byte("b"), nil))
+	require.NoError(t, d.DeleteRange([]byte("c"), []byte("d"), nil))
+
+	lsm := func() string {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		return d.mu.versions.currentVersion().String()
+	}
+
+	// The deletions are at or above the GC floor and are retained in the
+	// bottommost level. The value shadowed by the point deletion is not.
+	require.NoError(t, d.Compact([]byte("a"), []byte("d"), false /* parallelize */))
+	require.Equal(t, "6:\n  000005:[a#2,DEL-d#72057594037927935,RANGEDEL]\n", lsm())
+	v, closer, err := d.Get([]byte("b"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("b"), v)
+	require.NoError(t, closer.Close())
+
+	// Advancing the floor allows the deletions to be elided. A new write
+	// ensures the compaction rewrites the existing table.
+	d.SetGCFloorSeqNum(InternalKeySeqNumMax)
+	require.NoError(t, d.Set([]byte("b"), []byte("b"), nil))
+	require.NoError(t, d.Compact([]byte("a"), []byte("d"), false /* parallelize */))
+	require.Equal(t, "6:\n  000008:[b#0,SET-b#0,SET]\n", lsm())
+}
+
+func TestCompactionMaxVersionsPerKey(t *testing.T) {
+	opts := &Options{FS: vfs.NewMem(), Comparer: testkeys.Comparer}
+	opts.DisableAutomaticCompactions = true
+	opts.Experimental.MaxVersionsPerKey = 2
+	d, err := Open("", opts)
+	require.NoError(t, err)
+	defer func() { require.NoError(t, d.Close()) }()
+
+	keys := func() string {
+		iter := d.NewIter(nil)
+		defer iter.Close()
+		var keys []string
+		for valid := iter.First(); valid; valid = iter.Next() {
+			keys = append(keys, string(iter.Key()))
+		}
+		return strings.Join(keys, " ")
+	}
+	set := func(keys ...string) {
+		for _, k := range keys {
+			require.NoError(t, d.Set([]byte(k), nil, nil))
+		}
+	}
+	compact := func() {
+		require.NoError(t, d.Compact([]byte("a"), []byte("z"), false /* parallelize */))
+	}
+
+	// Only the two most recent versions of each key are retained. A deleted
+	// version doesn't count towards the limit.
+	set("a@1", "a@2", "c@1")
+	require.NoError(t, d.Flush())
+	set("a@3", "a@4", "b@1", "c@2", "c@3")
+	require.NoError(t, d.Delete([]byte("c@3"), nil))
+	compact()
+	require.Equal(t, "a@4 a@3 b@1 c@2 c@1", keys())
+	require.EqualValues(t, 2, d.Metrics().Compact.VersionsElided)
+
+	// Versions written after the earliest snapshot don't count towards the
+	// limit, so the versions observed by the snapshot are retained.
+	snap := d.NewSnapshot()
+	set("a@5", "a@6", "c@3")
+	compact()
+	require.Equal(t, "a@6 a@5 a@4 a@3 b@1 c@3 c@2 c@1", keys())
+	require.EqualValues(t, 2, d.Metrics().Compact.VersionsElided)
+
+	// Once the snapshot is closed, the older versions may be elided.
+	require.NoError(t, snap.Close())
+	set("b@2")
+	compact()
+	require.Equal(t, "a@6 a@5 b@2 b@1 c@3 c@2", keys())
+	require.EqualValues(t, 5, d.Metrics().Compact.VersionsElided)
+}
+
+func TestCompactionCompressionForKeyRange(t *testing.T) {
+	opts := &Options{FS: vfs.NewMem()}
+	opts.DisableAutomaticCompactions = true
+	var calls []string
+	opts.Experimental.CompressionForKeyRange = func(lower, upper []byte) Compression {
+		calls = append(calls, fmt.Sprintf("%s-%s", lower, upper))
+		if bytes.HasPrefix(lower, []byte("img")) {
+			return NoCompression
+		}
+		return DefaultCompression
+	}
+	d, err := Open("", opts)
+	require.NoError(t, err)
+	defer func() { require.NoError(t, d.Close()) }()
+
+	compression := func() []string {
+		t.Helper()
+		tables, err := d.SSTables(WithProperties())
+		require.NoError(t, err)
+		var names []string
+		for _, level := range tables {
+			for _, info := range level {
+				names = append(names, info.Properties.CompressionName)
+			}
+		}
+		return names
+	}
+
+	// Flushes consult CompressionForKeyRange with the bounds of the flush.
+	require.NoError(t, d.Set([]byte("img1"), []byte("a"), nil))
+	require.NoError(t, d.Set([]byte("img2"), []byte("a"), nil))
+	require.NoError(t, d.Flush())
+	require.NoError(t, d.Set([]byte("txt1"), []byte("a"), nil))
+	require.NoError(t, d.Flush())
+	require.Equal(t, []string{"img1-img2", "txt1-txt1"}, calls)
+	require.Equal(t, []string{"NoCompression", "Snappy"}, compression())
+
+	// So do compactions, falling back to the level's compression when
+	// DefaultCompression is returned.
+	require.NoError(t, d.Set([]byte("img1"), []byte("b"), nil))
+	require.NoError(t, d.Flush())
+	require.NoError(t, d.Set([]byte("txt1"), []byte("b"), nil))
+	require.NoError(t, d.Flush())
+	calls = nil
+	require.NoError(t, d.Compact([]byte("img"), []byte("imh"), false /* parallelize */))
+	require.NoError(t, d.Compact([]byte("txt"), []byte("txu"), false /* parallelize */))
+	require.Equal(t, []string{"img1-img2", "txt1-txt1"}, calls)
+	require.Equal(t, []string{"NoCompression", "Snappy"}, compression())
+}
+
+func TestCompactionReclaimedBytes(t *testing.T) {
+	d, err := Open("", &Options{FS: vfs.NewMem()})
+	require.NoError(t, err)
+	defer func() { require.NoError(t, d.Close()) }()
+
+	value := bytes.Repeat([]byte("v"), 100)
+	for i := 0; i < 1000; i++ {
+		require.NoError(t, d.Set([]byte(fmt.Sprintf("%04d", i)), value, nil))
+	}
+	require.NoError(t, d.Flush())
+	require.NoError(t, d.Compact([]byte("0000"), []byte("1000"), false))
+	before := d.Metrics()
+
+	// Compacting away the deleted data reclaims its space.
+	require.NoError(t, d.DeleteRange([]byte("0000"), []byte("0900"), nil))
+	require.NoError(t, d.Compact([]byte("0000"), []byte("1000"), false))
+	delta := d.MetricsSince(before)
+	require.Greater(t, delta.Compact.ReclaimedBytes, uint64(before.Levels[numLevels-1].Size)*8/10)
+}
+
+// buildRangeKeyFragmentDB opens a DB whose compactions use the provided
+// RangeKeyFragmentPolicy, and compacts into the bottommost level a dataset of
+// point keys interspersed with range keys that each span many output files'
+// worth of point keys.
+func buildRangeKeyFragmentDB(tb testing.TB, policy RangeKeyFragmentPolicy) *DB {
+	opts := &Options{
+		FS:                 vfs.NewMem(),
+		Comparer:           testkeys.Comparer,
+		FormatMajorVersion: FormatNewest,
+	}
+	opts.DisableAutomaticCompactions = true
+	opts.Levels = make([]LevelOptions, numLevels)
+	for i := range opts.Levels {
+		opts.Levels[i].TargetFileSize = 4 << 10
+		opts.Levels[i].RangeKeyFragmentPolicy = policy
+	}
+	d, err := Open("", opts)
+	require.NoError(tb, err)
+
+	rng := rand.New(rand.NewSource(1))
+	value := make([]byte, 200)
+	for i := 0; i < 2000; i++ {
+		rng.Read(value)
+		require.NoError(tb, d.Set([]byte(fmt.Sprintf("%04d", i)), value, nil))
+	}
+	for i := 0; i < 2000; i += 100 {
+		start := []byte(fmt.Sprintf("%04d", i+10))
+		end := []byte(fmt.Sprintf("%04d", i+25))
+		require.NoError(tb, d.RangeKeySet(start, end, []byte("@1"), []byte("v"), nil))
+	}
+	require.NoError(tb, d.Flush())
+	require.NoError(tb, d.Compact([]byte("0000"), []byte("2000"), false))
+	return d
+}
+
+func TestCompactionRangeKeyFragmentPolicy(t *testing.T) {
+	rangeKeySets := func(d *DB) (n uint64) {
+		tables, err := d.SSTables(WithProperties())
+		require.NoError(t, err)
+		for _, level := range tables {
+			for _, info := range level {
+				n += info.Properties.NumRangeKeySets
+			}
+		}
+		return n
+	}
+
+	d := buildRangeKeyFragmentDB(t, RangeKeyFragmentAtBoundaries)
+	atBoundaries := rangeKeySets(d)
+	require.NoError(t, d.Close())
+
+	d = buildRangeKeyFragmentDB(t, RangeKeyFragmentAvoidSplits)
+	avoidSplits := rangeKeySets(d)
+	// Deferred splits are bounded by twice the target file size, which the
+	// output may overshoot by the block being written when it's reached.
+	tables, err := d.SSTables()
+	require.NoError(t, err)
+	for _, level := range tables {
+		for _, info := range level {
+			require.LessOrEqual(t, info.Size, uint64(3*(4<<10)))
+		}
+	}
+	require.NoError(t, d.Close())
+
+	// Splitting outputs at target sizes fragments each range key across
+	// several files, unless the policy defers those splits.
+	require.Greater(t, atBoundaries, uint64(20))
+	require.Equal(t, uint64(20), avoidSplits)
+}
+
+func BenchmarkRangeKeyIterationFragmentPolicy(b *testing.B) {
+	for _, policy := range []RangeKeyFragmentPolicy{RangeKeyFragmentAtBoundaries, RangeKeyFragmentAvoidSplits} {
+		b.Run(policy.String(), func(b *testing.B) {
+			d := buildRangeKeyFragmentDB(b, policy)
+			defer func() { require.NoError(b, d.Close()) }()
+
+			iterOpts := IterOptions{KeyTypes: IterKeyTypeRangesOnly}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				iter := d.NewIter(&iterOpts)
+				for valid := iter.First(); valid; valid = iter.Next() {
+				}
+				require.NoError(b, iter.Close())
+			}
+		})
+	}
+}
+
+func TestCompactionLevelBlockSize(t *testing.T) {
+	opts := &Options{FS: vfs.NewMem()}
+	opts.Levels = make([]LevelOptions, numLevels)
+	for i := range opts.Levels {
+		opts.Levels[i].BlockSize = 512
+	}
+	opts.Levels[numLevels-1].BlockSize = 32 << 10
+	d, err := Open("", opts)
+	require.NoError(t, err)
+	defer func() { require.NoError(t, d.Close()) }()
+
+	// Write two overlapping L0 sstables, so that compacting them rewrites
+	// them rather than moving them.
+	value := bytes.Repeat([]byte("v"), 100)
+	for j := 0; j < 2; j++ {
+		for i := 0; i < 1000; i++ {
+			require.NoError(t, d.Set([]byte(fmt.Sprintf("k%04d", i)), value, nil))
+		}
+		require.NoError(t, d.Flush())
+	}
+	numDataBlocks := func(level int) (n uint64) {
+		tables, err := d.SSTables(WithProperties())
+		require.NoError(t, err)
+		require.NotEmpty(t, tables[level])
+		for _, table := range tables[level] {
+			n += table.Properties.NumDataBlocks
+		}
+		return n
+	}
+	// Each L0 sstable holds ~110KB of keys and values in 512B blocks.
+	require.Greater(t, numDataBlocks(0), uint64(2*100))
+
+	require.NoError(t, d.Compact([]byte("k"), []byte("l"), false))
+	require.Less(t, numDataBlocks(numLevels-1), uint64(10))
+}
+
+func TestCompactionOverlapOptions(t *testing.T) {
+	opts := (&Options{Levels: []LevelOptions{{TargetFileSize: 100}}}).EnsureDefaults()
+	// L2 is the adjusted output level of compactions from L1 into L2 when L1
+	// is the base level, and has a target file size of 400.
+	require.EqualValues(t, 25*400, expandedCompactionByteSizeLimit(opts, 2, math.MaxUint64))
+	require.EqualValues(t, 10*400, maxGrandparentOverlapBytes(opts, 2))
+
+	newFileMeta := func(fileNum FileNum, size uint64, smallest, largest string) *fileMetadata {
+		return (&fileMetadata{FileNum: fileNum, Size: size}).ExtendPointKeyBounds(opts.Comparer.Compare,
+			base.ParseInternalKey(smallest), base.ParseInternalKey(largest))
+	}
+	vers := newVersion(opts, [numLevels][]*fileMetadata{
+		1: {newFileMeta(1, 100, "a.SET.1", "c.SET.1"), newFileMeta(2, 100, "d.SET.1", "e.SET.1")},
+		2: {newFileMeta(3, 300, "b.SET.1", "f.SET.1")},
+		3: {newFileMeta(4, 50, "a.SET.1", "z.SET.1")},
+	})
+	// compact picks the first L1 file, which the picker may expand to include
+	// the second L1 file overlapping the same L2 file, bringing the
+	// compaction's inputs to 500 bytes.
+	compact := func() *compaction {
+		pc := newPickedCompaction(opts, vers, 1, 2, 1)
+		iter := vers.Levels[1].Iter()
+		iter.First()
+		pc.startLevel.files = iter.Take().Slice()
+		require.True(t, pc.setupInputs(opts, math.MaxUint64, pc.startLevel))
+		return newCompaction(pc, opts)
+	}
+
+	c := compact()
+	require.Equal(t, 2, c.startLevel.files.Len())
+	require.EqualValues(t, 10*400, c.maxOverlapBytes)
+	require.Contains(t, c.String(), "\noverlap: output-level=300 (ratio 1.50) grandparents=50 (max 4000 per output)\n")
+
+	// A ratio limiting the compaction's inputs to 400 bytes prevents the
+	// expansion, and the grandparent overlap limit is fixed.
+	opts.Experimental.MaxCompactionOverlapRatio = 1
+	opts.Experimental.MaxGrandparentOverlapBytes = 1000
+	c = compact()
+	require.Equal(t, 1, c.startLevel.files.Len())
+	require.EqualValues(t, 1000, c.maxOverlapBytes)
+	require.Contains(t, c.String(), "\noverlap: output-level=300 (ratio 3.00) grandparents=50 (max 1000 per output)\n")
+
+	opts.Experimental.MaxCompactionOverlapRatio = -1
+	require.Error(t, opts.Validate())
+}
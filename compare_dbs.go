@@ -0,0 +1,396 @@
+// Copyright 2026 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package pebble
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/cockroachdb/errors"
+	"golang.org/x/sync/errgroup"
+)
+
+// DifferenceKind describes how two DBs differ at a key or span.
+type DifferenceKind int8
+
+const (
+	// DifferenceOnlyInA indicates that a point key is present in A but not in
+	// B.
+	DifferenceOnlyInA DifferenceKind = iota
+	// DifferenceOnlyInB indicates that a point key is present in B but not in
+	// A.
+	DifferenceOnlyInB
+	// DifferenceValue indicates that a point key is present in both DBs with
+	// differing values.
+	DifferenceValue
+	// DifferenceRangeKeys indicates that the range keys in a span differ. A
+	// span is reported if it's not present in the other DB with the same
+	// bounds and the same range keys.
+	DifferenceRangeKeys
+)
+
+// String implements fmt.Stringer.
+func (k DifferenceKind) String() string {
+	switch k {
+	case DifferenceOnlyInA:
+		return "only-in-a"
+	case DifferenceOnlyInB:
+		return "only-in-b"
+	case DifferenceValue:
+		return "value"
+	case DifferenceRangeKeys:
+		return "range-keys"
+	default:
+		return fmt.Sprintf("unknown(%d)", k)
+	}
+}
+
+// Difference describes a difference between the logical state of two DBs,
+// as reported by CompareDBs.
+type Difference struct {
+	Kind DifferenceKind
+	// Key is the point key that differs. It is set for all kinds of
+	// differences except DifferenceRangeKeys.
+	Key []byte
+	// AValue and BValue are the values of the point key in A and B. The value
+	// is nil for the DB in which the key is absent.
+	AValue, BValue []byte
+	// Start and End are the bounds of a span whose range keys differ. They
+	// are set only for DifferenceRangeKeys.
+	Start, End []byte
+	// ARangeKeys and BRangeKeys are the range keys set over [Start, End) in A
+	// and B. They are nil for a DB that has no range keys with those bounds.
+	ARangeKeys, BRangeKeys []RangeKeyData
+}
+
+// String implements fmt.Stringer.
+func (d Difference) String() string {
+	if d.Kind == DifferenceRangeKeys {
+		return fmt.Sprintf("%s [%q,%q): a=%s b=%s",
+			d.Kind, d.Start, d.End, formatRangeKeyData(d.ARangeKeys), formatRangeKeyData(d.BRangeKeys))
+	}
+	return fmt.Sprintf("%s %q: a=%q b=%q", d.Kind, d.Key, d.AValue, d.BValue)
+}
+
+func formatRangeKeyData(keys []RangeKeyData) string {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, k := range keys {
+		if i > 0 {
+			buf.WriteByte(' ')
+		}
+		fmt.Fprintf(&buf, "%q=%q", k.Suffix, k.Value)
+	}
+	buf.WriteByte('}')
+	return buf.String()
+}
+
+// CompareOptions configures CompareDBs.
+type CompareOptions struct {
+	// LowerBound and UpperBound restrict the comparison to the keys in
+	// [LowerBound, UpperBound). Either may be nil, in which case the
+	// comparison is unbounded in that direction.
+	LowerBound []byte
+	UpperBound []byte
+	// Parallelism is the number of ranges of the keyspace scanned
+	// concurrently. The keyspace is partitioned at the boundaries of A's
+	// sstables. The default is 1.
+	Parallelism int
+	// MaxDifferences is the maximum number of differences reported by each
+	// of the scanned ranges. Zero means no limit.
+	MaxDifferences int
+}
+
+// CompareDBs compares the logical state of two DBs and returns the
+// differences between them in key order. The DBs are read at a consistent
+// snapshot of each, taken when CompareDBs is called.
+//
+// The comparison is of the state visible to iterators: point keys deleted by
+// point or range deletion tombstones are absent, and merge operands are
+// merged. Two DBs holding the same live keys are equal regardless of how
+// their tombstones or sstables are arranged. Range keys are compared after
+// defragmentation, so abutting spans with the same range keys are treated as
+// a single span. When the comparison is parallelized, range keys spanning
+// the boundary between two scanned ranges are compared separately in each,
+// and a difference in such a span may be reported once per range.
+//
+// Both DBs must use the same Comparer.
+func CompareDBs(a, b *DB, opts *CompareOptions) ([]Difference, error) {
+	if opts == nil {
+		opts = &CompareOptions{}
+	}
+	if a.opts.Comparer.Name != b.opts.Comparer.Name {
+		return nil, errors.Errorf("pebble: cannot compare DBs with comparers %q and %q",
+			errors.Safe(a.opts.Comparer.Name), errors.Safe(b.opts.Comparer.Name))
+	}
+	if opts.LowerBound != nil && opts.UpperBound != nil && a.cmp(opts.LowerBound, opts.UpperBound) >= 0 {
+		return nil, errors.Errorf("pebble: lower bound %s must be less than upper bound %s",
+			a.opts.Comparer.FormatKey(opts.LowerBound), a.opts.Comparer.FormatKey(opts.UpperBound))
+	}
+
+	sa, sb := a.NewSnapshot(), b.NewSnapshot()
+	defer sa.Close()
+	defer sb.Close()
+
+	bounds := a.compareSplitKeys(opts)
+	results := make([][]Difference, len(bounds)-1)
+	var g errgroup.Group
+	for i := range results {
+		i := i
+		c := dbComparer{
+			cmp:   a.cmp,
+			a:     sa,
+			b:     sb,
+			lower: bounds[i],
+			upper: bounds[i+1],
+			limit: opts.MaxDifferences,
+		}
+		g.Go(func() error {
+			var err error
+			results[i], err = c.run()
+			return err
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	var diffs []Difference
+	for _, r := range results {
+		diffs = append(diffs, r...)
+	}
+	return diffs, nil
+}
+
+// compareSplitKeys partitions the range compared by CompareDBs into at most
+// opts.Parallelism ranges, returning their bounds. The first and last bounds
+// are the bounds of the comparison. The interior bounds are chosen from the
+// smallest keys of the DB's sstables, evenly spaced.
+func (d *DB) compareSplitKeys(opts *CompareOptions) [][]byte {
+	bounds := [][]byte{opts.LowerBound}
+	if opts.Parallelism > 1 {
+		var keys [][]byte
+		d.mu.Lock()
+		v := d.mu.versions.currentVersion()
+		for level := range v.Levels {
+			iter := v.Levels[level].Iter()
+			for f := iter.First(); f != nil; f = iter.Next() {
+				k := f.Smallest.UserKey
+				if (opts.LowerBound == nil || d.cmp(k, opts.LowerBound) > 0) &&
+					(opts.UpperBound == nil || d.cmp(k, opts.UpperBound) < 0) {
+					keys = append(keys, append([]byte(nil), k...))
+				}
+			}
+		}
+		d.mu.Unlock()
+
+		sort.Slice(keys, func(i, j int) bool {
+			return d.cmp(keys[i], keys[j]) < 0
+		})
+		n := 0
+		for i := range keys {
+			if n == 0 || d.cmp(keys[n-1], keys[i]) != 0 {
+				keys[n] = keys[i]
+				n++
+			}
+		}
+		keys = keys[:n]
+		parts := opts.Parallelism
+		if parts > len(keys)+1 {
+			parts = len(keys) + 1
+		}
+		for i := 1; i < parts; i++ {
+			bounds = append(bounds, keys[i*len(keys)/parts])
+		}
+	}
+	return append(bounds, opts.UpperBound)
+}
+
+// dbComparer compares the keys of two snapshots within [lower, upper).
+type dbComparer struct {
+	cmp          Compare
+	a, b         *Snapshot
+	lower, upper []byte
+	limit        int
+	diffs        []Difference
+}
+
+// full returns true if the differences appended since the diffs had length
+// start reached the limit.
+func (c *dbComparer) full(start int) bool {
+	return c.limit > 0 && len(c.diffs)-start >= c.limit
+}
+
+func (c *dbComparer) run() ([]Difference, error) {
+	if err := c.comparePoints(); err != nil {
+		return nil, err
+	}
+	if err := c.compareRangeKeys(); err != nil {
+		return nil, err
+	}
+	// The point and range key differences are each in key order, and each
+	// limited to c.limit. Merge them, ordering a span by its start key.
+	sort.SliceStable(c.diffs, func(i, j int) bool {
+		return c.cmp(c.diffs[i].sortKey(), c.diffs[j].sortKey()) < 0
+	})
+	if c.full(0) {
+		c.diffs = c.diffs[:c.limit]
+	}
+	return c.diffs, nil
+}
+
+func (d *Difference) sortKey() []byte {
+	if d.Kind == DifferenceRangeKeys {
+		return d.Start
+	}
+	return d.Key
+}
+
+func (c *dbComparer) newIters(keyTypes IterKeyType) (ia, ib *Iterator) {
+	o := &IterOptions{KeyTypes: keyTypes, LowerBound: c.lower, UpperBound: c.upper}
+	return c.a.NewIter(o), c.b.NewIter(o)
+}
+
+func closeIters(ia, ib *Iterator) error {
+	return firstError(ia.Close(), ib.Close())
+}
+
+func (c *dbComparer) comparePoints() error {
+	ia, ib := c.newIters(IterKeyTypePointsOnly)
+	start := len(c.diffs)
+	va, vb := ia.First(), ib.First()
+	for (va || vb) && !c.full(start) {
+		var v int
+		switch {
+		case !vb:
+			v = -1
+		case !va:
+			v = +1
+		default:
+			v = c.cmp(ia.Key(), ib.Key())
+		}
+		switch {
+		case v < 0:
+			c.diffs = append(c.diffs, Difference{
+				Kind:   DifferenceOnlyInA,
+				Key:    append([]byte(nil), ia.Key()...),
+				AValue: append([]byte(nil), ia.Value()...),
+			})
+			va = ia.Next()
+		case v > 0:
+			c.diffs = append(c.diffs, Difference{
+				Kind:   DifferenceOnlyInB,
+				Key:    append([]byte(nil), ib.Key()...),
+				BValue: append([]byte(nil), ib.Value()...),
+			})
+			vb = ib.Next()
+		default:
+			if !bytes.Equal(ia.Value(), ib.Value()) {
+				c.diffs = append(c.diffs, Difference{
+					Kind:   DifferenceValue,
+					Key:    append([]byte(nil), ia.Key()...),
+					AValue: append([]byte(nil), ia.Value()...),
+					BValue: append([]byte(nil), ib.Value()...),
+				})
+			}
+			va, vb = ia.Next(), ib.Next()
+		}
+	}
+	return firstError(firstError(ia.Error(), ib.Error()), closeIters(ia, ib))
+}
+
+func (c *dbComparer) compareRangeKeys() error {
+	ia, ib := c.newIters(IterKeyTypeRangesOnly)
+	start := len(c.diffs)
+	va, vb := ia.First(), ib.First()
+	for (va || vb) && !c.full(start) {
+		var startA, endA, startB, endB []byte
+		if va {
+			startA, endA = ia.RangeBounds()
+		}
+		if vb {
+			startB, endB = ib.RangeBounds()
+		}
+		var v int
+		switch {
+		case !vb:
+			v = -1
+		case !va:
+			v = +1
+		default:
+			v = c.cmp(startA, startB)
+		}
+		switch {
+		case v < 0:
+			c.diffs = append(c.diffs, Difference{
+				Kind:       DifferenceRangeKeys,
+				Start:      append([]byte(nil), startA...),
+				End:        append([]byte(nil), endA...),
+				ARangeKeys: cloneRangeKeyData(ia.RangeKeys()),
+			})
+			va = ia.Next()
+		case v > 0:
+			c.diffs = append(c.diffs, Difference{
+				Kind:       DifferenceRangeKeys,
+				Start:      append([]byte(nil), startB...),
+				End:        append([]byte(nil), endB...),
+				BRangeKeys: cloneRangeKeyData(ib.RangeKeys()),
+			})
+			vb = ib.Next()
+		default:
+			if c.cmp(endA, endB) != 0 {
+				// The spans begin at the same key but end at different keys.
+				// Report each of them.
+				c.diffs = append(c.diffs, Difference{
+					Kind:       DifferenceRangeKeys,
+					Start:      append([]byte(nil), startA...),
+					End:        append([]byte(nil), endA...),
+					ARangeKeys: cloneRangeKeyData(ia.RangeKeys()),
+				}, Difference{
+					Kind:       DifferenceRangeKeys,
+					Start:      append([]byte(nil), startB...),
+					End:        append([]byte(nil), endB...),
+					BRangeKeys: cloneRangeKeyData(ib.RangeKeys()),
+				})
+			} else if !rangeKeyDataEqual(ia.RangeKeys(), ib.RangeKeys()) {
+				c.diffs = append(c.diffs, Difference{
+					Kind:       DifferenceRangeKeys,
+					Start:      append([]byte(nil), startA...),
+					End:        append([]byte(nil), endA...),
+					ARangeKeys: cloneRangeKeyData(ia.RangeKeys()),
+					BRangeKeys: cloneRangeKeyData(ib.RangeKeys()),
+				})
+			}
+			va, vb = ia.Next(), ib.Next()
+		}
+	}
+	return firstError(firstError(ia.Error(), ib.Error()), closeIters(ia, ib))
+}
+
+func cloneRangeKeyData(keys []RangeKeyData) []RangeKeyData {
+	if len(keys) == 0 {
+		return nil
+	}
+	c := make([]RangeKeyData, len(keys))
+	for i := range keys {
+		c[i] = RangeKeyData{
+			Suffix: append([]byte(nil), keys[i].Suffix...),
+			Value:  append([]byte(nil), keys[i].Value...),
+		}
+	}
+	return c
+}
+
+func rangeKeyDataEqual(a, b []RangeKeyData) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !bytes.Equal(a[i].Suffix, b[i].Suffix) || !bytes.Equal(a[i].Value, b[i].Value) {
+			return false
+		}
+	}
+	return true
+}
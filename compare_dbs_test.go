@@ -0,0 +1,89 @@
+// Copyright 2026 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package pebble
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/cockroachdb/pebble/internal/testkeys"
+	"github.com/cockroachdb/pebble/vfs"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompareDBs(t *testing.T) {
+	open := func() *DB {
+		d, err := Open("", &Options{
+			FS:                 vfs.NewMem(),
+			Comparer:           testkeys.Comparer,
+			FormatMajorVersion: FormatNewest,
+		})
+		require.NoError(t, err)
+		return d
+	}
+	a, b := open(), open()
+	defer func() {
+		require.NoError(t, a.Close())
+		require.NoError(t, b.Close())
+	}()
+
+	compare := func(opts *CompareOptions) []string {
+		diffs, err := CompareDBs(a, b, opts)
+		require.NoError(t, err)
+		var s []string
+		for _, d := range diffs {
+			s = append(s, d.String())
+		}
+		return s
+	}
+
+	// The same logical state, arrived at differently. A deletes keys with a
+	// range deletion and flushes, while B never writes them. A's range key is
+	// written as two abutting fragments.
+	for i := 0; i < 100; i++ {
+		k := []byte(fmt.Sprintf("k%03d", i))
+		require.NoError(t, a.Set(k, k, nil))
+		if i < 50 || i >= 60 {
+			require.NoError(t, b.Set(k, k, nil))
+		}
+		if i%25 == 0 {
+			require.NoError(t, a.Flush())
+		}
+	}
+	require.NoError(t, a.DeleteRange([]byte("k050"), []byte("k060"), nil))
+	require.NoError(t, a.Flush())
+	require.NoError(t, a.RangeKeySet([]byte("r1"), []byte("r3"), []byte("@1"), []byte("v"), nil))
+	require.NoError(t, a.RangeKeySet([]byte("r3"), []byte("r5"), []byte("@1"), []byte("v"), nil))
+	require.NoError(t, b.RangeKeySet([]byte("r1"), []byte("r5"), []byte("@1"), []byte("v"), nil))
+	require.Empty(t, compare(nil))
+	require.Empty(t, compare(&CompareOptions{Parallelism: 4}))
+
+	// Introduce differences of every kind.
+	require.NoError(t, a.Set([]byte("k010"), []byte("x"), nil))
+	require.NoError(t, a.Delete([]byte("k020"), nil))
+	require.NoError(t, b.Delete([]byte("k030"), nil))
+	require.NoError(t, b.RangeKeySet([]byte("r2"), []byte("r4"), []byte("@2"), []byte("w"), nil))
+	expected := []string{
+		`value "k010": a="x" b="k010"`,
+		`only-in-b "k020": a="" b="k020"`,
+		`only-in-a "k030": a="k030" b=""`,
+		`range-keys ["r1","r5"): a={"@1"="v"} b={}`,
+		`range-keys ["r1","r2"): a={} b={"@1"="v"}`,
+		`range-keys ["r2","r4"): a={} b={"@2"="w" "@1"="v"}`,
+		`range-keys ["r4","r5"): a={} b={"@1"="v"}`,
+	}
+	require.Equal(t, expected, compare(nil))
+	require.Equal(t, expected, compare(&CompareOptions{Parallelism: 4}))
+
+	// Bounds and limits restrict the differences reported.
+	require.Equal(t, expected[1:3], compare(&CompareOptions{
+		LowerBound: []byte("k015"),
+		UpperBound: []byte("k050"),
+	}))
+	require.Equal(t, expected[:2], compare(&CompareOptions{MaxDifferences: 2}))
+
+	_, err := CompareDBs(a, b, &CompareOptions{LowerBound: []byte("b"), UpperBound: []byte("a")})
+	require.Error(t, err)
+}
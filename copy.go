@@ -0,0 +1,293 @@
+// Copyright 2026 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package pebble
+
+import (
+	"context"
+	"io"
+	"os"
+	"sync/atomic"
+
+	"github.com/cockroachdb/errors"
+	"github.com/cockroachdb/errors/oserror"
+	"github.com/cockroachdb/pebble/internal/base"
+	"github.com/cockroachdb/pebble/record"
+	"github.com/cockroachdb/pebble/sstable"
+	"github.com/cockroachdb/pebble/vfs"
+	"github.com/cockroachdb/pebble/vfs/atomicfs"
+)
+
+// copyChunkSize is the size of the chunks in which CopyTo copies sstables.
+// The context passed to CopyTo is checked between chunks.
+const copyChunkSize = 1 << 20
+
+// CopyOptions hold the optional parameters for CopyTo.
+type CopyOptions struct {
+	// Progress, if non-nil, is invoked after each sstable is copied and
+	// verified.
+	Progress func(CopyProgress)
+}
+
+// CopyProgress describes the progress of a CopyTo call.
+type CopyProgress struct {
+	// TablesCopied is the number of sstables copied and verified so far.
+	TablesCopied int
+	// TablesTotal is the number of sstables being copied.
+	TablesTotal int
+	// BytesCopied is the total size of the sstables copied so far.
+	BytesCopied uint64
+	// BytesTotal is the total size of the sstables being copied.
+	BytesTotal uint64
+}
+
+// CopyTo copies the DB into destDir on the dest filesystem, which may differ
+// from the DB's own. Unlike Checkpoint, CopyTo never hard links files: each
+// live sstable is read and written to the destination, and the checksums of
+// all of the blocks of the written copy are verified before moving on to the
+// next sstable. A new MANIFEST describing only the copied sstables is written
+// in place of the DB's own, so the copy can be opened as a DB with the same
+// Options, and its OPTIONS file and format major version are those of the DB.
+//
+// The memtables are flushed before the copy is taken, so that all writes
+// committed before calling CopyTo are part of the copy, and no WAL is copied.
+// For a read-only DB, which cannot flush, writes that were recovered from the
+// WAL into the memtables when the DB was opened are not part of the copy.
+//
+// destDir must not exist. CopyTo may be canceled through ctx, in which case it
+// returns ctx.Err(). On error, CopyTo attempts to remove anything it wrote to
+// destDir.
+func (d *DB) CopyTo(
+	ctx context.Context, dest vfs.FS, destDir string, opts *CopyOptions,
+) (cpErr error /* used in deferred cleanup */) {
+	if err := d.closed.Load(); err != nil {
+		panic(err)
+	}
+	if opts == nil {
+		opts = &CopyOptions{}
+	}
+	if _, err := dest.Stat(destDir); !oserror.IsNotExist(err) {
+		if err == nil {
+			return &os.PathError{
+				Op:   "copy",
+				Path: destDir,
+				Err:  oserror.ErrExist,
+			}
+		}
+		return err
+	}
+	if !d.opts.ReadOnly {
+		if err := d.Flush(); err != nil {
+			return err
+		}
+	}
+
+	// Disable file deletions so that the sstables of the current version
+	// remain on disk while they're being copied.
+	d.mu.Lock()
+	d.disableFileDeletions()
+	defer func() {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		d.enableFileDeletions()
+	}()
+	current := d.mu.versions.currentVersion()
+	formatVers := d.mu.formatVers.vers
+	// The file number following the highest used by the DB is used for the
+	// copy's MANIFEST. All of the sequence numbers in the sstables of the
+	// current version are lower than logSeqNum.
+	manifestFileNum := d.mu.versions.nextFileNum
+	lastSeqNum := atomic.LoadUint64(&d.mu.versions.atomic.logSeqNum) - 1
+	optionsFileNum := d.optionsFileNum
+	d.mu.Unlock()
+
+	fs := syncingFS{
+		FS: dest,
+		syncOpts: vfs.SyncingFileOptions{
+			NoSyncOnClose: d.opts.NoSyncOnClose,
+			BytesPerSync:  d.opts.BytesPerSync,
+		},
+	}
+
+	var dir vfs.File
+	defer func() {
+		if dir != nil {
+			_ = dir.Close()
+		}
+		if cpErr != nil {
+			// Attempt to cleanup on error.
+			paths, _ := fs.List(destDir)
+			for _, path := range paths {
+				_ = fs.Remove(fs.PathJoin(destDir, path))
+			}
+			_ = fs.Remove(destDir)
+		}
+	}()
+	dir, cpErr = mkdirAllAndSyncParents(fs, destDir)
+	if cpErr != nil {
+		return cpErr
+	}
+
+	ve := versionEdit{
+		ComparerName:       d.opts.Comparer.Name,
+		MinUnflushedLogNum: manifestFileNum + 1,
+		NextFileNum:        manifestFileNum + 1,
+		LastSeqNum:         lastSeqNum,
+	}
+	var progress CopyProgress
+	for l := range current.Levels {
+		iter := current.Levels[l].Iter()
+		for f := iter.First(); f != nil; f = iter.Next() {
+			ve.NewFiles = append(ve.NewFiles, newFileEntry{Level: l, Meta: f})
+			progress.TablesTotal++
+			progress.BytesTotal += f.Size
+		}
+	}
+
+	// Copy and verify the sstables.
+	buf := make([]byte, copyChunkSize)
+	for _, nf := range ve.NewFiles {
+		srcPath := makeTableFilepath(d.opts.FS, d.dirname, nf.Meta.FileNum, nf.Meta.Name)
+		destPath := makeTableFilepath(fs, destDir, nf.Meta.FileNum, nf.Meta.Name)
+		if cpErr = copyTable(ctx, d.opts.FS, srcPath, fs, destPath, buf); cpErr != nil {
+			return cpErr
+		}
+		if cpErr = d.verifyCopiedTable(fs, destPath); cpErr != nil {
+			return errors.Wrapf(cpErr, "pebble: verifying copy of sstable %s", nf.Meta.FileNum)
+		}
+		progress.TablesCopied++
+		progress.BytesCopied += nf.Meta.Size
+		if opts.Progress != nil {
+			opts.Progress(progress)
+		}
+	}
+
+	{
+		// Copy the OPTIONS.
+		srcPath := base.MakeFilepath(d.opts.FS, d.dirname, fileTypeOptions, optionsFileNum)
+		destPath := fs.PathJoin(destDir, d.opts.FS.PathBase(srcPath))
+		if cpErr = vfs.CopyAcrossFS(d.opts.FS, srcPath, fs, destPath); cpErr != nil {
+			return cpErr
+		}
+	}
+
+	{
+		// Set the format major version in the destination directory.
+		var versionMarker *atomicfs.Marker
+		versionMarker, _, cpErr = atomicfs.LocateMarker(fs, destDir, formatVersionMarkerName)
+		if cpErr != nil {
+			return cpErr
+		}
+		cpErr = versionMarker.Move(formatVers.String())
+		if cpErr != nil {
+			return cpErr
+		}
+		cpErr = versionMarker.Close()
+		if cpErr != nil {
+			return cpErr
+		}
+	}
+
+	{
+		// Write the MANIFEST, and create a pointer to it.
+		path := base.MakeFilepath(fs, destDir, fileTypeManifest, manifestFileNum)
+		if cpErr = writeCopyManifest(fs, path, &ve); cpErr != nil {
+			return cpErr
+		}
+		var manifestMarker *atomicfs.Marker
+		manifestMarker, _, cpErr = atomicfs.LocateMarker(fs, destDir, manifestMarkerName)
+		if cpErr != nil {
+			return cpErr
+		}
+		cpErr = setCurrentFunc(formatVers, manifestMarker, fs, destDir, dir)(manifestFileNum)
+		if cpErr != nil {
+			return cpErr
+		}
+		cpErr = manifestMarker.Close()
+		if cpErr != nil {
+			return cpErr
+		}
+	}
+
+	// Sync and close the destination directory.
+	cpErr = dir.Sync()
+	if cpErr != nil {
+		return cpErr
+	}
+	cpErr = dir.Close()
+	dir = nil
+	return cpErr
+}
+
+// copyTable copies srcPath on srcFS to destPath on destFS in chunks of
+// len(buf) bytes, checking ctx between chunks.
+func copyTable(
+	ctx context.Context, srcFS vfs.FS, srcPath string, destFS vfs.FS, destPath string, buf []byte,
+) error {
+	src, err := srcFS.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := destFS.Create(destPath)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		n, err := io.ReadFull(src, buf)
+		if n > 0 {
+			if _, err := dst.Write(buf[:n]); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		} else if err != nil {
+			return err
+		}
+	}
+	return dst.Sync()
+}
+
+// verifyCopiedTable validates the checksums of all of the blocks of the
+// sstable at path. The blocks are read without going through the block
+// cache, so that they're read from the copy as written.
+func (d *DB) verifyCopiedTable(fs vfs.FS, path string) error {
+	f, err := fs.Open(path)
+	if err != nil {
+		return err
+	}
+	readerOpts := d.opts.MakeReaderOptions()
+	readerOpts.Cache = nil
+	r, err := sstable.NewReader(f, readerOpts)
+	if err != nil {
+		return err
+	}
+	return firstError(r.ValidateBlockChecksums(), r.Close())
+}
+
+// writeCopyManifest writes a MANIFEST containing the single version edit ve
+// at path.
+func writeCopyManifest(fs vfs.FS, path string, ve *versionEdit) error {
+	f, err := fs.Create(path)
+	if err != nil {
+		return err
+	}
+	w := record.NewWriter(f)
+	rw, err := w.Next()
+	if err == nil {
+		err = ve.Encode(rw)
+	}
+	if err == nil {
+		err = w.Close()
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	return firstError(err, f.Close())
+}
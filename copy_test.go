@@ -0,0 +1,126 @@
+// Copyright 2026 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package pebble
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+	"github.com/cockroachdb/errors/oserror"
+	"github.com/cockroachdb/pebble/vfs"
+	"github.com/stretchr/testify/require"
+)
+
+// corruptingFS corrupts the first byte written past offset 16 to each sstable
+// created on it.
+type corruptingFS struct {
+	vfs.FS
+}
+
+func (fs corruptingFS) Create(name string) (vfs.File, error) {
+	f, err := fs.FS.Create(name)
+	if err != nil || !strings.HasSuffix(name, ".sst") {
+		return f, err
+	}
+	return &corruptingFile{File: f}, nil
+}
+
+type corruptingFile struct {
+	vfs.File
+	corrupted bool
+}
+
+func (f *corruptingFile) Write(p []byte) (int, error) {
+	if !f.corrupted && len(p) > 16 {
+		p = append([]byte(nil), p...)
+		p[16] ^= 0xff
+		f.corrupted = true
+	}
+	return f.File.Write(p)
+}
+
+func TestCopyTo(t *testing.T) {
+	mem := vfs.NewMem()
+	opts := &Options{FS: mem}
+	d, err := Open("db", opts)
+	require.NoError(t, err)
+	defer func() { require.NoError(t, d.Close()) }()
+
+	// Write some keys into sstables across a few levels, and a few more into
+	// the memtable.
+	for i := 0; i < 100; i++ {
+		require.NoError(t, d.Set([]byte(fmt.Sprintf("a%03d", i)), []byte("lsm"), nil))
+	}
+	require.NoError(t, d.Compact([]byte("a"), []byte("b"), false))
+	for i := 0; i < 100; i += 2 {
+		require.NoError(t, d.Set([]byte(fmt.Sprintf("a%03d", i)), []byte("flushed"), nil))
+	}
+	require.NoError(t, d.Flush())
+	require.NoError(t, d.Set([]byte("b"), []byte("mem"), nil))
+
+	dest := vfs.NewMem()
+	var progress []CopyProgress
+	require.NoError(t, d.CopyTo(context.Background(), dest, "copy", &CopyOptions{
+		Progress: func(p CopyProgress) { progress = append(progress, p) },
+	}))
+	require.NotEmpty(t, progress)
+	last := progress[len(progress)-1]
+	require.Equal(t, len(progress), last.TablesTotal)
+	require.Equal(t, last.TablesTotal, last.TablesCopied)
+	require.Equal(t, last.BytesTotal, last.BytesCopied)
+
+	// The copy contains all of the writes committed before CopyTo, and none
+	// of the writes after it.
+	require.NoError(t, d.Set([]byte("c"), []byte("mem"), nil))
+	copyOpts := &Options{FS: dest}
+	c, err := Open("copy", copyOpts)
+	require.NoError(t, err)
+	iter := c.NewIter(nil)
+	var n int
+	for valid := iter.First(); valid; valid = iter.Next() {
+		key := string(iter.Key())
+		switch {
+		case key == "b":
+			require.Equal(t, "mem", string(iter.Value()))
+		case key[0] != 'a':
+			t.Fatalf("unexpected key %q", key)
+		case (key[3]-'0')%2 == 0:
+			require.Equal(t, "flushed", string(iter.Value()))
+		default:
+			require.Equal(t, "lsm", string(iter.Value()))
+		}
+		n++
+	}
+	require.NoError(t, iter.Close())
+	require.Equal(t, 101, n)
+	require.Equal(t, d.FormatMajorVersion(), c.FormatMajorVersion())
+
+	// The copy is a DB of its own, which can be written to.
+	require.NoError(t, c.Set([]byte("d"), []byte("copy"), nil))
+	require.NoError(t, c.Flush())
+	require.NoError(t, c.Close())
+
+	// The destination directory must not exist.
+	err = d.CopyTo(context.Background(), dest, "copy", nil)
+	require.True(t, oserror.IsExist(err), "%v", err)
+
+	// A copy that fails verification is removed.
+	err = d.CopyTo(context.Background(), corruptingFS{dest}, "corrupt", nil)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "verifying copy of sstable")
+	_, err = dest.Stat("corrupt")
+	require.True(t, oserror.IsNotExist(err), "%v", err)
+
+	// A canceled copy is removed.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = d.CopyTo(ctx, dest, "canceled", nil)
+	require.True(t, errors.Is(err, context.Canceled), "%v", err)
+	_, err = dest.Stat("canceled")
+	require.True(t, oserror.IsNotExist(err), "%v", err)
+}
@@ -165,6 +165,9 @@ func runIterCmd(d *datadriven.TestData, iter *Iterator, closeIter bool) string {
 			stats := iter.Stats()
 			fmt.Fprintf(&b, "stats: %s\n", stats.String())
 			continue
+		case "write-stats":
+			iter.WriteStats(&b)
+			continue
 		case "clone":
 			var opts CloneOptions
 			if len(parts) > 1 {
@@ -626,7 +629,7 @@ func runCompactCmd(td *datadriven.TestData, d *DB) error {
 		if err != nil {
 			return err
 		}
-		return d.manualCompact(iStart.UserKey, iEnd.UserKey, level, parallelize)
+		return d.manualCompact(iStart.UserKey, iEnd.UserKey, level, parallelize, nil /* progress */)
 	}
 	return d.Compact([]byte(parts[0]), []byte(parts[1]), parallelize)
 }
@@ -982,11 +985,17 @@ func (d *DB) waitTableStats() {
 
 func runIngestCmd(td *datadriven.TestData, d *DB, fs vfs.FS) error {
 	paths := make([]string, 0, len(td.CmdArgs))
+	var opts IngestOptions
 	for _, arg := range td.CmdArgs {
-		paths = append(paths, arg.String())
+		switch arg.Key {
+		case "disable-key-validation":
+			opts.DisableKeyValidation = true
+		default:
+			paths = append(paths, arg.String())
+		}
 	}
 
-	if err := d.Ingest(paths); err != nil {
+	if _, err := d.IngestWithOptions(paths, opts); err != nil {
 		return err
 	}
 	return nil
@@ -1017,7 +1026,7 @@ func runForceIngestCmd(td *datadriven.TestData, d *DB) error {
 		*fileMetadata,
 	) (int, error) {
 		return level, nil
-	})
+	}, IngestOptions{})
 	return err
 }
 
@@ -6,9 +6,10 @@
 package pebble // import "github.com/cockroachdb/pebble"
 
 import (
+	"bytes"
 	"fmt"
 	"io"
-	"os"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -45,6 +46,12 @@ var (
 	// ErrReadOnly is returned when a write operation is performed on a read-only
 	// database.
 	ErrReadOnly = errors.New("pebble: read-only")
+	// ErrDiskFull is returned when a write or ingestion is rejected because
+	// the disk space used by the DB would exceed Options.MaxDiskUsageBytes.
+	ErrDiskFull = errors.New("pebble: disk usage limit reached")
+	// ErrHistoryNotRetained is returned by GetAsOf when the versions of keys
+	// visible at the requested sequence number may no longer be retained.
+	ErrHistoryNotRetained = errors.New("pebble: history not retained")
 	// errNoSplit indicates that the user is trying to perform a range key
 	// operation but the configured Comparer does not provide a Split
 	// implementation.
@@ -58,6 +65,10 @@ type Reader interface {
 	// Get gets the value for the given key. It returns ErrNotFound if the DB
 	// does not contain the key.
 	//
+	// A key set to an empty value is present: Get returns a zero-length,
+	// possibly nil, value and no error. Only a key that was never set, or was
+	// deleted, is reported as ErrNotFound.
+	//
 	// The caller should not modify the contents of the returned slice, but it is
 	// safe to modify the contents of the argument after Get returns. The
 	// returned slice will remain valid until the returned Closer is closed. On
@@ -80,6 +91,11 @@ type Reader interface {
 
 // Writer is a writable key/value store.
 //
+// The empty key is a valid key, and the comparers provided by Pebble order it
+// before every other key. A nil key is the same key as the empty key, so
+// writing to a nil key writes to the empty key, and a range beginning at a nil
+// or empty key includes the empty key.
+//
 // Goroutine safety is dependent on the specific implementation.
 type Writer interface {
 	// Apply the operations contained in the batch to the DB.
@@ -137,6 +153,11 @@ type Writer interface {
 	// Set sets the value for the given key. It overwrites any previous value
 	// for that key; a DB is not a multi-map.
 	//
+	// The value may be empty (nil or zero-length). A key set to an empty
+	// value is distinct from a deleted key: it's found by Get and surfaced by
+	// iterators, with an empty value, and it's the base value of subsequent
+	// merge operands.
+	//
 	// It is safe to modify the contents of the arguments after Set returns.
 	Set(key, value []byte, o *WriteOptions) error
 
@@ -225,6 +246,57 @@ type DB struct {
 
 		// The number of bytes available on disk.
 		diskAvailBytes uint64
+
+		// The count and cumulative duration (in nanoseconds) of writes delayed
+		// because L0 read-amplification exceeded
+		// Options.L0SublevelReadAmpThreshold.
+		writeThrottleCount    int64
+		writeThrottleDuration int64
+
+		// The L0 read-amplification of the current version, stored whenever a
+		// new readState is installed, so that writes can compute their throttle
+		// delay without acquiring DB.mu.
+		l0ReadAmp int64
+
+		// The cumulative number of writes and ingestions rejected with
+		// ErrDiskFull.
+		diskFullRejections int64
+
+		// The cumulative number of retries of filesystem operations under
+		// Options.FSRetryPolicy.
+		fsRetries int64
+
+		// The cumulative number of positioning operations performed by closed
+		// iterators configured to surface range keys.
+		rangeKeyIterOps int64
+
+		// The cumulative number of iterators opened and closed.
+		itersOpened int64
+		itersClosed int64
+
+		// The cumulative number of flush and compaction outputs that failed
+		// validation. See Options.Experimental.ValidateOutputs.
+		outputValidationFailures int64
+
+		// Set to 1 when a sync of a WAL in the primary WAL directory exceeded
+		// Options.WALFailover.UnhealthySyncLatencyThreshold, and cleared when
+		// the next WAL is created.
+		walUnhealthy uint32
+
+		// The sequence number below which compactions may elide deletions, or
+		// zero if there is no such restriction. See
+		// Options.Experimental.GCFloorSeqNum.
+		gcFloorSeqNum uint64
+
+		// The number of bytes of write buffers held by the sstable writers of
+		// in-progress flushes and compactions, and the peak observed. See
+		// Options.SSTableWriteBufferSize.
+		writeBufferBytes     int64
+		peakWriteBufferBytes int64
+
+		// Set to 1 while writes are rejected because the filesystem was found
+		// to be read-only. See DB.IsReadOnlyDueToError.
+		readOnlyDueToError uint32
 	}
 
 	cacheID        uint64
@@ -243,10 +315,15 @@ type DB struct {
 	optionsFileNum FileNum
 	// The on-disk size of the current OPTIONS file.
 	optionsFileSize uint64
+	// iterTracker records the open iterators if
+	// Options.Experimental.TrackIteratorStacks is set, and is nil otherwise.
+	iterTracker *iterTracker
 
 	fileLock io.Closer
 	dataDir  vfs.File
 	walDir   vfs.File
+	// The secondary WAL directory configured by Options.WALFailover, or nil.
+	walFailoverDir vfs.File
 
 	tableCache           *tableCacheContainer
 	newIters             tableNewIters
@@ -342,6 +419,32 @@ type DB struct {
 			*record.LogWriter
 			// Can be nil.
 			metrics *record.LogWriterMetrics
+			// writeErr is the error of a failed WAL write that made the DB
+			// read-only. The LogWriter cannot be written to after a failure, so
+			// writes cannot resume until ResumeFromError has rotated the WAL.
+			writeErr error
+			// writerClosed is true once the LogWriter has been closed by a
+			// rotation of the WAL, until it's replaced by the LogWriter of the
+			// next WAL. The rotation leaves the closed LogWriter in place if it
+			// fails to create the next WAL. Protected by commitPipeline.mu.
+			writerClosed bool
+			// failover holds the state of the WAL failover configured by
+			// Options.WALFailover.
+			failover struct {
+				// active is true while new WALs are created in the secondary
+				// WAL directory.
+				active bool
+				// activatedAt is the time at which failover last became
+				// active.
+				activatedAt time.Time
+				// secondaryLogs holds the file numbers of the logs in the
+				// secondary WAL directory.
+				secondaryLogs map[FileNum]struct{}
+				// errors is the number of failed writes to WALs in the
+				// primary WAL directory since the last failover or failback.
+				// See Options.WALFailover.UnhealthyErrorThreshold.
+				errors int
+			}
 		}
 
 		mem struct {
@@ -364,6 +467,11 @@ type DB struct {
 			// footprint of memtables when lots of DB instances are used concurrently
 			// in test environments.
 			nextSize int
+			// mutableCreated is the time at which the most recently allocated
+			// memtable was created. It's used to measure the write rate
+			// observed by the mutable memtable when sizing memtables adaptively.
+			// See Options.Experimental.AdaptiveMemTableSize.
+			mutableCreated time.Time
 		}
 
 		compact struct {
@@ -375,6 +483,12 @@ type DB struct {
 			flushing bool
 			// The number of ongoing compactions.
 			compactingCount int
+			// The number of ReplaceAll calls in progress. No compactions are
+			// started while non-zero.
+			replacing int
+			// The number of outstanding calls to PauseCompactions. No
+			// compactions are started while non-zero.
+			paused int
 			// The list of deletion hints, suggesting ranges for delete-only
 			// compactions.
 			deletionHints []deleteCompactionHint
@@ -397,6 +511,10 @@ type DB struct {
 			// The idle start time for the flush "loop", i.e., when the flushing
 			// bool above transitions to false.
 			noOngoingFlushStartTime time.Time
+
+			// backgroundErr, if non-nil, is the error of a flush or compaction
+			// that paused background work. See DB.BackgroundError.
+			backgroundErr error
 		}
 
 		cleaner struct {
@@ -447,8 +565,28 @@ type DB struct {
 		}
 	}
 
+	// boundedStalenessSnapshot holds the snapshot shared by the iterators
+	// created by NewBoundedStalenessIter. Its mutex must be acquired before
+	// DB.mu.
+	boundedStalenessSnapshot struct {
+		sync.Mutex
+		// snapshot is the shared snapshot, or nil if there is none.
+		snapshot *Snapshot
+		// created is the time at which snapshot was created. The snapshot
+		// includes all writes made visible before it.
+		created time.Time
+		// releaseAt is the time at which snapshot is released, if it hasn't
+		// been replaced by then.
+		releaseAt time.Time
+		timer     *time.Timer
+	}
+
 	// Normally equal to time.Now() but may be overridden in tests.
 	timeNow func() time.Time
+
+	// compactionIOPriority is Options.Experimental.CompactionIOPriority, or
+	// the default if the priority can't be set on this platform.
+	compactionIOPriority IOPriority
 }
 
 var _ Reader = (*DB)(nil)
@@ -465,6 +603,98 @@ func (d *DB) Get(key []byte) ([]byte, io.Closer, error) {
 	return d.getInternal(key, nil /* batch */, nil /* snapshot */)
 }
 
+// GetWithProvenance gets the value for the given key, as Get does, and
+// reports where the value was read from: the level and file number of the
+// sstable containing the key, or a level of -1 and a file number of zero if
+// the key was read from a memtable. If the value is the result of merging
+// several merge operands, the provenance is that of the newest operand.
+//
+// GetWithProvenance is intended for diagnostics, such as identifying the
+// sstables serving the most reads as candidates for compaction, and tracking
+// the provenance adds a small cost to the read. It returns ErrNotFound if the
+// DB does not contain the key. As with Get, on success the caller MUST call
+// closer.Close() or a memory leak will occur.
+func (d *DB) GetWithProvenance(
+	key []byte,
+) (value []byte, fileNum FileNum, level int, closer io.Closer, err error) {
+	value, closer, err = d.getInternal(key, nil /* batch */, nil /* snapshot */)
+	if err != nil {
+		return nil, 0, 0, nil, err
+	}
+	g := &closer.(*Iterator).getIterAlloc.get
+	return value, g.srcFileNum, g.srcLevel, closer, nil
+}
+
+// GetAsOf gets the value for the given key as of the sequence number seqNum:
+// the value of the newest version of the key with a sequence number less than
+// or equal to seqNum. It returns ErrNotFound if the key had no value at that
+// point, i.e. it did not exist or its newest version was a deletion. The
+// sequence number of a write is reported by e.g. DB.SetWithSeq, and that
+// of the state visible to a snapshot by Snapshot.SeqNum, less one.
+//
+// Flushes and compactions discard the versions of a key shadowed by newer
+// versions, unless an open snapshot requires them, so a historical read is
+// only possible while the versions it needs are retained. GetAsOf reads at
+// seqNum only if it is at least HistoryFloorSeqNum, or if a snapshot is open
+// whose SeqNum is seqNum+1, and returns an error wrapping
+// ErrHistoryNotRetained otherwise. The floor is conservative: it advances to
+// the last sequence number assigned whenever a flush or compaction may have
+// discarded versions, and the history written before the DB was opened is
+// not retained. To read at a sequence number reliably, hold a snapshot open.
+// GetAsOf returns an error if seqNum has not yet been assigned to a
+// committed write.
+//
+// As with Get, the returned slice will remain valid until the returned
+// Closer is closed, and on success the caller MUST call closer.Close() or a
+// memory leak will occur.
+func (d *DB) GetAsOf(key []byte, seqNum uint64) (value []byte, closer io.Closer, err error) {
+	if err := d.closed.Load(); err != nil {
+		panic(err)
+	}
+	if visible := atomic.LoadUint64(&d.mu.versions.atomic.visibleSeqNum); seqNum >= visible {
+		return nil, nil, errors.Errorf("pebble: GetAsOf sequence number %d is not yet visible (next %d)",
+			errors.Safe(seqNum), errors.Safe(visible))
+	}
+	if err := d.checkHistoryRetained(seqNum); err != nil {
+		return nil, nil, err
+	}
+	value, closer, err = d.getInternal(key, nil /* batch */, &Snapshot{db: d, seqNum: seqNum + 1})
+	// A flush or compaction that discarded versions may have completed while
+	// reading, in which case the read may have observed its output.
+	if retainedErr := d.checkHistoryRetained(seqNum); retainedErr != nil {
+		if closer != nil {
+			closer.Close()
+		}
+		return nil, nil, retainedErr
+	}
+	return value, closer, err
+}
+
+// HistoryFloorSeqNum returns the smallest sequence number at which GetAsOf
+// may read without a snapshot open at that sequence number. It increases as
+// flushes and compactions complete.
+func (d *DB) HistoryFloorSeqNum() uint64 {
+	return atomic.LoadUint64(&d.mu.versions.atomic.historyFloorSeqNum)
+}
+
+// checkHistoryRetained returns an error wrapping ErrHistoryNotRetained if the
+// versions of keys visible at seqNum may no longer be retained.
+func (d *DB) checkHistoryRetained(seqNum uint64) error {
+	floor := d.HistoryFloorSeqNum()
+	if seqNum >= floor {
+		return nil
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for s := d.mu.snapshots.root.next; s != &d.mu.snapshots.root; s = s.next {
+		if s.seqNum == seqNum+1 {
+			return nil
+		}
+	}
+	return errors.Wrapf(ErrHistoryNotRetained, "sequence number %d is below the history floor %d",
+		errors.Safe(seqNum), errors.Safe(floor))
+}
+
 type getIterAlloc struct {
 	dbi    Iterator
 	keyBuf []byte
@@ -535,6 +765,7 @@ func (d *DB) getInternal(key []byte, b *Batch, s *Snapshot) ([]byte, io.Closer,
 		readState:    readState,
 		keyBuf:       buf.keyBuf,
 	}
+	i.opts.OnKeyAccess = d.opts.Experimental.OnGetKeyAccess
 
 	if !i.First() {
 		err := i.Close()
@@ -546,6 +777,129 @@ func (d *DB) getInternal(key []byte, b *Batch, s *Snapshot) ([]byte, io.Closer,
 	return i.Value(), i, nil
 }
 
+// GetLatestVersion returns the newest version of the given prefix: the first
+// key in the DB, and its value, that has the prefix as determined by
+// Comparer.Split. The versions of a prefix are assumed to sort from newest to
+// oldest, as with MVCC timestamps encoded in descending order, so that the
+// newest version is the first key with the prefix. It returns ErrNotFound if
+// the DB contains no key with the prefix.
+//
+// The prefix must be a complete prefix, with Comparer.Split(prefix) equal to
+// len(prefix). GetLatestVersion is equivalent to positioning an Iterator with
+// SeekPrefixGE(prefix) and reading its first key, with the Iterator
+// configured to use the bloom filters of every level, including L6, to skip
+// the sstables that don't contain the prefix. Unlike Get, it cannot stop at
+// the first level containing the prefix, since a newer version of the prefix
+// may be found in a lower level, for example after an ingestion.
+//
+// The caller should not modify the contents of the returned slices, but it is
+// safe to modify the contents of the argument after GetLatestVersion returns.
+// The returned slices will remain valid until the returned Closer is closed.
+// On success, the caller MUST call closer.Close() or a memory leak will occur.
+func (d *DB) GetLatestVersion(prefix []byte) (key, value []byte, closer io.Closer, err error) {
+	return d.getLatestVersionInternal(prefix, nil /* snapshot */)
+}
+
+func (d *DB) getLatestVersionInternal(
+	prefix []byte, s *Snapshot,
+) (key, value []byte, closer io.Closer, err error) {
+	if n := d.split(prefix); n != len(prefix) {
+		return nil, nil, nil, errors.Errorf("pebble: GetLatestVersion key %s is not a prefix",
+			d.opts.Comparer.FormatKey(prefix))
+	}
+	iter := d.newIterInternal(nil /* batch */, s, &IterOptions{UseL6Filters: true}, 0 /* minSeqNum */)
+	if !iter.SeekPrefixGE(prefix) {
+		if err := iter.Close(); err != nil {
+			return nil, nil, nil, err
+		}
+		return nil, nil, nil, ErrNotFound
+	}
+	return iter.Key(), iter.Value(), iter, nil
+}
+
+// GetMergeOperands returns the operands that would be merged to produce the
+// value for the given key, without invoking the Merger. It is intended for
+// debugging merge operators.
+//
+// The operands are returned in the order they were written, oldest first. If
+// the most recent Set of the key is visible, its value is returned as the
+// first operand, as it is the base value the operands would be merged onto.
+// Entries older than the most recent Set, Delete, SingleDelete or covering
+// range deletion are not returned. If the key has no visible Set or Merge,
+// GetMergeOperands returns ErrNotFound.
+//
+// The operands are returned as they're stored. Flushes and compactions merge
+// the operands of a key that are not separated by a snapshot, so an operand
+// returned by GetMergeOperands may itself be the result of a merge.
+//
+// The returned slices are copies, and may be retained by the caller.
+func (d *DB) GetMergeOperands(key []byte) ([][]byte, error) {
+	return d.getMergeOperandsInternal(key, nil /* snapshot */)
+}
+
+func (d *DB) getMergeOperandsInternal(key []byte, s *Snapshot) ([][]byte, error) {
+	if err := d.closed.Load(); err != nil {
+		panic(err)
+	}
+
+	readState := d.loadReadState()
+	defer readState.unref()
+
+	var seqNum uint64
+	if s != nil {
+		seqNum = s.seqNum
+	} else {
+		seqNum = atomic.LoadUint64(&d.mu.versions.atomic.visibleSeqNum)
+	}
+
+	get := &getIter{
+		logger:   d.opts.Logger,
+		cmp:      d.cmp,
+		equal:    d.equal,
+		newIters: d.newIters,
+		snapshot: seqNum,
+		key:      key,
+		mem:      readState.memtables,
+		l0:       readState.current.L0SublevelFiles,
+		version:  readState.current,
+	}
+	// Strip off memtables which cannot possibly contain the seqNum being read
+	// at. See getInternal.
+	for len(get.mem) > 0 {
+		n := len(get.mem)
+		if logSeqNum := get.mem[n-1].logSeqNum; logSeqNum < seqNum {
+			break
+		}
+		get.mem = get.mem[:n-1]
+	}
+
+	// The getIter surfaces the entries for the key newest first, stopping at a
+	// covering range deletion.
+	var operands [][]byte
+loop:
+	for k, v := get.First(); k != nil; k, v = get.Next() {
+		switch k.Kind() {
+		case InternalKeyKindMerge:
+			operands = append(operands, append([]byte(nil), v...))
+		case InternalKeyKindSet, InternalKeyKindSetWithDelete:
+			operands = append(operands, append([]byte(nil), v...))
+			break loop
+		default:
+			break loop
+		}
+	}
+	if err := get.Close(); err != nil {
+		return nil, err
+	}
+	if len(operands) == 0 {
+		return nil, ErrNotFound
+	}
+	for i, j := 0, len(operands)-1; i < j; i, j = i+1, j-1 {
+		operands[i], operands[j] = operands[j], operands[i]
+	}
+	return operands, nil
+}
+
 // Set sets the value for the given key. It overwrites any previous value
 // for that key; a DB is not a multi-map.
 //
@@ -561,6 +915,52 @@ func (d *DB) Set(key, value []byte, opts *WriteOptions) error {
 	return nil
 }
 
+// SetWithSeq is like Set, but returns the sequence number assigned to the
+// write by the commit pipeline. By the time SetWithSeq returns, the write is
+// visible to new reads, and is stored in the LSM as an internal key with the
+// returned sequence number.
+//
+// It is safe to modify the contents of the arguments after SetWithSeq returns.
+func (d *DB) SetWithSeq(key, value []byte, opts *WriteOptions) (seqNum uint64, err error) {
+	b := newBatch(d)
+	_ = b.Set(key, value, opts)
+	return d.applyWithSeq(b, opts)
+}
+
+// DeleteWithSeq is like Delete, but returns the sequence number assigned to
+// the write by the commit pipeline. See SetWithSeq.
+//
+// It is safe to modify the contents of the arguments after DeleteWithSeq
+// returns.
+func (d *DB) DeleteWithSeq(key []byte, opts *WriteOptions) (seqNum uint64, err error) {
+	b := newBatch(d)
+	_ = b.Delete(key, opts)
+	return d.applyWithSeq(b, opts)
+}
+
+// MergeWithSeq is like Merge, but returns the sequence number assigned to the
+// write by the commit pipeline. See SetWithSeq.
+//
+// It is safe to modify the contents of the arguments after MergeWithSeq
+// returns.
+func (d *DB) MergeWithSeq(key, value []byte, opts *WriteOptions) (seqNum uint64, err error) {
+	b := newBatch(d)
+	_ = b.Merge(key, value, opts)
+	return d.applyWithSeq(b, opts)
+}
+
+// applyWithSeq applies the single-operation batch b, returning the sequence
+// number that the commit pipeline assigned to it.
+func (d *DB) applyWithSeq(b *Batch, opts *WriteOptions) (uint64, error) {
+	if err := d.Apply(b, opts); err != nil {
+		return 0, err
+	}
+	seqNum := b.SeqNum()
+	// Only release the batch on success.
+	b.release()
+	return seqNum, nil
+}
+
 // Delete deletes the value for the given key. Deletes are blind all will
 // succeed even if the given key does not exist.
 //
@@ -576,6 +976,45 @@ func (d *DB) Delete(key []byte, opts *WriteOptions) error {
 	return nil
 }
 
+// DeleteIf deletes the key only if it's present and its value equals
+// expectedValue, and returns whether it deleted the key. A key whose value is
+// empty is present, and matched by an empty expectedValue.
+//
+// The read of the key and its deletion are atomic with respect to all other
+// writes to the DB: the value is read once every write committed before
+// DeleteIf is visible, and the deletion is sequenced immediately after the
+// read, so no write may be sequenced between them. Writes that commit after
+// DeleteIf returns are sequenced after the deletion. To provide this,
+// DeleteIf serializes with the commit pipeline, stalling other commits while
+// the key is read, so it should not be used on hot paths.
+//
+// It is safe to modify the contents of the arguments after DeleteIf returns.
+func (d *DB) DeleteIf(key, expectedValue []byte, opts *WriteOptions) (bool, error) {
+	b := newBatch(d)
+	_ = b.Delete(key, opts)
+	var getErr error
+	deleted, err := d.applyIf(b, opts, func() bool {
+		value, closer, err := d.Get(key)
+		if err != nil {
+			if err != ErrNotFound {
+				getErr = err
+			}
+			return false
+		}
+		defer closer.Close()
+		return bytes.Equal(value, expectedValue)
+	})
+	if err == nil {
+		err = getErr
+	}
+	if err != nil {
+		return false, err
+	}
+	// Only release the batch on success.
+	b.release()
+	return deleted, nil
+}
+
 // SingleDelete adds an action to the batch that single deletes the entry for key.
 // See Writer.SingleDelete for more details on the semantics of SingleDelete.
 //
@@ -607,6 +1046,60 @@ func (d *DB) DeleteRange(start, end []byte, opts *WriteOptions) error {
 	return nil
 }
 
+// DeletePrefixOptions configures DB.DeletePrefix.
+type DeletePrefixOptions struct {
+	// WriteOptions are the options for the write of the range tombstone.
+	WriteOptions *WriteOptions
+	// CompactionThreshold, if positive, causes DeletePrefix to compact the
+	// deleted keys, reclaiming the disk space they occupy, if they are
+	// estimated to occupy at least CompactionThreshold bytes. Otherwise, the
+	// space is reclaimed as the range tombstone is compacted in the normal
+	// course of background compactions.
+	CompactionThreshold uint64
+}
+
+// DeletePrefix deletes all of the keys (and values) beginning with prefix,
+// by writing a single range tombstone spanning from prefix to the smallest
+// key greater than every key beginning with prefix. If
+// opts.CompactionThreshold is met, DeletePrefix then compacts the deleted
+// range, and does not return until the compaction completes. opts may be
+// nil.
+//
+// The successor of the prefix is computed bytewise, by incrementing the last
+// byte of the prefix that is not 0xff, so DeletePrefix requires a Comparer
+// under which the keys beginning with a prefix are ordered bytewise relative
+// to keys not beginning with it, such as DefaultComparer. An error is
+// returned if the prefix is empty or consists only of 0xff bytes, for which
+// no such successor exists.
+//
+// It is safe to modify the contents of the arguments after DeletePrefix
+// returns.
+func (d *DB) DeletePrefix(prefix []byte, opts *DeletePrefixOptions) error {
+	if opts == nil {
+		opts = &DeletePrefixOptions{}
+	}
+	end := prefixSuccessor(prefix)
+	if end == nil {
+		return errors.Errorf("pebble: cannot delete prefix %s: no key sorts after every key with the prefix",
+			d.opts.Comparer.FormatKey(prefix))
+	}
+	var compact bool
+	if opts.CompactionThreshold > 0 {
+		size, err := d.EstimateDiskUsage(prefix, end)
+		if err != nil {
+			return err
+		}
+		compact = size >= opts.CompactionThreshold
+	}
+	if err := d.DeleteRange(prefix, end, opts.WriteOptions); err != nil {
+		return err
+	}
+	if !compact {
+		return nil
+	}
+	return d.Compact(prefix, end, false /* parallelize */)
+}
+
 // Merge adds an action to the DB that merges the value at key with the new
 // value. The details of the merge are dependent upon the configured merge
 // operator.
@@ -700,6 +1193,85 @@ func (d *DB) RangeKeyDelete(start, end []byte, opts *WriteOptions) error {
 //
 // It is safe to modify the contents of the arguments after Apply returns.
 func (d *DB) Apply(batch *Batch, opts *WriteOptions) error {
+	_, err := d.applyIf(batch, opts, nil)
+	return err
+}
+
+// Precondition is a condition on the value of a key, checked by
+// ApplyConditional before committing a batch.
+type Precondition struct {
+	// Key is the key whose value is checked.
+	Key []byte
+	// Value is the value the key is expected to hold. A key whose value is
+	// empty is present, and matched by an empty Value. Value is ignored if
+	// Absent is true.
+	Value []byte
+	// Absent, if true, expects the key to not be present in the DB.
+	Absent bool
+}
+
+// ApplyConditional applies the batch to the DB as Apply does, but only if
+// every one of the preconditions holds, and returns whether it applied the
+// batch. If a precondition doesn't hold, the batch is left unapplied and may
+// be modified and applied again, or closed. An empty batch is never applied.
+//
+// The preconditions are checked and the batch applied atomically with
+// respect to all other writes to the DB, providing serializable isolation:
+// the preconditions are read once every write committed before
+// ApplyConditional is visible, and the batch is sequenced immediately after
+// the reads, so no write, whether or not it's conditional, may be sequenced
+// between them. Writes that commit after ApplyConditional returns are
+// sequenced after the batch. The preconditions observe the DB itself, not
+// the contents of the batch, nor those of an indexed batch the caller may be
+// reading through. To provide this, ApplyConditional serializes with the
+// commit pipeline, stalling other commits while the preconditions are read,
+// so the number of preconditions should be kept small.
+//
+// It is safe to modify the contents of the arguments after ApplyConditional
+// returns.
+func (d *DB) ApplyConditional(
+	batch *Batch, preconditions []Precondition, opts *WriteOptions,
+) (committed bool, err error) {
+	var getErr error
+	committed, err = d.applyIf(batch, opts, func() bool {
+		for i := range preconditions {
+			ok, err := d.checkPrecondition(&preconditions[i])
+			if err != nil {
+				getErr = err
+				return false
+			}
+			if !ok {
+				return false
+			}
+		}
+		return true
+	})
+	if err == nil {
+		err = getErr
+	}
+	if err != nil {
+		return false, err
+	}
+	return committed, nil
+}
+
+// checkPrecondition reads the key of the precondition, and returns whether
+// the precondition holds.
+func (d *DB) checkPrecondition(p *Precondition) (bool, error) {
+	value, closer, err := d.Get(p.Key)
+	if err == ErrNotFound {
+		return p.Absent, nil
+	} else if err != nil {
+		return false, err
+	}
+	defer closer.Close()
+	return !p.Absent && bytes.Equal(value, p.Value), nil
+}
+
+// applyIf applies the batch to the DB as Apply does, but if cond is non-nil,
+// only if cond returns true. See commitPipeline.CommitIf. It returns whether
+// the batch was applied.
+func (d *DB) applyIf(batch *Batch, opts *WriteOptions, cond func() bool) (bool, error) {
 	if err := d.closed.Load(); err != nil {
 		panic(err)
 	}
@@ -707,7 +1279,10 @@ func (d *DB) Apply(batch *Batch, opts *WriteOptions) error {
 		panic("pebble: batch already applied")
 	}
 	if d.opts.ReadOnly {
-		return ErrReadOnly
+		return false, ErrReadOnly
+	}
+	if err := d.checkReadOnlyDueToError(); err != nil {
+		return false, err
 	}
 	if batch.db != nil && batch.db != d {
 		panic(fmt.Sprintf("pebble: batch db mismatch: %p != %p", batch.db, d))
@@ -715,12 +1290,12 @@ func (d *DB) Apply(batch *Batch, opts *WriteOptions) error {
 
 	sync := opts.GetSync()
 	if sync && d.opts.DisableWAL {
-		return errors.New("pebble: WAL disabled")
+		return false, errors.New("pebble: WAL disabled")
 	}
 
 	if batch.countRangeKeys > 0 {
 		if d.split == nil {
-			return errNoSplit
+			return false, errNoSplit
 		}
 		if d.FormatMajorVersion() < FormatRangeKeys {
 			panic(fmt.Sprintf(
@@ -735,10 +1310,38 @@ func (d *DB) Apply(batch *Batch, opts *WriteOptions) error {
 	if batch.db == nil {
 		batch.refreshMemTableSize()
 	}
+	if d.opts.MaxDiskUsageBytes > 0 && !batchOnlyDeletes(batch) {
+		if err := d.checkDiskUsage(uint64(len(batch.data))); err != nil {
+			return false, err
+		}
+	}
 	if int(batch.memTableSize) >= d.largeBatchThreshold {
 		batch.flushable = newFlushableBatch(batch, d.opts.Comparer)
 	}
-	if err := d.commit.Commit(batch, sync); err != nil {
+	if d.opts.L0SublevelReadAmpThreshold > 0 {
+		d.maybeThrottleWrite()
+	}
+	applied, err := d.commit.CommitIf(batch, sync, cond)
+	if err != nil && applied && d.opts.WALFailover != nil {
+		// The batch was applied to the memtable, but the WAL could not be
+		// synced. See WALFailoverOptions.
+		err = d.recoverWALSyncError(err)
+	}
+	if err != nil {
+		if applied && vfs.IsReadOnlyError(err) {
+			// The batch was applied to the memtable, but the WAL could not be
+			// synced because the filesystem is read-only. Reject further
+			// writes rather than crashing, so that reads continue to be
+			// served. See DB.IsReadOnlyDueToError.
+			d.mu.Lock()
+			if d.mu.log.writeErr == nil {
+				d.mu.log.writeErr = err
+			}
+			d.maybePauseBackgroundWorkLocked(err)
+			d.mu.compact.cond.Broadcast()
+			d.mu.Unlock()
+			return applied, err
+		}
 		// There isn't much we can do on an error here. The commit pipeline will be
 		// horked at this point.
 		d.opts.Logger.Fatalf("%v", err)
@@ -750,10 +1353,10 @@ func (d *DB) Apply(batch *Batch, opts *WriteOptions) error {
 	// skip the WAL write and instead wait for the large batch to be flushed to
 	// an sstable. For a 100 MB batch, this might actually be faster. For a 1
 	// GB batch this is almost certainly faster.
-	if batch.flushable != nil {
+	if applied && batch.flushable != nil {
 		batch.data = nil
 	}
-	return nil
+	return applied, nil
 }
 
 func (d *DB) commitApply(b *Batch, mem *memTable) error {
@@ -799,7 +1402,17 @@ func (d *DB) commitWrite(b *Batch, syncWG *sync.WaitGroup, syncErr *error) (*mem
 		// (see comment in newFlushableBatch()).
 		b.flushable.setSeqNum(b.SeqNum())
 		if !d.opts.DisableWAL {
+			// A failed WAL cannot be written to, so rotate it first. See
+			// WALFailoverOptions.
 			var err error
+			d.mu.Lock()
+			if d.walFailedLocked() {
+				err = d.makeRoomForWrite(nil)
+			}
+			d.mu.Unlock()
+			if err != nil {
+				return nil, err
+			}
 			size, err = d.mu.log.SyncRecord(repr, syncWG, syncErr)
 			if err != nil {
 				panic(err)
@@ -860,7 +1473,9 @@ var iterAllocPool = sync.Pool{
 
 // newIterInternal constructs a new iterator, merging in batch iterators as an extra
 // level.
-func (d *DB) newIterInternal(batch *Batch, s *Snapshot, o *IterOptions) *Iterator {
+func (d *DB) newIterInternal(
+	batch *Batch, s *Snapshot, o *IterOptions, minSeqNum uint64,
+) *Iterator {
 	if err := d.closed.Load(); err != nil {
 		panic(err)
 	}
@@ -906,6 +1521,7 @@ func (d *DB) newIterInternal(batch *Batch, s *Snapshot, o *IterOptions) *Iterato
 		equal:               d.equal,
 		merge:               d.merge,
 		split:               d.split,
+		formatKey:           d.opts.Comparer.FormatKey,
 		readState:           readState,
 		keyBuf:              buf.keyBuf,
 		prefixOrFullSeekKey: buf.prefixOrFullSeekKey,
@@ -914,6 +1530,7 @@ func (d *DB) newIterInternal(batch *Batch, s *Snapshot, o *IterOptions) *Iterato
 		newIters:            d.newIters,
 		newIterRangeKey:     d.tableNewRangeKeyIter,
 		seqNum:              seqNum,
+		minSeqNum:           minSeqNum,
 	}
 	if o != nil {
 		dbi.opts = *o
@@ -923,6 +1540,7 @@ func (d *DB) newIterInternal(batch *Batch, s *Snapshot, o *IterOptions) *Iterato
 	if batch != nil {
 		dbi.batchSeqNum = dbi.batch.nextSeqNum()
 	}
+	d.trackIterOpened(dbi)
 	return finishInitializingIter(buf)
 }
 
@@ -1038,6 +1656,15 @@ func (i *Iterator) constructPointIter(memtables flushableList, buf *iterAlloc) {
 	// We compute the number of levels needed ahead of time and reallocate a slice if
 	// the array from the iterAlloc isn't large enough. Doing this allocation once
 	// should improve the performance.
+	if i.minSeqNum > 0 {
+		// A memtable only contains sequence numbers below the log sequence
+		// number of the memtable that follows it. Skip the older memtables
+		// that only contain sequence numbers below minSeqNum.
+		for len(memtables) > 1 && memtables[1].logSeqNum <= i.minSeqNum {
+			memtables = memtables[1:]
+		}
+	}
+
 	numMergingLevels := 0
 	numLevelIters := 0
 	if i.batch != nil {
@@ -1046,10 +1673,30 @@ func (i *Iterator) constructPointIter(memtables flushableList, buf *iterAlloc) {
 	numMergingLevels += len(memtables)
 
 	current := i.readState.current
-	numMergingLevels += len(current.L0SublevelFiles)
-	numLevelIters += len(current.L0SublevelFiles)
+	l0Sublevels := current.L0SublevelFiles
+	var levelSlices [numLevels]manifest.LevelSlice
 	for level := 1; level < len(current.Levels); level++ {
-		if current.Levels[level].Empty() {
+		levelSlices[level] = current.Levels[level].Slice()
+	}
+	if i.minSeqNum > 0 {
+		// Skip the files that only contain sequence numbers below minSeqNum.
+		l0Sublevels = make([]manifest.LevelSlice, len(current.L0SublevelFiles))
+		for j := range current.L0SublevelFiles {
+			l0Sublevels[j] = filterFilesBySeqNum(i.cmp, current.L0SublevelFiles[j].Iter(), i.minSeqNum)
+		}
+		for level := 1; level < len(current.Levels); level++ {
+			levelSlices[level] = filterFilesBySeqNum(i.cmp, current.Levels[level].Iter(), i.minSeqNum)
+		}
+	}
+	for j := range l0Sublevels {
+		if l0Sublevels[j].Empty() {
+			continue
+		}
+		numMergingLevels++
+		numLevelIters++
+	}
+	for level := 1; level < len(levelSlices); level++ {
+		if levelSlices[level].Empty() {
 			continue
 		}
 		numMergingLevels++
@@ -1105,7 +1752,9 @@ func (i *Iterator) constructPointIter(memtables flushableList, buf *iterAlloc) {
 	levelsIndex := len(levels)
 	mlevels = mlevels[:numMergingLevels]
 	levels = levels[:numLevelIters]
-	var internalOpts internalIterOpts
+	// Values chunked into value blocks are only reassembled when the Iterator
+	// needs them. See Iterator.fetchValue.
+	internalOpts := internalIterOpts{lazyValues: true}
 	if i.opts.RangeKeyMasking.Filter != nil {
 		internalOpts.boundLimitedFilter = &i.rangeKeyMasking
 	}
@@ -1124,24 +1773,42 @@ func (i *Iterator) constructPointIter(memtables flushableList, buf *iterAlloc) {
 
 	// Add level iterators for the L0 sublevels, iterating from newest to
 	// oldest.
-	for i := len(current.L0SublevelFiles) - 1; i >= 0; i-- {
-		addLevelIterForFiles(current.L0SublevelFiles[i].Iter(), manifest.L0Sublevel(i))
+	for i := len(l0Sublevels) - 1; i >= 0; i-- {
+		if l0Sublevels[i].Empty() {
+			continue
+		}
+		addLevelIterForFiles(l0Sublevels[i].Iter(), manifest.L0Sublevel(i))
 	}
 
 	// Add level iterators for the non-empty non-L0 levels.
-	for level := 1; level < len(current.Levels); level++ {
-		if current.Levels[level].Empty() {
+	for level := 1; level < len(levelSlices); level++ {
+		if levelSlices[level].Empty() {
 			continue
 		}
-		addLevelIterForFiles(current.Levels[level].Iter(), manifest.Level(level))
+		addLevelIterForFiles(levelSlices[level].Iter(), manifest.Level(level))
 	}
 	buf.merging.init(&i.opts, i.cmp, i.split, mlevels...)
 	buf.merging.snapshot = i.seqNum
+	buf.merging.minSeqNum = i.minSeqNum
 	buf.merging.elideRangeTombstones = true
 	buf.merging.combinedIterState = &i.lazyCombinedIter.combinedIterState
 	i.pointIter = &buf.merging
 }
 
+// filterFilesBySeqNum returns a LevelSlice of the files of iter whose largest
+// sequence number is at least minSeqNum.
+func filterFilesBySeqNum(
+	cmp Compare, iter manifest.LevelIterator, minSeqNum uint64,
+) manifest.LevelSlice {
+	var files []*fileMetadata
+	for f := iter.First(); f != nil; f = iter.Next() {
+		if f.LargestSeqNum >= minSeqNum {
+			files = append(files, f)
+		}
+	}
+	return manifest.NewLevelSliceKeySorted(cmp, files)
+}
+
 // NewBatch returns a new empty write-only batch. Any reads on the batch will
 // return an error. If the batch is committed it will be applied to the DB.
 func (d *DB) NewBatch() *Batch {
@@ -1166,7 +1833,43 @@ func (d *DB) NewIndexedBatch() *Batch {
 // apparent memory and disk usage leak. Use snapshots (see NewSnapshot) for
 // point-in-time snapshots which avoids these problems.
 func (d *DB) NewIter(o *IterOptions) *Iterator {
-	return d.newIterInternal(nil /* batch */, nil /* snapshot */, o)
+	return d.newIterInternal(nil /* batch */, nil /* snapshot */, o, 0 /* minSeqNum */)
+}
+
+// NewIncrementalIter returns an iterator over the point keys in [lower,
+// upper) written at or after the sequence number sinceSeqNum, for use by
+// incremental exports such as backups. A nil lower or upper bound leaves the
+// iterator unbounded in that direction. The iterator reads the current state
+// of the DB as if every write with a sequence number below sinceSeqNum had
+// never happened: a key is surfaced if it was set since sinceSeqNum, and is
+// not surfaced if it was deleted since sinceSeqNum, or was last written
+// before it. The memtables and sstables that only contain sequence numbers
+// below sinceSeqNum are not read. Deletions are not surfaced: exports that
+// must propagate deletions should use an InternalIter and RangeDeletions,
+// subject to Options.Experimental.GCFloorSeqNum.
+//
+// An incremental export typically reads from a Snapshot with
+// Snapshot.NewIncrementalIter, passing the Snapshot.SeqNum of the previous
+// export's snapshot as sinceSeqNum. Keeping the previous export's snapshot
+// open until the next export's snapshot is taken prevents compactions from
+// merging the writes made before and after sinceSeqNum. Without such a
+// snapshot, the iterator can only observe the sequence numbers that
+// compactions retain:
+//
+//   - A key written since sinceSeqNum is not surfaced once a compaction
+//     moving it into the bottommost level zeroes its sequence number.
+//   - A key written before sinceSeqNum is not surfaced even if it was
+//     overwritten or deleted since, if compaction has dropped the newer write
+//     along with the older version; for example a SingleDelete meeting the
+//     Set it deletes.
+//   - Merge operands written before and after sinceSeqNum may have been
+//     combined by compaction into a single key with the newer sequence number,
+//     in which case the surfaced value includes the older operands.
+//
+// A sinceSeqNum of zero surfaces every key, and is equivalent to NewIter.
+func (d *DB) NewIncrementalIter(sinceSeqNum uint64, lower, upper []byte) *Iterator {
+	o := &IterOptions{LowerBound: lower, UpperBound: upper}
+	return d.newIterInternal(nil /* batch */, nil /* snapshot */, o, sinceSeqNum)
 }
 
 // NewSnapshot returns a point-in-time view of the current DB state. Iterators
@@ -1198,6 +1901,10 @@ func (d *DB) NewSnapshot() *Snapshot {
 // or to call Close concurrently with any other DB method. It is not valid
 // to call any of a DB's methods after the DB has been closed.
 func (d *DB) Close() error {
+	// Release the snapshot shared by bounded staleness iterators before
+	// acquiring d.mu, which releasing a snapshot acquires.
+	d.releaseBoundedStalenessSnapshot(nil /* ifCurrent */)
+
 	d.mu.Lock()
 	defer d.mu.Unlock()
 	if err := d.closed.Load(); err != nil {
@@ -1219,6 +1926,12 @@ func (d *DB) Close() error {
 	for d.mu.compact.compactingCount > 0 || d.mu.compact.flushing {
 		d.mu.compact.cond.Wait()
 	}
+	// Fail the queued manual compactions, which will never run, rather than
+	// leaving their callers waiting.
+	for _, manual := range d.mu.compact.manual {
+		manual.done <- ErrClosed
+	}
+	d.mu.compact.manual = nil
 	for d.mu.tableStats.loading {
 		d.mu.tableStats.cond.Wait()
 	}
@@ -1233,7 +1946,9 @@ func (d *DB) Close() error {
 	err = firstError(err, d.mu.formatVers.marker.Close())
 	err = firstError(err, d.tableCache.close())
 	if !d.opts.ReadOnly {
-		err = firstError(err, d.mu.log.Close())
+		if !d.mu.log.writerClosed {
+			err = firstError(err, d.mu.log.Close())
+		}
 	} else if d.mu.log.LogWriter != nil {
 		panic("pebble: log-writer should be nil in read-only mode")
 	}
@@ -1247,6 +1962,9 @@ func (d *DB) Close() error {
 	if d.dataDir != d.walDir {
 		err = firstError(err, d.walDir.Close())
 	}
+	if d.walFailoverDir != nil {
+		err = firstError(err, d.walFailoverDir.Close())
+	}
 
 	d.readState.val.unrefLocked()
 
@@ -1296,6 +2014,23 @@ func (d *DB) Close() error {
 
 // Compact the specified range of keys in the database.
 func (d *DB) Compact(start, end []byte, parallelize bool) error {
+	return d.CompactWithProgress(start, end, parallelize, nil /* progress */)
+}
+
+// CompactWithProgress does the same as Compact, additionally invoking
+// progress, if non-nil, with the progress of each compaction it runs.
+// Compacting a range of keys runs a compaction per level containing keys in
+// the range, and a compaction per disjoint subrange when parallelize is true,
+// in which case progress may be invoked concurrently. progress is invoked
+// periodically as each compaction iterates over its input, and a final time
+// when the compaction completes. Compactions that only move or delete files
+// complete without iterating over their input, and are not reported.
+//
+// progress is invoked from the goroutine running the compaction, which it
+// must not block for long.
+func (d *DB) CompactWithProgress(
+	start, end []byte, parallelize bool, progress func(CompactionProgress),
+) error {
 	if err := d.closed.Load(); err != nil {
 		panic(err)
 	}
@@ -1312,6 +2047,10 @@ func (d *DB) Compact(start, end []byte, parallelize bool) error {
 	meta := []*fileMetadata{m}
 
 	d.mu.Lock()
+	if err := d.mu.compact.backgroundErr; err != nil {
+		d.mu.Unlock()
+		return err
+	}
 	maxLevelWithFiles := 1
 	cur := d.mu.versions.currentVersion()
 	for level := 0; level < numLevels; level++ {
@@ -1359,12 +2098,14 @@ func (d *DB) Compact(start, end []byte, parallelize bool) error {
 		return err
 	}
 	if mem != nil {
-		<-mem.flushed
+		if err := d.waitForFlush(mem.flushed); err != nil {
+			return err
+		}
 	}
 
 	for level := 0; level < maxLevelWithFiles; {
 		if err := d.manualCompact(
-			iStart.UserKey, iEnd.UserKey, level, parallelize); err != nil {
+			iStart.UserKey, iEnd.UserKey, level, parallelize, progress); err != nil {
 			return err
 		}
 		level++
@@ -1377,7 +2118,9 @@ func (d *DB) Compact(start, end []byte, parallelize bool) error {
 	return nil
 }
 
-func (d *DB) manualCompact(start, end []byte, level int, parallelize bool) error {
+func (d *DB) manualCompact(
+	start, end []byte, level int, parallelize bool, progress func(CompactionProgress),
+) error {
 	d.mu.Lock()
 	curr := d.mu.versions.currentVersion()
 	files := curr.Overlaps(level, d.cmp, start, end, false)
@@ -1397,6 +2140,9 @@ func (d *DB) manualCompact(start, end []byte, level int, parallelize bool) error
 			end:   end,
 		})
 	}
+	for _, c := range compactions {
+		c.progress = progress
+	}
 	d.mu.compact.manual = append(d.mu.compact.manual, compactions...)
 	d.maybeScheduleCompaction()
 	d.mu.Unlock()
@@ -1440,20 +2186,228 @@ func (d *DB) splitManualCompaction(
 	return splitCompactions
 }
 
+// BackgroundError returns the error that paused the DB's background work, or
+// nil if background work is running normally.
+//
+// Most errors encountered by flushes and compactions are reported through
+// EventListener.BackgroundError and the work retried. An error caused by the
+// filesystem running out of space (ENOSPC) instead pauses all flushes and
+// compactions, to avoid repeatedly retrying work that cannot succeed, and is
+// returned by BackgroundError until ResumeFromError succeeds. While background
+// work is paused, reads proceed as usual and writes continue to fill the
+// memtables until the write stall thresholds are reached, at which point they
+// block. Flush, AsyncFlush and Compact return the error, while operations that
+// must wait for a memtable flush, such as an ingestion overlapping the
+// memtables, block until background work resumes.
+//
+// An error caused by the filesystem being read-only (EROFS), such as a mount
+// remounted read-only during a storage incident, also pauses background work,
+// and additionally makes the DB read-only: see IsReadOnlyDueToError.
+//
+// Errors writing to the WAL or MANIFEST are not reported by BackgroundError:
+// they are fatal, and invoke Logger.Fatalf. The exceptions are errors writing
+// to a WAL in the primary WAL directory when Options.WALFailover is
+// configured, which are recovered from by rotating the WAL (see
+// WALFailoverOptions), and an EROFS error syncing the WAL for a synced write,
+// which makes the DB read-only.
+func (d *DB) BackgroundError() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.mu.compact.backgroundErr
+}
+
+// ResumeFromError resumes background work paused by an error reported by
+// BackgroundError, for example once space has been freed on the filesystem or
+// the filesystem has been remounted writable. It waits for the flushes that
+// were pending due to the error to complete, and returns the error if
+// background work is paused again in the meantime. ResumeFromError returns nil
+// if background work is not paused.
+//
+// If the DB became read-only because a WAL write failed, the failed WAL cannot
+// be written to, and ResumeFromError first rotates the memtable to a new WAL
+// under a new log number, as a flush does. The writes in the failed WAL,
+// including those that weren't made durable by their sync, are in the rotated
+// memtable, and ResumeFromError waits for it to be flushed, after which the
+// failed WAL is obsolete. The failed WAL may not have been closed cleanly, so
+// if the process crashes before the flush completes, Open may find the failed
+// WAL to be corrupt.
+func (d *DB) ResumeFromError() error {
+	if err := d.closed.Load(); err != nil {
+		panic(err)
+	}
+	// Rotating the WAL requires commitPipeline.mu, which must be acquired
+	// before d.mu.
+	d.commit.mu.Lock()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.mu.compact.backgroundErr == nil && d.mu.log.writeErr == nil {
+		d.commit.mu.Unlock()
+		return nil
+	}
+	if err := d.mu.compact.backgroundErr; err != nil {
+		d.opts.Logger.Infof("resuming background work paused by: %s", err)
+	}
+	d.mu.compact.backgroundErr = nil
+	atomic.StoreUint32(&d.atomic.readOnlyDueToError, 0)
+	d.maybeScheduleFlush()
+	d.maybeScheduleCompaction()
+	var flushed chan struct{}
+	if d.mu.log.writeErr != nil {
+		// makeRoomForWrite pauses background work again if the new WAL can't
+		// be created because the filesystem is still read-only.
+		if err := d.makeRoomForWrite(nil); err != nil {
+			d.commit.mu.Unlock()
+			return err
+		}
+		d.mu.log.writeErr = nil
+		flushed = d.mu.mem.queue[len(d.mu.mem.queue)-2].flushed
+	}
+	d.commit.mu.Unlock()
+	for d.mu.compact.backgroundErr == nil {
+		if flushed != nil {
+			select {
+			case <-flushed:
+				flushed = nil
+			default:
+			}
+		}
+		if flushed == nil && !d.mu.compact.flushing {
+			break
+		}
+		d.mu.compact.cond.Wait()
+	}
+	return d.mu.compact.backgroundErr
+}
+
+// PauseCompactions prevents new compactions from starting until the returned
+// resume function is called. Compactions already in progress run to
+// completion, and flushes continue as usual. Automatic and manual compactions
+// alike are held back: a call to Compact waits for compactions to resume.
+// Pauses nest: compactions resume once every PauseCompactions call has been
+// matched by a call to its resume function. Calling a resume function more
+// than once has no further effect.
+//
+// Pausing is intended for short windows, such as around a latency-sensitive
+// operation that must not contend with compactions for IO. While
+// compactions are paused, flushed sstables accumulate in L0, increasing
+// read amplification, and writes stall once L0 reaches
+// Options.L0StopWritesThreshold files; prolonged pausing therefore risks
+// stalling writes until compactions resume. To stop automatic compactions
+// for longer periods, use Options.DisableAutomaticCompactions.
+func (d *DB) PauseCompactions() (resume func()) {
+	if err := d.closed.Load(); err != nil {
+		panic(err)
+	}
+	d.mu.Lock()
+	d.mu.compact.paused++
+	d.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			d.mu.Lock()
+			defer d.mu.Unlock()
+			d.mu.compact.paused--
+			d.maybeScheduleCompaction()
+		})
+	}
+}
+
+// IsReadOnlyDueToError returns true if the DB is rejecting writes because the
+// filesystem was found to be read-only (EROFS), for example because the mount
+// holding the DB's directory was remounted read-only after a storage error.
+//
+// A DB becomes read-only due to error when a flush, a compaction or the sync
+// of a synced write fails with EROFS. Its background work is then paused and
+// the error is reported by BackgroundError. While read-only, reads, iterators
+// and snapshots are served as usual from the memtables and sstables, and
+// writes fail with an error that matches both ErrReadOnly and the original
+// error under errors.Is. A write whose WAL sync failed was applied to the
+// memtable and is visible to reads, but it is not durable and is lost if the
+// process restarts.
+//
+// Once the filesystem is writable again, ResumeFromError resumes background
+// work and writes, rotating the WAL if a WAL write failed. Errors writing to
+// the WAL outside of a synced write remain fatal.
+func (d *DB) IsReadOnlyDueToError() bool {
+	return atomic.LoadUint32(&d.atomic.readOnlyDueToError) == 1
+}
+
+// checkReadOnlyDueToError returns an error if the DB is rejecting writes. See
+// IsReadOnlyDueToError.
+func (d *DB) checkReadOnlyDueToError() error {
+	if atomic.LoadUint32(&d.atomic.readOnlyDueToError) == 0 {
+		return nil
+	}
+	d.mu.Lock()
+	err := d.mu.compact.backgroundErr
+	d.mu.Unlock()
+	if err == nil {
+		return nil
+	}
+	return errors.Mark(errors.Wrap(err, "pebble: read-only due to error"), ErrReadOnly)
+}
+
+// maybePauseBackgroundWorkLocked pauses flushes and compactions if err is an
+// error that retrying background work cannot resolve, and makes the DB
+// read-only if the error is EROFS. See BackgroundError.
+//
+// d.mu must be held when calling this.
+func (d *DB) maybePauseBackgroundWorkLocked(err error) {
+	if vfs.IsReadOnlyError(err) {
+		// A read-only filesystem takes precedence over a full one, as it
+		// also prevents writes.
+		if atomic.LoadUint32(&d.atomic.readOnlyDueToError) == 1 {
+			return
+		}
+		d.opts.Logger.Infof("filesystem is read-only; pausing background work and rejecting writes: %s", err)
+		d.mu.compact.backgroundErr = err
+		atomic.StoreUint32(&d.atomic.readOnlyDueToError, 1)
+		return
+	}
+	if d.mu.compact.backgroundErr != nil || !vfs.IsNoSpaceError(err) {
+		return
+	}
+	d.opts.Logger.Infof("pausing background work: %s", err)
+	d.mu.compact.backgroundErr = err
+}
+
+// waitForFlush waits for the flushed channel of a memtable to be closed. It
+// returns early with the error reported by BackgroundError if background work
+// is paused before the memtable is flushed.
+func (d *DB) waitForFlush(flushed <-chan struct{}) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for {
+		select {
+		case <-flushed:
+			return nil
+		default:
+		}
+		if err := d.mu.compact.backgroundErr; err != nil {
+			return err
+		}
+		d.mu.compact.cond.Wait()
+	}
+}
+
 // Flush the memtable to stable storage.
+//
+// If background work is paused before the memtable is flushed, Flush returns
+// the error reported by BackgroundError.
 func (d *DB) Flush() error {
 	flushDone, err := d.AsyncFlush()
 	if err != nil {
 		return err
 	}
-	<-flushDone
-	return nil
+	return d.waitForFlush(flushDone)
 }
 
 // AsyncFlush asynchronously flushes the memtable to stable storage.
 //
 // If no error is returned, the caller can receive from the returned channel in
-// order to wait for the flush to complete.
+// order to wait for the flush to complete. If background work is paused,
+// AsyncFlush returns the error reported by BackgroundError.
 func (d *DB) AsyncFlush() (<-chan struct{}, error) {
 	if err := d.closed.Load(); err != nil {
 		panic(err)
@@ -1466,6 +2420,9 @@ func (d *DB) AsyncFlush() (<-chan struct{}, error) {
 	defer d.commit.mu.Unlock()
 	d.mu.Lock()
 	defer d.mu.Unlock()
+	if err := d.mu.compact.backgroundErr; err != nil {
+		return nil, err
+	}
 	flushed := d.mu.mem.queue[len(d.mu.mem.queue)-1].flushed
 	err := d.makeRoomForWrite(nil)
 	if err != nil {
@@ -1474,6 +2431,23 @@ func (d *DB) AsyncFlush() (<-chan struct{}, error) {
 	return flushed, nil
 }
 
+// SetGCFloorSeqNum sets the sequence number below which compactions may elide
+// point and range deletions. A seqNum of zero removes the restriction. See
+// Options.Experimental.GCFloorSeqNum.
+//
+// The new floor applies to compactions started after the call returns.
+// Deletions that were elided under a previous, higher floor are not restored
+// by lowering the floor.
+func (d *DB) SetGCFloorSeqNum(seqNum uint64) {
+	atomic.StoreUint64(&d.atomic.gcFloorSeqNum, seqNum)
+}
+
+// GCFloorSeqNum returns the current GC floor set by
+// Options.Experimental.GCFloorSeqNum or DB.SetGCFloorSeqNum.
+func (d *DB) GCFloorSeqNum() uint64 {
+	return atomic.LoadUint64(&d.atomic.gcFloorSeqNum)
+}
+
 // InternalIntervalMetrics returns the InternalIntervalMetrics and resets for
 // the next interval (which is until the next call to this method).
 func (d *DB) InternalIntervalMetrics() *InternalIntervalMetrics {
@@ -1493,6 +2467,39 @@ func (d *DB) InternalIntervalMetrics() *InternalIntervalMetrics {
 	return m
 }
 
+// RegisterVersionListener registers fn to be invoked with every edit applied to
+// the LSM after the call returns, such as by flushes, compactions and
+// ingestions. Each edit describes the tables it atomically added and removed,
+// so the listener observes the same sequence of changes as the LSM. Unlike the
+// EventListener callbacks, which describe individual jobs, the edits are
+// complete: applying them in order to the tables of the LSM at the time of
+// registration yields its current tables. Listeners cannot be unregistered,
+// and are invoked until the DB is closed.
+//
+// The listener is invoked synchronously once the edit has been applied,
+// while holding the DB mutex, and blocks all flushes, compactions,
+// ingestions and many other operations, including the creation of
+// iterators, until it returns. It must be cheap, for example only copying the
+// edit to a buffer or channel for processing elsewhere, and it must not call
+// into the DB.
+func (d *DB) RegisterVersionListener(fn func(VersionEditInfo)) {
+	if err := d.closed.Load(); err != nil {
+		panic(err)
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.mu.versions.versionListeners = append(d.mu.versions.versionListeners, fn)
+}
+
+// MetricsSince returns metrics about the database along with the change in
+// its cumulative counters since the prev snapshot, which is typically the
+// MetricsDelta.Current of a preceding call. A nil prev returns the counters'
+// current values. See MetricsDelta for a description of which fields are
+// reported as deltas.
+func (d *DB) MetricsSince(prev *Metrics) MetricsDelta {
+	return metricsDelta(d.Metrics(), prev)
+}
+
 // Metrics returns metrics about the database.
 func (d *DB) Metrics() *Metrics {
 	metrics := &Metrics{}
@@ -1501,17 +2508,37 @@ func (d *DB) Metrics() *Metrics {
 	d.mu.Lock()
 	*metrics = d.mu.versions.metrics
 	metrics.Compact.EstimatedDebt = d.mu.versions.picker.estimatedCompactionDebt(0)
+	metrics.Compact.LevelMultiplier = d.mu.versions.picker.getLevelMultiplier()
+	metrics.Compact.EstimatedWriteAmp = d.mu.versions.picker.getEstimatedMaxWAmp()
 	metrics.Compact.InProgressBytes = atomic.LoadInt64(&d.mu.versions.atomic.atomicInProgressBytes)
+	metrics.Compact.WriteBufferBytes = atomic.LoadInt64(&d.atomic.writeBufferBytes)
+	metrics.Compact.PeakWriteBufferBytes = atomic.LoadInt64(&d.atomic.peakWriteBufferBytes)
+	metrics.Compact.OutputValidationFailures = atomic.LoadInt64(&d.atomic.outputValidationFailures)
 	metrics.Compact.NumInProgress = int64(d.mu.compact.compactingCount)
 	metrics.Compact.MarkedFiles = d.mu.versions.currentVersion().Stats.MarkedForCompaction
+	if l0 := d.mu.versions.currentVersion().L0Sublevels; l0 != nil {
+		metrics.Flush.SplitBytes = d.opts.FlushSplitBytes * int64(len(l0.Levels))
+		metrics.Flush.NumSplitKeys = int64(len(l0.FlushSplitKeys()))
+	}
 	for _, m := range d.mu.mem.queue {
 		metrics.MemTable.Size += m.totalBytes()
 	}
+	for level, files := range d.mu.versions.currentVersion().RangeKeyLevels {
+		files.Slice().Each(func(f *fileMetadata) {
+			if f.StatsValidLocked() {
+				metrics.RangeKeys.Count[level] += f.Stats.NumRangeKeys
+				metrics.RangeKeys.Size += f.Stats.RangeKeySize
+			}
+		})
+	}
 	metrics.Snapshots.Count = d.mu.snapshots.count()
 	if metrics.Snapshots.Count > 0 {
 		metrics.Snapshots.EarliestSeqNum = d.mu.snapshots.earliest()
 	}
 	metrics.MemTable.Count = int64(len(d.mu.mem.queue))
+	if d.mu.mem.mutable != nil {
+		metrics.MemTable.MutableSize = d.mu.mem.mutable.totalBytes()
+	}
 	metrics.MemTable.ZombieCount = atomic.LoadInt64(&d.atomic.memTableCount) - metrics.MemTable.Count
 	metrics.MemTable.ZombieSize = uint64(atomic.LoadInt64(&d.atomic.memTableReserved)) - metrics.MemTable.Size
 	metrics.WAL.ObsoleteFiles = int64(recycledLogsCount)
@@ -1540,7 +2567,14 @@ func (d *DB) Metrics() *Metrics {
 		for level, score := range p.getScores(compactions) {
 			metrics.Levels[level].Score = score
 		}
+		for _, c := range compactions {
+			if c.outputLevel >= 0 {
+				metrics.Levels[c.outputLevel].CompactionsInProgress++
+			}
+		}
 	}
+	metrics.WriteThrottle.Active = d.writeThrottleDelay(
+		d.mu.versions.currentVersion().L0Sublevels.ReadAmplification()) > 0
 	metrics.Table.ZombieCount = int64(len(d.mu.versions.zombieTables))
 	for _, size := range d.mu.versions.zombieTables {
 		metrics.Table.ZombieSize += size
@@ -1554,7 +2588,25 @@ func (d *DB) Metrics() *Metrics {
 
 	metrics.BlockCache = d.opts.Cache.Metrics()
 	metrics.TableCache, metrics.Filter = d.tableCache.metrics()
+	metrics.MemoryBudget.Total = d.opts.MemoryBudget
+	metrics.MemoryBudget.MemTables = atomic.LoadInt64(&d.atomic.memTableReserved)
+	metrics.MemoryBudget.BlockCache = d.opts.Cache.MaxSize() - metrics.MemoryBudget.MemTables
+	metrics.MemoryBudget.TableCache = metrics.TableCache.Count * estimatedOpenTableMemory
 	metrics.TableIters = int64(d.tableCache.iterCount())
+	metrics.RangeKeys.IterOps = atomic.LoadInt64(&d.atomic.rangeKeyIterOps)
+	// Load the closed count first, so that the count of open iterators is
+	// never negative.
+	metrics.Iterators.Closed = atomic.LoadInt64(&d.atomic.itersClosed)
+	metrics.Iterators.Opened = atomic.LoadInt64(&d.atomic.itersOpened)
+	metrics.Iterators.Count = metrics.Iterators.Opened - metrics.Iterators.Closed
+	metrics.WriteThrottle.Count = atomic.LoadInt64(&d.atomic.writeThrottleCount)
+	metrics.WriteThrottle.Duration = time.Duration(atomic.LoadInt64(&d.atomic.writeThrottleDuration))
+	metrics.DiskUsage.Used = metrics.DiskSpaceUsage()
+	if d.opts.MaxDiskUsageBytes > 0 {
+		metrics.DiskUsage.Limit = uint64(d.opts.MaxDiskUsageBytes)
+	}
+	metrics.DiskUsage.RejectedWrites = atomic.LoadInt64(&d.atomic.diskFullRejections)
+	metrics.FSRetries.Count = atomic.LoadInt64(&d.atomic.fsRetries)
 	return metrics
 }
 
@@ -1562,6 +2614,9 @@ func (d *DB) Metrics() *Metrics {
 type sstablesOptions struct {
 	// set to true will return the sstable properties in TableInfo
 	withProperties bool
+
+	// if set, only tables overlapping the key range are returned
+	keyRange *KeyRange
 }
 
 // SSTablesOption set optional parameter used by `DB.SSTables`.
@@ -1577,12 +2632,52 @@ func WithProperties() SSTablesOption {
 	}
 }
 
+// WithKeyRange restricts the tables returned by `SSTables` to those
+// overlapping the user key range [start, end).
+func WithKeyRange(start, end []byte) SSTablesOption {
+	return func(opt *sstablesOptions) {
+		opt.keyRange = &KeyRange{Start: start, End: end}
+	}
+}
+
+// KeyRange encodes a key range in user key space. A KeyRange's Start is
+// inclusive while its End is exclusive.
+type KeyRange struct {
+	Start, End []byte
+}
+
+// overlaps returns true if the file's user key bounds overlap the key range.
+func (kr *KeyRange) overlaps(cmp Compare, m *fileMetadata) bool {
+	return cmp(m.Largest.UserKey, kr.Start) >= 0 && cmp(m.Smallest.UserKey, kr.End) < 0
+}
+
 // SSTableInfo export manifest.TableInfo with sstable.Properties
 type SSTableInfo struct {
 	manifest.TableInfo
 
 	// Properties is the sstable properties of this table.
 	Properties *sstable.Properties
+
+	// TimeRange is the range of timestamps recorded by the collector
+	// constructed by sstable.NewTimeRangePropertyCollector. It is only
+	// populated when the table properties are requested through
+	// WithProperties, and is nil if the table was written without the
+	// collector or contains no timestamped keys.
+	TimeRange *sstable.TimeRange
+
+	// KeyHistogram is the histogram of point keys recorded by the collector
+	// constructed by sstable.NewKeyHistogramPropertyCollector. As with
+	// TimeRange, it is only populated when the table properties are requested
+	// through WithProperties, and is nil if the table was written without the
+	// collector or contains no point keys. Combined with WithKeyRange, it may
+	// be used to estimate the number of keys in a range of the DB.
+	KeyHistogram *sstable.KeyHistogram
+
+	// ReadCount is the number of iterators, including those constructed by
+	// Get, that have read the table since it was added to the LSM or since
+	// the DB was opened, whichever is later. Reads by compactions aren't
+	// counted.
+	ReadCount uint64
 }
 
 // SSTables retrieves the current sstables. The returned slice is indexed by
@@ -1615,13 +2710,29 @@ func (d *DB) SSTables(opts ...SSTablesOption) ([][]SSTableInfo, error) {
 		iter := srcLevels[i].Iter()
 		j := 0
 		for m := iter.First(); m != nil; m = iter.Next() {
-			destTables[j] = SSTableInfo{TableInfo: m.TableInfo()}
+			if opt.keyRange != nil && !opt.keyRange.overlaps(d.cmp, m) {
+				continue
+			}
+			destTables[j] = SSTableInfo{
+				TableInfo: m.TableInfo(),
+				ReadCount: uint64(atomic.LoadInt64(&m.Atomic.ReadCount)),
+			}
 			if opt.withProperties {
 				p, err := d.tableCache.getTableProperties(m)
 				if err != nil {
 					return nil, err
 				}
 				destTables[j].Properties = p
+				if r, ok, err := sstable.ReadTimeRange(p); err != nil {
+					return nil, err
+				} else if ok {
+					destTables[j].TimeRange = &r
+				}
+				if h, ok, err := sstable.ReadKeyHistogram(p); err != nil {
+					return nil, err
+				} else if ok {
+					destTables[j].KeyHistogram = &h
+				}
 			}
 			j++
 		}
@@ -1631,6 +2742,14 @@ func (d *DB) SSTables(opts ...SSTablesOption) ([][]SSTableInfo, error) {
 	return destLevels, nil
 }
 
+// SSTablesInRange retrieves the current sstables overlapping the user key
+// range [start, end), as SSTables does with WithKeyRange. The properties of
+// the sstables, including their TimeRange and KeyHistogram, are populated if
+// the WithProperties option is passed.
+func (d *DB) SSTablesInRange(start, end []byte, opts ...SSTablesOption) ([][]SSTableInfo, error) {
+	return d.SSTables(append(opts, WithKeyRange(start, end))...)
+}
+
 // EstimateDiskUsage returns the estimated filesystem space used in bytes for
 // storing the range `[start, end]`. The estimation is computed as follows:
 //
@@ -1689,6 +2808,224 @@ func (d *DB) EstimateDiskUsage(start, end []byte) (uint64, error) {
 	return totalSize, nil
 }
 
+// ApproximateKeyCount returns an estimate of the number of live keys in the
+// DB, computed from the entry and deletion counts recorded in the properties
+// of each sstable without reading any data. The estimate assumes that every
+// point or range deletion shadows exactly one key, and is inexact when:
+//
+// - A key has multiple versions in different sstables, which are counted once
+//   per version until compactions merge them.
+// - A range deletion shadows more or fewer than one key, or a point deletion
+//   shadows nothing.
+// - Keys are written but not yet flushed, since the memtables are excluded.
+//
+// Range keys are not counted. The estimate is most accurate when the DB is
+// well compacted, and is intended for displays where an approximate count is
+// acceptable.
+func (d *DB) ApproximateKeyCount() uint64 {
+	if err := d.closed.Load(); err != nil {
+		panic(err)
+	}
+
+	// Grab and reference the current readState. This prevents the underlying
+	// files in the associated version from being deleted if there is a concurrent
+	// compaction.
+	readState := d.loadReadState()
+	defer readState.unref()
+
+	var entries, deletions uint64
+	for _, files := range readState.current.Levels {
+		iter := files.Iter()
+		for f := iter.First(); f != nil; f = iter.Next() {
+			if f.StatsValid() {
+				entries += f.Stats.NumEntries
+				deletions += f.Stats.NumDeletions
+				continue
+			}
+			// The table stats collector hasn't loaded this table's stats yet,
+			// so read them from its properties. A table whose properties can't
+			// be loaded is left out of the estimate.
+			_ = d.tableCache.withReader(f, func(r *sstable.Reader) error {
+				entries += r.Properties.NumEntries
+				deletions += r.Properties.NumDeletions
+				return nil
+			})
+		}
+	}
+	// Each deletion is itself an entry, and is assumed to shadow one more.
+	if 2*deletions >= entries {
+		return 0
+	}
+	return entries - 2*deletions
+}
+
+// The bounds on the sampling performed by DB.EstimateCompression.
+const (
+	estimateCompressionMaxFiles      = 16
+	estimateCompressionBlocksPerFile = 8
+)
+
+// EstimateCompressionRatio returns an estimate of the ratio of the compressed
+// size of the data within the user key range [lower, upper) to its
+// uncompressed size, as currently stored. See EstimateCompression.
+func (d *DB) EstimateCompressionRatio(lower, upper []byte) (float64, error) {
+	stats, err := d.EstimateCompression(lower, upper)
+	if err != nil {
+		return 0, err
+	}
+	return stats.Ratio(), nil
+}
+
+// EstimateCompression estimates how compressible the data within the user key
+// range [lower, upper) is, by sampling the data blocks of the sstables that
+// overlap the range. The returned stats describe the sampled blocks as
+// currently stored, and as they would be stored if recompressed with each of
+// SnappyCompression and ZstdCompression; see CompressionStats.Ratio and
+// CompressionStats.CodecRatio. A nil upper bound is treated as unbounded. The
+// data in memtables is not considered.
+//
+// Sampling is bounded so that the estimate is cheap: at most 16 sstables, chosen
+// evenly from those overlapping the range, are sampled, and at most 8 data
+// blocks, spread evenly across the range, are read from each. The sampled blocks are
+// read directly from the sstables, bypassing the block cache.
+func (d *DB) EstimateCompression(lower, upper []byte) (CompressionStats, error) {
+	if err := d.closed.Load(); err != nil {
+		panic(err)
+	}
+	if upper != nil && d.cmp(lower, upper) > 0 {
+		return CompressionStats{}, errors.New("invalid key-range specified (lower > upper)")
+	}
+
+	// Grab and reference the current readState. This prevents the underlying
+	// files in the associated version from being deleted if there is a
+	// concurrent compaction.
+	readState := d.loadReadState()
+	defer readState.unref()
+
+	var files []*fileMetadata
+	for level, levelFiles := range readState.current.Levels {
+		iter := levelFiles.Iter()
+		if level > 0 && upper != nil {
+			overlaps := readState.current.Overlaps(level, d.cmp, lower, upper, true /* exclusiveEnd */)
+			iter = overlaps.Iter()
+		}
+		for f := iter.First(); f != nil; f = iter.Next() {
+			if (upper == nil || d.cmp(f.Smallest.UserKey, upper) < 0) && d.cmp(lower, f.Largest.UserKey) <= 0 {
+				files = append(files, f)
+			}
+		}
+	}
+	if len(files) > estimateCompressionMaxFiles {
+		sampled := make([]*fileMetadata, estimateCompressionMaxFiles)
+		for i := range sampled {
+			sampled[i] = files[i*len(files)/estimateCompressionMaxFiles]
+		}
+		files = sampled
+	}
+
+	var stats CompressionStats
+	for _, f := range files {
+		err := d.tableCache.withReader(f, func(r *sstable.Reader) error {
+			s, err := r.EstimateCompression(lower, upper, estimateCompressionBlocksPerFile,
+				SnappyCompression, ZstdCompression)
+			stats.Merge(s)
+			return err
+		})
+		if err != nil {
+			return CompressionStats{}, err
+		}
+	}
+	return stats, nil
+}
+
+// SplitRange divides the user key range [lower, upper) into at most n
+// contiguous key ranges holding approximately equal amounts of data. The
+// returned ranges are in order, the first starts at lower and the last ends
+// at upper, and each split point between two ranges is a key present in the
+// DB. Fewer than n ranges are returned if the range holds too little data to
+// be divided further.
+//
+// The split points are approximate: they are chosen from the data block
+// boundaries recorded in the index blocks of the sstables that overlap the
+// range, weighted by the sizes of the blocks, and reflect the current file
+// metadata only. The data in memtables is not considered, and neither is
+// whether the data has been shadowed by newer writes or deletions.
+func (d *DB) SplitRange(lower, upper []byte, n int) ([]KeyRange, error) {
+	if err := d.closed.Load(); err != nil {
+		panic(err)
+	}
+	if n < 1 {
+		return nil, errors.Errorf("pebble: invalid number of key ranges: %d", n)
+	}
+	if d.cmp(lower, upper) >= 0 {
+		return nil, errors.New("invalid key-range specified (lower >= upper)")
+	}
+
+	// Grab and reference the current readState. This prevents the underlying
+	// files in the associated version from being deleted if there is a
+	// concurrent compaction.
+	readState := d.loadReadState()
+	defer readState.unref()
+
+	var boundaries []sstable.DataBlockBoundary
+	var totalSize uint64
+	for level, levelFiles := range readState.current.Levels {
+		iter := levelFiles.Iter()
+		if level > 0 {
+			overlaps := readState.current.Overlaps(level, d.cmp, lower, upper, true /* exclusiveEnd */)
+			iter = overlaps.Iter()
+		}
+		for f := iter.First(); f != nil; f = iter.Next() {
+			if d.cmp(f.Smallest.UserKey, upper) >= 0 || d.cmp(lower, f.Largest.UserKey) > 0 {
+				continue
+			}
+			err := d.tableCache.withReader(f, func(r *sstable.Reader) error {
+				b, err := r.DataBlockBoundaries(lower, upper)
+				for i := range b {
+					totalSize += b[i].Size
+				}
+				boundaries = append(boundaries, b...)
+				return err
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	sort.Slice(boundaries, func(i, j int) bool {
+		return d.cmp(boundaries[i].Key, boundaries[j].Key) < 0
+	})
+
+	// Pick the block boundaries at which the cumulative size of the blocks
+	// crosses each multiple of totalSize/n, and align each to the first key
+	// following the boundary.
+	iter := d.NewIter(&IterOptions{LowerBound: lower, UpperBound: upper})
+	ranges := []KeyRange{{Start: lower}}
+	var cumSize uint64
+	for i, j := 1, 0; i < n && j < len(boundaries); j++ {
+		cumSize += boundaries[j].Size
+		if cumSize*uint64(n) < totalSize*uint64(i) {
+			continue
+		}
+		for i < n && cumSize*uint64(n) >= totalSize*uint64(i) {
+			i++
+		}
+		key := boundaries[j].Key
+		if !iter.SeekGE(key) {
+			break
+		}
+		if d.equal(iter.Key(), key) && !iter.Next() {
+			break
+		}
+		if last := &ranges[len(ranges)-1]; d.cmp(iter.Key(), last.Start) > 0 {
+			last.End = append([]byte(nil), iter.Key()...)
+			ranges = append(ranges, KeyRange{Start: last.End})
+		}
+	}
+	ranges[len(ranges)-1].End = upper
+	return ranges, iter.Close()
+}
+
 func (d *DB) walPreallocateSize() int {
 	// Set the WAL preallocate size to 110% of the memtable size. Note that there
 	// is a bit of apples and oranges in units here as the memtabls size
@@ -1703,14 +3040,56 @@ func (d *DB) walPreallocateSize() int {
 	return size
 }
 
+// adaptiveMemTableSize returns the size of the memtable replacing the mutable
+// memtable imm, which is being rotated to make room for the batch b, under
+// Options.Experimental.AdaptiveMemTableSize. The size is chosen such that the
+// new memtable would fill in approximately the target flush interval at the
+// write rate observed by imm, but is at most twice and at least half the size
+// of imm. The size is large enough to hold b, if b is to be applied to the new
+// memtable.
+func (d *DB) adaptiveMemTableSize(imm *memTable, b *Batch) int {
+	o := &d.opts.Experimental.AdaptiveMemTableSize
+	prevSize := int(imm.totalBytes())
+	size := 2 * prevSize
+	if lifetime := d.timeNow().Sub(d.mu.mem.mutableCreated); lifetime > 0 {
+		rate := float64(imm.inuseBytes()) / lifetime.Seconds()
+		if target := rate * o.TargetFlushInterval.Seconds(); target < float64(size) {
+			size = int(target)
+		}
+	}
+	if size < prevSize/2 {
+		size = prevSize / 2
+	}
+	if size > o.MaxSize {
+		size = o.MaxSize
+	}
+	if size < o.MinSize {
+		size = o.MinSize
+	}
+	// A batch that did not fit in imm must fit in the new memtable. Batches
+	// larger than the largeBatchThreshold are not applied to memtables, so
+	// this never exceeds MaxSize.
+	if b != nil && b.flushable == nil {
+		if required := int(b.memTableSize) + int(memTableEmptySize); size < required {
+			size = required
+		}
+	}
+	return size
+}
+
 func (d *DB) newMemTable(logNum FileNum, logSeqNum uint64) (*memTable, *flushableEntry) {
 	size := d.mu.mem.nextSize
-	if d.mu.mem.nextSize < d.opts.MemTableSize {
+	maxSize := d.opts.MemTableSize
+	if d.opts.Experimental.AdaptiveMemTableSize.Enabled {
+		maxSize = d.opts.Experimental.AdaptiveMemTableSize.MaxSize
+	}
+	if d.mu.mem.nextSize < maxSize {
 		d.mu.mem.nextSize *= 2
-		if d.mu.mem.nextSize > d.opts.MemTableSize {
-			d.mu.mem.nextSize = d.opts.MemTableSize
+		if d.mu.mem.nextSize > maxSize {
+			d.mu.mem.nextSize = maxSize
 		}
 	}
+	d.mu.mem.mutableCreated = d.timeNow()
 
 	atomic.AddInt64(&d.atomic.memTableCount, 1)
 	atomic.AddInt64(&d.atomic.memTableReserved, int64(size))
@@ -1746,24 +3125,61 @@ func (d *DB) newFlushableEntry(f flushable, logNum FileNum, logSeqNum uint64) *f
 	}
 }
 
+// l0ThrottleMaxDelay is the delay applied to a write when L0
+// read-amplification is one sublevel short of Options.L0StopWritesThreshold.
+const l0ThrottleMaxDelay = time.Millisecond
+
+// writeThrottleDelay returns the delay to apply to a write given the current
+// L0 read-amplification. Writes are not delayed below
+// Options.L0SublevelReadAmpThreshold, and the delay ramps up linearly as L0
+// read-amplification approaches Options.L0StopWritesThreshold, at which point
+// makeRoomForWrite stalls writes entirely.
+func (d *DB) writeThrottleDelay(l0ReadAmp int) time.Duration {
+	soft, hard := d.opts.L0SublevelReadAmpThreshold, d.opts.L0StopWritesThreshold
+	if soft <= 0 || l0ReadAmp < soft {
+		return 0
+	}
+	if l0ReadAmp >= hard {
+		l0ReadAmp = hard - 1
+	}
+	return l0ThrottleMaxDelay * time.Duration(l0ReadAmp-soft+1) / time.Duration(hard-soft)
+}
+
+// maybeThrottleWrite delays the calling write if L0 read-amplification has
+// reached Options.L0SublevelReadAmpThreshold. It's called before the write
+// enters the commit pipeline, so that the delay doesn't hold up concurrent
+// writes.
+func (d *DB) maybeThrottleWrite() {
+	delay := d.writeThrottleDelay(int(atomic.LoadInt64(&d.atomic.l0ReadAmp)))
+	if delay == 0 {
+		return
+	}
+	time.Sleep(delay)
+	atomic.AddInt64(&d.atomic.writeThrottleCount, 1)
+	atomic.AddInt64(&d.atomic.writeThrottleDuration, int64(delay))
+}
+
 // makeRoomForWrite ensures that the memtable has room to hold the contents of
 // Batch. It reserves the space in the memtable and adds a reference to the
 // memtable. The caller must later ensure that the memtable is unreferenced. If
 // the memtable is full, or a nil Batch is provided, the current memtable is
 // rotated (marked as immutable) and a new mutable memtable is allocated. This
-// memtable rotation also causes a log rotation.
+// memtable rotation also causes a log rotation. The memtable is also rotated,
+// and its flush forced, if the unflushed WAL has reached Options.MaxWALSize.
 //
 // Both DB.mu and commitPipeline.mu must be held by the caller. Note that DB.mu
 // may be released and reacquired.
 func (d *DB) makeRoomForWrite(b *Batch) error {
 	force := b == nil || b.flushable != nil
+	walForced := !force && (d.walSizeExceededLocked() || d.walFailoverPendingLocked())
+	force = force || walForced
 	stalled := false
 	for {
 		if d.mu.mem.switching {
 			d.mu.mem.cond.Wait()
 			continue
 		}
-		if b != nil && b.flushable == nil {
+		if b != nil && b.flushable == nil && !force {
 			err := d.mu.mem.mutable.prepare(b)
 			if err != arenaskl.ErrArenaFull {
 				if stalled {
@@ -1813,6 +3229,7 @@ func (d *DB) makeRoomForWrite(b *Batch) error {
 		var newLogFile vfs.File
 		var newLogSize uint64
 		var prevLogSize uint64
+		var prevLogErr error
 		var err error
 
 		if !d.opts.DisableWAL {
@@ -1829,6 +3246,7 @@ func (d *DB) makeRoomForWrite(b *Batch) error {
 			if d.mu.log.queue[len(d.mu.log.queue)-1].fileSize < prevLogSize {
 				d.mu.log.queue[len(d.mu.log.queue)-1].fileSize = prevLogSize
 			}
+			prevLogFailed := d.mu.log.writeErr != nil
 			d.mu.Unlock()
 
 			// Close the previous log first. This writes an EOF trailer
@@ -1836,76 +3254,64 @@ func (d *DB) makeRoomForWrite(b *Batch) error {
 			// close the previous log before linking the new log file,
 			// otherwise a crash could leave both logs with unclean tails, and
 			// Open will treat the previous log as corrupt.
-			err = d.mu.log.LogWriter.Close()
-			metrics := d.mu.log.LogWriter.Metrics()
+			//
+			// If a write to the previous log failed, the error closing it is
+			// ignored: its writes are in the memtable being rotated, and are
+			// made durable by flushing it. See DB.ResumeFromError.
+			var metrics *record.LogWriterMetrics
+			if !d.mu.log.writerClosed {
+				err = d.mu.log.LogWriter.Close()
+				metrics = d.mu.log.LogWriter.Metrics()
+				d.mu.log.writerClosed = true
+			}
+			if prevLogFailed {
+				err = nil
+			}
 			d.mu.Lock()
-			if d.mu.log.metrics == nil {
+			// If WAL failover is configured, a failed write to or sync of a
+			// previous log in the primary WAL directory is recovered from:
+			// its writes are in the memtable being rotated, which is flushed
+			// before makeRoomForWrite returns, and so before any write is
+			// committed to the new log. See WALFailoverOptions.
+			if err != nil && d.opts.WALFailover != nil && !d.currentWALSecondaryLocked() {
+				d.walErrorLocked(err)
+				prevLogErr, err = err, nil
+			}
+			if metrics == nil {
+				// The previous log was closed by an earlier rotation.
+			} else if d.mu.log.metrics == nil {
 				d.mu.log.metrics = metrics
 			} else {
 				if err := d.mu.log.metrics.Merge(metrics); err != nil {
 					d.opts.Logger.Infof("metrics error: %s", err)
 				}
 			}
+			loc := d.walLocationLocked()
 			d.mu.Unlock()
 
-			newLogName := base.MakeFilepath(d.opts.FS, d.walDirname, fileTypeLog, newLogNum)
+			newLogName := base.MakeFilepath(loc.fs, loc.dirname, fileTypeLog, newLogNum)
 
-			// Try to use a recycled log file. Recycling log files is an important
-			// performance optimization as it is faster to sync a file that has
-			// already been written, than one which is being written for the first
-			// time. This is due to the need to sync file metadata when a file is
-			// being written for the first time. Note this is true even if file
-			// preallocation is performed (e.g. fallocate).
 			var recycleLog fileInfo
-			var recycleOK bool
 			if err == nil {
-				recycleLog, recycleOK = d.logRecycler.peek()
-				if recycleOK {
-					recycleLogName := base.MakeFilepath(d.opts.FS, d.walDirname, fileTypeLog, recycleLog.fileNum)
-					newLogFile, err = d.opts.FS.ReuseForWrite(recycleLogName, newLogName)
-					base.MustExist(d.opts.FS, newLogName, d.opts.Logger, err)
-				} else {
-					newLogFile, err = d.opts.FS.Create(newLogName)
-					base.MustExist(d.opts.FS, newLogName, d.opts.Logger, err)
-				}
-			}
-
-			if err == nil && recycleOK {
-				// Figure out the recycled WAL size. This Stat is necessary
-				// because ReuseForWrite's contract allows for removing the
-				// old file and creating a new one. We don't know whether the
-				// WAL was actually recycled.
-				// TODO(jackson): Adding a boolean to the ReuseForWrite return
-				// value indicating whether or not the file was actually
-				// reused would allow us to skip the stat and use
-				// recycleLog.fileSize.
-				var finfo os.FileInfo
-				finfo, err = newLogFile.Stat()
-				if err == nil {
-					newLogSize = uint64(finfo.Size())
+				newLogFile, newLogSize, recycleLog, err = d.createWAL(loc, newLogName)
+				// If the WAL couldn't be created in the primary WAL directory,
+				// fail over to the secondary directory.
+				if err != nil && !loc.secondary && d.opts.WALFailover != nil {
+					d.opts.EventListener.WALCreated(WALCreateInfo{
+						JobID:           jobID,
+						Path:            newLogName,
+						FileNum:         newLogNum,
+						RecycledFileNum: recycleLog.fileNum,
+						Err:             err,
+					})
+					d.mu.Lock()
+					loc = d.failoverWALLocked(err)
+					d.mu.Unlock()
+					newLogName = base.MakeFilepath(loc.fs, loc.dirname, fileTypeLog, newLogNum)
+					newLogFile, newLogSize, recycleLog, err = d.createWAL(loc, newLogName)
 				}
 			}
 
-			if err == nil {
-				// TODO(peter): RocksDB delays sync of the parent directory until the
-				// first time the log is synced. Is that worthwhile?
-				err = d.walDir.Sync()
-			}
-
-			if err != nil && newLogFile != nil {
-				newLogFile.Close()
-			} else if err == nil {
-				newLogFile = vfs.NewSyncingFile(newLogFile, vfs.SyncingFileOptions{
-					NoSyncOnClose:   d.opts.NoSyncOnClose,
-					BytesPerSync:    d.opts.WALBytesPerSync,
-					PreallocateSize: d.walPreallocateSize(),
-				})
-			}
-
-			if recycleOK {
-				err = firstError(err, d.logRecycler.pop(recycleLog.fileNum))
-			}
-
 			d.opts.EventListener.WALCreated(WALCreateInfo{
 				JobID:           jobID,
 				Path:            newLogName,
@@ -1917,10 +3323,24 @@ func (d *DB) makeRoomForWrite(b *Batch) error {
 			d.mu.Lock()
 			d.mu.mem.switching = false
 			d.mu.mem.cond.Broadcast()
+			if err == nil && loc.secondary {
+				d.mu.log.failover.secondaryLogs[newLogNum] = struct{}{}
+			}
 
 			d.mu.versions.metrics.WAL.Files++
 		}
 
+		if err != nil && vfs.IsReadOnlyError(err) {
+			// The filesystem is read-only. The previous log is closed and
+			// cannot be written to, so make the DB read-only rather than
+			// crashing. See DB.IsReadOnlyDueToError.
+			if d.mu.log.writeErr == nil {
+				d.mu.log.writeErr = err
+			}
+			d.maybePauseBackgroundWorkLocked(err)
+			d.mu.compact.cond.Broadcast()
+			return err
+		}
 		if err != nil {
 			// TODO(peter): avoid chewing through file numbers in a tight loop if there
 			// is an error here.
@@ -1933,19 +3353,22 @@ func (d *DB) makeRoomForWrite(b *Batch) error {
 		if !d.opts.DisableWAL {
 			d.mu.log.queue = append(d.mu.log.queue, fileInfo{fileNum: newLogNum, fileSize: newLogSize})
 			d.mu.log.LogWriter = record.NewLogWriter(newLogFile, newLogNum)
+			d.mu.log.writerClosed = false
 			d.mu.log.LogWriter.SetMinSyncInterval(d.opts.WALMinSyncInterval)
 		}
 
 		immMem := d.mu.mem.mutable
 		imm := d.mu.mem.queue[len(d.mu.mem.queue)-1]
 		imm.logSize = prevLogSize
-		imm.flushForced = imm.flushForced || (b == nil)
-
-		// If we are manually flushing and we used less than half of the bytes in
-		// the memtable, don't increase the size for the next memtable. This
-		// reduces memtable memory pressure when an application is frequently
-		// manually flushing.
-		if (b == nil) && uint64(immMem.availBytes()) > immMem.totalBytes()/2 {
+		imm.flushForced = imm.flushForced || (b == nil) || walForced || prevLogErr != nil
+
+		// If we are manually flushing (or flushing to bound the WAL size) and we
+		// used less than half of the bytes in the memtable, don't increase the
+		// size for the next memtable. This reduces memtable memory pressure when
+		// an application is frequently manually flushing.
+		if d.opts.Experimental.AdaptiveMemTableSize.Enabled {
+			d.mu.mem.nextSize = d.adaptiveMemTableSize(immMem, b)
+		} else if (b == nil || walForced) && uint64(immMem.availBytes()) > immMem.totalBytes()/2 {
 			d.mu.mem.nextSize = int(immMem.totalBytes())
 		}
 
@@ -1999,8 +3422,45 @@ func (d *DB) makeRoomForWrite(b *Batch) error {
 		if immMem.writerUnref() {
 			d.maybeScheduleFlush()
 		}
+		if prevLogErr != nil {
+			if err := d.waitForFlushLocked(d.mu.mem.queue[len(d.mu.mem.queue)-2].flushed); err != nil {
+				return err
+			}
+		}
 		force = false
+		walForced = false
+	}
+}
+
+// unflushedWALSizeLocked returns the logical size of the WAL backing the
+// mutable memtable and the immutable memtables that are yet to be flushed. This
+// is the volume of WAL that would be replayed if the DB were reopened.
+//
+// d.mu must be held when calling this.
+func (d *DB) unflushedWALSizeLocked() uint64 {
+	size := atomic.LoadUint64(&d.atomic.logSize)
+	for i, n := 0, len(d.mu.mem.queue)-1; i < n; i++ {
+		size += d.mu.mem.queue[i].logSize
+	}
+	return size
+}
+
+// walSizeExceededLocked returns true if the unflushed WAL has reached
+// Options.MaxWALSize and a flush should be forced. No flush is forced while a
+// flush is in progress or an immutable memtable is already due to be flushed,
+// as that flush will shrink the unflushed WAL.
+//
+// d.mu must be held when calling this.
+func (d *DB) walSizeExceededLocked() bool {
+	if d.opts.MaxWALSize <= 0 || d.opts.DisableWAL || d.mu.compact.flushing {
+		return false
+	}
+	for i, n := 0, len(d.mu.mem.queue)-1; i < n; i++ {
+		if d.mu.mem.queue[i].flushForced {
+			return false
+		}
 	}
+	return d.unflushedWALSizeLocked() >= uint64(d.opts.MaxWALSize)
 }
 
 func (d *DB) getEarliestUnflushedSeqNumLocked() uint64 {
@@ -6,6 +6,7 @@ package pebble
 
 import (
 	"bytes"
+	"encoding/binary"
 	"fmt"
 	"io"
 	"path/filepath"
@@ -18,7 +19,9 @@ import (
 	"time"
 
 	"github.com/cockroachdb/errors"
+	"github.com/cockroachdb/pebble/bloom"
 	"github.com/cockroachdb/pebble/internal/base"
+	"github.com/cockroachdb/pebble/internal/testkeys"
 	"github.com/cockroachdb/pebble/sstable"
 	"github.com/cockroachdb/pebble/vfs"
 	"github.com/stretchr/testify/require"
@@ -480,6 +483,389 @@ func TestGetMerge(t *testing.T) {
 	require.NoError(t, d.Close())
 }
 
+func TestGetMergeOperands(t *testing.T) {
+	d, err := Open("", testingRandomized(&Options{
+		FS: vfs.NewMem(),
+	}))
+	require.NoError(t, err)
+	defer func() { require.NoError(t, d.Close()) }()
+
+	verify := func(r interface {
+		GetMergeOperands([]byte) ([][]byte, error)
+	}, key string, expected ...string) {
+		t.Helper()
+		operands, err := r.GetMergeOperands([]byte(key))
+		if len(expected) == 0 {
+			require.ErrorIs(t, err, ErrNotFound)
+			return
+		}
+		require.NoError(t, err)
+		var actual []string
+		for _, o := range operands {
+			actual = append(actual, string(o))
+		}
+		require.Equal(t, expected, actual)
+	}
+
+	verify(d, "a")
+	require.NoError(t, d.Merge([]byte("a"), []byte("1"), nil))
+	require.NoError(t, d.Merge([]byte("a"), []byte("2"), nil))
+	verify(d, "a", "1", "2")
+
+	// Operands are collected across the memtable and sstables, and stop at
+	// the most recent Set, whose value is the base. Operands written to the
+	// same sstable by a flush are merged by the flush.
+	require.NoError(t, d.Flush())
+	require.NoError(t, d.Set([]byte("a"), []byte("base"), nil))
+	require.NoError(t, d.Merge([]byte("a"), []byte("3"), nil))
+	require.NoError(t, d.Flush())
+	require.NoError(t, d.Merge([]byte("a"), []byte("4"), nil))
+	verify(d, "a", "base3", "4")
+
+	// Snapshots see the operands visible at the snapshot.
+	s := d.NewSnapshot()
+	defer func() { require.NoError(t, s.Close()) }()
+	require.NoError(t, d.Delete([]byte("a"), nil))
+	require.NoError(t, d.Merge([]byte("a"), []byte("5"), nil))
+	verify(d, "a", "5")
+	verify(s, "a", "base3", "4")
+
+	// Range deletions hide the operands they cover.
+	require.NoError(t, d.Merge([]byte("b"), []byte("1"), nil))
+	require.NoError(t, d.Flush())
+	require.NoError(t, d.DeleteRange([]byte("a"), []byte("c"), nil))
+	require.NoError(t, d.Merge([]byte("b"), []byte("2"), nil))
+	verify(d, "a")
+	verify(d, "b", "2")
+	verify(s, "a", "base3", "4")
+}
+
+// TestEmptyValue verifies that a key set to an empty value is present, with
+// an empty value, wherever it resides, while a deleted key is absent.
+func TestEmptyValue(t *testing.T) {
+	mem := vfs.NewMem()
+	d, err := Open("", testingRandomized(&Options{FS: mem}))
+	require.NoError(t, err)
+	defer func() { require.NoError(t, d.Close()) }()
+
+	// check verifies that the keys in present are present with the value
+	// "" or, for merged keys, the given value, and that the keys in absent
+	// are absent, through Get, and through iteration and seeks.
+	check := func(r Reader, present map[string]string, absent ...string) {
+		t.Helper()
+		for k, want := range present {
+			v, closer, err := r.Get([]byte(k))
+			require.NoError(t, err, "%s", k)
+			require.Equal(t, want, string(v), "%s", k)
+			require.NoError(t, closer.Close())
+		}
+		for _, k := range absent {
+			_, _, err := r.Get([]byte(k))
+			require.ErrorIs(t, err, ErrNotFound, "%s", k)
+		}
+
+		iter := r.NewIter(nil)
+		got := map[string]string{}
+		for valid := iter.First(); valid; valid = iter.Next() {
+			got[string(iter.Key())] = string(iter.Value())
+		}
+		require.Equal(t, present, got)
+		for k, want := range present {
+			require.True(t, iter.SeekGE([]byte(k)), "%s", k)
+			require.Equal(t, k, string(iter.Key()))
+			require.Equal(t, want, string(iter.Value()))
+		}
+		for _, k := range absent {
+			require.False(t, iter.SeekGE([]byte(k)) && string(iter.Key()) == k, "%s", k)
+		}
+		require.NoError(t, iter.Close())
+	}
+
+	// Empty values written by Set, and Merge operands, are distinct from
+	// deletions, whether they're in a batch, the memtable or an sstable.
+	// The default merger concatenates operands, so merging an empty operand
+	// onto a deleted key produces a present key with an empty value.
+	b := d.NewIndexedBatch()
+	require.NoError(t, b.Set([]byte("a"), []byte{}, nil))
+	require.NoError(t, b.Set([]byte("b"), nil, nil))
+	require.NoError(t, b.Set([]byte("c"), []byte("c"), nil))
+	require.NoError(t, b.Delete([]byte("c"), nil))
+	require.NoError(t, b.Set([]byte("d"), []byte{}, nil))
+	require.NoError(t, b.Delete([]byte("d"), nil))
+	require.NoError(t, b.Delete([]byte("e"), nil))
+	require.NoError(t, b.Merge([]byte("e"), []byte{}, nil))
+	require.NoError(t, b.Set([]byte("f"), []byte{}, nil))
+	require.NoError(t, b.Merge([]byte("f"), []byte("1"), nil))
+	present := map[string]string{"a": "", "b": "", "e": "", "f": "1"}
+	absent := []string{"c", "d"}
+	check(b, present, absent...)
+	require.NoError(t, d.Apply(b, nil))
+	check(d, present, absent...)
+
+	snap := d.NewSnapshot()
+	defer func() { require.NoError(t, snap.Close()) }()
+	require.NoError(t, d.Flush())
+	check(d, present, absent...)
+	require.NoError(t, d.Compact([]byte("a"), []byte("z"), false /* parallelize */))
+	check(d, present, absent...)
+	check(snap, present, absent...)
+	operands, err := d.GetMergeOperands([]byte("e"))
+	require.NoError(t, err)
+	require.Len(t, operands, 1)
+	require.Empty(t, operands[0])
+
+	// Deleting an empty value makes the key absent, and setting a deleted key
+	// to an empty value makes it present again.
+	require.NoError(t, d.Delete([]byte("a"), nil))
+	require.NoError(t, d.Set([]byte("c"), []byte{}, nil))
+	present = map[string]string{"b": "", "c": "", "e": "", "f": "1"}
+	absent = []string{"a", "d"}
+	check(d, present, absent...)
+	require.NoError(t, d.Flush())
+	check(d, present, absent...)
+
+	// Empty values in ingested sstables are present too.
+	f, err := mem.Create("ext")
+	require.NoError(t, err)
+	w := sstable.NewWriter(f, sstable.WriterOptions{
+		TableFormat: d.FormatMajorVersion().MaxTableFormat(),
+	})
+	require.NoError(t, w.Set([]byte("g"), nil))
+	require.NoError(t, w.Close())
+	require.NoError(t, d.Ingest([]string{"ext"}))
+	present["g"] = ""
+	check(d, present, absent...)
+}
+
+func TestEmptyKey(t *testing.T) {
+	mem := vfs.NewMem()
+	d, err := Open("", testingRandomized(&Options{
+		FS:                 mem,
+		Comparer:           testkeys.Comparer,
+		FormatMajorVersion: FormatNewest,
+	}))
+	require.NoError(t, err)
+	defer func() { require.NoError(t, d.Close()) }()
+
+	empty := []byte{}
+	// check verifies that the empty key is present with the value v, or
+	// absent if v is nil, and that it sorts before every other key through
+	// Get, and through iteration, seeks and bounds.
+	check := func(r Reader, v []byte) {
+		t.Helper()
+		got, closer, err := r.Get(empty)
+		if v == nil {
+			require.ErrorIs(t, err, ErrNotFound)
+		} else {
+			require.NoError(t, err)
+			require.Equal(t, v, got)
+			require.NoError(t, closer.Close())
+		}
+		got, closer, err = r.Get(nil)
+		if v == nil {
+			require.ErrorIs(t, err, ErrNotFound)
+		} else {
+			require.NoError(t, err)
+			require.Equal(t, v, got)
+			require.NoError(t, closer.Close())
+		}
+
+		iter := r.NewIter(nil)
+		require.Equal(t, v != nil, iter.First() && len(iter.Key()) == 0)
+		require.Equal(t, v != nil, iter.SeekGE(empty) && len(iter.Key()) == 0)
+		require.Equal(t, v != nil, iter.SeekPrefixGE(empty) && len(iter.Key()) == 0)
+		require.Equal(t, v != nil, iter.SeekLT([]byte("a")) && len(iter.Key()) == 0)
+		require.False(t, iter.SeekLT(empty))
+		if v != nil {
+			// Reverse iteration ends at the empty key.
+			n := 1
+			for valid := iter.Last(); valid && len(iter.Key()) > 0; valid = iter.Prev() {
+				n++
+			}
+			require.Equal(t, 2, n)
+			require.False(t, iter.Prev())
+			require.True(t, iter.First())
+			require.Equal(t, v, iter.Value())
+			require.True(t, iter.Next())
+			require.Equal(t, "a", string(iter.Key()))
+			require.True(t, iter.Prev())
+			require.Empty(t, iter.Key())
+		}
+		require.NoError(t, iter.Close())
+
+		// An empty lower bound is equivalent to no lower bound, while an
+		// empty upper bound excludes every key.
+		iter = r.NewIter(&IterOptions{LowerBound: empty, UpperBound: []byte("a")})
+		require.Equal(t, v != nil, iter.First())
+		require.Equal(t, v != nil, iter.Last())
+		require.NoError(t, iter.Close())
+		iter = r.NewIter(&IterOptions{UpperBound: empty})
+		require.False(t, iter.First())
+		require.False(t, iter.Last())
+		require.False(t, iter.SeekGE(empty))
+		require.False(t, iter.SeekLT([]byte("a")))
+		iter.SetBounds(empty, []byte("b"))
+		require.Equal(t, v != nil, iter.First() && len(iter.Key()) == 0)
+		require.NoError(t, iter.Close())
+	}
+
+	// The empty key is written to a batch, the memtable and sstables.
+	b := d.NewIndexedBatch()
+	require.NoError(t, b.Set(empty, []byte("1"), nil))
+	require.NoError(t, b.Set([]byte("a"), []byte("a"), nil))
+	check(b, []byte("1"))
+	require.NoError(t, d.Apply(b, nil))
+	check(d, []byte("1"))
+	require.NoError(t, d.Flush())
+	check(d, []byte("1"))
+	require.NoError(t, d.Compact(empty, []byte("b"), false /* parallelize */))
+	check(d, []byte("1"))
+
+	// A nil key is the same key as the empty key.
+	require.NoError(t, d.Set(nil, []byte("2"), nil))
+	check(d, []byte("2"))
+	require.NoError(t, d.Delete(empty, nil))
+	check(d, nil)
+	require.NoError(t, d.Set(empty, []byte("3"), nil))
+	check(d, []byte("3"))
+	require.NoError(t, d.Flush())
+	check(d, []byte("3"))
+
+	// A range deletion starting at the empty key deletes it, while an empty
+	// range deletes nothing.
+	require.NoError(t, d.DeleteRange(empty, empty, nil))
+	check(d, []byte("3"))
+	require.NoError(t, d.DeleteRange(empty, []byte("a"), nil))
+	check(d, nil)
+	require.NoError(t, d.Flush())
+	check(d, nil)
+	require.NoError(t, d.Compact(empty, []byte("b"), false /* parallelize */))
+	check(d, nil)
+
+	// A range key starting at the empty key covers it.
+	require.NoError(t, d.Set(empty, []byte("4"), nil))
+	require.NoError(t, d.RangeKeySet(empty, []byte("a"), nil, []byte("r"), nil))
+	for i := 0; i < 2; i++ {
+		iter := d.NewIter(&IterOptions{KeyTypes: IterKeyTypePointsAndRanges})
+		require.True(t, iter.First())
+		require.Empty(t, iter.Key())
+		hasPoint, hasRange := iter.HasPointAndRange()
+		require.True(t, hasPoint)
+		require.True(t, hasRange)
+		start, end := iter.RangeBounds()
+		require.Empty(t, start)
+		require.Equal(t, "a", string(end))
+		require.NoError(t, iter.Close())
+		require.NoError(t, d.Flush())
+	}
+	check(d, []byte("4"))
+
+	// The empty key can be ingested.
+	f, err := mem.Create("ext")
+	require.NoError(t, err)
+	w := sstable.NewWriter(f, sstable.WriterOptions{
+		Comparer:    testkeys.Comparer,
+		TableFormat: d.FormatMajorVersion().MaxTableFormat(),
+	})
+	require.NoError(t, w.Set(empty, []byte("5")))
+	require.NoError(t, w.Close())
+	require.NoError(t, d.Ingest([]string{"ext"}))
+	check(d, []byte("5"))
+}
+
+func TestGetLatestVersion(t *testing.T) {
+	d, err := Open("", testingRandomized(&Options{
+		Comparer: testkeys.Comparer,
+		FS:       vfs.NewMem(),
+	}))
+	require.NoError(t, err)
+	defer func() { require.NoError(t, d.Close()) }()
+
+	verify := func(r interface {
+		GetLatestVersion([]byte) ([]byte, []byte, io.Closer, error)
+	}, prefix, expected string) {
+		t.Helper()
+		key, value, closer, err := r.GetLatestVersion([]byte(prefix))
+		if expected == "" {
+			require.ErrorIs(t, err, ErrNotFound)
+			return
+		}
+		require.NoError(t, err)
+		require.Equal(t, expected, fmt.Sprintf("%s:%s", key, value))
+		require.NoError(t, closer.Close())
+	}
+
+	verify(d, "a", "")
+	require.NoError(t, d.Set([]byte("a@2"), []byte("2"), nil))
+	require.NoError(t, d.Set([]byte("ab@9"), []byte("9"), nil))
+	require.NoError(t, d.Flush())
+	verify(d, "a", "a@2:2")
+	verify(d, "ab", "ab@9:9")
+	verify(d, "b", "")
+
+	// Versions in the memtable and in sstables are merged.
+	require.NoError(t, d.Set([]byte("a@1"), []byte("1"), nil))
+	require.NoError(t, d.Set([]byte("a@3"), []byte("3"), nil))
+	verify(d, "a", "a@3:3")
+
+	// Snapshots see the newest version visible at the snapshot.
+	s := d.NewSnapshot()
+	require.NoError(t, d.Set([]byte("a@4"), []byte("4"), nil))
+	require.NoError(t, d.Delete([]byte("ab@9"), nil))
+	verify(d, "a", "a@4:4")
+	verify(d, "ab", "")
+	verify(s, "a", "a@3:3")
+	verify(s, "ab", "ab@9:9")
+	require.NoError(t, s.Close())
+
+	// Deleted versions are skipped.
+	require.NoError(t, d.Delete([]byte("a@4"), nil))
+	require.NoError(t, d.Compact([]byte("a"), []byte("b"), false /* parallelize */))
+	verify(d, "a", "a@3:3")
+
+	// The key must be a complete prefix.
+	_, _, _, err = d.GetLatestVersion([]byte("a@3"))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "is not a prefix")
+}
+
+func TestWriteWithSeq(t *testing.T) {
+	d, err := Open("", &Options{FS: vfs.NewMem()})
+	require.NoError(t, err)
+	defer func() { require.NoError(t, d.Close()) }()
+
+	var seqNums []uint64
+	record := func(seqNum uint64, err error) {
+		require.NoError(t, err)
+		seqNums = append(seqNums, seqNum)
+	}
+	record(d.SetWithSeq([]byte("a"), []byte("1"), nil))
+	require.NoError(t, d.Set([]byte("z"), []byte("z"), nil))
+	record(d.MergeWithSeq([]byte("b"), []byte("2"), nil))
+	record(d.DeleteWithSeq([]byte("a"), nil))
+	require.Equal(t, []uint64{1, 3, 4}, seqNums)
+
+	// The returned sequence numbers are those stored in the LSM.
+	iter := d.NewInternalIter(nil)
+	var keys []string
+	for valid := iter.First(); valid; valid = iter.Next() {
+		keys = append(keys, fmt.Sprint(iter.Key().Pretty(DefaultComparer.FormatKey)))
+	}
+	require.NoError(t, iter.Close())
+	require.Equal(t, []string{"a#4,DEL", "a#1,SET", "b#3,MERGE", "z#2,SET"}, keys)
+
+	// A snapshot taken after the write observes it.
+	seqNum, err := d.SetWithSeq([]byte("c"), []byte("3"), nil)
+	require.NoError(t, err)
+	s := d.NewSnapshot()
+	defer func() { require.NoError(t, s.Close()) }()
+	require.Equal(t, seqNum+1, s.seqNum)
+	v, closer, err := s.Get([]byte("c"))
+	require.NoError(t, err)
+	require.Equal(t, "3", string(v))
+	require.NoError(t, closer.Close())
+}
+
 func TestMergeOrderSameAfterFlush(t *testing.T) {
 	// Ensure compaction iterator (used by flush) and user iterator process merge
 	// operands in the same order
@@ -861,6 +1247,205 @@ func TestMemTableReservationLeak(t *testing.T) {
 	}
 }
 
+func TestAdaptiveMemTableSize(t *testing.T) {
+	opts := &Options{
+		FS:           vfs.NewMem(),
+		MemTableSize: 4 << 20,
+	}
+	opts.Experimental.AdaptiveMemTableSize = AdaptiveMemTableSizeOptions{
+		Enabled:             true,
+		TargetFlushInterval: time.Second,
+	}
+	d, err := Open("", opts)
+	require.NoError(t, err)
+	defer func() { require.NoError(t, d.Close()) }()
+
+	var now time.Time
+	d.mu.Lock()
+	d.timeNow = func() time.Time { return now }
+	d.mu.Unlock()
+
+	// write writes keys until the mutable memtable is rotated, advancing the
+	// clock by tick after each write, and returns the new memtable size.
+	var key int
+	value := bytes.Repeat([]byte("v"), 1<<10)
+	mutable := func() *memTable {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		return d.mu.mem.mutable
+	}
+	write := func(tick time.Duration) uint64 {
+		for mem := mutable(); mutable() == mem; {
+			key++
+			require.NoError(t, d.Set([]byte(strconv.Itoa(key)), value, nil))
+			now = now.Add(tick)
+		}
+		return d.Metrics().MemTable.MutableSize
+	}
+
+	// Memtables start at MinSize, and grow by at most a factor of two under
+	// a high write rate.
+	require.Equal(t, uint64(256<<10), d.Metrics().MemTable.MutableSize)
+	for _, expected := range []uint64{512 << 10, 1 << 20, 2 << 20, 4 << 20} {
+		require.Equal(t, expected, write(0))
+	}
+	// MaxSize defaults to MemTableSize.
+	require.Equal(t, uint64(4<<20), write(0))
+
+	// Under a low write rate, they shrink by at most a factor of two down to
+	// MinSize.
+	for _, expected := range []uint64{2 << 20, 1 << 20, 512 << 10, 256 << 10, 256 << 10} {
+		require.Equal(t, expected, write(100*time.Millisecond))
+	}
+
+	// Writing roughly 1 MB a second targets a memtable of roughly 1 MB.
+	require.Equal(t, uint64(512<<10), write(time.Millisecond))
+	require.InEpsilon(t, 1<<20, write(time.Millisecond), 0.1)
+	require.InEpsilon(t, 1<<20, write(time.Millisecond), 0.1)
+}
+
+func TestMaxWALSize(t *testing.T) {
+	const maxWALSize = 64 << 10
+	d, err := Open("", &Options{
+		FS:           vfs.NewMem(),
+		MemTableSize: 4 << 20,
+		MaxWALSize:   maxWALSize,
+	})
+	require.NoError(t, err)
+	defer func() { require.NoError(t, d.Close()) }()
+
+	// set writes a key and waits for any flush it triggered to complete.
+	var key int
+	set := func(value []byte) {
+		key++
+		require.NoError(t, d.Set([]byte(strconv.Itoa(key)), value, nil))
+		d.mu.Lock()
+		for d.mu.compact.flushing {
+			d.mu.compact.cond.Wait()
+		}
+		d.mu.Unlock()
+	}
+
+	// Flushes are forced well before the memtable is full, and the unflushed
+	// WAL exceeds the limit by at most a single batch.
+	value := bytes.Repeat([]byte("v"), 1<<10)
+	for i := 0; i < 256; i++ {
+		set(value)
+		require.Less(t, d.Metrics().WAL.Size, uint64(maxWALSize+2<<10))
+	}
+	m := d.Metrics()
+	require.GreaterOrEqual(t, m.Flush.Count, int64(3))
+	require.Less(t, m.MemTable.Size, uint64(4<<20))
+
+	// A batch larger than the limit is written in its entirety, and is flushed
+	// by the next write.
+	flushes := d.Metrics().Flush.Count
+	set(bytes.Repeat([]byte("v"), 2*maxWALSize))
+	require.Greater(t, d.Metrics().WAL.Size, uint64(2*maxWALSize))
+	set(value)
+	require.Less(t, d.Metrics().WAL.Size, uint64(4<<10))
+	require.Less(t, flushes, d.Metrics().Flush.Count)
+}
+
+func TestEstimateCompression(t *testing.T) {
+	d, err := Open("", &Options{FS: vfs.NewMem()})
+	require.NoError(t, err)
+	defer func() { require.NoError(t, d.Close()) }()
+
+	// The data in memtables is not sampled.
+	value := bytes.Repeat([]byte("compressible "), 100)
+	for i := 0; i < 1000; i++ {
+		require.NoError(t, d.Set([]byte(fmt.Sprintf("%04d", i)), value, nil))
+	}
+	ratio, err := d.EstimateCompressionRatio(nil, nil)
+	require.NoError(t, err)
+	require.Zero(t, ratio)
+
+	require.NoError(t, d.Flush())
+	ratio, err = d.EstimateCompressionRatio(nil, nil)
+	require.NoError(t, err)
+	require.Greater(t, ratio, 0.0)
+	require.Less(t, ratio, 0.5)
+
+	stats, err := d.EstimateCompression([]byte("0100"), []byte("0200"))
+	require.NoError(t, err)
+	require.Equal(t, estimateCompressionBlocksPerFile, stats.Blocks)
+	require.Less(t, stats.CodecRatio(SnappyCompression), 0.5)
+	require.Less(t, stats.CodecRatio(ZstdCompression), 0.5)
+
+	stats, err = d.EstimateCompression([]byte("a"), []byte("b"))
+	require.NoError(t, err)
+	require.Zero(t, stats.Blocks)
+
+	_, err = d.EstimateCompression([]byte("b"), []byte("a"))
+	require.Error(t, err)
+}
+
+func TestSplitRange(t *testing.T) {
+	d, err := Open("", &Options{FS: vfs.NewMem()})
+	require.NoError(t, err)
+	defer func() { require.NoError(t, d.Close()) }()
+
+	// countKeys returns the number of keys within the key range.
+	countKeys := func(kr KeyRange) int {
+		t.Helper()
+		iter := d.NewIter(&IterOptions{LowerBound: kr.Start, UpperBound: kr.End})
+		var n int
+		for valid := iter.First(); valid; valid = iter.Next() {
+			n++
+		}
+		require.NoError(t, iter.Close())
+		return n
+	}
+
+	// The data in memtables is not considered.
+	value := bytes.Repeat([]byte("v"), 100)
+	for i := 0; i < 1000; i++ {
+		require.NoError(t, d.Set([]byte(fmt.Sprintf("%04d", i)), value, nil))
+	}
+	ranges, err := d.SplitRange([]byte("0000"), []byte("1000"), 4)
+	require.NoError(t, err)
+	require.Equal(t, []KeyRange{{Start: []byte("0000"), End: []byte("1000")}}, ranges)
+
+	require.NoError(t, d.Flush())
+	ranges, err = d.SplitRange([]byte("0000"), []byte("1000"), 4)
+	require.NoError(t, err)
+	require.Len(t, ranges, 4)
+	require.Equal(t, []byte("0000"), ranges[0].Start)
+	require.Equal(t, []byte("1000"), ranges[3].End)
+	for i, kr := range ranges {
+		if i > 0 {
+			// The split points are contiguous, and are keys within the DB.
+			require.Equal(t, ranges[i-1].End, kr.Start)
+			_, closer, err := d.Get(kr.Start)
+			require.NoError(t, err)
+			require.NoError(t, closer.Close())
+		}
+		n := countKeys(kr)
+		require.Greater(t, n, 200, "%s-%s", kr.Start, kr.End)
+		require.Less(t, n, 300, "%s-%s", kr.Start, kr.End)
+	}
+
+	// A subrange is split by the data within it.
+	ranges, err = d.SplitRange([]byte("0500"), []byte("0600"), 2)
+	require.NoError(t, err)
+	require.Len(t, ranges, 2)
+	require.Equal(t, []byte("0500"), ranges[0].Start)
+	require.Equal(t, []byte("0600"), ranges[1].End)
+	require.Less(t, countKeys(ranges[0]), 80)
+	require.Less(t, countKeys(ranges[1]), 80)
+
+	// A range without data is not split.
+	ranges, err = d.SplitRange([]byte("a"), []byte("b"), 4)
+	require.NoError(t, err)
+	require.Equal(t, []KeyRange{{Start: []byte("a"), End: []byte("b")}}, ranges)
+
+	_, err = d.SplitRange([]byte("b"), []byte("a"), 4)
+	require.Error(t, err)
+	_, err = d.SplitRange([]byte("a"), []byte("b"), 0)
+	require.Error(t, err)
+}
+
 func TestCacheEvict(t *testing.T) {
 	cache := NewCache(10 << 20)
 	defer cache.Unref()
@@ -911,6 +1496,95 @@ func TestFlushEmpty(t *testing.T) {
 	require.NoError(t, d.Close())
 }
 
+func TestWriteThrottle(t *testing.T) {
+	d, err := Open("", testingRandomized(&Options{
+		FS:                          vfs.NewMem(),
+		DisableAutomaticCompactions: true,
+		L0SublevelReadAmpThreshold:  2,
+	}))
+	require.NoError(t, err)
+
+	// Writes are not throttled while L0 read-amplification is below the
+	// threshold.
+	for i := 0; i < 2; i++ {
+		require.NoError(t, d.Set([]byte("a"), []byte("b"), nil))
+		m := d.Metrics()
+		require.False(t, m.WriteThrottle.Active)
+		require.EqualValues(t, 0, m.WriteThrottle.Count)
+		require.NoError(t, d.Flush())
+	}
+
+	// The two overlapping flushes produced two L0 sublevels, so subsequent
+	// writes are delayed.
+	m := d.Metrics()
+	require.EqualValues(t, 2, m.Levels[0].Sublevels)
+	require.True(t, m.WriteThrottle.Active)
+	require.NoError(t, d.Set([]byte("a"), []byte("b"), nil))
+	m = d.Metrics()
+	require.EqualValues(t, 1, m.WriteThrottle.Count)
+	require.Equal(t, d.writeThrottleDelay(2), m.WriteThrottle.Duration)
+
+	// Compacting L0 away stops the throttling.
+	require.NoError(t, d.Compact([]byte("a"), []byte("b"), false))
+	require.False(t, d.Metrics().WriteThrottle.Active)
+	require.NoError(t, d.Set([]byte("a"), []byte("b"), nil))
+	require.EqualValues(t, 1, d.Metrics().WriteThrottle.Count)
+	require.NoError(t, d.Close())
+}
+
+func TestMaxDiskUsageBytes(t *testing.T) {
+	mem := vfs.NewMem()
+	d, err := Open("", &Options{FS: mem})
+	require.NoError(t, err)
+	defer func() { require.NoError(t, d.Close()) }()
+	require.NoError(t, d.Set([]byte("a"), bytes.Repeat([]byte("a"), 1000), nil))
+	require.NoError(t, d.Flush())
+
+	// Leave 1000 bytes of headroom below the limit.
+	m := d.Metrics()
+	require.Zero(t, m.DiskUsage.Limit)
+	require.Equal(t, m.DiskSpaceUsage(), m.DiskUsage.Used)
+	d.opts.MaxDiskUsageBytes = int64(m.DiskUsage.Used) + 1000
+
+	// Writes that fit are applied, while those that don't are rejected.
+	require.NoError(t, d.Set([]byte("b"), []byte("b"), nil))
+	err = d.Set([]byte("c"), bytes.Repeat([]byte("c"), 5000), nil)
+	require.True(t, errors.Is(err, ErrDiskFull), "%v", err)
+	b := d.NewBatch()
+	require.NoError(t, b.Delete([]byte("a"), nil))
+	require.NoError(t, b.Set([]byte("c"), bytes.Repeat([]byte("c"), 5000), nil))
+	require.True(t, errors.Is(d.Apply(b, nil), ErrDiskFull))
+	require.NoError(t, b.Close())
+
+	// Deletions are always applied.
+	b = d.NewBatch()
+	require.NoError(t, b.Delete([]byte("a"), nil))
+	require.NoError(t, b.SingleDelete([]byte("b"), nil))
+	require.NoError(t, b.DeleteRange([]byte("c"), bytes.Repeat([]byte("z"), 5000), nil))
+	require.NoError(t, d.Apply(b, nil))
+	require.NoError(t, b.Close())
+	_, _, err = d.Get([]byte("a"))
+	require.True(t, errors.Is(err, ErrNotFound))
+
+	// Ingestions that don't fit are rejected.
+	f, err := mem.Create("ext")
+	require.NoError(t, err)
+	w := sstable.NewWriter(f, sstable.WriterOptions{})
+	require.NoError(t, w.Set([]byte("d"), bytes.Repeat([]byte("d"), 5000)))
+	require.NoError(t, w.Close())
+	err = d.Ingest([]string{"ext"})
+	require.True(t, errors.Is(err, ErrDiskFull), "%v", err)
+
+	m = d.Metrics()
+	require.Equal(t, uint64(d.opts.MaxDiskUsageBytes), m.DiskUsage.Limit)
+	require.EqualValues(t, 3, m.DiskUsage.RejectedWrites)
+
+	// Raising the limit allows the writes to proceed.
+	d.opts.MaxDiskUsageBytes += 10000
+	require.NoError(t, d.Set([]byte("c"), bytes.Repeat([]byte("c"), 5000), nil))
+	require.NoError(t, d.Ingest([]string{"ext"}))
+}
+
 func TestRollManifest(t *testing.T) {
 	toPreserve := rand.Int31n(5) + 1
 	opts := &Options{
@@ -1209,6 +1883,139 @@ func TestSSTables(t *testing.T) {
 	}
 }
 
+func TestSSTablesReadCount(t *testing.T) {
+	d, err := Open("", &Options{FS: vfs.NewMem()})
+	require.NoError(t, err)
+	defer func() { require.NoError(t, d.Close()) }()
+
+	require.NoError(t, d.Set([]byte("hello"), nil, nil))
+	require.NoError(t, d.Flush())
+	require.NoError(t, d.Set([]byte("world"), nil, nil))
+	require.NoError(t, d.Flush())
+
+	readCounts := func() string {
+		tableInfos, err := d.SSTables()
+		require.NoError(t, err)
+		var buf strings.Builder
+		for level, levelTables := range tableInfos {
+			for _, info := range levelTables {
+				fmt.Fprintf(&buf, "L%d:%s:%d ", level, info.FileNum, info.ReadCount)
+			}
+		}
+		return buf.String()
+	}
+	require.Equal(t, "L0:000005:0 L0:000007:0 ", readCounts())
+
+	// A Get only reads the table containing its key, while an iterator
+	// reads every table it steps through.
+	_, closer, err := d.Get([]byte("hello"))
+	require.NoError(t, err)
+	require.NoError(t, closer.Close())
+	require.Equal(t, "L0:000005:1 L0:000007:0 ", readCounts())
+	iter := d.NewIter(nil)
+	for valid := iter.First(); valid; valid = iter.Next() {
+	}
+	require.NoError(t, iter.Close())
+	require.Equal(t, "L0:000005:2 L0:000007:1 ", readCounts())
+
+	// Reads by compactions aren't counted.
+	require.NoError(t, d.Compact([]byte("a"), []byte("z"), false))
+	require.Equal(t, "L6:000008:0 ", readCounts())
+}
+
+func TestSSTablesKeyRangeAndTimeRange(t *testing.T) {
+	d, err := Open("", &Options{
+		FS:                 vfs.NewMem(),
+		Comparer:           testkeys.Comparer,
+		FormatMajorVersion: FormatNewest,
+		BlockPropertyCollectors: []func() BlockPropertyCollector{
+			func() BlockPropertyCollector {
+				return sstable.NewTimeRangePropertyCollector(testkeys.Comparer.Split,
+					func(suffix []byte) (uint64, error) {
+						t, err := testkeys.ParseSuffix(suffix)
+						return uint64(t), err
+					})
+			},
+		},
+	})
+	require.NoError(t, err)
+	defer func() { require.NoError(t, d.Close()) }()
+
+	// Create three sstables with disjoint key ranges.
+	require.NoError(t, d.Set([]byte("a@5"), nil, nil))
+	require.NoError(t, d.Set([]byte("b@2"), nil, nil))
+	require.NoError(t, d.Flush())
+	require.NoError(t, d.Set([]byte("m@9"), nil, nil))
+	require.NoError(t, d.Flush())
+	require.NoError(t, d.Set([]byte("x"), nil, nil))
+	require.NoError(t, d.Flush())
+
+	collect := func(opts ...SSTablesOption) []SSTableInfo {
+		tableInfos, err := d.SSTables(opts...)
+		require.NoError(t, err)
+		var tables []SSTableInfo
+		for _, levelTables := range tableInfos {
+			tables = append(tables, levelTables...)
+		}
+		return tables
+	}
+	require.Len(t, collect(), 3)
+	require.Len(t, collect(WithKeyRange([]byte("a"), []byte("n"))), 2)
+	require.Len(t, collect(WithKeyRange([]byte("c"), []byte("m"))), 0)
+
+	// The time range is only populated when properties are requested.
+	tables := collect(WithKeyRange([]byte("a"), []byte("c")))
+	require.Len(t, tables, 1)
+	require.Nil(t, tables[0].TimeRange)
+	tables = collect(WithKeyRange([]byte("a"), []byte("c")), WithProperties())
+	require.Len(t, tables, 1)
+	require.Equal(t, &sstable.TimeRange{Min: 2, Max: 5}, tables[0].TimeRange)
+
+	// A table without any timestamped keys has no time range.
+	tables = collect(WithKeyRange([]byte("x"), []byte("y")), WithProperties())
+	require.Len(t, tables, 1)
+	require.Nil(t, tables[0].TimeRange)
+
+	// SSTablesInRange is equivalent to SSTables with WithKeyRange.
+	tableInfos, err := d.SSTablesInRange([]byte("a"), []byte("c"), WithProperties())
+	require.NoError(t, err)
+	require.Len(t, tableInfos[0], 1)
+	require.Equal(t, &sstable.TimeRange{Min: 2, Max: 5}, tableInfos[0][0].TimeRange)
+}
+
+func TestSSTablesKeyHistogram(t *testing.T) {
+	d, err := Open("", &Options{
+		FS: vfs.NewMem(),
+		TablePropertyCollectors: []func() TablePropertyCollector{
+			func() TablePropertyCollector {
+				return sstable.NewKeyHistogramPropertyCollector(4, 0)
+			},
+		},
+	})
+	require.NoError(t, err)
+	defer func() { require.NoError(t, d.Close()) }()
+
+	for i := 0; i < 100; i++ {
+		require.NoError(t, d.Set([]byte(fmt.Sprintf("a%02d", i)), nil, nil))
+	}
+	require.NoError(t, d.Flush())
+	require.NoError(t, d.Set([]byte("b"), nil, nil))
+	require.NoError(t, d.Flush())
+
+	tableInfos, err := d.SSTables(WithKeyRange([]byte("a"), []byte("a99")), WithProperties())
+	require.NoError(t, err)
+	var tables []SSTableInfo
+	for _, levelTables := range tableInfos {
+		tables = append(tables, levelTables...)
+	}
+	require.Len(t, tables, 1)
+	h := tables[0].KeyHistogram
+	require.NotNil(t, h)
+	require.Equal(t, uint64(100), h.Count)
+	require.Len(t, h.Boundaries, 5)
+	require.Equal(t, uint64(100), h.EstimateCount(d.cmp, []byte("a"), []byte("b")))
+}
+
 func BenchmarkDelete(b *testing.B) {
 	rng := rand.New(rand.NewSource(uint64(time.Now().UnixNano())))
 	const keyCount = 10000
@@ -1264,6 +2071,39 @@ func BenchmarkDelete(b *testing.B) {
 	})
 }
 
+// BenchmarkConcurrentSet measures the throughput of concurrent writers
+// committing single-key batches. Run with -cpu to vary the number of writers.
+func BenchmarkConcurrentSet(b *testing.B) {
+	d, err := Open("", &Options{
+		FS:         vfs.NewMem(),
+		DisableWAL: true,
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer func() {
+		if err := d.Close(); err != nil {
+			b.Fatal(err)
+		}
+	}()
+
+	var seed uint64
+	val := bytes.Repeat([]byte("x"), 100)
+	b.SetBytes(int64(16 + len(val)))
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		rng := rand.New(rand.NewSource(atomic.AddUint64(&seed, 1)))
+		key := make([]byte, 16)
+		for pb.Next() {
+			binary.BigEndian.PutUint64(key[:8], rng.Uint64())
+			binary.BigEndian.PutUint64(key[8:], rng.Uint64())
+			if err := d.Set(key, val, NoSync); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
 func BenchmarkNewIterReadAmp(b *testing.B) {
 	for _, readAmp := range []int{10, 100, 1000} {
 		b.Run(strconv.Itoa(readAmp), func(b *testing.B) {
@@ -1312,3 +2152,550 @@ func verifyGetNotFound(t *testing.T, r Reader, key []byte) {
 		t.Fatalf("expected nil, but got %s", val)
 	}
 }
+
+func TestApproximateKeyCount(t *testing.T) {
+	d, err := Open("", &Options{FS: vfs.NewMem()})
+	require.NoError(t, err)
+	defer func() { require.NoError(t, d.Close()) }()
+
+	// Unflushed keys aren't counted.
+	for i := 0; i < 1000; i++ {
+		require.NoError(t, d.Set([]byte(fmt.Sprintf("%04d", i)), []byte("v"), nil))
+	}
+	require.Equal(t, uint64(0), d.ApproximateKeyCount())
+	require.NoError(t, d.Flush())
+	require.Equal(t, uint64(1000), d.ApproximateKeyCount())
+
+	// Each deletion is assumed to shadow a key, whether or not it has been
+	// compacted with it.
+	for i := 0; i < 1000; i += 10 {
+		require.NoError(t, d.Delete([]byte(fmt.Sprintf("%04d", i)), nil))
+	}
+	require.NoError(t, d.Flush())
+	require.Equal(t, uint64(900), d.ApproximateKeyCount())
+	require.NoError(t, d.Compact([]byte("0000"), []byte("1000"), false))
+	require.Equal(t, uint64(900), d.ApproximateKeyCount())
+}
+
+func TestDeleteIf(t *testing.T) {
+	d, err := Open("", &Options{FS: vfs.NewMem()})
+	require.NoError(t, err)
+	defer func() { require.NoError(t, d.Close()) }()
+
+	get := func(key string) string {
+		v, closer, err := d.Get([]byte(key))
+		if err == ErrNotFound {
+			return "<not found>"
+		}
+		require.NoError(t, err)
+		defer closer.Close()
+		return string(v)
+	}
+
+	deleted, err := d.DeleteIf([]byte("a"), nil, nil)
+	require.NoError(t, err)
+	require.False(t, deleted)
+
+	require.NoError(t, d.Set([]byte("a"), []byte("1"), nil))
+	deleted, err = d.DeleteIf([]byte("a"), []byte("2"), nil)
+	require.NoError(t, err)
+	require.False(t, deleted)
+	require.Equal(t, "1", get("a"))
+	deleted, err = d.DeleteIf([]byte("a"), []byte("1"), nil)
+	require.NoError(t, err)
+	require.True(t, deleted)
+	require.Equal(t, "<not found>", get("a"))
+
+	// An empty value is present, and matched by an empty expected value.
+	require.NoError(t, d.Set([]byte("b"), nil, nil))
+	require.NoError(t, d.Flush())
+	deleted, err = d.DeleteIf([]byte("b"), []byte{}, nil)
+	require.NoError(t, err)
+	require.True(t, deleted)
+	require.Equal(t, "<not found>", get("b"))
+
+	// Of concurrent DeleteIfs of the same key and value, only one deletes
+	// the key, while concurrent writes to other keys proceed.
+	for i := 0; i < 10; i++ {
+		require.NoError(t, d.Set([]byte("lock"), []byte("owner"), nil))
+		var wg sync.WaitGroup
+		var deletions int32
+		for j := 0; j < 8; j++ {
+			wg.Add(2)
+			go func(j int) {
+				defer wg.Done()
+				deleted, err := d.DeleteIf([]byte("lock"), []byte("owner"), nil)
+				require.NoError(t, err)
+				if deleted {
+					atomic.AddInt32(&deletions, 1)
+				}
+			}(j)
+			go func(j int) {
+				defer wg.Done()
+				require.NoError(t, d.Set([]byte(fmt.Sprintf("k%d", j)), nil, nil))
+			}(j)
+		}
+		wg.Wait()
+		require.Equal(t, int32(1), deletions)
+	}
+}
+
+func TestApplyConditional(t *testing.T) {
+	d, err := Open("", &Options{FS: vfs.NewMem()})
+	require.NoError(t, err)
+	defer func() { require.NoError(t, d.Close()) }()
+
+	get := func(key string) string {
+		v, closer, err := d.Get([]byte(key))
+		if err == ErrNotFound {
+			return "<not found>"
+		}
+		require.NoError(t, err)
+		defer closer.Close()
+		return string(v)
+	}
+
+	// The batch is applied only if every precondition holds.
+	require.NoError(t, d.Set([]byte("a"), []byte("1"), nil))
+	b := d.NewBatch()
+	require.NoError(t, b.Set([]byte("b"), []byte("2"), nil))
+	require.NoError(t, b.Delete([]byte("a"), nil))
+	committed, err := d.ApplyConditional(b, []Precondition{
+		{Key: []byte("a"), Value: []byte("1")},
+		{Key: []byte("b"), Value: []byte("2")},
+	}, nil)
+	require.NoError(t, err)
+	require.False(t, committed)
+	committed, err = d.ApplyConditional(b, []Precondition{
+		{Key: []byte("a"), Absent: true},
+	}, nil)
+	require.NoError(t, err)
+	require.False(t, committed)
+	require.Equal(t, "1", get("a"))
+	require.Equal(t, "<not found>", get("b"))
+
+	// The unapplied batch may be applied again.
+	committed, err = d.ApplyConditional(b, []Precondition{
+		{Key: []byte("a"), Value: []byte("1")},
+		{Key: []byte("b"), Absent: true},
+	}, nil)
+	require.NoError(t, err)
+	require.True(t, committed)
+	require.Equal(t, "<not found>", get("a"))
+	require.Equal(t, "2", get("b"))
+	require.NoError(t, b.Close())
+
+	// Concurrent compare-and-swaps of a pair of counters don't lose updates,
+	// including while unconditional writes are committed concurrently, whose
+	// publication the preconditions wait for.
+	require.NoError(t, d.Set([]byte("x"), []byte("0"), nil))
+	require.NoError(t, d.Set([]byte("y"), []byte("0"), nil))
+	const workers, increments = 8, 50
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	var writerWG sync.WaitGroup
+	writerWG.Add(1)
+	go func() {
+		defer writerWG.Done()
+		for n := 0; ; n++ {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			require.NoError(t, d.Set([]byte("z"), []byte(strconv.Itoa(n)), nil))
+		}
+	}()
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := 0; n < increments; {
+				x, y := get("x"), get("y")
+				v, err := strconv.Atoi(x)
+				require.NoError(t, err)
+				next := []byte(strconv.Itoa(v + 1))
+				b := d.NewBatch()
+				require.NoError(t, b.Set([]byte("x"), next, nil))
+				require.NoError(t, b.Set([]byte("y"), next, nil))
+				committed, err := d.ApplyConditional(b, []Precondition{
+					{Key: []byte("x"), Value: []byte(x)},
+					{Key: []byte("y"), Value: []byte(y)},
+				}, nil)
+				require.NoError(t, err)
+				require.NoError(t, b.Close())
+				if committed {
+					n++
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(done)
+	writerWG.Wait()
+	require.Equal(t, strconv.Itoa(workers*increments), get("x"))
+	require.Equal(t, strconv.Itoa(workers*increments), get("y"))
+}
+
+func TestComparerNormalize(t *testing.T) {
+	comparer := *DefaultComparer
+	comparer.Normalize = func(dst, key []byte) []byte {
+		for _, c := range key {
+			if 'A' <= c && c <= 'Z' {
+				c += 'a' - 'A'
+			}
+			dst = append(dst, c)
+		}
+		return dst
+	}
+	comparer.Split = func(a []byte) int { return len(a) }
+	comparer.Name = "case-insensitive"
+	opts := &Options{
+		Comparer: &comparer,
+		FS:       vfs.NewMem(),
+	}
+	opts.Levels = []LevelOptions{{FilterPolicy: bloom.FilterPolicy(10)}}
+	d, err := Open("", opts)
+	require.NoError(t, err)
+	defer func() { require.NoError(t, d.Close()) }()
+
+	require.NoError(t, d.Set([]byte("apple"), []byte("1"), nil))
+	require.NoError(t, d.Set([]byte("Banana"), []byte("2"), nil))
+	require.NoError(t, d.Set([]byte("cherry"), []byte("3"), nil))
+	// Keys with the same normalized form are the same key.
+	require.NoError(t, d.Set([]byte("APPLE"), []byte("4"), nil))
+
+	check := func() {
+		v, closer, err := d.Get([]byte("Apple"))
+		require.NoError(t, err)
+		require.Equal(t, "4", string(v))
+		require.NoError(t, closer.Close())
+
+		// Keys are returned with their original bytes, in normalized order,
+		// and bounds are compared with the normalized keys.
+		iter := d.NewIter(&IterOptions{LowerBound: []byte("APPLE"), UpperBound: []byte("CHERRY")})
+		var got []string
+		for valid := iter.First(); valid; valid = iter.Next() {
+			got = append(got, fmt.Sprintf("%s:%s", iter.Key(), iter.Value()))
+		}
+		require.Equal(t, []string{"APPLE:4", "Banana:2"}, got)
+
+		// Prefix iteration consults the bloom filters of the prefixes, which
+		// are normalized.
+		require.True(t, iter.SeekPrefixGE([]byte("BANANA")))
+		require.Equal(t, "Banana", string(iter.Key()))
+		require.NoError(t, iter.Close())
+	}
+	check()
+	require.NoError(t, d.Flush())
+	check()
+}
+
+func TestDeletePrefix(t *testing.T) {
+	d, err := Open("", &Options{
+		FS:                          vfs.NewMem(),
+		DisableAutomaticCompactions: true,
+	})
+	require.NoError(t, err)
+	defer func() { require.NoError(t, d.Close()) }()
+
+	keys := func() []string {
+		iter := d.NewIter(nil)
+		defer iter.Close()
+		var keys []string
+		for valid := iter.First(); valid; valid = iter.Next() {
+			keys = append(keys, string(iter.Key()))
+		}
+		return keys
+	}
+	for _, k := range []string{"a", "a\xff", "a\xffz", "b", "b\x00", "bb", "b\xff", "b\xff\xff", "c"} {
+		require.NoError(t, d.Set([]byte(k), nil, nil))
+	}
+
+	require.NoError(t, d.DeletePrefix([]byte("b"), nil))
+	require.Equal(t, []string{"a", "a\xff", "a\xffz", "c"}, keys())
+	// The successor of a prefix ending in 0xff bytes increments the last
+	// byte that is not 0xff.
+	require.NoError(t, d.DeletePrefix([]byte("a\xff"), nil))
+	require.Equal(t, []string{"a", "c"}, keys())
+
+	for _, prefix := range []string{"", "\xff", "\xff\xff"} {
+		require.Error(t, d.DeletePrefix([]byte(prefix), nil))
+	}
+	require.Equal(t, []string{"a", "c"}, keys())
+
+	// Write enough data under a prefix to warrant compacting it away.
+	for i := 0; i < 1000; i++ {
+		require.NoError(t, d.Set([]byte(fmt.Sprintf("p%04d", i)), make([]byte, 100), nil))
+	}
+	require.NoError(t, d.Flush())
+	size, err := d.EstimateDiskUsage([]byte("p"), []byte("q"))
+	require.NoError(t, err)
+	require.NotZero(t, size)
+
+	// The data under the prefix is below the compaction threshold.
+	require.NoError(t, d.DeletePrefix([]byte("p9"), &DeletePrefixOptions{CompactionThreshold: size}))
+	require.Equal(t, int64(0), d.Metrics().Compact.Count)
+
+	require.NoError(t, d.DeletePrefix([]byte("p"), &DeletePrefixOptions{CompactionThreshold: 1}))
+	require.NotZero(t, d.Metrics().Compact.Count)
+	size, err = d.EstimateDiskUsage([]byte("p"), []byte("q"))
+	require.NoError(t, err)
+	require.Zero(t, size)
+	require.Equal(t, []string{"a", "c"}, keys())
+}
+
+func TestGetAsOf(t *testing.T) {
+	d, err := Open("", &Options{FS: vfs.NewMem()})
+	require.NoError(t, err)
+	defer func() { require.NoError(t, d.Close()) }()
+
+	getAsOf := func(key string, seqNum uint64) string {
+		v, closer, err := d.GetAsOf([]byte(key), seqNum)
+		if err == ErrNotFound {
+			return "<not found>"
+		}
+		require.NoError(t, err)
+		defer closer.Close()
+		return string(v)
+	}
+
+	seq1, err := d.SetWithSeq([]byte("a"), []byte("1"), nil)
+	require.NoError(t, err)
+	seq2, err := d.SetWithSeq([]byte("a"), []byte("2"), nil)
+	require.NoError(t, err)
+	seq3, err := d.DeleteWithSeq([]byte("a"), nil)
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, seq1, d.HistoryFloorSeqNum())
+
+	// While the versions are in the memtable, every one is readable.
+	require.Equal(t, "<not found>", getAsOf("a", seq1-1))
+	require.Equal(t, "1", getAsOf("a", seq1))
+	require.Equal(t, "1", getAsOf("a", seq2-1))
+	require.Equal(t, "2", getAsOf("a", seq2))
+	require.Equal(t, "<not found>", getAsOf("a", seq3))
+
+	// A sequence number that hasn't been assigned can't be read.
+	_, _, err = d.GetAsOf([]byte("a"), seq3+1)
+	require.Error(t, err)
+
+	// A flush may discard shadowed versions, advancing the floor, except for
+	// those retained by a snapshot.
+	snap := d.NewSnapshot()
+	require.NoError(t, d.Flush())
+	require.Equal(t, seq3, d.HistoryFloorSeqNum())
+	_, _, err = d.GetAsOf([]byte("a"), seq2)
+	require.True(t, errors.Is(err, ErrHistoryNotRetained), "%v", err)
+	require.Equal(t, "<not found>", getAsOf("a", seq3))
+	require.Equal(t, snap.SeqNum()-1, seq3)
+	require.NoError(t, snap.Close())
+
+	snap = d.NewSnapshot()
+	seq4, err := d.SetWithSeq([]byte("a"), []byte("4"), nil)
+	require.NoError(t, err)
+	_, err = d.SetWithSeq([]byte("a"), []byte("5"), nil)
+	require.NoError(t, err)
+	require.NoError(t, d.Flush())
+	require.NoError(t, d.Compact([]byte("a"), []byte("b"), false /* parallelize */))
+	_, _, err = d.GetAsOf([]byte("a"), seq4)
+	require.True(t, errors.Is(err, ErrHistoryNotRetained), "%v", err)
+	require.Equal(t, "<not found>", getAsOf("a", snap.SeqNum()-1))
+	require.NoError(t, snap.Close())
+	require.Equal(t, "5", getAsOf("a", d.HistoryFloorSeqNum()))
+}
+
+func TestGetWithProvenance(t *testing.T) {
+	d, err := Open("", &Options{
+		FS:                          vfs.NewMem(),
+		DisableAutomaticCompactions: true,
+	})
+	require.NoError(t, err)
+	defer func() { require.NoError(t, d.Close()) }()
+
+	get := func(key string) string {
+		value, fileNum, level, closer, err := d.GetWithProvenance([]byte(key))
+		if err != nil {
+			return err.Error()
+		}
+		defer func() { require.NoError(t, closer.Close()) }()
+		return fmt.Sprintf("%s %s L%d", value, fileNum, level)
+	}
+
+	require.NoError(t, d.Set([]byte("a"), []byte("1"), nil))
+	require.NoError(t, d.Merge([]byte("d"), []byte("1"), nil))
+	require.NoError(t, d.Flush())
+	require.NoError(t, d.Compact([]byte("a"), []byte("e"), false /* parallelize */))
+	require.NoError(t, d.Set([]byte("b"), []byte("2"), nil))
+	require.NoError(t, d.Flush())
+	require.NoError(t, d.Set([]byte("c"), []byte("3"), nil))
+	require.NoError(t, d.Merge([]byte("d"), []byte("3"), nil))
+
+	tables, err := d.SSTables()
+	require.NoError(t, err)
+	files := make(map[string]FileNum)
+	for _, level := range tables {
+		for _, f := range level {
+			files[string(f.Smallest.UserKey)] = f.FileNum
+		}
+	}
+	require.Equal(t, fmt.Sprintf("1 %s L6", files["a"]), get("a"))
+	require.Equal(t, fmt.Sprintf("2 %s L0", files["b"]), get("b"))
+	require.Equal(t, "3 000000 L-1", get("c"))
+	// The provenance of a merged value is that of the newest operand.
+	require.Equal(t, "13 000000 L-1", get("d"))
+	require.Equal(t, ErrNotFound.Error(), get("e"))
+}
+
+func TestIncrementalIter(t *testing.T) {
+	d, err := Open("", &Options{
+		FS:                          vfs.NewMem(),
+		DisableAutomaticCompactions: true,
+	})
+	require.NoError(t, err)
+	defer func() { require.NoError(t, d.Close()) }()
+
+	// scan returns the keys and values surfaced by the iterator, and the
+	// number of levels of its merging iterator.
+	scan := func(iter *Iterator) (string, int) {
+		defer func() { require.NoError(t, iter.Close()) }()
+		var buf strings.Builder
+		for valid := iter.First(); valid; valid = iter.Next() {
+			fmt.Fprintf(&buf, "%s:%s ", iter.Key(), iter.Value())
+		}
+		return strings.TrimSpace(buf.String()), len(iter.alloc.merging.levels)
+	}
+
+	for _, k := range []string{"a", "b", "c"} {
+		require.NoError(t, d.Set([]byte(k), []byte("1"), nil))
+	}
+	require.NoError(t, d.Flush())
+	s1 := d.NewSnapshot()
+	defer func() { require.NoError(t, s1.Close()) }()
+
+	require.NoError(t, d.Set([]byte("b"), []byte("2"), nil))
+	require.NoError(t, d.Delete([]byte("c"), nil))
+	require.NoError(t, d.Set([]byte("d"), []byte("2"), nil))
+	require.NoError(t, d.Flush())
+	s2 := d.NewSnapshot()
+	defer func() { require.NoError(t, s2.Close()) }()
+	require.NoError(t, d.Set([]byte("e"), []byte("3"), nil))
+
+	// The sstable written before s1 is not read, nor is the flushed memtable.
+	keys, levels := scan(d.NewIncrementalIter(s1.SeqNum(), nil, nil))
+	require.Equal(t, "b:2 d:2 e:3", keys)
+	require.Equal(t, 2, levels)
+	keys, _ = scan(d.NewIncrementalIter(s1.SeqNum(), []byte("c"), []byte("e")))
+	require.Equal(t, "d:2", keys)
+	keys, levels = scan(d.NewIncrementalIter(s2.SeqNum(), nil, nil))
+	require.Equal(t, "e:3", keys)
+	require.Equal(t, 1, levels)
+
+	// Reading from a snapshot excludes the writes made after it.
+	keys, _ = scan(s2.NewIncrementalIter(s1.SeqNum(), nil, nil))
+	require.Equal(t, "b:2 d:2", keys)
+
+	// A sinceSeqNum of zero is equivalent to NewIter.
+	keys, levels = scan(d.NewIncrementalIter(0, nil, nil))
+	require.Equal(t, "a:1 b:2 d:2 e:3", keys)
+	require.Equal(t, 3, levels)
+
+	// Clones and SetOptions preserve the incremental view.
+	iter := d.NewIncrementalIter(s1.SeqNum(), nil, nil)
+	clone, err := iter.Clone(CloneOptions{})
+	require.NoError(t, err)
+	require.NoError(t, iter.Close())
+	clone.SetOptions(&IterOptions{LowerBound: []byte("c")})
+	keys, _ = scan(clone)
+	require.Equal(t, "d:2 e:3", keys)
+}
+
+func TestMaxInlineValueSize(t *testing.T) {
+	for _, fmv := range []FormatMajorVersion{FormatCompressionDictionaries, FormatChunkedValues} {
+		t.Run(fmv.String(), func(t *testing.T) {
+			opts := &Options{
+				FS:                 vfs.NewMem(),
+				FormatMajorVersion: fmv,
+				Levels:             []LevelOptions{{BlockSize: 256}},
+			}
+			opts.Experimental.MaxInlineValueSize = 64
+			d, err := Open("", opts)
+			require.NoError(t, err)
+			defer func() { require.NoError(t, d.Close()) }()
+
+			// Values of SET keys, and MERGE operands of "m", each flushed to an
+			// sstable of its own so that the flushes don't merge them. Only the
+			// values larger than 64 bytes are chunked.
+			expected := make(map[string][]byte)
+			for i, n := range []int{10, 64, 65, 1000, 10000} {
+				k := fmt.Sprintf("k%d", i)
+				expected[k] = bytes.Repeat([]byte{byte('a' + i)}, n)
+				require.NoError(t, d.Set([]byte(k), expected[k], nil))
+			}
+			for i, n := range []int{100, 10, 500} {
+				operand := bytes.Repeat([]byte{byte('x' + i)}, n)
+				expected["m"] = append(expected["m"], operand...)
+				require.NoError(t, d.Merge([]byte("m"), operand, nil))
+				require.NoError(t, d.Flush())
+			}
+
+			verify := func() {
+				for k, v := range expected {
+					verifyGet(t, d, []byte(k), v)
+				}
+				iter := d.NewIter(nil)
+				var n int
+				for valid := iter.First(); valid; valid = iter.Next() {
+					require.Equal(t, expected[string(iter.Key())], iter.Value())
+					n++
+				}
+				require.Equal(t, len(expected), n)
+				for valid := iter.Last(); valid; valid = iter.Prev() {
+					require.Equal(t, expected[string(iter.Key())], iter.Value())
+				}
+				require.NoError(t, iter.Close())
+			}
+			numChunkedValues := func() uint64 {
+				tables, err := d.SSTables(WithProperties())
+				require.NoError(t, err)
+				var n uint64
+				for level := range tables {
+					for _, table := range tables[level] {
+						n += table.Properties.NumChunkedValues
+					}
+				}
+				return n
+			}
+
+			// blockReads returns the blocks read by a forward scan, which
+			// reads chunked values only if readValues is true.
+			blockReads := func(readValues bool) uint64 {
+				iter := d.NewIter(nil)
+				for valid := iter.First(); valid; valid = iter.Next() {
+					if readValues {
+						_ = iter.Value()
+					}
+				}
+				n := iter.Stats().InternalStats.BlockReads
+				require.NoError(t, iter.Close())
+				return n
+			}
+
+			verify()
+			want := map[FormatMajorVersion]uint64{FormatChunkedValues: 5}[fmv]
+			require.Equal(t, want, numChunkedValues())
+			// The chunked values of SET keys are only read by Value. Chunked
+			// merge operands are read to be merged either way.
+			if fmv == FormatChunkedValues {
+				require.Less(t, blockReads(false), blockReads(true))
+			} else {
+				require.Equal(t, blockReads(false), blockReads(true))
+			}
+
+			// The compaction merges the operands into a single chunked value.
+			require.NoError(t, d.Compact([]byte("a"), []byte("z"), false /* parallelize */))
+			verify()
+			want = map[FormatMajorVersion]uint64{FormatChunkedValues: 4}[fmv]
+			require.Equal(t, want, numChunkedValues())
+		})
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright 2026 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package pebble
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/cockroachdb/pebble/vfs"
+)
+
+// wrapDir wraps a directory opened by the DB according to the configured
+// Options.Experimental.DirSyncPolicy.
+func wrapDir(opts *Options, dir vfs.File) vfs.File {
+	if dir == nil || opts.Experimental.DirSyncPolicy == DirSyncPerOperation {
+		return dir
+	}
+	d := &batchedDir{File: dir}
+	d.cond.L = &d.mu
+	return d
+}
+
+// batchedDir wraps a directory, coalescing concurrent syncs of the directory.
+// A call to Sync waits for a sync of the directory that began after the call,
+// which captures every change made to the directory before the call, and
+// shares that sync with the other callers waiting on it.
+type batchedDir struct {
+	vfs.File
+	mu   sync.Mutex
+	cond sync.Cond
+	// syncing is true while a sync is in progress.
+	syncing bool
+	// started and finished count the syncs that have begun and completed.
+	started, finished uint64
+	// err is the error returned by the most recently completed sync.
+	err error
+}
+
+func (d *batchedDir) Sync() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	// A sync in progress may have begun before the caller's changes to the
+	// directory, so the caller must wait for the next sync to begin.
+	want := d.started + 1
+	for d.finished < want {
+		if d.syncing {
+			d.cond.Wait()
+			continue
+		}
+		d.syncing = true
+		d.started++
+		n := d.started
+		d.mu.Unlock()
+		err := d.File.Sync()
+		d.mu.Lock()
+		d.syncing = false
+		d.finished, d.err = n, err
+		d.cond.Broadcast()
+	}
+	return d.err
+}
+
+// deferredDirSyncWAL wraps a newly created WAL under DirSyncDeferred, syncing
+// the WAL's directory before the first sync of the WAL, rather than when the
+// WAL is created.
+type deferredDirSyncWAL struct {
+	vfs.File
+	dir    vfs.File
+	atomic struct {
+		dirSynced uint32
+	}
+}
+
+func (f *deferredDirSyncWAL) Sync() error {
+	if atomic.LoadUint32(&f.atomic.dirSynced) == 0 {
+		if err := f.dir.Sync(); err != nil {
+			return err
+		}
+		atomic.StoreUint32(&f.atomic.dirSynced, 1)
+	}
+	return f.File.Sync()
+}
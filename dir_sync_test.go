@@ -0,0 +1,101 @@
+// Copyright 2026 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package pebble
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/pebble/vfs"
+	"github.com/stretchr/testify/require"
+)
+
+// blockingDir is a directory whose syncs block until released.
+type blockingDir struct {
+	vfs.File
+	syncs   chan struct{}
+	release chan struct{}
+}
+
+func (d *blockingDir) Sync() error {
+	d.syncs <- struct{}{}
+	<-d.release
+	return nil
+}
+
+func TestBatchedDirSync(t *testing.T) {
+	opts := &Options{}
+	opts.Experimental.DirSyncPolicy = DirSyncBatched
+	bd := &blockingDir{syncs: make(chan struct{}, 10), release: make(chan struct{})}
+	dir := wrapDir(opts, bd)
+
+	// Syncs that don't overlap are not coalesced.
+	for i := 0; i < 2; i++ {
+		errCh := make(chan error)
+		go func() { errCh <- dir.Sync() }()
+		<-bd.syncs
+		bd.release <- struct{}{}
+		require.NoError(t, <-errCh)
+	}
+
+	// The syncs requested while a sync is in progress wait for the next sync,
+	// which they share.
+	var wg sync.WaitGroup
+	sync1 := func() {
+		defer wg.Done()
+		require.NoError(t, dir.Sync())
+	}
+	wg.Add(1)
+	go sync1()
+	<-bd.syncs
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go sync1()
+	}
+	time.Sleep(10 * time.Millisecond)
+	bd.release <- struct{}{}
+	<-bd.syncs
+	bd.release <- struct{}{}
+	wg.Wait()
+	require.Empty(t, bd.syncs)
+
+	// The default policy doesn't wrap the directory.
+	require.Equal(t, vfs.File(bd), wrapDir(&Options{}, bd))
+}
+
+func TestDirSyncDeferred(t *testing.T) {
+	for _, policy := range []DirSyncPolicy{DirSyncPerOperation, DirSyncDeferred} {
+		t.Run(policy.String(), func(t *testing.T) {
+			var buf syncedBuffer
+			opts := &Options{
+				FS:     loggingFS{FS: vfs.NewMem(), w: &buf},
+				WALDir: "wal",
+			}
+			opts.Experimental.DirSyncPolicy = policy
+			d, err := Open("", opts)
+			require.NoError(t, err)
+			defer func() { require.NoError(t, d.Close()) }()
+			walDirSyncs := func() int {
+				return strings.Count(buf.String(), "sync: wal\n")
+			}
+
+			// Under DirSyncDeferred, the WAL directory isn't synced until the
+			// WAL is synced.
+			expected := 1
+			if policy == DirSyncDeferred {
+				expected = 0
+			}
+			require.Equal(t, expected, walDirSyncs())
+			require.NoError(t, d.Set([]byte("a"), nil, NoSync))
+			require.Equal(t, expected, walDirSyncs())
+			require.NoError(t, d.Set([]byte("b"), nil, Sync))
+			require.Equal(t, 1, walDirSyncs())
+			require.NoError(t, d.Set([]byte("c"), nil, Sync))
+			require.Equal(t, 1, walDirSyncs())
+		})
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright 2026 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package pebble
+
+import "sync/atomic"
+
+// checkDiskUsage returns ErrDiskFull if writing size more bytes would make the
+// disk space used by the DB exceed Options.MaxDiskUsageBytes.
+func (d *DB) checkDiskUsage(size uint64) error {
+	_, recycledLogSize := d.logRecycler.stats()
+	d.mu.Lock()
+	usage := d.diskUsageLocked() + recycledLogSize
+	d.mu.Unlock()
+	if usage+size <= uint64(d.opts.MaxDiskUsageBytes) {
+		return nil
+	}
+	atomic.AddInt64(&d.atomic.diskFullRejections, 1)
+	return ErrDiskFull
+}
+
+// diskUsageLocked returns the disk space used by the DB, as computed by
+// Metrics.DiskSpaceUsage, excluding the recycled WALs, which are measured
+// without holding d.mu, and the MANIFEST, which would require waiting for
+// in-progress writes to the MANIFEST.
+//
+// d.mu must be held when calling this.
+func (d *DB) diskUsageLocked() uint64 {
+	vs := d.mu.versions
+	var usage uint64
+	for _, l := range vs.metrics.Levels {
+		usage += uint64(l.Size)
+	}
+	usage += vs.metrics.Table.ObsoleteSize
+	for _, size := range vs.zombieTables {
+		usage += size
+	}
+	usage += uint64(atomic.LoadInt64(&vs.atomic.atomicInProgressBytes))
+	// See the computation of Metrics.WAL.PhysicalSize.
+	walSize := atomic.LoadUint64(&d.atomic.logSize)
+	if n := len(d.mu.log.queue); n > 0 {
+		if walSize < d.mu.log.queue[n-1].fileSize {
+			walSize = d.mu.log.queue[n-1].fileSize
+		}
+		for i := 0; i < n-1; i++ {
+			walSize += d.mu.log.queue[i].fileSize
+		}
+	}
+	return usage + walSize + d.optionsFileSize
+}
+
+// batchOnlyDeletes returns true if b contains only deletions, which are
+// applied regardless of Options.MaxDiskUsageBytes.
+func batchOnlyDeletes(b *Batch) bool {
+	for r := b.Reader(); ; {
+		kind, _, _, ok := r.Next()
+		if !ok {
+			return true
+		}
+		switch kind {
+		case InternalKeyKindDelete, InternalKeyKindSingleDelete,
+			InternalKeyKindRangeDelete, InternalKeyKindRangeKeyDelete:
+		default:
+			return false
+		}
+	}
+}
@@ -418,3 +418,42 @@ func TestDBWALRotationCrash(t *testing.T) {
 		require.NoError(t, run(fs, k))
 	}
 }
+
+func TestFSRetryPolicy(t *testing.T) {
+	errTransient := errors.New("transient error")
+	// Fail every other operation, other than syncs, which aren't retried.
+	var count int32
+	inj := errorfs.InjectorFunc(func(op errorfs.Op, _ string) error {
+		if op != errorfs.OpFileSync && atomic.AddInt32(&count, 1)%2 == 1 {
+			return errTransient
+		}
+		return nil
+	})
+	fs := errorfs.Wrap(vfs.NewMem(), inj)
+
+	// Without a retry policy, the errors are returned.
+	_, err := Open("", &Options{FS: fs})
+	require.True(t, errors.Is(err, errTransient), "%v", err)
+
+	d, err := Open("", &Options{
+		FS: fs,
+		FSRetryPolicy: &vfs.RetryPolicy{
+			MaxRetries:  10,
+			IsRetryable: func(err error) bool { return errors.Is(err, errTransient) },
+		},
+	})
+	require.NoError(t, err)
+	for i := 0; i < 100; i++ {
+		require.NoError(t, d.Set([]byte(fmt.Sprintf("%03d", i)), []byte("v"), nil))
+	}
+	require.NoError(t, d.Flush())
+	require.NoError(t, d.Compact([]byte("000"), []byte("100"), false))
+	v, closer, err := d.Get([]byte("050"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("v"), v)
+	require.NoError(t, closer.Close())
+
+	m := d.Metrics()
+	require.Less(t, int64(0), m.FSRetries.Count)
+	require.NoError(t, d.Close())
+}
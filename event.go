@@ -230,6 +230,35 @@ func (i ManifestDeleteInfo) SafeFormat(w redact.SafePrinter, _ rune) {
 	w.Printf("[JOB %d] MANIFEST deleted %s", redact.Safe(i.JobID), redact.Safe(i.FileNum))
 }
 
+// VersionEditInfo contains the info for an edit to the LSM, which atomically
+// adds and removes tables. It's passed to the listeners registered with
+// DB.RegisterVersionListener.
+type VersionEditInfo struct {
+	// JobID is the ID of the job that applied the edit. It matches the JobID of
+	// the job's other events, such as FlushInfo, CompactionInfo and
+	// TableIngestInfo.
+	JobID int
+	// Added contains the tables added by the edit, organized by level in
+	// increasing order of level.
+	Added []LevelInfo
+	// Deleted contains the tables removed by the edit, organized by level in
+	// increasing order of level. A table moved between levels appears in
+	// Deleted at its previous level, and in Added at its new level.
+	Deleted []LevelInfo
+}
+
+func (i VersionEditInfo) String() string {
+	return redact.StringWithoutMarkers(i)
+}
+
+// SafeFormat implements redact.SafeFormatter.
+func (i VersionEditInfo) SafeFormat(w redact.SafePrinter, _ rune) {
+	w.Printf("[JOB %d] version edit: added ", redact.Safe(i.JobID))
+	w.Print(levelInfos(i.Added))
+	w.Printf(", deleted ")
+	w.Print(levelInfos(i.Deleted))
+}
+
 // TableCreateInfo contains the info for a table creation event.
 type TableCreateInfo struct {
 	JobID int
@@ -283,7 +312,18 @@ type TableIngestInfo struct {
 	// GlobalSeqNum is the sequence number that was assigned to all entries in
 	// the ingested table.
 	GlobalSeqNum uint64
-	Err          error
+	// MemtableOverlap is true if the ingested tables overlapped the data in a
+	// memtable. The ingestion then forces the memtable to be flushed, and waits
+	// for the flush to complete before the tables are added to the LSM, which
+	// places them in L0. Overlap with a memtable is a common cause of slow
+	// ingestions.
+	MemtableOverlap bool
+	// OverlapSmallest and OverlapLargest are the smallest and largest user keys
+	// of the ingested tables that overlapped the memtable. They're only set if
+	// MemtableOverlap is true.
+	OverlapSmallest []byte
+	OverlapLargest  []byte
+	Err             error
 }
 
 func (i TableIngestInfo) String() string {
@@ -306,6 +346,9 @@ func (i TableIngestInfo) SafeFormat(w redact.SafePrinter, _ rune) {
 		w.Printf(" L%d:%s (%s)", redact.Safe(t.Level), redact.Safe(t.FileNum),
 			redact.Safe(humanize.Uint64(t.Size)))
 	}
+	if i.MemtableOverlap {
+		w.Printf("; flushed overlapping memtable")
+	}
 }
 
 // TableStatsInfo contains the info for a table stats loaded event.
@@ -395,6 +438,36 @@ func (i WALDeleteInfo) SafeFormat(w redact.SafePrinter, _ rune) {
 	w.Printf("[JOB %d] WAL deleted %s", redact.Safe(i.JobID), redact.Safe(i.FileNum))
 }
 
+// WALTruncateInfo contains the info for a WAL truncation event, which occurs
+// when Open stops replaying the WAL at a batch whose sequence number is ahead
+// of the next expected sequence number. See
+// Options.Experimental.OnSeqNumMismatch.
+type WALTruncateInfo struct {
+	// JobID is the ID of the job that replayed the WAL.
+	JobID int
+	// FileNum is the file number of the WAL containing the batch.
+	FileNum FileNum
+	// Offset is the offset of the batch within the WAL. The batch, every later
+	// batch in the WAL, and every later WAL were discarded.
+	Offset int64
+	// SeqNum is the sequence number of the batch.
+	SeqNum uint64
+	// RecoveredSeqNum is the largest sequence number recovered by Open. All
+	// writes with larger sequence numbers were discarded.
+	RecoveredSeqNum uint64
+}
+
+func (i WALTruncateInfo) String() string {
+	return redact.StringWithoutMarkers(i)
+}
+
+// SafeFormat implements redact.SafeFormatter.
+func (i WALTruncateInfo) SafeFormat(w redact.SafePrinter, _ rune) {
+	w.Printf("[JOB %d] WAL truncated %s at offset %d: batch sequence number %d, recovered sequence number %d",
+		redact.Safe(i.JobID), redact.Safe(i.FileNum), redact.Safe(i.Offset),
+		redact.Safe(i.SeqNum), redact.Safe(i.RecoveredSeqNum))
+}
+
 // WriteStallBeginInfo contains the info for a write stall begin event.
 type WriteStallBeginInfo struct {
 	Reason string
@@ -473,6 +546,10 @@ type EventListener struct {
 	// WALDeleted is invoked after a WAL has been deleted.
 	WALDeleted func(WALDeleteInfo)
 
+	// WALTruncated is invoked when Open stops replaying the WAL at a batch with
+	// an unexpected sequence number. See Options.Experimental.OnSeqNumMismatch.
+	WALTruncated func(WALTruncateInfo)
+
 	// WriteStallBegin is invoked when writes are intentionally delayed.
 	WriteStallBegin func(WriteStallBeginInfo)
 
@@ -539,6 +616,9 @@ func (l *EventListener) EnsureDefaults(logger Logger) {
 	if l.WALDeleted == nil {
 		l.WALDeleted = func(info WALDeleteInfo) {}
 	}
+	if l.WALTruncated == nil {
+		l.WALTruncated = func(info WALTruncateInfo) {}
+	}
 	if l.WriteStallBegin == nil {
 		l.WriteStallBegin = func(info WriteStallBeginInfo) {}
 	}
@@ -603,6 +683,9 @@ func MakeLoggingEventListener(logger Logger) EventListener {
 		WALDeleted: func(info WALDeleteInfo) {
 			logger.Infof("%s", info)
 		},
+		WALTruncated: func(info WALTruncateInfo) {
+			logger.Infof("%s", info)
+		},
 		WriteStallBegin: func(info WriteStallBeginInfo) {
 			logger.Infof("%s", info)
 		},
@@ -681,6 +764,10 @@ func TeeEventListener(a, b EventListener) EventListener {
 			a.WALDeleted(info)
 			b.WALDeleted(info)
 		},
+		WALTruncated: func(info WALTruncateInfo) {
+			a.WALTruncated(info)
+			b.WALTruncated(info)
+		},
 		WriteStallBegin: func(info WriteStallBeginInfo) {
 			a.WriteStallBegin(info)
 			b.WriteStallBegin(info)
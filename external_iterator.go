@@ -59,6 +59,7 @@ func NewExternalIter(
 		equal:               o.equal(),
 		merge:               o.Merger.Merge,
 		split:               o.Comparer.Split,
+		formatKey:           o.Comparer.FormatKey,
 		readState:           nil,
 		keyBuf:              buf.keyBuf,
 		prefixOrFullSeekKey: buf.prefixOrFullSeekKey,
@@ -122,7 +123,12 @@ func finishInitializingExternal(it *Iterator) {
 				pointIter    internalIterator
 				err          error
 			)
-			pointIter, err = r.NewIter(it.opts.LowerBound, it.opts.UpperBound)
+			pointIter, err = r.NewIterWithBlockPropertyFilters(
+				it.opts.LowerBound, it.opts.UpperBound, nil, /* filterer */
+				true /* useFilterBlock */, sstable.IterOptions{
+					DisableCacheFill: it.opts.DisableCacheFill,
+					PrefetchBlocks:   it.opts.PrefetchBlocks,
+				})
 			if err == nil {
 				rangeDelIter, err = r.NewRawRangeDelIter()
 			}
@@ -7,6 +7,7 @@ package pebble
 import (
 	"fmt"
 
+	"github.com/cockroachdb/errors"
 	"github.com/cockroachdb/pebble/internal/base"
 	"github.com/cockroachdb/pebble/vfs"
 )
@@ -27,6 +28,40 @@ const (
 	fileTypeOldTemp  = base.FileTypeOldTemp
 )
 
+// makeTableFilepath returns the path in dirname of the sstable with the given
+// file number and name. The name is the one recorded for the sstable in the
+// manifest (see Options.SSTablePathFunc), and is empty if the sstable is named
+// by its file number.
+func makeTableFilepath(fs vfs.FS, dirname string, fileNum FileNum, name string) string {
+	if name != "" {
+		return fs.PathJoin(dirname, name)
+	}
+	return base.MakeFilepath(fs, dirname, fileTypeTable, fileNum)
+}
+
+// validateTablePathFunc returns an error if pathFunc is observed to violate
+// the requirements of Options.SSTablePathFunc: that it returns a unique file
+// name for each file number, which doesn't have the form of the names of the
+// DB's other files. Only a sample of file numbers is checked, so a nil error
+// doesn't guarantee pathFunc is valid.
+func validateTablePathFunc(fs vfs.FS, pathFunc func(FileNum) string) error {
+	seen := make(map[string]FileNum)
+	for _, fileNum := range []FileNum{1, 2, 3, 10, 1000, 123456, 1 << 32} {
+		name := pathFunc(fileNum)
+		if name == "" || fs.PathBase(name) != name {
+			return errors.Errorf("sstable %s is named %q, which is not a file name", fileNum, name)
+		}
+		if _, _, ok := base.ParseFilename(fs, name); ok {
+			return errors.Errorf("sstable %s is named %q, which has the form of a DB file name", fileNum, name)
+		}
+		if prev, ok := seen[name]; ok {
+			return errors.Errorf("sstables %s and %s are both named %q", prev, fileNum, name)
+		}
+		seen[name] = fileNum
+	}
+	return nil
+}
+
 // setCurrentFile sets the CURRENT file to point to the manifest with
 // provided file number.
 //
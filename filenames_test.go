@@ -5,10 +5,15 @@
 package pebble
 
 import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
 	"testing"
 
 	"github.com/cockroachdb/errors"
 	"github.com/cockroachdb/pebble/internal/base"
+	"github.com/cockroachdb/pebble/sstable"
 	"github.com/cockroachdb/pebble/vfs"
 	"github.com/stretchr/testify/require"
 )
@@ -104,3 +109,121 @@ func (l noFatalLogger) Infof(format string, args ...interface{}) {
 func (l noFatalLogger) Fatalf(format string, args ...interface{}) {
 	l.t.Logf(format, args...)
 }
+
+func TestSSTablePathFunc(t *testing.T) {
+	mem := vfs.NewMem()
+	opts := &Options{
+		FS: mem,
+		SSTablePathFunc: func(fileNum FileNum) string {
+			return fmt.Sprintf("table-%s", fileNum)
+		},
+	}
+	tables := func(dir string) []string {
+		ls, err := mem.List(dir)
+		require.NoError(t, err)
+		var tables []string
+		for _, name := range ls {
+			if strings.HasPrefix(name, "table-") {
+				tables = append(tables, name)
+			}
+			fileType, _, ok := base.ParseFilename(mem, name)
+			require.False(t, ok && fileType == fileTypeTable, name)
+		}
+		sort.Strings(tables)
+		return tables
+	}
+
+	d, err := Open("db", opts)
+	require.NoError(t, err)
+	require.NoError(t, d.Set([]byte("a"), []byte("a"), nil))
+	require.NoError(t, d.Flush())
+	require.NoError(t, d.Set([]byte("b"), []byte("b"), nil))
+	require.NoError(t, d.Flush())
+	require.Equal(t, []string{"table-000005", "table-000007"}, tables("db"))
+
+	// Ingested sstables are linked under the custom names, and the inputs of
+	// compactions are deleted under them.
+	f, err := mem.Create("ext")
+	require.NoError(t, err)
+	w := sstable.NewWriter(f, sstable.WriterOptions{})
+	require.NoError(t, w.Set([]byte("c"), []byte("c")))
+	require.NoError(t, w.Close())
+	require.NoError(t, d.Ingest([]string{"ext"}))
+	require.Equal(t, []string{"table-000005", "table-000007", "table-000008"}, tables("db"))
+	require.NoError(t, d.Compact([]byte("a"), []byte("d"), false /* parallelize */))
+	require.Equal(t, []string{"table-000008", "table-000009"}, tables("db"))
+
+	require.NoError(t, d.Checkpoint("checkpoint"))
+	require.Equal(t, []string{"table-000008", "table-000009"}, tables("checkpoint"))
+	require.NoError(t, d.Close())
+
+	// The names are recorded in the MANIFEST, so the DB and its checkpoint
+	// can be reopened without the function.
+	for _, dir := range []string{"db", "checkpoint"} {
+		d, err = Open(dir, &Options{FS: mem})
+		require.NoError(t, err)
+		for _, k := range []string{"a", "b", "c"} {
+			v, closer, err := d.Get([]byte(k))
+			require.NoError(t, err)
+			require.Equal(t, k, string(v))
+			require.NoError(t, closer.Close())
+		}
+		require.NoError(t, d.Close())
+	}
+
+	// The function need not be deterministic. Sstables that are in the DB
+	// directory under names that aren't recorded in the MANIFEST, such as
+	// those left behind by a crash, are deleted by Open, but other files are
+	// left alone.
+	var calls int
+	opts.SSTablePathFunc = func(fileNum FileNum) string {
+		calls++
+		return fmt.Sprintf("table-%s-%d", fileNum, calls)
+	}
+	f, err = mem.Create("db/table-orphan")
+	require.NoError(t, err)
+	w = sstable.NewWriter(f, sstable.WriterOptions{})
+	require.NoError(t, w.Set([]byte("z"), []byte("z")))
+	require.NoError(t, w.Close())
+	f, err = mem.Create("db/notes")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	d, err = Open("db", opts)
+	require.NoError(t, err)
+	require.Equal(t, []string{"table-000008", "table-000009"}, tables("db"))
+	_, err = mem.Stat("db/notes")
+	require.NoError(t, err)
+	require.NoError(t, d.Set([]byte("d"), []byte("d"), nil))
+	require.NoError(t, d.Flush())
+	names := tables("db")
+	require.Len(t, names, 3)
+	require.Regexp(t, `^table-\d+-\d+$`, names[2])
+	require.NoError(t, d.Close())
+	d, err = Open("db", opts)
+	require.NoError(t, err)
+	require.Equal(t, names, tables("db"))
+	require.NoError(t, d.Close())
+
+	// Functions observed to return names that aren't unique file names, or
+	// that have the form of the names of the DB's files, are rejected.
+	for _, tc := range []struct {
+		pathFunc func(FileNum) string
+		errRE    string
+	}{
+		{func(fileNum FileNum) string {
+			return fmt.Sprintf("tables/%s", fileNum)
+		}, `sstable 000001 is named "tables/000001", which is not a file name`},
+		{func(fileNum FileNum) string {
+			return base.MakeFilename(fileTypeLog, fileNum)
+		}, `sstable 000001 is named "000001.log", which has the form of a DB file name`},
+		{func(fileNum FileNum) string {
+			return base.MakeFilename(fileTypeTable, fileNum+1)
+		}, `sstable 000001 is named "000002.sst", which has the form of a DB file name`},
+		{func(fileNum FileNum) string {
+			return fmt.Sprintf("table-%d", fileNum%2)
+		}, `sstables 000001 and 000003 are both named "table-1"`},
+	} {
+		_, err := Open("other", &Options{FS: mem, SSTablePathFunc: tc.pathFunc})
+		require.Regexp(t, regexp.QuoteMeta(tc.errRE), err)
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright 2026 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package pebble
+
+import (
+	"github.com/cockroachdb/errors"
+	"github.com/cockroachdb/pebble/internal/base"
+)
+
+// FlushRange flushes the memtables holding keys in the range [lower, upper),
+// and returns whether a flush was needed. It returns once every key, range
+// deletion and range key in the range that was written before the call is
+// durable in sstables. If no unflushed memtable overlaps the range,
+// FlushRange returns immediately without flushing.
+//
+// Memtables can only be flushed in their entirety, and in order: flushing the
+// range flushes every memtable up to and including the newest one overlapping
+// the range, along with all of their keys outside the range. If the mutable
+// memtable overlaps the range, it's rotated and flushed as by Flush. If only
+// older, immutable memtables overlap the range, FlushRange waits for them to
+// flush without rotating the mutable memtable.
+//
+// If background work is paused before the memtables are flushed, FlushRange
+// returns the error reported by BackgroundError.
+func (d *DB) FlushRange(lower, upper []byte) (flushed bool, err error) {
+	if err := d.closed.Load(); err != nil {
+		panic(err)
+	}
+	if d.opts.ReadOnly {
+		return false, ErrReadOnly
+	}
+	if lower == nil || upper == nil {
+		return false, errors.New("pebble: FlushRange requires lower and upper bounds")
+	}
+	if d.cmp(lower, upper) >= 0 {
+		return false, errors.Errorf("pebble: FlushRange lower %s is not less than upper %s",
+			d.opts.Comparer.FormatKey(lower), d.opts.Comparer.FormatKey(upper))
+	}
+
+	d.mu.Lock()
+	if err := d.mu.compact.backgroundErr; err != nil {
+		d.mu.Unlock()
+		return false, err
+	}
+	mem, err := func() (*flushableEntry, error) {
+		// The queue is ordered from oldest to newest with the mutable memtable
+		// being the last element in the slice. We want to wait for the newest
+		// memtable that overlaps.
+		for i := len(d.mu.mem.queue) - 1; i >= 0; i-- {
+			mem := d.mu.mem.queue[i]
+			if !flushableOverlapsRange(d.cmp, mem, lower, upper) {
+				continue
+			}
+			var err error
+			if mem.flushable == d.mu.mem.mutable {
+				// We have to hold both commitPipeline.mu and DB.mu when calling
+				// makeRoomForWrite(). Lock order requirements elsewhere force us to
+				// unlock DB.mu in order to grab commitPipeline.mu first.
+				d.mu.Unlock()
+				d.commit.mu.Lock()
+				d.mu.Lock()
+				defer d.commit.mu.Unlock()
+				if mem.flushable == d.mu.mem.mutable {
+					// Only flush if the active memtable is unchanged.
+					err = d.makeRoomForWrite(nil)
+				}
+			}
+			mem.flushForced = true
+			d.maybeScheduleFlush()
+			return mem, err
+		}
+		return nil, nil
+	}()
+	d.mu.Unlock()
+
+	if err != nil {
+		return false, err
+	}
+	if mem == nil {
+		return false, nil
+	}
+	return true, d.waitForFlush(mem.flushed)
+}
+
+// flushableOverlapsRange returns true if the flushable holds point keys, range
+// deletions or range keys overlapping the range [lower, upper).
+func flushableOverlapsRange(cmp Compare, f flushable, lower, upper []byte) bool {
+	m := (&fileMetadata{}).ExtendPointKeyBounds(cmp,
+		base.MakeInternalKey(lower, InternalKeySeqNumMax, InternalKeyKindMax),
+		base.MakeRangeDeleteSentinelKey(upper))
+	if ingestMemtableOverlaps(cmp, f, []*fileMetadata{m}) {
+		return true
+	}
+	rangeKeyIter := f.newRangeKeyIter(nil)
+	if rangeKeyIter == nil {
+		return false
+	}
+	defer rangeKeyIter.Close()
+	// The range keys are fragmented, so only the last span beginning before
+	// lower may cover lower.
+	if s := rangeKeyIter.SeekLT(lower); s != nil && cmp(s.End, lower) > 0 {
+		return true
+	}
+	s := rangeKeyIter.SeekGE(lower)
+	return s != nil && cmp(s.Start, upper) < 0
+}
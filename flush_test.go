@@ -11,6 +11,7 @@ import (
 
 	"github.com/cockroachdb/errors"
 	"github.com/cockroachdb/pebble/internal/datadriven"
+	"github.com/cockroachdb/pebble/internal/testkeys"
 	"github.com/cockroachdb/pebble/vfs"
 	"github.com/stretchr/testify/require"
 )
@@ -127,3 +128,51 @@ func TestFlushEmptyKey(t *testing.T) {
 	require.NoError(t, closer.Close())
 	require.NoError(t, d.Close())
 }
+
+func TestFlushRange(t *testing.T) {
+	d, err := Open("", &Options{
+		FS:                 vfs.NewMem(),
+		Comparer:           testkeys.Comparer,
+		FormatMajorVersion: FormatNewest,
+	})
+	require.NoError(t, err)
+	defer func() { require.NoError(t, d.Close()) }()
+
+	numFlushes := func() int64 {
+		return d.Metrics().Flush.Count
+	}
+	flushRange := func(lower, upper string) bool {
+		flushed, err := d.FlushRange([]byte(lower), []byte(upper))
+		require.NoError(t, err)
+		return flushed
+	}
+
+	// An empty memtable isn't flushed.
+	require.False(t, flushRange("a", "z"))
+	require.Zero(t, numFlushes())
+
+	// Point keys, range deletions and range keys each cause a flush when they
+	// overlap the range, and the upper bound is exclusive.
+	for _, tc := range []struct {
+		write func() error
+		lower string
+		upper string
+	}{
+		{func() error { return d.Set([]byte("m"), nil, nil) }, "n", "z"},
+		{func() error { return d.DeleteRange([]byte("c"), []byte("f"), nil) }, "f", "z"},
+		{func() error { return d.RangeKeySet([]byte("p"), []byte("r"), nil, nil, nil) }, "a", "p"},
+	} {
+		n := numFlushes()
+		require.NoError(t, tc.write())
+		require.False(t, flushRange(tc.lower, tc.upper))
+		require.Equal(t, n, numFlushes())
+		require.True(t, flushRange("a", "z"))
+		require.Equal(t, n+1, numFlushes())
+		require.False(t, flushRange("a", "z"))
+	}
+
+	_, err = d.FlushRange([]byte("b"), []byte("a"))
+	require.Error(t, err)
+	_, err = d.FlushRange(nil, []byte("a"))
+	require.Error(t, err)
+}
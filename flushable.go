@@ -40,6 +40,10 @@ type flushableEntry struct {
 	// delayedFlushForced indicates whether a timer has been set to force a flush
 	// on this memtable at some point in the future. Protected by DB.mu
 	delayedFlushForced bool
+	// flushBarrier prevents the flushable from being flushed together with
+	// newer flushables, so that the sstables flushed from it contain no keys
+	// newer than its own. Set by ReplaceAll. Protected by DB.mu.
+	flushBarrier bool
 	// logNum corresponds to the WAL that contains the records present in the
 	// receiver.
 	logNum FileNum
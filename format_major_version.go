@@ -92,11 +92,22 @@ const (
 	// version will have a table format version of at least Pebblev1 (Block
 	// Properties).
 	FormatMinTableFormatPebblev1
+	// FormatCompressionDictionaries is a format major version that introduces
+	// sstable.TableFormatPebblev3, which permits sstables compressed with zstd
+	// compression dictionaries. Previous Pebble versions cannot read these
+	// sstables, so they may only be ingested at or above this version.
+	FormatCompressionDictionaries
+	// FormatChunkedValues is a format major version that introduces
+	// sstable.TableFormatPebblev4, which prefixes the values of point keys and
+	// permits values larger than Options.Experimental.MaxInlineValueSize to be
+	// chunked into value blocks. Previous Pebble versions cannot read these
+	// sstables, so they may only be ingested at or above this version.
+	FormatChunkedValues
 	// FormatNewest always contains the most recent format major version.
 	// NB: When adding new versions, the MaxTableFormat method should also be
 	// updated to return the maximum allowable version for the new
 	// FormatMajorVersion.
-	FormatNewest FormatMajorVersion = FormatMinTableFormatPebblev1
+	FormatNewest FormatMajorVersion = FormatChunkedValues
 )
 
 // MaxTableFormat returns the maximum sstable.TableFormat that can be used at
@@ -110,6 +121,10 @@ func (v FormatMajorVersion) MaxTableFormat() sstable.TableFormat {
 		return sstable.TableFormatPebblev1
 	case FormatRangeKeys, FormatMinTableFormatPebblev1:
 		return sstable.TableFormatPebblev2
+	case FormatCompressionDictionaries:
+		return sstable.TableFormatPebblev3
+	case FormatChunkedValues:
+		return sstable.TableFormatPebblev4
 	default:
 		panic(fmt.Sprintf("pebble: unsupported format major version: %s", v))
 	}
@@ -123,7 +138,8 @@ func (v FormatMajorVersion) MinTableFormat() sstable.TableFormat {
 		FormatVersioned, FormatSetWithDelete, FormatBlockPropertyCollector,
 		FormatSplitUserKeysMarked, FormatMarkedCompacted, FormatRangeKeys:
 		return sstable.TableFormatLevelDB
-	case FormatMinTableFormatPebblev1:
+	case FormatMinTableFormatPebblev1, FormatCompressionDictionaries,
+		FormatChunkedValues:
 		return sstable.TableFormatPebblev1
 	default:
 		panic(fmt.Sprintf("pebble: unsupported format major version: %s", v))
@@ -229,6 +245,12 @@ var formatMajorVersionMigrations = map[FormatMajorVersion]func(*DB) error{
 	FormatMinTableFormatPebblev1: func(d *DB) error {
 		return d.finalizeFormatVersUpgrade(FormatMinTableFormatPebblev1)
 	},
+	FormatCompressionDictionaries: func(d *DB) error {
+		return d.finalizeFormatVersUpgrade(FormatCompressionDictionaries)
+	},
+	FormatChunkedValues: func(d *DB) error {
+		return d.finalizeFormatVersUpgrade(FormatChunkedValues)
+	},
 }
 
 const formatVersionMarkerName = `format-version`
@@ -45,6 +45,10 @@ func TestRatchetFormat(t *testing.T) {
 	require.Equal(t, FormatRangeKeys, d.FormatMajorVersion())
 	require.NoError(t, d.RatchetFormatMajorVersion(FormatMinTableFormatPebblev1))
 	require.Equal(t, FormatMinTableFormatPebblev1, d.FormatMajorVersion())
+	require.NoError(t, d.RatchetFormatMajorVersion(FormatCompressionDictionaries))
+	require.Equal(t, FormatCompressionDictionaries, d.FormatMajorVersion())
+	require.NoError(t, d.RatchetFormatMajorVersion(FormatChunkedValues))
+	require.Equal(t, FormatChunkedValues, d.FormatMajorVersion())
 	require.NoError(t, d.Close())
 
 	// If we Open the database again, leaving the default format, the
@@ -202,6 +206,8 @@ func TestFormatMajorVersions_TableFormat(t *testing.T) {
 		FormatMarkedCompacted:         {sstable.TableFormatLevelDB, sstable.TableFormatPebblev1},
 		FormatRangeKeys:               {sstable.TableFormatLevelDB, sstable.TableFormatPebblev2},
 		FormatMinTableFormatPebblev1:  {sstable.TableFormatPebblev1, sstable.TableFormatPebblev2},
+		FormatCompressionDictionaries: {sstable.TableFormatPebblev1, sstable.TableFormatPebblev3},
+		FormatChunkedValues:           {sstable.TableFormatPebblev1, sstable.TableFormatPebblev4},
 	}
 
 	// Valid versions.
@@ -35,6 +35,13 @@ type getIter struct {
 	iterKey      *InternalKey
 	iterValue    []byte
 	err          error
+	// sourced is set once the first visible key has been surfaced, and
+	// srcLevel and srcFileNum are set to the level and sstable it was read
+	// from. srcLevel is -1 and srcFileNum zero if the key was read from the
+	// batch or a memtable. See DB.GetWithProvenance.
+	sourced    bool
+	srcLevel   int
+	srcFileNum FileNum
 }
 
 // TODO(sumeer): CockroachDB code doesn't use getIter, but, for completeness,
@@ -105,6 +112,9 @@ func (g *getIter) Next() (*InternalKey, []byte) {
 						g.iterKey, g.iterValue = g.iter.Next()
 						continue
 					}
+					if !g.sourced {
+						g.recordSource()
+					}
 					return g.iterKey, g.iterValue
 				}
 			}
@@ -181,6 +191,24 @@ func (g *getIter) Next() (*InternalKey, []byte) {
 	}
 }
 
+// recordSource records the level and sstable from which the current key was
+// read.
+func (g *getIter) recordSource() {
+	g.sourced = true
+	g.srcLevel, g.srcFileNum = -1, 0
+	if g.iter != &g.levelIter {
+		return
+	}
+	// g.level is advanced past a level below L0 as its iterator is created.
+	g.srcLevel = g.level
+	if g.srcLevel > 0 {
+		g.srcLevel--
+	}
+	if f := g.levelIter.iterFile; f != nil {
+		g.srcFileNum = f.FileNum
+	}
+}
+
 func (g *getIter) Prev() (*InternalKey, []byte) {
 	panic("pebble: Prev unimplemented")
 }
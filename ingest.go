@@ -5,7 +5,9 @@
 package pebble
 
 import (
+	"fmt"
 	"sort"
+	"strings"
 	"time"
 
 	"github.com/cockroachdb/errors"
@@ -44,8 +46,51 @@ func ingestValidateKey(opts *Options, key *InternalKey) error {
 	return nil
 }
 
+// ingestValidateKeyOrder validates every point key of the sstable at path,
+// and that their user keys are strictly increasing. An sstable with duplicate
+// or out-of-order user keys violates the invariants of the LSM, so ingesting
+// it could surface the wrong value for a key or corrupt the DB.
+func ingestValidateKeyOrder(opts *Options, path string, r *sstable.Reader) error {
+	// NB: Validation reads every data block of the sstable, so avoid
+	// displacing the contents of the block cache.
+	iter, err := r.NewIterWithBlockPropertyFilters(
+		nil /* lower */, nil /* upper */, nil /* filterer */, false, /* useFilterBlock */
+		sstable.IterOptions{DisableCacheFill: true})
+	if err != nil {
+		return err
+	}
+	var prev InternalKey
+	var havePrev bool
+	for key, _ := iter.First(); key != nil; key, _ = iter.Next() {
+		if err := ingestValidateKey(opts, key); err != nil {
+			return firstError(err, iter.Close())
+		}
+		if havePrev {
+			if c := opts.Comparer.Compare(prev.UserKey, key.UserKey); c == 0 {
+				err = base.CorruptionErrorf("pebble: external sstable %s has duplicate key: %s",
+					path, key.Pretty(opts.Comparer.FormatKey))
+			} else if c > 0 {
+				err = base.CorruptionErrorf("pebble: external sstable %s has out-of-order keys: %s follows %s",
+					path, key.Pretty(opts.Comparer.FormatKey), prev.Pretty(opts.Comparer.FormatKey))
+			}
+			if err != nil {
+				return firstError(err, iter.Close())
+			}
+		}
+		prev.UserKey = append(prev.UserKey[:0], key.UserKey...)
+		prev.Trailer = key.Trailer
+		havePrev = true
+	}
+	return firstError(iter.Error(), iter.Close())
+}
+
 func ingestLoad1(
-	opts *Options, fmv FormatMajorVersion, path string, cacheID uint64, fileNum FileNum,
+	opts *Options,
+	fmv FormatMajorVersion,
+	path string,
+	cacheID uint64,
+	fileNum FileNum,
+	validateKeys bool,
 ) (*fileMetadata, error) {
 	stat, err := opts.FS.Stat(path)
 	if err != nil {
@@ -75,6 +120,9 @@ func ingestLoad1(
 			tf, fmv, fmv.MinTableFormat(), fmv.MaxTableFormat(),
 		)
 	}
+	if err := ingestCheckPropertyCollectors(opts, path, &r.Properties); err != nil {
+		return nil, err
+	}
 
 	meta := &fileMetadata{}
 	meta.FileNum = fileNum
@@ -92,6 +140,12 @@ func ingestLoad1(
 	// calculating stats before we can remove the original link.
 	maybeSetStatsFromProperties(meta, &r.Properties)
 
+	if validateKeys {
+		if err := ingestValidateKeyOrder(opts, path, r); err != nil {
+			return nil, err
+		}
+	}
+
 	{
 		iter, err := r.NewIter(nil /* lower */, nil /* upper */)
 		if err != nil {
@@ -193,13 +247,71 @@ func ingestLoad1(
 	return meta, nil
 }
 
+// ingestCheckPropertyCollectors checks that the property collectors used to
+// write an sstable match those configured for the DB, returning an error on a
+// mismatch if Options.Experimental.StrictIngestBlockProperties is set, and
+// logging the mismatch otherwise.
+func ingestCheckPropertyCollectors(opts *Options, path string, props *sstable.Properties) error {
+	used := make(map[string]bool)
+	if names := strings.Trim(props.PropertyCollectorNames, "[]"); names != "" {
+		for _, name := range strings.Split(names, ",") {
+			used[name] = true
+		}
+	}
+	configured := make(map[string]bool)
+	for i := range opts.TablePropertyCollectors {
+		configured[opts.TablePropertyCollectors[i]().Name()] = true
+	}
+	var missing []string
+	for i := range opts.BlockPropertyCollectors {
+		name := opts.BlockPropertyCollectors[i]().Name()
+		configured[name] = true
+		if _, ok := props.UserProperties[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	var unknown []string
+	for name := range used {
+		if !configured[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(missing) == 0 && len(unknown) == 0 {
+		return nil
+	}
+	sort.Strings(unknown)
+
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "pebble: ingested sstable %s", path)
+	if len(unknown) > 0 {
+		fmt.Fprintf(&buf, " has properties from unconfigured collectors [%s]", strings.Join(unknown, ","))
+	}
+	if len(missing) > 0 {
+		if len(unknown) > 0 {
+			buf.WriteString(" and")
+		}
+		fmt.Fprintf(&buf, " lacks properties of block property collectors [%s]; their filters will not"+
+			" exclude any of its blocks", strings.Join(missing, ","))
+	}
+	if opts.Experimental.StrictIngestBlockProperties {
+		return errors.New(buf.String())
+	}
+	opts.Logger.Infof("%s", buf.String())
+	return nil
+}
+
 func ingestLoad(
-	opts *Options, fmv FormatMajorVersion, paths []string, cacheID uint64, pending []FileNum,
+	opts *Options,
+	fmv FormatMajorVersion,
+	paths []string,
+	cacheID uint64,
+	pending []FileNum,
+	validateKeys bool,
 ) ([]*fileMetadata, []string, error) {
 	meta := make([]*fileMetadata, 0, len(paths))
 	newPaths := make([]string, 0, len(paths))
 	for i := range paths {
-		m, err := ingestLoad1(opts, fmv, paths[i], cacheID, pending[i])
+		m, err := ingestLoad1(opts, fmv, paths[i], cacheID, pending[i], validateKeys)
 		if err != nil {
 			return nil, nil, err
 		}
@@ -211,6 +323,53 @@ func ingestLoad(
 	return meta, newPaths, nil
 }
 
+// ingestLoadRangeDels returns the bounds of the range deletions in the
+// sstables at paths.
+func ingestLoadRangeDels(opts *Options, paths []string) ([]keyspan.Span, error) {
+	var spans []keyspan.Span
+	for _, path := range paths {
+		f, err := opts.FS.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		r, err := sstable.NewReader(f, opts.MakeReaderOptions())
+		if err != nil {
+			return nil, err
+		}
+		iter, err := r.NewRawRangeDelIter()
+		if err == nil && iter != nil {
+			for s := iter.First(); s != nil; s = iter.Next() {
+				spans = append(spans, keyspan.Span{
+					Start: append([]byte(nil), s.Start...),
+					End:   append([]byte(nil), s.End...),
+				})
+			}
+			err = firstError(iter.Error(), iter.Close())
+		}
+		if err = firstError(err, r.Close()); err != nil {
+			return nil, err
+		}
+	}
+	return spans, nil
+}
+
+// ingestCheckRangeDelOverlap returns ErrIngestRangeDelOverlap if any of the
+// given range deletion bounds contains a visible point key.
+func (d *DB) ingestCheckRangeDelOverlap(rangeDels []keyspan.Span) error {
+	for _, s := range rangeDels {
+		iter := d.NewIter(&IterOptions{LowerBound: s.Start, UpperBound: s.End})
+		overlaps := iter.First()
+		if err := iter.Close(); err != nil {
+			return err
+		}
+		if overlaps {
+			return errors.Wrapf(ErrIngestRangeDelOverlap, "range deletion [%s, %s)",
+				d.opts.Comparer.FormatKey(s.Start), d.opts.Comparer.FormatKey(s.End))
+		}
+	}
+	return nil
+}
+
 // Struct for sorting metadatas by smallest user keys, while ensuring the
 // matching path also gets swapped to the same index. For use in
 // ingestSortAndVerify.
@@ -255,7 +414,7 @@ func ingestSortAndVerify(cmp Compare, meta []*fileMetadata, paths []string) erro
 func ingestCleanup(fs vfs.FS, dirname string, meta []*fileMetadata) error {
 	var firstErr error
 	for i := range meta {
-		target := base.MakeFilepath(fs, dirname, fileTypeTable, meta[i].FileNum)
+		target := makeTableFilepath(fs, dirname, meta[i].FileNum, meta[i].Name)
 		if err := fs.Remove(target); err != nil {
 			firstErr = firstError(firstErr, err)
 		}
@@ -277,7 +436,10 @@ func ingestLink(
 	}
 
 	for i := range paths {
-		target := base.MakeFilepath(fs, dirname, fileTypeTable, meta[i].FileNum)
+		if opts.SSTablePathFunc != nil {
+			meta[i].Name = opts.SSTablePathFunc(meta[i].FileNum)
+		}
+		target := makeTableFilepath(fs, dirname, meta[i].FileNum, meta[i].Name)
 		var err error
 		if _, ok := opts.FS.(*vfs.MemFS); ok && opts.DebugCheck != nil {
 			// The combination of MemFS+Ingest+DebugCheck produces awkwardness around
@@ -317,6 +479,31 @@ func ingestLink(
 	return nil
 }
 
+// ingestMemtableOverlapBounds returns the smallest and largest user keys of the
+// tables in meta that overlap the memtable mem, which must overlap at least one
+// of them. meta must be sorted by smallest key. The returned keys are non-nil,
+// even if empty.
+func ingestMemtableOverlapBounds(
+	cmp Compare, mem flushable, meta []*fileMetadata,
+) (smallest, largest []byte) {
+	var found bool
+	for i := range meta {
+		if !ingestMemtableOverlaps(cmp, mem, meta[i:i+1]) {
+			continue
+		}
+		// NB: The empty key is a valid key, so nil can't be used to indicate
+		// that the bounds haven't been set.
+		if !found {
+			found = true
+			smallest = append([]byte{}, meta[i].Smallest.UserKey...)
+			largest = append([]byte{}, meta[i].Largest.UserKey...)
+		} else if cmp(meta[i].Largest.UserKey, largest) > 0 {
+			largest = append(largest[:0], meta[i].Largest.UserKey...)
+		}
+	}
+	return smallest, largest
+}
+
 func ingestMemtableOverlaps(cmp Compare, mem flushable, meta []*fileMetadata) bool {
 	iter := mem.newIter(nil)
 	rangeDelIter := mem.newRangeDelIter(nil)
@@ -620,7 +807,7 @@ func (d *DB) Ingest(paths []string) error {
 	if d.opts.ReadOnly {
 		return ErrReadOnly
 	}
-	_, err := d.ingest(paths, ingestTargetLevel)
+	_, err := d.ingest(paths, ingestTargetLevel, IngestOptions{})
 	return err
 }
 
@@ -635,6 +822,10 @@ type IngestOperationStats struct {
 	// be approximate once https://github.com/cockroachdb/pebble/issues/25 is
 	// implemented.
 	ApproxIngestedIntoL0Bytes uint64
+	// Compaction is the compaction of the sstables ingested into L0 out of L0
+	// requested by IngestOptions.CompactAfter. It is nil if no compaction was
+	// requested, or if no sstables were ingested into L0.
+	Compaction *IngestCompaction
 }
 
 // IngestWithStats does the same as Ingest, and additionally returns
@@ -646,11 +837,265 @@ func (d *DB) IngestWithStats(paths []string) (IngestOperationStats, error) {
 	if d.opts.ReadOnly {
 		return IngestOperationStats{}, ErrReadOnly
 	}
-	return d.ingest(paths, ingestTargetLevel)
+	return d.ingest(paths, ingestTargetLevel, IngestOptions{})
+}
+
+// IngestRangeDelPolicy controls the effect of the range deletions contained in
+// ingested sstables on the data already in the DB.
+//
+// Regardless of the policy, the range deletions in an ingested sstable never
+// delete the sstable's own point keys: all of the keys in an ingested sstable,
+// including its range deletions, are assigned the same sequence number, and a
+// range deletion only deletes keys with lower sequence numbers.
+type IngestRangeDelPolicy int8
+
+const (
+	// IngestRangeDelShadowExisting is the default policy. The range deletions
+	// in an ingested sstable are assigned the ingestion's sequence number,
+	// which is greater than that of any key already written to the DB, and so
+	// delete all existing keys within their bounds, whether in the memtables or
+	// the LSM. Keys visible to snapshots opened before the ingestion remain
+	// visible through those snapshots.
+	IngestRangeDelShadowExisting IngestRangeDelPolicy = iota
+	// IngestRangeDelFileLocal confines the range deletions in an ingested
+	// sstable to the ingested data, which they don't delete either. The
+	// ingestion fails with ErrIngestRangeDelOverlap, without modifying the DB,
+	// if any range deletion in the ingested sstables overlaps a key visible in
+	// the DB when the ingestion is sequenced. Range deletions that only
+	// overlap keys that are already deleted are allowed.
+	IngestRangeDelFileLocal
+)
+
+// ErrIngestRangeDelOverlap is returned by IngestWithOptions when using
+// IngestRangeDelFileLocal if a range deletion in an ingested sstable overlaps
+// existing data.
+var ErrIngestRangeDelOverlap = errors.New("pebble: ingested range deletion overlaps existing data")
+
+// IngestOptions hold the optional parameters for IngestWithOptions.
+type IngestOptions struct {
+	// RangeDelPolicy controls the effect of the range deletions in the
+	// ingested sstables on existing data. The default is
+	// IngestRangeDelShadowExisting.
+	RangeDelPolicy IngestRangeDelPolicy
+	// DisableKeyValidation disables the validation, before ingestion, that
+	// the point keys of each sstable have strictly increasing user keys. By
+	// default, an sstable with duplicate or out-of-order keys, which could
+	// surface the wrong value for a key or corrupt the DB if ingested, is
+	// rejected with an error identifying the offending key. The validation
+	// reads every data block of the sstables, which callers that trust the
+	// producer of their sstables may avoid by disabling it.
+	DisableKeyValidation bool
+	// CompactAfter requests that the sstables ingested into L0 be compacted
+	// out of L0 following the ingestion, so that continuous ingestion doesn't
+	// build up L0 sublevels and the read amplification they incur. The
+	// default, IngestCompactAfterNone, leaves the sstables to the automatic
+	// compactions.
+	CompactAfter IngestCompactAfter
+}
+
+// IngestCompactAfter configures the compaction of ingested sstables out of L0
+// following an ingestion. See IngestOptions.CompactAfter.
+type IngestCompactAfter int8
+
+const (
+	// IngestCompactAfterNone doesn't compact the ingested sstables.
+	IngestCompactAfterNone IngestCompactAfter = iota
+	// IngestCompactAfterSync compacts the key range spanned by the sstables
+	// ingested into L0 out of L0 before the ingestion returns. An error
+	// encountered by the compaction is returned by IngestCompaction.Wait, and
+	// not by the ingestion, which has succeeded.
+	IngestCompactAfterSync
+	// IngestCompactAfterAsync compacts the key range spanned by the sstables
+	// ingested into L0 out of L0 in the background. The compaction may be
+	// awaited through the IngestCompaction returned in IngestOperationStats.
+	IngestCompactAfterAsync
+)
+
+// IngestCompaction is a compaction out of L0 of the key range spanned by the
+// sstables ingested into L0, requested by IngestOptions.CompactAfter. It uses
+// the manual compaction machinery of DB.Compact, restricted to L0: the files
+// in L0 overlapping the key range are compacted into the base level, along
+// with the files of the base level that they overlap.
+type IngestCompaction struct {
+	// Start and End are the inclusive bounds of the key range compacted.
+	Start, End []byte
+
+	done   chan struct{}
+	result IngestCompactionResult
+	err    error
+}
+
+// IngestCompactionResult describes the state of the LSM once an
+// IngestCompaction completed.
+type IngestCompactionResult struct {
+	// L0Files and L0Sublevels are the number of files and sublevels in L0.
+	L0Files     int
+	L0Sublevels int
+	// OverlappingL0Files is the number of files in L0 overlapping the
+	// compacted key range. It is nonzero if memtables containing keys in the
+	// range were flushed, or sstables were ingested into the range, while the
+	// compaction ran.
+	OverlappingL0Files int
+	// ReadAmp is the read amplification of the LSM: the number of L0
+	// sublevels plus the number of non-empty levels below L0.
+	ReadAmp int
+}
+
+// Done returns a channel that is closed when the compaction completes.
+func (c *IngestCompaction) Done() <-chan struct{} {
+	return c.done
+}
+
+// Wait waits for the compaction to complete, and returns the state of the LSM
+// once it completed, or the error encountered by the compaction.
+func (c *IngestCompaction) Wait() (IngestCompactionResult, error) {
+	<-c.done
+	return c.result, c.err
+}
+
+// compactIngestedL0 compacts [start, end] out of L0, in the background if
+// async is true.
+func (d *DB) compactIngestedL0(start, end []byte, async bool) *IngestCompaction {
+	c := &IngestCompaction{Start: start, End: end, done: make(chan struct{})}
+	run := func() {
+		defer close(c.done)
+		if c.err = d.manualCompact(start, end, 0, false /* parallelize */, nil /* progress */); c.err != nil {
+			return
+		}
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		if d.closed.Load() != nil {
+			c.err = ErrClosed
+			return
+		}
+		v := d.mu.versions.currentVersion()
+		overlaps := v.Overlaps(0, d.cmp, start, end, false /* exclusiveEnd */)
+		c.result = IngestCompactionResult{
+			L0Files:            v.Levels[0].Len(),
+			L0Sublevels:        len(v.L0SublevelFiles),
+			OverlappingL0Files: overlaps.Len(),
+			ReadAmp:            len(v.L0SublevelFiles),
+		}
+		for level := 1; level < numLevels; level++ {
+			if !v.Levels[level].Empty() {
+				c.result.ReadAmp++
+			}
+		}
+	}
+	if !async {
+		run()
+		return c
+	}
+	d.compactionSchedulers.Add(1)
+	go func() {
+		defer d.compactionSchedulers.Done()
+		run()
+	}()
+	return c
+}
+
+// IngestWithOptions does the same as IngestWithStats, using the given
+// options.
+func (d *DB) IngestWithOptions(paths []string, opts IngestOptions) (IngestOperationStats, error) {
+	if err := d.closed.Load(); err != nil {
+		panic(err)
+	}
+	if d.opts.ReadOnly {
+		return IngestOperationStats{}, ErrReadOnly
+	}
+	return d.ingest(paths, ingestTargetLevel, opts)
+}
+
+// IngestPlacement describes where an sstable passed to IngestDryRun would be
+// placed by Ingest.
+type IngestPlacement struct {
+	// Path is the path of the sstable.
+	Path string
+	// Size is the size of the sstable in bytes.
+	Size uint64
+	// Smallest and Largest are the bounds of the sstable. The sequence numbers
+	// are those recorded in the sstable (typically zero); a real ingestion
+	// assigns them when the sstable is added to the LSM.
+	Smallest InternalKey
+	Largest  InternalKey
+	// Level is the level of the LSM the sstable would be ingested into.
+	Level int
+	// OverlapsMemtable is true if the sstable overlaps the data in a memtable.
+	// Ingesting such an sstable forces the memtable to be flushed, after which
+	// the sstable is placed in L0.
+	OverlapsMemtable bool
+}
+
+// IngestDryRun returns the placement that Ingest would currently choose for
+// each of the sstables in paths, without ingesting them or otherwise modifying
+// the DB. The sstables are validated and checked for overlap with each other
+// as Ingest does. Empty sstables, which Ingest elides, are omitted from the
+// result, and the placements are returned in order of smallest key.
+//
+// The placement is determined using the same logic as Ingest, against the
+// current state of the LSM and memtables. Flushes, compactions and other
+// ingestions that happen between the call to IngestDryRun and a subsequent
+// call to Ingest may change the placement chosen by Ingest.
+func (d *DB) IngestDryRun(paths []string) ([]IngestPlacement, error) {
+	if err := d.closed.Load(); err != nil {
+		panic(err)
+	}
+	if d.opts.ReadOnly {
+		return nil, ErrReadOnly
+	}
+
+	// Load the sstables without the DB's block cache. The blocks read by the
+	// dry run would otherwise displace the DB's cached blocks, and be cached
+	// under placeholder file numbers that a subsequent Ingest doesn't use.
+	loadOpts := *d.opts
+	loadOpts.Cache = nil
+	fileNums := make([]FileNum, len(paths))
+	for i := range fileNums {
+		fileNums[i] = FileNum(i + 1)
+	}
+	meta, paths, err := ingestLoad(&loadOpts, d.FormatMajorVersion(), paths, 0 /* cacheID */, fileNums,
+		true /* validateKeys */)
+	if err != nil {
+		return nil, err
+	}
+	if err := ingestSortAndVerify(d.cmp, meta, paths); err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	current := d.mu.versions.currentVersion()
+	baseLevel := d.mu.versions.picker.getBaseLevel()
+	iterOps := IterOptions{logger: d.opts.Logger}
+	placements := make([]IngestPlacement, len(meta))
+	for i, m := range meta {
+		p := &placements[i]
+		p.Path = paths[i]
+		p.Size = m.Size
+		p.Smallest = m.Smallest
+		p.Largest = m.Largest
+		// Ingest flushes any memtable that overlaps an sstable, and then
+		// determines the target level against the resulting version. The
+		// flushed memtable is placed in L0, where it overlaps the sstable.
+		for _, mem := range d.mu.mem.queue {
+			if ingestMemtableOverlaps(d.cmp, mem, meta[i:i+1]) {
+				p.OverlapsMemtable = true
+				break
+			}
+		}
+		if p.OverlapsMemtable {
+			continue
+		}
+		p.Level, err = ingestTargetLevel(d.newIters, iterOps, d.cmp, current, baseLevel, d.mu.compact.inProgress, m)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return placements, nil
 }
 
 func (d *DB) ingest(
-	paths []string, targetLevelFunc ingestTargetLevelFunc,
+	paths []string, targetLevelFunc ingestTargetLevelFunc, opts IngestOptions,
 ) (IngestOperationStats, error) {
 	// Allocate file numbers for all of the files being ingested and mark them as
 	// pending in order to prevent them from being deleted. Note that this causes
@@ -668,7 +1113,8 @@ func (d *DB) ingest(
 
 	// Load the metadata for all of the files being ingested. This step detects
 	// and elides empty sstables.
-	meta, paths, err := ingestLoad(d.opts, d.FormatMajorVersion(), paths, d.cacheID, pendingOutputs)
+	meta, paths, err := ingestLoad(d.opts, d.FormatMajorVersion(), paths, d.cacheID, pendingOutputs,
+		!opts.DisableKeyValidation)
 	if err != nil {
 		return IngestOperationStats{}, err
 	}
@@ -676,12 +1122,30 @@ func (d *DB) ingest(
 		// All of the sstables to be ingested were empty. Nothing to do.
 		return IngestOperationStats{}, nil
 	}
+	if d.opts.MaxDiskUsageBytes > 0 {
+		var size uint64
+		for _, m := range meta {
+			size += m.Size
+		}
+		if err := d.checkDiskUsage(size); err != nil {
+			return IngestOperationStats{}, err
+		}
+	}
 
 	// Verify the sstables do not overlap.
 	if err := ingestSortAndVerify(d.cmp, meta, paths); err != nil {
 		return IngestOperationStats{}, err
 	}
 
+	// Under IngestRangeDelFileLocal, the bounds of the range deletions are
+	// checked for overlap with existing data once the ingestion is sequenced.
+	var rangeDels []keyspan.Span
+	if opts.RangeDelPolicy == IngestRangeDelFileLocal {
+		if rangeDels, err = ingestLoadRangeDels(d.opts, paths); err != nil {
+			return IngestOperationStats{}, err
+		}
+	}
+
 	// Hard link the sstables into the DB directory. Since the sstables aren't
 	// referenced by a version, they won't be used. If the hard linking fails
 	// (e.g. because the files reside on a different filesystem), ingestLink will
@@ -699,9 +1163,17 @@ func (d *DB) ingest(
 	}
 
 	var mem *flushableEntry
+	var overlapSmallest, overlapLargest []byte
 	prepare := func() {
 		// Note that d.commit.mu is held by commitPipeline when calling prepare.
 
+		// All of the writes sequenced before the ingestion are visible, and
+		// no writes sequenced after it are, so the check for overlap of range
+		// deletions with existing data is exact.
+		if err = d.ingestCheckRangeDelOverlap(rangeDels); err != nil {
+			return
+		}
+
 		d.mu.Lock()
 		defer d.mu.Unlock()
 
@@ -713,6 +1185,7 @@ func (d *DB) ingest(
 			m := d.mu.mem.queue[i]
 			if ingestMemtableOverlaps(d.cmp, m, meta) {
 				mem = m
+				overlapSmallest, overlapLargest = ingestMemtableOverlapBounds(d.cmp, m, meta)
 				if mem.flushable == d.mu.mem.mutable {
 					err = d.makeRoomForWrite(nil)
 				}
@@ -766,11 +1239,16 @@ func (d *DB) ingest(
 	}
 
 	info := TableIngestInfo{
-		JobID:        jobID,
-		GlobalSeqNum: meta[0].SmallestSeqNum,
-		Err:          err,
+		JobID:           jobID,
+		GlobalSeqNum:    meta[0].SmallestSeqNum,
+		MemtableOverlap: mem != nil,
+		OverlapSmallest: overlapSmallest,
+		OverlapLargest:  overlapLargest,
+		Err:             err,
 	}
 	var stats IngestOperationStats
+	var ingestedIntoL0 bool
+	var l0Smallest, l0Largest []byte
 	if ve != nil {
 		info.Tables = make([]struct {
 			TableInfo
@@ -783,11 +1261,24 @@ func (d *DB) ingest(
 			stats.Bytes += e.Meta.Size
 			if e.Level == 0 {
 				stats.ApproxIngestedIntoL0Bytes += e.Meta.Size
+				// NB: The empty key is a valid key, so nil can't be used to
+				// indicate that the bounds haven't been set.
+				if !ingestedIntoL0 || d.cmp(e.Meta.Smallest.UserKey, l0Smallest) < 0 {
+					l0Smallest = e.Meta.Smallest.UserKey
+				}
+				if !ingestedIntoL0 || d.cmp(e.Meta.Largest.UserKey, l0Largest) > 0 {
+					l0Largest = e.Meta.Largest.UserKey
+				}
+				ingestedIntoL0 = true
 			}
 		}
 	}
 	d.opts.EventListener.TableIngested(info)
 
+	if err == nil && ingestedIntoL0 && opts.CompactAfter != IngestCompactAfterNone {
+		stats.Compaction = d.compactIngestedL0(l0Smallest, l0Largest,
+			opts.CompactAfter == IngestCompactAfterAsync)
+	}
 	return stats, err
 }
 
@@ -22,7 +22,10 @@ import (
 	"github.com/cockroachdb/pebble/internal/datadriven"
 	"github.com/cockroachdb/pebble/internal/errorfs"
 	"github.com/cockroachdb/pebble/internal/keyspan"
+	"github.com/cockroachdb/pebble/internal/private"
 	"github.com/cockroachdb/pebble/internal/rangekey"
+	"github.com/cockroachdb/pebble/internal/testkeys"
+	"github.com/cockroachdb/pebble/internal/testkeys/blockprop"
 	"github.com/cockroachdb/pebble/sstable"
 	"github.com/cockroachdb/pebble/vfs"
 	"github.com/kr/pretty"
@@ -85,7 +88,7 @@ func TestIngestLoad(t *testing.T) {
 				Comparer: DefaultComparer,
 				FS:       mem,
 			}
-			meta, _, err := ingestLoad(opts, dbVersion, []string{"ext"}, 0, []FileNum{1})
+			meta, _, err := ingestLoad(opts, dbVersion, []string{"ext"}, 0, []FileNum{1}, true /* validateKeys */)
 			if err != nil {
 				return err.Error()
 			}
@@ -171,7 +174,7 @@ func TestIngestLoadRand(t *testing.T) {
 		Comparer: DefaultComparer,
 		FS:       mem,
 	}
-	meta, _, err := ingestLoad(opts, version, paths, 0, pending)
+	meta, _, err := ingestLoad(opts, version, paths, 0, pending, true /* validateKeys */)
 	require.NoError(t, err)
 
 	for _, m := range meta {
@@ -192,11 +195,143 @@ func TestIngestLoadInvalid(t *testing.T) {
 		Comparer: DefaultComparer,
 		FS:       mem,
 	}
-	if _, _, err := ingestLoad(opts, FormatNewest, []string{"invalid"}, 0, []FileNum{1}); err == nil {
+	if _, _, err := ingestLoad(opts, FormatNewest, []string{"invalid"}, 0, []FileNum{1}, true /* validateKeys */); err == nil {
 		t.Fatalf("expected error, but found success")
 	}
 }
 
+func TestIngestLoadKeyValidation(t *testing.T) {
+	writeTable := func(fs vfs.FS, path string, keys ...string) {
+		f, err := fs.Create(path)
+		require.NoError(t, err)
+		w := sstable.NewWriter(f, sstable.WriterOptions{
+			TableFormat: FormatNewest.MaxTableFormat(),
+		})
+		private.SSTableWriterDisableKeyOrderChecks(w)
+		for _, k := range keys {
+			require.NoError(t, w.Set([]byte(k), nil))
+		}
+		require.NoError(t, w.Close())
+	}
+
+	testCases := []struct {
+		keys        []string
+		expectedErr string
+	}{
+		{keys: []string{"a", "b", "c"}},
+		{
+			keys:        []string{"a", "b", "b", "c"},
+			expectedErr: "pebble: external sstable ext has duplicate key: b#0,SET",
+		},
+		{
+			keys:        []string{"a", "c", "b"},
+			expectedErr: "pebble: external sstable ext has out-of-order keys: b#0,SET follows c#0,SET",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(strings.Join(tc.keys, ","), func(t *testing.T) {
+			mem := vfs.NewMem()
+			writeTable(mem, "ext", tc.keys...)
+			opts := &Options{
+				Comparer: DefaultComparer,
+				FS:       mem,
+			}
+			_, _, err := ingestLoad(opts, FormatNewest, []string{"ext"}, 0, []FileNum{1}, true /* validateKeys */)
+			if tc.expectedErr == "" {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, tc.expectedErr)
+				require.True(t, errors.Is(err, base.ErrCorruption))
+			}
+
+			// With validation disabled, the keys are not inspected.
+			_, _, err = ingestLoad(opts, FormatNewest, []string{"ext"}, 0, []FileNum{1}, false /* validateKeys */)
+			require.NoError(t, err)
+		})
+	}
+
+	t.Run("ingest", func(t *testing.T) {
+		mem := vfs.NewMem()
+		d, err := Open("", &Options{FS: mem, FormatMajorVersion: FormatNewest})
+		require.NoError(t, err)
+		defer func() { require.NoError(t, d.Close()) }()
+
+		writeTable(mem, "ext", "a", "a")
+		err = d.Ingest([]string{"ext"})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "duplicate key: a#0,SET")
+		_, closer, err := d.Get([]byte("a"))
+		require.ErrorIs(t, err, ErrNotFound)
+		if closer != nil {
+			closer.Close()
+		}
+
+		_, err = d.IngestWithOptions([]string{"ext"}, IngestOptions{DisableKeyValidation: true})
+		require.NoError(t, err)
+		_, closer, err = d.Get([]byte("a"))
+		require.NoError(t, err)
+		require.NoError(t, closer.Close())
+	})
+}
+
+func TestIngestPropertyCollectorCheck(t *testing.T) {
+	collectors := []func() BlockPropertyCollector{blockprop.NewBlockPropertyCollector}
+	for _, tc := range []struct {
+		db, sstable []func() BlockPropertyCollector
+		expected    string
+	}{
+		{db: collectors, sstable: collectors},
+		{db: nil, sstable: nil},
+		{
+			db:       collectors,
+			sstable:  nil,
+			expected: `lacks properties of block property collectors \[pebble.internal.testkeys.suffixes\]`,
+		},
+		{
+			db:       nil,
+			sstable:  collectors,
+			expected: `has properties from unconfigured collectors \[pebble.internal.testkeys.suffixes\]`,
+		},
+	} {
+		for _, strict := range []bool{false, true} {
+			var log syncedBuffer
+			opts := &Options{
+				FS:                      vfs.NewMem(),
+				Comparer:                testkeys.Comparer,
+				BlockPropertyCollectors: tc.db,
+				FormatMajorVersion:      FormatNewest,
+				Logger:                  &log,
+			}
+			opts.Experimental.StrictIngestBlockProperties = strict
+			d, err := Open("", opts)
+			require.NoError(t, err)
+
+			f, err := opts.FS.Create("ext")
+			require.NoError(t, err)
+			w := sstable.NewWriter(f, sstable.WriterOptions{
+				Comparer:                testkeys.Comparer,
+				BlockPropertyCollectors: tc.sstable,
+				TableFormat:             d.FormatMajorVersion().MaxTableFormat(),
+			})
+			require.NoError(t, w.Set([]byte("a@1"), nil))
+			require.NoError(t, w.Close())
+
+			err = d.Ingest([]string{"ext"})
+			switch {
+			case tc.expected == "":
+				require.NoError(t, err)
+				require.NotContains(t, log.String(), "ingested sstable")
+			case strict:
+				require.Regexp(t, tc.expected, err)
+			default:
+				require.NoError(t, err)
+				require.Regexp(t, tc.expected, log.String())
+			}
+			require.NoError(t, d.Close())
+		}
+	}
+}
+
 func TestIngestSortAndVerify(t *testing.T) {
 	comparers := map[string]Compare{
 		"default": DefaultComparer.Compare,
@@ -640,6 +775,37 @@ func TestIngest(t *testing.T) {
 			}
 			return ""
 
+		case "ingest-dry-run":
+			var paths []string
+			for _, arg := range td.CmdArgs {
+				paths = append(paths, arg.String())
+			}
+			d.mu.Lock()
+			d.waitTableStats()
+			d.mu.Unlock()
+			cacheBefore := d.opts.Cache.Metrics()
+			placements, err := d.IngestDryRun(paths)
+			if err != nil {
+				return err.Error()
+			}
+			// The sstables loaded by the dry run must not be added to the block
+			// cache. The overlap checks read the DB's own sstables, whose blocks
+			// are already cached by the preceding commands.
+			if m := d.opts.Cache.Metrics(); m.Count != cacheBefore.Count || m.Size != cacheBefore.Size {
+				return fmt.Sprintf("block cache changed: %d blocks (%d bytes) -> %d blocks (%d bytes)",
+					cacheBefore.Count, cacheBefore.Size, m.Count, m.Size)
+			}
+			var buf strings.Builder
+			for _, p := range placements {
+				fmt.Fprintf(&buf, "%s: L%d [%s-%s]", p.Path, p.Level,
+					p.Smallest.Pretty(DefaultComparer.FormatKey), p.Largest.Pretty(DefaultComparer.FormatKey))
+				if p.OverlapsMemtable {
+					buf.WriteString(" overlaps memtable")
+				}
+				buf.WriteString("\n")
+			}
+			return buf.String()
+
 		case "get":
 			return runGetCmd(td, d)
 
@@ -1043,6 +1209,134 @@ func TestIngestStats(t *testing.T) {
 	require.NoError(t, d.Close())
 }
 
+func TestIngestMemtableOverlapEvent(t *testing.T) {
+	mem := vfs.NewMem()
+	var infos []TableIngestInfo
+	d, err := Open("", &Options{
+		FS: mem,
+		EventListener: EventListener{
+			TableIngested: func(info TableIngestInfo) {
+				infos = append(infos, info)
+			},
+		},
+	})
+	require.NoError(t, err)
+	defer func() { require.NoError(t, d.Close()) }()
+
+	ingest := func(tables ...[]string) TableIngestInfo {
+		t.Helper()
+		var paths []string
+		for i, keys := range tables {
+			path := fmt.Sprintf("ext%d", i)
+			f, err := mem.Create(path)
+			require.NoError(t, err)
+			w := sstable.NewWriter(f, sstable.WriterOptions{})
+			for _, k := range keys {
+				require.NoError(t, w.Set([]byte(k), nil))
+			}
+			require.NoError(t, w.Close())
+			paths = append(paths, path)
+		}
+		require.NoError(t, d.Ingest(paths))
+		return infos[len(infos)-1]
+	}
+
+	// Only the bounds of the tables that overlap the memtable are reported.
+	require.NoError(t, d.Set([]byte("c"), nil, nil))
+	require.NoError(t, d.Set([]byte("m"), nil, nil))
+	info := ingest([]string{"a", "b"}, []string{"c", "d"}, []string{"k", "n"}, []string{"x", "z"})
+	require.True(t, info.MemtableOverlap)
+	require.Equal(t, "c", string(info.OverlapSmallest))
+	require.Equal(t, "n", string(info.OverlapLargest))
+	require.Contains(t, info.String(), "flushed overlapping memtable")
+
+	// The memtable was flushed, so ingesting over the same keys again does
+	// not overlap a memtable.
+	info = ingest([]string{"c", "d"})
+	require.False(t, info.MemtableOverlap)
+	require.Nil(t, info.OverlapSmallest)
+	require.NotContains(t, info.String(), "flushed overlapping memtable")
+
+	// The empty key is the smallest key.
+	require.NoError(t, d.Set([]byte(""), nil, nil))
+	require.NoError(t, d.Set([]byte("m"), nil, nil))
+	info = ingest([]string{"", "b"}, []string{"k", "n"})
+	require.True(t, info.MemtableOverlap)
+	require.NotNil(t, info.OverlapSmallest)
+	require.Empty(t, info.OverlapSmallest)
+	require.Equal(t, "n", string(info.OverlapLargest))
+}
+
+func TestIngestRangeDelPolicy(t *testing.T) {
+	mem := vfs.NewMem()
+	d, err := Open("", &Options{FS: mem})
+	require.NoError(t, err)
+	defer func() { require.NoError(t, d.Close()) }()
+
+	// ingest writes an sstable containing a range deletion over [start, end)
+	// and a set of each of the given keys and ingests it.
+	ingest := func(policy IngestRangeDelPolicy, start, end string, keys ...string) error {
+		t.Helper()
+		f, err := mem.Create("ext")
+		require.NoError(t, err)
+		w := sstable.NewWriter(f, sstable.WriterOptions{})
+		require.NoError(t, w.DeleteRange([]byte(start), []byte(end)))
+		for _, k := range keys {
+			require.NoError(t, w.Set([]byte(k), []byte("ingested")))
+		}
+		require.NoError(t, w.Close())
+		_, err = d.IngestWithOptions([]string{"ext"}, IngestOptions{RangeDelPolicy: policy})
+		return err
+	}
+	get := func(r Reader, key string) string {
+		t.Helper()
+		v, closer, err := r.Get([]byte(key))
+		if errors.Is(err, ErrNotFound) {
+			return ""
+		}
+		require.NoError(t, err)
+		defer closer.Close()
+		return string(v)
+	}
+
+	// By default, the ingested range deletion shadows existing keys, both in
+	// the memtable and in the LSM, but not the keys of the ingested sstable
+	// itself. Snapshots still observe the shadowed keys.
+	require.NoError(t, d.Set([]byte("a"), []byte("lsm"), nil))
+	require.NoError(t, d.Flush())
+	require.NoError(t, d.Set([]byte("b"), []byte("mem"), nil))
+	snap := d.NewSnapshot()
+	require.NoError(t, ingest(IngestRangeDelShadowExisting, "a", "d", "c"))
+	require.Equal(t, "", get(d, "a"))
+	require.Equal(t, "", get(d, "b"))
+	require.Equal(t, "ingested", get(d, "c"))
+	require.Equal(t, "lsm", get(snap, "a"))
+	require.Equal(t, "mem", get(snap, "b"))
+	require.NoError(t, snap.Close())
+
+	// Under IngestRangeDelFileLocal, a range deletion overlapping a visible
+	// key fails the ingestion and leaves the DB unmodified, whether the key
+	// is in the memtable or in the LSM.
+	require.NoError(t, d.Set([]byte("f"), []byte("mem"), nil))
+	err = ingest(IngestRangeDelFileLocal, "e", "h", "g")
+	require.True(t, errors.Is(err, ErrIngestRangeDelOverlap), "%v", err)
+	require.Equal(t, "mem", get(d, "f"))
+	require.Equal(t, "", get(d, "g"))
+	require.NoError(t, d.Flush())
+	err = ingest(IngestRangeDelFileLocal, "e", "h", "g")
+	require.True(t, errors.Is(err, ErrIngestRangeDelOverlap), "%v", err)
+	require.Equal(t, "mem", get(d, "f"))
+	require.Equal(t, "", get(d, "g"))
+
+	// A range deletion that only overlaps deleted keys, or no keys at all,
+	// is allowed, and doesn't delete the ingested keys.
+	require.NoError(t, d.Delete([]byte("f"), nil))
+	require.NoError(t, ingest(IngestRangeDelFileLocal, "e", "h", "g"))
+	require.Equal(t, "ingested", get(d, "g"))
+	require.NoError(t, ingest(IngestRangeDelFileLocal, "x", "z", "y"))
+	require.Equal(t, "ingested", get(d, "y"))
+}
+
 func TestIngestFlushQueuedLargeBatch(t *testing.T) {
 	// Verify that ingestion forces a flush of a queued large batch.
 
@@ -1625,8 +1919,12 @@ func TestIngestValidation(t *testing.T) {
 				_, err = vfs.Clone(tmpFS, fs, ingestTableName, ingestTableName)
 				require.NoError(t, err)
 
-				// Ingest the external table.
-				err = d.Ingest([]string{ingestTableName})
+				// Ingest the external table. Key validation during ingestion
+				// reads every data block, and would detect the corruption
+				// before the asynchronous validation under test.
+				_, err = d.IngestWithOptions([]string{ingestTableName}, IngestOptions{
+					DisableKeyValidation: true,
+				})
 				if err != nil {
 					et.errLoc = errLocationIngest
 					et.err = err
@@ -1766,3 +2064,113 @@ func runBenchmarkManySSTablesInUseKeyRanges(b *testing.B, d *DB, count int) {
 		_ = calculateInuseKeyRanges(v, d.cmp, 0, numLevels-1, smallest, largest)
 	}
 }
+
+func TestIngestUserProperties(t *testing.T) {
+	mem := vfs.NewMem()
+	d, err := Open("", &Options{FS: mem, FormatMajorVersion: FormatNewest})
+	require.NoError(t, err)
+	defer func() { require.NoError(t, d.Close()) }()
+
+	f, err := mem.Create("ext")
+	require.NoError(t, err)
+	w := sstable.NewWriter(f, sstable.WriterOptions{
+		TableFormat:    d.FormatMajorVersion().MaxTableFormat(),
+		UserProperties: map[string]string{"producer": "importer-7"},
+	})
+	require.NoError(t, w.Set([]byte("a"), []byte("1")))
+	require.NoError(t, w.Close())
+	require.NoError(t, d.Ingest([]string{"ext"}))
+
+	userProps := func() []string {
+		tables, err := d.SSTables(WithProperties())
+		require.NoError(t, err)
+		var props []string
+		for _, level := range tables {
+			for _, table := range level {
+				if v, ok := table.Properties.UserProperties["producer"]; ok {
+					props = append(props, v)
+				}
+			}
+		}
+		return props
+	}
+	require.Equal(t, []string{"importer-7"}, userProps())
+
+	// Rewriting the sstable in a compaction drops the user properties.
+	require.NoError(t, d.Set([]byte("a"), []byte("2"), nil))
+	require.NoError(t, d.Compact([]byte("a"), []byte("c"), false /* parallelize */))
+	require.Empty(t, userProps())
+}
+
+func TestIngestCompactAfter(t *testing.T) {
+	mem := vfs.NewMem()
+	d, err := Open("", &Options{
+		FS:                          mem,
+		FormatMajorVersion:          FormatNewest,
+		DisableAutomaticCompactions: true,
+	})
+	require.NoError(t, err)
+	defer func() { require.NoError(t, d.Close()) }()
+
+	var fileNum int
+	ingest := func(compactAfter IngestCompactAfter, keys ...string) IngestOperationStats {
+		fileNum++
+		path := fmt.Sprintf("ext%d", fileNum)
+		f, err := mem.Create(path)
+		require.NoError(t, err)
+		w := sstable.NewWriter(f, sstable.WriterOptions{
+			TableFormat: d.FormatMajorVersion().MaxTableFormat(),
+		})
+		for _, k := range keys {
+			require.NoError(t, w.Set([]byte(k), []byte(path)))
+		}
+		require.NoError(t, w.Close())
+		stats, err := d.IngestWithOptions([]string{path}, IngestOptions{CompactAfter: compactAfter})
+		require.NoError(t, err)
+		return stats
+	}
+	flush := func(keys ...string) {
+		for _, k := range keys {
+			require.NoError(t, d.Set([]byte(k), nil, nil))
+		}
+		require.NoError(t, d.Flush())
+	}
+	l0Files := func() int {
+		return int(d.Metrics().Levels[0].NumFiles)
+	}
+
+	// An sstable overlapping L0 is ingested into L0, where it's left by
+	// default.
+	flush("a", "b", "c", "d", "z")
+	stats := ingest(IngestCompactAfterNone, "b", "c")
+	require.Nil(t, stats.Compaction)
+	require.Equal(t, 2, l0Files())
+
+	// The ingested key range is compacted out of L0, along with the files in
+	// L0 that it overlaps.
+	stats = ingest(IngestCompactAfterSync, "c", "d")
+	require.NotNil(t, stats.Compaction)
+	require.Equal(t, "c", string(stats.Compaction.Start))
+	require.Equal(t, "d", string(stats.Compaction.End))
+	select {
+	case <-stats.Compaction.Done():
+	default:
+		t.Fatal("synchronous compaction has not completed")
+	}
+	result, err := stats.Compaction.Wait()
+	require.NoError(t, err)
+	require.Equal(t, IngestCompactionResult{ReadAmp: 1}, result)
+	require.Zero(t, l0Files())
+
+	flush("n")
+	stats = ingest(IngestCompactAfterAsync, "n", "o")
+	require.NotNil(t, stats.Compaction)
+	result, err = stats.Compaction.Wait()
+	require.NoError(t, err)
+	require.Equal(t, IngestCompactionResult{ReadAmp: 1}, result)
+	require.Zero(t, l0Files())
+
+	// No compaction is necessary if the ingested sstables bypass L0.
+	stats = ingest(IngestCompactAfterSync, "zz")
+	require.Nil(t, stats.Compaction)
+}
@@ -4,13 +4,27 @@
 
 package base
 
-import "github.com/cockroachdb/pebble/vfs"
+import (
+	"github.com/cockroachdb/errors"
+	"github.com/cockroachdb/pebble/vfs"
+)
 
 // Cleaner cleans obsolete files.
 type Cleaner interface {
 	Clean(fs vfs.FS, fileType FileType, path string) error
 }
 
+// AsyncCleaner is implemented by a cleaner that may finish cleaning a file
+// after returning, e.g. once the file has been uploaded elsewhere. The DB
+// uses CleanAsync rather than Clean for such cleaners.
+type AsyncCleaner interface {
+	Cleaner
+	// CleanAsync cleans the file, and calls done once it has finished with
+	// the file, either before or after returning. done must be called exactly
+	// once, and may be called from any goroutine.
+	CleanAsync(fs vfs.FS, fileType FileType, path string, done func(error))
+}
+
 // NeedsFileContents is implemented by a cleaner that needs the contents of the
 // files that it is being asked to clean.
 type NeedsFileContents interface {
@@ -58,3 +72,99 @@ func (ArchiveCleaner) String() string {
 
 func (ArchiveCleaner) needsFileContents() {
 }
+
+// CleanAction is the action taken by a PolicyCleaner on an obsolete file.
+type CleanAction int8
+
+const (
+	// CleanActionDelete deletes the file.
+	CleanActionDelete CleanAction = iota
+	// CleanActionArchive moves the file into the archive directory.
+	CleanActionArchive
+	// CleanActionHandOff hands the file off to PolicyCleaner.HandOff.
+	CleanActionHandOff
+)
+
+// String implements fmt.Stringer.
+func (a CleanAction) String() string {
+	switch a {
+	case CleanActionDelete:
+		return "delete"
+	case CleanActionArchive:
+		return "archive"
+	case CleanActionHandOff:
+		return "hand-off"
+	default:
+		panic(errors.AssertionFailedf("unknown clean action %d", a))
+	}
+}
+
+// PolicyCleaner chooses, for each obsolete file, whether to delete it, move
+// it into an archive directory, or hand it off to a callback, such as one
+// that uploads the file to object storage before removing it.
+type PolicyCleaner struct {
+	// Policy returns the action to take on an obsolete file. If nil, every
+	// file is deleted.
+	Policy func(fileType FileType, path string) CleanAction
+	// ArchiveDir is the directory into which archived files are moved, which
+	// is created if it doesn't exist. If empty, files are moved into the
+	// "archive" subdirectory of their directory, as ArchiveCleaner does.
+	ArchiveDir string
+	// HandOff is invoked with the files for which Policy returns
+	// CleanActionHandOff, and takes ownership of the file: it's responsible
+	// for removing the file from fs once it's done with it. HandOff may
+	// return before it's done with the file, and must call done exactly once
+	// when it is, with the error, if any, that prevented handling the file.
+	// It must be set if Policy may return CleanActionHandOff.
+	HandOff func(fs vfs.FS, fileType FileType, path string, done func(error))
+}
+
+var _ AsyncCleaner = PolicyCleaner{}
+var _ NeedsFileContents = PolicyCleaner{}
+
+// Clean cleans the file, waiting for a hand-off to complete.
+func (c PolicyCleaner) Clean(fs vfs.FS, fileType FileType, path string) error {
+	ch := make(chan error, 1)
+	c.CleanAsync(fs, fileType, path, func(err error) { ch <- err })
+	return <-ch
+}
+
+// CleanAsync implements AsyncCleaner. Deletions and archivals complete before
+// CleanAsync returns, while hand-offs complete when HandOff calls done.
+func (c PolicyCleaner) CleanAsync(
+	fs vfs.FS, fileType FileType, path string, done func(error),
+) {
+	action := CleanActionDelete
+	if c.Policy != nil {
+		action = c.Policy(fileType, path)
+	}
+	switch action {
+	case CleanActionDelete:
+		done(fs.Remove(path))
+	case CleanActionArchive:
+		destDir := c.ArchiveDir
+		if destDir == "" {
+			destDir = fs.PathJoin(fs.PathDir(path), "archive")
+		}
+		if err := fs.MkdirAll(destDir, 0755); err != nil {
+			done(err)
+			return
+		}
+		done(fs.Rename(path, fs.PathJoin(destDir, fs.PathBase(path))))
+	case CleanActionHandOff:
+		if c.HandOff == nil {
+			done(errors.AssertionFailedf("pebble: PolicyCleaner.HandOff is nil"))
+			return
+		}
+		c.HandOff(fs, fileType, path, done)
+	default:
+		done(errors.AssertionFailedf("pebble: unknown clean action %d", action))
+	}
+}
+
+func (PolicyCleaner) String() string {
+	return "policy"
+}
+
+func (PolicyCleaner) needsFileContents() {
+}
@@ -9,6 +9,7 @@ import (
 	"encoding/binary"
 	"fmt"
 	"strconv"
+	"sync"
 	"unicode/utf8"
 )
 
@@ -113,12 +114,163 @@ type Comparer struct {
 	Split          Split
 	Successor      Successor
 
+	// PrefixLen, if positive, declares that all keys at least PrefixLen bytes
+	// long are ordered by their first PrefixLen bytes, compared bytewise,
+	// before the rest of the key. That is, for any keys a and b of at least
+	// PrefixLen bytes whose first PrefixLen bytes differ, Compare(a, b) equals
+	// bytes.Compare(a[:PrefixLen], b[:PrefixLen]), and if their first
+	// PrefixLen bytes are equal, Compare(a, b) equals
+	// Compare(a[PrefixLen:], b[PrefixLen:]). Keys shorter than PrefixLen are
+	// permitted, and are always ordered by Compare.
+	//
+	// The hint allows Pebble to order most pairs of keys with a word-wise
+	// comparison of their prefixes, only calling Compare on the remainder of
+	// the keys when the prefixes are equal. It is a performance optimization, and must not change the
+	// ordering defined by Compare.
+	PrefixLen int
+
+	// Normalize, if non-nil, appends to dst the form of key by which it is
+	// ordered, and returns the extended buffer. It allows keys to be ordered by
+	// a transformation of their bytes, such as a case-insensitive ordering,
+	// without rewriting them. Keys are stored and returned with their original
+	// bytes, but every comparison of keys, including against iterator bounds,
+	// is performed by the functions above on the normalized keys. Keys with
+	// the same normalized form are the same key: a Set of "Apple" overwrites
+	// an earlier Set of "apple", and a Get of either returns the newer value.
+	//
+	// Normalize is called on every comparison of keys. It must be
+	// deterministic, and must not allocate other than to grow dst: keys are
+	// normalized into buffers that are reused across comparisons, so that
+	// comparisons don't allocate once the buffers have grown to the size of
+	// the keys. If Split is set, Normalize must preserve the length of keys,
+	// and normalizing the prefix of a key must yield the prefix of the
+	// normalized key, so that the bloom filters of prefixes remain consistent
+	// with the ordering. PrefixLen is ignored if Normalize is set.
+	//
+	// Normalize determines the order of the keys persisted in a database, and
+	// must not change over the lifetime of the database. Opening an existing
+	// database with a different Normalize corrupts it, as keys are no longer
+	// found where the new ordering expects them. Unlike the comparison
+	// functions, Normalize is not applied to the comparer's Name, so a change
+	// to Normalize should be accompanied by a change of Name, which causes
+	// opening a database created with the previous Name to fail.
+	Normalize func(dst, key []byte) []byte
+
 	// Name is the name of the comparer.
 	//
 	// The Level-DB on-disk format stores the comparer name, and opening a
 	// database with a different comparer from the one it was created with
 	// will result in an error.
 	Name string
+
+	// normalized is set on the comparers returned by NormalizedComparer, whose
+	// functions apply Normalize.
+	normalized bool
+}
+
+// NormalizedComparer returns a Comparer whose comparison functions apply
+// c.Normalize to keys before comparing them. See Comparer.Normalize. It
+// returns c if c.Normalize is nil, or if c was returned by
+// NormalizedComparer.
+func NormalizedComparer(c *Comparer) *Comparer {
+	if c.Normalize == nil || c.normalized {
+		return c
+	}
+	n := c.Normalize
+	cmp := c.Compare
+	nc := *c
+	nc.normalized = true
+	nc.PrefixLen = 0
+	nc.Compare = func(a, b []byte) int {
+		bufs := getNormalizeBufs()
+		bufs[0] = n(bufs[0][:0], a)
+		bufs[1] = n(bufs[1][:0], b)
+		v := cmp(bufs[0], bufs[1])
+		normalizeBufsPool.Put(bufs)
+		return v
+	}
+	eq := c.Equal
+	if eq == nil {
+		eq = func(a, b []byte) bool { return cmp(a, b) == 0 }
+	}
+	nc.Equal = func(a, b []byte) bool {
+		bufs := getNormalizeBufs()
+		bufs[0] = n(bufs[0][:0], a)
+		bufs[1] = n(bufs[1][:0], b)
+		v := eq(bufs[0], bufs[1])
+		normalizeBufsPool.Put(bufs)
+		return v
+	}
+	if abbr := c.AbbreviatedKey; abbr != nil {
+		nc.AbbreviatedKey = func(key []byte) uint64 {
+			bufs := getNormalizeBufs()
+			bufs[0] = n(bufs[0][:0], key)
+			v := abbr(bufs[0])
+			normalizeBufsPool.Put(bufs)
+			return v
+		}
+	}
+	// The separators and successors of normalized keys are ordered correctly
+	// relative to the normalized keys, but need not be once normalized
+	// themselves. Fall back to the trivial separator or successor, a, if not.
+	if sep := c.Separator; sep != nil {
+		nc.Separator = func(dst, a, b []byte) []byte {
+			bufs := getNormalizeBufs()
+			defer normalizeBufsPool.Put(bufs)
+			bufs[0] = n(bufs[0][:0], a)
+			na := bufs[0]
+			var nb []byte
+			if b != nil {
+				bufs[1] = n(bufs[1][:0], b)
+				nb = bufs[1]
+			}
+			k := sep(dst, na, nb)
+			bufs[2] = n(bufs[2][:0], k[len(dst):])
+			nk := bufs[2]
+			if cmp(na, nk) > 0 || (b != nil && cmp(nk, nb) >= 0) {
+				return append(k[:len(dst)], a...)
+			}
+			return k
+		}
+	}
+	if succ := c.Successor; succ != nil {
+		nc.Successor = func(dst, a []byte) []byte {
+			bufs := getNormalizeBufs()
+			defer normalizeBufsPool.Put(bufs)
+			bufs[0] = n(bufs[0][:0], a)
+			na := bufs[0]
+			k := succ(dst, na)
+			bufs[1] = n(bufs[1][:0], k[len(dst):])
+			if cmp(bufs[1], na) < 0 {
+				return append(k[:len(dst)], a...)
+			}
+			return k
+		}
+	}
+	if split := c.Split; split != nil {
+		nc.Split = func(a []byte) int {
+			bufs := getNormalizeBufs()
+			bufs[0] = n(bufs[0][:0], a)
+			v := split(bufs[0])
+			normalizeBufsPool.Put(bufs)
+			return v
+		}
+	}
+	return &nc
+}
+
+// normalizeBufs holds the buffers into which the comparers returned by
+// NormalizedComparer normalize keys.
+type normalizeBufs [3][]byte
+
+var normalizeBufsPool = sync.Pool{
+	New: func() interface{} {
+		return &normalizeBufs{}
+	},
+}
+
+func getNormalizeBufs() *normalizeBufs {
+	return normalizeBufsPool.Get().(*normalizeBufs)
 }
 
 // DefaultFormatter is the default implementation of user key formatting:
@@ -198,6 +350,36 @@ var DefaultComparer = &Comparer{
 	Name: "leveldb.BytewiseComparator",
 }
 
+// FixedPrefixCompare returns a Compare that orders keys of at least prefixLen
+// bytes by a word-wise comparison of their first prefixLen bytes, falling back
+// to cmp on the remainder of the keys when the prefixes are equal, and to cmp
+// on the entire keys when either key is shorter than prefixLen. See
+// Comparer.PrefixLen. If prefixLen is not positive, cmp is returned as is.
+func FixedPrefixCompare(cmp Compare, prefixLen int) Compare {
+	if prefixLen <= 0 {
+		return cmp
+	}
+	return func(a, b []byte) int {
+		if len(a) < prefixLen || len(b) < prefixLen {
+			return cmp(a, b)
+		}
+		i := 0
+		for ; i+8 <= prefixLen; i += 8 {
+			x, y := binary.BigEndian.Uint64(a[i:]), binary.BigEndian.Uint64(b[i:])
+			if x != y {
+				if x < y {
+					return -1
+				}
+				return +1
+			}
+		}
+		if c := bytes.Compare(a[i:prefixLen], b[i:prefixLen]); c != 0 {
+			return c
+		}
+		return cmp(a[prefixLen:], b[prefixLen:])
+	}
+}
+
 // SharedPrefixLen returns the largest i such that a[:i] equals b[:i].
 // This function can be useful in implementing the Comparer interface.
 func SharedPrefixLen(a, b []byte) int {
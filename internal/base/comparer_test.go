@@ -5,11 +5,14 @@
 package base
 
 import (
+	"encoding/binary"
 	"fmt"
 	"sort"
 	"testing"
 	"time"
 
+	"github.com/cockroachdb/pebble/internal/invariants"
+	"github.com/stretchr/testify/require"
 	"golang.org/x/exp/rand"
 )
 
@@ -91,6 +94,79 @@ func TestAbbreviatedKey(t *testing.T) {
 	}
 }
 
+func TestFixedPrefixCompare(t *testing.T) {
+	rng := rand.New(rand.NewSource(uint64(time.Now().UnixNano())))
+	randBytes := func(size int) []byte {
+		data := make([]byte, size)
+		for i := range data {
+			// A small alphabet makes equal prefixes common.
+			data[i] = byte(rng.Intn(3))
+		}
+		return data
+	}
+	for _, prefixLen := range []int{0, 1, 5, 8, 12, 16} {
+		cmp := FixedPrefixCompare(DefaultComparer.Compare, prefixLen)
+		for i := 0; i < 10000; i++ {
+			// Keys may be shorter than the prefix length.
+			a, b := randBytes(rng.Intn(20)), randBytes(rng.Intn(20))
+			if got, want := cmp(a, b), DefaultComparer.Compare(a, b); got != want {
+				t.Fatalf("prefixLen=%d: Compare(%x, %x) = %d, expected %d", prefixLen, a, b, got, want)
+			}
+		}
+	}
+}
+
+func BenchmarkFixedPrefixCompare(b *testing.B) {
+	// Keys share a 16 byte prefix with half of the other keys, and are ordered
+	// by their suffixes when their prefixes are equal.
+	rng := rand.New(rand.NewSource(1449168817))
+	keys := make([][]byte, 1024)
+	for i := range keys {
+		keys[i] = make([]byte, 16+rng.Intn(32))
+		binary.BigEndian.PutUint64(keys[i][8:], uint64(i%2))
+		for j := 16; j < len(keys[i]); j++ {
+			keys[i][j] = byte(rng.Intn(256))
+		}
+	}
+	// A comparer that compares a byte at a time stands in for user comparers
+	// that are more expensive than bytes.Compare.
+	byteWise := func(a, b []byte) int {
+		for i := 0; i < len(a) && i < len(b); i++ {
+			if a[i] != b[i] {
+				if a[i] < b[i] {
+					return -1
+				}
+				return +1
+			}
+		}
+		switch {
+		case len(a) < len(b):
+			return -1
+		case len(a) > len(b):
+			return +1
+		}
+		return 0
+	}
+	for _, c := range []struct {
+		name string
+		cmp  Compare
+	}{{"bytes", DefaultComparer.Compare}, {"bytewise", byteWise}} {
+		for _, prefixLen := range []int{0, 16} {
+			b.Run(fmt.Sprintf("cmp=%s/prefixLen=%d", c.name, prefixLen), func(b *testing.B) {
+				cmp := FixedPrefixCompare(c.cmp, prefixLen)
+				var sum int
+				for i := 0; i < b.N; i++ {
+					sum += cmp(keys[i%len(keys)], keys[(i*7+1)%len(keys)])
+				}
+				if testing.Verbose() {
+					// Ensure the compiler doesn't optimize away our benchmark.
+					fmt.Println(sum)
+				}
+			})
+		}
+	}
+}
+
 func BenchmarkAbbreviatedKey(b *testing.B) {
 	rng := rand.New(rand.NewSource(1449168817))
 	randBytes := func(size int) []byte {
@@ -117,3 +193,75 @@ func BenchmarkAbbreviatedKey(b *testing.B) {
 		fmt.Println(sum)
 	}
 }
+
+// appendLower appends the lowercase form of key to dst, as a Normalize
+// function for a case-insensitive ordering.
+func appendLower(dst, key []byte) []byte {
+	for _, c := range key {
+		if 'A' <= c && c <= 'Z' {
+			c += 'a' - 'A'
+		}
+		dst = append(dst, c)
+	}
+	return dst
+}
+
+func TestNormalizedComparer(t *testing.T) {
+	require.Same(t, DefaultComparer, NormalizedComparer(DefaultComparer))
+
+	c := *DefaultComparer
+	c.Normalize = appendLower
+	c.PrefixLen = 4
+	nc := NormalizedComparer(&c)
+	require.Same(t, nc, NormalizedComparer(nc))
+	require.Equal(t, 0, nc.PrefixLen)
+
+	require.Equal(t, 0, nc.Compare([]byte("Apple"), []byte("aPPLE")))
+	require.True(t, nc.Equal([]byte("Apple"), []byte("aPPLE")))
+	require.Equal(t, -1, nc.Compare([]byte("banana"), []byte("Cherry")))
+	require.Equal(t, nc.AbbreviatedKey([]byte("cherry")), nc.AbbreviatedKey([]byte("CHERRY")))
+
+	// Separators and successors must be ordered correctly relative to the
+	// normalized keys.
+	rng := rand.New(rand.NewSource(uint64(time.Now().UnixNano())))
+	randKey := func() []byte {
+		key := make([]byte, 1+rng.Intn(4))
+		for i := range key {
+			key[i] = "@AZ[`az{\xff"[rng.Intn(9)]
+		}
+		return key
+	}
+	for i := 0; i < 10000; i++ {
+		a, b := randKey(), randKey()
+		if nc.Compare(a, b) >= 0 {
+			continue
+		}
+		sep := nc.Separator(nil, a, b)
+		require.LessOrEqual(t, nc.Compare(a, sep), 0, "a=%q b=%q sep=%q", a, b, sep)
+		require.Less(t, nc.Compare(sep, b), 0, "a=%q b=%q sep=%q", a, b, sep)
+		succ := nc.Successor(nil, a)
+		require.GreaterOrEqual(t, nc.Compare(succ, a), 0, "a=%q succ=%q", a, succ)
+	}
+}
+
+func TestNormalizedComparerAllocs(t *testing.T) {
+	if invariants.RaceEnabled {
+		// sync.Pool is a no-op under -race, making this test fail.
+		t.Skip("not supported under -race")
+	}
+
+	c := *DefaultComparer
+	c.Normalize = appendLower
+	c.Split = func(a []byte) int { return len(a) }
+	nc := NormalizedComparer(&c)
+	a, b := []byte("Apple"), []byte("aPPLE")
+	n := testing.AllocsPerRun(100, func() {
+		_ = nc.Compare(a, b)
+		_ = nc.Equal(a, b)
+		_ = nc.AbbreviatedKey(a)
+		_ = nc.Split(a)
+	})
+	if n > 0 {
+		t.Fatalf("normalized comparisons allocated %d, want 0", int(n))
+	}
+}
@@ -302,6 +302,28 @@ type InternalIteratorWithStats interface {
 	ResetStats()
 }
 
+// LazyValueIterator is implemented by internal iterators whose positioning
+// methods may return a placeholder in place of the value at their position,
+// deferring the work of producing the value until it's needed. Iterators over
+// sstables with values chunked into value blocks, and the iterators that wrap
+// them, implement it. A placeholder must not be interpreted as a value.
+type LazyValueIterator interface {
+	InternalIterator
+	// FetchValue returns the value at the iterator's position, given the value
+	// returned by the last positioning call, which must have returned a point
+	// key. The returned value is valid until the next positioning call.
+	FetchValue(value []byte) ([]byte, error)
+}
+
+// FetchValue returns the value at the position of iter, given the value
+// returned by the last positioning call of iter. See LazyValueIterator.
+func FetchValue(iter InternalIterator, value []byte) ([]byte, error) {
+	if l, ok := iter.(LazyValueIterator); ok {
+		return l.FetchValue(value)
+	}
+	return value, nil
+}
+
 // InternalIteratorStats contains miscellaneous stats produced by
 // InternalIterators that are part of the InternalIterator tree. Not every
 // field is relevant for an InternalIterator implementation. The field values
@@ -313,6 +335,20 @@ type InternalIteratorStats struct {
 	BlockBytes uint64
 	// Subset of BlockBytes that were in the block cache.
 	BlockBytesInCache uint64
+	// The count of blocks loaded. Like BlockBytes, only the second-level index
+	// and data blocks containing points are included.
+	BlockReads uint64
+	// The count of bloom filter probes performed by SeekPrefixGE, and the
+	// subset of those probes that excluded the table.
+	FilterProbes    uint64
+	FilterNegatives uint64
+	// The count of data blocks loaded that had been prefetched, split by
+	// whether the prefetch had completed and the block was found in the block
+	// cache (a hit), or not (a miss). A miss indicates the prefetch was still
+	// in flight, or the block was evicted before being loaded. Data blocks
+	// that weren't prefetched aren't counted.
+	PrefetchHits   uint64
+	PrefetchMisses uint64
 
 	// The following can repeatedly count the same points if they are iterated
 	// over multiple times. Additionally, they may count a point twice when
@@ -322,7 +358,8 @@ type InternalIteratorStats struct {
 	// included.
 	KeyBytes uint64
 	// Bytes in values that were iterated over. Currently, only point values are
-	// included.
+	// included. A value that was iterated over without being fetched (see
+	// LazyValueIterator) counts as the size of its placeholder.
 	ValueBytes uint64
 	// The count of points iterated over.
 	PointCount uint64
@@ -336,6 +373,11 @@ type InternalIteratorStats struct {
 func (s *InternalIteratorStats) Merge(from InternalIteratorStats) {
 	s.BlockBytes += from.BlockBytes
 	s.BlockBytesInCache += from.BlockBytesInCache
+	s.BlockReads += from.BlockReads
+	s.FilterProbes += from.FilterProbes
+	s.FilterNegatives += from.FilterNegatives
+	s.PrefetchHits += from.PrefetchHits
+	s.PrefetchMisses += from.PrefetchMisses
 	s.KeyBytes += from.KeyBytes
 	s.ValueBytes += from.ValueBytes
 	s.PointCount += from.PointCount
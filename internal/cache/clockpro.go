@@ -76,6 +76,13 @@ func (h Handle) Release() {
 	}
 }
 
+// UncachedHandle returns a Handle for a value allocated by Cache.Alloc that is
+// not added to the cache, taking ownership of the value. The value is freed
+// when the handle is released.
+func UncachedHandle(v *Value) Handle {
+	return Handle{value: v}
+}
+
 type shard struct {
 	hits   int64
 	misses int64
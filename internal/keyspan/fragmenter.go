@@ -260,6 +260,17 @@ func (f *Fragmenter) Covers(key base.InternalKey, snapshot uint64) bool {
 	return false
 }
 
+// CoversUserKey returns true if the specified user key is within the bounds of
+// one of the pending spans, regardless of the spans' keys.
+func (f *Fragmenter) CoversUserKey(key []byte) bool {
+	for _, s := range f.pending {
+		if f.Cmp(s.Start, key) <= 0 && f.Cmp(key, s.End) < 0 {
+			return true
+		}
+	}
+	return false
+}
+
 // Empty returns true if all fragments added so far have finished flushing.
 func (f *Fragmenter) Empty() bool {
 	return f.finished || len(f.pending) == 0
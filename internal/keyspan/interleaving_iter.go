@@ -1004,6 +1004,12 @@ func (i *InterleavingIter) Close() error {
 	return firstError(perr, rerr)
 }
 
+// FetchValue implements base.LazyValueIterator. Point keys are returned at
+// the position of the point iterator.
+func (i *InterleavingIter) FetchValue(value []byte) ([]byte, error) {
+	return base.FetchValue(i.pointIter, value)
+}
+
 // String implements (base.InternalIterator).String.
 func (i *InterleavingIter) String() string {
 	return fmt.Sprintf("keyspan-interleaving(%q)", i.pointIter.String())
@@ -49,6 +49,13 @@ type TableStats struct {
 	NumDeletions uint64
 	// NumRangeKeySets is the total number of range key sets in the table.
 	NumRangeKeySets uint64
+	// NumRangeKeys is the total number of range key sets, unsets and deletes in
+	// the table. Each range key fragment holds one of each kind of range key
+	// present within its bounds.
+	NumRangeKeys uint64
+	// RangeKeySize is the total uncompressed size of the keys and values of
+	// the table's range keys.
+	RangeKeySize uint64
 	// Estimate of the total disk space that may be dropped by this table's
 	// point deletions by compacting them.
 	PointDeletionsBytesEstimate uint64
@@ -88,6 +95,12 @@ type FileMetadata struct {
 		// that returns a user key (eg. Next, Prev, SeekGE, SeekLT, etc).
 		AllowedSeeks int64
 
+		// ReadCount is the number of iterators, including those constructed
+		// by Get, that have read the file since it was added to the LSM or
+		// since the DB was opened, whichever is later. Iterators constructed
+		// by compactions aren't counted.
+		ReadCount int64
+
 		// statsValid is 1 if stats have been loaded for the table. The
 		// TableStats structure is populated only if valid is 1.
 		statsValid uint32
@@ -103,6 +116,10 @@ type FileMetadata struct {
 	refs int32
 	// FileNum is the file number.
 	FileNum base.FileNum
+	// Name is the name of the file within the DB directory, if it was named
+	// by Options.SSTablePathFunc when it was created. It's empty for files
+	// named by their file number.
+	Name string
 	// Size is the size of the file, in bytes.
 	Size uint64
 	// File creation time in seconds since the epoch (1970-01-01 00:00:00
@@ -972,6 +989,9 @@ func (v *Version) CheckConsistency(dirname string, fs vfs.FS) error {
 		iter := files.Iter()
 		for f := iter.First(); f != nil; f = iter.Next() {
 			path := base.MakeFilepath(fs, dirname, base.FileTypeTable, f.FileNum)
+			if f.Name != "" {
+				path = fs.PathJoin(dirname, f.Name)
+			}
 			info, err := fs.Stat(path)
 			if err != nil {
 				buf.WriteString("L%d: %s: %v\n")
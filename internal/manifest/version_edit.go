@@ -55,6 +55,7 @@ const (
 	customTagNeedsCompaction   = 2
 	customTagCreationTime      = 6
 	customTagPathID            = 65
+	customTagName              = 66
 	customTagNonSafeIgnoreMask = 1 << 6
 )
 
@@ -267,6 +268,7 @@ func (v *VersionEdit) Decode(r io.Reader) error {
 			}
 			var markedForCompaction bool
 			var creationTime uint64
+			var name string
 			if tag == tagNewFile4 || tag == tagNewFile5 {
 				for {
 					customTag, err := d.readUvarint()
@@ -297,6 +299,12 @@ func (v *VersionEdit) Decode(r io.Reader) error {
 					case customTagPathID:
 						return base.CorruptionErrorf("new-file4: path-id field not supported")
 
+					case customTagName:
+						if len(field) == 0 {
+							return base.CorruptionErrorf("new-file4: empty name")
+						}
+						name = string(field)
+
 					default:
 						if (customTag & customTagNonSafeIgnoreMask) != 0 {
 							return base.CorruptionErrorf("new-file4: custom field not supported: %d", customTag)
@@ -306,6 +314,7 @@ func (v *VersionEdit) Decode(r io.Reader) error {
 			}
 			m := &FileMetadata{
 				FileNum:             fileNum,
+				Name:                name,
 				Size:                size,
 				CreationTime:        int64(creationTime),
 				SmallestSeqNum:      smallestSeqNum,
@@ -397,7 +406,7 @@ func (v *VersionEdit) Encode(w io.Writer) error {
 		e.writeUvarint(uint64(x.FileNum))
 	}
 	for _, x := range v.NewFiles {
-		customFields := x.Meta.MarkedForCompaction || x.Meta.CreationTime != 0
+		customFields := x.Meta.MarkedForCompaction || x.Meta.CreationTime != 0 || x.Meta.Name != ""
 		var tag uint64
 		switch {
 		case x.Meta.HasRangeKeys:
@@ -450,6 +459,10 @@ func (v *VersionEdit) Encode(w io.Writer) error {
 				e.writeUvarint(customTagNeedsCompaction)
 				e.writeBytes([]byte{1})
 			}
+			if x.Meta.Name != "" {
+				e.writeUvarint(customTagName)
+				e.writeBytes([]byte(x.Meta.Name))
+			}
 			e.writeUvarint(customTagTerminate)
 		}
 	}
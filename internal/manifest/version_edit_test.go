@@ -76,6 +76,7 @@ func TestVersionEditRoundTrip(t *testing.T) {
 
 	m4 := (&FileMetadata{
 		FileNum:        809,
+		Name:           "backup-809.sst",
 		Size:           8090,
 		CreationTime:   809060,
 		SmallestSeqNum: 9,
@@ -278,6 +278,9 @@ func randomOptions(rng *rand.Rand) *testOptions {
 	opts.MaxManifestFileSize = 1 << uint(rng.Intn(30)) // 1B  - 1GB
 	opts.MemTableSize = 2 << (10 + uint(rng.Intn(16))) // 2KB - 256MB
 	opts.MemTableStopWritesThreshold = 2 + rng.Intn(5) // 2 - 5
+	if rng.Intn(4) == 0 {
+		opts.MaxWALSize = 1 << (10 + uint(rng.Intn(16))) // 1KB - 32MB
+	}
 	if rng.Intn(2) == 0 {
 		opts.WALDir = "data/wal"
 	}
@@ -293,6 +296,9 @@ func randomOptions(rng *rand.Rand) *testOptions {
 	lopts.BlockSize = 1 << uint(rng.Intn(24))      // 1 - 16MB
 	lopts.BlockSizeThreshold = 50 + rng.Intn(50)   // 50 - 100
 	lopts.IndexBlockSize = 1 << uint(rng.Intn(24)) // 1 - 16MB
+	if rng.Intn(2) == 0 {
+		lopts.MaxCompactionConcurrency = 1 + rng.Intn(3) // 1 - 3
+	}
 	lopts.TargetFileSize = 1 << uint(rng.Intn(28)) // 1 - 256MB
 	opts.Levels = []pebble.LevelOptions{lopts}
 
@@ -0,0 +1,213 @@
+// Copyright 2026 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package pebble
+
+import (
+	"sync/atomic"
+
+	"github.com/cockroachdb/pebble/internal/base"
+	"github.com/cockroachdb/pebble/internal/manifest"
+)
+
+// InternalIterOptions hold the optional parameters for an InternalIter.
+type InternalIterOptions struct {
+	// LowerBound specifies the smallest user key (inclusive) that the iterator
+	// will return during iteration. If nil, the iterator is unbounded below.
+	LowerBound []byte
+	// UpperBound specifies the largest user key (exclusive) that the iterator
+	// will return during iteration. If nil, the iterator is unbounded above.
+	UpperBound []byte
+}
+
+// InternalIter iterates over the raw internal point keys of a DB or Snapshot:
+// the user key, sequence number and kind of every Set, SetWithDelete, Merge,
+// Delete and SingleDelete stored in the memtables and sstables, along with its
+// value. It exists for low-level tools such as physical replication that must
+// reproduce the contents of the LSM exactly, rather than its logical state.
+//
+// An InternalIter is UNSAFE for ordinary reads. It intentionally bypasses the
+// logic that produces a DB's logical view:
+//
+//   - Every version of a user key is surfaced, newest first, including
+//     versions shadowed by newer keys.
+//   - Merge operands are surfaced individually, and are not merged.
+//   - Point deletions are surfaced as keys, and keys covered by point or range
+//     deletions are surfaced regardless.
+//   - Keys may disappear from the LSM at any time once they're no longer
+//     visible, for example when a compaction drops them, so two InternalIters
+//     reading the same sequence number may surface different sets of keys
+//     while describing the same logical state.
+//
+// Keys with sequence numbers newer than the DB's visible sequence number (or
+// the Snapshot's sequence number) are not surfaced. Range deletions are read
+// separately through RangeDeletions, which reads the same memtables and
+// sstables as the InternalIter. Range keys are not surfaced.
+//
+// An InternalIter holds a reference to the memtables and sstables it reads,
+// preventing them from being deleted until the iterator is closed.
+type InternalIter struct {
+	db        *DB
+	opts      InternalIterOptions
+	seqNum    uint64
+	readState *readState
+	iter      mergingIter
+	levels    []levelIter
+	key       *InternalKey
+	value     []byte
+}
+
+// NewInternalIter returns an InternalIter over the raw internal point keys of
+// the DB within the bounds provided by o, which may be nil. The returned
+// iterator is unpositioned, and must be closed by the caller. See the
+// documentation of InternalIter before using it.
+func (d *DB) NewInternalIter(o *InternalIterOptions) *InternalIter {
+	if err := d.closed.Load(); err != nil {
+		panic(err)
+	}
+	// Grab the read state before the sequence number, as in newIterInternal.
+	readState := d.loadReadState()
+	return d.newInternalIter(readState, o, atomic.LoadUint64(&d.mu.versions.atomic.visibleSeqNum))
+}
+
+func (d *DB) newInternalIter(
+	readState *readState, o *InternalIterOptions, seqNum uint64,
+) *InternalIter {
+	i := &InternalIter{
+		db:        d,
+		seqNum:    seqNum,
+		readState: readState,
+	}
+	if o != nil {
+		i.opts = *o
+	}
+	iterOpts := IterOptions{
+		LowerBound: i.opts.LowerBound,
+		UpperBound: i.opts.UpperBound,
+		logger:     d.opts.Logger,
+	}
+
+	current := readState.current
+	numLevelIters := len(current.L0SublevelFiles)
+	for level := 1; level < len(current.Levels); level++ {
+		if !current.Levels[level].Empty() {
+			numLevelIters++
+		}
+	}
+	i.levels = make([]levelIter, numLevelIters)
+	var mlevels []mergingIterLevel
+
+	// No range deletion iterators are provided to the merging iterator, so
+	// that keys covered by range deletions are surfaced.
+	memtables := readState.memtables
+	for j := len(memtables) - 1; j >= 0; j-- {
+		mem := memtables[j]
+		// Memtables containing only sequence numbers newer than seqNum are
+		// invisible.
+		if mem.logSeqNum >= seqNum {
+			continue
+		}
+		mlevels = append(mlevels, mergingIterLevel{
+			iter: base.WrapIterWithStats(mem.newIter(&iterOpts)),
+		})
+	}
+	levelsIndex := 0
+	addLevelIterForFiles := func(files manifest.LevelIterator, level manifest.Level) {
+		li := &i.levels[levelsIndex]
+		levelsIndex++
+		li.init(iterOpts, d.cmp, d.split, d.newIters, files, level, internalIterOpts{})
+		mlevels = append(mlevels, mergingIterLevel{iter: li})
+	}
+	for j := len(current.L0SublevelFiles) - 1; j >= 0; j-- {
+		addLevelIterForFiles(current.L0SublevelFiles[j].Iter(), manifest.L0Sublevel(j))
+	}
+	for level := 1; level < len(current.Levels); level++ {
+		if current.Levels[level].Empty() {
+			continue
+		}
+		addLevelIterForFiles(current.Levels[level].Iter(), manifest.Level(level))
+	}
+	i.iter.init(&iterOpts, d.cmp, d.split, mlevels...)
+	i.iter.snapshot = seqNum
+	return i
+}
+
+// First moves the iterator to the first internal key, returning true if the
+// iterator is positioned at a valid key.
+func (i *InternalIter) First() bool {
+	if lower := i.opts.LowerBound; lower != nil {
+		i.key, i.value = i.iter.SeekGE(lower, base.SeekGEFlagsNone)
+	} else {
+		i.key, i.value = i.iter.First()
+	}
+	return i.key != nil
+}
+
+// SeekGE moves the iterator to the first internal key with a user key greater
+// than or equal to the provided key, returning true if the iterator is
+// positioned at a valid key.
+func (i *InternalIter) SeekGE(key []byte) bool {
+	if lower := i.opts.LowerBound; lower != nil && i.db.cmp(key, lower) < 0 {
+		key = lower
+	}
+	i.key, i.value = i.iter.SeekGE(key, base.SeekGEFlagsNone)
+	return i.key != nil
+}
+
+// Next moves the iterator to the next internal key, returning true if the
+// iterator is positioned at a valid key. Internal keys are ordered by user key,
+// and then by descending sequence number.
+func (i *InternalIter) Next() bool {
+	if i.key == nil {
+		return false
+	}
+	i.key, i.value = i.iter.Next()
+	return i.key != nil
+}
+
+// Valid returns true if the iterator is positioned at a valid internal key.
+func (i *InternalIter) Valid() bool {
+	return i.key != nil
+}
+
+// Key returns the internal key at the iterator's current position. The
+// returned key's user key is only valid until the next positioning method is
+// called.
+func (i *InternalIter) Key() InternalKey {
+	return *i.key
+}
+
+// Value returns the value of the internal key at the iterator's current
+// position. The returned slice is only valid until the next positioning method
+// is called.
+func (i *InternalIter) Value() []byte {
+	return i.value
+}
+
+// RangeDeletions returns an iterator over the range deletion tombstones within
+// the InternalIter's bounds, read from the same memtables and sstables as the
+// InternalIter and at the same sequence number. The returned iterator may be
+// used after the InternalIter is closed, and must be closed by the caller. See
+// DB.RangeDeletions.
+func (i *InternalIter) RangeDeletions() *RangeDeletionIter {
+	i.readState.ref()
+	return i.db.newRangeDeletionIter(i.readState, i.opts.LowerBound, i.opts.UpperBound, i.seqNum)
+}
+
+// Error returns any accumulated error.
+func (i *InternalIter) Error() error {
+	return i.iter.Error()
+}
+
+// Close closes the iterator, releasing the memtables and sstables it
+// references. It returns any accumulated error.
+func (i *InternalIter) Close() error {
+	err := i.iter.Close()
+	i.key, i.value = nil, nil
+	if i.readState != nil {
+		i.readState.unref()
+		i.readState = nil
+	}
+	return err
+}
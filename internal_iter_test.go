@@ -0,0 +1,85 @@
+// Copyright 2026 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package pebble
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/cockroachdb/pebble/vfs"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInternalIter(t *testing.T) {
+	d, err := Open("", &Options{FS: vfs.NewMem()})
+	require.NoError(t, err)
+	defer func() { require.NoError(t, d.Close()) }()
+
+	require.NoError(t, d.Set([]byte("a"), []byte("a1"), nil))
+	require.NoError(t, d.Set([]byte("b"), []byte("b2"), nil))
+	require.NoError(t, d.Merge([]byte("c"), []byte("c3"), nil))
+	require.NoError(t, d.Flush())
+	require.NoError(t, d.Set([]byte("a"), []byte("a4"), nil))
+	require.NoError(t, d.Delete([]byte("b"), nil))
+	require.NoError(t, d.Merge([]byte("c"), []byte("c6"), nil))
+	require.NoError(t, d.DeleteRange([]byte("c"), []byte("e"), nil))
+	s := d.NewSnapshot()
+	defer func() { require.NoError(t, s.Close()) }()
+	require.NoError(t, d.Set([]byte("d"), []byte("d8"), nil))
+
+	type reader interface {
+		NewInternalIter(o *InternalIterOptions) *InternalIter
+	}
+	scan := func(r reader, o *InternalIterOptions) string {
+		var buf strings.Builder
+		iter := r.NewInternalIter(o)
+		for valid := iter.First(); valid; valid = iter.Next() {
+			fmt.Fprintf(&buf, "%s:%s\n", iter.Key().Pretty(DefaultComparer.FormatKey), iter.Value())
+		}
+		require.NoError(t, iter.Error())
+		rangeDels := iter.RangeDeletions()
+		require.NoError(t, iter.Close())
+		for valid := rangeDels.First(); valid; valid = rangeDels.Next() {
+			rd := rangeDels.RangeDeletion()
+			fmt.Fprintf(&buf, "[%s-%s)%v\n", rd.Start, rd.End, rd.SeqNums)
+		}
+		require.NoError(t, rangeDels.Close())
+		return buf.String()
+	}
+
+	// Shadowed keys, deletions, unmerged operands and keys covered by range
+	// deletions are all surfaced.
+	require.Equal(t, `a#4,SET:a4
+a#1,SET:a1
+b#5,DEL:
+b#2,SET:b2
+c#6,MERGE:c6
+c#3,MERGE:c3
+d#8,SET:d8
+[c-e)[7]
+`, scan(d, nil))
+
+	require.Equal(t, `a#4,SET:a4
+a#1,SET:a1
+b#5,DEL:
+b#2,SET:b2
+c#6,MERGE:c6
+c#3,MERGE:c3
+[c-e)[7]
+`, scan(s, nil))
+
+	require.Equal(t, `b#5,DEL:
+b#2,SET:b2
+c#6,MERGE:c6
+c#3,MERGE:c3
+[c-d)[7]
+`, scan(d, &InternalIterOptions{LowerBound: []byte("b"), UpperBound: []byte("d")}))
+
+	iter := d.NewInternalIter(nil)
+	require.True(t, iter.SeekGE([]byte("bb")))
+	require.Equal(t, "c#6,MERGE", fmt.Sprint(iter.Key().Pretty(DefaultComparer.FormatKey)))
+	require.NoError(t, iter.Close())
+}
@@ -0,0 +1,16 @@
+// Copyright 2026 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+//go:build !linux
+// +build !linux
+
+package pebble
+
+import "github.com/cockroachdb/errors"
+
+// setThreadIOPriority sets the I/O priority of the calling goroutine's OS
+// thread. I/O priorities are only supported on Linux.
+func setThreadIOPriority(p IOPriority) (restore func(), err error) {
+	return nil, errors.New("pebble: I/O priorities are not supported on this platform")
+}
@@ -0,0 +1,65 @@
+// Copyright 2026 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+//go:build linux
+// +build linux
+
+package pebble
+
+import (
+	"runtime"
+
+	"golang.org/x/sys/unix"
+)
+
+// The ioprio_get and ioprio_set constants, from linux/ioprio.h.
+const (
+	ioprioWhoProcess = 1
+	ioprioClassShift = 13
+	ioprioClassBE    = 2
+	ioprioClassIdle  = 3
+)
+
+func ioprioValue(p IOPriority) uintptr {
+	switch p.Class {
+	case IOPriorityBestEffort:
+		return ioprioClassBE<<ioprioClassShift | uintptr(p.Level)
+	case IOPriorityIdle:
+		return ioprioClassIdle << ioprioClassShift
+	default:
+		return 0
+	}
+}
+
+// setThreadIOPriority locks the calling goroutine to its OS thread and sets
+// the thread's I/O priority. It returns a function that restores the thread's
+// previous I/O priority and unlocks the goroutine from the thread. If the
+// previous priority can't be restored, the goroutine remains locked to the
+// thread, so that the thread exits along with the goroutine rather than
+// running other goroutines at the new priority.
+func setThreadIOPriority(p IOPriority) (restore func(), err error) {
+	runtime.LockOSThread()
+	// A "who" of zero denotes the calling thread.
+	prev, _, errno := unix.Syscall(unix.SYS_IOPRIO_GET, ioprioWhoProcess, 0, 0)
+	if errno != 0 {
+		runtime.UnlockOSThread()
+		return nil, errno
+	}
+	if prev>>ioprioClassShift == 0 {
+		// The thread has no explicit priority, and its priority level is
+		// derived from its CPU niceness. Restore it to have no explicit
+		// priority.
+		prev = 0
+	}
+	if _, _, errno := unix.Syscall(unix.SYS_IOPRIO_SET, ioprioWhoProcess, 0, ioprioValue(p)); errno != 0 {
+		runtime.UnlockOSThread()
+		return nil, errno
+	}
+	return func() {
+		if _, _, errno := unix.Syscall(unix.SYS_IOPRIO_SET, ioprioWhoProcess, 0, prev); errno != 0 {
+			return
+		}
+		runtime.UnlockOSThread()
+	}, nil
+}
@@ -0,0 +1,55 @@
+// Copyright 2026 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+//go:build linux
+// +build linux
+
+package pebble
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/cockroachdb/pebble/vfs"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+func TestSetThreadIOPriority(t *testing.T) {
+	getIOPriority := func() uintptr {
+		v, _, errno := unix.Syscall(unix.SYS_IOPRIO_GET, ioprioWhoProcess, 0, 0)
+		require.Zero(t, errno)
+		return v
+	}
+
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	prev := getIOPriority()
+
+	p := IOPriority{Class: IOPriorityBestEffort, Level: 7}
+	restore, err := setThreadIOPriority(p)
+	if err != nil {
+		t.Skipf("cannot set I/O priority: %v", err)
+	}
+	require.Equal(t, ioprioValue(p), getIOPriority())
+	restore()
+	require.Equal(t, prev, getIOPriority())
+}
+
+func TestCompactionIOPriority(t *testing.T) {
+	opts := &Options{FS: vfs.NewMem()}
+	opts.Experimental.CompactionIOPriority = IOPriority{Class: IOPriorityBestEffort, Level: 7}
+	d, err := Open("", opts)
+	require.NoError(t, err)
+	defer func() { require.NoError(t, d.Close()) }()
+	require.Equal(t, opts.Experimental.CompactionIOPriority, d.compactionIOPriority)
+
+	require.NoError(t, d.Set([]byte("a"), nil, nil))
+	require.NoError(t, d.Flush())
+	require.NoError(t, d.Compact([]byte("a"), []byte("b"), false /* parallelize */))
+	require.Equal(t, int64(1), d.Metrics().Levels[6].NumFiles)
+
+	opts.Experimental.CompactionIOPriority.Level = 8
+	require.Error(t, opts.Validate())
+}
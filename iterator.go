@@ -6,6 +6,7 @@ package pebble
 
 import (
 	"bytes"
+	"fmt"
 	"io"
 	"sync"
 	"sync/atomic"
@@ -151,6 +152,7 @@ type Iterator struct {
 	equal     Equal
 	merge     Merge
 	split     Split
+	formatKey base.FormatKey
 	iter      internalIteratorWithStats
 	pointIter internalIteratorWithStats
 	readState *readState
@@ -171,6 +173,9 @@ type Iterator struct {
 	value       []byte
 	valueBuf    []byte
 	valueCloser io.Closer
+	// lazyValue is true if value is a placeholder for a value that's only
+	// fetched from iter when Value is called. See base.LazyValueIterator.
+	lazyValue bool
 	// boundsBuf holds two buffers used to store the lower and upper bounds.
 	// Whenever the Iterator's bounds change, the new bounds are copied into
 	// boundsBuf[boundsBufIdx]. The two bounds share a slice to reduce
@@ -187,6 +192,8 @@ type Iterator struct {
 	readSampling        readSampling
 	stats               IteratorStats
 	externalReaders     []*sstable.Reader
+	// peekBuf holds the key most recently returned by Peek.
+	peekBuf []byte
 
 	// Following fields used when constructing an iterator stack, eg, in Clone
 	// and SetOptions or when re-fragmenting a batch's range keys/range dels.
@@ -196,6 +203,9 @@ type Iterator struct {
 	newIterRangeKey  keyspan.TableNewSpanIter
 	lazyCombinedIter lazyCombinedIter
 	seqNum           uint64
+	// minSeqNum is the smallest sequence number of the keys surfaced by an
+	// incremental iterator, or zero. See DB.NewIncrementalIter.
+	minSeqNum uint64
 	// batchSeqNum is used by Iterators over indexed batches to detect when the
 	// underlying batch has been mutated. The batch beneath an indexed batch may
 	// be mutated while the Iterator is open, but new keys are not surfaced
@@ -236,6 +246,14 @@ type Iterator struct {
 	lastPositioningOp lastPositioningOpKind
 	// Used in some tests to disable the random disabling of seek optimizations.
 	forceEnableSeekOpt bool
+	// seekCachePending is set when the Iterator was positioned by a SeekGE
+	// that hit seekCache, in which case iter has not been positioned and must
+	// be seeked to prefixOrFullSeekKey before stepping. See
+	// IterOptions.SeekCacheSize.
+	seekCachePending bool
+	// seekCache holds the results of recent SeekGE calls if
+	// opts.SeekCacheSize is positive. It is allocated by the first SeekGE.
+	seekCache *seekCache
 }
 
 // iteratorRangeKeyState holds an iterator's range key iteration state.
@@ -390,6 +408,7 @@ type readSampling struct {
 func (i *Iterator) findNextEntry(limit []byte) {
 	i.iterValidityState = IterExhausted
 	i.pos = iterPosCurForward
+	i.lazyValue = false
 	if i.opts.rangeKeys() && i.rangeKey != nil {
 		i.rangeKey.rangeKeyOnly = false
 	}
@@ -403,7 +422,7 @@ func (i *Iterator) findNextEntry(limit []byte) {
 		key := *i.iterKey
 
 		if i.hasPrefix {
-			if n := i.split(key.UserKey); !bytes.Equal(i.prefixOrFullSeekKey, key.UserKey[:n]) {
+			if n := i.split(key.UserKey); !i.equal(i.prefixOrFullSeekKey, key.UserKey[:n]) {
 				return
 			}
 		}
@@ -448,6 +467,7 @@ func (i *Iterator) findNextEntry(limit []byte) {
 			i.keyBuf = append(i.keyBuf[:0], key.UserKey...)
 			i.key = i.keyBuf
 			i.value = i.iterValue
+			i.lazyValue = true
 			i.iterValidityState = IterValid
 			i.saveRangeKey()
 			return
@@ -508,6 +528,7 @@ func (i *Iterator) nextPointCurrentUserKey() bool {
 
 	case InternalKeyKindSet, InternalKeyKindSetWithDelete:
 		i.value = i.iterValue
+		i.lazyValue = true
 		return true
 
 	case InternalKeyKindMerge:
@@ -527,8 +548,12 @@ func (i *Iterator) nextPointCurrentUserKey() bool {
 //
 // mergeForward does not update iterValidityState.
 func (i *Iterator) mergeForward(key base.InternalKey) (valid bool) {
+	var value []byte
+	if value, i.err = base.FetchValue(i.iter, i.iterValue); i.err != nil {
+		return false
+	}
 	var valueMerger ValueMerger
-	valueMerger, i.err = i.merge(key.UserKey, i.iterValue)
+	valueMerger, i.err = i.merge(key.UserKey, value)
 	if i.err != nil {
 		return false
 	}
@@ -592,6 +617,111 @@ func (i *Iterator) nextUserKey() {
 	}
 }
 
+// skipSampledKeys moves the internal iterator approximately SampleStride-1
+// user keys beyond its current position, leaving it at the first entry of a
+// user key. If the stride spans at least one data block of the sstables of
+// the bottommost level holding keys at or after the current position, the
+// internal iterator is repositioned with a SeekGE to a key found by
+// navigating the index blocks of those sstables, so that the data blocks
+// stepped over are never loaded. Otherwise the keys are stepped over one at a
+// time. See IterOptions.SampleStride.
+func (i *Iterator) skipSampledKeys() {
+	if i.iterKey == nil {
+		return
+	}
+	if target, ok := i.sampleStrideTarget(i.iterKey.UserKey); ok {
+		if i.cmp(target, i.iterKey.UserKey) > 0 {
+			i.iterKey, i.iterValue = i.iter.SeekGE(target, base.SeekGEFlagsNone)
+			i.stats.ForwardSeekCount[InternalIterCall]++
+		}
+		return
+	}
+	// Set i.iterValidityState to IterExhausted to force the calls to
+	// nextUserKey to save the key i.iter is pointing at in order to determine
+	// when the next user key is reached.
+	i.iterValidityState = IterExhausted
+	for n := 1; n < i.opts.SampleStride && i.iterKey != nil; n++ {
+		i.nextUserKey()
+	}
+}
+
+// sampleStrideTarget returns a key approximately SampleStride-1 user keys
+// beyond key, estimated from the sstables of the bottommost level L1+ that
+// holds keys at or after key, which usually holds most of the DB's keys. The
+// number of keys per data block of each sstable is estimated from its
+// properties, and the data blocks stepped over are found by navigating its
+// index. It returns false if the stride is shorter than a data block, or if
+// no such level exists, in which case the caller steps over the keys one at a
+// time.
+func (i *Iterator) sampleStrideTarget(key []byte) ([]byte, bool) {
+	if i.readState == nil {
+		return nil, false
+	}
+	d := i.readState.db
+	for level := numLevels - 1; level > 0; level-- {
+		iter := i.readState.current.Levels[level].Iter()
+		files := iter.Filter(manifest.KeyTypePoint)
+		f := files.SeekGE(i.cmp, key)
+		if f == nil {
+			continue
+		}
+		// Step over the data blocks of the level's sstables, starting with
+		// the one that may contain key, until the remaining keys of the stride
+		// fit within a data block.
+		remaining := i.opts.SampleStride - 1
+		var moved bool
+		var last []byte
+		for ; f != nil; f = files.Next() {
+			if moved {
+				key = f.SmallestPointKey.UserKey
+			}
+			var sep []byte
+			var blocks int
+			err := d.tableCache.withReader(f, func(r *sstable.Reader) error {
+				if r.Properties.NumDataBlocks == 0 {
+					return nil
+				}
+				keysPerBlock := int(r.Properties.NumEntries / r.Properties.NumDataBlocks)
+				if keysPerBlock < 1 {
+					keysPerBlock = 1
+				}
+				if blocks = remaining / keysPerBlock; blocks == 0 {
+					return nil
+				}
+				var skipped int
+				var err error
+				sep, skipped, err = r.SkipDataBlocks(key, blocks)
+				remaining -= skipped * keysPerBlock
+				return err
+			})
+			switch {
+			case err != nil:
+				// Fall back to stepping over the keys, which surfaces the error
+				// if it persists.
+				return nil, false
+			case sep != nil:
+				return sep, true
+			case blocks == 0:
+				return key, moved
+			}
+			moved = true
+			last = f.LargestPointKey.UserKey
+		}
+		// The stride extends beyond the last sstable of the level.
+		return last, true
+	}
+	return nil, false
+}
+
+// maybeReportKeyAccess invokes the IterOptions.OnKeyAccess callback, if any,
+// with the current key. Like maybeSampleRead, it is called when a public
+// positioning method of Iterator is returning.
+func (i *Iterator) maybeReportKeyAccess() {
+	if i.opts.OnKeyAccess != nil && i.iterValidityState == IterValid {
+		i.opts.OnKeyAccess(i.key)
+	}
+}
+
 func (i *Iterator) maybeSampleRead() {
 	// This method is only called when a public method of Iterator is
 	// returning, and below we exclude the case were the iterator is paused at
@@ -698,6 +828,7 @@ func (i *Iterator) sampleRead() {
 func (i *Iterator) findPrevEntry(limit []byte) {
 	i.iterValidityState = IterExhausted
 	i.pos = iterPosCurReverse
+	i.lazyValue = false
 	if i.opts.rangeKeys() && i.rangeKey != nil {
 		i.rangeKey.rangeKeyOnly = false
 	}
@@ -821,7 +952,14 @@ func (i *Iterator) findPrevEntry(limit []byte) {
 			// call, so use valueBuf instead. Note that valueBuf is only used
 			// in this one instance; everywhere else (eg. in findNextEntry),
 			// we just point i.value to the unsafe i.iter-owned value buffer.
-			i.valueBuf = append(i.valueBuf[:0], i.iterValue...)
+			// The value must also be fetched before the Prev() call, if it's
+			// fetched lazily.
+			var value []byte
+			if value, i.err = base.FetchValue(i.iter, i.iterValue); i.err != nil {
+				i.iterValidityState = IterExhausted
+				return
+			}
+			i.valueBuf = append(i.valueBuf[:0], value...)
 			i.value = i.valueBuf
 			i.saveRangeKey()
 			i.iterValidityState = IterValid
@@ -831,11 +969,16 @@ func (i *Iterator) findPrevEntry(limit []byte) {
 			continue
 
 		case InternalKeyKindMerge:
+			var value []byte
+			if value, i.err = base.FetchValue(i.iter, i.iterValue); i.err != nil {
+				i.iterValidityState = IterExhausted
+				return
+			}
 			if i.iterValidityState == IterExhausted {
 				i.keyBuf = append(i.keyBuf[:0], key.UserKey...)
 				i.key = i.keyBuf
 				i.saveRangeKey()
-				valueMerger, i.err = i.merge(i.key, i.iterValue)
+				valueMerger, i.err = i.merge(i.key, value)
 				if i.err != nil {
 					return
 				}
@@ -843,14 +986,14 @@ func (i *Iterator) findPrevEntry(limit []byte) {
 			} else if valueMerger == nil {
 				valueMerger, i.err = i.merge(i.key, i.value)
 				if i.err == nil {
-					i.err = valueMerger.MergeNewer(i.iterValue)
+					i.err = valueMerger.MergeNewer(value)
 				}
 				if i.err != nil {
 					i.iterValidityState = IterExhausted
 					return
 				}
 			} else {
-				i.err = valueMerger.MergeNewer(i.iterValue)
+				i.err = valueMerger.MergeNewer(value)
 				if i.err != nil {
 					i.iterValidityState = IterExhausted
 					return
@@ -934,13 +1077,19 @@ func (i *Iterator) mergeNext(key InternalKey, valueMerger ValueMerger) {
 
 		case InternalKeyKindSet, InternalKeyKindSetWithDelete:
 			// We've hit a Set value. Merge with the existing value and return.
-			i.err = valueMerger.MergeOlder(i.iterValue)
+			var value []byte
+			if value, i.err = base.FetchValue(i.iter, i.iterValue); i.err == nil {
+				i.err = valueMerger.MergeOlder(value)
+			}
 			return
 
 		case InternalKeyKindMerge:
 			// We've hit another Merge value. Merge with the existing value and
 			// continue looping.
-			i.err = valueMerger.MergeOlder(i.iterValue)
+			var value []byte
+			if value, i.err = base.FetchValue(i.iter, i.iterValue); i.err == nil {
+				i.err = valueMerger.MergeOlder(value)
+			}
 			if i.err != nil {
 				return
 			}
@@ -1013,6 +1162,7 @@ func (i *Iterator) SeekGEWithLimit(key []byte, limit []byte) IterValidityState {
 				// Noop
 				if !invariants.Enabled || !disableSeekOpt(key, uintptr(unsafe.Pointer(i))) || i.forceEnableSeekOpt {
 					i.lastPositioningOp = seekGELastPositioningOp
+					i.maybeReportKeyAccess()
 					return i.iterValidityState
 				}
 			}
@@ -1042,20 +1192,80 @@ func (i *Iterator) SeekGEWithLimit(key []byte, limit []byte) IterValidityState {
 			}
 		}
 	}
+	useSeekCache := seekInternalIter && limit == nil && i.useSeekCache()
+	if useSeekCache {
+		if e := i.seekCache.get(i.equal, key); e != nil {
+			i.seekGEFromCache(key, e)
+			return i.iterValidityState
+		}
+	}
+	i.seekCachePending = false
 	if seekInternalIter {
 		i.iterKey, i.iterValue = i.iter.SeekGE(key, flags)
 		i.stats.ForwardSeekCount[InternalIterCall]++
 	}
 	i.findNextEntry(limit)
 	i.maybeSampleRead()
+	i.maybeReportKeyAccess()
 	if i.Error() == nil && i.batch == nil {
 		// Prepare state for a future noop optimization.
 		i.prefixOrFullSeekKey = append(i.prefixOrFullSeekKey[:0], key...)
 		i.lastPositioningOp = seekGELastPositioningOp
+		if useSeekCache {
+			// The cached value must not be a placeholder, so fetch it.
+			if value := i.Value(); i.err == nil {
+				i.seekCache.add(key, i.key, value, i.iterValidityState == IterValid)
+			}
+		}
 	}
 	return i.iterValidityState
 }
 
+// useSeekCache returns true if SeekGE calls without a limit may be served by
+// the seek cache, allocating the cache if necessary.
+func (i *Iterator) useSeekCache() bool {
+	if i.opts.SeekCacheSize <= 0 || i.batch != nil || i.opts.rangeKeys() {
+		return false
+	}
+	if i.seekCache == nil || len(i.seekCache.entries) != i.opts.SeekCacheSize {
+		i.seekCache = newSeekCache(i.opts.SeekCacheSize)
+	}
+	return true
+}
+
+// seekGEFromCache positions the Iterator at the cached result e of a SeekGE
+// to key. The internal iterator is left unpositioned until the Iterator is
+// next stepped, at which point materializeSeekCache seeks it to key.
+func (i *Iterator) seekGEFromCache(key []byte, e *seekCacheEntry) {
+	i.iterValidityState = IterExhausted
+	i.pos = iterPosCurForward
+	i.iterKey, i.iterValue = nil, nil
+	i.lazyValue = false
+	i.prefixOrFullSeekKey = append(i.prefixOrFullSeekKey[:0], key...)
+	i.seekCachePending = true
+	// Close the closer for the current value if one was open.
+	if i.closeValueCloser() != nil {
+		return
+	}
+	if e.valid {
+		i.keyBuf = append(i.keyBuf[:0], e.key...)
+		i.key = i.keyBuf
+		i.value = e.value
+		i.iterValidityState = IterValid
+	}
+	i.maybeReportKeyAccess()
+}
+
+// materializeSeekCache positions the internal iterator at the result of the
+// SeekGE that was served by the seek cache, so that the Iterator can be
+// stepped from it.
+func (i *Iterator) materializeSeekCache() {
+	i.seekCachePending = false
+	i.iterKey, i.iterValue = i.iter.SeekGE(i.prefixOrFullSeekKey, base.SeekGEFlagsNone)
+	i.stats.ForwardSeekCount[InternalIterCall]++
+	i.findNextEntry(nil)
+}
+
 // SeekPrefixGE moves the iterator to the first key/value pair whose key is
 // greater than or equal to the given key and which has the same "prefix" as
 // the given key. The prefix for a key is determined by the user-defined
@@ -1106,6 +1316,7 @@ func (i *Iterator) SeekPrefixGE(key []byte) bool {
 	// iterator position.
 	i.lastPositioningOp = unknownLastPositionOp
 	i.requiresReposition = false
+	i.seekCachePending = false
 	i.err = nil // clear cached iteration error
 	i.stats.ForwardSeekCount[InterfaceCall]++
 	if i.rangeKey != nil {
@@ -1160,14 +1371,14 @@ func (i *Iterator) SeekPrefixGE(key []byte) bool {
 	copy(i.prefixOrFullSeekKey, keyPrefix)
 
 	if lowerBound := i.opts.GetLowerBound(); lowerBound != nil && i.cmp(key, lowerBound) < 0 {
-		if n := i.split(lowerBound); !bytes.Equal(i.prefixOrFullSeekKey, lowerBound[:n]) {
+		if n := i.split(lowerBound); !i.equal(i.prefixOrFullSeekKey, lowerBound[:n]) {
 			i.err = errors.New("pebble: SeekPrefixGE supplied with key outside of lower bound")
 			i.iterValidityState = IterExhausted
 			return false
 		}
 		key = lowerBound
 	} else if upperBound := i.opts.GetUpperBound(); upperBound != nil && i.cmp(key, upperBound) > 0 {
-		if n := i.split(upperBound); !bytes.Equal(i.prefixOrFullSeekKey, upperBound[:n]) {
+		if n := i.split(upperBound); !i.equal(i.prefixOrFullSeekKey, upperBound[:n]) {
 			i.err = errors.New("pebble: SeekPrefixGE supplied with key outside of upper bound")
 			i.iterValidityState = IterExhausted
 			return false
@@ -1179,6 +1390,7 @@ func (i *Iterator) SeekPrefixGE(key []byte) bool {
 	i.stats.ForwardSeekCount[InternalIterCall]++
 	i.findNextEntry(nil)
 	i.maybeSampleRead()
+	i.maybeReportKeyAccess()
 	if i.Error() == nil {
 		i.lastPositioningOp = seekPrefixGELastPositioningOp
 	}
@@ -1219,6 +1431,7 @@ func (i *Iterator) SeekLTWithLimit(key []byte, limit []byte) IterValidityState {
 	// position.
 	i.lastPositioningOp = unknownLastPositionOp
 	i.requiresReposition = false
+	i.seekCachePending = false
 	i.err = nil // clear cached iteration error
 	i.hasPrefix = false
 	i.stats.ReverseSeekCount[InterfaceCall]++
@@ -1250,6 +1463,7 @@ func (i *Iterator) SeekLTWithLimit(key []byte, limit []byte) IterValidityState {
 					(limit == nil || i.cmp(limit, i.key) <= 0)) {
 				if !invariants.Enabled || !disableSeekOpt(key, uintptr(unsafe.Pointer(i))) {
 					i.lastPositioningOp = seekLTLastPositioningOp
+					i.maybeReportKeyAccess()
 					return i.iterValidityState
 				}
 			}
@@ -1266,6 +1480,7 @@ func (i *Iterator) SeekLTWithLimit(key []byte, limit []byte) IterValidityState {
 	}
 	i.findPrevEntry(limit)
 	i.maybeSampleRead()
+	i.maybeReportKeyAccess()
 	if i.Error() == nil && i.batch == nil {
 		// Prepare state for a future noop optimization.
 		i.prefixOrFullSeekKey = append(i.prefixOrFullSeekKey[:0], key...)
@@ -1281,6 +1496,7 @@ func (i *Iterator) First() bool {
 	i.hasPrefix = false
 	i.lastPositioningOp = unknownLastPositionOp
 	i.requiresReposition = false
+	i.seekCachePending = false
 	i.stats.ForwardSeekCount[InterfaceCall]++
 	if i.rangeKey != nil {
 		i.rangeKey.updated = false
@@ -1296,6 +1512,7 @@ func (i *Iterator) First() bool {
 	}
 	i.findNextEntry(nil)
 	i.maybeSampleRead()
+	i.maybeReportKeyAccess()
 	return i.iterValidityState == IterValid
 }
 
@@ -1306,6 +1523,7 @@ func (i *Iterator) Last() bool {
 	i.hasPrefix = false
 	i.lastPositioningOp = unknownLastPositionOp
 	i.requiresReposition = false
+	i.seekCachePending = false
 	i.stats.ReverseSeekCount[InterfaceCall]++
 	if i.rangeKey != nil {
 		i.rangeKey.updated = false
@@ -1321,6 +1539,7 @@ func (i *Iterator) Last() bool {
 	}
 	i.findPrevEntry(nil)
 	i.maybeSampleRead()
+	i.maybeReportKeyAccess()
 	return i.iterValidityState == IterValid
 }
 
@@ -1350,6 +1569,9 @@ func (i *Iterator) NextWithLimit(limit []byte) IterValidityState {
 	if i.err != nil {
 		return i.iterValidityState
 	}
+	if i.seekCachePending {
+		i.materializeSeekCache()
+	}
 	i.lastPositioningOp = unknownLastPositionOp
 	i.requiresReposition = false
 	if i.rangeKey != nil {
@@ -1359,6 +1581,9 @@ func (i *Iterator) NextWithLimit(limit []byte) IterValidityState {
 	switch i.pos {
 	case iterPosCurForward:
 		i.nextUserKey()
+		if i.opts.SampleStride > 1 && i.rangeKey == nil {
+			i.skipSampledKeys()
+		}
 	case iterPosCurForwardPaused:
 		// Already at the right place.
 	case iterPosCurReverse:
@@ -1414,6 +1639,7 @@ func (i *Iterator) NextWithLimit(limit []byte) IterValidityState {
 	}
 	i.findNextEntry(limit)
 	i.maybeSampleRead()
+	i.maybeReportKeyAccess()
 	return i.iterValidityState
 }
 
@@ -1438,6 +1664,9 @@ func (i *Iterator) PrevWithLimit(limit []byte) IterValidityState {
 	if i.err != nil {
 		return i.iterValidityState
 	}
+	if i.seekCachePending {
+		i.materializeSeekCache()
+	}
 	i.lastPositioningOp = unknownLastPositionOp
 	i.requiresReposition = false
 	if i.rangeKey != nil {
@@ -1506,6 +1735,7 @@ func (i *Iterator) PrevWithLimit(limit []byte) IterValidityState {
 	}
 	i.findPrevEntry(limit)
 	i.maybeSampleRead()
+	i.maybeReportKeyAccess()
 	return i.iterValidityState
 }
 
@@ -1516,6 +1746,46 @@ type RangeKeyData struct {
 	Value  []byte
 }
 
+// Peek returns the key that a call to Next would position the iterator at,
+// without logically moving the iterator: Key, Value, RangeKeys and the other
+// accessors continue to describe the current position, and a subsequent call
+// to Next positions the iterator at the peeked key. Peek returns false if the
+// iterator is not positioned at a valid key, or if Next would exhaust the
+// iterator or encounter an error, in which case the error is returned by
+// Error.
+//
+// The returned key is owned by the iterator, and is only valid until the next
+// call to Peek or any positioning method. Peek is not free: it steps the
+// iterator forward to find the next key and then steps it back, which may
+// require reading and discarding the shadowed versions of both keys. In prefix
+// iteration mode, where the iterator cannot step backward, the current key is
+// instead found again with SeekPrefixGE.
+func (i *Iterator) Peek() (key []byte, ok bool) {
+	if !i.Valid() {
+		return nil, false
+	}
+	rangeKeyUpdated := i.rangeKey != nil && i.rangeKey.updated
+	var cur []byte
+	if i.hasPrefix {
+		cur = append(cur, i.key...)
+	}
+	if ok = i.Next(); ok {
+		i.peekBuf = append(i.peekBuf[:0], i.key...)
+		key = i.peekBuf
+	} else if i.err != nil {
+		return nil, false
+	}
+	if i.hasPrefix {
+		i.SeekPrefixGE(cur)
+	} else {
+		i.Prev()
+	}
+	if i.rangeKey != nil {
+		i.rangeKey.updated = rangeKeyUpdated
+	}
+	return key, ok
+}
+
 // rangeKeyWithinLimit is called during limited reverse iteration when
 // positioned over a key beyond the limit. If there exists a range key that lies
 // within the limit, the iterator must not pause in order to ensure the user has
@@ -1605,6 +1875,10 @@ func (i *Iterator) saveRangeKey() {
 			Value:  value,
 		})
 	}
+	if i.opts.RangeKeyResolver != nil && len(i.rangeKey.keys) > 0 {
+		resolved := i.opts.RangeKeyResolver(i.rangeKey.keys)
+		i.rangeKey.keys = append(i.rangeKey.keys[:0], resolved)
+	}
 }
 
 // RangeKeyChanged indicates whether the most recent iterator positioning
@@ -1650,12 +1924,55 @@ func (i *Iterator) Key() []byte {
 	return i.key
 }
 
+// KeyPrefix returns the prefix of the current key, as determined by the
+// Comparer's Split, or nil if the iterator is not valid. The returned slice
+// is a subslice of the slice returned by Key, and is subject to the same
+// restrictions. KeyPrefix panics if the Comparer has no Split.
+func (i *Iterator) KeyPrefix() []byte {
+	if !i.Valid() {
+		return nil
+	}
+	return i.key[:i.splitKey()]
+}
+
+// KeySuffix returns the suffix of the current key, the remainder of the key
+// following its prefix (see KeyPrefix), or nil if the iterator is not valid.
+// The suffix of a key with no suffix is empty. The returned slice is a
+// subslice of the slice returned by Key, and is subject to the same
+// restrictions. KeySuffix panics if the Comparer has no Split.
+func (i *Iterator) KeySuffix() []byte {
+	if !i.Valid() {
+		return nil
+	}
+	return i.key[i.splitKey():]
+}
+
+// splitKey returns the length of the prefix of the current key.
+func (i *Iterator) splitKey() int {
+	if i.split == nil {
+		panic("pebble: split must be provided for KeyPrefix and KeySuffix")
+	}
+	return i.split(i.key)
+}
+
 // Value returns the value of the current key/value pair, or nil if done. The
 // caller should not modify the contents of the returned slice, and its
-// contents may change on the next call to Next.
+// contents may change on the next call to Next. The value of a key set to an
+// empty value may be nil; use Valid to distinguish it from the iterator being
+// exhausted.
+//
+// Values chunked into value blocks (see Options.Experimental.MaxInlineValueSize)
+// are only read when Value is called. If reading fails, Value returns nil and
+// the error is returned by Error.
 //
 // Only valid if HasPointAndRange() returns true for hasPoint.
 func (i *Iterator) Value() []byte {
+	if i.lazyValue {
+		i.lazyValue = false
+		if i.value, i.err = base.FetchValue(i.iter, i.value); i.err != nil {
+			i.value = nil
+		}
+	}
 	return i.value
 }
 
@@ -1714,6 +2031,7 @@ func (i *Iterator) Close() error {
 	err := i.err
 
 	if i.readState != nil {
+		i.recordRangeKeyIterOps()
 		if i.readSampling.pendingCompactions.size > 0 {
 			// Copy pending read compactions using db.mu.Lock()
 			i.readState.db.mu.Lock()
@@ -1731,6 +2049,7 @@ func (i *Iterator) Close() error {
 			}
 		}
 
+		i.readState.db.trackIterClosed(i)
 		i.readState.unref()
 		i.readState = nil
 	}
@@ -1798,7 +2117,9 @@ func (i *Iterator) Close() error {
 }
 
 // SetBounds sets the lower and upper bounds for the iterator. Once SetBounds
-// returns, the caller is free to mutate the provided slices.
+// returns, the caller is free to mutate the provided slices. If the iterator
+// was configured with IterOptions.PrefixBound, the bounds remain constrained
+// to the keys beginning with the prefix.
 //
 // The iterator will always be invalidated and must be repositioned with a call
 // to SeekGE, SeekPrefixGE, SeekLT, First, or Last.
@@ -1835,6 +2156,11 @@ func (i *Iterator) SetBounds(lower, upper []byte) {
 }
 
 func (i *Iterator) saveBounds(lower, upper []byte) {
+	prefix := i.opts.PrefixBound
+	if prefix != nil {
+		lower, upper = prefixBounds(i.cmp, prefix, lower, upper)
+	}
+
 	// Copy the user-provided bounds into an Iterator-owned buffer. We can't
 	// overwrite the current bounds, because some internal iterators compare old
 	// and new bounds for optimizations.
@@ -1852,10 +2178,53 @@ func (i *Iterator) saveBounds(lower, upper []byte) {
 	} else {
 		i.opts.UpperBound = nil
 	}
+	if prefix != nil {
+		// The prefix is retained so that subsequent calls to SetBounds continue
+		// to be constrained by it.
+		buf = append(buf, prefix...)
+		i.opts.PrefixBound = buf[len(buf)-len(prefix):]
+	}
 	i.boundsBuf[i.boundsBufIdx] = buf
 	i.boundsBufIdx = 1 - i.boundsBufIdx
 }
 
+// prefixSuccessor returns the smallest key, under a bytewise ordering, that
+// is greater than every key beginning with prefix. It is found by trimming any
+// trailing 0xff bytes and incrementing the last remaining byte. A prefix of
+// only 0xff bytes, including the empty prefix, has no such key, and nil is
+// returned.
+func prefixSuccessor(prefix []byte) []byte {
+	n := len(prefix)
+	for n > 0 && prefix[n-1] == 0xff {
+		n--
+	}
+	if n == 0 {
+		return nil
+	}
+	succ := append([]byte(nil), prefix[:n]...)
+	succ[n-1]++
+	return succ
+}
+
+// prefixBounds returns the intersection of the bounds [lower, upper) with the
+// range of keys beginning with prefix. A nil bound is unbounded.
+func prefixBounds(cmp Compare, prefix, lower, upper []byte) ([]byte, []byte) {
+	if lower == nil || cmp(lower, prefix) < 0 {
+		lower = prefix
+	}
+	if succ := prefixSuccessor(prefix); succ != nil {
+		if upper == nil || cmp(succ, upper) < 0 {
+			upper = succ
+		}
+	}
+	// If the ranges are disjoint, the iterator is bounded to the empty range
+	// [upper, upper).
+	if upper != nil && cmp(lower, upper) > 0 {
+		lower = upper
+	}
+	return lower, upper
+}
+
 // SetOptions sets new iterator options for the iterator. Note that the lower
 // and upper bounds applied here will supersede any bounds set by previous calls
 // to SetBounds.
@@ -1904,14 +2273,19 @@ func (i *Iterator) SetOptions(o *IterOptions) {
 		o.OnlyReadGuaranteedDurable != i.opts.OnlyReadGuaranteedDurable ||
 		o.TableFilter != nil || i.opts.TableFilter != nil
 
-	// If either options specify block property filters for an iterator stack,
-	// reconstruct it.
+	// If either options specify block property filters or a corruption
+	// callback for an iterator stack, or DisableCacheFill or PrefetchBlocks
+	// changed, reconstruct it.
 	if i.pointIter != nil && (closeBoth || len(o.PointKeyFilters) > 0 || len(i.opts.PointKeyFilters) > 0 ||
-		o.RangeKeyMasking.Filter != nil || i.opts.RangeKeyMasking.Filter != nil) {
+		o.RangeKeyMasking.Filter != nil || i.opts.RangeKeyMasking.Filter != nil ||
+		o.OnCorruption != nil || i.opts.OnCorruption != nil ||
+		o.DisableCacheFill != i.opts.DisableCacheFill ||
+		o.PrefetchBlocks != i.opts.PrefetchBlocks) {
 		i.err = firstError(i.err, i.pointIter.Close())
 		i.pointIter = nil
 	}
-	if i.rangeKey != nil && (closeBoth || len(o.RangeKeyFilters) > 0 || len(i.opts.RangeKeyFilters) > 0) {
+	if i.rangeKey != nil && (closeBoth || len(o.RangeKeyFilters) > 0 || len(i.opts.RangeKeyFilters) > 0 ||
+		o.RangeKeyResolver != nil || i.opts.RangeKeyResolver != nil) {
 		i.err = firstError(i.err, i.rangeKey.rangeKeyIter.Close())
 		i.rangeKey = nil
 	}
@@ -1991,14 +2365,16 @@ func (i *Iterator) SetOptions(o *IterOptions) {
 
 	boundsEqual := ((i.opts.LowerBound == nil) == (o.LowerBound == nil)) &&
 		((i.opts.UpperBound == nil) == (o.UpperBound == nil)) &&
+		((i.opts.PrefixBound == nil) == (o.PrefixBound == nil)) &&
 		i.equal(i.opts.LowerBound, o.LowerBound) &&
-		i.equal(i.opts.UpperBound, o.UpperBound)
+		i.equal(i.opts.UpperBound, o.UpperBound) &&
+		bytes.Equal(i.opts.PrefixBound, o.PrefixBound)
 
 	if boundsEqual && o.KeyTypes == i.opts.KeyTypes &&
 		(i.pointIter != nil || !i.opts.pointKeys()) &&
 		(i.rangeKey != nil || !i.opts.rangeKeys() || i.opts.KeyTypes == IterKeyTypePointsAndRanges) &&
 		i.equal(o.RangeKeyMasking.Suffix, i.opts.RangeKeyMasking.Suffix) &&
-		o.UseL6Filters == i.opts.UseL6Filters {
+		o.UseL6Filters == i.opts.UseL6Filters && o.SeekCacheSize == i.opts.SeekCacheSize {
 		// The options are identical, so we can likely use the fast path. In
 		// addition to all the above constraints, we cannot use the fast path if
 		// configured to perform lazy combined iteration but an indexed batch
@@ -2015,13 +2391,13 @@ func (i *Iterator) SetOptions(o *IterOptions) {
 
 	// The options changed. Save the new ones to i.opts.
 	if boundsEqual {
-		// Copying the options into i.opts will overwrite LowerBound and
-		// UpperBound fields with the user-provided slices. We need to hold on
-		// to the Pebble-owned slices, so save them and re-set them after the
-		// copy.
-		lower, upper := i.opts.LowerBound, i.opts.UpperBound
+		// Copying the options into i.opts will overwrite LowerBound,
+		// UpperBound and PrefixBound fields with the user-provided slices. We
+		// need to hold on to the Pebble-owned slices, so save them and re-set
+		// them after the copy.
+		lower, upper, prefix := i.opts.LowerBound, i.opts.UpperBound, i.opts.PrefixBound
 		i.opts = *o
-		i.opts.LowerBound, i.opts.UpperBound = lower, upper
+		i.opts.LowerBound, i.opts.UpperBound, i.opts.PrefixBound = lower, upper, prefix
 	} else {
 		i.opts = *o
 		i.saveBounds(o.LowerBound, o.UpperBound)
@@ -2046,11 +2422,80 @@ func (i *Iterator) SetOptions(o *IterOptions) {
 	finishInitializingIter(i.alloc)
 }
 
+// Refresh re-pins the iterator to a new point-in-time view of the DB: the
+// view of the provided snapshot, or the current state of the DB if snapshot is
+// nil. The iterator releases its reference to the memtables and sstables of
+// its previous view, and reads those of the new view, while retaining its
+// options and the allocations of its iterator stack. Refresh allows an
+// iterator to be reused across requests, rather than closing the iterator and
+// creating a new one for each request.
+//
+// If the iterator was created over an indexed batch, the iterator's view of the
+// batch is refreshed as well, as with SetOptions.
+//
+// Like the iterator's other methods, Refresh must not be called concurrently
+// with any other use of the iterator. An iterator pooled for reuse must only be
+// used by one goroutine at a time. The iterator is invalidated, and must be
+// repositioned with a call to SeekGE, SeekPrefixGE, SeekLT, First, or Last.
+//
+// Refresh panics if the iterator was not created by the DB (for example an
+// external iterator), has been closed, or if the snapshot belongs to another DB
+// or is closed.
+func (i *Iterator) Refresh(snapshot *Snapshot) {
+	if i.readState == nil || i.externalReaders != nil || i.getIterAlloc != nil {
+		panic("pebble: Refresh requires an open iterator created by a DB")
+	}
+	d := i.readState.db
+	if snapshot != nil && snapshot.db != d {
+		panic("pebble: Refresh requires an open snapshot of the iterator's DB")
+	}
+	if snapshot != nil && i.opts.OnlyReadGuaranteedDurable {
+		panic("OnlyReadGuaranteedDurable is not supported for batches or snapshots")
+	}
+	i.requiresReposition = true
+
+	// Close the iterator stacks reading the previous view before releasing its
+	// readState, which may delete the sstables they read.
+	if i.pointIter != nil {
+		i.err = firstError(i.err, i.pointIter.Close())
+		i.pointIter = nil
+	}
+	if i.rangeKey != nil {
+		i.err = firstError(i.err, i.rangeKey.rangeKeyIter.Close())
+		i.rangeKey = nil
+	}
+
+	// As in newIterInternal, the seqnum to read at is determined after grabbing
+	// the readState.
+	readState := d.loadReadState()
+	i.readState.unref()
+	i.readState = readState
+	if snapshot != nil {
+		i.seqNum = snapshot.seqNum
+	} else {
+		i.seqNum = atomic.LoadUint64(&d.mu.versions.atomic.visibleSeqNum)
+	}
+	if i.batch != nil {
+		i.batchSeqNum = i.batch.nextSeqNum()
+	}
+
+	i.lazyCombinedIter.combinedIterState = combinedIterState{
+		initialized: !i.opts.rangeKeys(),
+	}
+	i.invalidate()
+	finishInitializingIter(i.alloc)
+}
+
 func (i *Iterator) invalidate() {
 	i.lastPositioningOp = unknownLastPositionOp
+	i.seekCachePending = false
+	if i.seekCache != nil {
+		i.seekCache.reset()
+	}
 	i.hasPrefix = false
 	i.iterKey = nil
 	i.iterValue = nil
+	i.lazyValue = false
 	i.err = nil
 	// This switch statement isn't necessary for correctness since callers
 	// should call a repositioning method. We could have arbitrarily set i.pos
@@ -2081,10 +2526,28 @@ func (i *Iterator) Metrics() IteratorMetrics {
 
 // ResetStats resets the stats to 0.
 func (i *Iterator) ResetStats() {
+	i.recordRangeKeyIterOps()
 	i.stats = IteratorStats{}
 	i.iter.ResetStats()
 }
 
+// recordRangeKeyIterOps adds the positioning operations recorded in the
+// iterator's stats to the DB's range key metrics, if the iterator surfaces
+// range keys.
+func (i *Iterator) recordRangeKeyIterOps() {
+	if i.readState == nil || !i.opts.rangeKeys() {
+		return
+	}
+	var ops int
+	for _, n := range [...]int{
+		i.stats.ForwardSeekCount[InterfaceCall], i.stats.ReverseSeekCount[InterfaceCall],
+		i.stats.ForwardStepCount[InterfaceCall], i.stats.ReverseStepCount[InterfaceCall],
+	} {
+		ops += n
+	}
+	atomic.AddInt64(&i.readState.db.atomic.rangeKeyIterOps, int64(ops))
+}
+
 // Stats returns the current stats.
 func (i *Iterator) Stats() IteratorStats {
 	stats := i.stats
@@ -2092,6 +2555,74 @@ func (i *Iterator) Stats() IteratorStats {
 	return stats
 }
 
+// WriteStats writes a human-readable breakdown of the iterator's stats to w.
+// In addition to the information returned by Stats, the output includes the
+// iterator's bounds, formatted with the Comparer's FormatKey, and the block
+// reads and bloom filter probes performed by each level of the LSM.
+//
+// WriteStats is intended for debugging, eg, logging what a slow scan did once
+// it completes.
+func (i *Iterator) WriteStats(w io.Writer) {
+	formatKey := i.formatKey
+	if formatKey == nil {
+		formatKey = DefaultComparer.FormatKey
+	}
+	stats := i.Stats()
+
+	fmt.Fprintf(w, "bounds: [")
+	if i.opts.LowerBound != nil {
+		fmt.Fprintf(w, "%s", formatKey(i.opts.LowerBound))
+	}
+	fmt.Fprintf(w, ", ")
+	if i.opts.UpperBound != nil {
+		fmt.Fprintf(w, "%s", formatKey(i.opts.UpperBound))
+	}
+	fmt.Fprintf(w, ")\n")
+	for k := range stats.ForwardSeekCount {
+		switch IteratorStatsKind(k) {
+		case InterfaceCall:
+			fmt.Fprintf(w, "interface: ")
+		case InternalIterCall:
+			fmt.Fprintf(w, "internal: ")
+		}
+		fmt.Fprintf(w, "seeks (fwd %d, rev %d), steps (fwd %d, rev %d)\n",
+			stats.ForwardSeekCount[k], stats.ReverseSeekCount[k],
+			stats.ForwardStepCount[k], stats.ReverseStepCount[k])
+	}
+	s := &stats.InternalStats
+	fmt.Fprintf(w, "points: count %s, key-bytes %s, value-bytes %s, tombstoned %s\n",
+		humanize.SI.Uint64(s.PointCount), humanize.SI.Uint64(s.KeyBytes),
+		humanize.SI.Uint64(s.ValueBytes), humanize.SI.Uint64(s.PointsCoveredByRangeTombstones))
+	writeBlockStats := func(s *InternalIteratorStats) {
+		fmt.Fprintf(w, "blocks %d (total %s, cached %s), filter probes %d (negative %d)\n",
+			s.BlockReads, humanize.IEC.Uint64(s.BlockBytes), humanize.IEC.Uint64(s.BlockBytesInCache),
+			s.FilterProbes, s.FilterNegatives)
+	}
+	fmt.Fprintf(w, "total: ")
+	writeBlockStats(s)
+
+	m, ok := i.pointIter.(*mergingIter)
+	if !ok {
+		return
+	}
+	for j := range m.levels {
+		var name string
+		switch li := m.levels[j].iter.(type) {
+		case *levelIter:
+			name = li.level.String()
+		default:
+			if j == 0 && i.batch != nil {
+				name = "batch"
+			} else {
+				name = "memtable"
+			}
+		}
+		levelStats := m.levels[j].iter.Stats()
+		fmt.Fprintf(w, "  %s: ", name)
+		writeBlockStats(&levelStats)
+	}
+}
+
 // CloneOptions configures an iterator constructed through Iterator.Clone.
 type CloneOptions struct {
 	// IterOptions, if non-nil, define the iterator options to configure a
@@ -2146,6 +2677,7 @@ func (i *Iterator) Clone(opts CloneOptions) (*Iterator, error) {
 		equal:               i.equal,
 		merge:               i.merge,
 		split:               i.split,
+		formatKey:           i.formatKey,
 		readState:           readState,
 		keyBuf:              buf.keyBuf,
 		prefixOrFullSeekKey: buf.prefixOrFullSeekKey,
@@ -2155,6 +2687,7 @@ func (i *Iterator) Clone(opts CloneOptions) (*Iterator, error) {
 		newIters:            i.newIters,
 		newIterRangeKey:     i.newIterRangeKey,
 		seqNum:              i.seqNum,
+		minSeqNum:           i.minSeqNum,
 	}
 	dbi.saveBounds(dbi.opts.LowerBound, dbi.opts.UpperBound)
 
@@ -2164,6 +2697,7 @@ func (i *Iterator) Clone(opts CloneOptions) (*Iterator, error) {
 		dbi.batchSeqNum = (uint64(len(i.batch.data)) | base.InternalKeySeqNumBatch)
 	}
 
+	readState.db.trackIterOpened(dbi)
 	return finishInitializingIter(buf), nil
 }
 
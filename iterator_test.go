@@ -1549,6 +1549,398 @@ func TestIteratorGuaranteedDurable(t *testing.T) {
 	})
 }
 
+func TestIteratorRefresh(t *testing.T) {
+	d, err := Open("", &Options{
+		FS:                          vfs.NewMem(),
+		DisableAutomaticCompactions: true,
+	})
+	require.NoError(t, err)
+	defer func() { require.NoError(t, d.Close()) }()
+
+	scan := func(iter *Iterator) string {
+		var buf strings.Builder
+		for valid := iter.First(); valid; valid = iter.Next() {
+			fmt.Fprintf(&buf, "%s:%s ", iter.Key(), iter.Value())
+		}
+		require.NoError(t, iter.Error())
+		return strings.TrimSpace(buf.String())
+	}
+
+	require.NoError(t, d.Set([]byte("a"), []byte("1"), nil))
+	require.NoError(t, d.Flush())
+	iter := d.NewIter(&IterOptions{UpperBound: []byte("c")})
+	require.NoError(t, d.Set([]byte("a"), []byte("2"), nil))
+	require.NoError(t, d.Set([]byte("b"), []byte("2"), nil))
+	require.Equal(t, "a:1", scan(iter))
+
+	// Refreshing the iterator observes the current state of the DB, and
+	// retains the iterator's options.
+	iter.Refresh(nil)
+	require.False(t, iter.Valid())
+	require.Equal(t, "a:2 b:2", scan(iter))
+
+	s := d.NewSnapshot()
+	require.NoError(t, d.Delete([]byte("a"), nil))
+	iter.Refresh(s)
+	require.Equal(t, "a:2 b:2", scan(iter))
+	iter.Refresh(nil)
+	require.Equal(t, "b:2", scan(iter))
+	require.NoError(t, s.Close())
+
+	// The sstables compacted away while pinned by the iterator are released
+	// once the iterator is refreshed.
+	require.NoError(t, d.Flush())
+	iter.Refresh(nil)
+	require.NoError(t, d.Compact([]byte("a"), []byte("c"), false /* parallelize */))
+	require.NotZero(t, d.Metrics().Table.ZombieCount)
+	iter.Refresh(nil)
+	// Obsolete sstables are deleted in the background.
+	for i := 0; d.Metrics().Table.ZombieCount > 0; i++ {
+		require.Less(t, i, 1000)
+		time.Sleep(time.Millisecond)
+	}
+	require.Equal(t, "b:2", scan(iter))
+	require.NoError(t, iter.Close())
+
+	// A closed iterator can't be refreshed.
+	require.Panics(t, func() { iter.Refresh(nil) })
+
+	// Refreshing an iterator over an indexed batch also refreshes its view of
+	// the batch.
+	b := d.NewIndexedBatch()
+	iter = b.NewIter(nil)
+	require.NoError(t, b.Set([]byte("c"), []byte("3"), nil))
+	require.NoError(t, d.Set([]byte("d"), []byte("4"), nil))
+	require.Equal(t, "b:2", scan(iter))
+	iter.Refresh(nil)
+	require.Equal(t, "b:2 c:3 d:4", scan(iter))
+	require.NoError(t, iter.Close())
+	require.NoError(t, b.Close())
+}
+
+func TestIteratorKeyPrefixSuffix(t *testing.T) {
+	d, err := Open("", &Options{
+		FS:       vfs.NewMem(),
+		Comparer: testkeys.Comparer,
+	})
+	require.NoError(t, err)
+	defer func() { require.NoError(t, d.Close()) }()
+
+	for _, k := range []string{"a", "b@3", "b@10", "cc@1"} {
+		require.NoError(t, d.Set([]byte(k), nil, nil))
+	}
+	iter := d.NewIter(nil)
+	var got []string
+	for valid := iter.First(); valid; valid = iter.Next() {
+		got = append(got, fmt.Sprintf("%s|%s", iter.KeyPrefix(), iter.KeySuffix()))
+		require.Equal(t, iter.Key(), append(iter.KeyPrefix(), iter.KeySuffix()...))
+	}
+	require.Equal(t, []string{"a|", "b|@10", "b|@3", "cc|@1"}, got)
+	require.Nil(t, iter.KeyPrefix())
+	require.Nil(t, iter.KeySuffix())
+	require.NoError(t, iter.Close())
+}
+
+func TestIteratorOnCorruption(t *testing.T) {
+	mem := vfs.NewMem()
+	opts := &Options{FS: mem, DisableAutomaticCompactions: true}
+	opts.Levels = []LevelOptions{{BlockSize: 1}}
+	d, err := Open("", opts)
+	require.NoError(t, err)
+	for _, k := range []string{"a", "b", "c"} {
+		require.NoError(t, d.Set([]byte(k), []byte(k), nil))
+	}
+	require.NoError(t, d.Flush())
+	tables, err := d.SSTables()
+	require.NoError(t, err)
+	require.Len(t, tables[0], 1)
+	fileNum := tables[0][0].FileNum
+	require.NoError(t, d.Close())
+
+	// Corrupt the data block holding "b".
+	path := base.MakeFilepath(mem, "", fileTypeTable, fileNum)
+	f, err := mem.Open(path)
+	require.NoError(t, err)
+	r, err := sstable.NewReader(f, sstable.ReaderOptions{})
+	require.NoError(t, err)
+	layout, err := r.Layout()
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+	require.Len(t, layout.Data, 3)
+	f, err = mem.Open(path)
+	require.NoError(t, err)
+	data, err := io.ReadAll(f)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	data[layout.Data[1].Offset] ^= 0xff
+	f, err = mem.Create(path)
+	require.NoError(t, err)
+	_, err = f.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	d, err = Open("", opts)
+	require.NoError(t, err)
+	defer func() { require.NoError(t, d.Close()) }()
+
+	scan := func(o *IterOptions) (string, error) {
+		iter := d.NewIter(o)
+		var buf strings.Builder
+		for valid := iter.First(); valid; valid = iter.Next() {
+			fmt.Fprintf(&buf, "%s ", iter.Key())
+		}
+		return buf.String(), iter.Close()
+	}
+
+	_, err = scan(nil)
+	require.True(t, errors.Is(err, ErrCorruption))
+
+	var skipped []string
+	keys, err := scan(&IterOptions{
+		OnCorruption: func(file FileNum, offset int64, err error) CorruptionAction {
+			require.Equal(t, fileNum, file)
+			require.EqualValues(t, layout.Data[1].Offset, offset)
+			var cbe *CorruptBlockError
+			require.True(t, errors.As(err, &cbe))
+			skipped = append(skipped, string(cbe.LastKey))
+			return CorruptionSkipBlock
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "a c ", keys)
+	require.Equal(t, []string{"b"}, skipped)
+}
+
+func TestIteratorOnKeyAccess(t *testing.T) {
+	var accessed []string
+	onKeyAccess := func(key []byte) {
+		accessed = append(accessed, string(key))
+	}
+	opts := &Options{FS: vfs.NewMem()}
+	opts.Experimental.OnGetKeyAccess = onKeyAccess
+	d, err := Open("", opts)
+	require.NoError(t, err)
+	defer func() { require.NoError(t, d.Close()) }()
+	for _, k := range []string{"a", "b", "c", "d"} {
+		require.NoError(t, d.Set([]byte(k), nil, nil))
+	}
+	require.NoError(t, d.Delete([]byte("c"), nil))
+
+	iter := d.NewIter(&IterOptions{OnKeyAccess: onKeyAccess})
+	for valid := iter.First(); valid; valid = iter.Next() {
+	}
+	iter.SeekGE([]byte("b"))
+	iter.SeekGE([]byte("b")) // noop seek
+	iter.SeekLT([]byte("a"))
+	iter.Last()
+	iter.Prev()
+	require.NoError(t, iter.Close())
+	require.Equal(t, []string{"a", "b", "d", "b", "b", "d", "b"}, accessed)
+
+	// Get reports the key through Options.Experimental.OnGetKeyAccess.
+	accessed = accessed[:0]
+	_, closer, err := d.Get([]byte("b"))
+	require.NoError(t, err)
+	require.NoError(t, closer.Close())
+	_, _, err = d.Get([]byte("c"))
+	require.Equal(t, ErrNotFound, err)
+	require.Equal(t, []string{"b"}, accessed)
+}
+
+func TestIteratorDisableCacheFill(t *testing.T) {
+	c := NewCache(1 << 20)
+	defer c.Unref()
+	d, err := Open("", &Options{FS: vfs.NewMem(), Cache: c})
+	require.NoError(t, err)
+	defer func() { require.NoError(t, d.Close()) }()
+	for i := 0; i < 1000; i++ {
+		require.NoError(t, d.Set([]byte(fmt.Sprintf("%04d", i)), bytes.Repeat([]byte("v"), 100), nil))
+	}
+	require.NoError(t, d.Flush())
+
+	scan := func(o *IterOptions) {
+		iter := d.NewIter(o)
+		var n int
+		for valid := iter.First(); valid; valid = iter.Next() {
+			n++
+		}
+		require.Equal(t, 1000, n)
+		require.NoError(t, iter.Close())
+	}
+
+	// Opening the sstable in the table cache reads its metaindex and
+	// properties blocks through the block cache, regardless of the options of
+	// the iterator opening it.
+	scan(&IterOptions{DisableCacheFill: true})
+	before := d.Metrics().BlockCache
+	scan(&IterOptions{DisableCacheFill: true})
+	after := d.Metrics().BlockCache
+	require.Equal(t, before.Count, after.Count)
+	require.Equal(t, before.Size, after.Size)
+	require.Greater(t, after.Misses, before.Misses)
+
+	scan(nil)
+	require.Greater(t, d.Metrics().BlockCache.Count, after.Count)
+}
+
+func TestIteratorRangeKeyResolver(t *testing.T) {
+	d, err := Open("", &Options{
+		FS:                 vfs.NewMem(),
+		Comparer:           testkeys.Comparer,
+		FormatMajorVersion: FormatNewest,
+	})
+	require.NoError(t, err)
+	defer func() { require.NoError(t, d.Close()) }()
+	require.NoError(t, d.RangeKeySet([]byte("a"), []byte("e"), []byte("@1"), []byte("3"), nil))
+	require.NoError(t, d.RangeKeySet([]byte("c"), []byte("g"), []byte("@2"), []byte("9"), nil))
+	require.NoError(t, d.RangeKeySet([]byte("c"), []byte("g"), []byte("@3"), []byte("5"), nil))
+
+	var calls int
+	maxValue := func(keys []RangeKeyData) RangeKeyData {
+		calls++
+		r := keys[0]
+		for _, k := range keys[1:] {
+			if bytes.Compare(k.Value, r.Value) > 0 {
+				r = k
+			}
+		}
+		return r
+	}
+	scan := func(iter *Iterator) string {
+		var buf bytes.Buffer
+		for valid := iter.First(); valid; valid = iter.Next() {
+			start, end := iter.RangeBounds()
+			fmt.Fprintf(&buf, "[%s,%s):", start, end)
+			for _, k := range iter.RangeKeys() {
+				fmt.Fprintf(&buf, " %s=%s", k.Suffix, k.Value)
+			}
+			buf.WriteString("\n")
+		}
+		return buf.String()
+	}
+
+	iter := d.NewIter(&IterOptions{KeyTypes: IterKeyTypeRangesOnly})
+	require.Equal(t, "[a,c): @1=3\n[c,e): @3=5 @2=9 @1=3\n[e,g): @3=5 @2=9\n", scan(iter))
+
+	// The resolver is called once per span, surfacing a single range key.
+	iter.SetOptions(&IterOptions{KeyTypes: IterKeyTypeRangesOnly, RangeKeyResolver: maxValue})
+	require.Equal(t, "[a,c): @1=3\n[c,e): @2=9\n[e,g): @2=9\n", scan(iter))
+	require.Equal(t, 3, calls)
+
+	// Removing the resolver restores the unresolved range keys.
+	iter.SetOptions(&IterOptions{KeyTypes: IterKeyTypeRangesOnly})
+	require.Equal(t, "[a,c): @1=3\n[c,e): @3=5 @2=9 @1=3\n[e,g): @3=5 @2=9\n", scan(iter))
+	require.NoError(t, iter.Close())
+}
+
+func TestIteratorPrefetchBlocks(t *testing.T) {
+	d, err := Open("", &Options{
+		FS:     vfs.NewMem(),
+		Levels: []LevelOptions{{BlockSize: 32}},
+	})
+	require.NoError(t, err)
+	defer func() { require.NoError(t, d.Close()) }()
+	for i := 0; i < 1000; i++ {
+		require.NoError(t, d.Set([]byte(fmt.Sprintf("%04d", i)), []byte("value"), nil))
+	}
+	require.NoError(t, d.Flush())
+
+	scan := func(o *IterOptions) IteratorStats {
+		iter := d.NewIter(o)
+		var n int
+		for valid := iter.First(); valid; valid = iter.Next() {
+			n++
+		}
+		require.Equal(t, 1000, n)
+		stats := iter.Stats()
+		require.NoError(t, iter.Close())
+		return stats
+	}
+	stats := scan(&IterOptions{PrefetchBlocks: 4})
+	require.Greater(t, stats.InternalStats.PrefetchHits+stats.InternalStats.PrefetchMisses, uint64(0))
+	stats = scan(&IterOptions{PrefetchBlocks: 4, DisableCacheFill: true})
+	require.Zero(t, stats.InternalStats.PrefetchHits+stats.InternalStats.PrefetchMisses)
+}
+
+func TestIteratorPeek(t *testing.T) {
+	d, err := Open("", &Options{
+		FS:                 vfs.NewMem(),
+		Comparer:           testkeys.Comparer,
+		FormatMajorVersion: FormatNewest,
+	})
+	require.NoError(t, err)
+	defer func() { require.NoError(t, d.Close()) }()
+	// Shadowed versions of keys, in both sstables and the memtable, must be
+	// skipped when peeking.
+	for _, k := range []string{"a", "b", "c", "d", "e"} {
+		require.NoError(t, d.Set([]byte(k), []byte(k+"1"), nil))
+	}
+	require.NoError(t, d.Flush())
+	require.NoError(t, d.Set([]byte("b"), []byte("b2"), nil))
+	require.NoError(t, d.Delete([]byte("c"), nil))
+	require.NoError(t, d.Merge([]byte("d"), []byte("d2"), nil))
+	require.NoError(t, d.RangeKeySet([]byte("d"), []byte("f"), nil, []byte("r"), nil))
+
+	for _, o := range []*IterOptions{
+		{KeyTypes: IterKeyTypePointsOnly},
+		{KeyTypes: IterKeyTypePointsAndRanges},
+	} {
+		iter := d.NewIter(o)
+		var peeked []string
+		valid := iter.First()
+		for valid {
+			key := string(iter.Key())
+			value := string(iter.Value())
+			rangeKeyChanged := iter.RangeKeyChanged()
+			next, ok := iter.Peek()
+			// Peeking doesn't move the iterator.
+			require.True(t, iter.Valid())
+			require.Equal(t, key, string(iter.Key()))
+			require.Equal(t, value, string(iter.Value()))
+			require.Equal(t, rangeKeyChanged, iter.RangeKeyChanged())
+			if !ok {
+				require.Nil(t, next)
+			} else {
+				peeked = append(peeked, string(next))
+			}
+			valid = iter.Next()
+			require.Equal(t, ok, valid)
+			if ok {
+				require.Equal(t, string(next), string(iter.Key()))
+			}
+		}
+		require.Equal(t, []string{"b", "d", "e"}, peeked)
+		_, ok := iter.Peek()
+		require.False(t, ok)
+
+		// Peek while iterating in reverse.
+		require.True(t, iter.Last())
+		require.True(t, iter.Prev())
+		next, ok := iter.Peek()
+		require.True(t, ok)
+		require.Equal(t, "e", string(next))
+		require.Equal(t, "d", string(iter.Key()))
+		require.True(t, iter.Prev())
+		require.Equal(t, "b", string(iter.Key()))
+		require.NoError(t, iter.Close())
+	}
+
+	// In prefix iteration mode, Peek only surfaces keys with the same prefix.
+	require.NoError(t, d.Set([]byte("b@2"), nil, nil))
+	iter := d.NewIter(nil)
+	require.True(t, iter.SeekPrefixGE([]byte("b")))
+	next, ok := iter.Peek()
+	require.True(t, ok)
+	require.Equal(t, "b@2", string(next))
+	require.Equal(t, "b", string(iter.Key()))
+	require.True(t, iter.Next())
+	_, ok = iter.Peek()
+	require.False(t, ok)
+	require.Equal(t, "b@2", string(iter.Key()))
+	require.False(t, iter.Next())
+	require.NoError(t, iter.Close())
+}
+
 func TestIteratorBoundsLifetimes(t *testing.T) {
 	d := newTestkeysDatabase(t, testkeys.Alpha(2))
 	defer func() { require.NoError(t, d.Close()) }()
@@ -1727,6 +2119,7 @@ func testSetOptionsEquivalence(t *testing.T, seed uint64) {
 			o.RangeKeyMasking.Suffix = testkeys.Suffix(rng.Intn(ks.Count()))
 		}
 		o.OnlyReadGuaranteedDurable = rng.Intn(10) == 0 // 10% probability
+		o.DisableCacheFill = rng.Intn(4) == 0           // 25% probability
 	}
 
 	var longLivedIter, newIter *Iterator
@@ -1799,6 +2192,9 @@ func iterOptionsString(o *IterOptions) string {
 	if o.UseL6Filters {
 		fmt.Fprintf(&buf, ", use-L6-filters")
 	}
+	if o.DisableCacheFill {
+		fmt.Fprintf(&buf, ", disable-cache-fill")
+	}
 	for i, pkf := range o.PointKeyFilters {
 		fmt.Fprintf(&buf, ", point-key-filter[%d]=%q", i, pkf.Name())
 	}
@@ -1808,6 +2204,217 @@ func iterOptionsString(o *IterOptions) string {
 	return buf.String()
 }
 
+func TestIteratorSeekCache(t *testing.T) {
+	d, err := Open("", &Options{FS: vfs.NewMem()})
+	require.NoError(t, err)
+	defer func() { require.NoError(t, d.Close()) }()
+	for _, k := range []string{"a", "c", "e"} {
+		require.NoError(t, d.Set([]byte(k), []byte(k+"1"), nil))
+	}
+	require.NoError(t, d.Flush())
+	require.NoError(t, d.Set([]byte("c"), []byte("c2"), nil))
+	require.NoError(t, d.Delete([]byte("e"), nil))
+
+	iter := d.NewIter(&IterOptions{SeekCacheSize: 2})
+	internalSeeks := func() int {
+		return iter.Stats().ForwardSeekCount[InternalIterCall]
+	}
+	seek := func(key string) string {
+		if !iter.SeekGE([]byte(key)) {
+			return "."
+		}
+		return fmt.Sprintf("%s:%s", iter.Key(), iter.Value())
+	}
+
+	require.Equal(t, "c:c2", seek("b"))
+	require.Equal(t, ".", seek("d"))
+	require.Equal(t, 2, internalSeeks())
+
+	// Repeated seeks are served by the cache.
+	require.Equal(t, "c:c2", seek("b"))
+	require.Equal(t, ".", seek("d"))
+	require.Equal(t, "c:c2", seek("b"))
+	require.Equal(t, 2, internalSeeks())
+
+	// Stepping the iterator after a cache hit repositions it.
+	require.True(t, iter.Prev())
+	require.Equal(t, "a", string(iter.Key()))
+	require.Equal(t, 3, internalSeeks())
+	require.Equal(t, "c:c2", seek("b"))
+	require.False(t, iter.Next())
+	require.Equal(t, ".", seek("d"))
+	require.True(t, iter.Prev())
+	require.Equal(t, "c", string(iter.Key()))
+
+	// The oldest result is replaced when the cache is full.
+	n := internalSeeks()
+	require.Equal(t, "a:a1", seek(""))
+	require.Equal(t, ".", seek("d"))
+	require.Equal(t, "c:c2", seek("b"))
+	require.Equal(t, n+2, internalSeeks())
+
+	// Changing the bounds empties the cache.
+	iter.SetBounds(nil, []byte("c"))
+	require.Equal(t, ".", seek("b"))
+	require.Equal(t, n+3, internalSeeks())
+	require.Equal(t, ".", seek("b"))
+	require.Equal(t, n+3, internalSeeks())
+	require.NoError(t, iter.Close())
+}
+
+func TestIteratorSampleStride(t *testing.T) {
+	d, err := Open("", &Options{FS: vfs.NewMem()})
+	require.NoError(t, err)
+	defer func() { require.NoError(t, d.Close()) }()
+	for i := 0; i < 50; i++ {
+		k := []byte(fmt.Sprintf("k%02d", i))
+		require.NoError(t, d.Set(k, []byte("old"), nil))
+	}
+	require.NoError(t, d.Flush())
+	// Overwrite and delete some of the keys in the memtable, so that the
+	// stride lands on keys with multiple versions.
+	require.NoError(t, d.Set([]byte("k10"), []byte("new"), nil))
+	require.NoError(t, d.Delete([]byte("k20"), nil))
+
+	sample := func(o *IterOptions) string {
+		iter := d.NewIter(o)
+		var s string
+		for valid := iter.First(); valid; valid = iter.Next() {
+			s += fmt.Sprintf("%s:%s ", iter.Key(), iter.Value())
+		}
+		require.NoError(t, iter.Close())
+		return s
+	}
+	// The deleted key k20 counts towards the stride, but is not surfaced,
+	// nor is its shadowed value.
+	require.Equal(t, "k00:old k10:new k21:old k31:old k41:old ",
+		sample(&IterOptions{SampleStride: 10}))
+	require.Equal(t, "k25:old k35:old ",
+		sample(&IterOptions{SampleStride: 10, LowerBound: []byte("k25"), UpperBound: []byte("k45")}))
+
+	// A stride of 1 or less iterates over every key.
+	require.Equal(t, sample(nil), sample(&IterOptions{SampleStride: 1}))
+}
+
+func TestIteratorPrefixBound(t *testing.T) {
+	d, err := Open("", &Options{FS: vfs.NewMem()})
+	require.NoError(t, err)
+	defer func() { require.NoError(t, d.Close()) }()
+	for _, k := range []string{
+		"a", "ab", "ab\xff", "ab\xff\xff", "ac", "b", "\xff", "\xff\xff", "\xff\xffz",
+	} {
+		require.NoError(t, d.Set([]byte(k), nil, nil))
+	}
+
+	// scan returns the keys visible to iter, and checks that iterating in
+	// reverse surfaces the same keys.
+	scan := func(iter *Iterator) string {
+		var fwd, rev []string
+		for valid := iter.First(); valid; valid = iter.Next() {
+			fwd = append(fwd, fmt.Sprintf("%q", iter.Key()))
+		}
+		for valid := iter.Last(); valid; valid = iter.Prev() {
+			rev = append([]string{fmt.Sprintf("%q", iter.Key())}, rev...)
+		}
+		require.Equal(t, fwd, rev)
+		return strings.Join(fwd, " ")
+	}
+	testCases := []struct {
+		prefix, lower, upper string
+		expected             string
+	}{
+		{prefix: "ab", expected: `"ab" "ab\xff" "ab\xff\xff"`},
+		{prefix: "ab\xff", expected: `"ab\xff" "ab\xff\xff"`},
+		{prefix: "\xff", expected: `"\xff" "\xff\xff" "\xff\xffz"`},
+		{prefix: "\xff\xff", expected: `"\xff\xff" "\xff\xffz"`},
+		{prefix: "ab", lower: "ab\xff", expected: `"ab\xff" "ab\xff\xff"`},
+		{prefix: "ab", upper: "ab\xff\xff", expected: `"ab" "ab\xff"`},
+		{prefix: "ab", lower: "a", upper: "b", expected: `"ab" "ab\xff" "ab\xff\xff"`},
+		{prefix: "ab", lower: "ac", expected: ``},
+		{prefix: "ab", upper: "a", expected: ``},
+		{prefix: "", lower: "ac", upper: "\xff", expected: `"ac" "b"`},
+	}
+	for _, tc := range testCases {
+		o := &IterOptions{PrefixBound: []byte(tc.prefix)}
+		if tc.lower != "" {
+			o.LowerBound = []byte(tc.lower)
+		}
+		if tc.upper != "" {
+			o.UpperBound = []byte(tc.upper)
+		}
+		iter := d.NewIter(o)
+		require.Equal(t, tc.expected, scan(iter), "%q [%q, %q)", tc.prefix, tc.lower, tc.upper)
+		require.NoError(t, iter.Close())
+	}
+
+	// The prefix continues to constrain bounds set after construction, and
+	// is copied rather than retained.
+	prefix := []byte("ab")
+	iter := d.NewIter(&IterOptions{PrefixBound: prefix})
+	prefix[0] = 'b'
+	iter.SetBounds([]byte("ab\xff"), nil)
+	require.Equal(t, `"ab\xff" "ab\xff\xff"`, scan(iter))
+	iter.SetBounds(nil, nil)
+	require.Equal(t, `"ab" "ab\xff" "ab\xff\xff"`, scan(iter))
+	// SetOptions replaces the prefix.
+	iter.SetOptions(&IterOptions{PrefixBound: []byte("a")})
+	require.Equal(t, `"a" "ab" "ab\xff" "ab\xff\xff" "ac"`, scan(iter))
+	iter.SetOptions(&IterOptions{})
+	require.Equal(t, 9, len(strings.Fields(scan(iter))))
+	require.NoError(t, iter.Close())
+}
+
+func TestIteratorSampleStrideIndex(t *testing.T) {
+	d, err := Open("", &Options{
+		FS:     vfs.NewMem(),
+		Levels: []LevelOptions{{BlockSize: 256, IndexBlockSize: 256}},
+	})
+	require.NoError(t, err)
+	defer func() { require.NoError(t, d.Close()) }()
+	const n = 10000
+	for i := 0; i < n; i++ {
+		k := []byte(fmt.Sprintf("k%05d", i))
+		require.NoError(t, d.Set(k, bytes.Repeat([]byte("v"), 16), nil))
+	}
+	require.NoError(t, d.Compact([]byte("k"), []byte("l"), false /* parallelize */))
+	m := d.Metrics()
+	require.EqualValues(t, 0, m.Levels[0].NumFiles)
+	require.Less(t, int64(0), m.Levels[numLevels-1].NumFiles)
+
+	// sample returns the indexes of the keys surfaced, and the number of
+	// blocks read.
+	sample := func(o *IterOptions) ([]int, uint64) {
+		iter := d.NewIter(o)
+		var keys []int
+		for valid := iter.First(); valid; valid = iter.Next() {
+			k, err := strconv.Atoi(string(iter.Key()[1:]))
+			require.NoError(t, err)
+			keys = append(keys, k)
+		}
+		stats := iter.Stats()
+		require.NoError(t, iter.Close())
+		return keys, stats.InternalStats.BlockReads
+	}
+	_, scanBlocks := sample(nil)
+
+	// The surfaced keys are approximately the stride apart, within the
+	// number of keys in a data block.
+	keys, blocks := sample(&IterOptions{SampleStride: 1000})
+	require.InDelta(t, n/1000, len(keys), 1)
+	for j := 1; j < len(keys); j++ {
+		require.InDelta(t, 1000, keys[j]-keys[j-1], 20)
+	}
+	// The data blocks stepped over aren't read.
+	require.Less(t, blocks, scanBlocks/10)
+
+	// The stride respects the iterator's bounds.
+	keys, _ = sample(&IterOptions{
+		SampleStride: 1000, LowerBound: []byte("k02500"), UpperBound: []byte("k05000"),
+	})
+	require.Equal(t, 2500, keys[0])
+	require.Len(t, keys, 3)
+}
+
 func newTestkeysDatabase(t *testing.T, ks testkeys.Keyspace) *DB {
 	dbOpts := &Options{
 		Comparer:           testkeys.Comparer,
@@ -2063,6 +2670,47 @@ func BenchmarkIteratorSeekGENoop(b *testing.B) {
 	}
 }
 
+func BenchmarkIteratorSeekGERepeated(b *testing.B) {
+	d, err := Open("", &Options{FS: vfs.NewMem(), DisableAutomaticCompactions: true})
+	require.NoError(b, err)
+	defer func() { require.NoError(b, d.Close()) }()
+
+	// Write overlapping keys into sstables in L6, L0 and the memtable, so that
+	// a seek must merge across all of them.
+	const keyCount = 100000
+	for _, stride := range []int{1, 7, 13} {
+		for i := 0; i < keyCount; i += stride {
+			require.NoError(b, d.Set([]byte(fmt.Sprintf("%08d", i)), []byte("value"), nil))
+		}
+		if stride == 1 {
+			require.NoError(b, d.Compact([]byte("0"), []byte("9"), false /* parallelize */))
+		} else if stride == 7 {
+			require.NoError(b, d.Flush())
+		}
+	}
+
+	const hotKeys = 16
+	rng := rand.New(rand.NewSource(uint64(time.Now().UnixNano())))
+	var keys [][]byte
+	for i := 0; i < hotKeys; i++ {
+		keys = append(keys, []byte(fmt.Sprintf("%08d", rng.Intn(keyCount))))
+	}
+	order := rng.Perm(1024)
+	for _, cacheSize := range []int{0, hotKeys} {
+		b.Run(fmt.Sprintf("seekCacheSize=%d", cacheSize), func(b *testing.B) {
+			iter := d.NewIter(&IterOptions{SeekCacheSize: cacheSize})
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if !iter.SeekGE(keys[order[i%len(order)]%hotKeys]) {
+					b.Fatal("should be valid")
+				}
+			}
+			b.StopTimer()
+			require.NoError(b, iter.Close())
+		})
+	}
+}
+
 func BenchmarkBlockPropertyFilter(b *testing.B) {
 	rng := rand.New(rand.NewSource(uint64(time.Now().UnixNano())))
 	for _, matchInterval := range []int{1, 10, 100, 1000} {
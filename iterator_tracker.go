@@ -0,0 +1,84 @@
+// Copyright 2026 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package pebble
+
+import (
+	"runtime/debug"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// IteratorInfo describes an open iterator, as returned by DB.OldestIterators.
+type IteratorInfo struct {
+	// CreatedAt is the time at which the iterator was created.
+	CreatedAt time.Time
+	// Stack is the stack trace of the goroutine that created the iterator.
+	Stack string
+
+	// seq orders iterators created at the same time.
+	seq uint64
+}
+
+// iterTracker records the open iterators of a DB. See
+// Options.Experimental.TrackIteratorStacks.
+type iterTracker struct {
+	mu      sync.Mutex
+	nextSeq uint64
+	iters   map[*Iterator]IteratorInfo
+}
+
+// trackIterOpened records the creation of an iterator.
+func (d *DB) trackIterOpened(i *Iterator) {
+	atomic.AddInt64(&d.atomic.itersOpened, 1)
+	if t := d.iterTracker; t != nil {
+		info := IteratorInfo{
+			CreatedAt: d.timeNow(),
+			Stack:     string(debug.Stack()),
+		}
+		t.mu.Lock()
+		info.seq = t.nextSeq
+		t.nextSeq++
+		t.iters[i] = info
+		t.mu.Unlock()
+	}
+}
+
+// trackIterClosed records the closing of an iterator.
+func (d *DB) trackIterClosed(i *Iterator) {
+	atomic.AddInt64(&d.atomic.itersClosed, 1)
+	if t := d.iterTracker; t != nil {
+		t.mu.Lock()
+		delete(t.iters, i)
+		t.mu.Unlock()
+	}
+}
+
+// OldestIterators returns the creation time and stack of up to n of the
+// oldest open iterators, oldest first, or of all open iterators if n is
+// negative, to help identify iterators that have been leaked rather than
+// closed. It returns nil unless Options.Experimental.TrackIteratorStacks is
+// set. The number of open iterators is reported by Metrics.Iterators
+// regardless.
+func (d *DB) OldestIterators(n int) []IteratorInfo {
+	t := d.iterTracker
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	infos := make([]IteratorInfo, 0, len(t.iters))
+	for _, info := range t.iters {
+		infos = append(infos, info)
+	}
+	t.mu.Unlock()
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].seq < infos[j].seq
+	})
+	if n >= 0 && len(infos) > n {
+		infos = infos[:n]
+	}
+	return infos
+}
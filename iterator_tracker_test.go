@@ -0,0 +1,75 @@
+// Copyright 2026 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package pebble
+
+import (
+	"testing"
+
+	"github.com/cockroachdb/pebble/vfs"
+	"github.com/stretchr/testify/require"
+)
+
+// leakIterator creates an iterator that its caller intentionally leaks.
+func leakIterator(d *DB) *Iterator {
+	return d.NewIter(nil)
+}
+
+func TestIteratorTracking(t *testing.T) {
+	for _, track := range []bool{false, true} {
+		t.Run("", func(t *testing.T) {
+			opts := &Options{FS: vfs.NewMem()}
+			opts.Experimental.TrackIteratorStacks = track
+			d, err := Open("", opts)
+			require.NoError(t, err)
+			defer func() { require.NoError(t, d.Close()) }()
+			require.NoError(t, d.Set([]byte("a"), nil, nil))
+
+			requireCounts := func(count, opened, closed int64) {
+				t.Helper()
+				m := d.Metrics()
+				require.Equal(t, count, m.Iterators.Count)
+				require.Equal(t, opened, m.Iterators.Opened)
+				require.Equal(t, closed, m.Iterators.Closed)
+			}
+			requireCounts(0, 0, 0)
+
+			iter := d.NewIter(nil)
+			requireCounts(1, 1, 0)
+			require.NoError(t, iter.Close())
+			requireCounts(0, 1, 1)
+
+			// The iterators of snapshots and clones are tracked too.
+			snap := d.NewSnapshot()
+			iter = snap.NewIter(nil)
+			clone, err := iter.Clone(CloneOptions{})
+			require.NoError(t, err)
+			requireCounts(2, 3, 1)
+			require.NoError(t, iter.Close())
+			require.NoError(t, snap.Close())
+
+			leaked := leakIterator(d)
+			requireCounts(2, 4, 2)
+
+			oldest := d.OldestIterators(-1)
+			if !track {
+				require.Nil(t, oldest)
+			} else {
+				require.Len(t, oldest, 2)
+				require.Contains(t, oldest[0].Stack, "Clone")
+				require.Contains(t, oldest[1].Stack, "leakIterator")
+				require.False(t, oldest[1].CreatedAt.Before(oldest[0].CreatedAt))
+
+				oldest = d.OldestIterators(1)
+				require.Len(t, oldest, 1)
+				require.Contains(t, oldest[0].Stack, "Clone")
+			}
+
+			require.NoError(t, clone.Close())
+			require.NoError(t, leaked.Close())
+			requireCounts(0, 4, 4)
+			require.Empty(t, d.OldestIterators(-1))
+		})
+	}
+}
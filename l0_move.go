@@ -0,0 +1,104 @@
+// Copyright 2026 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package pebble
+
+import "github.com/cockroachdb/pebble/internal/manifest"
+
+// TrivialMoveL0 moves the L0 files whose key ranges overlap no other file in
+// L0 or in the base level directly down to the base level, and returns the
+// number of files moved. As with the trivial moves performed by compactions,
+// the files are moved by updating the LSM's metadata, without rewriting any
+// data. TrivialMoveL0 may be used to reduce read amplification after a burst
+// of ingestions into L0 of sstables that don't overlap one another, without
+// waiting for, or paying for, compactions of L0.
+//
+// Files being compacted, and files overlapping the output of an in-progress
+// compaction into the base level, are not moved. All of the files are moved
+// by a single move compaction, which is reported to the EventListener and in
+// Metrics like any other.
+func (d *DB) TrivialMoveL0() (int, error) {
+	if err := d.closed.Load(); err != nil {
+		panic(err)
+	}
+	if d.opts.ReadOnly {
+		return 0, ErrReadOnly
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.mu.compact.replacing > 0 {
+		return 0, nil
+	}
+	c := d.newL0MoveCompactionLocked()
+	if c == nil {
+		return 0, nil
+	}
+	d.addInProgressCompaction(c)
+	d.mu.compact.compactingCount++
+	err := d.compact1(c, nil /* errChannel */)
+	d.mu.compact.compactingCount--
+	d.maybeScheduleCompaction()
+	d.mu.compact.cond.Broadcast()
+	if err != nil {
+		return 0, err
+	}
+	return c.startLevel.files.Len(), nil
+}
+
+// newL0MoveCompactionLocked returns a move compaction of the L0 files that may
+// be moved to the base level by TrivialMoveL0, or nil if there are none.
+// Since no file moved overlaps another L0 file, the files moved don't overlap
+// one another either.
+//
+// d.mu must be held when calling this.
+func (d *DB) newL0MoveCompactionLocked() *compaction {
+	cur := d.mu.versions.currentVersion()
+	baseLevel := d.mu.versions.picker.getBaseLevel()
+	var files []*fileMetadata
+	iter := cur.Levels[0].Iter()
+	for f := iter.First(); f != nil; f = iter.Next() {
+		if f.Compacting {
+			continue
+		}
+		start, end := f.Smallest.UserKey, f.Largest.UserKey
+		exclusiveEnd := f.Largest.IsExclusiveSentinel()
+		l0Overlaps := cur.Overlaps(0, d.cmp, start, end, exclusiveEnd)
+		baseOverlaps := cur.Overlaps(baseLevel, d.cmp, start, end, exclusiveEnd)
+		if l0Overlaps.Len() != 1 || !baseOverlaps.Empty() {
+			continue
+		}
+		overlapsOutput := false
+		for c := range d.mu.compact.inProgress {
+			if c.outputLevel != nil && c.outputLevel.level == baseLevel &&
+				d.cmp(start, c.largest.UserKey) <= 0 && d.cmp(c.smallest.UserKey, end) <= 0 {
+				overlapsOutput = true
+				break
+			}
+		}
+		if !overlapsOutput {
+			files = append(files, f)
+		}
+	}
+	if len(files) == 0 {
+		return nil
+	}
+
+	c := &compaction{
+		kind:      compactionKindMove,
+		cmp:       d.cmp,
+		equal:     d.equal,
+		formatKey: d.opts.Comparer.FormatKey,
+		logger:    d.opts.Logger,
+		version:   cur,
+		inputs: []compactionLevel{
+			{level: 0, files: manifest.NewLevelSliceSeqSorted(files)},
+			{level: baseLevel},
+		},
+	}
+	c.startLevel = &c.inputs[0]
+	c.outputLevel = &c.inputs[1]
+	c.smallest, c.largest = manifest.KeyRange(d.cmp, c.startLevel.files.Iter())
+	return c
+}
@@ -0,0 +1,69 @@
+// Copyright 2026 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package pebble
+
+import (
+	"testing"
+
+	"github.com/cockroachdb/pebble/vfs"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrivialMoveL0(t *testing.T) {
+	opts := &Options{FS: vfs.NewMem(), DisableAutomaticCompactions: true}
+	d, err := Open("", opts)
+	require.NoError(t, err)
+	defer func() { require.NoError(t, d.Close()) }()
+
+	lsm := func() string {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		return d.mu.versions.currentVersion().String()
+	}
+	flush := func(keys ...string) {
+		for _, k := range keys {
+			require.NoError(t, d.Set([]byte(k), []byte(k), nil))
+		}
+		require.NoError(t, d.Flush())
+	}
+
+	n, err := d.TrivialMoveL0()
+	require.NoError(t, err)
+	require.Equal(t, 0, n)
+
+	// Only the L0 files that overlap neither another L0 file nor a file in the
+	// base level are moved.
+	flush("a")
+	require.NoError(t, d.Compact([]byte("a"), []byte("b"), false /* parallelize */))
+	flush("a")
+	flush("c")
+	flush("d")
+	flush("e", "g")
+	flush("f")
+	n, err = d.TrivialMoveL0()
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+	require.Equal(t, `0.1:
+  000015:[f#7,SET-f#7,SET]
+0.0:
+  000007:[a#2,SET-a#2,SET]
+  000013:[e#5,SET-g#6,SET]
+6:
+  000005:[a#1,SET-a#1,SET]
+  000009:[c#3,SET-c#3,SET]
+  000011:[d#4,SET-d#4,SET]
+`, lsm())
+	require.EqualValues(t, 3, d.Metrics().Levels[6].TablesMoved)
+
+	n, err = d.TrivialMoveL0()
+	require.NoError(t, err)
+	require.Equal(t, 0, n)
+	for _, k := range []string{"a", "c", "d", "e", "f", "g"} {
+		v, closer, err := d.Get([]byte(k))
+		require.NoError(t, err)
+		require.Equal(t, k, string(v))
+		require.NoError(t, closer.Close())
+	}
+}
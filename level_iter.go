@@ -27,6 +27,10 @@ type tableNewIters func(
 type internalIterOpts struct {
 	bytesIterated      *uint64
 	boundLimitedFilter sstable.BoundLimitedBlockPropertyFilter
+	// lazyValues, if true, has the sstable iterators defer reassembling values
+	// chunked into value blocks until they're fetched through
+	// base.FetchValue.
+	lazyValues bool
 }
 
 // levelIter provides a merged view of the sstables in a level.
@@ -235,6 +239,9 @@ func (l *levelIter) init(
 	l.tableOpts.TableFilter = opts.TableFilter
 	l.tableOpts.PointKeyFilters = opts.PointKeyFilters
 	l.tableOpts.UseL6Filters = opts.UseL6Filters
+	l.tableOpts.OnCorruption = opts.OnCorruption
+	l.tableOpts.DisableCacheFill = opts.DisableCacheFill
+	l.tableOpts.PrefetchBlocks = opts.PrefetchBlocks
 	l.tableOpts.level = l.level
 	l.cmp = cmp
 	l.split = split
@@ -1070,6 +1077,14 @@ func (l *levelIter) SetBounds(lower, upper []byte) {
 	l.iter.SetBounds(l.tableOpts.LowerBound, l.tableOpts.UpperBound)
 }
 
+// FetchValue implements base.LazyValueIterator.
+func (l *levelIter) FetchValue(value []byte) ([]byte, error) {
+	if l.iter == nil {
+		return value, nil
+	}
+	return base.FetchValue(l.iter, value)
+}
+
 func (l *levelIter) String() string {
 	if l.iterFile != nil {
 		return fmt.Sprintf("%s: fileNum=%s", l.level, l.iter.String())
@@ -0,0 +1,129 @@
+// Copyright 2026 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package pebble
+
+import (
+	"fmt"
+
+	"github.com/cockroachdb/pebble/internal/manifest"
+)
+
+// LSMStructure describes the shape of the LSM at a point in time, as returned
+// by DB.LSMView. It is a tree of levels, L0 sublevels and files, and may be
+// serialized as JSON.
+type LSMStructure struct {
+	// Levels holds each of the levels of the LSM, from L0 to L6, including
+	// empty levels.
+	Levels []LSMLevel
+}
+
+// LSMLevel describes a level of the LSM.
+type LSMLevel struct {
+	Level int
+	// Size is the total size of the files in the level in bytes.
+	Size uint64
+	// Sublevels holds the files of L0, grouped by L0 sublevel from the oldest
+	// sublevel to the newest. It is only populated for L0.
+	Sublevels []LSMSublevel `json:",omitempty"`
+	// Files holds the files of the level in key order. It is not populated for
+	// L0, whose files are found in Sublevels.
+	Files []LSMFile `json:",omitempty"`
+}
+
+// LSMSublevel describes an L0 sublevel.
+type LSMSublevel struct {
+	Sublevel int
+	// Files holds the files of the sublevel in key order.
+	Files []LSMFile
+}
+
+// LSMFile describes an sstable in the LSM.
+type LSMFile struct {
+	FileNum FileNum
+	// Size is the size of the file in bytes.
+	Size uint64
+	// Smallest and Largest are the bounds of the keys in the file.
+	Smallest LSMKey
+	Largest  LSMKey
+	// SmallestSeqNum and LargestSeqNum are the bounds of the sequence numbers
+	// of the keys in the file.
+	SmallestSeqNum uint64
+	LargestSeqNum  uint64
+	// Compacting is true if the file is an input of an in-progress compaction.
+	Compacting bool `json:",omitempty"`
+}
+
+// LSMKey describes a bound of the keys in an sstable.
+type LSMKey struct {
+	UserKey []byte
+	// Pretty is the user key formatted by the DB's Comparer.FormatKey.
+	Pretty string
+	SeqNum uint64
+	Kind   InternalKeyKind
+}
+
+// LSMView returns a description of the current shape of the LSM: its levels,
+// L0 sublevels and files, with the key bounds and size of each file. The
+// description is taken from a single version of the LSM, so it is consistent
+// even if flushes and compactions are running concurrently, though it may be
+// out of date by the time it is returned. LSMView reads no files, and its
+// cost is proportional to the number of files in the LSM, so it is cheap
+// enough to be polled, for example to visualize compactions as they happen.
+func (d *DB) LSMView() LSMStructure {
+	if err := d.closed.Load(); err != nil {
+		panic(err)
+	}
+
+	// Hold d.mu while building the description, since it protects the
+	// Compacting field of the files.
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	cur := d.mu.versions.currentVersion()
+
+	s := LSMStructure{Levels: make([]LSMLevel, len(cur.Levels))}
+	for level := range cur.Levels {
+		l := &s.Levels[level]
+		l.Level = level
+		files := cur.Levels[level].Slice()
+		l.Size = files.SizeSum()
+		if level > 0 {
+			l.Files = d.lsmViewFiles(files)
+			continue
+		}
+		for sublevel := range cur.L0SublevelFiles {
+			l.Sublevels = append(l.Sublevels, LSMSublevel{
+				Sublevel: sublevel,
+				Files:    d.lsmViewFiles(cur.L0SublevelFiles[sublevel]),
+			})
+		}
+	}
+	return s
+}
+
+func (d *DB) lsmViewFiles(files manifest.LevelSlice) []LSMFile {
+	var res []LSMFile
+	iter := files.Iter()
+	for f := iter.First(); f != nil; f = iter.Next() {
+		res = append(res, LSMFile{
+			FileNum:        f.FileNum,
+			Size:           f.Size,
+			Smallest:       d.lsmViewKey(f.Smallest),
+			Largest:        d.lsmViewKey(f.Largest),
+			SmallestSeqNum: f.SmallestSeqNum,
+			LargestSeqNum:  f.LargestSeqNum,
+			Compacting:     f.Compacting,
+		})
+	}
+	return res
+}
+
+func (d *DB) lsmViewKey(k InternalKey) LSMKey {
+	return LSMKey{
+		UserKey: append([]byte(nil), k.UserKey...),
+		Pretty:  fmt.Sprint(d.opts.Comparer.FormatKey(k.UserKey)),
+		SeqNum:  k.SeqNum(),
+		Kind:    k.Kind(),
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright 2026 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package pebble
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/cockroachdb/pebble/vfs"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLSMView(t *testing.T) {
+	d, err := Open("", &Options{FS: vfs.NewMem(), DisableAutomaticCompactions: true})
+	require.NoError(t, err)
+	defer func() { require.NoError(t, d.Close()) }()
+
+	require.NoError(t, d.Set([]byte("x"), nil, nil))
+	require.NoError(t, d.Compact([]byte("x"), []byte("y"), false /* parallelize */))
+	require.NoError(t, d.Set([]byte("a"), nil, nil))
+	require.NoError(t, d.Set([]byte("c"), nil, nil))
+	require.NoError(t, d.Flush())
+	require.NoError(t, d.Set([]byte("b"), nil, nil))
+	require.NoError(t, d.Flush())
+
+	describe := func(s LSMStructure) string {
+		var buf strings.Builder
+		files := func(indent string, files []LSMFile) {
+			for _, f := range files {
+				fmt.Fprintf(&buf, "%s%s:[%s#%d,%s-%s#%d,%s]\n", indent, f.FileNum,
+					f.Smallest.Pretty, f.Smallest.SeqNum, f.Smallest.Kind,
+					f.Largest.Pretty, f.Largest.SeqNum, f.Largest.Kind)
+			}
+		}
+		for _, l := range s.Levels {
+			if l.Size == 0 {
+				require.Empty(t, l.Sublevels)
+				require.Empty(t, l.Files)
+				continue
+			}
+			fmt.Fprintf(&buf, "L%d:\n", l.Level)
+			for _, sl := range l.Sublevels {
+				fmt.Fprintf(&buf, "  sublevel %d:\n", sl.Sublevel)
+				files("    ", sl.Files)
+			}
+			files("  ", l.Files)
+		}
+		return buf.String()
+	}
+
+	s := d.LSMView()
+	require.Len(t, s.Levels, numLevels)
+	const expected = `L0:
+  sublevel 0:
+    000007:[a#2,SET-c#3,SET]
+  sublevel 1:
+    000009:[b#4,SET-b#4,SET]
+L6:
+  000005:[x#1,SET-x#1,SET]
+`
+	require.Equal(t, expected, describe(s))
+
+	// The structure survives a round trip through JSON.
+	data, err := json.Marshal(s)
+	require.NoError(t, err)
+	var decoded LSMStructure
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	require.Equal(t, s, decoded)
+}
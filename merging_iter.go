@@ -242,6 +242,10 @@ type mergingIter struct {
 	upper    []byte
 	stats    InternalIteratorStats
 
+	// minSeqNum is the smallest sequence number of the keys surfaced. Keys
+	// with smaller sequence numbers are skipped as if they weren't visible.
+	minSeqNum uint64
+
 	combinedIterState *combinedIterState
 
 	// Elide range tombstones from being returned during iteration. Set to true
@@ -285,6 +289,7 @@ func (m *mergingIter) init(
 		m.upper = opts.UpperBound
 	}
 	m.snapshot = InternalKeySeqNumMax
+	m.minSeqNum = 0
 	m.levels = levels
 	m.heap.cmp = cmp
 	m.split = split
@@ -687,7 +692,7 @@ func (m *mergingIter) findNextEntry() (*InternalKey, []byte) {
 		// the level's iterator, item.key's memory is potentially invalid. If
 		// the iterator is now exhausted, item.key may be garbage.
 		if m.prefix != nil && reseeked {
-			if n := m.split(item.key.UserKey); !bytes.Equal(m.prefix, item.key.UserKey[:n]) {
+			if n := m.split(item.key.UserKey); m.heap.cmp(m.prefix, item.key.UserKey[:n]) != 0 {
 				return nil, nil
 			}
 		}
@@ -698,7 +703,7 @@ func (m *mergingIter) findNextEntry() (*InternalKey, []byte) {
 			reseeked = true
 			continue
 		}
-		if item.key.Visible(m.snapshot) &&
+		if item.key.Visible(m.snapshot) && item.key.SeqNum() >= m.minSeqNum &&
 			(!m.levels[item.index].isIgnorableBoundaryKey) &&
 			(item.key.Kind() != InternalKeyKindRangeDelete || !m.elideRangeTombstones) {
 			return &item.key, item.value
@@ -852,7 +857,7 @@ func (m *mergingIter) findPrevEntry() (*InternalKey, []byte) {
 			m.stats.PointsCoveredByRangeTombstones++
 			continue
 		}
-		if item.key.Visible(m.snapshot) &&
+		if item.key.Visible(m.snapshot) && item.key.SeqNum() >= m.minSeqNum &&
 			(!m.levels[item.index].isIgnorableBoundaryKey) &&
 			(item.key.Kind() != InternalKeyKindRangeDelete || !m.elideRangeTombstones) {
 			return &item.key, item.value
@@ -946,6 +951,16 @@ func (m *mergingIter) seekGE(key []byte, level int, flags base.SeekGEFlags) {
 	m.initMinHeap()
 }
 
+// FetchValue implements base.LazyValueIterator. The value at the
+// mergingIter's position is the value at the position of the level at the top
+// of the heap.
+func (m *mergingIter) FetchValue(value []byte) ([]byte, error) {
+	if m.heap.len() == 0 {
+		return value, nil
+	}
+	return base.FetchValue(m.levels[m.heap.items[0].index].iter, value)
+}
+
 func (m *mergingIter) String() string {
 	return "merging"
 }
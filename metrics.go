@@ -6,6 +6,7 @@ package pebble
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/HdrHistogram/hdrhistogram-go"
 	"github.com/cockroachdb/pebble/internal/base"
@@ -47,6 +48,8 @@ type LevelMetrics struct {
 	Size int64
 	// The level's compaction score.
 	Score float64
+	// The number of compactions in progress that output to the level.
+	CompactionsInProgress int64
 	// The number of incoming bytes from other levels read during
 	// compactions. This excludes bytes moved and bytes ingested. For L0 this is
 	// the bytes written to the WAL.
@@ -141,24 +144,56 @@ type Metrics struct {
 		ReadCount        int64
 		RewriteCount     int64
 		MultiLevelCount  int64
+		// The number of compactions picked to reclaim disk space under
+		// Options.Experimental.SpaceReclamationPriority.
+		SpaceReclamationCount int64
 		// An estimate of the number of bytes that need to be compacted for the LSM
 		// to reach a stable state.
 		EstimatedDebt uint64
+		// The ratio between the target sizes of adjacent levels from the base
+		// level to the bottom level, and the write amplification it is
+		// estimated to incur. See Options.Experimental.TargetWriteAmp.
+		LevelMultiplier   float64
+		EstimatedWriteAmp float64
 		// Number of bytes present in sstables being written by in-progress
 		// compactions. This value will be zero if there are no in-progress
 		// compactions.
 		InProgressBytes int64
 		// Number of compactions that are in-progress.
 		NumInProgress int64
+		// The number of bytes of write buffers held by the sstable writers of
+		// in-progress flushes and compactions, and the peak observed since the
+		// DB was opened. See Options.SSTableWriteBufferSize.
+		WriteBufferBytes     int64
+		PeakWriteBufferBytes int64
 		// MarkedFiles is a count of files that are marked for
 		// compaction. Such files are compacted in a rewrite compaction
 		// when no other compactions are picked.
 		MarkedFiles int
+		// The cumulative number of versions elided by compactions due to
+		// Options.Experimental.MaxVersionsPerKey.
+		VersionsElided int64
+		// The cumulative number of bytes of disk space reclaimed by
+		// compactions, as the total size of their input sstables less the
+		// total size of their output sstables, for the compactions that
+		// shrank their input.
+		ReclaimedBytes uint64
+		// The cumulative number of sstables written by flushes and
+		// compactions that failed validation under
+		// Options.Experimental.ValidateOutputs.
+		OutputValidationFailures int64
 	}
 
 	Flush struct {
 		// The total number of flushes.
 		Count int64
+		// SplitBytes is the effective target number of bytes between the keys
+		// at which flushes split their L0 outputs. It is Options.FlushSplitBytes
+		// multiplied by the current number of L0 sublevels.
+		SplitBytes int64
+		// NumSplitKeys is the number of keys at which flushes currently split
+		// their L0 outputs.
+		NumSplitKeys int64
 	}
 
 	Filter FilterMetrics
@@ -171,6 +206,10 @@ type Metrics struct {
 		Size uint64
 		// The count of memtables.
 		Count int64
+		// The number of bytes allocated by the mutable memtable. When memtables
+		// are sized adaptively (see Options.Experimental.AdaptiveMemTableSize),
+		// this is the current target memtable size.
+		MutableSize uint64
 		// The number of bytes present in zombie memtables which are no longer
 		// referenced by the current DB state but are still in use by an iterator.
 		ZombieSize uint64
@@ -178,6 +217,22 @@ type Metrics struct {
 		ZombieCount int64
 	}
 
+	// RangeKeys holds metrics for range keys. The counts and sizes are
+	// computed from the stats of the sstables containing range keys, and don't
+	// include range keys in memtables or in sstables whose stats have not yet
+	// been loaded.
+	RangeKeys struct {
+		// The number of range key sets, unsets and deletes in the sstables of
+		// each level.
+		Count [numLevels]uint64
+		// The total uncompressed size of the keys and values of the range keys
+		// in sstables.
+		Size uint64
+		// The cumulative number of positioning operations performed by closed
+		// iterators configured to surface range keys.
+		IterOps int64
+	}
+
 	Snapshots struct {
 		// The number of currently open snapshots.
 		Count int
@@ -203,6 +258,17 @@ type Metrics struct {
 	// Count of the number of open sstable iterators.
 	TableIters int64
 
+	// Iterators holds metrics for the iterators created by the DB, including
+	// those of its snapshots and indexed batches.
+	Iterators struct {
+		// The number of currently open iterators.
+		Count int64
+		// The cumulative number of iterators opened.
+		Opened int64
+		// The cumulative number of iterators closed.
+		Closed int64
+	}
+
 	WAL struct {
 		// Number of live WAL files.
 		Files int64
@@ -212,6 +278,9 @@ type Metrics struct {
 		ObsoletePhysicalSize uint64
 		// Size of the live data in the WAL files. Note that with WAL file
 		// recycling this is less than the actual on-disk size of the WAL files.
+		// The live WAL files back the memtables that are yet to be flushed, so
+		// this is also the volume of WAL replayed on recovery, which is bounded
+		// by Options.MaxWALSize.
 		Size uint64
 		// Physical size of the WAL files on-disk. With WAL file recycling,
 		// this is greater than the live data in WAL files.
@@ -222,6 +291,57 @@ type Metrics struct {
 		BytesWritten uint64
 	}
 
+	// WriteThrottle holds metrics for the soft throttling of writes configured
+	// by Options.L0SublevelReadAmpThreshold. The current L0 sublevel count is
+	// reported by Levels[0].Sublevels.
+	WriteThrottle struct {
+		// Active is true if L0 read-amplification currently exceeds
+		// Options.L0SublevelReadAmpThreshold, causing writes to be delayed.
+		Active bool
+		// The cumulative number of writes that have been delayed.
+		Count int64
+		// The cumulative duration writes have been delayed.
+		Duration time.Duration
+	}
+
+	// DiskUsage holds metrics for the limit on disk usage configured by
+	// Options.MaxDiskUsageBytes.
+	DiskUsage struct {
+		// Used is the disk space used by the DB, as returned by
+		// DiskSpaceUsage.
+		Used uint64
+		// Limit is Options.MaxDiskUsageBytes, or zero if disk usage is
+		// unlimited.
+		Limit uint64
+		// The cumulative number of writes and ingestions rejected with
+		// ErrDiskFull.
+		RejectedWrites int64
+	}
+
+	// FSRetries holds metrics for the retrying of filesystem operations
+	// configured by Options.FSRetryPolicy.
+	FSRetries struct {
+		// The cumulative number of retries of filesystem operations that
+		// failed with a retryable error.
+		Count int64
+	}
+
+	// MemoryBudget holds the current apportionment of memory among the block
+	// cache, memtables and table cache. See Options.MemoryBudget.
+	MemoryBudget struct {
+		// Total is Options.MemoryBudget, or zero if no budget is configured.
+		Total int64
+		// BlockCache is the capacity of the block cache, less the memory
+		// reserved from it by memtables.
+		BlockCache int64
+		// MemTables is the memory reserved from the block cache by memtables,
+		// including memtables that have been flushed but are still referenced.
+		MemTables int64
+		// TableCache is the estimated memory used by the table cache's open
+		// sstables.
+		TableCache int64
+	}
+
 	private struct {
 		optionsFileSize  uint64
 		manifestFileSize uint64
@@ -281,6 +401,117 @@ func (m *Metrics) Total() LevelMetrics {
 	return total
 }
 
+// MetricsDelta holds the change in a DB's metrics between two snapshots, as
+// computed by DB.MetricsSince. The embedded Metrics holds, for each cumulative
+// counter, the amount the counter increased by since the previous snapshot.
+// Gauges, such as the size and count of files in a level, the number of open
+// snapshots and the current cache sizes, hold their value in the current
+// snapshot. Current holds the current snapshot, and may be passed as the
+// previous snapshot to a subsequent call to DB.MetricsSince.
+//
+// The cumulative counters are:
+//   - BlockCache.{Hits,Misses} and TableCache.{Hits,Misses}
+//   - Compact.{Count,DefaultCount,DeleteOnlyCount,ElisionOnlyCount,MoveCount,
+//     ReadCount,RewriteCount,MultiLevelCount,SpaceReclamationCount,
+//     VersionsElided,ReclaimedBytes,OutputValidationFailures}
+//   - Flush.Count
+//   - Filter.{Hits,Misses}
+//   - Iterators.{Opened,Closed}
+//   - Levels[*].{BytesIn,BytesIngested,BytesMoved,BytesRead,BytesCompacted,
+//     BytesFlushed,TablesCompacted,TablesFlushed,TablesIngested,TablesMoved}
+//   - RangeKeys.IterOps
+//   - WAL.{BytesIn,BytesWritten}
+//   - WriteThrottle.{Count,Duration}
+//   - DiskUsage.RejectedWrites
+//   - FSRetries.Count
+//
+// If a counter is smaller in the current snapshot than in the previous one,
+// for example because the previous snapshot was taken from an earlier
+// incarnation of the DB, the counter is assumed to have been reset and the
+// delta is its current value.
+type MetricsDelta struct {
+	Metrics
+	// Current is the snapshot the delta was computed from.
+	Current *Metrics
+}
+
+// metricsDelta computes the MetricsDelta between the cur and prev snapshots.
+// A nil prev is treated as a snapshot with all counters set to zero.
+func metricsDelta(cur, prev *Metrics) MetricsDelta {
+	d := MetricsDelta{Metrics: *cur, Current: cur}
+	if prev == nil {
+		return d
+	}
+	m := &d.Metrics
+	m.BlockCache.Hits = deltaInt64(cur.BlockCache.Hits, prev.BlockCache.Hits)
+	m.BlockCache.Misses = deltaInt64(cur.BlockCache.Misses, prev.BlockCache.Misses)
+	m.TableCache.Hits = deltaInt64(cur.TableCache.Hits, prev.TableCache.Hits)
+	m.TableCache.Misses = deltaInt64(cur.TableCache.Misses, prev.TableCache.Misses)
+
+	m.Compact.Count = deltaInt64(cur.Compact.Count, prev.Compact.Count)
+	m.Compact.DefaultCount = deltaInt64(cur.Compact.DefaultCount, prev.Compact.DefaultCount)
+	m.Compact.DeleteOnlyCount = deltaInt64(cur.Compact.DeleteOnlyCount, prev.Compact.DeleteOnlyCount)
+	m.Compact.ElisionOnlyCount = deltaInt64(cur.Compact.ElisionOnlyCount, prev.Compact.ElisionOnlyCount)
+	m.Compact.MoveCount = deltaInt64(cur.Compact.MoveCount, prev.Compact.MoveCount)
+	m.Compact.ReadCount = deltaInt64(cur.Compact.ReadCount, prev.Compact.ReadCount)
+	m.Compact.RewriteCount = deltaInt64(cur.Compact.RewriteCount, prev.Compact.RewriteCount)
+	m.Compact.MultiLevelCount = deltaInt64(cur.Compact.MultiLevelCount, prev.Compact.MultiLevelCount)
+	m.Compact.SpaceReclamationCount = deltaInt64(cur.Compact.SpaceReclamationCount, prev.Compact.SpaceReclamationCount)
+	m.Compact.VersionsElided = deltaInt64(cur.Compact.VersionsElided, prev.Compact.VersionsElided)
+	m.Compact.ReclaimedBytes = deltaUint64(cur.Compact.ReclaimedBytes, prev.Compact.ReclaimedBytes)
+	m.Compact.OutputValidationFailures = deltaInt64(cur.Compact.OutputValidationFailures,
+		prev.Compact.OutputValidationFailures)
+
+	m.Flush.Count = deltaInt64(cur.Flush.Count, prev.Flush.Count)
+
+	m.Filter.Hits = deltaInt64(cur.Filter.Hits, prev.Filter.Hits)
+	m.Filter.Misses = deltaInt64(cur.Filter.Misses, prev.Filter.Misses)
+
+	m.Iterators.Opened = deltaInt64(cur.Iterators.Opened, prev.Iterators.Opened)
+	m.Iterators.Closed = deltaInt64(cur.Iterators.Closed, prev.Iterators.Closed)
+
+	for i := range m.Levels {
+		l, c, p := &m.Levels[i], &cur.Levels[i], &prev.Levels[i]
+		l.BytesIn = deltaUint64(c.BytesIn, p.BytesIn)
+		l.BytesIngested = deltaUint64(c.BytesIngested, p.BytesIngested)
+		l.BytesMoved = deltaUint64(c.BytesMoved, p.BytesMoved)
+		l.BytesRead = deltaUint64(c.BytesRead, p.BytesRead)
+		l.BytesCompacted = deltaUint64(c.BytesCompacted, p.BytesCompacted)
+		l.BytesFlushed = deltaUint64(c.BytesFlushed, p.BytesFlushed)
+		l.TablesCompacted = deltaUint64(c.TablesCompacted, p.TablesCompacted)
+		l.TablesFlushed = deltaUint64(c.TablesFlushed, p.TablesFlushed)
+		l.TablesIngested = deltaUint64(c.TablesIngested, p.TablesIngested)
+		l.TablesMoved = deltaUint64(c.TablesMoved, p.TablesMoved)
+	}
+
+	m.RangeKeys.IterOps = deltaInt64(cur.RangeKeys.IterOps, prev.RangeKeys.IterOps)
+
+	m.WAL.BytesIn = deltaUint64(cur.WAL.BytesIn, prev.WAL.BytesIn)
+	m.WAL.BytesWritten = deltaUint64(cur.WAL.BytesWritten, prev.WAL.BytesWritten)
+
+	m.WriteThrottle.Count = deltaInt64(cur.WriteThrottle.Count, prev.WriteThrottle.Count)
+	m.WriteThrottle.Duration = time.Duration(
+		deltaInt64(int64(cur.WriteThrottle.Duration), int64(prev.WriteThrottle.Duration)))
+
+	m.DiskUsage.RejectedWrites = deltaInt64(cur.DiskUsage.RejectedWrites, prev.DiskUsage.RejectedWrites)
+	m.FSRetries.Count = deltaInt64(cur.FSRetries.Count, prev.FSRetries.Count)
+	return d
+}
+
+func deltaInt64(cur, prev int64) int64 {
+	if cur < prev {
+		return cur
+	}
+	return cur - prev
+}
+
+func deltaUint64(cur, prev uint64) uint64 {
+	if cur < prev {
+		return cur
+	}
+	return cur - prev
+}
+
 const notApplicable = redact.SafeString("-")
 
 func (m *Metrics) formatWAL(w redact.SafePrinter) {
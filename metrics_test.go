@@ -10,9 +10,11 @@ import (
 
 	"github.com/cockroachdb/pebble/internal/datadriven"
 	"github.com/cockroachdb/pebble/internal/humanize"
+	"github.com/cockroachdb/pebble/internal/testkeys"
 	"github.com/cockroachdb/pebble/vfs"
 	"github.com/cockroachdb/redact"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/exp/rand"
 )
 
 func TestMetricsFormat(t *testing.T) {
@@ -245,3 +247,162 @@ zmemtbl         0     0 B
 		t.Fatalf("expected%s\nbut found%s", expected, s)
 	}
 }
+
+func TestMetricsSince(t *testing.T) {
+	d, err := Open("", &Options{FS: vfs.NewMem()})
+	require.NoError(t, err)
+	defer func() { require.NoError(t, d.Close()) }()
+
+	writeAndFlush := func() {
+		require.NoError(t, d.Set([]byte("a"), []byte("b"), nil))
+		require.NoError(t, d.Flush())
+	}
+
+	writeAndFlush()
+	require.NoError(t, d.NewIter(nil).Close())
+	delta := d.MetricsSince(nil)
+	require.Equal(t, *delta.Current, delta.Metrics)
+	require.EqualValues(t, 1, delta.Flush.Count)
+	prev := delta.Current
+
+	writeAndFlush()
+	writeAndFlush()
+	iter := d.NewIter(nil)
+	require.NoError(t, iter.Close())
+	iter = d.NewIter(nil)
+	delta = d.MetricsSince(prev)
+	require.NoError(t, iter.Close())
+	cur := delta.Current
+	// Counters report the change since the previous snapshot.
+	require.EqualValues(t, 2, delta.Flush.Count)
+	require.EqualValues(t, 2, delta.Levels[0].TablesFlushed)
+	require.Equal(t, cur.Levels[0].BytesFlushed-prev.Levels[0].BytesFlushed, delta.Levels[0].BytesFlushed)
+	require.Equal(t, cur.WAL.BytesIn-prev.WAL.BytesIn, delta.WAL.BytesIn)
+	require.EqualValues(t, 2, delta.Iterators.Opened)
+	require.EqualValues(t, 1, delta.Iterators.Closed)
+	// Gauges report their current value.
+	require.Equal(t, cur.Levels[0].NumFiles, delta.Levels[0].NumFiles)
+	require.Equal(t, cur.Levels[0].Size, delta.Levels[0].Size)
+	require.Equal(t, cur.MemTable.Size, delta.MemTable.Size)
+	require.EqualValues(t, 1, delta.Iterators.Count)
+
+	// A counter that went backwards is treated as having been reset.
+	delta = metricsDelta(prev, cur)
+	require.Equal(t, prev.Flush.Count, delta.Flush.Count)
+	require.Equal(t, prev.Levels[0].TablesFlushed, delta.Levels[0].TablesFlushed)
+}
+
+func TestMetricsWriteBuffer(t *testing.T) {
+	d, err := Open("", &Options{
+		FS:                     vfs.NewMem(),
+		SSTableWriteBufferSize: 64 << 10,
+	})
+	require.NoError(t, err)
+	defer func() { require.NoError(t, d.Close()) }()
+
+	m := d.Metrics()
+	require.Zero(t, m.Compact.WriteBufferBytes)
+	require.Zero(t, m.Compact.PeakWriteBufferBytes)
+
+	require.NoError(t, d.Set([]byte("a"), []byte("b"), nil))
+	require.NoError(t, d.Flush())
+	require.NoError(t, d.Set([]byte("c"), []byte("d"), nil))
+	require.NoError(t, d.Flush())
+	require.NoError(t, d.Compact([]byte("a"), []byte("d"), false /* parallelize */))
+
+	// The flushes and the compaction each held a single write buffer.
+	m = d.Metrics()
+	require.Zero(t, m.Compact.WriteBufferBytes)
+	require.EqualValues(t, 64<<10, m.Compact.PeakWriteBufferBytes)
+}
+
+func TestMetricsFlushSplit(t *testing.T) {
+	d, err := Open("", &Options{
+		FS:                          vfs.NewMem(),
+		DisableAutomaticCompactions: true,
+		FlushSplitBytes:             16 << 10,
+	})
+	require.NoError(t, err)
+	defer func() { require.NoError(t, d.Close()) }()
+
+	// An empty L0 has no sublevels and no split keys.
+	m := d.Metrics()
+	require.Zero(t, m.Flush.SplitBytes)
+	require.Zero(t, m.Flush.NumSplitKeys)
+
+	rng := rand.New(rand.NewSource(1))
+	val := make([]byte, 512)
+	writeAndFlush := func() {
+		for i := 0; i < 256; i++ {
+			_, _ = rng.Read(val)
+			require.NoError(t, d.Set([]byte(fmt.Sprintf("%04d", i)), val, nil))
+		}
+		require.NoError(t, d.Flush())
+	}
+
+	writeAndFlush()
+	m = d.Metrics()
+	require.EqualValues(t, 16<<10, m.Flush.SplitBytes)
+	require.NotZero(t, m.Flush.NumSplitKeys)
+
+	// The second flush overlaps the first, so it adds a sublevel and is split
+	// into multiple files at the split keys.
+	writeAndFlush()
+	m = d.Metrics()
+	require.EqualValues(t, 2*16<<10, m.Flush.SplitBytes)
+	require.EqualValues(t, 2, m.Levels[0].Sublevels)
+	require.Greater(t, m.Levels[0].NumFiles, int64(2))
+}
+
+func TestMetricsRangeKeys(t *testing.T) {
+	d, err := Open("", &Options{
+		FS:                 vfs.NewMem(),
+		Comparer:           testkeys.Comparer,
+		FormatMajorVersion: FormatNewest,
+	})
+	require.NoError(t, err)
+	defer func() { require.NoError(t, d.Close()) }()
+
+	// Range keys in the memtable aren't counted.
+	require.NoError(t, d.RangeKeySet([]byte("a"), []byte("c"), []byte("@1"), []byte("v"), nil))
+	require.NoError(t, d.RangeKeyUnset([]byte("b"), []byte("d"), []byte("@2"), nil))
+	m := d.Metrics()
+	require.Zero(t, m.RangeKeys.Count[0])
+	require.Zero(t, m.RangeKeys.Size)
+
+	// The overlapping range keys are fragmented into [a,b) holding a set,
+	// [b,c) holding a set and an unset, and [c,d) holding an unset.
+	require.NoError(t, d.Flush())
+	d.mu.Lock()
+	d.waitTableStats()
+	d.mu.Unlock()
+	m = d.Metrics()
+	require.EqualValues(t, 4, m.RangeKeys.Count[0])
+	require.NotZero(t, m.RangeKeys.Size)
+
+	require.NoError(t, d.Compact([]byte("a"), []byte("d"), false))
+	d.mu.Lock()
+	d.waitTableStats()
+	d.mu.Unlock()
+	m = d.Metrics()
+	require.Zero(t, m.RangeKeys.Count[0])
+	require.EqualValues(t, 4, m.RangeKeys.Count[numLevels-1])
+
+	// Only iterators surfacing range keys are counted.
+	prev := d.Metrics()
+	iter := d.NewIter(nil)
+	for valid := iter.First(); valid; valid = iter.Next() {
+	}
+	require.NoError(t, iter.Close())
+	require.Zero(t, d.MetricsSince(prev).RangeKeys.IterOps)
+	iter = d.NewIter(&IterOptions{KeyTypes: IterKeyTypeRangesOnly})
+	// Each range key is surfaced by one positioning operation, and a final
+	// Next exhausts the iterator.
+	var ops int64
+	for valid := iter.First(); valid; valid = iter.Next() {
+		ops++
+	}
+	require.Zero(t, d.MetricsSince(prev).RangeKeys.IterOps)
+	require.NoError(t, iter.Close())
+	require.Equal(t, ops+1, d.MetricsSince(prev).RangeKeys.IterOps)
+}
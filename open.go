@@ -12,10 +12,12 @@ import (
 	"math"
 	"os"
 	"sort"
+	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/cockroachdb/errors"
+	"github.com/cockroachdb/errors/oserror"
 	"github.com/cockroachdb/pebble/internal/arenaskl"
 	"github.com/cockroachdb/pebble/internal/base"
 	"github.com/cockroachdb/pebble/internal/cache"
@@ -23,6 +25,7 @@ import (
 	"github.com/cockroachdb/pebble/internal/manual"
 	"github.com/cockroachdb/pebble/internal/rate"
 	"github.com/cockroachdb/pebble/record"
+	"github.com/cockroachdb/pebble/sstable"
 	"github.com/cockroachdb/pebble/vfs"
 )
 
@@ -60,7 +63,7 @@ func Open(dirname string, opts *Options) (db *DB, _ error) {
 	}
 
 	if opts.Cache == nil {
-		opts.Cache = cache.New(cacheDefaultSize)
+		opts.Cache = cache.New(opts.defaultCacheSize())
 	} else {
 		opts.Cache.Ref()
 	}
@@ -70,7 +73,7 @@ func Open(dirname string, opts *Options) (db *DB, _ error) {
 		dirname:             dirname,
 		walDirname:          opts.WALDir,
 		opts:                opts,
-		cmp:                 opts.Comparer.Compare,
+		cmp:                 base.FixedPrefixCompare(opts.Comparer.Compare, opts.Comparer.PrefixLen),
 		equal:               opts.equal(),
 		merge:               opts.Merger.Merge,
 		split:               opts.Comparer.Split,
@@ -80,8 +83,30 @@ func Open(dirname string, opts *Options) (db *DB, _ error) {
 		closed:              new(atomic.Value),
 		closedCh:            make(chan struct{}),
 	}
+	if opts.FSRetryPolicy != nil {
+		opts.FS = vfs.WithRetries(opts.FS, *opts.FSRetryPolicy, func(error) {
+			atomic.AddInt64(&d.atomic.fsRetries, 1)
+		})
+	}
+	if opts.Experimental.TrackIteratorStacks {
+		d.iterTracker = &iterTracker{iters: make(map[*Iterator]IteratorInfo)}
+	}
+	if opts.Experimental.AdaptiveMemTableSize.Enabled {
+		d.largeBatchThreshold = (opts.Experimental.AdaptiveMemTableSize.MaxSize - int(memTableEmptySize)) / 2
+	}
+	if p := opts.Experimental.CompactionIOPriority; p.Class != IOPriorityDefault {
+		// Check that the priority can be set, so that a failure is logged once
+		// rather than by every compaction.
+		if restore, err := setThreadIOPriority(p); err != nil {
+			opts.Logger.Infof("ignoring compaction I/O priority %s: %v", p.Class, err)
+		} else {
+			restore()
+			d.compactionIOPriority = p
+		}
+	}
 	d.mu.versions = &versionSet{}
 	d.atomic.diskAvailBytes = math.MaxUint64
+	d.atomic.gcFloorSeqNum = opts.Experimental.GCFloorSeqNum
 	d.mu.versions.diskAvailBytes = d.getDiskAvailableBytesCached
 
 	defer func() {
@@ -132,6 +157,9 @@ func Open(dirname string, opts *Options) (db *DB, _ error) {
 	if d.mu.mem.nextSize > initialMemTableSize {
 		d.mu.mem.nextSize = initialMemTableSize
 	}
+	if opts.Experimental.AdaptiveMemTableSize.Enabled {
+		d.mu.mem.nextSize = opts.Experimental.AdaptiveMemTableSize.MinSize
+	}
 	d.mu.mem.cond.L = &d.mu.Mutex
 	d.mu.cleaner.cond.L = &d.mu.Mutex
 	d.mu.compact.cond.L = &d.mu.Mutex
@@ -167,6 +195,9 @@ func Open(dirname string, opts *Options) (db *DB, _ error) {
 		if d.walDirname != d.dirname && d.walDir != nil {
 			d.walDir.Close()
 		}
+		if d.walFailoverDir != nil {
+			d.walFailoverDir.Close()
+		}
 		if d.mu.formatVers.marker != nil {
 			d.mu.formatVers.marker.Close()
 		}
@@ -179,6 +210,7 @@ func Open(dirname string, opts *Options) (db *DB, _ error) {
 	if err != nil {
 		return nil, err
 	}
+	d.dataDir = wrapDir(opts, d.dataDir)
 	if d.walDirname == "" {
 		d.walDirname = d.dirname
 	}
@@ -195,6 +227,27 @@ func Open(dirname string, opts *Options) (db *DB, _ error) {
 		if err != nil {
 			return nil, err
 		}
+		d.walDir = wrapDir(opts, d.walDir)
+	}
+	if f := opts.WALFailover; f != nil {
+		if f.SecondaryFS == opts.FS && (f.SecondaryDir == d.walDirname || f.SecondaryDir == d.dirname) {
+			return nil, errors.Errorf("pebble: WALFailover.SecondaryDir %q must differ from the WAL and data directories", f.SecondaryDir)
+		}
+		if !d.opts.ReadOnly {
+			if err := f.SecondaryFS.MkdirAll(f.SecondaryDir, 0755); err != nil {
+				return nil, err
+			}
+		}
+		d.walFailoverDir, err = f.SecondaryFS.OpenDir(f.SecondaryDir)
+		if d.opts.ReadOnly && oserror.IsNotExist(err) {
+			// The secondary WAL directory was never created, so there are no
+			// WALs to replay from it.
+			err = nil
+		} else if err != nil {
+			return nil, err
+		}
+		d.walFailoverDir = wrapDir(opts, d.walFailoverDir)
+		d.mu.log.failover.secondaryLogs = make(map[FileNum]struct{})
 	}
 
 	// Lock the database directory.
@@ -204,6 +257,9 @@ func Open(dirname string, opts *Options) (db *DB, _ error) {
 		if d.dataDir != d.walDir {
 			d.walDir.Close()
 		}
+		if d.walFailoverDir != nil {
+			d.walFailoverDir.Close()
+		}
 		return nil, err
 	}
 	defer func() {
@@ -258,6 +314,11 @@ func Open(dirname string, opts *Options) (db *DB, _ error) {
 		if err := d.mu.versions.currentVersion().CheckConsistency(dirname, opts.FS); err != nil {
 			return nil, err
 		}
+		if opts.StrictManifestValidation {
+			if err := d.validateManifestLocked(); err != nil {
+				return nil, errors.Wrapf(err, "pebble: database %q: MANIFEST %s", dirname, manifestFileNum)
+			}
+		}
 	}
 
 	// If the Options specify a format major version higher than the
@@ -299,6 +360,19 @@ func Open(dirname string, opts *Options) (db *DB, _ error) {
 		}
 		ls = append(ls, ls2...)
 	}
+	if f := opts.WALFailover; f != nil && d.walFailoverDir != nil {
+		ls2, err := f.SecondaryFS.List(f.SecondaryDir)
+		if err != nil {
+			return nil, err
+		}
+		// Only WALs are expected in the secondary WAL directory.
+		for _, filename := range ls2 {
+			if ft, fn, ok := base.ParseFilename(f.SecondaryFS, filename); ok && ft == fileTypeLog {
+				d.mu.log.failover.secondaryLogs[fn] = struct{}{}
+				ls = append(ls, filename)
+			}
+		}
+	}
 
 	// Replay any newer log files than the ones named in the manifest.
 	type fileNumAndName struct {
@@ -362,10 +436,18 @@ func Open(dirname string, opts *Options) (db *DB, _ error) {
 	})
 
 	var ve versionEdit
+	var truncated bool
 	for i, lf := range logFiles {
+		if truncated {
+			// Replay stopped at a sequence number mismatch in an earlier WAL.
+			d.mu.versions.markFileNumUsed(lf.num)
+			continue
+		}
 		lastWAL := i == len(logFiles)-1
-		maxSeqNum, err := d.replayWAL(jobID, &ve, opts.FS,
-			opts.FS.PathJoin(d.walDirname, lf.name), lf.num, strictWALTail && !lastWAL)
+		loc := d.logLocationLocked(lf.num)
+		var maxSeqNum uint64
+		maxSeqNum, truncated, err = d.replayWAL(jobID, &ve, loc.fs,
+			loc.fs.PathJoin(loc.dirname, lf.name), lf.num, strictWALTail && !lastWAL)
 		if err != nil {
 			return nil, err
 		}
@@ -375,6 +457,8 @@ func Open(dirname string, opts *Options) (db *DB, _ error) {
 		}
 	}
 	d.mu.versions.atomic.visibleSeqNum = d.mu.versions.atomic.logSeqNum
+	// The history written before the DB was opened may have been discarded.
+	d.mu.versions.atomic.historyFloorSeqNum = d.mu.versions.atomic.logSeqNum - 1
 
 	if !d.opts.ReadOnly {
 		// Create an empty .log file.
@@ -396,14 +480,22 @@ func Open(dirname string, opts *Options) (db *DB, _ error) {
 			return nil, err
 		}
 
-		newLogName := base.MakeFilepath(opts.FS, d.walDirname, fileTypeLog, newLogNum)
+		loc := d.primaryWALLocation()
+		newLogName := base.MakeFilepath(loc.fs, loc.dirname, fileTypeLog, newLogNum)
 		d.mu.log.queue = append(d.mu.log.queue, fileInfo{fileNum: newLogNum, fileSize: 0})
-		logFile, err := opts.FS.Create(newLogName)
+		logFile, _, _, err := d.createWAL(loc, newLogName)
+		if err != nil && opts.WALFailover != nil {
+			// Fail over to the secondary WAL directory if the WAL can't be
+			// created in the primary directory.
+			loc = d.failoverWALLocked(err)
+			newLogName = base.MakeFilepath(loc.fs, loc.dirname, fileTypeLog, newLogNum)
+			logFile, _, _, err = d.createWAL(loc, newLogName)
+		}
 		if err != nil {
 			return nil, err
 		}
-		if err := d.walDir.Sync(); err != nil {
-			return nil, err
+		if loc.secondary {
+			d.mu.log.failover.secondaryLogs[newLogNum] = struct{}{}
 		}
 		d.opts.EventListener.WALCreated(WALCreateInfo{
 			JobID:   jobID,
@@ -414,11 +506,6 @@ func Open(dirname string, opts *Options) (db *DB, _ error) {
 		// memtables being flushed, only for the next unflushed memtable.
 		d.mu.mem.queue[len(d.mu.mem.queue)-1].logNum = newLogNum
 
-		logFile = vfs.NewSyncingFile(logFile, vfs.SyncingFileOptions{
-			NoSyncOnClose:   d.opts.NoSyncOnClose,
-			BytesPerSync:    d.opts.WALBytesPerSync,
-			PreallocateSize: d.walPreallocateSize(),
-		})
 		d.mu.log.LogWriter = record.NewLogWriter(logFile, newLogNum)
 		d.mu.log.LogWriter.SetMinSyncInterval(d.opts.WALMinSyncInterval)
 		d.mu.versions.metrics.WAL.Files++
@@ -506,6 +593,60 @@ func Open(dirname string, opts *Options) (db *DB, _ error) {
 	return d, nil
 }
 
+// validateManifestLocked checks the LSM loaded from the MANIFEST for
+// consistency, returning an error describing the first inconsistency found.
+// See Options.StrictManifestValidation.
+//
+// d.mu must be held when calling this.
+func (d *DB) validateManifestLocked() error {
+	cmp, formatKey := d.opts.Comparer.Compare, d.opts.Comparer.FormatKey
+	vs := d.mu.versions
+	logSeqNum := atomic.LoadUint64(&vs.atomic.logSeqNum)
+	current := vs.currentVersion()
+	for level := range current.Levels {
+		iter := current.Levels[level].Iter()
+		for f := iter.First(); f != nil; f = iter.Next() {
+			if err := f.Validate(cmp, formatKey); err != nil {
+				return errors.Wrapf(err, "L%d", errors.Safe(level))
+			}
+			if f.FileNum >= vs.nextFileNum {
+				return base.CorruptionErrorf("L%d: file %s has a file number not below the next file number %s",
+					errors.Safe(level), errors.Safe(f.FileNum), errors.Safe(vs.nextFileNum))
+			}
+			if f.LargestSeqNum >= logSeqNum {
+				return base.CorruptionErrorf("L%d: file %s has largest seqnum %d above the last seqnum %d",
+					errors.Safe(level), errors.Safe(f.FileNum), errors.Safe(f.LargestSeqNum), errors.Safe(logSeqNum-1))
+			}
+		}
+	}
+	if err := current.CheckOrdering(cmp, formatKey); err != nil {
+		return err
+	}
+
+	// Read the footer and properties of every sstable, bypassing the table
+	// cache, which the DB hasn't started using yet.
+	readerOpts := d.opts.MakeReaderOptions()
+	readerOpts.Cache = nil
+	for level := range current.Levels {
+		iter := current.Levels[level].Iter()
+		for f := iter.First(); f != nil; f = iter.Next() {
+			path := makeTableFilepath(d.opts.FS, d.dirname, f.FileNum, f.Name)
+			file, err := d.opts.FS.Open(path)
+			if err != nil {
+				return errors.Wrapf(err, "L%d: file %s", errors.Safe(level), errors.Safe(f.FileNum))
+			}
+			r, err := sstable.NewReader(file, readerOpts)
+			if err == nil {
+				err = r.Close()
+			}
+			if err != nil {
+				return errors.Wrapf(err, "L%d: file %s", errors.Safe(level), errors.Safe(f.FileNum))
+			}
+		}
+	}
+	return nil
+}
+
 // GetVersion returns the engine version string from the latest options
 // file present in dir. Used to check what Pebble or RocksDB version was last
 // used to write to the database stored in this directory. An empty string is
@@ -564,21 +705,31 @@ func GetVersion(dir string, fs vfs.FS) (string, error) {
 	return version, nil
 }
 
-// replayWAL replays the edits in the specified log file.
+// replayWAL replays the edits in the specified log file. If replay stopped at a
+// batch with an unexpected sequence number, truncated is true and no
+// subsequent log files should be replayed. See
+// Options.Experimental.OnSeqNumMismatch.
 //
 // d.mu must be held when calling this, but the mutex may be dropped and
 // re-acquired during the course of this method.
 func (d *DB) replayWAL(
 	jobID int, ve *versionEdit, fs vfs.FS, filename string, logNum FileNum, strictWALTail bool,
-) (maxSeqNum uint64, err error) {
+) (maxSeqNum uint64, truncated bool, err error) {
 	file, err := fs.Open(filename)
 	if err != nil {
-		return 0, err
+		return 0, false, err
 	}
 	defer file.Close()
 
+	applier := newWALReplayApplier(d.opts.WALReplayConcurrency)
+	defer func() {
+		// Wait for the batches being applied, if returning early.
+		_ = applier.finish()
+	}()
+
 	var (
-		b               Batch
+		b               *Batch
+		batch           Batch
 		buf             bytes.Buffer
 		mem             *memTable
 		entry           *flushableEntry
@@ -586,6 +737,11 @@ func (d *DB) replayWAL(
 		rr              = record.NewReader(file, logNum)
 		offset          int64 // byte offset in rr
 		lastFlushOffset int64
+		// expectedSeqNum is the next sequence number expected in the log: the
+		// larger of the next sequence number following the MANIFEST and any
+		// previously replayed logs, and the sequence number following the
+		// batches replayed so far.
+		expectedSeqNum = atomic.LoadUint64(&d.mu.versions.atomic.logSeqNum)
 	)
 
 	if d.opts.ReadOnly {
@@ -643,27 +799,57 @@ func (d *DB) replayWAL(
 			} else if record.IsInvalidRecord(err) && !strictWALTail {
 				break
 			}
-			return 0, errors.Wrap(err, "pebble: error when replaying WAL")
+			return 0, false, errors.Wrap(err, "pebble: error when replaying WAL")
 		}
 
 		if buf.Len() < batchHeaderLen {
-			return 0, base.CorruptionErrorf("pebble: corrupt log file %q (num %s)",
+			return 0, false, base.CorruptionErrorf("pebble: corrupt log file %q (num %s)",
 				filename, errors.Safe(logNum))
 		}
 
 		// Specify Batch.db so that Batch.SetRepr will compute Batch.memTableSize
-		// which is used below.
-		b = Batch{db: d}
-		b.SetRepr(buf.Bytes())
+		// which is used below. A batch applied concurrently needs its own copy
+		// of the data, which is currently owned by buf.
+		if applier.concurrent() {
+			b = &Batch{db: d}
+			b.SetRepr(append([]byte(nil), buf.Bytes()...))
+		} else {
+			batch = Batch{db: d}
+			b = &batch
+			b.SetRepr(buf.Bytes())
+		}
 		seqNum := b.SeqNum()
+		if seqNum > expectedSeqNum {
+			switch d.opts.Experimental.OnSeqNumMismatch {
+			case SeqNumMismatchFail:
+				return 0, false, base.CorruptionErrorf(
+					"pebble: log file %q (num %s) contains a batch at offset %d with sequence number %d, ahead of the expected sequence number %d",
+					filename, errors.Safe(logNum), errors.Safe(offset), errors.Safe(seqNum), errors.Safe(expectedSeqNum))
+			case SeqNumMismatchTruncate:
+				d.opts.EventListener.WALTruncated(WALTruncateInfo{
+					JobID:           jobID,
+					FileNum:         logNum,
+					Offset:          offset,
+					SeqNum:          seqNum,
+					RecoveredSeqNum: expectedSeqNum - 1,
+				})
+				truncated = true
+			}
+			if truncated {
+				break
+			}
+		}
 		maxSeqNum = seqNum + uint64(b.Count())
+		if expectedSeqNum < maxSeqNum {
+			expectedSeqNum = maxSeqNum
+		}
 
 		if b.memTableSize >= uint64(d.largeBatchThreshold) {
 			flushMem()
 			// Make a copy of the data slice since it is currently owned by buf and will
 			// be reused in the next iteration.
 			b.data = append([]byte(nil), b.data...)
-			b.flushable = newFlushableBatch(&b, d.opts.Comparer)
+			b.flushable = newFlushableBatch(b, d.opts.Comparer)
 			entry := d.newFlushableEntry(b.flushable, logNum, b.SeqNum())
 			// Disable memory accounting by adding a reader ref that will never be
 			// removed.
@@ -675,8 +861,8 @@ func (d *DB) replayWAL(
 			}
 		} else {
 			ensureMem(seqNum)
-			if err = mem.prepare(&b); err != nil && err != arenaskl.ErrArenaFull {
-				return 0, err
+			if err = mem.prepare(b); err != nil && err != arenaskl.ErrArenaFull {
+				return 0, false, err
 			}
 			// We loop since DB.newMemTable() slowly grows the size of allocated memtables, so the
 			// batch may not initially fit, but will eventually fit (since it is smaller than
@@ -684,18 +870,22 @@ func (d *DB) replayWAL(
 			for err == arenaskl.ErrArenaFull {
 				flushMem()
 				ensureMem(seqNum)
-				err = mem.prepare(&b)
+				err = mem.prepare(b)
 				if err != nil && err != arenaskl.ErrArenaFull {
-					return 0, err
+					return 0, false, err
 				}
 			}
-			if err = mem.apply(&b, seqNum); err != nil {
-				return 0, err
+			if err = applier.apply(mem, b, seqNum); err != nil {
+				return 0, false, err
 			}
-			mem.writerUnref()
 		}
 		buf.Reset()
 	}
+	// The memtables must not be flushed, nor read, until every batch has been
+	// applied.
+	if err := applier.finish(); err != nil {
+		return 0, false, err
+	}
 	flushMem()
 	// mem is nil here.
 	if !d.opts.ReadOnly {
@@ -703,14 +893,104 @@ func (d *DB) replayWAL(
 			1 /* base level */, toFlush)
 		newVE, _, err := d.runCompaction(jobID, c)
 		if err != nil {
-			return 0, err
+			return 0, false, err
 		}
 		ve.NewFiles = append(ve.NewFiles, newVE.NewFiles...)
 		for i := range toFlush {
 			toFlush[i].readerUnref()
 		}
 	}
-	return maxSeqNum, err
+	return maxSeqNum, truncated, err
+}
+
+// walReplayApplier applies the batches replayed from a WAL to memtables. If
+// its concurrency is greater than one, batches are applied asynchronously by
+// a pool of goroutines. See Options.WALReplayConcurrency.
+type walReplayApplier struct {
+	work     chan walReplayBatch
+	wg       sync.WaitGroup
+	finished bool
+
+	mu struct {
+		sync.Mutex
+		err error
+	}
+}
+
+// walReplayBatch is a batch to be applied to a memtable.
+type walReplayBatch struct {
+	mem    *memTable
+	b      *Batch
+	seqNum uint64
+}
+
+func newWALReplayApplier(concurrency int) *walReplayApplier {
+	a := &walReplayApplier{}
+	if concurrency <= 1 {
+		return a
+	}
+	a.work = make(chan walReplayBatch, concurrency)
+	a.wg.Add(concurrency)
+	for i := 0; i < concurrency; i++ {
+		go func() {
+			defer a.wg.Done()
+			for w := range a.work {
+				if err := applyReplayedBatch(w.mem, w.b, w.seqNum); err != nil {
+					a.mu.Lock()
+					if a.mu.err == nil {
+						a.mu.err = err
+					}
+					a.mu.Unlock()
+				}
+			}
+		}()
+	}
+	return a
+}
+
+// concurrent returns true if batches are applied asynchronously, in which
+// case they must not be modified after being passed to apply.
+func (a *walReplayApplier) concurrent() bool {
+	return a.work != nil
+}
+
+// apply applies the batch, which must have been prepared in mem. An error
+// encountered while applying a batch asynchronously is returned by a later
+// call to apply or finish.
+func (a *walReplayApplier) apply(mem *memTable, b *Batch, seqNum uint64) error {
+	if !a.concurrent() {
+		return applyReplayedBatch(mem, b, seqNum)
+	}
+	a.mu.Lock()
+	err := a.mu.err
+	a.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	a.work <- walReplayBatch{mem: mem, b: b, seqNum: seqNum}
+	return nil
+}
+
+// finish waits for all the batches to have been applied, and returns the
+// first error encountered applying them. The applier cannot be used after
+// finish has been called, though finish may be called again.
+func (a *walReplayApplier) finish() error {
+	if a.concurrent() && !a.finished {
+		a.finished = true
+		close(a.work)
+		a.wg.Wait()
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.mu.err
+}
+
+func applyReplayedBatch(mem *memTable, b *Batch, seqNum uint64) error {
+	if err := mem.apply(b, seqNum); err != nil {
+		return err
+	}
+	mem.writerUnref()
+	return nil
 }
 
 func checkOptions(opts *Options, path string) (strictWALTail bool, err error) {
@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"math"
 	"os"
 	"path/filepath"
 	"reflect"
@@ -19,7 +20,9 @@ import (
 	"sync/atomic"
 	"syscall"
 	"testing"
+	"time"
 
+	"github.com/cockroachdb/errors"
 	"github.com/cockroachdb/errors/oserror"
 	"github.com/cockroachdb/pebble/internal/base"
 	"github.com/cockroachdb/pebble/internal/cache"
@@ -28,6 +31,7 @@ import (
 	"github.com/cockroachdb/pebble/vfs/atomicfs"
 	"github.com/kr/pretty"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/exp/rand"
 )
 
 func TestOpenSharedTableCache(t *testing.T) {
@@ -148,7 +152,7 @@ func TestNewDBFilenames(t *testing.T) {
 			"LOCK",
 			"MANIFEST-000001",
 			"OPTIONS-000003",
-			"marker.format-version.000008.009",
+			"marker.format-version.000010.011",
 			"marker.manifest.000001.MANIFEST-000001",
 		},
 	}
@@ -923,6 +927,74 @@ func TestOpenWALReplayReadOnlySeqNums(t *testing.T) {
 	require.NoError(t, d.Close())
 }
 
+func TestOpenWALReplaySeqNumMismatch(t *testing.T) {
+	mem := vfs.NewMem()
+	d, err := Open("", &Options{FS: mem})
+	require.NoError(t, err)
+	require.NoError(t, d.Set([]byte("a"), nil, nil))
+	// Skip over some sequence numbers, as if the writes assigned them had been
+	// lost.
+	d.commit.mu.Lock()
+	atomic.AddUint64(&d.mu.versions.atomic.logSeqNum, 10)
+	atomic.AddUint64(&d.mu.versions.atomic.visibleSeqNum, 10)
+	d.commit.mu.Unlock()
+	seqNum, err := d.SetWithSeq([]byte("b"), nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, uint64(12), seqNum)
+	require.NoError(t, d.Set([]byte("c"), nil, nil))
+	require.NoError(t, d.Close())
+
+	open := func(action SeqNumMismatchAction, listener EventListener) (*DB, vfs.FS, error) {
+		fs := vfs.NewMem()
+		_, err := vfs.Clone(mem, fs, "", "")
+		require.NoError(t, err)
+		opts := &Options{FS: fs, EventListener: listener}
+		opts.Experimental.OnSeqNumMismatch = action
+		d, err := Open("", opts)
+		return d, fs, err
+	}
+	keys := func(d *DB) string {
+		var buf strings.Builder
+		iter := d.NewIter(nil)
+		for valid := iter.First(); valid; valid = iter.Next() {
+			buf.Write(iter.Key())
+		}
+		require.NoError(t, iter.Close())
+		return buf.String()
+	}
+
+	// By default the gap is ignored.
+	d, _, err = open(SeqNumMismatchAccept, EventListener{})
+	require.NoError(t, err)
+	require.Equal(t, "abc", keys(d))
+	require.Less(t, uint64(13), d.mu.versions.atomic.logSeqNum)
+	require.NoError(t, d.Close())
+
+	_, _, err = open(SeqNumMismatchFail, EventListener{})
+	require.Error(t, err)
+	require.True(t, errors.Is(err, base.ErrCorruption))
+
+	var infos []WALTruncateInfo
+	d, fs, err := open(SeqNumMismatchTruncate, EventListener{
+		WALTruncated: func(info WALTruncateInfo) {
+			infos = append(infos, info)
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "a", keys(d))
+	require.Len(t, infos, 1)
+	require.Equal(t, uint64(12), infos[0].SeqNum)
+	require.Equal(t, uint64(1), infos[0].RecoveredSeqNum)
+	require.NoError(t, d.Close())
+
+	// The truncation is durable: the discarded batches are not replayed when
+	// the DB is reopened.
+	d, err = Open("", &Options{FS: fs})
+	require.NoError(t, err)
+	require.Equal(t, "a", keys(d))
+	require.NoError(t, d.Close())
+}
+
 func TestOpenWALReplayMemtableGrowth(t *testing.T) {
 	mem := vfs.NewMem()
 	const memTableSize = 64 * 1024 * 1024
@@ -947,6 +1019,113 @@ func TestOpenWALReplayMemtableGrowth(t *testing.T) {
 	db.Close()
 }
 
+func TestOpenWALReplayConcurrency(t *testing.T) {
+	for _, readOnly := range []bool{false, true} {
+		t.Run(fmt.Sprintf("read-only=%t", readOnly), func(t *testing.T) {
+			mem := vfs.NewMem()
+			d, err := Open("", &Options{FS: mem, MemTableSize: 64 << 20})
+			require.NoError(t, err)
+
+			// Write many small batches, repeatedly overwriting, merging and
+			// deleting the same keys, so that a replay that reordered the
+			// writes to a key would produce different values.
+			rng := rand.New(rand.NewSource(uint64(time.Now().UnixNano())))
+			for i := 0; i < 5000; i++ {
+				b := d.NewBatch()
+				for j := 0; j < 1+rng.Intn(4); j++ {
+					key := []byte(fmt.Sprintf("%03d", rng.Intn(100)))
+					switch rng.Intn(10) {
+					case 0:
+						require.NoError(t, b.Delete(key, nil))
+					case 1:
+						end := []byte(fmt.Sprintf("%03d", rng.Intn(100)))
+						if bytes.Compare(key, end) < 0 {
+							require.NoError(t, b.DeleteRange(key, end, nil))
+						}
+					case 2, 3:
+						require.NoError(t, b.Merge(key, []byte(fmt.Sprint(i)), nil))
+					default:
+						require.NoError(t, b.Set(key, []byte(fmt.Sprint(i)), nil))
+					}
+				}
+				require.NoError(t, b.Commit(nil))
+			}
+			read := func(d *DB) string {
+				var buf strings.Builder
+				iter := d.NewIter(nil)
+				for valid := iter.First(); valid; valid = iter.Next() {
+					fmt.Fprintf(&buf, "%s:%s\n", iter.Key(), iter.Value())
+				}
+				require.NoError(t, iter.Close())
+				return buf.String()
+			}
+			expected := read(d)
+			require.NoError(t, d.Close())
+
+			// Replay the WAL on several goroutines, with small memtables so that
+			// the replay spans several of them.
+			d, err = Open("", &Options{
+				FS:                   mem,
+				MemTableSize:         256 << 10,
+				ReadOnly:             readOnly,
+				WALReplayConcurrency: 8,
+			})
+			require.NoError(t, err)
+			require.Equal(t, expected, read(d))
+			require.NoError(t, d.Close())
+		})
+	}
+}
+
+func TestOpenStrictManifestValidation(t *testing.T) {
+	mem := vfs.NewMem()
+	d, err := Open("", &Options{FS: mem})
+	require.NoError(t, err)
+	require.NoError(t, d.Set([]byte("a"), []byte("a"), nil))
+	require.NoError(t, d.Set([]byte("b"), []byte("b"), nil))
+	require.NoError(t, d.Flush())
+
+	// A file whose recorded seqnums are above the last seqnum recorded in the
+	// MANIFEST is reported.
+	d.mu.Lock()
+	iter := d.mu.versions.currentVersion().Levels[0].Iter()
+	f := iter.First()
+	largest := f.LargestSeqNum
+	f.LargestSeqNum = math.MaxUint64 >> 8
+	err = d.validateManifestLocked()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "largest seqnum")
+	f.LargestSeqNum = largest
+	require.NoError(t, d.validateManifestLocked())
+	d.mu.Unlock()
+	require.NoError(t, d.Close())
+
+	// Corrupt the footer of the sstable, preserving its size. Open only
+	// detects the corruption under strict validation.
+	path := base.MakeFilepath(mem, "", fileTypeTable, f.FileNum)
+	file, err := mem.Open(path)
+	require.NoError(t, err)
+	data, err := ioutil.ReadAll(file)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+	for i := len(data) - 8; i < len(data); i++ {
+		data[i] = 0xff
+	}
+	require.NoError(t, mem.Remove(path))
+	file, err = mem.Create(path)
+	require.NoError(t, err)
+	_, err = file.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	d, err = Open("", &Options{FS: mem})
+	require.NoError(t, err)
+	require.NoError(t, d.Close())
+	_, err = Open("", &Options{FS: mem, StrictManifestValidation: true})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), fmt.Sprintf("L0: file %s", f.FileNum))
+}
+
 func TestGetVersion(t *testing.T) {
 	mem := vfs.NewMem()
 	opts := &Options{
@@ -8,6 +8,7 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"math"
 	"runtime"
 	"strconv"
 	"strings"
@@ -24,6 +25,10 @@ import (
 
 const (
 	cacheDefaultSize = 8 << 20 // 8 MB
+	// estimatedOpenTableMemory is the estimated memory used by the table cache
+	// for each open sstable, excluding its blocks, which are held by the block
+	// cache. It's used to apportion Options.MemoryBudget.
+	estimatedOpenTableMemory = 16 << 10 // 16 KB
 )
 
 // Compression exports the base.Compression type.
@@ -37,6 +42,15 @@ const (
 	ZstdCompression    = sstable.ZstdCompression
 )
 
+// RangeKeyFragmentPolicy exports the sstable.RangeKeyFragmentPolicy type.
+type RangeKeyFragmentPolicy = sstable.RangeKeyFragmentPolicy
+
+// Exported RangeKeyFragmentPolicy constants.
+const (
+	RangeKeyFragmentAtBoundaries = sstable.RangeKeyFragmentAtBoundaries
+	RangeKeyFragmentAvoidSplits  = sstable.RangeKeyFragmentAvoidSplits
+)
+
 // FilterType exports the base.FilterType type.
 type FilterType = base.FilterType
 
@@ -60,6 +74,21 @@ type BlockPropertyCollector = sstable.BlockPropertyCollector
 // BlockPropertyFilter exports the sstable.BlockPropertyFilter type.
 type BlockPropertyFilter = base.BlockPropertyFilter
 
+// CorruptionAction exports the sstable.CorruptionAction type.
+type CorruptionAction = sstable.CorruptionAction
+
+// Exported CorruptionAction constants.
+const (
+	CorruptionFail      = sstable.CorruptionFail
+	CorruptionSkipBlock = sstable.CorruptionSkipBlock
+)
+
+// CorruptBlockError exports the sstable.CorruptBlockError type.
+type CorruptBlockError = sstable.CorruptBlockError
+
+// CompressionStats exports the sstable.CompressionStats type.
+type CompressionStats = sstable.CompressionStats
+
 // IterKeyType configures which types of keys an iterator should surface.
 type IterKeyType int8
 
@@ -89,6 +118,176 @@ func (t IterKeyType) String() string {
 	}
 }
 
+// SeqNumMismatchAction configures how Open handles a batch in the WAL whose
+// sequence number is ahead of the sequence numbers that precede it. See
+// Options.Experimental.OnSeqNumMismatch.
+type SeqNumMismatchAction int8
+
+const (
+	// SeqNumMismatchAccept replays the batch and advances the DB's sequence
+	// number past it. The sequence numbers skipped over belonged to writes
+	// that were lost, so the recovered state may include writes that were
+	// committed after the lost writes, and may not correspond to any state the
+	// DB was in before the crash.
+	SeqNumMismatchAccept SeqNumMismatchAction = iota
+	// SeqNumMismatchTruncate stops replaying the WAL at the batch, discarding
+	// it along with every later batch, including those in later WALs. The
+	// recovered state is then a prefix of the DB's commit history, but the
+	// discarded batches are lost even if they were synced. The truncation is
+	// reported through EventListener.WALTruncated. The WAL files themselves
+	// are not modified, but once Open has flushed the recovered state they're
+	// obsolete and are deleted.
+	SeqNumMismatchTruncate
+	// SeqNumMismatchFail fails Open with a corruption error, leaving the DB
+	// unmodified so that it may be examined or repaired.
+	SeqNumMismatchFail
+)
+
+// String implements fmt.Stringer.
+func (a SeqNumMismatchAction) String() string {
+	switch a {
+	case SeqNumMismatchAccept:
+		return "accept"
+	case SeqNumMismatchTruncate:
+		return "truncate"
+	case SeqNumMismatchFail:
+		return "fail"
+	default:
+		panic(fmt.Sprintf("unknown sequence number mismatch action %d", a))
+	}
+}
+
+// DirSyncPolicy configures when the DB syncs its data and WAL directories,
+// which makes the creation of the files within them durable. See
+// Options.Experimental.DirSyncPolicy.
+type DirSyncPolicy int8
+
+const (
+	// DirSyncPerOperation syncs a directory after every operation that
+	// creates a file within it that must survive a crash: before the sstables
+	// written by a flush, compaction or ingestion are recorded in the
+	// MANIFEST, and as each WAL is created. It is the safest policy, and the
+	// default.
+	DirSyncPerOperation DirSyncPolicy = iota
+	// DirSyncBatched coalesces concurrent syncs of the same directory into a
+	// single sync, shared by every operation that was waiting for a sync when
+	// it began. Each operation still waits for a sync of the directory that
+	// began after it created its files, so the crash consistency guarantees
+	// are the same as DirSyncPerOperation. The number of syncs is reduced
+	// when flushes, compactions and ingestions complete concurrently.
+	DirSyncBatched
+	// DirSyncDeferred batches syncs as DirSyncBatched does, and additionally
+	// defers the sync of a WAL's directory from the creation of the WAL to the
+	// first time the WAL is synced, as RocksDB does. A write committed with
+	// Sync remains durable, as the WAL's directory entry is synced along with
+	// the write. A WAL that is never synced, because every write to it was
+	// committed with NoSync, may be lost in a crash along with its writes even
+	// though the WAL's data was synced when the WAL was closed. If the WALs are
+	// split between the primary and secondary directories of
+	// Options.WALFailover, such a WAL may be lost while the synced writes
+	// committed after it to the other directory survive, so the recovered
+	// state may not be a prefix of the DB's commit history. The directories
+	// are still synced before sstables are recorded in the MANIFEST.
+	DirSyncDeferred
+)
+
+// String implements fmt.Stringer.
+func (p DirSyncPolicy) String() string {
+	switch p {
+	case DirSyncPerOperation:
+		return "per-operation"
+	case DirSyncBatched:
+		return "batched"
+	case DirSyncDeferred:
+		return "deferred"
+	default:
+		panic(fmt.Sprintf("unknown directory sync policy %d", p))
+	}
+}
+
+// IOPriorityClass is an I/O scheduling class, as used by the Linux I/O
+// schedulers that support I/O priorities, such as BFQ. See
+// Options.Experimental.CompactionIOPriority.
+type IOPriorityClass int8
+
+const (
+	// IOPriorityDefault leaves the I/O priority unchanged.
+	IOPriorityDefault IOPriorityClass = iota
+	// IOPriorityBestEffort schedules I/O in the best-effort class, the class
+	// of threads without an explicit I/O priority, at the priority level
+	// given by IOPriority.Level.
+	IOPriorityBestEffort
+	// IOPriorityIdle schedules I/O only when no other thread has performed
+	// I/O recently. Idle I/O may be starved by a busy foreground workload.
+	IOPriorityIdle
+)
+
+// String implements fmt.Stringer.
+func (c IOPriorityClass) String() string {
+	switch c {
+	case IOPriorityDefault:
+		return "default"
+	case IOPriorityBestEffort:
+		return "best-effort"
+	case IOPriorityIdle:
+		return "idle"
+	default:
+		panic(fmt.Sprintf("unknown I/O priority class %d", c))
+	}
+}
+
+// IOPriority is an I/O scheduling class and priority level.
+type IOPriority struct {
+	Class IOPriorityClass
+	// Level is the priority level within the best-effort class, from 0
+	// (highest) to 7 (lowest). Threads without an explicit I/O priority are
+	// scheduled at level 4. It's ignored by the other classes.
+	Level int
+}
+
+// SingleDeleteRangeDelAction configures how a compaction handles a SINGLEDEL
+// whose next older entry for the same key, within the same snapshot stripe,
+// is deleted by a range deletion that sorts between the two. See
+// Options.Experimental.OnSingleDeleteRangeDel.
+type SingleDeleteRangeDelAction int8
+
+const (
+	// SingleDeleteRangeDelConsume applies the SINGLEDEL to the deleted entry
+	// as though the range deletion didn't exist: if the entry is a SET, both
+	// the SINGLEDEL and the SET are elided. Since the SET was already deleted
+	// by the range deletion, which is retained, the result is unchanged as
+	// long as the SINGLEDEL was used as intended, with a single SET of the key
+	// since its last deletion.
+	SingleDeleteRangeDelConsume SingleDeleteRangeDelAction = iota
+	// SingleDeleteRangeDelToDelete transforms the SINGLEDEL into a DEL and
+	// elides the deleted entries beneath it in the snapshot stripe. The DEL
+	// continues to delete older entries of the key in lower levels, which
+	// avoids surfacing an older SET of the key if the key was set more than
+	// once, at the cost of retaining the tombstone until it reaches the
+	// bottom of the LSM.
+	SingleDeleteRangeDelToDelete
+	// SingleDeleteRangeDelFail fails the compaction with an error wrapping
+	// ErrSingleDeleteRangeDel that describes the keys involved. Failed
+	// compactions are reported through EventListener.BackgroundError and
+	// retried, so this is intended for tests and debug builds, to flag uses of
+	// SINGLEDEL on keys that may have been deleted by a range deletion.
+	SingleDeleteRangeDelFail
+)
+
+// String implements fmt.Stringer.
+func (a SingleDeleteRangeDelAction) String() string {
+	switch a {
+	case SingleDeleteRangeDelConsume:
+		return "consume"
+	case SingleDeleteRangeDelToDelete:
+		return "to-delete"
+	case SingleDeleteRangeDelFail:
+		return "fail"
+	default:
+		panic(fmt.Sprintf("unknown single delete range deletion action %d", a))
+	}
+}
+
 // IterOptions hold the optional per-query parameters for NewIter.
 //
 // Like Options, a nil *IterOptions is valid and means to use the default
@@ -97,13 +296,31 @@ type IterOptions struct {
 	// LowerBound specifies the smallest key (inclusive) that the iterator will
 	// return during iteration. If the iterator is seeked or iterated past this
 	// boundary the iterator will return Valid()==false. Setting LowerBound
-	// effectively truncates the key space visible to the iterator.
+	// effectively truncates the key space visible to the iterator. A nil
+	// LowerBound imposes no lower bound, as does an empty LowerBound, since the
+	// empty key is the smallest key.
 	LowerBound []byte
 	// UpperBound specifies the largest key (exclusive) that the iterator will
 	// return during iteration. If the iterator is seeked or iterated past this
 	// boundary the iterator will return Valid()==false. Setting UpperBound
-	// effectively truncates the key space visible to the iterator.
+	// effectively truncates the key space visible to the iterator. A nil
+	// UpperBound imposes no upper bound, while an empty, non-nil UpperBound
+	// excludes every key.
 	UpperBound []byte
+	// PrefixBound, if non-nil, restricts iteration to keys beginning with
+	// PrefixBound, as if the iterator's bounds were set to PrefixBound and the
+	// smallest key greater than every key beginning with PrefixBound. It
+	// composes with LowerBound and UpperBound: the iterator is bounded by the
+	// intersection of the two ranges. If PrefixBound consists solely of 0xff
+	// bytes, no key greater than PrefixBound lies outside the prefix, so it
+	// imposes no upper bound.
+	//
+	// PrefixBound is a prefix of the bytes of user keys, not of the prefixes
+	// returned by Comparer.Split, and requires a Comparer under which the keys
+	// beginning with any byte prefix form a contiguous range. This is the case
+	// for DefaultComparer and for comparers that order keys bytewise before
+	// considering their suffixes.
+	PrefixBound []byte
 	// TableFilter can be used to filter the tables that are scanned during
 	// iteration based on the user properties. Return true to scan the table and
 	// false to skip scanning. This function must be thread-safe since the same
@@ -126,6 +343,19 @@ type IterOptions struct {
 	// range keys. Range key masking is only supported during combined range key
 	// and point key iteration mode (IterKeyTypePointsAndRanges).
 	RangeKeyMasking RangeKeyMasking
+	// RangeKeyResolver, if set, combines the range keys overlapping a position
+	// into a single range key. When the iterator steps onto a new range key
+	// span, the resolver is called once with the span's keys, ordered by
+	// suffix in the same order as returned by Iterator.RangeKeys, and
+	// Iterator.RangeKeys returns only the range key it returns. The resolver is
+	// not called again while the iterator remains within the same span, so its
+	// cost is a function call per range key span visited. The returned suffix
+	// and value must remain valid as long as the slices passed to the
+	// resolver, for example by referencing them. Range key masking and range
+	// key filters are unaffected and continue to operate on the unresolved
+	// range keys. If nil, Iterator.RangeKeys returns every range key
+	// overlapping the iterator's position.
+	RangeKeyResolver func(keys []RangeKeyData) RangeKeyData
 
 	// OnlyReadGuaranteedDurable is an advanced option that is only supported by
 	// the Reader implemented by DB. When set to true, only the guaranteed to be
@@ -163,6 +393,94 @@ type IterOptions struct {
 	// existing is not low or if we just expect a one-time Seek (where loading the
 	// data block directly is better).
 	UseL6Filters bool
+	// OnCorruption, if non-nil, is invoked when the iterator fails to load a
+	// block of an sstable due to corruption. The callback is passed the file
+	// number of the sstable, the offset of the corrupt block within the file
+	// and a *CorruptBlockError describing the keys contained in the block. If
+	// it returns CorruptionSkipBlock, the keys in the block are omitted and
+	// iteration continues with the next block. Otherwise the corruption error
+	// is surfaced through Iterator.Error, which is also the behavior when
+	// OnCorruption is nil.
+	//
+	// Skipping a corrupt block may surface keys that were shadowed by keys in
+	// the skipped block, and is intended for best-effort recovery of data from
+	// a damaged DB. Corruption of range deletion and range key blocks, and of
+	// the top level of an sstable's index, is always surfaced as an error.
+	// This function must be thread-safe since the same function can be used
+	// by multiple iterators, if the iterator is cloned.
+	OnCorruption func(file FileNum, offset int64, err error) CorruptionAction
+	// OnKeyAccess, if non-nil, is invoked with the current key each time a
+	// positioning method (eg, SeekGE, First, Next) leaves the iterator at a
+	// valid position. It is intended for analyzing key access patterns, for
+	// example to build a heat map of accessed keys.
+	//
+	// The callback is invoked synchronously on every positioning call and adds
+	// its cost to every step of iteration, so it should be cheap and it is
+	// recommended to only configure it for a sampled fraction of iterators.
+	// The key passed to the callback is only valid for the duration of the
+	// call and must not be retained or modified; the callback must copy the
+	// key if it needs it after returning.
+	OnKeyAccess func(key []byte)
+	// DisableCacheFill, if true, prevents the data, index and filter blocks of
+	// sstables read by the iterator from being added to the block cache. Blocks
+	// already present in the cache are still read from it. It is intended for
+	// large one-off scans, such as backups or analytics queries, that would
+	// otherwise evict the hot working set from the cache. It is the inverse of
+	// RocksDB's ReadOptions.fill_cache, and the zero value fills the cache.
+	//
+	// Blocks not added to the cache are read from disk each time the iterator
+	// loads them, so an iterator that revisits blocks, for example by seeking
+	// back and forth, reads them repeatedly. Range deletion and range key
+	// blocks are always added to the cache.
+	DisableCacheFill bool
+	// PrefetchBlocks, if positive, configures the iterator to asynchronously
+	// read up to PrefetchBlocks data blocks ahead of its current data block
+	// into the block cache during forward iteration, overlapping the I/O of
+	// reading them with the processing of the current block. It is intended
+	// for sequential scans of storage with high read latency. Blocks that can
+	// only contain keys at or above the upper bound are not prefetched, nor
+	// are blocks excluded by PointKeyFilters. Prefetching applies within each
+	// sstable, and does not read ahead across the sstables of a level.
+	//
+	// Prefetched blocks are read through the block cache, so prefetching is
+	// disabled if DisableCacheFill is set. The number of loaded blocks that
+	// were prefetched is reported by IteratorStats.InternalStats's
+	// PrefetchHits and PrefetchMisses.
+	PrefetchBlocks int
+	// SeekCacheSize, if positive, configures the iterator to cache the results
+	// of up to SeekCacheSize of its most recent SeekGE calls. A SeekGE to a key
+	// found in the cache positions the iterator at the cached result without
+	// seeking through the levels of the LSM. It is intended for iterators that
+	// are reused to repeatedly seek to the same hot keys.
+	//
+	// A cache hit defers repositioning the LSM's iterators until the iterator
+	// is stepped with Next or Prev, so it only saves work for callers that
+	// read the key and value at the seek position and then seek elsewhere.
+	// The cache is emptied whenever the iterator's bounds change or its view
+	// of the DB is refreshed by SetOptions. Only SeekGE calls without a limit
+	// by iterators that iterate over point keys only and do not read through
+	// an indexed batch use the cache.
+	SeekCacheSize int
+	// SampleStride, if greater than 1, makes Next skip ahead by approximately
+	// SampleStride keys rather than step to the next key, so that iterating
+	// surfaces about every SampleStride-th key. The skip is made by navigating
+	// the index blocks of the sstables of the bottommost level holding keys
+	// beyond the iterator's position: the number of keys per data block is
+	// estimated from each sstable's properties, and the iterator seeks past
+	// the data blocks holding the keys skipped. The data blocks stepped over
+	// are never loaded, so the keys and values of skipped keys aren't read.
+	//
+	// The stride is approximate. It's estimated from a single level, and is
+	// accurate to within about a data block when that level holds most of the
+	// keys in the range, as the bottommost level usually does. Keys in the
+	// memtables and in other levels that fall within a skip are skipped too,
+	// without being counted. When the stride is shorter than a data block, or
+	// the keys beyond the iterator's position are only in the memtables and
+	// L0, Next instead steps over SampleStride keys one at a time, counting
+	// keys that are deleted, and reads them. The keys skipped are never
+	// surfaced, and deletions and merge operands among them aren't resolved.
+	// Seeks, Prev, and iterators that iterate over range keys are unaffected.
+	SampleStride int
 	// Internal options.
 	logger Logger
 	// Level corresponding to this file. Only passed in if constructed by a
@@ -321,6 +639,14 @@ type LevelOptions struct {
 	BlockRestartInterval int
 
 	// BlockSize is the target uncompressed size in bytes of each table block.
+	// It applies to the sstables written into the level by flushes and
+	// compactions, so that, for example, upper levels may use smaller blocks
+	// for finer-grained point reads while the bottommost level uses larger
+	// blocks for better compression and smaller indexes.
+	//
+	// An sstable keeps the block size it was written with until a compaction
+	// rewrites it into another level. Sstables moved to another level without
+	// being rewritten, and ingested sstables, retain their block size.
 	//
 	// The default value is 4096.
 	BlockSize int
@@ -332,6 +658,13 @@ type LevelOptions struct {
 	// The default value is 90
 	BlockSizeThreshold int
 
+	// BlockAlignment, if greater than 1, aligns the blocks of the sstables
+	// written to the level to multiples of BlockAlignment bytes, padding the
+	// files as necessary. See sstable.WriterOptions.BlockAlignment.
+	//
+	// The default value (zero) means no alignment.
+	BlockAlignment int
+
 	// Compression defines the per-block compression to use.
 	//
 	// The default value (DefaultCompression) uses snappy compression.
@@ -344,6 +677,14 @@ type LevelOptions struct {
 	// package.
 	//
 	// The default value means to use no filter.
+	//
+	// A table is written with the filter policy of the level it's written
+	// to: a flush uses L0's policy, and a compaction the output level's. A
+	// table moved to another level without being rewritten, such as by a move
+	// compaction, keeps the filter it was written with until a compaction
+	// rewrites it. Leaving the policy nil for a level disables filters there,
+	// which saves space in levels where they're of little use, such as the
+	// last level of a DB serving mostly range scans.
 	FilterPolicy FilterPolicy
 
 	// FilterType defines whether an existing filter policy is applied at a
@@ -355,6 +696,13 @@ type LevelOptions struct {
 	// filters should be preferred except under constrained memory situations.
 	FilterType FilterType
 
+	// RangeKeyFragmentPolicy configures how range keys are fragmented at the
+	// boundaries between the sstables written to the level by a flush or
+	// compaction. See sstable.RangeKeyFragmentPolicy.
+	//
+	// The default value is RangeKeyFragmentAtBoundaries.
+	RangeKeyFragmentPolicy RangeKeyFragmentPolicy
+
 	// IndexBlockSize is the target uncompressed size in bytes of each index
 	// block. When the index block size is larger than this target, two-level
 	// indexes are automatically enabled. Setting this option to a large value
@@ -364,6 +712,27 @@ type LevelOptions struct {
 	// The default value is the value of BlockSize.
 	IndexBlockSize int
 
+	// MaxCompactionConcurrency is the maximum number of automatic compactions
+	// outputting to the level that may run concurrently. It shapes the
+	// distribution of compaction IO across the LSM, for example by preventing
+	// compactions into Lbase from using all of the compaction concurrency
+	// permitted by Options.MaxConcurrentCompactions. Manual compactions and
+	// flushes are not subject to the limit.
+	//
+	// The default value (zero) means no per-level limit.
+	MaxCompactionConcurrency int
+
+	// MaxKeysPerFile is the maximum number of point keys in each sstable
+	// written to the level by a flush or compaction. An output sstable is
+	// finished once it reaches either MaxKeysPerFile keys or TargetFileSize
+	// bytes, whichever comes first, so a small limit produces sstables
+	// smaller than the target size. It bounds the size of index blocks and
+	// the cost of per-file operations when keys are small. See
+	// sstable.WriterOptions.MaxKeysPerFile.
+	//
+	// The default value (zero) means no limit.
+	MaxKeysPerFile int
+
 	// The target file size for the level.
 	TargetFileSize int64
 }
@@ -396,6 +765,103 @@ func (o *LevelOptions) EnsureDefaults() *LevelOptions {
 	return o
 }
 
+// AdaptiveMemTableSizeOptions configure the adaptive sizing of memtables.
+//
+// When enabled, the size of each new memtable is chosen such that, at the
+// write rate observed while the previous memtable was mutable, the new
+// memtable fills in approximately TargetFlushInterval. Memtables shrink
+// towards MinSize when the write rate drops, and grow towards MaxSize when it
+// rises. To smooth flush behavior across bursts, a memtable is at most twice
+// as large, and at least half as large, as the one preceding it.
+//
+// Options.MemTableSize continues to bound the size of memtables, and
+// MemTableStopWritesThreshold continues to be expressed in multiples of
+// Options.MemTableSize. Batches larger than half of MaxSize bypass the
+// memtable and are flushed directly, as batches larger than half of
+// Options.MemTableSize are when adaptive sizing is disabled.
+type AdaptiveMemTableSizeOptions struct {
+	// Enabled enables adaptive memtable sizing. When disabled, memtables grow
+	// from 256 KB to Options.MemTableSize and remain at that size.
+	Enabled bool
+
+	// MinSize is the smallest size of a memtable.
+	//
+	// The default value is 256 KB, or MaxSize if smaller.
+	MinSize int
+
+	// MaxSize is the largest size of a memtable. It must not exceed
+	// Options.MemTableSize.
+	//
+	// The default value is Options.MemTableSize.
+	MaxSize int
+
+	// TargetFlushInterval is the duration in which a memtable should fill at
+	// the current write rate.
+	//
+	// The default value is 10 seconds.
+	TargetFlushInterval time.Duration
+}
+
+// WALFailoverOptions configure the failover of the WAL to a secondary
+// directory while the primary WAL directory is unhealthy.
+//
+// The primary WAL directory is considered unhealthy when a sync of the current
+// WAL takes longer than UnhealthySyncLatencyThreshold, when
+// UnhealthyErrorThreshold writes to or syncs of WALs in it have failed, or when
+// a new WAL cannot be created in it. The memtable is then rotated, and the WAL
+// backing the new memtable is created in the secondary directory. WALs
+// continue to be created in the secondary directory until FailbackInterval has
+// elapsed, at which point the memtable is rotated again and WALs are once
+// again created in the primary directory. If the primary directory is still
+// unhealthy, the WAL fails over again.
+//
+// A WAL cannot be written to after a write to or sync of it fails. If the WAL
+// is in the primary directory, the memtable is rotated, and the new WAL is
+// created in the primary directory, or in the secondary directory if the
+// failure made the primary directory unhealthy. The writes to the failed WAL
+// are made durable by flushing the rotated memtable, and commits, including
+// the synced write whose sync failed, wait for the flush to complete. The
+// failed WAL may not have been closed cleanly, so if the process crashes
+// before the flush completes, Open may find it to be corrupt. A failure of a
+// WAL in the secondary directory is not recovered from.
+//
+// A WAL sync that never completes continues to stall writes. When the DB is
+// opened, the WALs in both directories are replayed in the order in which they
+// were created. Because the secondary directory may hold WALs that haven't
+// been flushed, it must always be configured when opening the DB once failover
+// has been used.
+type WALFailoverOptions struct {
+	// SecondaryFS is the filesystem holding the secondary WAL directory.
+	//
+	// The default value is Options.FS.
+	SecondaryFS vfs.FS
+
+	// SecondaryDir is the secondary WAL directory. It must not be the primary
+	// WAL directory. It's created if it doesn't exist.
+	SecondaryDir string
+
+	// UnhealthySyncLatencyThreshold is the latency of a WAL sync above which
+	// the primary WAL directory is considered unhealthy.
+	//
+	// The default value is 100 milliseconds.
+	UnhealthySyncLatencyThreshold time.Duration
+
+	// UnhealthyErrorThreshold is the number of failed writes to or syncs of
+	// WALs in the primary WAL directory, since the last failover or failback,
+	// at which the primary WAL directory is considered unhealthy. Below the
+	// threshold, a failed WAL is replaced by a new WAL in the primary
+	// directory.
+	//
+	// The default value is 1, failing over on the first error.
+	UnhealthyErrorThreshold int
+
+	// FailbackInterval is how long WALs are created in the secondary directory
+	// after a failover before failing back to the primary directory.
+	//
+	// The default value is 1 minute.
+	FailbackInterval time.Duration
+}
+
 // Options holds the optional parameters for configuring pebble. These options
 // apply to the DB at large; per-query options are defined by the IterOptions
 // and WriteOptions types.
@@ -411,14 +877,67 @@ type Options struct {
 
 	// Cache is used to cache uncompressed blocks from sstables.
 	//
-	// The default cache size is 8 MB.
+	// The default cache size is 8 MB, unless MemoryBudget is set.
 	Cache *cache.Cache
 
-	// Cleaner cleans obsolete files.
+	// MemoryBudget, if positive, is the memory that the DB's block cache,
+	// memtables and table cache may use in total. It's apportioned among them
+	// by the following policy, unless overridden by the individual options:
+	//
+	//  - MemTableSize defaults to an eighth of the budget divided by
+	//    MemTableStopWritesThreshold, so that the memtables that may be
+	//    queued before writes are stopped use at most an eighth of the
+	//    budget.
+	//  - MaxOpenFiles defaults to the number of open sstables whose
+	//    estimated memory is a sixteenth of the budget, and no fewer than
+	//    the default of 1000.
+	//  - If Cache is nil, the block cache is created with the remainder of
+	//    the budget after the table cache's estimated memory, but no less
+	//    than a quarter of it.
+	//
+	// The memory used by memtables is reserved from the block cache, so the
+	// block cache's share of the budget shrinks as memtables fill and grows
+	// as they're flushed. The current apportionment is reported by
+	// Metrics.MemoryBudget. The budget does not account for the memory used
+	// by iterators, compactions or batches.
+	MemoryBudget int64
+
+	// Cleaner cleans obsolete files. PolicyCleaner chooses, for each file,
+	// whether to delete it, archive it, or hand it off to a callback.
+	//
+	// A file is only cleaned once it's obsolete: for an sstable, once it has
+	// been removed from the LSM by a version edit that has been synced to the
+	// MANIFEST and no iterator or snapshot still reads it; for a WAL, once its
+	// contents have been flushed. File numbers are never reused, so the DB
+	// never writes to the path of a file being cleaned. The files released
+	// by a cleaning job are passed to the cleaner one at a time, in the order
+	// WALs, sstables, MANIFESTs, then OPTIONS files, each by increasing file
+	// number. A cleaner implementing AsyncCleaner may finish with files out
+	// of that order. DB.Close waits for the cleaner to finish with every file
+	// it was passed, and the event listener's WALDeleted, TableDeleted and
+	// ManifestDeleted are invoked once it has. A file that is still present
+	// when the DB is next opened, e.g. because the process exited before an
+	// asynchronous cleaner finished with it, is passed to the cleaner again,
+	// so cleaners must tolerate seeing a file more than once.
+	//
+	// A cleaner that needs the contents of the files it cleans, such as
+	// ArchiveCleaner and PolicyCleaner, disables the recycling of WAL files.
 	//
 	// The default cleaner uses the DeleteCleaner.
 	Cleaner Cleaner
 
+	// CompactionReadaheadSize is the size of the buffer each compaction input
+	// iterator uses to read ahead of the data block it is positioned at. It
+	// does not affect reads performed by user iterators or Get. Larger values
+	// reduce the number of reads issued per compaction, which can improve
+	// compaction throughput on storage with high per-read latency such as
+	// cloud block storage. Smaller values reduce the memory used by
+	// compactions.
+	//
+	// The default value is 0, in which case compactions rely on OS-level
+	// readahead.
+	CompactionReadaheadSize int
+
 	// Comparer defines a total ordering over the space of []byte keys: a 'less
 	// than' relationship. The same comparison algorithm must be used for reads
 	// and writes over the lifetime of the DB.
@@ -473,12 +992,127 @@ type Options struct {
 		// concurrency slots as determined by the two options is chosen.
 		CompactionDebtConcurrency int
 
+		// CompactionIOPriority configures the I/O priority of compactions, so
+		// that their I/O yields to the I/O of foreground reads and flushes. It
+		// applies to the reads and writes performed by the goroutine running
+		// a compaction, which is locked to its OS thread while the priority is
+		// set. I/O performed on a compaction's behalf by other goroutines, such
+		// as the writes of sstable writers with writer parallelism enabled
+		// (see MaxWriterConcurrency), and write-back of dirty pages by the
+		// kernel, are not affected. Flushes are not affected either, as
+		// delaying them stalls writes.
+		//
+		// I/O priorities are only supported on Linux, and only take effect
+		// with an I/O scheduler that supports them, such as BFQ. On other
+		// platforms, or if the priority can't be set, Open logs a message and
+		// the option has no effect. The default, IOPriorityDefault, leaves the
+		// I/O priority of compactions unchanged.
+		CompactionIOPriority IOPriority
+
 		// DeleteRangeFlushDelay configures how long the database should wait
 		// before forcing a flush of a memtable that contains a range
 		// deletion. Disk space cannot be reclaimed until the range deletion
 		// is flushed. No automatic flush occurs if zero.
 		DeleteRangeFlushDelay time.Duration
 
+		// AdaptiveMemTableSize configures the adaptive sizing of memtables based
+		// on the recent write rate. See AdaptiveMemTableSizeOptions.
+		AdaptiveMemTableSize AdaptiveMemTableSizeOptions
+
+		// OnSeqNumMismatch configures how Open handles a batch in the WAL whose
+		// sequence number is ahead of the next expected sequence number: the
+		// larger of the sequence number following the MANIFEST's last sequence
+		// number, and the sequence number following the batches replayed so
+		// far. Sequence numbers are assigned contiguously, and the sequence
+		// numbers of ingested sstables are recorded in the MANIFEST, so such a
+		// gap indicates that the writes assigned the skipped sequence numbers
+		// were lost, for example because a WAL file was lost in a partial crash.
+		// A gap may also result from an ingestion that failed after it was
+		// assigned a sequence number.
+		//
+		// The default, SeqNumMismatchAccept, replays the batch regardless.
+		OnSeqNumMismatch SeqNumMismatchAction
+
+		// DirSyncPolicy configures when the data and WAL directories are
+		// synced to make the creation of files within them durable. Syncing a
+		// directory is expensive on some filesystems, and DirSyncBatched and
+		// DirSyncDeferred reduce the number of directory syncs. See the
+		// DirSyncPolicy constants for the crash consistency implications of
+		// each policy. The default, DirSyncPerOperation, syncs a directory
+		// after every operation that requires it.
+		DirSyncPolicy DirSyncPolicy
+
+		// GCFloorSeqNum, if non-zero, is the initial GC floor: compactions only
+		// elide point and range deletions with sequence numbers less than the
+		// floor. Deletions at or above the floor are retained even when they are
+		// compacted into the bottommost level and shadow no data, allowing an
+		// external process to observe them before they are garbage collected.
+		// The floor may be advanced (or cleared by setting it to zero) while the
+		// DB is open using DB.SetGCFloorSeqNum.
+		//
+		// The floor constrains elision in addition to open snapshots: a deletion
+		// is only elided if it is below both the floor and the earliest open
+		// snapshot. Unlike a snapshot, the floor does not retain the data that a
+		// deletion shadows, only the deletion itself. Retained deletions occupy
+		// space in the LSM and must be skipped by iterators, so a floor that is
+		// not advanced regularly increases both space and read amplification for
+		// workloads that delete frequently.
+		GCFloorSeqNum uint64
+
+		// MaxVersionsPerKey, if non-zero, is the number of versions of each key
+		// retained by compactions. The versions of a key are the user keys
+		// sharing the prefix returned by Comparer.Split, and are assumed to sort
+		// from newest to oldest, as with MVCC timestamps encoded in descending
+		// order; without a Split function, every user key is its own single
+		// version. Once a compaction has written MaxVersionsPerKey versions of a
+		// key, it elides the older versions, along with all of their entries.
+		//
+		// Versions are only counted and elided within the last snapshot stripe:
+		// a version written after the earliest open snapshot doesn't count
+		// towards the limit, and a version is only elided if it is visible to
+		// every open snapshot, so a snapshot pins the versions that it observes
+		// until it is closed. Versions at or above the GC floor (see
+		// GCFloorSeqNum) are never elided, nor are versions with entries that
+		// may exist in levels below the compaction's output level. Versions are
+		// counted among the keys of each compaction, without regard for newer
+		// deletions in levels above it that may delete some of the counted
+		// versions. Deleted versions don't count towards the limit.
+		//
+		// The number of elided versions is reported by
+		// Metrics.Compact.VersionsElided.
+		MaxVersionsPerKey int
+
+		// OnSingleDeleteRangeDel configures how compactions handle a SINGLEDEL
+		// whose next older entry for the same key, within the same snapshot
+		// stripe, is deleted by a range deletion with a sequence number between
+		// theirs: that is, a SINGLEDEL of a key that was set, then deleted by a
+		// range deletion, and then single deleted. The default,
+		// SingleDeleteRangeDelConsume, applies the SINGLEDEL to the entry as
+		// though the range deletion didn't exist. See SingleDeleteRangeDelAction
+		// for the alternatives.
+		//
+		// A SINGLEDEL that is itself deleted by a newer range deletion is always
+		// elided, and a SINGLEDEL separated from the entry by a snapshot is left
+		// in place until the snapshot is closed, regardless of this option.
+		OnSingleDeleteRangeDel SingleDeleteRangeDelAction
+
+		// CompressionForKeyRange, if set, chooses the compression of each
+		// sstable written by flushes and compactions from its key range,
+		// overriding the Compression of the output level's LevelOptions. It's
+		// called as each output sstable is started, with the sstable's smallest
+		// user key and, as the sstable's own largest key isn't known yet, the
+		// largest user key of the flush or compaction, which bounds it. Both
+		// bounds are inclusive. Returning DefaultCompression selects the
+		// compression of the output level, which is also used when
+		// CompressionForKeyRange is nil.
+		//
+		// The compression is chosen once for each sstable, so an sstable
+		// extending across key ranges that call for different compressions is
+		// compressed according to the range it starts in. The compression of
+		// ingested sstables, and of those written by sstable.Writer outside of
+		// the DB, is unaffected.
+		CompressionForKeyRange func(lower, upper []byte) Compression
+
 		// MinDeletionRate is the minimum number of bytes per second that would
 		// be deleted. Deletion pacing is used to slow down deletions when
 		// compactions finish up or readers close, and newly-obsolete files need
@@ -543,17 +1177,158 @@ type Options struct {
 		// NOTE: callers should take care to not mutate the key being validated.
 		KeyValidationFunc func(userKey []byte) error
 
+		// OnGetKeyAccess, if non-nil, is invoked with the key found by each
+		// successful call to Get on the DB, or on one of its batches or
+		// snapshots. It is the Get equivalent of IterOptions.OnKeyAccess, and
+		// the same restrictions and performance caveats apply.
+		OnGetKeyAccess func(key []byte)
+
 		// ValidateOnIngest schedules validation of sstables after they have
 		// been ingested.
 		//
 		// By default, this value is false.
 		ValidateOnIngest bool
 
+		// ValidateOutputs, if true, validates each sstable written by a flush
+		// or compaction before the flush or compaction is applied to the LSM.
+		// The sstable is read back from the filesystem, bypassing the block
+		// cache, and validated: the checksums of all of its blocks are
+		// verified, its point keys must be in strictly increasing order, and
+		// its point keys, range deletions and range keys must fall within the
+		// bounds recorded for the sstable. This catches corruption introduced
+		// while writing sstables before it can become part of the LSM, at the
+		// cost of reading every output sstable once more.
+		//
+		// If validation fails, the flush or compaction fails without changing
+		// the LSM and its outputs are deleted. The error is reported through
+		// EventListener.BackgroundError, and the flush or compaction is retried
+		// as if it had failed for any other reason. Failures are counted in
+		// Metrics.Compact.OutputValidationFailures.
+		//
+		// By default, this value is false.
+		ValidateOutputs bool
+
+		// StrictIngestBlockProperties makes ingestion fail if an sstable's
+		// property collectors don't match those configured through
+		// Options.TablePropertyCollectors and Options.BlockPropertyCollectors:
+		// either the sstable carries properties from a collector that isn't
+		// configured, or it lacks the properties of a configured block
+		// property collector. Block property filters are matched to an
+		// sstable's properties by collector name, so the blocks of an sstable
+		// lacking a collector's properties are never excluded by that
+		// collector's filters. When false, such mismatches are logged at
+		// ingestion, and the sstable is ingested.
+		StrictIngestBlockProperties bool
+
 		// MultiLevelCompaction allows the compaction of SSTs from more than two
 		// levels iff a conventional two level compaction will quickly trigger a
 		// compaction in the output level.
 		MultiLevelCompaction bool
 
+		// SpaceReclamationPriority biases compaction picking toward reclaiming
+		// the disk space occupied by data deleted by point and range
+		// tombstones, at the cost of additional write amplification. It is the
+		// weight given to the estimate of the disk space that compacting a
+		// file's tombstones may reclaim:
+		//
+		//  - When choosing the file to compact within a level, a file's
+		//    reclaimable bytes are counted 1+SpaceReclamationPriority times in
+		//    its compensated size, in place of once.
+		//  - When no level requires compaction, a file outside of L0 and the
+		//    bottommost level whose reclaimable bytes, multiplied by
+		//    SpaceReclamationPriority, are at least its size is compacted into
+		//    the next level. For example, a value of 10 compacts the files
+		//    whose tombstones are estimated to delete at least 10% of their
+		//    size in data beneath them.
+		//
+		// The disk space reclaimed by compactions is reported by
+		// Metrics.Compact.ReclaimedBytes. The default value of zero disables
+		// the bias.
+		SpaceReclamationPriority float64
+
+		// TargetWriteAmp is the write amplification that the sizing of the
+		// levels aims to approach. By default, the base level (the level into
+		// which L0 is compacted) is the deepest level that keeps the ratio
+		// between the sizes of adjacent levels near 10, and the ratio is then
+		// smoothed so the levels grow evenly from LBaseMaxBytes to the bottom
+		// level. When TargetWriteAmp is positive, the base level is instead the
+		// deepest level whose estimated write amp, (levels from the base level
+		// to the bottom) * (ratio + 1), is at most TargetWriteAmp. A lower
+		// target spreads data over more levels with smaller ratios, trading
+		// read amplification for write amplification. If no level meets the
+		// target, the level with the lowest estimated write amp is used.
+		//
+		// The ratio and estimated write amp in effect are reported by
+		// Metrics.Compact.LevelMultiplier and EstimatedWriteAmp, and the
+		// measured write amp by Metrics.Total().WriteAmp(). The default value of
+		// zero disables the tuning.
+		TargetWriteAmp float64
+
+		// MaxInlineValueSize, if positive, is the size above which the values
+		// of SET, SETWITHDEL and MERGE entries written to sstables are chunked
+		// into value blocks, each no larger than the block size of the level.
+		// The data block holds a small handle to the chunks in place of the
+		// value, so large values don't inflate data blocks beyond the block
+		// size, and iterators and Get transparently reassemble the value.
+		// Iterator reads a chunked value only when Value is called, but
+		// reverse iteration, merges and compactions read it in full whenever
+		// they're positioned at its key. Compactions chunk it again when
+		// writing their output.
+		//
+		// Each operand of a merge is an entry of its own, and is chunked or
+		// stored inline independently of the other operands of the key. The
+		// merged value produced by a compaction is chunked if it exceeds the
+		// threshold. Chunking applies to values within a single sstable, and is
+		// independent of separating values into blob files shared across
+		// sstables, which Pebble does not implement.
+		//
+		// Value blocks require sstable.TableFormatPebblev4, so values are only
+		// chunked in sstables written once the format major version is at least
+		// FormatChunkedValues. The default value of zero stores every value in
+		// its data block.
+		MaxInlineValueSize int
+
+		// MaxGrandparentOverlapBytes, if positive, is the maximum number of
+		// bytes of overlap with the grandparent level (the level beneath the
+		// output level) allowed for a single output sstable of a compaction,
+		// and for a file moved to the next level by a trivial move. A
+		// compaction's output is split into a new sstable before it would
+		// overlap more, so that a later compaction of the output into the
+		// grandparent level isn't too large. The default of zero uses 10 times
+		// the target file size of the output level.
+		MaxGrandparentOverlapBytes int64
+
+		// MaxCompactionOverlapRatio bounds how far the compaction picker
+		// expands the inputs of a compaction beyond the files it initially
+		// picks, as a ratio of the total size of the compaction's inputs to
+		// the target file size of its output level. The picker adds files of
+		// the start level that don't widen the compaction's overlap with the
+		// output level, and L0 files sharing the compaction's key range, as
+		// long as the inputs remain within the ratio. A larger ratio allows
+		// fewer, larger compactions, rewriting each output level file fewer
+		// times, while a smaller one keeps compactions short. The default of
+		// zero uses a ratio of 25.
+		MaxCompactionOverlapRatio float64
+
+		// ReadDrivenCompaction enables using the number of times each file
+		// has been read, as reported by SSTableInfo.ReadCount, to choose
+		// between files that are otherwise equally good candidates for
+		// compaction out of a level. The file read most often is compacted
+		// first, so that hot regions of the LSM spanning several levels are
+		// consolidated before cold ones, reducing the read amplification
+		// observed by skewed workloads. Read counts are held in memory and
+		// reset when the DB is reopened.
+		ReadDrivenCompaction bool
+
+		// TrackIteratorStacks enables the recording of the goroutine stack at
+		// the creation of every iterator, until the iterator is closed, so that
+		// the oldest open iterators may be retrieved with DB.OldestIterators
+		// to identify iterators that are leaked rather than closed. Leaked
+		// iterators pin the memtables and sstables they read, preventing
+		// their memory and disk space from being reclaimed. Capturing a stack
+		// is expensive, and this option is intended for debugging.
+		TrackIteratorStacks bool
+
 		// MaxWriterConcurrency is used to indicate the maximum number of
 		// compression workers the compression queue is allowed to use. If
 		// MaxWriterConcurrency > 0, then the Writer will use parallelism, to
@@ -588,7 +1363,10 @@ type Options struct {
 	// targeted to contain around FlushSplitBytes bytes in each sublevel
 	// between pairs of boundary keys). Splitting sstables during flush
 	// allows increased compaction flexibility and concurrency when those
-	// tables are compacted to lower levels.
+	// tables are compacted to lower levels. Smaller values produce more L0
+	// files, each overlapping fewer Lbase files. The target grows with the
+	// number of L0 sublevels, and the effective value is reported in
+	// Metrics.Flush.SplitBytes.
 	FlushSplitBytes int64
 
 	// FormatMajorVersion sets the format of on-disk files. It is
@@ -612,6 +1390,13 @@ type Options struct {
 	// The default value uses the underlying operating system's file system.
 	FS vfs.FS
 
+	// FSRetryPolicy, if non-nil, configures the retrying of operations on FS
+	// that fail with transient errors, such as the EAGAIN and EINTR errors
+	// that networked filesystems may return during brief storage hiccups. See
+	// vfs.WithRetries. The number of retries is reported by
+	// Metrics.FSRetries.
+	FSRetryPolicy *vfs.RetryPolicy
+
 	// The count of L0 files necessary to trigger an L0 compaction.
 	L0CompactionFileThreshold int
 
@@ -622,6 +1407,17 @@ type Options struct {
 	// sublevels. Writes are stopped when this threshold is reached.
 	L0StopWritesThreshold int
 
+	// Soft limit on L0 read-amplification, computed as the number of L0
+	// sublevels. When this threshold is reached, each write is delayed in
+	// proportion to how close L0 read-amplification is to
+	// L0StopWritesThreshold, giving L0 compactions an opportunity to catch up
+	// before writes are stopped entirely. Throttling is reported through
+	// Metrics.WriteThrottle.
+	//
+	// The default value is 0, which disables throttling. If non-zero, the
+	// value must be less than L0StopWritesThreshold.
+	L0SublevelReadAmpThreshold int
+
 	// The maximum number of bytes for LBase. The base level is the level which
 	// L0 is compacted into. The base level is determined dynamically based on
 	// the existing data in the LSM. The maximum number of bytes for other levels
@@ -638,6 +1434,24 @@ type Options struct {
 	// The default logger uses the Go standard library log package.
 	Logger Logger
 
+	// MaxDiskUsageBytes caps the disk space used by the DB. Once the disk space
+	// used, as reported by Metrics.DiskSpaceUsage, would exceed the limit,
+	// writes are rejected with ErrDiskFull before they're applied, without
+	// entering an error state, so that the DB remains usable: batches that
+	// contain only deletions (point deletions, single deletions, range
+	// deletions and range key deletions) are still applied, and flushes and
+	// compactions still run, so that the space of deleted data may be
+	// reclaimed. Ingestions are rejected if the ingested sstables would
+	// exceed the limit. The usage relative to the limit is reported by
+	// Metrics.DiskUsage.
+	//
+	// The limit is enforced against the disk space used when a write begins,
+	// and flushes and compactions may temporarily use space beyond it, so the
+	// limit should leave headroom below the capacity of the volume.
+	//
+	// The default value of 0 disables the limit.
+	MaxDiskUsageBytes int64
+
 	// MaxManifestFileSize is the maximum size the MANIFEST file is allowed to
 	// become. When the MANIFEST exceeds this size it is rolled over and a new
 	// MANIFEST is created.
@@ -649,6 +1463,23 @@ type Options struct {
 	// The default value is 1000.
 	MaxOpenFiles int
 
+	// MaxWALSize bounds the volume of WAL that must be replayed when the DB is
+	// reopened after a crash. When the unflushed WAL, the WAL files backing
+	// the mutable memtable and the immutable memtables queued for flushing,
+	// reaches MaxWALSize, the next write rotates the memtable and the WAL and
+	// forces a flush, regardless of how full the memtable is. The unflushed
+	// WAL size is reported by Metrics.WAL.Size.
+	//
+	// The limit is checked before a batch is written, so the unflushed WAL
+	// may exceed the limit by the size of a single batch. A batch larger than
+	// the limit is written in its entirety, and is flushed immediately after.
+	// Additionally, no rotation is forced while a flush is already in progress
+	// or pending, since that flush will shrink the unflushed WAL.
+	//
+	// The default value of 0 disables the limit. MaxWALSize has no effect if
+	// DisableWAL is true.
+	MaxWALSize int64
+
 	// The size of a MemTable in steady state. The actual MemTable size starts at
 	// min(256KB, MemTableSize) and doubles for each subsequent MemTable up to
 	// MemTableSize. This reduces the memory pressure caused by MemTables for
@@ -701,6 +1532,70 @@ type Options struct {
 	// disabled.
 	ReadOnly bool
 
+	// SSTablePathFunc, if non-nil, names the sstables of the DB: it returns the
+	// name, within the DB directory, of the sstable with the given file number.
+	// By default, sstables are named by their file number, as in 000123.sst.
+	// The function is called once for each sstable, when a flush, compaction
+	// or ingestion creates it, and the name is recorded in the MANIFEST along
+	// with the sstable's file number. Every later access to the sstable,
+	// including reads, deletions, checkpoints and copies, uses the recorded
+	// name, so the function need not return the same name for a file number
+	// every time it's called, and may be changed or removed between Opens of
+	// the DB. A name may, for example, be derived from the sstable's creation
+	// time. Versions of Pebble that don't record names can't open a DB with
+	// named sstables.
+	//
+	// Names must be unique, must not contain a path separator, and must not
+	// have the form of the names of the DB's own files (e.g. 000123.log).
+	// Validate calls the function for a sample of file numbers and rejects it
+	// if it's observed to violate these requirements.
+	//
+	// If the function is set, the scan for obsolete files performed by Open
+	// also deletes every sstable in the DB directory whose name doesn't have
+	// the form of a DB file name and isn't recorded in the MANIFEST, such as an
+	// sstable left behind by a crash before it was added to the LSM, or an
+	// obsolete sstable whose deletion was interrupted by a crash. The DB
+	// directory must therefore not hold any sstables other than the DB's own.
+	SSTablePathFunc func(fileNum FileNum) string
+
+	// SSTableWriteBufferSize is the size of the buffer into which the sstable
+	// writers of flushes and compactions accumulate their output before
+	// writing it to the file. Each in-progress flush or compaction holds one
+	// such buffer, so the total is bounded by the buffer size times the
+	// number of concurrent compactions (see MaxConcurrentCompactions) plus
+	// one for a flush. Metrics.Compact.PeakWriteBufferBytes reports the peak
+	// observed.
+	//
+	// The write buffer is not the only memory held by an sstable writer: each
+	// writer also accumulates the data block being built, of roughly the
+	// level's LevelOptions.BlockSize, along with the index and filter blocks,
+	// and compresses each finished block into a separate buffer of similar
+	// size. When the writer compresses blocks in parallel (see
+	// Experimental.MaxWriterConcurrency), several blocks and their
+	// compressed forms may be buffered at once. A block larger than the write
+	// buffer bypasses it and is written to the file directly, so there's
+	// little benefit in a write buffer much larger than the block size,
+	// beyond reducing the number of writes issued to the filesystem.
+	//
+	// The default value is 4KB.
+	SSTableWriteBufferSize int
+
+	// StrictManifestValidation, if true, makes Open validate the LSM described
+	// by the MANIFEST before using it. Open always checks that the sstables
+	// referenced by the MANIFEST exist and have the recorded sizes. Strict
+	// validation additionally checks that the recorded bounds of each sstable
+	// are consistent with one another, that the files of each level are
+	// ordered and don't overlap as required, that the recorded file numbers
+	// and sequence numbers are lower than the next ones to be assigned, and
+	// that the footer and properties of each sstable can be read. Open fails
+	// with an error identifying the first inconsistency found, rather than
+	// the DB failing later during reads or compactions.
+	//
+	// Strict validation reads every sstable in the DB, which slows down Open
+	// for large DBs. It's recommended when opening a DB that may have been
+	// damaged, e.g. after a disk failure.
+	StrictManifestValidation bool
+
 	// TableCache is an initialized TableCache which should be set as an
 	// option if the DB needs to be initialized with a pre-existing table cache.
 	// If TableCache is nil, then a table cache which is unique to the DB instance
@@ -730,11 +1625,36 @@ type Options struct {
 	// default behaviour in RocksDB.
 	WALBytesPerSync int
 
+	// WALReplayConcurrency is the number of goroutines that apply the batches
+	// replayed from the WAL to memtables during Open. Replay remains
+	// sequential in every other respect: the WAL is read, and its batches
+	// decoded, checked and assigned to memtables, in log order, and each
+	// memtable is flushed only once all the batches assigned to it have been
+	// applied. Only the insertion of the batches' entries into the memtables
+	// proceeds in parallel, as it does for concurrent commits.
+	//
+	// Every entry carries the sequence number of its batch, which orders the
+	// writes to a key independently of the order in which they're inserted,
+	// so the result of the replay is identical to that of a sequential
+	// replay: the writes to the same key are ordered as they were committed.
+	// A larger concurrency speeds up the replay of large WALs when inserting
+	// into the memtables, rather than reading the WAL, is the bottleneck, at
+	// the cost of a copy of each batch.
+	//
+	// The default value is 1, i.e. batches are applied by the goroutine
+	// replaying the WAL.
+	WALReplayConcurrency int
+
 	// WALDir specifies the directory to store write-ahead logs (WALs) in. If
 	// empty (the default), WALs will be stored in the same directory as sstables
 	// (i.e. the directory passed to pebble.Open).
 	WALDir string
 
+	// WALFailover, if non-nil, configures the failover of the WAL to a
+	// secondary directory while the WAL directory is unhealthy. See
+	// WALFailoverOptions.
+	WALFailover *WALFailoverOptions
+
 	// WALMinSyncInterval is the minimum duration between syncs of the WAL. If
 	// WAL syncs are requested faster than this interval, they will be
 	// artificially delayed. Introducing a small artificial delay (500us) between
@@ -791,12 +1711,24 @@ func (o *Options) EnsureDefaults() *Options {
 	if o.BytesPerSync <= 0 {
 		o.BytesPerSync = 512 << 10 // 512 KB
 	}
+	if o.WALReplayConcurrency <= 0 {
+		o.WALReplayConcurrency = 1
+	}
+	if o.SSTableWriteBufferSize <= 0 {
+		o.SSTableWriteBufferSize = 4 << 10 // 4 KB
+	}
 	if o.Cleaner == nil {
 		o.Cleaner = DeleteCleaner{}
 	}
 	if o.Comparer == nil {
 		o.Comparer = DefaultComparer
 	}
+	// EnsureDefaults is called again on the options of an open DB, for
+	// example when creating a memtable, so only write the field if it
+	// changes.
+	if c := base.NormalizedComparer(o.Comparer); c != o.Comparer {
+		o.Comparer = c
+	}
 	if o.Experimental.L0CompactionConcurrency <= 0 {
 		o.Experimental.L0CompactionConcurrency = 10
 	}
@@ -864,12 +1796,30 @@ func (o *Options) EnsureDefaults() *Options {
 	if o.MaxManifestFileSize == 0 {
 		o.MaxManifestFileSize = 128 << 20 // 128 MB
 	}
+	if o.MemoryBudget > 0 {
+		o.applyMemoryBudget()
+	}
 	if o.MaxOpenFiles == 0 {
 		o.MaxOpenFiles = 1000
 	}
 	if o.MemTableSize <= 0 {
 		o.MemTableSize = 4 << 20
 	}
+	if o.Experimental.AdaptiveMemTableSize.Enabled {
+		a := &o.Experimental.AdaptiveMemTableSize
+		if a.MaxSize <= 0 {
+			a.MaxSize = o.MemTableSize
+		}
+		if a.MinSize <= 0 {
+			a.MinSize = 256 << 10 // 256 KB
+			if a.MinSize > a.MaxSize {
+				a.MinSize = a.MaxSize
+			}
+		}
+		if a.TargetFlushInterval <= 0 {
+			a.TargetFlushInterval = 10 * time.Second
+		}
+	}
 	if o.MemTableStopWritesThreshold <= 0 {
 		o.MemTableStopWritesThreshold = 2
 	}
@@ -897,6 +1847,24 @@ func (o *Options) EnsureDefaults() *Options {
 				})
 			})
 	}
+	if o.WALFailover != nil {
+		// Copy the failover options so that setting their defaults doesn't
+		// modify the caller's options.
+		f := *o.WALFailover
+		if f.SecondaryFS == nil {
+			f.SecondaryFS = o.FS
+		}
+		if f.UnhealthySyncLatencyThreshold <= 0 {
+			f.UnhealthySyncLatencyThreshold = 100 * time.Millisecond
+		}
+		if f.UnhealthyErrorThreshold <= 0 {
+			f.UnhealthyErrorThreshold = 1
+		}
+		if f.FailbackInterval <= 0 {
+			f.FailbackInterval = time.Minute
+		}
+		o.WALFailover = &f
+	}
 	if o.FlushSplitBytes <= 0 {
 		o.FlushSplitBytes = 2 * o.Levels[0].TargetFileSize
 	}
@@ -966,10 +1934,47 @@ func filterPolicyName(p FilterPolicy) string {
 	return p.Name()
 }
 
+// applyMemoryBudget apportions MemoryBudget to the options it governs that
+// aren't set. See the MemoryBudget documentation for the policy.
+func (o *Options) applyMemoryBudget() {
+	if o.MemTableSize <= 0 {
+		threshold := o.MemTableStopWritesThreshold
+		if threshold <= 0 {
+			threshold = 2
+		}
+		size := o.MemoryBudget / 8 / int64(threshold)
+		if size >= maxMemTableSize {
+			size = maxMemTableSize - 1
+		}
+		if size > 0 {
+			o.MemTableSize = int(size)
+		}
+	}
+	if o.MaxOpenFiles == 0 {
+		o.MaxOpenFiles = 1000
+		if n := o.MemoryBudget / 16 / estimatedOpenTableMemory; n > int64(o.MaxOpenFiles) {
+			o.MaxOpenFiles = int(n)
+		}
+	}
+}
+
+// defaultCacheSize returns the size of the block cache created by Open if
+// Cache is nil.
+func (o *Options) defaultCacheSize() int64 {
+	if o.MemoryBudget <= 0 {
+		return cacheDefaultSize
+	}
+	size := o.MemoryBudget - int64(TableCacheSize(o.MaxOpenFiles))*estimatedOpenTableMemory
+	if size < o.MemoryBudget/4 {
+		size = o.MemoryBudget / 4
+	}
+	return size
+}
+
 func (o *Options) String() string {
 	var buf bytes.Buffer
 
-	cacheSize := int64(cacheDefaultSize)
+	cacheSize := o.defaultCacheSize()
 	if o.Cache != nil {
 		cacheSize = o.Cache.MaxSize()
 	}
@@ -982,6 +1987,7 @@ func (o *Options) String() string {
 	fmt.Fprintf(&buf, "  cache_size=%d\n", cacheSize)
 	fmt.Fprintf(&buf, "  cleaner=%s\n", o.Cleaner)
 	fmt.Fprintf(&buf, "  compaction_debt_concurrency=%d\n", o.Experimental.CompactionDebtConcurrency)
+	fmt.Fprintf(&buf, "  compaction_readahead_size=%d\n", o.CompactionReadaheadSize)
 	fmt.Fprintf(&buf, "  comparer=%s\n", o.Comparer.Name)
 	fmt.Fprintf(&buf, "  delete_range_flush_delay=%s\n", o.Experimental.DeleteRangeFlushDelay)
 	fmt.Fprintf(&buf, "  disable_wal=%t\n", o.DisableWAL)
@@ -991,16 +1997,23 @@ func (o *Options) String() string {
 	fmt.Fprintf(&buf, "  l0_compaction_file_threshold=%d\n", o.L0CompactionFileThreshold)
 	fmt.Fprintf(&buf, "  l0_compaction_threshold=%d\n", o.L0CompactionThreshold)
 	fmt.Fprintf(&buf, "  l0_stop_writes_threshold=%d\n", o.L0StopWritesThreshold)
+	fmt.Fprintf(&buf, "  l0_sublevel_read_amp_threshold=%d\n", o.L0SublevelReadAmpThreshold)
 	fmt.Fprintf(&buf, "  lbase_max_bytes=%d\n", o.LBaseMaxBytes)
 	fmt.Fprintf(&buf, "  max_concurrent_compactions=%d\n", o.MaxConcurrentCompactions())
+	fmt.Fprintf(&buf, "  max_disk_usage_bytes=%d\n", o.MaxDiskUsageBytes)
 	fmt.Fprintf(&buf, "  max_manifest_file_size=%d\n", o.MaxManifestFileSize)
 	fmt.Fprintf(&buf, "  max_open_files=%d\n", o.MaxOpenFiles)
+	fmt.Fprintf(&buf, "  max_wal_size=%d\n", o.MaxWALSize)
 	fmt.Fprintf(&buf, "  mem_table_size=%d\n", o.MemTableSize)
 	fmt.Fprintf(&buf, "  mem_table_stop_writes_threshold=%d\n", o.MemTableStopWritesThreshold)
+	fmt.Fprintf(&buf, "  memory_budget=%d\n", o.MemoryBudget)
 	fmt.Fprintf(&buf, "  min_deletion_rate=%d\n", o.Experimental.MinDeletionRate)
 	fmt.Fprintf(&buf, "  merger=%s\n", o.Merger.Name)
 	fmt.Fprintf(&buf, "  read_compaction_rate=%d\n", o.Experimental.ReadCompactionRate)
 	fmt.Fprintf(&buf, "  read_sampling_multiplier=%d\n", o.Experimental.ReadSamplingMultiplier)
+	fmt.Fprintf(&buf, "  sstable_write_buffer_size=%d\n", o.SSTableWriteBufferSize)
+	fmt.Fprintf(&buf, "  strict_ingest_block_properties=%t\n", o.Experimental.StrictIngestBlockProperties)
+	fmt.Fprintf(&buf, "  strict_manifest_validation=%t\n", o.StrictManifestValidation)
 	fmt.Fprintf(&buf, "  strict_wal_tail=%t\n", o.private.strictWALTail)
 	fmt.Fprintf(&buf, "  table_cache_shards=%d\n", o.Experimental.TableCacheShards)
 	fmt.Fprintf(&buf, "  table_property_collectors=[")
@@ -1014,10 +2027,28 @@ func (o *Options) String() string {
 	}
 	fmt.Fprintf(&buf, "]\n")
 	fmt.Fprintf(&buf, "  validate_on_ingest=%t\n", o.Experimental.ValidateOnIngest)
+	fmt.Fprintf(&buf, "  validate_outputs=%t\n", o.Experimental.ValidateOutputs)
 	fmt.Fprintf(&buf, "  wal_dir=%s\n", o.WALDir)
 	fmt.Fprintf(&buf, "  wal_bytes_per_sync=%d\n", o.WALBytesPerSync)
+	fmt.Fprintf(&buf, "  wal_replay_concurrency=%d\n", o.WALReplayConcurrency)
 	fmt.Fprintf(&buf, "  max_writer_concurrency=%d\n", o.Experimental.MaxWriterConcurrency)
 	fmt.Fprintf(&buf, "  force_writer_parallelism=%t\n", o.Experimental.ForceWriterParallelism)
+	fmt.Fprintf(&buf, "  adaptive_mem_table_size_enabled=%t\n", o.Experimental.AdaptiveMemTableSize.Enabled)
+	fmt.Fprintf(&buf, "  adaptive_mem_table_size_min=%d\n", o.Experimental.AdaptiveMemTableSize.MinSize)
+	fmt.Fprintf(&buf, "  adaptive_mem_table_size_max=%d\n", o.Experimental.AdaptiveMemTableSize.MaxSize)
+	fmt.Fprintf(&buf, "  adaptive_mem_table_size_target_flush_interval=%s\n",
+		o.Experimental.AdaptiveMemTableSize.TargetFlushInterval)
+	fmt.Fprintf(&buf, "  on_seq_num_mismatch=%s\n", o.Experimental.OnSeqNumMismatch)
+	fmt.Fprintf(&buf, "  max_versions_per_key=%d\n", o.Experimental.MaxVersionsPerKey)
+	fmt.Fprintf(&buf, "  on_single_delete_range_del=%s\n", o.Experimental.OnSingleDeleteRangeDel)
+	fmt.Fprintf(&buf, "  space_reclamation_priority=%g\n", o.Experimental.SpaceReclamationPriority)
+	fmt.Fprintf(&buf, "  target_write_amp=%g\n", o.Experimental.TargetWriteAmp)
+	fmt.Fprintf(&buf, "  max_inline_value_size=%d\n", o.Experimental.MaxInlineValueSize)
+	fmt.Fprintf(&buf, "  dir_sync_policy=%s\n", o.Experimental.DirSyncPolicy)
+	fmt.Fprintf(&buf, "  compaction_io_priority_class=%s\n", o.Experimental.CompactionIOPriority.Class)
+	fmt.Fprintf(&buf, "  compaction_io_priority_level=%d\n", o.Experimental.CompactionIOPriority.Level)
+	fmt.Fprintf(&buf, "  max_grandparent_overlap_bytes=%d\n", o.Experimental.MaxGrandparentOverlapBytes)
+	fmt.Fprintf(&buf, "  max_compaction_overlap_ratio=%g\n", o.Experimental.MaxCompactionOverlapRatio)
 
 	for i := range o.Levels {
 		l := &o.Levels[i]
@@ -1025,10 +2056,14 @@ func (o *Options) String() string {
 		fmt.Fprintf(&buf, "[Level \"%d\"]\n", i)
 		fmt.Fprintf(&buf, "  block_restart_interval=%d\n", l.BlockRestartInterval)
 		fmt.Fprintf(&buf, "  block_size=%d\n", l.BlockSize)
+		fmt.Fprintf(&buf, "  block_alignment=%d\n", l.BlockAlignment)
 		fmt.Fprintf(&buf, "  compression=%s\n", l.Compression)
 		fmt.Fprintf(&buf, "  filter_policy=%s\n", filterPolicyName(l.FilterPolicy))
 		fmt.Fprintf(&buf, "  filter_type=%s\n", l.FilterType)
 		fmt.Fprintf(&buf, "  index_block_size=%d\n", l.IndexBlockSize)
+		fmt.Fprintf(&buf, "  max_compaction_concurrency=%d\n", l.MaxCompactionConcurrency)
+		fmt.Fprintf(&buf, "  max_keys_per_file=%d\n", l.MaxKeysPerFile)
+		fmt.Fprintf(&buf, "  range_key_fragment_policy=%s\n", l.RangeKeyFragmentPolicy)
 		fmt.Fprintf(&buf, "  target_file_size=%d\n", l.TargetFileSize)
 	}
 
@@ -1155,6 +2190,8 @@ func (o *Options) Parse(s string, hooks *ParseHooks) error {
 				}
 			case "compaction_debt_concurrency":
 				o.Experimental.CompactionDebtConcurrency, err = strconv.Atoi(value)
+			case "compaction_readahead_size":
+				o.CompactionReadaheadSize, err = strconv.Atoi(value)
 			case "delete_range_flush_delay":
 				o.Experimental.DeleteRangeFlushDelay, err = time.ParseDuration(value)
 			case "disable_wal":
@@ -1182,6 +2219,8 @@ func (o *Options) Parse(s string, hooks *ParseHooks) error {
 				o.L0CompactionThreshold, err = strconv.Atoi(value)
 			case "l0_stop_writes_threshold":
 				o.L0StopWritesThreshold, err = strconv.Atoi(value)
+			case "l0_sublevel_read_amp_threshold":
+				o.L0SublevelReadAmpThreshold, err = strconv.Atoi(value)
 			case "l0_sublevel_compactions":
 				// Do nothing; option existed in older versions of pebble.
 			case "lbase_max_bytes":
@@ -1194,14 +2233,20 @@ func (o *Options) Parse(s string, hooks *ParseHooks) error {
 				} else {
 					o.MaxConcurrentCompactions = func() int { return concurrentCompactions }
 				}
+			case "max_disk_usage_bytes":
+				o.MaxDiskUsageBytes, err = strconv.ParseInt(value, 10, 64)
 			case "max_manifest_file_size":
 				o.MaxManifestFileSize, err = strconv.ParseInt(value, 10, 64)
 			case "max_open_files":
 				o.MaxOpenFiles, err = strconv.Atoi(value)
+			case "max_wal_size":
+				o.MaxWALSize, err = strconv.ParseInt(value, 10, 64)
 			case "mem_table_size":
 				o.MemTableSize, err = strconv.Atoi(value)
 			case "mem_table_stop_writes_threshold":
 				o.MemTableStopWritesThreshold, err = strconv.Atoi(value)
+			case "memory_budget":
+				o.MemoryBudget, err = strconv.ParseInt(value, 10, 64)
 			case "min_compaction_rate":
 				// Do nothing; option existed in older versions of pebble, and
 				// may be meaningful again eventually.
@@ -1210,6 +2255,10 @@ func (o *Options) Parse(s string, hooks *ParseHooks) error {
 			case "min_flush_rate":
 				// Do nothing; option existed in older versions of pebble, and
 				// may be meaningful again eventually.
+			case "sstable_write_buffer_size":
+				o.SSTableWriteBufferSize, err = strconv.Atoi(value)
+			case "strict_manifest_validation":
+				o.StrictManifestValidation, err = strconv.ParseBool(value)
 			case "strict_wal_tail":
 				o.private.strictWALTail, err = strconv.ParseBool(value)
 			case "merger":
@@ -1236,18 +2285,90 @@ func (o *Options) Parse(s string, hooks *ParseHooks) error {
 				default:
 					return errors.Errorf("pebble: unknown table format: %q", errors.Safe(value))
 				}
+			case "strict_ingest_block_properties":
+				o.Experimental.StrictIngestBlockProperties, err = strconv.ParseBool(value)
 			case "table_property_collectors":
 				// TODO(peter): set o.TablePropertyCollectors
 			case "validate_on_ingest":
 				o.Experimental.ValidateOnIngest, err = strconv.ParseBool(value)
+			case "validate_outputs":
+				o.Experimental.ValidateOutputs, err = strconv.ParseBool(value)
 			case "wal_dir":
 				o.WALDir = value
 			case "wal_bytes_per_sync":
 				o.WALBytesPerSync, err = strconv.Atoi(value)
+			case "wal_replay_concurrency":
+				o.WALReplayConcurrency, err = strconv.Atoi(value)
 			case "max_writer_concurrency":
 				o.Experimental.MaxWriterConcurrency, err = strconv.Atoi(value)
 			case "force_writer_parallelism":
 				o.Experimental.ForceWriterParallelism, err = strconv.ParseBool(value)
+			case "adaptive_mem_table_size_enabled":
+				o.Experimental.AdaptiveMemTableSize.Enabled, err = strconv.ParseBool(value)
+			case "adaptive_mem_table_size_min":
+				o.Experimental.AdaptiveMemTableSize.MinSize, err = strconv.Atoi(value)
+			case "adaptive_mem_table_size_max":
+				o.Experimental.AdaptiveMemTableSize.MaxSize, err = strconv.Atoi(value)
+			case "adaptive_mem_table_size_target_flush_interval":
+				o.Experimental.AdaptiveMemTableSize.TargetFlushInterval, err = time.ParseDuration(value)
+			case "on_seq_num_mismatch":
+				switch value {
+				case "accept":
+					o.Experimental.OnSeqNumMismatch = SeqNumMismatchAccept
+				case "truncate":
+					o.Experimental.OnSeqNumMismatch = SeqNumMismatchTruncate
+				case "fail":
+					o.Experimental.OnSeqNumMismatch = SeqNumMismatchFail
+				default:
+					return errors.Errorf("pebble: unknown sequence number mismatch action: %q", errors.Safe(value))
+				}
+			case "max_versions_per_key":
+				o.Experimental.MaxVersionsPerKey, err = strconv.Atoi(value)
+			case "on_single_delete_range_del":
+				switch value {
+				case "consume":
+					o.Experimental.OnSingleDeleteRangeDel = SingleDeleteRangeDelConsume
+				case "to-delete":
+					o.Experimental.OnSingleDeleteRangeDel = SingleDeleteRangeDelToDelete
+				case "fail":
+					o.Experimental.OnSingleDeleteRangeDel = SingleDeleteRangeDelFail
+				default:
+					return errors.Errorf("pebble: unknown single delete range deletion action: %q", errors.Safe(value))
+				}
+			case "space_reclamation_priority":
+				o.Experimental.SpaceReclamationPriority, err = strconv.ParseFloat(value, 64)
+			case "target_write_amp":
+				o.Experimental.TargetWriteAmp, err = strconv.ParseFloat(value, 64)
+			case "max_inline_value_size":
+				o.Experimental.MaxInlineValueSize, err = strconv.Atoi(value)
+			case "dir_sync_policy":
+				switch value {
+				case "per-operation":
+					o.Experimental.DirSyncPolicy = DirSyncPerOperation
+				case "batched":
+					o.Experimental.DirSyncPolicy = DirSyncBatched
+				case "deferred":
+					o.Experimental.DirSyncPolicy = DirSyncDeferred
+				default:
+					return errors.Errorf("pebble: unknown directory sync policy: %q", errors.Safe(value))
+				}
+			case "compaction_io_priority_class":
+				switch value {
+				case "default":
+					o.Experimental.CompactionIOPriority.Class = IOPriorityDefault
+				case "best-effort":
+					o.Experimental.CompactionIOPriority.Class = IOPriorityBestEffort
+				case "idle":
+					o.Experimental.CompactionIOPriority.Class = IOPriorityIdle
+				default:
+					return errors.Errorf("pebble: unknown I/O priority class: %q", errors.Safe(value))
+				}
+			case "compaction_io_priority_level":
+				o.Experimental.CompactionIOPriority.Level, err = strconv.Atoi(value)
+			case "max_grandparent_overlap_bytes":
+				o.Experimental.MaxGrandparentOverlapBytes, err = strconv.ParseInt(value, 10, 64)
+			case "max_compaction_overlap_ratio":
+				o.Experimental.MaxCompactionOverlapRatio, err = strconv.ParseFloat(value, 64)
 			default:
 				if hooks != nil && hooks.SkipUnknown != nil && hooks.SkipUnknown(section+"."+key, value) {
 					return nil
@@ -1281,6 +2402,8 @@ func (o *Options) Parse(s string, hooks *ParseHooks) error {
 				l.BlockRestartInterval, err = strconv.Atoi(value)
 			case "block_size":
 				l.BlockSize, err = strconv.Atoi(value)
+			case "block_alignment":
+				l.BlockAlignment, err = strconv.Atoi(value)
 			case "compression":
 				switch value {
 				case "Default":
@@ -1307,6 +2430,19 @@ func (o *Options) Parse(s string, hooks *ParseHooks) error {
 				}
 			case "index_block_size":
 				l.IndexBlockSize, err = strconv.Atoi(value)
+			case "max_compaction_concurrency":
+				l.MaxCompactionConcurrency, err = strconv.Atoi(value)
+			case "max_keys_per_file":
+				l.MaxKeysPerFile, err = strconv.Atoi(value)
+			case "range_key_fragment_policy":
+				switch value {
+				case "at-boundaries":
+					l.RangeKeyFragmentPolicy = RangeKeyFragmentAtBoundaries
+				case "avoid-splits":
+					l.RangeKeyFragmentPolicy = RangeKeyFragmentAvoidSplits
+				default:
+					return errors.Errorf("pebble: unknown range key fragment policy: %q", errors.Safe(value))
+				}
 			case "target_file_size":
 				l.TargetFileSize, err = strconv.ParseInt(value, 10, 64)
 			default:
@@ -1374,6 +2510,11 @@ func (o *Options) Validate() error {
 		fmt.Fprintf(&buf, "L0StopWritesThreshold (%d) must be >= L0CompactionThreshold (%d)\n",
 			o.L0StopWritesThreshold, o.L0CompactionThreshold)
 	}
+	if o.L0SublevelReadAmpThreshold < 0 || (o.L0SublevelReadAmpThreshold > 0 &&
+		o.L0SublevelReadAmpThreshold >= o.L0StopWritesThreshold) {
+		fmt.Fprintf(&buf, "L0SublevelReadAmpThreshold (%d) must be >= 0 and < L0StopWritesThreshold (%d)\n",
+			o.L0SublevelReadAmpThreshold, o.L0StopWritesThreshold)
+	}
 	if uint64(o.MemTableSize) >= maxMemTableSize {
 		fmt.Fprintf(&buf, "MemTableSize (%s) must be < %s\n",
 			humanize.Uint64(uint64(o.MemTableSize)), humanize.Uint64(maxMemTableSize))
@@ -1382,6 +2523,58 @@ func (o *Options) Validate() error {
 		fmt.Fprintf(&buf, "MemTableStopWritesThreshold (%d) must be >= 2\n",
 			o.MemTableStopWritesThreshold)
 	}
+	if a := o.Experimental.AdaptiveMemTableSize; a.Enabled && (a.MinSize > a.MaxSize || a.MaxSize > o.MemTableSize) {
+		fmt.Fprintf(&buf, "AdaptiveMemTableSize.MinSize (%s) must be <= MaxSize (%s), which must be <= MemTableSize (%s)\n",
+			humanize.Uint64(uint64(a.MinSize)), humanize.Uint64(uint64(a.MaxSize)),
+			humanize.Uint64(uint64(o.MemTableSize)))
+	}
+	if a := o.Experimental.OnSeqNumMismatch; a < SeqNumMismatchAccept || a > SeqNumMismatchFail {
+		fmt.Fprintf(&buf, "OnSeqNumMismatch (%d) is not a valid SeqNumMismatchAction\n", a)
+	}
+	if p := o.Experimental.DirSyncPolicy; p < DirSyncPerOperation || p > DirSyncDeferred {
+		fmt.Fprintf(&buf, "DirSyncPolicy (%d) is not a valid DirSyncPolicy\n", p)
+	}
+	if p := o.Experimental.CompactionIOPriority; p.Class < IOPriorityDefault || p.Class > IOPriorityIdle {
+		fmt.Fprintf(&buf, "CompactionIOPriority.Class (%d) is not a valid IOPriorityClass\n", p.Class)
+	} else if p.Class == IOPriorityBestEffort && (p.Level < 0 || p.Level > 7) {
+		fmt.Fprintf(&buf, "CompactionIOPriority.Level (%d) must be in [0, 7]\n", p.Level)
+	}
+	if a := o.Experimental.OnSingleDeleteRangeDel; a < SingleDeleteRangeDelConsume || a > SingleDeleteRangeDelFail {
+		fmt.Fprintf(&buf, "OnSingleDeleteRangeDel (%d) is not a valid SingleDeleteRangeDelAction\n", a)
+	}
+	if o.MaxWALSize < 0 {
+		fmt.Fprintf(&buf, "MaxWALSize (%d) must be >= 0\n", o.MaxWALSize)
+	}
+	if o.MemoryBudget < 0 {
+		fmt.Fprintf(&buf, "MemoryBudget (%d) must be >= 0\n", o.MemoryBudget)
+	}
+	if o.Experimental.TargetWriteAmp < 0 {
+		fmt.Fprintf(&buf, "TargetWriteAmp (%g) must be >= 0\n",
+			o.Experimental.TargetWriteAmp)
+	}
+	if o.Experimental.MaxInlineValueSize < 0 {
+		fmt.Fprintf(&buf, "MaxInlineValueSize (%d) must be >= 0\n",
+			o.Experimental.MaxInlineValueSize)
+	}
+	if o.Experimental.MaxGrandparentOverlapBytes < 0 {
+		fmt.Fprintf(&buf, "MaxGrandparentOverlapBytes (%d) must be >= 0\n",
+			o.Experimental.MaxGrandparentOverlapBytes)
+	}
+	if r := o.Experimental.MaxCompactionOverlapRatio; r < 0 || math.IsNaN(r) || math.IsInf(r, 0) {
+		fmt.Fprintf(&buf, "MaxCompactionOverlapRatio (%g) must be finite and >= 0\n", r)
+	}
+	if o.Experimental.SpaceReclamationPriority < 0 {
+		fmt.Fprintf(&buf, "SpaceReclamationPriority (%g) must be >= 0\n",
+			o.Experimental.SpaceReclamationPriority)
+	}
+	if f := o.WALFailover; f != nil && f.SecondaryDir == "" {
+		fmt.Fprintf(&buf, "WALFailover.SecondaryDir must be set\n")
+	}
+	if o.SSTablePathFunc != nil {
+		if err := validateTablePathFunc(o.FS, o.SSTablePathFunc); err != nil {
+			fmt.Fprintf(&buf, "SSTablePathFunc: %s\n", err)
+		}
+	}
 	if o.FormatMajorVersion > FormatNewest {
 		fmt.Fprintf(&buf, "FormatMajorVersion (%d) must be <= %d\n",
 			o.FormatMajorVersion, FormatNewest)
@@ -1403,6 +2596,7 @@ func (o *Options) MakeReaderOptions() sstable.ReaderOptions {
 		readerOpts.Cache = o.Cache
 		readerOpts.Comparer = o.Comparer
 		readerOpts.Filters = o.Filters
+		readerOpts.CompactionReadaheadSize = o.CompactionReadaheadSize
 		if o.Merger != nil {
 			readerOpts.MergerName = o.Merger.Name
 		}
@@ -1423,14 +2617,21 @@ func (o *Options) MakeWriterOptions(level int, format sstable.TableFormat) sstab
 		}
 		writerOpts.TablePropertyCollectors = o.TablePropertyCollectors
 		writerOpts.BlockPropertyCollectors = o.BlockPropertyCollectors
+		writerOpts.WriteBufferSize = o.SSTableWriteBufferSize
+		if format >= sstable.TableFormatPebblev4 {
+			writerOpts.MaxInlineValueSize = o.Experimental.MaxInlineValueSize
+		}
 	}
 	levelOpts := o.Level(level)
 	writerOpts.BlockRestartInterval = levelOpts.BlockRestartInterval
 	writerOpts.BlockSize = levelOpts.BlockSize
 	writerOpts.BlockSizeThreshold = levelOpts.BlockSizeThreshold
+	writerOpts.BlockAlignment = levelOpts.BlockAlignment
 	writerOpts.Compression = levelOpts.Compression
 	writerOpts.FilterPolicy = levelOpts.FilterPolicy
 	writerOpts.FilterType = levelOpts.FilterType
+	writerOpts.RangeKeyFragmentPolicy = levelOpts.RangeKeyFragmentPolicy
 	writerOpts.IndexBlockSize = levelOpts.IndexBlockSize
+	writerOpts.MaxKeysPerFile = levelOpts.MaxKeysPerFile
 	return writerOpts
 }
@@ -28,6 +28,9 @@ func (o *Options) testingRandomized() *Options {
 		// [MostCompatible, FormatNewest].
 		o.FormatMajorVersion = FormatMajorVersion(rand.Intn(int(FormatNewest)) + 1)
 	}
+	if o.WALReplayConcurrency == 0 {
+		o.WALReplayConcurrency = rand.Intn(4) + 1
+	}
 	return o
 }
 
@@ -73,6 +76,7 @@ func TestOptionsString(t *testing.T) {
   cache_size=8388608
   cleaner=delete
   compaction_debt_concurrency=1073741824
+  compaction_readahead_size=0
   comparer=leveldb.BytewiseComparator
   delete_range_flush_delay=0s
   disable_wal=false
@@ -82,32 +86,60 @@ func TestOptionsString(t *testing.T) {
   l0_compaction_file_threshold=500
   l0_compaction_threshold=4
   l0_stop_writes_threshold=12
+  l0_sublevel_read_amp_threshold=0
   lbase_max_bytes=67108864
   max_concurrent_compactions=1
+  max_disk_usage_bytes=0
   max_manifest_file_size=134217728
   max_open_files=1000
+  max_wal_size=0
   mem_table_size=4194304
   mem_table_stop_writes_threshold=2
+  memory_budget=0
   min_deletion_rate=0
   merger=pebble.concatenate
   read_compaction_rate=16000
   read_sampling_multiplier=16
+  sstable_write_buffer_size=4096
+  strict_ingest_block_properties=false
+  strict_manifest_validation=false
   strict_wal_tail=true
   table_cache_shards=8
   table_property_collectors=[]
   validate_on_ingest=false
+  validate_outputs=false
   wal_dir=
   wal_bytes_per_sync=0
+  wal_replay_concurrency=1
   max_writer_concurrency=0
   force_writer_parallelism=false
+  adaptive_mem_table_size_enabled=false
+  adaptive_mem_table_size_min=0
+  adaptive_mem_table_size_max=0
+  adaptive_mem_table_size_target_flush_interval=0s
+  on_seq_num_mismatch=accept
+  max_versions_per_key=0
+  on_single_delete_range_del=consume
+  space_reclamation_priority=0
+  target_write_amp=0
+  max_inline_value_size=0
+  dir_sync_policy=per-operation
+  compaction_io_priority_class=default
+  compaction_io_priority_level=0
+  max_grandparent_overlap_bytes=0
+  max_compaction_overlap_ratio=0
 
 [Level "0"]
   block_restart_interval=16
   block_size=4096
+  block_alignment=0
   compression=Snappy
   filter_policy=none
   filter_type=table
   index_block_size=4096
+  max_compaction_concurrency=0
+  max_keys_per_file=0
+  range_key_fragment_policy=at-boundaries
   target_file_size=2097152
 `
 
@@ -230,6 +262,23 @@ func TestOptionsParse(t *testing.T) {
 			opts.Experimental.TableCacheShards = 500
 			opts.Experimental.MaxWriterConcurrency = 1
 			opts.Experimental.ForceWriterParallelism = true
+			opts.Experimental.AdaptiveMemTableSize = AdaptiveMemTableSizeOptions{
+				Enabled:             true,
+				MinSize:             1 << 20,
+				MaxSize:             2 << 20,
+				TargetFlushInterval: 5 * time.Second,
+			}
+			opts.Experimental.OnSeqNumMismatch = SeqNumMismatchTruncate
+			opts.Experimental.MaxVersionsPerKey = 3
+			opts.Experimental.OnSingleDeleteRangeDel = SingleDeleteRangeDelToDelete
+			opts.StrictManifestValidation = true
+			opts.Experimental.SpaceReclamationPriority = 1.5
+			opts.Experimental.TargetWriteAmp = 12.5
+			opts.Experimental.MaxInlineValueSize = 2 << 10
+			opts.Experimental.DirSyncPolicy = DirSyncBatched
+			opts.Experimental.CompactionIOPriority = IOPriority{Class: IOPriorityBestEffort, Level: 6}
+			opts.Experimental.MaxGrandparentOverlapBytes = 64 << 20
+			opts.Experimental.MaxCompactionOverlapRatio = 2.5
 			opts.EnsureDefaults()
 			str := opts.String()
 
@@ -266,6 +315,13 @@ func TestOptionsValidate(t *testing.T) {
 			`L0StopWritesThreshold .* must be >= L0CompactionThreshold .*`,
 		},
 		{`
+[Options]
+  l0_sublevel_read_amp_threshold=12
+  l0_stop_writes_threshold=12
+`,
+			`L0SublevelReadAmpThreshold \(12\) must be >= 0 and < L0StopWritesThreshold \(12\)`,
+		},
+		{`
 [Options]
   mem_table_size=4294967296
 `,
@@ -277,6 +333,24 @@ func TestOptionsValidate(t *testing.T) {
 `,
 			`MemTableStopWritesThreshold .* must be >= 2`,
 		},
+		{`
+[Options]
+  max_compaction_overlap_ratio=-1
+`,
+			`MaxCompactionOverlapRatio \(-1\) must be finite and >= 0`,
+		},
+		{`
+[Options]
+  max_compaction_overlap_ratio=NaN
+`,
+			`MaxCompactionOverlapRatio \(NaN\) must be finite and >= 0`,
+		},
+		{`
+[Options]
+  max_compaction_overlap_ratio=+Inf
+`,
+			`MaxCompactionOverlapRatio \(\+Inf\) must be finite and >= 0`,
+		},
 	}
 
 	for _, c := range testCases {
@@ -312,3 +386,46 @@ func TestOptionsValidateCache(t *testing.T) {
 		t.Errorf("Unexpected error message")
 	}
 }
+
+func TestOptionsMemoryBudget(t *testing.T) {
+	opts := (&Options{MemoryBudget: 1 << 30}).EnsureDefaults()
+	require.Equal(t, 64<<20, opts.MemTableSize)
+	require.Equal(t, 4096, opts.MaxOpenFiles)
+	require.Equal(t, int64(1<<30-(4096-numNonTableCacheFiles)*estimatedOpenTableMemory), opts.defaultCacheSize())
+
+	// Small budgets don't reduce MaxOpenFiles below its default, and options
+	// set explicitly take precedence over the budget.
+	opts = (&Options{
+		MemoryBudget:                64 << 20,
+		MemTableSize:                1 << 20,
+		MemTableStopWritesThreshold: 4,
+	}).EnsureDefaults()
+	require.Equal(t, 1<<20, opts.MemTableSize)
+	require.Equal(t, 1000, opts.MaxOpenFiles)
+	opts = (&Options{MemoryBudget: 64 << 20, MemTableStopWritesThreshold: 4}).EnsureDefaults()
+	require.Equal(t, 2<<20, opts.MemTableSize)
+
+	// The block cache keeps at least a quarter of the budget.
+	opts = (&Options{MemoryBudget: 64 << 20, MaxOpenFiles: 100000}).EnsureDefaults()
+	require.Equal(t, int64(16<<20), opts.defaultCacheSize())
+
+	d, err := Open("", &Options{FS: vfs.NewMem(), MemoryBudget: 64 << 20})
+	require.NoError(t, err)
+	defer func() { require.NoError(t, d.Close()) }()
+	require.Equal(t, 4<<20, d.opts.MemTableSize)
+	require.Equal(t, int64(64<<20-990*estimatedOpenTableMemory), d.opts.Cache.MaxSize())
+	require.NoError(t, d.Set([]byte("a"), []byte("b"), nil))
+	require.NoError(t, d.Flush())
+	_, closer, err := d.Get([]byte("a"))
+	require.NoError(t, err)
+	require.NoError(t, closer.Close())
+
+	// The memtables' memory is reserved from the block cache.
+	m := d.Metrics()
+	require.Equal(t, int64(64<<20), m.MemoryBudget.Total)
+	require.Greater(t, m.MemoryBudget.MemTables, int64(0))
+	require.Equal(t, d.opts.Cache.MaxSize(), m.MemoryBudget.BlockCache+m.MemoryBudget.MemTables)
+	require.Equal(t, int64(estimatedOpenTableMemory), m.MemoryBudget.TableCache)
+
+	require.Error(t, (&Options{MemoryBudget: -1}).EnsureDefaults().Validate())
+}
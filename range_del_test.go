@@ -92,12 +92,133 @@ func TestRangeDel(t *testing.T) {
 			iter := snap.NewIter(nil)
 			return runIterCmd(td, iter, true)
 
+		case "range-deletions":
+			snap := Snapshot{
+				db:     d,
+				seqNum: InternalKeySeqNumMax,
+			}
+			var lower, upper []byte
+			for _, arg := range td.CmdArgs {
+				if len(arg.Vals) != 1 {
+					return fmt.Sprintf("%s: %s=<value>", td.Cmd, arg.Key)
+				}
+				switch arg.Key {
+				case "seq":
+					var err error
+					snap.seqNum, err = strconv.ParseUint(arg.Vals[0], 10, 64)
+					if err != nil {
+						return err.Error()
+					}
+				case "lower":
+					lower = []byte(arg.Vals[0])
+				case "upper":
+					upper = []byte(arg.Vals[0])
+				default:
+					return fmt.Sprintf("%s: unknown arg: %s", td.Cmd, arg.Key)
+				}
+			}
+
+			iter := snap.RangeDeletions(lower, upper)
+			var buf bytes.Buffer
+			for _, line := range strings.Split(td.Input, "\n") {
+				parts := strings.Fields(line)
+				if len(parts) == 0 {
+					continue
+				}
+				var valid bool
+				switch parts[0] {
+				case "first":
+					valid = iter.First()
+				case "next":
+					valid = iter.Next()
+				case "seek-ge":
+					valid = iter.SeekGE([]byte(parts[1]))
+				default:
+					return fmt.Sprintf("unknown op: %s", parts[0])
+				}
+				if !valid {
+					fmt.Fprintf(&buf, ".\n")
+					continue
+				}
+				rd := iter.RangeDeletion()
+				fmt.Fprintf(&buf, "%s-%s: %v\n", rd.Start, rd.End, rd.SeqNums)
+			}
+			if err := iter.Close(); err != nil {
+				fmt.Fprintf(&buf, "err=%v\n", err)
+			}
+			return buf.String()
+
+		case "is-range-deleted":
+			snap := Snapshot{
+				db:     d,
+				seqNum: InternalKeySeqNumMax,
+			}
+			var lower, upper []byte
+			for _, arg := range td.CmdArgs {
+				if len(arg.Vals) != 1 {
+					return fmt.Sprintf("%s: %s=<value>", td.Cmd, arg.Key)
+				}
+				switch arg.Key {
+				case "seq":
+					var err error
+					snap.seqNum, err = strconv.ParseUint(arg.Vals[0], 10, 64)
+					if err != nil {
+						return err.Error()
+					}
+				case "lower":
+					lower = []byte(arg.Vals[0])
+				case "upper":
+					upper = []byte(arg.Vals[0])
+				default:
+					return fmt.Sprintf("%s: unknown arg: %s", td.Cmd, arg.Key)
+				}
+			}
+			deleted, err := snap.IsRangeDeleted(lower, upper)
+			if err != nil {
+				return err.Error()
+			}
+			return fmt.Sprintf("%t", deleted)
+
 		default:
 			return fmt.Sprintf("unknown command: %s", td.Cmd)
 		}
 	})
 }
 
+func TestIsRangeDeleted(t *testing.T) {
+	d, err := Open("", &Options{FS: vfs.NewMem()})
+	require.NoError(t, err)
+	defer func() { require.NoError(t, d.Close()) }()
+
+	isRangeDeleted := func(lower, upper string) bool {
+		deleted, err := d.IsRangeDeleted([]byte(lower), []byte(upper))
+		require.NoError(t, err)
+		return deleted
+	}
+
+	// A range without keys is not deleted, nor is one whose keys were deleted
+	// by point deletions.
+	require.False(t, isRangeDeleted("a", "z"))
+	require.NoError(t, d.Set([]byte("b"), nil, nil))
+	require.NoError(t, d.Delete([]byte("b"), nil))
+	require.False(t, isRangeDeleted("a", "z"))
+
+	require.NoError(t, d.DeleteRange([]byte("a"), []byte("m"), nil))
+	require.NoError(t, d.Flush())
+	require.NoError(t, d.DeleteRange([]byte("m"), []byte("z"), nil))
+	require.True(t, isRangeDeleted("a", "z"))
+	require.False(t, isRangeDeleted("a", "zz"))
+
+	// A key written after the range deletion is live.
+	require.NoError(t, d.Set([]byte("n"), nil, nil))
+	require.False(t, isRangeDeleted("a", "z"))
+	require.True(t, isRangeDeleted("a", "n"))
+	require.True(t, isRangeDeleted("na", "z"))
+
+	_, err = d.IsRangeDeleted(nil, []byte("z"))
+	require.Error(t, err)
+}
+
 func TestDeleteRangeFlushDelay(t *testing.T) {
 	opts := &Options{FS: vfs.NewMem()}
 	opts.Experimental.DeleteRangeFlushDelay = 10 * time.Millisecond
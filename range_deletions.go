@@ -0,0 +1,299 @@
+// Copyright 2026 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package pebble
+
+import (
+	"sync/atomic"
+
+	"github.com/cockroachdb/errors"
+	"github.com/cockroachdb/pebble/internal/base"
+	"github.com/cockroachdb/pebble/internal/keyspan"
+	"github.com/cockroachdb/pebble/internal/manifest"
+)
+
+// RangeDeletion describes a fragment of the range deletion tombstones in the
+// DB. Every range deletion tombstone that overlaps the span [Start, End) covers
+// it entirely.
+type RangeDeletion struct {
+	Start []byte
+	End   []byte
+	// SeqNums holds the sequence numbers of the range deletion tombstones
+	// covering the span that are visible to the iterator, across all levels
+	// of the LSM, in descending order. It is never empty.
+	SeqNums []uint64
+}
+
+// RangeDeletionIter iterates over the range deletion tombstones of a DB or
+// Snapshot, fragmented at every tombstone boundary and merged across the
+// memtables and all levels of the LSM. It is intended for introspection:
+// tombstones are surfaced as they're stored, regardless of whether the data
+// they cover still exists. Range keys are not surfaced.
+//
+// A RangeDeletionIter holds a reference to the memtables and sstables it
+// reads, preventing them from being deleted until the iterator is closed.
+type RangeDeletionIter struct {
+	cmp          Compare
+	seqNum       uint64
+	lower, upper []byte
+	readState    *readState
+	iter         keyspan.MergingIter
+	span         *keyspan.Span
+	cur          RangeDeletion
+}
+
+// RangeDeletions returns an iterator over the range deletion tombstones
+// overlapping the range [lower, upper). Either of lower or upper may be nil,
+// in which case the range is unbounded in that direction. The returned
+// iterator is unpositioned, and must be closed by the caller.
+func (d *DB) RangeDeletions(lower, upper []byte) *RangeDeletionIter {
+	if err := d.closed.Load(); err != nil {
+		panic(err)
+	}
+	return d.newRangeDeletionIter(d.loadReadState(), lower, upper,
+		atomic.LoadUint64(&d.mu.versions.atomic.visibleSeqNum))
+}
+
+// IsRangeDeleted returns true if the range [lower, upper) is deleted by range
+// deletion tombstones: every key in the range is covered by a range deletion
+// tombstone visible at the DB's current sequence number, and no point key in
+// the range is live, i.e. none was written after the tombstones covering it.
+// Both lower and upper must be non-nil, and lower must be less than upper.
+//
+// Only range deletion tombstones delete a range. A range whose point keys
+// were all deleted by point deletions, or that never held any keys, is not
+// deleted unless it's also covered by range deletion tombstones. Range keys
+// are ignored: a range deleted by range deletion tombstones may still hold
+// range keys, which DeleteRange does not remove.
+//
+// A range may be covered by tombstones that have been compacted away along
+// with the keys they deleted, in which case the range is not reported as
+// deleted even though it holds no keys.
+func (d *DB) IsRangeDeleted(lower, upper []byte) (bool, error) {
+	if err := d.closed.Load(); err != nil {
+		panic(err)
+	}
+	s := d.NewSnapshot()
+	defer s.Close()
+	return s.IsRangeDeleted(lower, upper)
+}
+
+// isRangeDeleted implements IsRangeDeleted for the snapshot s.
+func (d *DB) isRangeDeleted(lower, upper []byte, s *Snapshot) (bool, error) {
+	if lower == nil || upper == nil {
+		return false, errors.New("pebble: IsRangeDeleted requires lower and upper bounds")
+	}
+	if d.cmp(lower, upper) >= 0 {
+		return false, errors.Errorf("pebble: IsRangeDeleted lower %s is not less than upper %s",
+			d.opts.Comparer.FormatKey(lower), d.opts.Comparer.FormatKey(upper))
+	}
+
+	// Check that the tombstones cover the range without gaps.
+	covered := lower
+	rdi := s.RangeDeletions(lower, upper)
+	for valid := rdi.First(); valid && d.cmp(covered, upper) < 0; valid = rdi.Next() {
+		rd := rdi.RangeDeletion()
+		if d.cmp(rd.Start, covered) > 0 {
+			break
+		}
+		covered = rd.End
+	}
+	if err := rdi.Close(); err != nil {
+		return false, err
+	}
+	if d.cmp(covered, upper) < 0 {
+		return false, nil
+	}
+
+	// Check that no point key written after the tombstones is live.
+	iter := s.NewIter(&IterOptions{LowerBound: lower, UpperBound: upper})
+	live := iter.First()
+	if err := iter.Close(); err != nil {
+		return false, err
+	}
+	return !live, nil
+}
+
+// newRangeDeletionIter constructs a RangeDeletionIter reading the provided
+// readState, taking ownership of the caller's reference to it.
+func (d *DB) newRangeDeletionIter(
+	readState *readState, lower, upper []byte, seqNum uint64,
+) *RangeDeletionIter {
+	i := &RangeDeletionIter{
+		cmp:       d.cmp,
+		seqNum:    seqNum,
+		lower:     lower,
+		upper:     upper,
+		readState: readState,
+	}
+	var levels []keyspan.FragmentIterator
+	iterOpts := &IterOptions{LowerBound: lower, UpperBound: upper, logger: d.opts.Logger}
+	for j := len(i.readState.memtables) - 1; j >= 0; j-- {
+		mem := i.readState.memtables[j]
+		// Memtables containing only sequence numbers newer than seqNum are
+		// invisible.
+		if mem.logSeqNum >= seqNum {
+			continue
+		}
+		if rangeDelIter := mem.newRangeDelIter(iterOpts); rangeDelIter != nil {
+			levels = append(levels, rangeDelIter)
+		}
+	}
+
+	current := i.readState.current
+	newRangeDelIter := func(
+		f *manifest.FileMetadata, lower, upper []byte,
+	) (keyspan.FragmentIterator, error) {
+		iter, rangeDelIter, err := d.newIters(f, iterOpts, internalIterOpts{})
+		if err != nil {
+			return nil, err
+		}
+		if err := iter.Close(); err != nil {
+			if rangeDelIter != nil {
+				rangeDelIter.Close()
+			}
+			return nil, err
+		}
+		if rangeDelIter == nil {
+			return emptyKeyspanIter, nil
+		}
+		// Range deletion tombstones may extend beyond the bounds of the
+		// sstable containing them, and are only valid within those bounds.
+		// See compaction.newInputIter.
+		return keyspan.Truncate(d.cmp, rangeDelIter, lower, upper, &f.Smallest, &f.Largest), nil
+	}
+
+	// L0 files may overlap one another, and are added individually.
+	iter := current.Levels[0].Iter()
+	for f := iter.Last(); f != nil; f = iter.Prev() {
+		if !f.HasPointKeys ||
+			(lower != nil && d.cmp(f.Largest.UserKey, lower) < 0) ||
+			(upper != nil && d.cmp(f.Smallest.UserKey, upper) >= 0) {
+			continue
+		}
+		rangeDelIter, err := newRangeDelIter(f, f.Smallest.UserKey, f.Largest.UserKey)
+		if err != nil {
+			rangeDelIter = &errorKeyspanIter{err: err}
+		}
+		levels = append(levels, rangeDelIter)
+	}
+	for level := 1; level < len(current.Levels); level++ {
+		if current.Levels[level].Empty() {
+			continue
+		}
+		level := level
+		wrapper := func(
+			f *manifest.FileMetadata, _ *keyspan.SpanIterOptions,
+		) (keyspan.FragmentIterator, error) {
+			// Truncate to the bounds of the file's atomic compaction unit,
+			// matching the truncation performed by compactions.
+			unit, _ := expandToAtomicUnit(d.cmp, current.Overlaps(level, d.cmp,
+				f.Smallest.UserKey, f.Smallest.UserKey, false /* exclusiveEnd */), true /* disableIsCompacting */)
+			lowerBound, upperBound := manifest.KeyRange(d.cmp, unit.Iter())
+			return newRangeDelIter(f, lowerBound.UserKey, upperBound.UserKey)
+		}
+		li := &keyspan.LevelIter{}
+		li.Init(keyspan.SpanIterOptions{}, d.cmp, wrapper, current.Levels[level].Iter(),
+			manifest.Level(level), d.opts.Logger, manifest.KeyTypePoint)
+		levels = append(levels, li)
+	}
+
+	i.iter.Init(d.cmp, keyspan.TransformerFunc(i.visible), levels...)
+	return i
+}
+
+// visible is a keyspan.Transformer that filters out the range deletion
+// tombstones that are not visible at the iterator's sequence number.
+func (i *RangeDeletionIter) visible(_ base.Compare, s keyspan.Span, dst *keyspan.Span) error {
+	dst.Start, dst.End = s.Start, s.End
+	dst.Keys = dst.Keys[:0]
+	for _, k := range s.Keys {
+		if k.Kind() == base.InternalKeyKindRangeDelete && base.Visible(k.SeqNum(), i.seqNum) {
+			dst.Keys = append(dst.Keys, k)
+		}
+	}
+	return nil
+}
+
+// First moves the iterator to the first range deletion fragment, returning
+// true if the iterator is positioned at a valid fragment.
+func (i *RangeDeletionIter) First() bool {
+	if i.lower != nil {
+		return i.setSpan(keyspan.SeekGE(i.cmp, &i.iter, i.lower))
+	}
+	return i.setSpan(i.iter.First())
+}
+
+// SeekGE moves the iterator to the first range deletion fragment that ends
+// after the provided key, returning true if the iterator is positioned at a
+// valid fragment.
+func (i *RangeDeletionIter) SeekGE(key []byte) bool {
+	if i.lower != nil && i.cmp(key, i.lower) < 0 {
+		key = i.lower
+	}
+	return i.setSpan(keyspan.SeekGE(i.cmp, &i.iter, key))
+}
+
+// Next moves the iterator to the next range deletion fragment, returning true
+// if the iterator is positioned at a valid fragment.
+func (i *RangeDeletionIter) Next() bool {
+	if i.span == nil {
+		return false
+	}
+	return i.setSpan(i.iter.Next())
+}
+
+func (i *RangeDeletionIter) setSpan(s *keyspan.Span) bool {
+	// Skip the fragments whose tombstones are all invisible at the iterator's
+	// sequence number.
+	for s != nil && s.Empty() {
+		s = i.iter.Next()
+	}
+	if s == nil || (i.upper != nil && i.cmp(s.Start, i.upper) >= 0) {
+		i.span = nil
+		return false
+	}
+	i.span = s
+	i.cur = RangeDeletion{Start: s.Start, End: s.End, SeqNums: i.cur.SeqNums[:0]}
+	if i.lower != nil && i.cmp(i.cur.Start, i.lower) < 0 {
+		i.cur.Start = i.lower
+	}
+	if i.upper != nil && i.cmp(i.cur.End, i.upper) > 0 {
+		i.cur.End = i.upper
+	}
+	for _, k := range s.Keys {
+		i.cur.SeqNums = append(i.cur.SeqNums, k.SeqNum())
+	}
+	return true
+}
+
+// Valid returns true if the iterator is positioned at a valid range deletion
+// fragment.
+func (i *RangeDeletionIter) Valid() bool {
+	return i.span != nil
+}
+
+// RangeDeletion returns the range deletion fragment at the iterator's current
+// position, truncated to the iterator's bounds. The returned fragment and its
+// slices are only valid until the next positioning method is called.
+func (i *RangeDeletionIter) RangeDeletion() RangeDeletion {
+	return i.cur
+}
+
+// Error returns any accumulated error.
+func (i *RangeDeletionIter) Error() error {
+	return i.iter.Error()
+}
+
+// Close closes the iterator, releasing the memtables and sstables it
+// references. It returns any accumulated error.
+func (i *RangeDeletionIter) Close() error {
+	err := i.iter.Close()
+	i.span = nil
+	if i.readState != nil {
+		i.readState.unref()
+		i.readState = nil
+	}
+	return err
+}
@@ -629,6 +629,11 @@ func (i *lazyCombinedIter) SetBounds(lower, upper []byte) {
 	i.pointIter.SetBounds(lower, upper)
 }
 
+// FetchValue implements base.LazyValueIterator.
+func (i *lazyCombinedIter) FetchValue(value []byte) ([]byte, error) {
+	return base.FetchValue(i.pointIter, value)
+}
+
 func (i *lazyCombinedIter) String() string {
 	if i.combinedIterState.initialized {
 		return i.parent.rangeKey.iiter.String()
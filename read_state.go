@@ -91,6 +91,9 @@ func (d *DB) updateReadStateLocked(checker func(*DB) error) {
 	for _, mem := range s.memtables {
 		mem.readerRef()
 	}
+	if s.current.L0Sublevels != nil {
+		atomic.StoreInt64(&d.atomic.l0ReadAmp, int64(s.current.L0Sublevels.ReadAmplification()))
+	}
 
 	d.readState.Lock()
 	old := d.readState.val
@@ -0,0 +1,138 @@
+// Copyright 2026 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package pebble
+
+import (
+	"context"
+
+	"github.com/cockroachdb/errors"
+)
+
+// rebalanceChunkFactor bounds the input of each compaction performed by
+// RebalanceLevel, as a multiple of the level's target file size.
+const rebalanceChunkFactor = 10
+
+// RebalanceOptions hold the optional parameters for RebalanceLevel.
+type RebalanceOptions struct {
+	// Progress, if non-nil, is invoked after each compaction performed by the
+	// rebalance completes.
+	Progress func(RebalanceProgress)
+}
+
+// RebalanceProgress describes the progress of a RebalanceLevel call.
+type RebalanceProgress struct {
+	// Compactions is the number of compactions completed so far.
+	Compactions int
+	// BytesRewritten is the total size of the input files of the compactions
+	// completed so far. The last output of a compaction is rewritten again by
+	// the following one, so it may exceed the initial size of the level.
+	BytesRewritten uint64
+	// BytesRemaining is the total size of the files in the level that remain
+	// to be rewritten.
+	BytesRemaining uint64
+}
+
+// RebalanceLevel rewrites the files in the given level into files of roughly
+// the target file size of compactions into the level, without moving any data
+// to another level. As with other compactions, the target file size is taken
+// from the Options.Levels entry of the level's position relative to the base
+// level, which is L1 for the base level itself. RebalanceLevel may be used to
+// repair a level whose files vary widely in size, for example after a change
+// to TargetFileSize or after ingesting many small sstables.
+//
+// The level is rewritten left to right by a sequence of rewrite compactions,
+// each reading a bounded number of adjacent files, so that the rebalance
+// doesn't hold a large fraction of the level compacting at once. Each
+// compaction behaves like any other: keys visible to open snapshots are
+// preserved, and deletion tombstones are only dropped when no data beneath
+// them and no snapshot can observe them. Concurrent compactions may change the
+// level while it is being rebalanced, in which case the rebalance picks up the
+// level's current files as it goes.
+//
+// RebalanceLevel may be canceled through ctx, in which case it returns
+// ctx.Err(). A compaction already running when the context is canceled is
+// allowed to finish in the background, and the level remains consistent.
+// L0 cannot be rebalanced.
+func (d *DB) RebalanceLevel(ctx context.Context, level int, opts *RebalanceOptions) error {
+	if err := d.closed.Load(); err != nil {
+		panic(err)
+	}
+	if d.opts.ReadOnly {
+		return ErrReadOnly
+	}
+	if level < 1 || level >= numLevels {
+		return errors.Errorf("pebble: cannot rebalance level %d", level)
+	}
+	if opts == nil {
+		opts = &RebalanceOptions{}
+	}
+	var progress RebalanceProgress
+	var cursor []byte
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		d.mu.Lock()
+		// The base level may change as the rebalance proceeds, so the size of
+		// each chunk is computed as in newPickedCompaction.
+		adjustedLevel := 1 + level - d.mu.versions.picker.getBaseLevel()
+		chunkSize := uint64(rebalanceChunkFactor * d.opts.Level(adjustedLevel).TargetFileSize)
+		iter := d.mu.versions.currentVersion().Levels[level].Iter()
+		// The file containing the cursor holds the last output of the previous
+		// compaction, which is likely smaller than the target file size, so it
+		// is rewritten along with at least one of the files that follow it.
+		f := iter.First()
+		if cursor != nil {
+			f = iter.SeekGE(d.cmp, cursor)
+		}
+		var start, end []byte
+		var size uint64
+		var n int
+		for ; f != nil && (n < 2 || size < chunkSize); f = iter.Next() {
+			if n == 0 {
+				start = f.Smallest.UserKey
+			}
+			end = f.Largest.UserKey
+			size += f.Size
+			n++
+		}
+		if n == 0 || (cursor != nil && n == 1) {
+			// Only the last output of the previous compaction remains.
+			d.mu.Unlock()
+			return nil
+		}
+		progress.BytesRemaining = 0
+		for ; f != nil; f = iter.Next() {
+			progress.BytesRemaining += f.Size
+		}
+		manual := &manualCompaction{
+			level:     level,
+			done:      make(chan error, 1),
+			start:     start,
+			end:       end,
+			rebalance: true,
+		}
+		d.mu.compact.manual = append(d.mu.compact.manual, manual)
+		d.maybeScheduleCompaction()
+		d.mu.Unlock()
+
+		select {
+		case err := <-manual.done:
+			if err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		progress.Compactions++
+		progress.BytesRewritten += size
+		if opts.Progress != nil {
+			opts.Progress(progress)
+		}
+		cursor = append(cursor[:0], end...)
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright 2026 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package pebble
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+	"github.com/cockroachdb/pebble/vfs"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRebalanceLevel(t *testing.T) {
+	levels := make([]LevelOptions, numLevels)
+	for i := range levels {
+		levels[i].TargetFileSize = 1 << 20
+	}
+	d, err := Open("", &Options{
+		FS:                          vfs.NewMem(),
+		DisableAutomaticCompactions: true,
+		Levels:                      levels,
+	})
+	require.NoError(t, err)
+	defer func() { require.NoError(t, d.Close()) }()
+
+	// Write one large L6 file, followed by many small ones.
+	rng := rand.New(rand.NewSource(1))
+	value := func() []byte {
+		v := make([]byte, 256)
+		rng.Read(v)
+		return v
+	}
+	for i := 0; i < 1000; i++ {
+		require.NoError(t, d.Set([]byte(fmt.Sprintf("a%04d", i)), value(), nil))
+	}
+	require.NoError(t, d.Compact([]byte("a"), []byte("b"), false))
+	for i := 0; i < 10; i++ {
+		require.NoError(t, d.Set([]byte(fmt.Sprintf("b%04d", i)), value(), nil))
+		require.NoError(t, d.Compact([]byte("b"), []byte("c"), false))
+	}
+
+	// Shadow a key visible to a snapshot.
+	s := d.NewSnapshot()
+	defer func() { require.NoError(t, s.Close()) }()
+	require.NoError(t, d.Delete([]byte("a0010"), nil))
+	require.NoError(t, d.Compact([]byte("a"), []byte("b"), false))
+
+	const targetFileSize = 8 << 10
+	d.mu.Lock()
+	files := d.mu.versions.currentVersion().Levels[6].Slice()
+	for i := range d.opts.Levels {
+		d.opts.Levels[i].TargetFileSize = targetFileSize
+	}
+	d.mu.Unlock()
+	require.Equal(t, 11, files.Len())
+	levelSize := files.SizeSum()
+
+	// A canceled rebalance does nothing.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.True(t, errors.Is(d.RebalanceLevel(ctx, 6, nil), context.Canceled))
+	require.Error(t, d.RebalanceLevel(context.Background(), 0, nil))
+
+	var progress []RebalanceProgress
+	require.NoError(t, d.RebalanceLevel(context.Background(), 6, &RebalanceOptions{
+		Progress: func(p RebalanceProgress) { progress = append(progress, p) },
+	}))
+	require.Greater(t, len(progress), 1)
+	last := progress[len(progress)-1]
+	require.Equal(t, len(progress), last.Compactions)
+	require.Zero(t, last.BytesRemaining)
+	require.GreaterOrEqual(t, last.BytesRewritten, levelSize)
+
+	// Every file but the last is close to the target file size.
+	d.mu.Lock()
+	v := d.mu.versions.currentVersion()
+	var sizes []uint64
+	v.Levels[6].Slice().Each(func(f *fileMetadata) { sizes = append(sizes, f.Size) })
+	d.mu.Unlock()
+	for l := 0; l < numLevels-1; l++ {
+		require.True(t, v.Levels[l].Empty())
+	}
+	require.Greater(t, len(sizes), int(levelSize/(2*targetFileSize)))
+	for i, size := range sizes {
+		require.LessOrEqual(t, size, uint64(2*targetFileSize))
+		if i < len(sizes)-1 {
+			require.GreaterOrEqual(t, size, uint64(targetFileSize/2))
+		}
+	}
+
+	// The data is unchanged, including as observed by the snapshot.
+	_, _, err = d.Get([]byte("a0010"))
+	require.Equal(t, ErrNotFound, err)
+	_, closer, err := s.Get([]byte("a0010"))
+	require.NoError(t, err)
+	require.NoError(t, closer.Close())
+	count := func(r Reader) int {
+		it := r.NewIter(nil)
+		var n int
+		for valid := it.First(); valid; valid = it.Next() {
+			n++
+		}
+		require.NoError(t, it.Close())
+		return n
+	}
+	require.Equal(t, 1009, count(d))
+	require.Equal(t, 1010, count(s))
+}
@@ -665,6 +665,20 @@ func (w *LogWriter) SyncRecord(p []byte, wg *sync.WaitGroup, err *error) (int64,
 	return offset, nil
 }
 
+// Err returns the error of a failed write to, or sync of, the file, after
+// which the LogWriter cannot be written to. Such an error is otherwise only
+// returned to a sync waiter, or by a later call to WriteRecord or SyncRecord.
+// External synchronisation provided by commitPipeline.mu.
+func (w *LogWriter) Err() error {
+	if w.err != nil {
+		return w.err
+	}
+	f := &w.flusher
+	f.Lock()
+	defer f.Unlock()
+	return f.err
+}
+
 // Size returns the current size of the file.
 // External synchronisation provided by commitPipeline.mu.
 func (w *LogWriter) Size() int64 {
@@ -0,0 +1,140 @@
+// Copyright 2026 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package record
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/cockroachdb/errors"
+	"github.com/cockroachdb/pebble/internal/base"
+	"github.com/cockroachdb/pebble/vfs"
+)
+
+// walBatchHeaderLen is the length of the header of a batch: an 8-byte
+// sequence number followed by a 4-byte count of the batch's entries.
+const walBatchHeaderLen = 12
+
+// WALBatch is a batch read from a WAL by a WALReader.
+type WALBatch struct {
+	// SeqNum is the sequence number of the first entry of the batch.
+	SeqNum uint64
+	// Count is the number of entries in the batch.
+	Count uint32
+	// Repr is the batch's representation, including its header, as accepted
+	// by pebble.Batch.SetRepr.
+	Repr []byte
+	// Offset is the offset within the WAL of the record holding the batch.
+	Offset int64
+}
+
+// WALTailError is returned by WALReader.Next when an invalid record is
+// encountered, for example because the WAL's tail was only partially written
+// before a crash. Offset is the offset within the WAL of the invalid record:
+// all records before it were read successfully, and none after it are read.
+type WALTailError struct {
+	Offset int64
+	Err    error
+}
+
+func (e *WALTailError) Error() string {
+	return fmt.Sprintf("pebble/record: invalid WAL record at offset %d: %s", e.Offset, e.Err)
+}
+
+// Unwrap returns the error describing the invalid record.
+func (e *WALTailError) Unwrap() error {
+	return e.Err
+}
+
+// WALReader reads the batches committed to a WAL, independently of opening
+// the DB that wrote it.
+type WALReader struct {
+	f   vfs.File
+	r   *Reader
+	err error
+}
+
+// NewWALReader returns a reader of the batches committed to the WAL f.
+//
+// Logs may be recycled, in which case the tail of the file holds records
+// written by a previous instance of the log, which must not be read. These are
+// identified by the log number of the file, which is parsed from the file's
+// name. If the file isn't named like a WAL, the log number is taken from the
+// first record instead, which is correct as long as at least one record was
+// written to the file since it was recycled.
+func NewWALReader(f vfs.File) *WALReader {
+	return &WALReader{f: f}
+}
+
+func (w *WALReader) init() error {
+	if finfo, err := w.f.Stat(); err != nil {
+		return err
+	} else if ft, fileNum, ok := base.ParseFilename(vfs.Default, finfo.Name()); ok && ft == base.FileTypeLog {
+		w.r = NewReader(w.f, fileNum)
+		return nil
+	}
+	var hdr [recyclableHeaderSize]byte
+	n, err := io.ReadFull(w.f, hdr[:])
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return err
+	}
+	var logNum base.FileNum
+	if n == recyclableHeaderSize && hdr[6] >= recyclableFullChunkType && hdr[6] <= recyclableLastChunkType {
+		logNum = base.FileNum(binary.LittleEndian.Uint32(hdr[7:11]))
+	}
+	w.r = NewReader(io.MultiReader(bytes.NewReader(hdr[:n]), w.f), logNum)
+	return nil
+}
+
+// Next returns the next batch in the WAL. It returns io.EOF once all batches
+// have been read, including when the remainder of the WAL is zeroed, and a
+// *WALTailError if an invalid record is encountered, in which case the batches
+// already read are those preceding the error's offset. Either error is
+// returned by all subsequent calls.
+func (w *WALReader) Next() (WALBatch, error) {
+	if w.err != nil {
+		return WALBatch{}, w.err
+	}
+	if w.r == nil {
+		if w.err = w.init(); w.err != nil {
+			return WALBatch{}, w.err
+		}
+	}
+	offset := w.r.Offset()
+	b, err := w.next()
+	if err != nil {
+		if err == ErrZeroedChunk {
+			// The unused tail of a preallocated WAL is zeroed.
+			err = io.EOF
+		} else if IsInvalidRecord(err) || errors.Is(err, base.ErrCorruption) {
+			err = &WALTailError{Offset: offset, Err: err}
+		}
+		w.err = err
+		return WALBatch{}, err
+	}
+	b.Offset = offset
+	return b, nil
+}
+
+func (w *WALReader) next() (WALBatch, error) {
+	rec, err := w.r.Next()
+	if err != nil {
+		return WALBatch{}, err
+	}
+	repr, err := io.ReadAll(rec)
+	if err != nil {
+		return WALBatch{}, err
+	}
+	if len(repr) < walBatchHeaderLen {
+		return WALBatch{}, base.CorruptionErrorf("pebble/record: batch of %d bytes is too short", len(repr))
+	}
+	return WALBatch{
+		SeqNum: binary.LittleEndian.Uint64(repr[:8]),
+		Count:  binary.LittleEndian.Uint32(repr[8:walBatchHeaderLen]),
+		Repr:   repr,
+	}, nil
+}
@@ -0,0 +1,96 @@
+// Copyright 2026 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package record
+
+import (
+	"encoding/binary"
+	"io"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+	"github.com/cockroachdb/pebble/internal/base"
+	"github.com/cockroachdb/pebble/vfs"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWALReader(t *testing.T) {
+	mem := vfs.NewMem()
+	makeBatch := func(seqNum uint64, count uint32, size int) []byte {
+		repr := make([]byte, walBatchHeaderLen+size)
+		binary.LittleEndian.PutUint64(repr[:8], seqNum)
+		binary.LittleEndian.PutUint32(repr[8:12], count)
+		return repr
+	}
+	writeLog := func(f vfs.File, logNum base.FileNum, batches ...[]byte) (offsets []int64) {
+		w := NewLogWriter(f, logNum)
+		for _, b := range batches {
+			offsets = append(offsets, w.Size())
+			_, err := w.WriteRecord(b)
+			require.NoError(t, err)
+		}
+		require.NoError(t, w.Close())
+		return offsets
+	}
+	readLog := func(name string) (seqNums []uint64, offsets []int64, err error) {
+		f, err := mem.Open(name)
+		require.NoError(t, err)
+		defer f.Close()
+		r := NewWALReader(f)
+		for {
+			b, err := r.Next()
+			if err != nil {
+				return seqNums, offsets, err
+			}
+			require.Equal(t, uint32(2), b.Count)
+			seqNums = append(seqNums, b.SeqNum)
+			offsets = append(offsets, b.Offset)
+		}
+	}
+
+	f, err := mem.Create("000005.log")
+	require.NoError(t, err)
+	offsets := writeLog(f, 5,
+		makeBatch(10, 2, 100), makeBatch(12, 2, 40000), makeBatch(14, 2, 100))
+
+	t.Run("complete", func(t *testing.T) {
+		seqNums, readOffsets, err := readLog("000005.log")
+		require.Equal(t, io.EOF, err)
+		require.Equal(t, []uint64{10, 12, 14}, seqNums)
+		require.Equal(t, offsets, readOffsets)
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		// Copy the log, omitting the end of the last batch, to a file that isn't
+		// named like a WAL, so that the log number is taken from its first record.
+		f, err := mem.Open("000005.log")
+		require.NoError(t, err)
+		data, err := io.ReadAll(f)
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+		f, err = mem.Create("salvaged")
+		require.NoError(t, err)
+		_, err = f.Write(data[:offsets[2]+20])
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+
+		seqNums, _, err := readLog("salvaged")
+		require.Equal(t, []uint64{10, 12}, seqNums)
+		var tailErr *WALTailError
+		require.True(t, errors.As(err, &tailErr))
+		require.Equal(t, offsets[2], tailErr.Offset)
+		require.True(t, IsInvalidRecord(tailErr.Err))
+	})
+
+	t.Run("recycled", func(t *testing.T) {
+		// Recycle log 5 as log 6, overwriting only its first batch. The
+		// remaining records of log 5 are not read.
+		f, err := mem.ReuseForWrite("000005.log", "000006.log")
+		require.NoError(t, err)
+		writeLog(f, 6, makeBatch(20, 2, 100))
+		seqNums, _, err := readLog("000006.log")
+		require.Equal(t, io.EOF, err)
+		require.Equal(t, []uint64{20}, seqNums)
+	})
+}
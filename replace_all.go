@@ -0,0 +1,197 @@
+// Copyright 2026 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package pebble
+
+// ReplaceAll atomically replaces the entire contents of the DB with the
+// sstables at paths. The sstables must satisfy the same requirements as those
+// passed to Ingest, and on success ReplaceAll removes the input paths. Passing
+// no sstables, or only empty ones, deletes the entire contents of the DB.
+//
+// ReplaceAll is sequenced like a write: all of the writes sequenced before it
+// are replaced, and all of the writes sequenced after it are applied on top of
+// the new contents. The memtables holding the replaced writes are flushed,
+// and then a single version edit removes every sstable holding replaced data
+// from the LSM and adds the new sstables to the bottommost level, so that
+// readers switch from the old contents to the new ones at once. The removed
+// sstables are deleted once they're no longer referenced by any iterator.
+// ReplaceAll waits for in-progress compactions to complete, and no
+// compactions are started while it runs.
+//
+// Iterators opened before ReplaceAll continue to read the old contents.
+// Snapshots are not preserved: reads through a snapshot taken before
+// ReplaceAll observe neither the old contents, which have been removed, nor
+// the new ones, which are newer than the snapshot.
+func (d *DB) ReplaceAll(paths []string) error {
+	if err := d.closed.Load(); err != nil {
+		panic(err)
+	}
+	if d.opts.ReadOnly {
+		return ErrReadOnly
+	}
+
+	d.mu.Lock()
+	pendingOutputs := make([]FileNum, len(paths))
+	for i := range paths {
+		pendingOutputs[i] = d.mu.versions.getNextFileNum()
+	}
+	jobID := d.mu.nextJobID
+	d.mu.nextJobID++
+	d.mu.Unlock()
+
+	meta, paths, err := ingestLoad(d.opts, d.FormatMajorVersion(), paths, d.cacheID, pendingOutputs,
+		true /* validateKeys */)
+	if err != nil {
+		return err
+	}
+	if err := ingestSortAndVerify(d.cmp, meta, paths); err != nil {
+		return err
+	}
+	if err := ingestLink(jobID, d.opts, d.dirname, paths, meta); err != nil {
+		return err
+	}
+	if err := d.dataDir.Sync(); err != nil {
+		return err
+	}
+
+	var mem *flushableEntry
+	prepare := func() {
+		// Note that d.commit.mu is held by commitPipeline when calling prepare.
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		if err = d.mu.compact.backgroundErr; err != nil {
+			return
+		}
+		// Rotate the mutable memtable so that the memtables holding the writes
+		// sequenced before the replacement may be flushed, and prevent them from
+		// being flushed together with the writes sequenced after it.
+		mem = d.mu.mem.queue[len(d.mu.mem.queue)-1]
+		mem.flushBarrier = true
+		if err = d.makeRoomForWrite(nil); err != nil {
+			return
+		}
+		// Compactions started from here on could combine replaced data with
+		// newer flushed data.
+		d.mu.compact.replacing++
+	}
+
+	var ve *versionEdit
+	apply := func(seqNum uint64) {
+		if err != nil {
+			// An error occurred during prepare.
+			return
+		}
+		if err = ingestUpdateSeqNum(d.cmp, d.opts.Comparer.FormatKey, seqNum, meta); err == nil {
+			if err = d.waitForFlush(mem.flushed); err == nil {
+				ve, err = d.replaceAllApply(jobID, seqNum, meta)
+			}
+		}
+
+		d.mu.Lock()
+		d.mu.compact.replacing--
+		d.maybeScheduleCompaction()
+		d.mu.Unlock()
+	}
+
+	// Allocate at least one sequence number, so that the writes sequenced
+	// after the replacement have sequence numbers greater than seqNum even if
+	// there are no sstables.
+	count := len(meta)
+	if count == 0 {
+		count = 1
+	}
+	d.commit.AllocateSeqNum(count, prepare, apply)
+
+	if err != nil {
+		if err2 := ingestCleanup(d.opts.FS, d.dirname, meta); err2 != nil {
+			d.opts.Logger.Infof("replace cleanup failed: %v", err2)
+		}
+	} else {
+		for _, path := range paths {
+			if err2 := d.opts.FS.Remove(path); err2 != nil {
+				d.opts.Logger.Infof("replace failed to remove original file: %s", err2)
+			}
+		}
+	}
+
+	info := TableIngestInfo{
+		JobID: jobID,
+		Err:   err,
+	}
+	if len(meta) > 0 {
+		info.GlobalSeqNum = meta[0].SmallestSeqNum
+	}
+	if ve != nil {
+		info.Tables = make([]struct {
+			TableInfo
+			Level int
+		}, len(ve.NewFiles))
+		for i := range ve.NewFiles {
+			e := &ve.NewFiles[i]
+			info.Tables[i].Level = e.Level
+			info.Tables[i].TableInfo = e.Meta.TableInfo()
+		}
+	}
+	d.opts.EventListener.TableIngested(info)
+	return err
+}
+
+// replaceAllApply applies the version edit of ReplaceAll, which removes every
+// sstable containing keys with sequence numbers lower than seqNum from the LSM,
+// and adds the sstables in meta to the bottommost level.
+//
+// The memtables holding keys with sequence numbers lower than seqNum must have
+// been flushed, and no compactions may be started, when calling this.
+func (d *DB) replaceAllApply(
+	jobID int, seqNum uint64, meta []*fileMetadata,
+) (*versionEdit, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for d.mu.compact.compactingCount > 0 {
+		d.mu.compact.cond.Wait()
+	}
+
+	ve := &versionEdit{
+		DeletedFiles: map[deletedFileEntry]*fileMetadata{},
+		NewFiles:     make([]newFileEntry, len(meta)),
+	}
+	metrics := make(map[int]*LevelMetrics)
+
+	// The sstables flushed since the replacement hold only newer keys, and
+	// reside in L0, so the bottommost level is free for the new sstables.
+	d.mu.versions.logLock()
+	current := d.mu.versions.currentVersion()
+	for level := range current.Levels {
+		levelMetrics := &LevelMetrics{}
+		iter := current.Levels[level].Iter()
+		for f := iter.First(); f != nil; f = iter.Next() {
+			if f.LargestSeqNum >= seqNum {
+				continue
+			}
+			levelMetrics.NumFiles--
+			levelMetrics.Size -= int64(f.Size)
+			ve.DeletedFiles[deletedFileEntry{Level: level, FileNum: f.FileNum}] = f
+		}
+		metrics[level] = levelMetrics
+	}
+	bottomMetrics := metrics[numLevels-1]
+	for i, m := range meta {
+		ve.NewFiles[i] = newFileEntry{Level: numLevels - 1, Meta: m}
+		bottomMetrics.NumFiles++
+		bottomMetrics.Size += int64(m.Size)
+		bottomMetrics.BytesIngested += m.Size
+		bottomMetrics.TablesIngested++
+	}
+	if err := d.mu.versions.logAndApply(jobID, ve, metrics, false /* forceRotation */, func() []compactionInfo {
+		return d.getInProgressCompactionInfoLocked(nil)
+	}); err != nil {
+		return nil, err
+	}
+	d.updateReadStateLocked(d.opts.DebugCheck)
+	d.updateTableStatsLocked(ve.NewFiles)
+	d.deleteObsoleteFiles(jobID, false /* waitForOngoing */)
+	d.maybeValidateSSTablesLocked(ve.NewFiles)
+	return ve, nil
+}
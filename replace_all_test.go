@@ -0,0 +1,113 @@
+// Copyright 2026 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package pebble
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/errors/oserror"
+	"github.com/cockroachdb/pebble/sstable"
+	"github.com/cockroachdb/pebble/vfs"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplaceAll(t *testing.T) {
+	mem := vfs.NewMem()
+	d, err := Open("", &Options{FS: mem})
+	require.NoError(t, err)
+	defer func() { require.NoError(t, d.Close()) }()
+
+	// writeTable writes an sstable at path containing a set of each of the
+	// given keys.
+	writeTable := func(path string, keys ...string) {
+		t.Helper()
+		f, err := mem.Create(path)
+		require.NoError(t, err)
+		w := sstable.NewWriter(f, sstable.WriterOptions{})
+		for _, k := range keys {
+			require.NoError(t, w.Set([]byte(k), []byte("new")))
+		}
+		require.NoError(t, w.Close())
+	}
+	scan := func(r Reader) string {
+		t.Helper()
+		iter := r.NewIter(nil)
+		var s string
+		for valid := iter.First(); valid; valid = iter.Next() {
+			s += fmt.Sprintf("%s:%s ", iter.Key(), iter.Value())
+		}
+		require.NoError(t, iter.Close())
+		return s
+	}
+	numTables := func() int {
+		t.Helper()
+		var n int
+		for _, l := range d.Metrics().Levels {
+			n += int(l.NumFiles)
+		}
+		return n
+	}
+
+	// Spread the existing data across the LSM and the memtable.
+	require.NoError(t, d.Set([]byte("a"), []byte("old"), nil))
+	require.NoError(t, d.Set([]byte("b"), []byte("old"), nil))
+	require.NoError(t, d.Flush())
+	require.NoError(t, d.Compact([]byte("a"), []byte("c"), false))
+	require.NoError(t, d.Set([]byte("c"), []byte("old"), nil))
+	require.NoError(t, d.Flush())
+	require.NoError(t, d.Set([]byte("d"), []byte("old"), nil))
+	require.Equal(t, "a:old b:old c:old d:old ", scan(d))
+
+	iter := d.NewIter(nil)
+	snap := d.NewSnapshot()
+	writeTable("ext1", "b", "x")
+	writeTable("ext2", "y")
+	require.NoError(t, d.ReplaceAll([]string{"ext1", "ext2"}))
+	require.Equal(t, "b:new x:new y:new ", scan(d))
+	require.Equal(t, 2, numTables())
+	m := d.Metrics()
+	require.Equal(t, int64(2), m.Levels[numLevels-1].NumFiles)
+	require.Equal(t, uint64(2), m.Levels[numLevels-1].TablesIngested)
+
+	// The input paths are removed.
+	_, err = mem.Stat("ext1")
+	require.True(t, oserror.IsNotExist(err), "%v", err)
+
+	// Iterators opened before the replacement continue to read the old
+	// contents, while snapshots observe neither.
+	var s string
+	for valid := iter.First(); valid; valid = iter.Next() {
+		s += fmt.Sprintf("%s:%s ", iter.Key(), iter.Value())
+	}
+	require.Equal(t, "a:old b:old c:old d:old ", s)
+	require.Equal(t, "", scan(snap))
+	require.NoError(t, snap.Close())
+
+	// The replaced sstables are obsolete once no longer referenced.
+	require.Less(t, int64(0), d.Metrics().Table.ZombieCount)
+	require.NoError(t, iter.Close())
+	// Obsolete sstables are deleted in the background.
+	for i := 0; d.Metrics().Table.ZombieCount > 0; i++ {
+		require.Less(t, i, 1000)
+		time.Sleep(time.Millisecond)
+	}
+
+	// Writes after the replacement are applied on top of the new contents, and
+	// survive a subsequent flush and compaction.
+	require.NoError(t, d.Set([]byte("c"), []byte("newer"), nil))
+	require.NoError(t, d.Flush())
+	require.NoError(t, d.Compact([]byte("a"), []byte("z"), false))
+	require.Equal(t, "b:new c:newer x:new y:new ", scan(d))
+
+	// Replacing with no sstables deletes the entire contents of the DB.
+	require.NoError(t, d.Set([]byte("z"), []byte("mem"), nil))
+	require.NoError(t, d.ReplaceAll(nil))
+	require.Equal(t, "", scan(d))
+	require.Equal(t, 0, numTables())
+	require.NoError(t, d.Set([]byte("a"), []byte("after"), nil))
+	require.Equal(t, "a:after ", scan(d))
+}
@@ -0,0 +1,63 @@
+// Copyright 2026 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package pebble
+
+// seekCache caches the results of an Iterator's recent SeekGE calls, keyed by
+// the seek key. See IterOptions.SeekCacheSize.
+//
+// The cache holds at most len(entries) results, and replaces them in FIFO
+// order. The buffers of replaced entries are reused.
+type seekCache struct {
+	entries []seekCacheEntry
+	// n is the number of entries in use.
+	n int
+	// next is the index of the entry that will be replaced next.
+	next int
+}
+
+// seekCacheEntry holds the result of a SeekGE(seekKey): the key and value the
+// iterator was positioned at if valid, or nothing if the seek exhausted the
+// iterator.
+type seekCacheEntry struct {
+	seekKey []byte
+	key     []byte
+	value   []byte
+	valid   bool
+}
+
+func newSeekCache(size int) *seekCache {
+	return &seekCache{entries: make([]seekCacheEntry, size)}
+}
+
+// get returns the entry for seekKey, or nil if there is none.
+func (c *seekCache) get(equal Equal, seekKey []byte) *seekCacheEntry {
+	for j := 0; j < c.n; j++ {
+		if e := &c.entries[j]; equal(e.seekKey, seekKey) {
+			return e
+		}
+	}
+	return nil
+}
+
+// add records the result of a SeekGE(seekKey). The key and value are copied.
+func (c *seekCache) add(seekKey, key, value []byte, valid bool) {
+	e := &c.entries[c.next]
+	e.seekKey = append(e.seekKey[:0], seekKey...)
+	e.valid = valid
+	if valid {
+		e.key = append(e.key[:0], key...)
+		e.value = append(e.value[:0], value...)
+	}
+	c.next = (c.next + 1) % len(c.entries)
+	if c.n < len(c.entries) {
+		c.n++
+	}
+}
+
+// reset empties the cache.
+func (c *seekCache) reset() {
+	c.n = 0
+	c.next = 0
+}
@@ -7,6 +7,7 @@ package pebble
 import (
 	"io"
 	"math"
+	"time"
 )
 
 // Snapshot provides a read-only point-in-time view of the DB state.
@@ -38,6 +39,53 @@ func (s *Snapshot) Get(key []byte) ([]byte, io.Closer, error) {
 	return s.db.getInternal(key, nil /* batch */, s)
 }
 
+// GetLatestVersion returns the newest version of the given prefix visible to
+// the snapshot. See DB.GetLatestVersion.
+func (s *Snapshot) GetLatestVersion(prefix []byte) (key, value []byte, closer io.Closer, err error) {
+	if s.db == nil {
+		panic(ErrClosed)
+	}
+	return s.db.getLatestVersionInternal(prefix, s)
+}
+
+// GetMergeOperands returns the operands that would be merged to produce the
+// value for the given key at the snapshot, without invoking the Merger. See
+// DB.GetMergeOperands.
+func (s *Snapshot) GetMergeOperands(key []byte) ([][]byte, error) {
+	if s.db == nil {
+		panic(ErrClosed)
+	}
+	return s.db.getMergeOperandsInternal(key, s)
+}
+
+// RangeDeletions returns an iterator over the range deletion tombstones
+// overlapping the range [lower, upper) that are visible to the snapshot. See
+// DB.RangeDeletions.
+func (s *Snapshot) RangeDeletions(lower, upper []byte) *RangeDeletionIter {
+	if s.db == nil {
+		panic(ErrClosed)
+	}
+	return s.db.newRangeDeletionIter(s.db.loadReadState(), lower, upper, s.seqNum)
+}
+
+// IsRangeDeleted returns true if the range [lower, upper) is deleted by range
+// deletion tombstones visible to the snapshot. See DB.IsRangeDeleted.
+func (s *Snapshot) IsRangeDeleted(lower, upper []byte) (bool, error) {
+	if s.db == nil {
+		panic(ErrClosed)
+	}
+	return s.db.isRangeDeleted(lower, upper, s)
+}
+
+// NewInternalIter returns an InternalIter over the raw internal point keys
+// visible to the snapshot. See DB.NewInternalIter.
+func (s *Snapshot) NewInternalIter(o *InternalIterOptions) *InternalIter {
+	if s.db == nil {
+		panic(ErrClosed)
+	}
+	return s.db.newInternalIter(s.db.loadReadState(), o, s.seqNum)
+}
+
 // NewIter returns an iterator that is unpositioned (Iterator.Valid() will
 // return false). The iterator can be positioned via a call to SeekGE,
 // SeekLT, First or Last.
@@ -45,7 +93,26 @@ func (s *Snapshot) NewIter(o *IterOptions) *Iterator {
 	if s.db == nil {
 		panic(ErrClosed)
 	}
-	return s.db.newIterInternal(nil /* batch */, s, o)
+	return s.db.newIterInternal(nil /* batch */, s, o, 0 /* minSeqNum */)
+}
+
+// NewIncrementalIter returns an iterator over the point keys in [lower,
+// upper) written at or after the sequence number sinceSeqNum, as of the
+// snapshot. See DB.NewIncrementalIter.
+func (s *Snapshot) NewIncrementalIter(sinceSeqNum uint64, lower, upper []byte) *Iterator {
+	if s.db == nil {
+		panic(ErrClosed)
+	}
+	o := &IterOptions{LowerBound: lower, UpperBound: upper}
+	return s.db.newIterInternal(nil /* batch */, s, o, sinceSeqNum)
+}
+
+// SeqNum returns the sequence number at which the snapshot reads: the
+// snapshot observes the writes with smaller sequence numbers. An export of
+// the snapshot may use its SeqNum as the sinceSeqNum of the next incremental
+// export. See DB.NewIncrementalIter.
+func (s *Snapshot) SeqNum() uint64 {
+	return s.seqNum
 }
 
 // Close closes the snapshot, releasing its resources. Close must be called.
@@ -69,6 +136,89 @@ func (s *Snapshot) Close() error {
 	return nil
 }
 
+// NewBoundedStalenessIter returns an iterator that is unpositioned
+// (Iterator.Valid() will return false), like NewIter, but that may observe a
+// slightly stale view of the DB in exchange for sharing a snapshot with other
+// such iterators, rather than pinning the current state of the DB.
+//
+// The DB maintains a single snapshot shared by all bounded staleness
+// iterators. An iterator created with a given maxLag observes that snapshot
+// if it was created no more than maxLag before the call to
+// NewBoundedStalenessIter, and otherwise replaces it with a new snapshot of
+// the current DB state. Precisely, the iterator observes a consistent,
+// point-in-time view of the DB that:
+//
+//   - Includes every write made visible (eg, by committing a batch) before
+//     the time maxLag prior to the call to NewBoundedStalenessIter.
+//   - May or may not include writes made visible after that time, and never
+//     includes writes made visible after the call.
+//
+// A maxLag of zero or less provides no staleness, and is equivalent to
+// NewIter.
+//
+// Once created, an iterator's view remains stable until it is closed,
+// regardless of the shared snapshot being replaced or released. The shared
+// snapshot is released once it is older than the largest maxLag it was
+// shared with, so that it doesn't prevent compactions from dropping the keys
+// and tombstones it pins for longer than necessary. As with NewIter, a
+// long-lived iterator prevents the memtables and sstables it references from
+// being deleted.
+func (d *DB) NewBoundedStalenessIter(maxLag time.Duration, o *IterOptions) *Iterator {
+	if err := d.closed.Load(); err != nil {
+		panic(err)
+	}
+	if maxLag <= 0 {
+		return d.NewIter(o)
+	}
+
+	bs := &d.boundedStalenessSnapshot
+	bs.Lock()
+	defer bs.Unlock()
+	now := d.timeNow()
+	if bs.snapshot == nil || now.Sub(bs.created) > maxLag {
+		if bs.snapshot != nil {
+			_ = bs.snapshot.Close()
+			bs.timer.Stop()
+		}
+		bs.created = now
+		bs.snapshot = d.NewSnapshot()
+		bs.releaseAt = now
+		snapshot := bs.snapshot
+		bs.timer = time.AfterFunc(maxLag, func() {
+			d.releaseBoundedStalenessSnapshot(snapshot)
+		})
+	}
+	if releaseAt := bs.created.Add(maxLag); releaseAt.After(bs.releaseAt) {
+		bs.releaseAt = releaseAt
+	}
+	// The iterator's view is pinned by its readState from this point on, so
+	// it remains stable after the snapshot is closed.
+	return bs.snapshot.NewIter(o)
+}
+
+// releaseBoundedStalenessSnapshot releases the snapshot shared by bounded
+// staleness iterators. If ifCurrent is non-nil, the snapshot is only released
+// if it is ifCurrent and its release time has passed; otherwise the release is
+// rescheduled.
+func (d *DB) releaseBoundedStalenessSnapshot(ifCurrent *Snapshot) {
+	bs := &d.boundedStalenessSnapshot
+	bs.Lock()
+	defer bs.Unlock()
+	if bs.snapshot == nil || (ifCurrent != nil && bs.snapshot != ifCurrent) {
+		return
+	}
+	if ifCurrent != nil {
+		if wait := bs.releaseAt.Sub(d.timeNow()); wait > 0 {
+			bs.timer.Reset(wait)
+			return
+		}
+	}
+	bs.timer.Stop()
+	_ = bs.snapshot.Close()
+	bs.snapshot = nil
+	bs.timer = nil
+}
+
 type snapshotList struct {
 	root Snapshot
 }
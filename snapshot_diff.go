@@ -0,0 +1,175 @@
+// Copyright 2026 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package pebble
+
+import (
+	"bytes"
+
+	"github.com/cockroachdb/errors"
+)
+
+// SnapshotDiffIter iterates over the point keys whose state differs between
+// two snapshots of a DB, in key order. See SnapshotDiff.
+type SnapshotDiffIter struct {
+	cmp          Compare
+	older, newer *Iterator
+	// olderValid and newerValid are true if the corresponding iterator is
+	// positioned at a key that hasn't yet been compared.
+	olderValid, newerValid bool
+	cur                    Difference
+	valid                  bool
+	err                    error
+}
+
+// SnapshotDiff returns an iterator over the point keys in [lower, upper) whose
+// state differs between the older and newer snapshots of the same DB. Either
+// of lower or upper may be nil, in which case the range is unbounded in that
+// direction. The returned iterator is unpositioned, and must be closed by the
+// caller before either snapshot is closed.
+//
+// The differences are surfaced as the Difference values reported by
+// CompareDBs, with A the older snapshot and B the newer one: a key written
+// after the older snapshot is DifferenceOnlyInB, a key deleted after the
+// older snapshot is DifferenceOnlyInA, and a key whose value changed is
+// DifferenceValue. As with CompareDBs, the comparison is of the state visible
+// to iterators: a key overwritten with its existing value, or written and
+// deleted again between the snapshots, does not differ. Range keys are not
+// compared.
+//
+// SnapshotDiff is a merge-scan of two iterators, one reading each snapshot.
+// Its cost is that of iterating over every live key in the range in both
+// snapshots, regardless of how few of them differ: every key is read twice,
+// and the iterators hold references to the memtables and sstables of both
+// snapshots until the iterator is closed. To find the keys written after a
+// snapshot without comparing values, NewIncrementalIter may be cheaper.
+func SnapshotDiff(older, newer *Snapshot, lower, upper []byte) *SnapshotDiffIter {
+	if older.db == nil || newer.db == nil {
+		panic(ErrClosed)
+	}
+	i := &SnapshotDiffIter{cmp: older.db.cmp}
+	switch {
+	case older.db != newer.db:
+		i.err = errors.New("pebble: cannot diff snapshots of different DBs")
+		return i
+	case older.seqNum > newer.seqNum:
+		i.err = errors.Errorf("pebble: snapshot at %d is newer than snapshot at %d",
+			errors.Safe(older.seqNum), errors.Safe(newer.seqNum))
+		return i
+	case lower != nil && upper != nil && i.cmp(lower, upper) >= 0:
+		i.err = errors.Errorf("pebble: lower bound %s must be less than upper bound %s",
+			older.db.opts.Comparer.FormatKey(lower), older.db.opts.Comparer.FormatKey(upper))
+		return i
+	}
+	o := &IterOptions{KeyTypes: IterKeyTypePointsOnly, LowerBound: lower, UpperBound: upper}
+	i.older, i.newer = older.NewIter(o), newer.NewIter(o)
+	return i
+}
+
+// First moves the iterator to the first key that differs, returning true if
+// the iterator is positioned at a valid difference.
+func (i *SnapshotDiffIter) First() bool {
+	if i.err != nil {
+		return false
+	}
+	i.olderValid, i.newerValid = i.older.First(), i.newer.First()
+	return i.findNext()
+}
+
+// SeekGE moves the iterator to the first key that differs and is greater
+// than or equal to the provided key, returning true if the iterator is
+// positioned at a valid difference.
+func (i *SnapshotDiffIter) SeekGE(key []byte) bool {
+	if i.err != nil {
+		return false
+	}
+	i.olderValid, i.newerValid = i.older.SeekGE(key), i.newer.SeekGE(key)
+	return i.findNext()
+}
+
+// Next moves the iterator to the next key that differs, returning true if the
+// iterator is positioned at a valid difference.
+func (i *SnapshotDiffIter) Next() bool {
+	if !i.valid {
+		return false
+	}
+	// Step past the key of the current difference.
+	switch i.cur.Kind {
+	case DifferenceOnlyInA:
+		i.olderValid = i.older.Next()
+	case DifferenceOnlyInB:
+		i.newerValid = i.newer.Next()
+	default:
+		i.olderValid, i.newerValid = i.older.Next(), i.newer.Next()
+	}
+	return i.findNext()
+}
+
+// findNext compares the keys at the iterators' positions, advancing past the
+// keys that don't differ until it finds one that does.
+func (i *SnapshotDiffIter) findNext() bool {
+	i.valid = false
+	i.cur = Difference{}
+	for i.olderValid || i.newerValid {
+		var v int
+		switch {
+		case !i.newerValid:
+			v = -1
+		case !i.olderValid:
+			v = +1
+		default:
+			v = i.cmp(i.older.Key(), i.newer.Key())
+		}
+		switch {
+		case v < 0:
+			i.cur = Difference{Kind: DifferenceOnlyInA, Key: i.older.Key(), AValue: i.older.Value()}
+		case v > 0:
+			i.cur = Difference{Kind: DifferenceOnlyInB, Key: i.newer.Key(), BValue: i.newer.Value()}
+		case !bytes.Equal(i.older.Value(), i.newer.Value()):
+			i.cur = Difference{
+				Kind:   DifferenceValue,
+				Key:    i.older.Key(),
+				AValue: i.older.Value(),
+				BValue: i.newer.Value(),
+			}
+		default:
+			i.olderValid, i.newerValid = i.older.Next(), i.newer.Next()
+			continue
+		}
+		i.valid = true
+		return true
+	}
+	if err := firstError(i.older.Error(), i.newer.Error()); err != nil {
+		i.err = err
+	}
+	return false
+}
+
+// Valid returns true if the iterator is positioned at a valid difference.
+func (i *SnapshotDiffIter) Valid() bool {
+	return i.valid
+}
+
+// Difference returns the difference at the iterator's current position. The
+// returned Difference's slices are only valid until the next positioning
+// method is called.
+func (i *SnapshotDiffIter) Difference() Difference {
+	return i.cur
+}
+
+// Error returns any accumulated error.
+func (i *SnapshotDiffIter) Error() error {
+	return i.err
+}
+
+// Close closes the iterator, releasing the memtables and sstables it
+// references. It returns any accumulated error.
+func (i *SnapshotDiffIter) Close() error {
+	err := i.err
+	if i.older != nil {
+		err = firstError(err, closeIters(i.older, i.newer))
+		i.older, i.newer = nil, nil
+	}
+	return err
+}
@@ -0,0 +1,80 @@
+// Copyright 2026 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package pebble
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/cockroachdb/pebble/vfs"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshotDiff(t *testing.T) {
+	d, err := Open("", &Options{FS: vfs.NewMem()})
+	require.NoError(t, err)
+	defer func() { require.NoError(t, d.Close()) }()
+
+	for i := 0; i < 100; i++ {
+		k := []byte(fmt.Sprintf("k%03d", i))
+		require.NoError(t, d.Set(k, k, nil))
+	}
+	require.NoError(t, d.Flush())
+	s1 := d.NewSnapshot()
+	defer func() { require.NoError(t, s1.Close()) }()
+
+	// Changes of every kind, some of them flushed, along with changes that
+	// leave the visible state unchanged.
+	require.NoError(t, d.Set([]byte("k010"), []byte("x"), nil))
+	require.NoError(t, d.Delete([]byte("k020"), nil))
+	require.NoError(t, d.Flush())
+	require.NoError(t, d.DeleteRange([]byte("k050"), []byte("k053"), nil))
+	require.NoError(t, d.Set([]byte("k060"), []byte("k060"), nil))
+	require.NoError(t, d.Set([]byte("k070a"), []byte("y"), nil))
+	require.NoError(t, d.Set([]byte("k080a"), []byte("z"), nil))
+	require.NoError(t, d.Delete([]byte("k080a"), nil))
+	s2 := d.NewSnapshot()
+	defer func() { require.NoError(t, s2.Close()) }()
+
+	// Changes after the newer snapshot are not observed.
+	require.NoError(t, d.Set([]byte("k090"), []byte("w"), nil))
+
+	diff := func(older, newer *Snapshot, lower, upper []byte) []string {
+		iter := SnapshotDiff(older, newer, lower, upper)
+		var s []string
+		for valid := iter.First(); valid; valid = iter.Next() {
+			s = append(s, iter.Difference().String())
+		}
+		require.NoError(t, iter.Close())
+		return s
+	}
+	expected := []string{
+		`value "k010": a="k010" b="x"`,
+		`only-in-a "k020": a="k020" b=""`,
+		`only-in-a "k050": a="k050" b=""`,
+		`only-in-a "k051": a="k051" b=""`,
+		`only-in-a "k052": a="k052" b=""`,
+		`only-in-b "k070a": a="" b="y"`,
+	}
+	require.Equal(t, expected, diff(s1, s2, nil, nil))
+	require.Equal(t, expected[1:4], diff(s1, s2, []byte("k015"), []byte("k052")))
+	require.Empty(t, diff(s2, s2, nil, nil))
+
+	iter := SnapshotDiff(s1, s2, nil, nil)
+	require.True(t, iter.SeekGE([]byte("k051")))
+	require.Equal(t, expected[3], iter.Difference().String())
+	require.True(t, iter.SeekGE([]byte("k053")))
+	require.Equal(t, expected[5], iter.Difference().String())
+	require.False(t, iter.Next())
+	require.True(t, iter.First())
+	require.Equal(t, expected[0], iter.Difference().String())
+	require.NoError(t, iter.Close())
+
+	// The snapshots must be ordered.
+	iter = SnapshotDiff(s2, s1, nil, nil)
+	require.False(t, iter.First())
+	require.Error(t, iter.Error())
+	require.Error(t, iter.Close())
+}
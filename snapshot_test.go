@@ -348,3 +348,67 @@ func TestNewSnapshotRace(t *testing.T) {
 	wg.Wait()
 	require.NoError(t, d.Close())
 }
+
+func TestNewBoundedStalenessIter(t *testing.T) {
+	d, err := Open("", &Options{FS: vfs.NewMem()})
+	require.NoError(t, err)
+	defer func() { require.NoError(t, d.Close()) }()
+	now := time.Unix(0, 0)
+	d.timeNow = func() time.Time { return now }
+
+	read := func(iter *Iterator) string {
+		require.True(t, iter.SeekGE([]byte("a")))
+		return string(iter.Value())
+	}
+	numSnapshots := func() int {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		return d.mu.snapshots.count()
+	}
+
+	require.NoError(t, d.Set([]byte("a"), []byte("1"), nil))
+	iter1 := d.NewBoundedStalenessIter(time.Minute, nil)
+	require.Equal(t, "1", read(iter1))
+	require.Equal(t, 1, numSnapshots())
+
+	// Within the lag, the shared snapshot is reused.
+	require.NoError(t, d.Set([]byte("a"), []byte("2"), nil))
+	now = now.Add(30 * time.Second)
+	iter2 := d.NewBoundedStalenessIter(time.Minute, nil)
+	require.Equal(t, "1", read(iter2))
+	require.Equal(t, 1, numSnapshots())
+
+	// A smaller lag requires a fresher snapshot, which replaces the shared
+	// snapshot. Existing iterators are unaffected.
+	iter3 := d.NewBoundedStalenessIter(10*time.Second, nil)
+	require.Equal(t, "2", read(iter3))
+	require.Equal(t, 1, numSnapshots())
+	require.Equal(t, "1", read(iter1))
+
+	// A lag of zero reads the current state.
+	require.NoError(t, d.Set([]byte("a"), []byte("3"), nil))
+	iter4 := d.NewBoundedStalenessIter(0, nil)
+	require.Equal(t, "3", read(iter4))
+	for _, iter := range []*Iterator{iter1, iter2, iter3, iter4} {
+		require.NoError(t, iter.Close())
+	}
+
+	// The shared snapshot is released once it is older than the largest lag it
+	// was shared with.
+	iter5 := d.NewBoundedStalenessIter(time.Minute, nil)
+	require.Equal(t, "2", read(iter5))
+	require.NoError(t, iter5.Close())
+	d.boundedStalenessSnapshot.Lock()
+	snapshot := d.boundedStalenessSnapshot.snapshot
+	d.boundedStalenessSnapshot.Unlock()
+	now = now.Add(59 * time.Second)
+	d.releaseBoundedStalenessSnapshot(snapshot)
+	require.Equal(t, 1, numSnapshots())
+	now = now.Add(time.Second)
+	d.releaseBoundedStalenessSnapshot(snapshot)
+	require.Equal(t, 0, numSnapshots())
+
+	iter6 := d.NewBoundedStalenessIter(time.Minute, nil)
+	require.Equal(t, "3", read(iter6))
+	require.NoError(t, iter6.Close())
+}
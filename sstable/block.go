@@ -328,6 +328,27 @@ func (i *blockIter) invalidate() {
 	i.data = nil
 }
 
+// initAt initializes the iterator to iterate over the block of src,
+// positioned at the entry src is positioned at, such that Next returns the
+// entry following it. The block is not retained beyond the lifetime of src's
+// reference to it.
+func (i *blockIter) initAt(src *blockIter) error {
+	if err := i.init(src.cmp, src.data, src.globalSeqNum); err != nil {
+		return err
+	}
+	i.offset = src.offset
+	i.nextOffset = src.nextOffset
+	i.fullKey = append(i.fullKey[:0], src.key...)
+	return nil
+}
+
+// unposition leaves the iterator positioned at no entry, such that valid()
+// returns false, without releasing the block it has loaded.
+func (i *blockIter) unposition() {
+	i.offset = 0
+	i.restarts = 0
+}
+
 // isDataInvalidated returns true when the blockIter has been invalidated
 // using an invalidate call. NB: this is different from blockIter.Valid
 // which is part of the InternalIterator implementation.
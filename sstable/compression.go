@@ -33,14 +33,20 @@ func decompressedLen(blockType blockType, b []byte) (int, int, error) {
 	}
 }
 
-func decompressInto(blockType blockType, compressed []byte, buf []byte) ([]byte, error) {
+func decompressInto(
+	blockType blockType, compressed []byte, buf []byte, dict *zstdDecoder,
+) ([]byte, error) {
 	var result []byte
 	var err error
 	switch blockType {
 	case snappyCompressionBlockType:
 		result, err = snappy.Decode(buf, compressed)
 	case zstdCompressionBlockType:
-		result, err = decodeZstd(buf, compressed)
+		if dict != nil {
+			result, err = dict.decode(buf, compressed)
+		} else {
+			result, err = decodeZstd(buf, compressed)
+		}
 	}
 	if err != nil {
 		return nil, base.MarkCorruptionError(err)
@@ -53,7 +59,11 @@ func decompressInto(blockType blockType, compressed []byte, buf []byte) ([]byte,
 }
 
 // decompressBlock decompresses an SST block, with space allocated from a cache.
-func decompressBlock(cache *cache.Cache, blockType blockType, b []byte) (*cache.Value, error) {
+// The decoder of the sstable's compression dictionary, dict, is used for zstd
+// compressed blocks, and may be nil.
+func decompressBlock(
+	cache *cache.Cache, blockType blockType, b []byte, dict *zstdDecoder,
+) (*cache.Value, error) {
 	if blockType == noCompressionBlockType {
 		return nil, nil
 	}
@@ -68,15 +78,18 @@ func decompressBlock(cache *cache.Cache, blockType blockType, b []byte) (*cache.
 	// Allocate sufficient space from the cache.
 	decoded := cache.Alloc(decodedLen)
 	decodedBuf := decoded.Buf()
-	if _, err := decompressInto(blockType, b, decodedBuf); err != nil {
+	if _, err := decompressInto(blockType, b, decodedBuf, dict); err != nil {
 		cache.Free(decoded)
+		return nil, err
 	}
 	return decoded, nil
 }
 
-// compressBlock compresses an SST block, using compressBuf as the desired destination.
+// compressBlock compresses an SST block, using compressBuf as the desired
+// destination. The compression dictionary dict is used for zstd compression,
+// and may be nil.
 func compressBlock(
-	compression Compression, b []byte, compressedBuf []byte,
+	compression Compression, b []byte, compressedBuf []byte, dict []byte,
 ) (blockType blockType, compressed []byte) {
 	switch compression {
 	case SnappyCompression:
@@ -91,8 +104,21 @@ func compressBlock(
 	varIntLen := binary.PutUvarint(compressedBuf, uint64(len(b)))
 	switch compression {
 	case ZstdCompression:
-		return zstdCompressionBlockType, encodeZstd(compressedBuf, varIntLen, b)
+		return zstdCompressionBlockType, encodeZstd(compressedBuf, varIntLen, b, dict)
 	default:
 		return noCompressionBlockType, b
 	}
 }
+
+// zstdDictMagic is the magic number at the start of a dictionary in the zstd
+// dictionary format.
+const zstdDictMagic = 0xEC30A437
+
+// validateCompressionDict returns an error if dict is not a dictionary in the
+// zstd dictionary format.
+func validateCompressionDict(dict []byte) error {
+	if len(dict) < 8 || binary.LittleEndian.Uint32(dict) != zstdDictMagic {
+		return errors.New("pebble/table: compression dictionary is not in the zstd dictionary format")
+	}
+	return nil
+}
@@ -7,10 +7,38 @@
 
 package sstable
 
+// The ZDICT functions are provided by the zstd library bundled with
+// github.com/DataDog/zstd.
+
+/*
+#include <stddef.h>
+
+size_t ZDICT_trainFromBuffer(void* dictBuffer, size_t dictBufferCapacity,
+	const void* samplesBuffer, const size_t* samplesSizes, unsigned nbSamples);
+unsigned ZDICT_isError(size_t errorCode);
+const char* ZDICT_getErrorName(size_t errorCode);
+
+typedef struct ZSTD_DCtx_s ZSTD_DCtx;
+typedef struct ZSTD_DDict_s ZSTD_DDict;
+
+ZSTD_DCtx* ZSTD_createDCtx(void);
+size_t ZSTD_freeDCtx(ZSTD_DCtx* dctx);
+ZSTD_DDict* ZSTD_createDDict(const void* dictBuffer, size_t dictSize);
+size_t ZSTD_freeDDict(ZSTD_DDict* ddict);
+size_t ZSTD_decompress_usingDDict(ZSTD_DCtx* dctx, void* dst, size_t dstCapacity,
+	const void* src, size_t srcSize, const ZSTD_DDict* ddict);
+unsigned ZSTD_isError(size_t code);
+const char* ZSTD_getErrorName(size_t code);
+*/
+import "C"
+
 import (
 	"bytes"
+	"runtime"
+	"unsafe"
 
 	"github.com/DataDog/zstd"
+	"github.com/cockroachdb/errors"
 )
 
 // decodeZstd decompresses b with the Zstandard algorithm.
@@ -20,15 +48,107 @@ func decodeZstd(decodedBuf, b []byte) ([]byte, error) {
 	return zstd.Decompress(decodedBuf, b)
 }
 
+// zstdDecoder decompresses blocks compressed with a zstd compression
+// dictionary. The dictionary is digested once, when the decoder is created. A
+// zstdDecoder may be used concurrently, and must be closed to release its
+// memory.
+type zstdDecoder struct {
+	ddict *C.ZSTD_DDict
+	// dctxs holds the decompression contexts not in use. A context may only
+	// be used by one goroutine at a time.
+	dctxs chan *C.ZSTD_DCtx
+}
+
+func newZstdDecoder(dict []byte) (*zstdDecoder, error) {
+	ddict := C.ZSTD_createDDict(unsafe.Pointer(&dict[0]), C.size_t(len(dict)))
+	if ddict == nil {
+		return nil, errors.New("pebble/table: invalid compression dictionary")
+	}
+	return &zstdDecoder{ddict: ddict, dctxs: make(chan *C.ZSTD_DCtx, runtime.GOMAXPROCS(0))}, nil
+}
+
+// decode decompresses b into decodedBuf, which must be large enough to hold
+// the decompressed block. On success, it returns the decoded byte slice.
+func (d *zstdDecoder) decode(decodedBuf, b []byte) ([]byte, error) {
+	var dctx *C.ZSTD_DCtx
+	select {
+	case dctx = <-d.dctxs:
+	default:
+		if dctx = C.ZSTD_createDCtx(); dctx == nil {
+			return nil, errors.New("pebble/table: could not allocate zstd decompression context")
+		}
+	}
+	var dst, src unsafe.Pointer
+	if len(decodedBuf) > 0 {
+		dst = unsafe.Pointer(&decodedBuf[0])
+	}
+	if len(b) > 0 {
+		src = unsafe.Pointer(&b[0])
+	}
+	n := C.ZSTD_decompress_usingDDict(dctx, dst, C.size_t(len(decodedBuf)), src, C.size_t(len(b)), d.ddict)
+	select {
+	case d.dctxs <- dctx:
+	default:
+		C.ZSTD_freeDCtx(dctx)
+	}
+	if C.ZSTD_isError(n) != 0 {
+		return nil, errors.Newf("pebble/table: zstd decompression: %s", C.GoString(C.ZSTD_getErrorName(n)))
+	}
+	return decodedBuf[:n], nil
+}
+
+// close releases the memory held by the decoder.
+func (d *zstdDecoder) close() {
+	for {
+		select {
+		case dctx := <-d.dctxs:
+			C.ZSTD_freeDCtx(dctx)
+		default:
+			C.ZSTD_freeDDict(d.ddict)
+			return
+		}
+	}
+}
+
 // encodeZstd compresses b with the Zstandard algorithm at default compression
-// level (level 3). It reuses the preallocated capacity of compressedBuf if it
-// is sufficient. The subslice `compressedBuf[:varIntLen]` should already encode
-// the length of `b` before calling encodeZstd. It returns the encoded byte
-// slice, including the `compressedBuf[:varIntLen]` prefix.
-func encodeZstd(compressedBuf []byte, varIntLen int, b []byte) []byte {
+// level (level 3), using the dictionary dict if it is non-nil. It reuses the
+// preallocated capacity of compressedBuf if it is sufficient. The subslice
+// `compressedBuf[:varIntLen]` should already encode the length of `b` before
+// calling encodeZstd. It returns the encoded byte slice, including the
+// `compressedBuf[:varIntLen]` prefix.
+func encodeZstd(compressedBuf []byte, varIntLen int, b []byte, dict []byte) []byte {
 	buf := bytes.NewBuffer(compressedBuf[:varIntLen])
-	writer := zstd.NewWriterLevel(buf, 3)
+	writer := zstd.NewWriterLevelDict(buf, 3, dict)
 	writer.Write(b)
 	writer.Close()
 	return buf.Bytes()
 }
+
+// TrainCompressionDict trains a zstd compression dictionary of at most
+// maxSize bytes from the provided samples, for use as
+// WriterOptions.CompressionDict. The samples should be representative of the
+// keys and values that will be written, and typically total 100 times the
+// size of the dictionary. Training fails if too few samples are provided.
+func TrainCompressionDict(samples [][]byte, maxSize int) ([]byte, error) {
+	var total int
+	for _, s := range samples {
+		total += len(s)
+	}
+	if len(samples) == 0 || total == 0 || maxSize <= 0 {
+		return nil, errors.New("pebble/table: no samples to train compression dictionary")
+	}
+	concat := make([]byte, 0, total)
+	sizes := make([]C.size_t, len(samples))
+	for i, s := range samples {
+		concat = append(concat, s...)
+		sizes[i] = C.size_t(len(s))
+	}
+	dict := make([]byte, maxSize)
+	n := C.ZDICT_trainFromBuffer(unsafe.Pointer(&dict[0]), C.size_t(maxSize),
+		unsafe.Pointer(&concat[0]), &sizes[0], C.unsigned(len(samples)))
+	if C.ZDICT_isError(n) != 0 {
+		return nil, errors.Newf("pebble/table: training compression dictionary: %s",
+			C.GoString(C.ZDICT_getErrorName(n)))
+	}
+	return dict[:n], nil
+}
@@ -7,7 +7,10 @@
 
 package sstable
 
-import "github.com/klauspost/compress/zstd"
+import (
+	"github.com/cockroachdb/errors"
+	"github.com/klauspost/compress/zstd"
+)
 
 // decodeZstd decompresses b with the Zstandard algorithm.
 // It reuses the preallocated capacity of decodedBuf if it is sufficient.
@@ -18,13 +21,52 @@ func decodeZstd(decodedBuf, b []byte) ([]byte, error) {
 	return decoder.DecodeAll(b, decodedBuf[:0])
 }
 
+// zstdDecoder decompresses blocks compressed with a zstd compression
+// dictionary. The dictionary is loaded once, when the decoder is created. A
+// zstdDecoder may be used concurrently, and must be closed to release its
+// resources.
+type zstdDecoder struct {
+	decoder *zstd.Decoder
+}
+
+func newZstdDecoder(dict []byte) (*zstdDecoder, error) {
+	decoder, err := zstd.NewReader(nil, zstd.WithDecoderDicts(dict))
+	if err != nil {
+		return nil, err
+	}
+	return &zstdDecoder{decoder: decoder}, nil
+}
+
+// decode decompresses b into decodedBuf, which must be large enough to hold
+// the decompressed block. On success, it returns the decoded byte slice.
+func (d *zstdDecoder) decode(decodedBuf, b []byte) ([]byte, error) {
+	return d.decoder.DecodeAll(b, decodedBuf[:0])
+}
+
+// close releases the resources held by the decoder.
+func (d *zstdDecoder) close() {
+	d.decoder.Close()
+}
+
 // encodeZstd compresses b with the Zstandard algorithm at default compression
-// level (level 3). It reuses the preallocated capacity of compressedBuf if it
-// is sufficient. The subslice `compressedBuf[:varIntLen]` should already encode
-// the length of `b` before calling encodeZstd. It returns the encoded byte
-// slice, including the `compressedBuf[:varIntLen]` prefix.
-func encodeZstd(compressedBuf []byte, varIntLen int, b []byte) []byte {
-	encoder, _ := zstd.NewWriter(nil)
+// level (level 3), using the dictionary dict if it is non-nil. It reuses the
+// preallocated capacity of compressedBuf if it is sufficient. The subslice
+// `compressedBuf[:varIntLen]` should already encode the length of `b` before
+// calling encodeZstd. It returns the encoded byte slice, including the
+// `compressedBuf[:varIntLen]` prefix.
+func encodeZstd(compressedBuf []byte, varIntLen int, b []byte, dict []byte) []byte {
+	var opts []zstd.EOption
+	if dict != nil {
+		// The dictionary is validated by the Writer.
+		opts = append(opts, zstd.WithEncoderDict(dict))
+	}
+	encoder, _ := zstd.NewWriter(nil, opts...)
 	defer encoder.Close()
 	return encoder.EncodeAll(b, compressedBuf[:varIntLen])
 }
+
+// TrainCompressionDict trains a zstd compression dictionary. It requires cgo,
+// and returns an error in builds without cgo.
+func TrainCompressionDict(samples [][]byte, maxSize int) ([]byte, error) {
+	return nil, errors.New("pebble/table: training compression dictionaries requires cgo")
+}
@@ -0,0 +1,108 @@
+// Copyright 2026 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package sstable
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/cockroachdb/pebble/internal/cache"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompressionDict(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	values := make([][]byte, 5000)
+	for i := range values {
+		id := rng.Intn(1000000)
+		values[i] = []byte(fmt.Sprintf(
+			`{"user_id":%d,"name":"user-%d","email":"user%d@example.com","created":"2022-%02d-%02d","roles":["reader","writer"]}`,
+			id, id, id, 1+rng.Intn(12), 1+rng.Intn(28)))
+	}
+	dict, err := TrainCompressionDict(values[:2000], 8<<10)
+	if !useStandardZstdLib {
+		require.Error(t, err)
+		t.Skip("training compression dictionaries requires cgo")
+	}
+	require.NoError(t, err)
+
+	c := cache.New(1 << 20)
+	defer c.Unref()
+	writeTable := func(o WriterOptions) *Reader {
+		o.BlockSize = 512
+		o.TableFormat = TableFormatPebblev3
+		f := &memFile{}
+		w := NewWriter(f, o)
+		for i, v := range values {
+			require.NoError(t, w.Set([]byte(fmt.Sprintf("%06d", i)), v))
+		}
+		require.NoError(t, w.Close())
+		r, err := NewMemReader(f.Data(), ReaderOptions{Cache: c})
+		require.NoError(t, err)
+		return r
+	}
+	checkTable := func(r *Reader) {
+		iter, err := r.NewIter(nil /* lower */, nil /* upper */)
+		require.NoError(t, err)
+		var n int
+		for k, v := iter.First(); k != nil; k, v = iter.Next() {
+			require.Equal(t, fmt.Sprintf("%06d", n), string(k.UserKey))
+			require.Equal(t, values[n], v)
+			n++
+		}
+		require.NoError(t, iter.Close())
+		require.Equal(t, len(values), n)
+		require.NoError(t, r.ValidateBlockChecksums())
+	}
+
+	// Tables written with and without a dictionary may be read side by side,
+	// sharing a block cache.
+	noDict := writeTable(WriterOptions{Compression: ZstdCompression})
+	defer noDict.Close()
+	withDict := writeTable(WriterOptions{Compression: ZstdCompression, CompressionDict: dict})
+	defer withDict.Close()
+	checkTable(noDict)
+	checkTable(withDict)
+
+	l, err := noDict.Layout()
+	require.NoError(t, err)
+	require.Zero(t, l.CompressionDict.Length)
+	l, err = withDict.Layout()
+	require.NoError(t, err)
+	require.Equal(t, uint64(len(dict)), l.CompressionDict.Length)
+	// The dictionary is loaded into a decoder once, when the table is opened.
+	require.Nil(t, noDict.dictDecoder)
+	require.NotNil(t, withDict.dictDecoder)
+
+	// The dictionary is stored in the table, but more than pays for itself.
+	require.Less(t, withDict.Properties.DataSize+uint64(len(dict)), noDict.Properties.DataSize)
+
+	// The dictionary is ignored by other compression algorithms.
+	snappy := writeTable(WriterOptions{Compression: SnappyCompression, CompressionDict: dict})
+	defer snappy.Close()
+	checkTable(snappy)
+	l, err = snappy.Layout()
+	require.NoError(t, err)
+	require.Zero(t, l.CompressionDict.Length)
+
+	// Dictionaries must be in the zstd dictionary format.
+	w := NewWriter(&memFile{}, WriterOptions{
+		TableFormat:     TableFormatPebblev3,
+		Compression:     ZstdCompression,
+		CompressionDict: []byte("not a zstd dictionary"),
+	})
+	require.Error(t, w.Set([]byte("a"), nil))
+	require.Error(t, w.Close())
+
+	// Dictionaries require TableFormatPebblev3.
+	w = NewWriter(&memFile{}, WriterOptions{
+		TableFormat:     TableFormatPebblev2,
+		Compression:     ZstdCompression,
+		CompressionDict: dict,
+	})
+	require.NoError(t, w.Set([]byte("a"), values[0]))
+	require.Regexp(t, `minimum required version \(Pebble,v3\) for compression dictionaries`, w.Close())
+}
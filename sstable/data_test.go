@@ -46,6 +46,15 @@ func optsFromArgs(td *datadriven.TestData, writerOpts *WriterOptions) error {
 			if err != nil {
 				return err
 			}
+		case "max-inline-value-size":
+			if len(arg.Vals) != 1 {
+				return errors.Errorf("%s: arg %s expects 1 value", td.Cmd, arg.Key)
+			}
+			var err error
+			writerOpts.MaxInlineValueSize, err = strconv.Atoi(arg.Vals[0])
+			if err != nil {
+				return err
+			}
 		case "filter":
 			writerOpts.FilterPolicy = bloom.FilterPolicy(10)
 		case "comparer-split-4b-suffix":
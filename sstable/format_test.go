@@ -43,6 +43,18 @@ func TestTableFormat_RoundTrip(t *testing.T) {
 			version: 2,
 			want:    TableFormatPebblev2,
 		},
+		{
+			name:    "PebbleDBv3",
+			magic:   pebbleDBMagic,
+			version: 3,
+			want:    TableFormatPebblev3,
+		},
+		{
+			name:    "PebbleDBv4",
+			magic:   pebbleDBMagic,
+			version: 4,
+			want:    TableFormatPebblev4,
+		},
 		// Invalid cases.
 		{
 			name:    "Invalid RocksDB version",
@@ -53,8 +65,8 @@ func TestTableFormat_RoundTrip(t *testing.T) {
 		{
 			name:    "Invalid PebbleDB version",
 			magic:   pebbleDBMagic,
-			version: 3,
-			wantErr: "pebble/table: unsupported pebble format version 3",
+			version: 5,
+			wantErr: "pebble/table: unsupported pebble format version 5",
 		},
 		{
 			name:    "Unknown magic string",
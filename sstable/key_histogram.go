@@ -0,0 +1,190 @@
+// Copyright 2026 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package sstable
+
+import (
+	"encoding/binary"
+
+	"github.com/cockroachdb/pebble/internal/base"
+)
+
+// KeyHistogramPropertyCollectorName is the name of the table property
+// collector constructed by NewKeyHistogramPropertyCollector.
+const KeyHistogramPropertyCollectorName = "pebble.key-histogram"
+
+// KeyHistogram is a coarse, equi-count histogram of the point keys in an
+// sstable, as recorded by a KeyHistogramPropertyCollector.
+type KeyHistogram struct {
+	// Count is the number of point keys in the table, counting each version of
+	// a user key.
+	Count uint64
+	// Boundaries holds the user keys bounding the histogram's buckets, in
+	// increasing order. Bucket i contains approximately
+	// Count/(len(Boundaries)-1) keys, all within [Boundaries[i],
+	// Boundaries[i+1]]. The first boundary is the table's smallest point key,
+	// and the last is its largest, unless boundaries were truncated. If the
+	// table holds a single point key, Boundaries holds just that key.
+	Boundaries [][]byte
+}
+
+// EstimateCount estimates the number of point keys in the table within the
+// user key range [start, end), by adding the counts of the buckets contained
+// within the range and half of the counts of the buckets partially
+// overlapping it.
+func (h *KeyHistogram) EstimateCount(cmp Compare, start, end []byte) uint64 {
+	if len(h.Boundaries) == 0 {
+		return 0
+	}
+	if len(h.Boundaries) == 1 {
+		if cmp(h.Boundaries[0], start) >= 0 && cmp(h.Boundaries[0], end) < 0 {
+			return h.Count
+		}
+		return 0
+	}
+	var halves uint64
+	for i := 0; i+1 < len(h.Boundaries); i++ {
+		lower, upper := h.Boundaries[i], h.Boundaries[i+1]
+		switch {
+		case cmp(upper, start) < 0 || cmp(lower, end) >= 0:
+		case cmp(lower, start) >= 0 && cmp(upper, end) < 0:
+			halves += 2
+		default:
+			halves++
+		}
+	}
+	return halves * h.Count / uint64(2*(len(h.Boundaries)-1))
+}
+
+// KeyHistogramPropertyCollector is a table property collector recording a
+// KeyHistogram of the point keys in each sstable. The histogram may be read
+// through ReadKeyHistogram.
+//
+// The collector samples every one of a power of two of the table's keys,
+// doubling the sampling interval when the number of samples exceeds four times
+// the number of buckets, so that it retains memory proportional to the number
+// of buckets rather than to the size of the table.
+type KeyHistogramPropertyCollector struct {
+	buckets        int
+	maxBoundaryLen int
+	count          uint64
+	interval       uint64
+	// samples[i] holds the key with rank i*interval.
+	samples [][]byte
+	last    []byte
+}
+
+var _ TablePropertyCollector = (*KeyHistogramPropertyCollector)(nil)
+
+// NewKeyHistogramPropertyCollector returns a table property collector
+// recording a KeyHistogram with the given number of buckets for each sstable.
+// If maxBoundaryLen is positive, boundary keys are truncated to at most
+// maxBoundaryLen bytes in order to bound the size of the property, which makes
+// the boundaries, and the estimates derived from them, less precise. The
+// property occupies roughly (buckets+1) times the boundary length.
+func NewKeyHistogramPropertyCollector(buckets, maxBoundaryLen int) *KeyHistogramPropertyCollector {
+	if buckets < 1 {
+		buckets = 1
+	}
+	return &KeyHistogramPropertyCollector{
+		buckets:        buckets,
+		maxBoundaryLen: maxBoundaryLen,
+		interval:       1,
+	}
+}
+
+// Add implements the TablePropertyCollector interface.
+func (c *KeyHistogramPropertyCollector) Add(key InternalKey, value []byte) error {
+	switch key.Kind() {
+	case base.InternalKeyKindSet, base.InternalKeyKindSetWithDelete, base.InternalKeyKindMerge,
+		base.InternalKeyKindDelete, base.InternalKeyKindSingleDelete:
+	default:
+		// Range deletions and range keys are not point keys.
+		return nil
+	}
+	k := key.UserKey
+	if c.maxBoundaryLen > 0 && len(k) > c.maxBoundaryLen {
+		k = k[:c.maxBoundaryLen]
+	}
+	if c.count%c.interval == 0 {
+		c.samples = append(c.samples, append([]byte(nil), k...))
+		if len(c.samples) > 4*c.buckets {
+			// Retain the samples with even indexes, so that samples[i] continues
+			// to hold the key with rank i*interval.
+			for i := 0; 2*i < len(c.samples); i++ {
+				c.samples[i] = c.samples[2*i]
+			}
+			c.samples = c.samples[:(len(c.samples)+1)/2]
+			c.interval *= 2
+		}
+	}
+	c.last = append(c.last[:0], k...)
+	c.count++
+	return nil
+}
+
+// Finish implements the TablePropertyCollector interface.
+func (c *KeyHistogramPropertyCollector) Finish(userProps map[string]string) error {
+	if c.count == 0 {
+		return nil
+	}
+	buckets := uint64(c.buckets)
+	if c.count-1 < buckets {
+		buckets = c.count - 1
+	}
+	buf := binary.AppendUvarint(nil, c.count)
+	buf = binary.AppendUvarint(buf, buckets+1)
+	appendBoundary := func(k []byte) {
+		buf = binary.AppendUvarint(buf, uint64(len(k)))
+		buf = append(buf, k...)
+	}
+	for b := uint64(0); b < buckets; b++ {
+		rank := b * (c.count - 1) / buckets
+		appendBoundary(c.samples[rank/c.interval])
+	}
+	appendBoundary(c.last)
+	userProps[KeyHistogramPropertyCollectorName] = string(buf)
+	return nil
+}
+
+// Name implements the TablePropertyCollector interface.
+func (c *KeyHistogramPropertyCollector) Name() string {
+	return KeyHistogramPropertyCollectorName
+}
+
+// ReadKeyHistogram returns the KeyHistogram recorded by a
+// KeyHistogramPropertyCollector. It returns ok=false if the table was not
+// written with the collector or contains no point keys.
+func ReadKeyHistogram(props *Properties) (h KeyHistogram, ok bool, err error) {
+	prop, ok := props.UserProperties[KeyHistogramPropertyCollectorName]
+	if !ok {
+		return KeyHistogram{}, false, nil
+	}
+	corrupt := base.CorruptionErrorf("table properties for %s is corrupted", KeyHistogramPropertyCollectorName)
+	buf := []byte(prop)
+	readUvarint := func() (uint64, bool) {
+		v, n := binary.Uvarint(buf)
+		if n <= 0 {
+			return 0, false
+		}
+		buf = buf[n:]
+		return v, true
+	}
+	var n uint64
+	if h.Count, ok = readUvarint(); !ok {
+		return KeyHistogram{}, false, corrupt
+	}
+	if n, ok = readUvarint(); !ok || n > uint64(len(buf)) {
+		return KeyHistogram{}, false, corrupt
+	}
+	h.Boundaries = make([][]byte, n)
+	for i := range h.Boundaries {
+		l, ok := readUvarint()
+		if !ok || l > uint64(len(buf)) {
+			return KeyHistogram{}, false, corrupt
+		}
+		h.Boundaries[i], buf = buf[:l:l], buf[l:]
+	}
+	return h, true, nil
+}
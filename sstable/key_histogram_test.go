@@ -0,0 +1,116 @@
+// Copyright 2026 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package sstable
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/cockroachdb/pebble/vfs"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyHistogramPropertyCollector(t *testing.T) {
+	writeTable := func(t *testing.T, buckets, maxBoundaryLen int, keys ...string) *Reader {
+		mem := vfs.NewMem()
+		f, err := mem.Create("test")
+		require.NoError(t, err)
+		w := NewWriter(f, WriterOptions{
+			TablePropertyCollectors: []func() TablePropertyCollector{
+				func() TablePropertyCollector {
+					return NewKeyHistogramPropertyCollector(buckets, maxBoundaryLen)
+				},
+			},
+		})
+		for _, k := range keys {
+			require.NoError(t, w.Set([]byte(k), nil))
+		}
+		require.NoError(t, w.DeleteRange([]byte("a"), []byte("z")))
+		require.NoError(t, w.Close())
+
+		f, err = mem.Open("test")
+		require.NoError(t, err)
+		r, err := NewReader(f, ReaderOptions{})
+		require.NoError(t, err)
+		return r
+	}
+
+	t.Run("many-keys", func(t *testing.T) {
+		var keys []string
+		for i := 0; i < 1000; i++ {
+			keys = append(keys, fmt.Sprintf("key%04d", i))
+		}
+		r := writeTable(t, 10, 0, keys...)
+		defer r.Close()
+		h, ok, err := ReadKeyHistogram(&r.Properties)
+		require.NoError(t, err)
+		require.True(t, ok)
+		require.Equal(t, uint64(1000), h.Count)
+		require.Len(t, h.Boundaries, 11)
+		require.Equal(t, "key0000", string(h.Boundaries[0]))
+		require.Equal(t, "key0999", string(h.Boundaries[10]))
+		for i := 1; i < len(h.Boundaries); i++ {
+			require.True(t, bytes.Compare(h.Boundaries[i-1], h.Boundaries[i]) < 0)
+		}
+
+		for _, tc := range []struct {
+			start, end string
+			min, max   uint64
+		}{
+			{"a", "z", 1000, 1000},
+			{"key0000", "key0500", 450, 550},
+			{"key0250", "key0260", 0, 100},
+			{"z", "zz", 0, 0},
+		} {
+			n := h.EstimateCount(bytes.Compare, []byte(tc.start), []byte(tc.end))
+			require.True(t, n >= tc.min && n <= tc.max, "[%s, %s): %d", tc.start, tc.end, n)
+		}
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		r := writeTable(t, 2, 3, "aaaa", "bbbb", "cccc")
+		defer r.Close()
+		h, ok, err := ReadKeyHistogram(&r.Properties)
+		require.NoError(t, err)
+		require.True(t, ok)
+		require.Equal(t, KeyHistogram{
+			Count:      3,
+			Boundaries: [][]byte{[]byte("aaa"), []byte("bbb"), []byte("ccc")},
+		}, h)
+	})
+
+	t.Run("single-key", func(t *testing.T) {
+		r := writeTable(t, 10, 0, "b")
+		defer r.Close()
+		h, ok, err := ReadKeyHistogram(&r.Properties)
+		require.NoError(t, err)
+		require.True(t, ok)
+		require.Equal(t, KeyHistogram{Count: 1, Boundaries: [][]byte{[]byte("b")}}, h)
+		require.Equal(t, uint64(1), h.EstimateCount(bytes.Compare, []byte("a"), []byte("c")))
+		require.Zero(t, h.EstimateCount(bytes.Compare, []byte("a"), []byte("b")))
+	})
+
+	t.Run("no-point-keys", func(t *testing.T) {
+		r := writeTable(t, 10, 0)
+		defer r.Close()
+		_, ok, err := ReadKeyHistogram(&r.Properties)
+		require.NoError(t, err)
+		require.False(t, ok)
+	})
+
+	t.Run("corrupt", func(t *testing.T) {
+		_, _, err := ReadKeyHistogram(&Properties{
+			UserProperties: map[string]string{KeyHistogramPropertyCollectorName: "\x05\x02\x09a"},
+		})
+		require.Error(t, err)
+	})
+
+	t.Run("no-collector", func(t *testing.T) {
+		_, ok, err := ReadKeyHistogram(&Properties{})
+		require.NoError(t, err)
+		require.False(t, ok)
+	})
+}
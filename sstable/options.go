@@ -36,6 +36,42 @@ func (c Compression) String() string {
 	}
 }
 
+// RangeKeyFragmentPolicy configures how range keys are fragmented at the
+// boundaries between the sstables written by a flush or compaction. Within an
+// sstable, range keys are only fragmented where they overlap one another: they
+// are stored in a single range key block, separately from the data blocks, so
+// the targets for the sizes of data blocks don't split them.
+type RangeKeyFragmentPolicy int
+
+// The available range key fragment policies.
+const (
+	// RangeKeyFragmentAtBoundaries fragments range keys at the sstable
+	// boundaries chosen for the point keys, so that each sstable's size stays
+	// close to its target.
+	RangeKeyFragmentAtBoundaries RangeKeyFragmentPolicy = iota
+	// RangeKeyFragmentAvoidSplits defers ending an sstable due to its size
+	// while a range key spans the point at which it would end, so that the
+	// range key isn't fragmented at the boundary, until the sstable reaches
+	// twice its target size. This reduces the number of fragments readers must
+	// coalesce when range keys are large relative to the sstables, at the cost
+	// of sstables that overshoot their target size. Larger sstables make
+	// subsequent compactions coarser, increasing write amplification. Splits
+	// that limit the overlap of an sstable with the level below are never
+	// deferred.
+	RangeKeyFragmentAvoidSplits
+)
+
+func (p RangeKeyFragmentPolicy) String() string {
+	switch p {
+	case RangeKeyFragmentAtBoundaries:
+		return "at-boundaries"
+	case RangeKeyFragmentAvoidSplits:
+		return "avoid-splits"
+	default:
+		return "unknown"
+	}
+}
+
 // FilterType exports the base.FilterType type.
 type FilterType = base.FilterType
 
@@ -117,12 +153,24 @@ type ReaderOptions struct {
 	// written with {Batch,DB}.Merge. The MergerName is checked for consistency
 	// with the value stored in the sstable when it was written.
 	MergerName string
+
+	// CompactionReadaheadSize is the size of the buffer used by iterators
+	// constructed through Reader.NewCompactionIter to read ahead of the current
+	// data block. Larger values reduce the number of reads issued to the
+	// underlying file, which benefits storage with high per-read latency, at
+	// the cost of memory for each open compaction iterator. Blocks larger than
+	// the buffer are read directly.
+	//
+	// The default value of zero disables the buffer, in which case compaction
+	// iterators rely on OS-level readahead.
+	CompactionReadaheadSize int
 }
 
 func (o ReaderOptions) ensureDefaults() ReaderOptions {
 	if o.Comparer == nil {
 		o.Comparer = base.DefaultComparer
 	}
+	o.Comparer = base.NormalizedComparer(o.Comparer)
 	if o.MergerName == "" {
 		o.MergerName = base.DefaultMerger.Name
 	}
@@ -149,6 +197,20 @@ type WriterOptions struct {
 	// The default value is 90
 	BlockSizeThreshold int
 
+	// BlockAlignment, if greater than 1, aligns each block of the sstable to a
+	// multiple of BlockAlignment bytes from the start of the file, by padding
+	// the file with zeros before any block that would otherwise be unaligned.
+	// The alignment is recorded in the table's properties, and a Reader of the
+	// table rounds up the reads of its blocks to a multiple of the alignment
+	// wherever the file allows, so that each block is read as whole aligned
+	// units, such as pages of the underlying storage, and no two blocks share
+	// a unit. The cost is the space taken by padding, which is on average half
+	// the alignment per block, so the alignment should be small relative to
+	// BlockSize.
+	//
+	// The default value (zero) means no alignment.
+	BlockAlignment int
+
 	// Cache is used to cache uncompressed blocks from sstables.
 	//
 	// The default is a nil cache.
@@ -166,6 +228,21 @@ type WriterOptions struct {
 	// The default value (DefaultCompression) uses snappy compression.
 	Compression Compression
 
+	// CompressionDict is a zstd dictionary used to compress the data and index
+	// blocks of the sstable when Compression is ZstdCompression. It is ignored
+	// for other compression algorithms. A dictionary trained on samples of the
+	// keys and values being written can significantly improve the compression
+	// of small blocks of similarly structured data. The dictionary must be in
+	// the zstd dictionary format, as produced by TrainCompressionDict or `zstd
+	// --train`.
+	//
+	// Compression dictionaries require TableFormatPebblev3 or later. The
+	// dictionary is stored in the sstable, so sstables may be read without
+	// supplying it, and sstables written with different dictionaries (or none)
+	// may be read by the same process. The cost is the size of the dictionary
+	// (typically tens of kilobytes) in every sstable.
+	CompressionDict []byte
+
 	// FilterPolicy defines a filter algorithm (such as a Bloom filter) that can
 	// reduce disk reads for Get calls.
 	//
@@ -184,6 +261,14 @@ type WriterOptions struct {
 	// filters should be preferred except under constrained memory situations.
 	FilterType FilterType
 
+	// RangeKeyFragmentPolicy configures how the range keys written to the
+	// sstable are fragmented at its boundaries with the other sstables written
+	// by the same flush or compaction. It doesn't affect the sstable written by
+	// a standalone Writer, whose boundaries are chosen by its user.
+	//
+	// The default value is RangeKeyFragmentAtBoundaries.
+	RangeKeyFragmentPolicy RangeKeyFragmentPolicy
+
 	// IndexBlockSize is the target uncompressed size in bytes of each index
 	// block. When the index block size is larger than this target, two-level
 	// indexes are automatically enabled. Setting this option to a large value
@@ -193,6 +278,33 @@ type WriterOptions struct {
 	// The default value is the value of BlockSize.
 	IndexBlockSize int
 
+	// MaxKeysPerFile is the maximum number of point keys in an sstable
+	// written by a flush or compaction. When an output sstable reaches it,
+	// the flush or compaction finishes the sstable and starts a new one,
+	// even if the sstable is smaller than its target size. The limit is
+	// applied at user key boundaries, so an sstable exceeds it when the
+	// versions of the user key that reached it span the limit. The Writer
+	// doesn't enforce the limit itself: it's consulted by the client
+	// deciding when to start a new sstable, using Writer.NumPointKeys.
+	//
+	// The default value (zero) means no limit.
+	MaxKeysPerFile int
+
+	// MaxInlineValueSize, if positive, is the size above which the values of
+	// SET, SETWITHDEL and MERGE entries are chunked into value blocks of at
+	// most BlockSize bytes, in place of being stored in the data block of
+	// their key. The data block stores a small handle locating the chunks, and
+	// iterators reassemble the value when positioned at its key, or when it's
+	// fetched if IterOptions.LazyValues is set. This keeps large values from
+	// producing data blocks larger than BlockSize.
+	//
+	// Value blocks require TableFormatPebblev4 or later. Each value block is
+	// written as soon as it's filled, ahead of the data block holding the
+	// handle of its value.
+	//
+	// The default value (zero) stores every value in its data block.
+	MaxInlineValueSize int
+
 	// Merger defines the associative merge operation to use for merging values
 	// written with {Batch,DB}.Merge. The MergerName is checked for consistency
 	// with the value stored in the sstable when it was written.
@@ -214,6 +326,20 @@ type WriterOptions struct {
 	// built and lives for the lifetime of writing that table.
 	BlockPropertyCollectors []func() BlockPropertyCollector
 
+	// UserProperties are arbitrary properties, such as the identity of the
+	// sstable's producer or the version of the schema of its keys, written to
+	// the table properties block alongside those of the property collectors.
+	// They are read back through Properties.UserProperties, including for the
+	// sstables ingested into a DB through DB.SSTables with WithProperties,
+	// allowing the provenance of an sstable to be traced. The names must not
+	// collide with those of the property collectors.
+	//
+	// The properties are not carried over to the sstables produced when a
+	// flush or compaction rewrites an sstable, which only record the
+	// properties of the DB's own collectors. Properties that must survive
+	// compaction must be re-derived from the keys by a collector.
+	UserProperties map[string]string
+
 	// Checksum specifies which checksum to use.
 	Checksum ChecksumType
 
@@ -221,6 +347,14 @@ type WriterOptions struct {
 	// compress data blocks and write datablocks to disk in parallel with the
 	// Writer client goroutine.
 	Parallelism bool
+
+	// WriteBufferSize is the size of the buffer into which the Writer
+	// accumulates its output before writing it to the file. It's unused if
+	// the file provides its own buffering through a Flush method. Blocks
+	// larger than the buffer are written to the file directly.
+	//
+	// The default value is 4KB.
+	WriteBufferSize int
 }
 
 func (o WriterOptions) ensureDefaults() WriterOptions {
@@ -233,9 +367,13 @@ func (o WriterOptions) ensureDefaults() WriterOptions {
 	if o.BlockSizeThreshold <= 0 {
 		o.BlockSizeThreshold = base.DefaultBlockSizeThreshold
 	}
+	if o.BlockAlignment < 0 {
+		o.BlockAlignment = 0
+	}
 	if o.Comparer == nil {
 		o.Comparer = base.DefaultComparer
 	}
+	o.Comparer = base.NormalizedComparer(o.Comparer)
 	if o.Compression <= DefaultCompression || o.Compression >= NCompression {
 		o.Compression = SnappyCompression
 	}
@@ -245,6 +383,9 @@ func (o WriterOptions) ensureDefaults() WriterOptions {
 	if o.MergerName == "" {
 		o.MergerName = base.DefaultMerger.Name
 	}
+	if o.WriteBufferSize <= 0 {
+		o.WriteBufferSize = 4 << 10 // 4 KB
+	}
 	if o.Checksum == ChecksumTypeNone {
 		o.Checksum = ChecksumTypeCRC32c
 	}
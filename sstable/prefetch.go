@@ -0,0 +1,132 @@
+// Copyright 2026 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package sstable
+
+// blockPrefetcher holds the state of an iterator's asynchronous prefetching of
+// data blocks during forward iteration. Prefetched blocks are read into the
+// block cache by background goroutines, from which the iterator later loads
+// them.
+type blockPrefetcher struct {
+	// depth is the number of data blocks following the current block to
+	// prefetch. Prefetching is disabled if zero.
+	depth int
+	// inflight is the number of prefetches that have been started and whose
+	// completion hasn't been received from done. It never exceeds depth.
+	inflight int
+	done     chan struct{}
+	// pending holds the offsets of the prefetched data blocks that the
+	// iterator hasn't loaded yet, in increasing order.
+	pending []uint64
+	// peek is used to step through the index entries following the iterator's
+	// index position, without moving the iterator's index.
+	peek blockIter
+}
+
+func (p *blockPrefetcher) init(depth int) {
+	if depth < 0 {
+		depth = 0
+	}
+	p.depth = depth
+	if depth > 0 && cap(p.done) < depth {
+		p.done = make(chan struct{}, depth)
+	}
+}
+
+// take removes the offsets of blocks preceding the block at the given offset
+// from the pending prefetches, and returns whether the block at the offset was
+// itself prefetched.
+func (p *blockPrefetcher) take(offset uint64) bool {
+	n := 0
+	for n < len(p.pending) && p.pending[n] < offset {
+		n++
+	}
+	prefetched := n < len(p.pending) && p.pending[n] == offset
+	if prefetched {
+		n++
+	}
+	p.pending = append(p.pending[:0], p.pending[n:]...)
+	return prefetched
+}
+
+// reap accounts for the completed prefetches, blocking until all of them have
+// completed if wait is true.
+func (p *blockPrefetcher) reap(wait bool) {
+	for p.inflight > 0 {
+		if wait {
+			<-p.done
+		} else {
+			select {
+			case <-p.done:
+			default:
+				return
+			}
+		}
+		p.inflight--
+	}
+}
+
+func (p *blockPrefetcher) resetForReuse() blockPrefetcher {
+	return blockPrefetcher{
+		done:    p.done,
+		pending: p.pending[:0],
+		peek:    p.peek.resetForReuse(),
+	}
+}
+
+// maybePrefetch starts prefetching the data blocks following the block at the
+// iterator's index position, up to the prefetch depth. It's called after the
+// iterator loads a data block while iterating forward. Blocks that may only
+// contain keys at or beyond the upper bound, and blocks excluded by the
+// block-property filters, are not prefetched. With a two-level index, only the
+// blocks of the current second-level index block are prefetched.
+func (i *singleLevelIterator) maybePrefetch() {
+	p := &i.prefetch
+	if p.depth == 0 || !i.fillCache {
+		return
+	}
+	p.reap(false /* wait */)
+	if p.inflight >= p.depth {
+		return
+	}
+	if err := p.peek.initAt(&i.index); err != nil {
+		return
+	}
+	// The separator of the previous index entry is an exclusive lower bound
+	// on the keys of the next block.
+	sep := i.index.Key().UserKey
+	for n := 0; n < p.depth && p.inflight < p.depth; n++ {
+		if i.upper != nil && i.cmp(sep, i.upper) >= 0 {
+			return
+		}
+		key, v := p.peek.Next()
+		if key == nil {
+			return
+		}
+		sep = key.UserKey
+		bhp, err := decodeBlockHandleWithProperties(v)
+		if err != nil {
+			return
+		}
+		if len(p.pending) > 0 && bhp.Offset <= p.pending[len(p.pending)-1] {
+			// Already prefetched.
+			continue
+		}
+		if i.bpfs != nil {
+			if intersects, err := i.bpfs.intersects(bhp.Props); err != nil || intersects == blockExcluded {
+				continue
+			}
+		}
+		p.pending = append(p.pending, bhp.Offset)
+		p.inflight++
+		go func(r *Reader, bh BlockHandle, done chan<- struct{}) {
+			// Errors are ignored, and will be encountered again when the
+			// iterator loads the block.
+			if h, _, err := r.readBlock(bh, nil /* transform */, nil /* readaheadState */, true /* fillCache */); err == nil {
+				h.Release()
+			}
+			done <- struct{}{}
+		}(i.reader, bhp.BlockHandle, p.done)
+	}
+}
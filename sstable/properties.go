@@ -56,6 +56,9 @@ func init() {
 // automatically populated during sstable creation and load from the properties
 // meta block when an sstable is opened.
 type Properties struct {
+	// The alignment of the blocks in the table, if the table was written with
+	// WriterOptions.BlockAlignment. Zero if the blocks are not aligned.
+	BlockAlignment uint64 `prop:"pebble.block.alignment"`
 	// ID of column family for this SST file, corresponding to the CF identified
 	// by column_family_name.
 	ColumnFamilyID uint64 `prop:"rocksdb.column.family.id"`
@@ -103,6 +106,8 @@ type Properties struct {
 	IndexValueIsDeltaEncoded uint64 `prop:"rocksdb.index.value.is.delta.encoded"`
 	// The name of the merger used in this table. Empty if no merger is used.
 	MergerName string `prop:"rocksdb.merge.operator"`
+	// The number of values in this table chunked into value blocks.
+	NumChunkedValues uint64 `prop:"pebble.num.chunked.values"`
 	// The number of blocks in this table.
 	NumDataBlocks uint64 `prop:"rocksdb.num.data.blocks"`
 	// The number of deletion entries in this table, including both point and
@@ -140,6 +145,9 @@ type Properties struct {
 	RawValueSize uint64 `prop:"rocksdb.raw.value.size"`
 	// Size of the top-level index if kTwoLevelIndexSearch is used.
 	TopLevelIndexSize uint64 `prop:"rocksdb.top-level.index.size"`
+	// The total size of the value blocks, which are interleaved with the data
+	// blocks but aren't included in DataSize.
+	ValueBlocksSize uint64 `prop:"pebble.value.blocks.size"`
 	// User collected properties.
 	UserProperties map[string]string
 	// If filtering is enabled, was the filter created on the whole key.
@@ -289,6 +297,9 @@ func (p *Properties) save(w *rawBlockWriter) {
 		m[k] = []byte(v)
 	}
 
+	if p.BlockAlignment != 0 {
+		p.saveUvarint(m, unsafe.Offsetof(p.BlockAlignment), p.BlockAlignment)
+	}
 	p.saveUvarint(m, unsafe.Offsetof(p.ColumnFamilyID), p.ColumnFamilyID)
 	if p.ColumnFamilyName != "" {
 		p.saveString(m, unsafe.Offsetof(p.ColumnFamilyName), p.ColumnFamilyName)
@@ -328,6 +339,10 @@ func (p *Properties) save(w *rawBlockWriter) {
 	if p.MergerName != "" {
 		p.saveString(m, unsafe.Offsetof(p.MergerName), p.MergerName)
 	}
+	if p.NumChunkedValues > 0 {
+		p.saveUvarint(m, unsafe.Offsetof(p.NumChunkedValues), p.NumChunkedValues)
+		p.saveUvarint(m, unsafe.Offsetof(p.ValueBlocksSize), p.ValueBlocksSize)
+	}
 	p.saveUvarint(m, unsafe.Offsetof(p.NumDataBlocks), p.NumDataBlocks)
 	p.saveUvarint(m, unsafe.Offsetof(p.NumEntries), p.NumEntries)
 	p.saveUvarint(m, unsafe.Offsetof(p.NumDeletions), p.NumDeletions)
@@ -80,6 +80,7 @@ func TestPropertiesSave(t *testing.T) {
 		IndexType:                12,
 		IndexValueIsDeltaEncoded: 13,
 		MergerName:               "merge operator name",
+		NumChunkedValues:         26,
 		NumDataBlocks:            14,
 		NumDeletions:             15,
 		NumEntries:               16,
@@ -95,6 +96,7 @@ func TestPropertiesSave(t *testing.T) {
 		RawKeySize:               23,
 		RawValueSize:             24,
 		TopLevelIndexSize:        25,
+		ValueBlocksSize:          27,
 		WholeKeyFiltering:        true,
 		UserProperties: map[string]string{
 			"user-prop-a": "1",
@@ -124,6 +126,9 @@ func TestPropertiesSave(t *testing.T) {
 		if props.IndexPartitions == 0 {
 			props.TopLevelIndexSize = 0
 		}
+		if props.NumChunkedValues == 0 {
+			props.ValueBlocksSize = 0
+		}
 		check1(&props)
 	}
 }
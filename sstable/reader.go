@@ -104,6 +104,47 @@ type Iterator interface {
 	SetCloseHook(fn func(i Iterator) error)
 }
 
+// CorruptionAction is returned by a CorruptionHandler to indicate how an
+// iterator should proceed after encountering a corrupt block.
+type CorruptionAction int8
+
+const (
+	// CorruptionFail surfaces the corruption error through the iterator's
+	// Error method. This is the behavior when no CorruptionHandler is
+	// configured.
+	CorruptionFail CorruptionAction = iota
+	// CorruptionSkipBlock skips the keys in the corrupt block and continues
+	// iteration with the adjacent block.
+	CorruptionSkipBlock
+)
+
+// CorruptionHandler is invoked by an iterator when a data block or a
+// second-level index block of the sstable with the given file number fails to
+// load due to corruption. The offset is the offset of the corrupt block within
+// the file. The error is a *CorruptBlockError which describes the keys lost if
+// the block is skipped.
+type CorruptionHandler func(fileNum base.FileNum, offset int64, err error) CorruptionAction
+
+// CorruptBlockError is the error passed to a CorruptionHandler.
+type CorruptBlockError struct {
+	// LastKey is the user key of the index entry for the corrupt block. All of
+	// the keys in the block are less than or equal to LastKey, and greater than
+	// the last key of the preceding block.
+	LastKey []byte
+	// Err is the underlying corruption error.
+	Err error
+}
+
+// Error implements the error interface.
+func (e *CorruptBlockError) Error() string {
+	return fmt.Sprintf("pebble/table: corrupt block ending at key %q: %v", e.LastKey, e.Err)
+}
+
+// Unwrap returns the underlying corruption error.
+func (e *CorruptBlockError) Unwrap() error {
+	return e.Err
+}
+
 // singleLevelIterator iterates over an entire table of data. To seek for a given
 // key, it first looks in the index for the block that contains that key, and then
 // looks inside that block.
@@ -128,6 +169,9 @@ type singleLevelIterator struct {
 	err       error
 	closeHook func(i Iterator) error
 	stats     base.InternalIteratorStats
+	// onCorruption, if non-nil, is consulted when a block fails to load due to
+	// corruption.
+	onCorruption CorruptionHandler
 
 	// boundsCmp and positionedUsingLatestBounds are for optimizing iteration
 	// that uses multiple adjacent bounds. The seek after setting a new bound
@@ -223,6 +267,15 @@ type singleLevelIterator struct {
 	// is high).
 	useFilter              bool
 	lastBloomFilterMatched bool
+	// fillCache specifies whether blocks read by the iterator that aren't
+	// already in the block cache are added to it.
+	fillCache bool
+	// prefetch holds the state of the asynchronous prefetching of data blocks
+	// during forward iteration.
+	prefetch blockPrefetcher
+	// filterKeyBuf holds the normalized prefix looked up in the table filter,
+	// if the comparer normalizes keys.
+	filterKeyBuf []byte
 }
 
 // singleLevelIterator implements the base.InternalIterator interface.
@@ -274,12 +327,12 @@ func checkTwoLevelIterator(obj interface{}) {
 // synonmous with Reader.NewIter, but allows for reusing of the iterator
 // between different Readers.
 func (i *singleLevelIterator) init(
-	r *Reader, lower, upper []byte, filterer *BlockPropertiesFilterer, useFilter bool,
+	r *Reader, lower, upper []byte, filterer *BlockPropertiesFilterer, useFilter, fillCache bool,
 ) error {
 	if r.err != nil {
 		return r.err
 	}
-	indexH, err := r.readIndex()
+	indexH, err := r.readIndex(fillCache)
 	if err != nil {
 		return err
 	}
@@ -288,6 +341,7 @@ func (i *singleLevelIterator) init(
 	i.upper = upper
 	i.bpfs = filterer
 	i.useFilter = useFilter
+	i.fillCache = fillCache
 	i.reader = r
 	i.cmp = r.Compare
 	err = i.index.initHandle(i.cmp, indexH, r.Properties.GlobalSeqNum)
@@ -303,6 +357,9 @@ func (i *singleLevelIterator) init(
 // setupForCompaction sets up the singleLevelIterator for use with compactionIter.
 // Currently, it skips readahead ramp-up. It should be called after init is called.
 func (i *singleLevelIterator) setupForCompaction() {
+	if n := i.reader.opts.CompactionReadaheadSize; n > 0 {
+		i.dataRS.buf = make([]byte, 0, n)
+	}
 	if i.reader.fs != nil {
 		f, err := i.reader.fs.Open(i.reader.filename, vfs.SequentialReadsOption)
 		if err == nil {
@@ -315,8 +372,10 @@ func (i *singleLevelIterator) setupForCompaction() {
 
 func (i *singleLevelIterator) resetForReuse() singleLevelIterator {
 	return singleLevelIterator{
-		index: i.index.resetForReuse(),
-		data:  i.data.resetForReuse(),
+		index:        i.index.resetForReuse(),
+		data:         i.data.resetForReuse(),
+		prefetch:     i.prefetch.resetForReuse(),
+		filterKeyBuf: i.filterKeyBuf[:0],
 	}
 }
 
@@ -398,21 +457,53 @@ func (i *singleLevelIterator) loadBlock(dir int8) loadBlockResult {
 		}
 		// blockIntersects
 	}
-	block, err := i.readBlockWithStats(i.dataBH, &i.dataRS)
-	if err != nil {
-		i.err = err
-		return loadBlockFailed
+	prefetched := i.prefetch.take(i.dataBH.Offset)
+	block, cacheHit, err := i.readBlockWithStats(i.dataBH, &i.dataRS)
+	if err == nil {
+		if prefetched {
+			if cacheHit {
+				i.stats.PrefetchHits++
+			} else {
+				i.stats.PrefetchMisses++
+			}
+		}
+		err = i.data.initHandle(i.cmp, block, i.reader.Properties.GlobalSeqNum)
 	}
-	i.err = i.data.initHandle(i.cmp, block, i.reader.Properties.GlobalSeqNum)
-	if i.err != nil {
-		// The block is partially loaded, and we don't want it to appear valid.
+	if err != nil {
+		// The block may be partially loaded, and we don't want it to appear
+		// valid.
 		i.data.invalidate()
+		if i.skipCorruptBlock(i.dataBH, i.index.Key(), err) {
+			return loadBlockIrrelevant
+		}
+		i.err = err
 		return loadBlockFailed
 	}
 	i.initBounds()
+	if dir > 0 {
+		i.maybePrefetch()
+	}
 	return loadBlockOK
 }
 
+// skipCorruptBlock returns true if err is a corruption error and the
+// iterator's CorruptionHandler elected to skip the block with the handle bh
+// and index entry key.
+func (i *singleLevelIterator) skipCorruptBlock(
+	bh BlockHandle, key *InternalKey, err error,
+) bool {
+	if i.onCorruption == nil || !errors.Is(err, base.ErrCorruption) {
+		return false
+	}
+	var lastKey []byte
+	if key != nil {
+		lastKey = append(lastKey, key.UserKey...)
+	}
+	action := i.onCorruption(i.reader.fileNum, int64(bh.Offset),
+		&CorruptBlockError{LastKey: lastKey, Err: err})
+	return action == CorruptionSkipBlock
+}
+
 // resolveMaybeExcluded is invoked when the block-property filterer has found
 // that a block is excluded according to its properties but only if its bounds
 // fall within the filter's current bounds.  This function consults the
@@ -482,16 +573,17 @@ func (i *singleLevelIterator) resolveMaybeExcluded(dir int8) intersectsResult {
 
 func (i *singleLevelIterator) readBlockWithStats(
 	bh BlockHandle, raState *readaheadState,
-) (cache.Handle, error) {
-	block, cacheHit, err := i.reader.readBlock(bh, nil /* transform */, raState)
+) (_ cache.Handle, cacheHit bool, _ error) {
+	block, cacheHit, err := i.reader.readBlock(bh, nil /* transform */, raState, i.fillCache)
 	if err == nil {
 		n := bh.Length
+		i.stats.BlockReads++
 		i.stats.BlockBytes += n
 		if cacheHit {
 			i.stats.BlockBytesInCache += n
 		}
 	}
-	return block, err
+	return block, cacheHit, err
 }
 
 func (i *singleLevelIterator) initBoundsForAlreadyLoadedBlock() {
@@ -740,14 +832,16 @@ func (i *singleLevelIterator) seekPrefixGE(
 		i.lastBloomFilterMatched = false
 		// Check prefix bloom filter.
 		var dataH cache.Handle
-		dataH, i.err = i.reader.readFilter()
+		dataH, i.err = i.reader.readFilter(i.fillCache)
 		if i.err != nil {
 			i.data.invalidate()
 			return nil, nil
 		}
-		mayContain := i.reader.tableFilter.mayContain(dataH.Get(), prefix)
+		mayContain := i.reader.tableFilter.mayContain(dataH.Get(), i.filterKey(prefix))
 		dataH.Release()
+		i.stats.FilterProbes++
 		if !mayContain {
+			i.stats.FilterNegatives++
 			// This invalidation may not be necessary for correctness, and may
 			// be a place to optimize later by reusing the already loaded
 			// block. It was necessary in earlier versions of the code since
@@ -1157,6 +1251,8 @@ func firstError(err0, err1 error) error {
 // Close implements internalIterator.Close, as documented in the pebble
 // package.
 func (i *singleLevelIterator) Close() error {
+	// Wait for prefetches to complete before the Reader may be closed.
+	i.prefetch.reap(true /* wait */)
 	var err error
 	if i.closeHook != nil {
 		err = firstError(err, i.closeHook(i))
@@ -1334,8 +1430,7 @@ func (i *twoLevelIterator) loadIndex(dir int8) loadBlockResult {
 	// index fails.
 	i.data.invalidate()
 	if !i.topLevelIndex.valid() {
-		i.index.offset = 0
-		i.index.restarts = 0
+		i.index.unposition()
 		return loadBlockFailed
 	}
 	bhp, err := decodeBlockHandleWithProperties(i.topLevelIndex.Value())
@@ -1358,16 +1453,19 @@ func (i *twoLevelIterator) loadIndex(dir int8) loadBlockResult {
 		}
 		// blockIntersects
 	}
-	indexBlock, err := i.readBlockWithStats(bhp.BlockHandle, nil /* readaheadState */)
+	indexBlock, _, err := i.readBlockWithStats(bhp.BlockHandle, nil /* readaheadState */)
+	if err == nil {
+		err = i.index.initHandle(i.cmp, indexBlock, i.reader.Properties.GlobalSeqNum)
+	}
 	if err != nil {
+		if i.skipCorruptBlock(bhp.BlockHandle, i.topLevelIndex.Key(), err) {
+			i.index.unposition()
+			return loadBlockIrrelevant
+		}
 		i.err = err
 		return loadBlockFailed
 	}
-	if i.err = i.index.initHandle(
-		i.cmp, indexBlock, i.reader.Properties.GlobalSeqNum); i.err == nil {
-		return loadBlockOK
-	}
-	return loadBlockFailed
+	return loadBlockOK
 }
 
 // resolveMaybeExcluded is invoked when the block-property filterer has found
@@ -1434,12 +1532,12 @@ func (i *twoLevelIterator) resolveMaybeExcluded(dir int8) intersectsResult {
 }
 
 func (i *twoLevelIterator) init(
-	r *Reader, lower, upper []byte, filterer *BlockPropertiesFilterer, useFilter bool,
+	r *Reader, lower, upper []byte, filterer *BlockPropertiesFilterer, useFilter, fillCache bool,
 ) error {
 	if r.err != nil {
 		return r.err
 	}
-	topLevelIndexH, err := r.readIndex()
+	topLevelIndexH, err := r.readIndex(fillCache)
 	if err != nil {
 		return err
 	}
@@ -1448,6 +1546,7 @@ func (i *twoLevelIterator) init(
 	i.upper = upper
 	i.bpfs = filterer
 	i.useFilter = useFilter
+	i.fillCache = fillCache
 	i.reader = r
 	i.cmp = r.Compare
 	err = i.topLevelIndex.initHandle(i.cmp, topLevelIndexH, r.Properties.GlobalSeqNum)
@@ -1572,14 +1671,16 @@ func (i *twoLevelIterator) SeekPrefixGE(
 		}
 		i.lastBloomFilterMatched = false
 		var dataH cache.Handle
-		dataH, i.err = i.reader.readFilter()
+		dataH, i.err = i.reader.readFilter(i.fillCache)
 		if i.err != nil {
 			i.data.invalidate()
 			return nil, nil
 		}
-		mayContain := i.reader.tableFilter.mayContain(dataH.Get(), prefix)
+		mayContain := i.reader.tableFilter.mayContain(dataH.Get(), i.filterKey(prefix))
 		dataH.Release()
+		i.stats.FilterProbes++
 		if !mayContain {
+			i.stats.FilterNegatives++
 			// This invalidation may not be necessary for correctness, and may
 			// be a place to optimize later by reusing the already loaded
 			// block. It was necessary in earlier versions of the code since
@@ -1930,6 +2031,8 @@ func (i *twoLevelIterator) skipBackward() (*InternalKey, []byte) {
 // Close implements internalIterator.Close, as documented in the pebble
 // package.
 func (i *twoLevelIterator) Close() error {
+	// Wait for prefetches to complete before the Reader may be closed.
+	i.prefetch.reap(true /* wait */)
 	var err error
 	if i.closeHook != nil {
 		err = firstError(err, i.closeHook(i))
@@ -2074,6 +2177,33 @@ type readaheadState struct {
 	// the other variables in readaheadState don't matter much as we defer
 	// to OS-level readahead.
 	sequentialFile vfs.File
+	// buf, if it has a non-zero capacity, holds the data read ahead of the
+	// current block, starting at file offset bufOffset. It is only used by
+	// compaction iterators configured with
+	// ReaderOptions.CompactionReadaheadSize.
+	buf       []byte
+	bufOffset int64
+}
+
+// readAt reads len(b) bytes from file at offset off. If rs has a read-ahead
+// buffer, the read is served from the buffer, refilling it from the file
+// starting at off if it does not contain the requested range.
+func (rs *readaheadState) readAt(file ReadableFile, b []byte, off int64) error {
+	if cap(rs.buf) < len(b) {
+		_, err := file.ReadAt(b, off)
+		return err
+	}
+	if off < rs.bufOffset || off+int64(len(b)) > rs.bufOffset+int64(len(rs.buf)) {
+		n, err := file.ReadAt(rs.buf[:cap(rs.buf)], off)
+		if err != nil && !(err == io.EOF && n >= len(b)) {
+			rs.buf = rs.buf[:0]
+			return err
+		}
+		rs.buf = rs.buf[:n]
+		rs.bufOffset = off
+	}
+	copy(b, rs.buf[off-rs.bufOffset:])
+	return nil
 }
 
 func (rs *readaheadState) recordCacheHit(offset, blockLength int64) {
@@ -2263,9 +2393,11 @@ func (c Comparers) readerApply(r *Reader) {
 		return
 	}
 	if comparer, ok := c[r.Properties.ComparerName]; ok {
-		r.Compare = comparer.Compare
+		comparer = base.NormalizedComparer(comparer)
+		r.Compare = base.FixedPrefixCompare(comparer.Compare, comparer.PrefixLen)
 		r.FormatKey = comparer.FormatKey
 		r.Split = comparer.Split
+		r.normalize = comparer.Normalize
 	}
 }
 
@@ -2358,6 +2490,9 @@ type Reader struct {
 	filterBH          BlockHandle
 	rangeDelBH        BlockHandle
 	rangeKeyBH        BlockHandle
+	compressionDictBH BlockHandle
+	valueIndexBH      BlockHandle
+	dictDecoder       *zstdDecoder
 	rangeDelTransform blockTransform
 	propertiesBH      BlockHandle
 	metaIndexBH       BlockHandle
@@ -2366,6 +2501,7 @@ type Reader struct {
 	Compare           Compare
 	FormatKey         base.FormatKey
 	Split             Split
+	normalize         func(dst, key []byte) []byte
 	mergerOK          bool
 	checksumType      ChecksumType
 	tableFilter       *tableFilterReader
@@ -2376,6 +2512,10 @@ type Reader struct {
 // Close implements DB.Close, as documented in the pebble package.
 func (r *Reader) Close() error {
 	r.opts.Cache.Unref()
+	if r.dictDecoder != nil {
+		r.dictDecoder.close()
+		r.dictDecoder = nil
+	}
 
 	if r.err != nil {
 		if r.file != nil {
@@ -2396,36 +2536,63 @@ func (r *Reader) Close() error {
 	return nil
 }
 
+// IterOptions holds the optional parameters of an iterator created by
+// NewIterWithBlockPropertyFilters. The zero value is the default.
+type IterOptions struct {
+	// OnCorruption, if non-nil, is invoked when a data block or second-level
+	// index block fails to load due to corruption, and decides whether the
+	// iterator skips the block or fails.
+	OnCorruption CorruptionHandler
+	// DisableCacheFill, if true, keeps the data, index and filter blocks read
+	// by the iterator out of the block cache, though blocks already in the
+	// cache are still read from it.
+	DisableCacheFill bool
+	// PrefetchBlocks, if positive and DisableCacheFill is false, configures
+	// the iterator to asynchronously read up to PrefetchBlocks data blocks
+	// ahead into the block cache while iterating forward.
+	PrefetchBlocks int
+	// LazyValues, if true, configures the iterator to return a placeholder in
+	// place of a value chunked into value blocks, and to reassemble the value
+	// only when it's fetched through base.FetchValue. Positioning the iterator
+	// then never reads value blocks.
+	LazyValues bool
+}
+
 // NewIterWithBlockPropertyFilters returns an iterator for the contents of the
 // table. If an error occurs, NewIterWithBlockPropertyFilters cleans up after
 // itself and returns a nil iterator.
 func (r *Reader) NewIterWithBlockPropertyFilters(
-	lower, upper []byte, filterer *BlockPropertiesFilterer, useFilterBlock bool,
+	lower, upper []byte, filterer *BlockPropertiesFilterer, useFilterBlock bool, opts IterOptions,
 ) (Iterator, error) {
 	// NB: pebble.tableCache wraps the returned iterator with one which performs
 	// reference counting on the Reader, preventing the Reader from being closed
 	// until the final iterator closes.
 	if r.Properties.IndexType == twoLevelIndex {
 		i := twoLevelIterPool.Get().(*twoLevelIterator)
-		err := i.init(r, lower, upper, filterer, useFilterBlock)
+		err := i.init(r, lower, upper, filterer, useFilterBlock, !opts.DisableCacheFill)
 		if err != nil {
 			return nil, err
 		}
-		return i, nil
+		i.onCorruption = opts.OnCorruption
+		i.prefetch.init(opts.PrefetchBlocks)
+		return r.wrapValues(i, !opts.DisableCacheFill, opts.LazyValues), nil
 	}
 
 	i := singleLevelIterPool.Get().(*singleLevelIterator)
-	err := i.init(r, lower, upper, filterer, useFilterBlock)
+	err := i.init(r, lower, upper, filterer, useFilterBlock, !opts.DisableCacheFill)
 	if err != nil {
 		return nil, err
 	}
-	return i, nil
+	i.onCorruption = opts.OnCorruption
+	i.prefetch.init(opts.PrefetchBlocks)
+	return r.wrapValues(i, !opts.DisableCacheFill, opts.LazyValues), nil
 }
 
 // NewIter returns an iterator for the contents of the table. If an error
 // occurs, NewIter cleans up after itself and returns a nil iterator.
 func (r *Reader) NewIter(lower, upper []byte) (Iterator, error) {
-	return r.NewIterWithBlockPropertyFilters(lower, upper, nil, true /* useFilterBlock */)
+	return r.NewIterWithBlockPropertyFilters(
+		lower, upper, nil, true /* useFilterBlock */, IterOptions{})
 }
 
 // NewCompactionIter returns an iterator similar to NewIter but it also increments
@@ -2434,26 +2601,26 @@ func (r *Reader) NewIter(lower, upper []byte) (Iterator, error) {
 func (r *Reader) NewCompactionIter(bytesIterated *uint64) (Iterator, error) {
 	if r.Properties.IndexType == twoLevelIndex {
 		i := twoLevelIterPool.Get().(*twoLevelIterator)
-		err := i.init(r, nil /* lower */, nil /* upper */, nil, false /* useFilter */)
+		err := i.init(r, nil /* lower */, nil /* upper */, nil, false /* useFilter */, true /* fillCache */)
 		if err != nil {
 			return nil, err
 		}
 		i.setupForCompaction()
-		return &twoLevelCompactionIterator{
+		return r.wrapValues(&twoLevelCompactionIterator{
 			twoLevelIterator: i,
 			bytesIterated:    bytesIterated,
-		}, nil
+		}, true /* fillCache */, false /* lazy */), nil
 	}
 	i := singleLevelIterPool.Get().(*singleLevelIterator)
-	err := i.init(r, nil /* lower */, nil /* upper */, nil, false /* useFilter */)
+	err := i.init(r, nil /* lower */, nil /* upper */, nil, false /* useFilter */, true /* fillCache */)
 	if err != nil {
 		return nil, err
 	}
 	i.setupForCompaction()
-	return &compactionIterator{
+	return r.wrapValues(&compactionIterator{
 		singleLevelIterator: i,
 		bytesIterated:       bytesIterated,
-	}, nil
+	}, true /* fillCache */, false /* lazy */), nil
 }
 
 // NewRawRangeDelIter returns an internal iterator for the contents of the
@@ -2492,27 +2659,38 @@ func (r *Reader) NewRawRangeKeyIter() (keyspan.FragmentIterator, error) {
 	return i, nil
 }
 
-func (r *Reader) readIndex() (cache.Handle, error) {
+func (r *Reader) readIndex(fillCache bool) (cache.Handle, error) {
 	h, _, err :=
-		r.readBlock(r.indexBH, nil /* transform */, nil /* readaheadState */)
+		r.readBlock(r.indexBH, nil /* transform */, nil /* readaheadState */, fillCache)
 	return h, err
 }
 
-func (r *Reader) readFilter() (cache.Handle, error) {
+// filterKey returns the key to look up in the table filter for the given
+// key. If the comparer normalizes keys, the key is normalized into the
+// iterator's buffer. See Comparer.Normalize.
+func (i *singleLevelIterator) filterKey(key []byte) []byte {
+	if i.reader.normalize != nil {
+		i.filterKeyBuf = i.reader.normalize(i.filterKeyBuf[:0], key)
+		return i.filterKeyBuf
+	}
+	return key
+}
+
+func (r *Reader) readFilter(fillCache bool) (cache.Handle, error) {
 	h, _, err :=
-		r.readBlock(r.filterBH, nil /* transform */, nil /* readaheadState */)
+		r.readBlock(r.filterBH, nil /* transform */, nil /* readaheadState */, fillCache)
 	return h, err
 }
 
 func (r *Reader) readRangeDel() (cache.Handle, error) {
 	h, _, err :=
-		r.readBlock(r.rangeDelBH, r.rangeDelTransform, nil /* readaheadState */)
+		r.readBlock(r.rangeDelBH, r.rangeDelTransform, nil /* readaheadState */, true /* fillCache */)
 	return h, err
 }
 
 func (r *Reader) readRangeKey() (cache.Handle, error) {
 	h, _, err :=
-		r.readBlock(r.rangeKeyBH, nil /* transform */, nil /* readaheadState */)
+		r.readBlock(r.rangeKeyBH, nil /* transform */, nil /* readaheadState */, true /* fillCache */)
 	return h, err
 }
 
@@ -2538,9 +2716,34 @@ func checkChecksum(
 	return nil
 }
 
-// readBlock reads and decompresses a block from disk into memory.
+// blockReadLen returns the number of bytes to read to load the block with the
+// given handle: the length of the block and its trailer, rounded up to a
+// multiple of the table's block alignment if it has one, without reading
+// past the end of the file.
+func (r *Reader) blockReadLen(bh BlockHandle) int {
+	n := bh.Length + blockTrailerLen
+	if a := r.Properties.BlockAlignment; a > 1 && bh.Offset%a == 0 {
+		n = (n + a - 1) / a * a
+		if fileSize := r.footerBH.Offset + r.footerBH.Length; bh.Offset+n > fileSize {
+			n = fileSize - bh.Offset
+		}
+	}
+	return int(n)
+}
+
+// alignedReadBufPool holds the scratch buffers into which blocks are read when
+// the table's block alignment pads the read beyond the block's trailer.
+var alignedReadBufPool = sync.Pool{
+	New: func() interface{} {
+		return new([]byte)
+	},
+}
+
+// readBlock reads and decompresses a block from disk into memory. If fillCache
+// is false, a block that isn't already in the block cache is not added to it,
+// and is freed once the returned handle is released.
 func (r *Reader) readBlock(
-	bh BlockHandle, transform blockTransform, raState *readaheadState,
+	bh BlockHandle, transform blockTransform, raState *readaheadState, fillCache bool,
 ) (_ cache.Handle, cacheHit bool, _ error) {
 	if h := r.opts.Cache.Get(r.cacheID, r.fileNum, bh.Offset); h.Get() != nil {
 		if raState != nil {
@@ -2589,7 +2792,29 @@ func (r *Reader) readBlock(
 
 	v := r.opts.Cache.Alloc(int(bh.Length + blockTrailerLen))
 	b := v.Buf()
-	if _, err := file.ReadAt(b, int64(bh.Offset)); err != nil {
+	// An aligned read includes the padding following the block. Read it into
+	// a scratch buffer rather than allocating the padding from the block
+	// cache, and copy out the block.
+	readBuf := b
+	var scratch *[]byte
+	if n := r.blockReadLen(bh); n > len(b) {
+		scratch = alignedReadBufPool.Get().(*[]byte)
+		if cap(*scratch) < n {
+			*scratch = make([]byte, n)
+		}
+		readBuf = (*scratch)[:n]
+	}
+	var err error
+	if raState != nil {
+		err = raState.readAt(file, readBuf, int64(bh.Offset))
+	} else {
+		_, err = file.ReadAt(readBuf, int64(bh.Offset))
+	}
+	if scratch != nil {
+		copy(b, readBuf)
+		alignedReadBufPool.Put(scratch)
+	}
+	if err != nil {
 		r.opts.Cache.Free(v)
 		return cache.Handle{}, false, err
 	}
@@ -2603,7 +2828,7 @@ func (r *Reader) readBlock(
 	b = b[:bh.Length]
 	v.Truncate(len(b))
 
-	decoded, err := decompressBlock(r.opts.Cache, typ, b)
+	decoded, err := decompressBlock(r.opts.Cache, typ, b, r.dictDecoder)
 	if decoded != nil {
 		r.opts.Cache.Free(v)
 		v = decoded
@@ -2628,6 +2853,9 @@ func (r *Reader) readBlock(
 		v = newV
 	}
 
+	if !fillCache {
+		return cache.UncachedHandle(v), false, nil
+	}
 	h := r.opts.Cache.Set(r.cacheID, r.fileNum, bh.Offset, v)
 	return h, false, nil
 }
@@ -2676,7 +2904,7 @@ func (r *Reader) transformRangeDelV1(b []byte) ([]byte, error) {
 }
 
 func (r *Reader) readMetaindex(metaindexBH BlockHandle) error {
-	b, _, err := r.readBlock(metaindexBH, nil /* transform */, nil /* readaheadState */)
+	b, _, err := r.readBlock(metaindexBH, nil /* transform */, nil /* readaheadState */, true /* fillCache */)
 	if err != nil {
 		return err
 	}
@@ -2705,8 +2933,23 @@ func (r *Reader) readMetaindex(metaindexBH BlockHandle) error {
 		return err
 	}
 
+	// The compression dictionary must be loaded before reading any of the
+	// blocks that may be compressed with it.
+	if bh, ok := meta[metaCompressionDictName]; ok {
+		b, _, err = r.readBlock(bh, nil /* transform */, nil /* readaheadState */, true /* fillCache */)
+		if err != nil {
+			return err
+		}
+		r.compressionDictBH = bh
+		r.dictDecoder, err = newZstdDecoder(b.Get())
+		b.Release()
+		if err != nil {
+			return base.MarkCorruptionError(err)
+		}
+	}
+
 	if bh, ok := meta[metaPropertiesName]; ok {
-		b, _, err = r.readBlock(bh, nil /* transform */, nil /* readaheadState */)
+		b, _, err = r.readBlock(bh, nil /* transform */, nil /* readaheadState */, true /* fillCache */)
 		if err != nil {
 			return err
 		}
@@ -2731,6 +2974,10 @@ func (r *Reader) readMetaindex(metaindexBH BlockHandle) error {
 		r.rangeKeyBH = bh
 	}
 
+	if bh, ok := meta[metaValueIndexName]; ok {
+		r.valueIndexBH = bh
+	}
+
 	for name, fp := range r.opts.Filters {
 		types := []struct {
 			ftype  FilterType
@@ -2768,16 +3015,33 @@ func (r *Reader) Layout() (*Layout, error) {
 	}
 
 	l := &Layout{
-		Data:       make([]BlockHandleWithProperties, 0, r.Properties.NumDataBlocks),
-		Filter:     r.filterBH,
-		RangeDel:   r.rangeDelBH,
-		RangeKey:   r.rangeKeyBH,
-		Properties: r.propertiesBH,
-		MetaIndex:  r.metaIndexBH,
-		Footer:     r.footerBH,
+		Data:            make([]BlockHandleWithProperties, 0, r.Properties.NumDataBlocks),
+		Filter:          r.filterBH,
+		RangeDel:        r.rangeDelBH,
+		RangeKey:        r.rangeKeyBH,
+		CompressionDict: r.compressionDictBH,
+		ValueIndex:      r.valueIndexBH,
+		Properties:      r.propertiesBH,
+		MetaIndex:       r.metaIndexBH,
+		Footer:          r.footerBH,
+	}
+
+	if r.valueIndexBH.Length > 0 {
+		valueIndexH, _, err := r.readBlock(
+			r.valueIndexBH, nil /* transform */, nil /* readaheadState */, true /* fillCache */)
+		if err != nil {
+			return nil, err
+		}
+		for e := valueIndexH.Get(); len(e) >= valueIndexEntryLen; e = e[valueIndexEntryLen:] {
+			l.Value = append(l.Value, BlockHandle{
+				Offset: binary.LittleEndian.Uint64(e),
+				Length: binary.LittleEndian.Uint64(e[8:]),
+			})
+		}
+		valueIndexH.Release()
 	}
 
-	indexH, err := r.readIndex()
+	indexH, err := r.readIndex(true /* fillCache */)
 	if err != nil {
 		return nil, err
 	}
@@ -2815,7 +3079,7 @@ func (r *Reader) Layout() (*Layout, error) {
 			l.Index = append(l.Index, indexBH.BlockHandle)
 
 			subIndex, _, err := r.readBlock(
-				indexBH.BlockHandle, nil /* transform */, nil /* readaheadState */)
+				indexBH.BlockHandle, nil /* transform */, nil /* readaheadState */, true /* fillCache */)
 			if err != nil {
 				return nil, err
 			}
@@ -2860,7 +3124,9 @@ func (r *Reader) ValidateBlockChecksums() error {
 		blocks[i] = l.Data[i].BlockHandle
 	}
 	blocks = append(blocks, l.Index...)
-	blocks = append(blocks, l.TopIndex, l.Filter, l.RangeDel, l.RangeKey, l.Properties, l.MetaIndex)
+	blocks = append(blocks, l.Value...)
+	blocks = append(blocks, l.TopIndex, l.Filter, l.RangeDel, l.RangeKey, l.CompressionDict, l.ValueIndex,
+		l.Properties, l.MetaIndex)
 
 	// Sorting by offset ensures we are performing a sequential scan of the
 	// file.
@@ -2880,7 +3146,7 @@ func (r *Reader) ValidateBlockChecksums() error {
 		}
 
 		// Read the block, which validates the checksum.
-		h, _, err := r.readBlock(bh, nil /* transform */, blockRS)
+		h, _, err := r.readBlock(bh, nil /* transform */, blockRS, true /* fillCache */)
 		if err != nil {
 			return err
 		}
@@ -2900,7 +3166,9 @@ func (r *Reader) ValidateBlockChecksums() error {
 // before nor completely after the file's range.
 //
 // Only blocks containing point keys are considered. Range deletion and range
-// key blocks are not considered.
+// key blocks are not considered. Value blocks, which are interleaved with the
+// data blocks, are included if they lie between the data blocks overlapping
+// the range.
 //
 // TODO(ajkr): account for metablock space usage. Perhaps look at the fraction of
 // data blocks overlapped and add that same fraction of the metadata blocks to the
@@ -2910,7 +3178,7 @@ func (r *Reader) EstimateDiskUsage(start, end []byte) (uint64, error) {
 		return 0, r.err
 	}
 
-	indexH, err := r.readIndex()
+	indexH, err := r.readIndex(true /* fillCache */)
 	if err != nil {
 		return 0, err
 	}
@@ -2943,7 +3211,7 @@ func (r *Reader) EstimateDiskUsage(start, end []byte) (uint64, error) {
 			return 0, errCorruptIndexEntry
 		}
 		startIdxBlock, _, err := r.readBlock(
-			startIdxBH.BlockHandle, nil /* transform */, nil /* readaheadState */)
+			startIdxBH.BlockHandle, nil /* transform */, nil /* readaheadState */, true /* fillCache */)
 		if err != nil {
 			return 0, err
 		}
@@ -2964,7 +3232,7 @@ func (r *Reader) EstimateDiskUsage(start, end []byte) (uint64, error) {
 				return 0, errCorruptIndexEntry
 			}
 			endIdxBlock, _, err := r.readBlock(
-				endIdxBH.BlockHandle, nil /* transform */, nil /* readaheadState */)
+				endIdxBH.BlockHandle, nil /* transform */, nil /* readaheadState */, true /* fillCache */)
 			if err != nil {
 				return 0, err
 			}
@@ -2990,7 +3258,7 @@ func (r *Reader) EstimateDiskUsage(start, end []byte) (uint64, error) {
 
 	if endIdxIter == nil {
 		// The range spans beyond this file. Include data blocks through the last.
-		return r.Properties.DataSize - startBH.Offset, nil
+		return r.Properties.DataSize + r.Properties.ValueBlocksSize - startBH.Offset, nil
 	}
 	key, val = endIdxIter.SeekGE(end, base.SeekGEFlagsNone)
 	if key == nil {
@@ -2998,7 +3266,7 @@ func (r *Reader) EstimateDiskUsage(start, end []byte) (uint64, error) {
 			return 0, err
 		}
 		// The range spans beyond this file. Include data blocks through the last.
-		return r.Properties.DataSize - startBH.Offset, nil
+		return r.Properties.DataSize + r.Properties.ValueBlocksSize - startBH.Offset, nil
 	}
 	endBH, err := decodeBlockHandleWithProperties(val)
 	if err != nil {
@@ -3007,6 +3275,363 @@ func (r *Reader) EstimateDiskUsage(start, end []byte) (uint64, error) {
 	return endBH.Offset + endBH.Length + blockTrailerLen - startBH.Offset, nil
 }
 
+// SkipDataBlocks navigates the table's index from the data block that may
+// contain key to the data block n blocks after it, without loading any data
+// blocks, and returns the user key of that block's index separator. The
+// separator is greater than or equal to the last key of the block and less
+// than the first key of the following block, so a SeekGE to it lands at the
+// end of the block or the start of the next. If the table has n or fewer data
+// blocks from the one that may contain key onward, SkipDataBlocks returns a
+// nil key, and the number of data blocks it skipped is returned as skipped.
+func (r *Reader) SkipDataBlocks(key []byte, n int) (sep []byte, skipped int, err error) {
+	if r.err != nil {
+		return nil, 0, r.err
+	}
+
+	indexH, err := r.readIndex(true /* fillCache */)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer indexH.Release()
+	topIter, err := newBlockIter(r.Compare, indexH.Get())
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// scan steps the index iterator from k over the data blocks, returning
+	// the separator once n blocks have been skipped.
+	scan := func(iter *blockIter, k *InternalKey) []byte {
+		for ; k != nil; k, _ = iter.Next() {
+			if skipped == n {
+				return append([]byte(nil), k.UserKey...)
+			}
+			skipped++
+		}
+		return nil
+	}
+
+	if r.Properties.IndexPartitions == 0 {
+		k, _ := topIter.SeekGE(key, base.SeekGEFlagsNone)
+		return scan(topIter, k), skipped, topIter.Error()
+	}
+
+	// In a partitioned index, step through the index partitions from the one
+	// that may contain key, reading each through the block cache.
+	first := true
+	for k, v := topIter.SeekGE(key, base.SeekGEFlagsNone); k != nil; k, v = topIter.Next() {
+		bh, err := decodeBlockHandleWithProperties(v)
+		if err != nil {
+			return nil, 0, errCorruptIndexEntry
+		}
+		idxBlock, _, err := r.readBlock(
+			bh.BlockHandle, nil /* transform */, nil /* readaheadState */, true /* fillCache */)
+		if err != nil {
+			return nil, 0, err
+		}
+		iter, err := newBlockIter(r.Compare, idxBlock.Get())
+		if err != nil {
+			idxBlock.Release()
+			return nil, 0, err
+		}
+		var ik *InternalKey
+		if first {
+			ik, _ = iter.SeekGE(key, base.SeekGEFlagsNone)
+			first = false
+		} else {
+			ik, _ = iter.First()
+		}
+		sep = scan(iter, ik)
+		err = iter.Error()
+		idxBlock.Release()
+		if sep != nil || err != nil {
+			return sep, skipped, err
+		}
+	}
+	return nil, skipped, topIter.Error()
+}
+
+// CompressionStats describes the compressibility of a sample of data blocks.
+type CompressionStats struct {
+	// Blocks is the number of data blocks sampled.
+	Blocks int
+	// UncompressedSize is the total uncompressed size of the sampled blocks.
+	UncompressedSize uint64
+	// CompressedSize is the total size of the sampled blocks as stored in the
+	// sstables, compressed with the codecs they were written with.
+	CompressedSize uint64
+	// CodecSizes holds the total size of the sampled blocks when recompressed
+	// with each of the codecs requested, without a compression dictionary. As
+	// when writing an sstable, a block that a codec shrinks by less than 12.5%
+	// is counted at its uncompressed size.
+	CodecSizes map[Compression]uint64
+}
+
+// Merge adds the sampled blocks in o to s.
+func (s *CompressionStats) Merge(o CompressionStats) {
+	s.Blocks += o.Blocks
+	s.UncompressedSize += o.UncompressedSize
+	s.CompressedSize += o.CompressedSize
+	for c, size := range o.CodecSizes {
+		if s.CodecSizes == nil {
+			s.CodecSizes = make(map[Compression]uint64)
+		}
+		s.CodecSizes[c] += size
+	}
+}
+
+// Ratio returns the ratio of the compressed size of the sampled blocks to their
+// uncompressed size, or 0 if no blocks were sampled.
+func (s CompressionStats) Ratio() float64 {
+	if s.UncompressedSize == 0 {
+		return 0
+	}
+	return float64(s.CompressedSize) / float64(s.UncompressedSize)
+}
+
+// CodecRatio returns the ratio of the size of the sampled blocks recompressed
+// with codec c to their uncompressed size, or 0 if no blocks were sampled or
+// c was not requested.
+func (s CompressionStats) CodecRatio(c Compression) float64 {
+	size, ok := s.CodecSizes[c]
+	if !ok || s.UncompressedSize == 0 {
+		return 0
+	}
+	return float64(size) / float64(s.UncompressedSize)
+}
+
+// EstimateCompression samples up to maxBlocks of the data blocks that overlap
+// the user key range [start, end), spread evenly across the range, and returns
+// their compressed and uncompressed sizes. Each sampled block is additionally
+// recompressed with each of codecs. A nil end is treated as unbounded. The
+// sampled blocks are read directly from the file, bypassing the block cache.
+func (r *Reader) EstimateCompression(
+	start, end []byte, maxBlocks int, codecs ...Compression,
+) (CompressionStats, error) {
+	var stats CompressionStats
+	if r.err != nil {
+		return stats, r.err
+	}
+	handles, err := r.dataBlocksInRange(start, end)
+	if err != nil {
+		return stats, err
+	}
+	if len(handles) > maxBlocks {
+		sampled := make([]BlockHandle, maxBlocks)
+		for i := range sampled {
+			sampled[i] = handles[i*len(handles)/maxBlocks]
+		}
+		handles = sampled
+	}
+	if len(codecs) > 0 {
+		stats.CodecSizes = make(map[Compression]uint64, len(codecs))
+		for _, c := range codecs {
+			stats.CodecSizes[c] = 0
+		}
+	}
+
+	var buf, compressedBuf []byte
+	for _, bh := range handles {
+		if n := int(bh.Length + blockTrailerLen); cap(buf) < n {
+			buf = make([]byte, n)
+		} else {
+			buf = buf[:n]
+		}
+		if _, err := r.file.ReadAt(buf, int64(bh.Offset)); err != nil {
+			return stats, err
+		}
+		if err := checkChecksum(r.checksumType, buf, bh, r.fileNum); err != nil {
+			return stats, err
+		}
+		typ := blockType(buf[bh.Length])
+		raw := buf[:bh.Length]
+		uncompressed := raw
+		if typ != noCompressionBlockType {
+			decompressedLen, prefix, err := decompressedLen(typ, raw)
+			if err != nil {
+				return stats, err
+			}
+			uncompressed, err = decompressInto(typ, raw[prefix:], make([]byte, decompressedLen), r.dictDecoder)
+			if err != nil {
+				return stats, err
+			}
+		}
+		stats.Blocks++
+		stats.UncompressedSize += uint64(len(uncompressed))
+		stats.CompressedSize += bh.Length
+		for _, c := range codecs {
+			var compressed []byte
+			_, compressed = compressBlock(c, uncompressed, compressedBuf, nil /* dict */)
+			if len(compressed) >= len(uncompressed)-len(uncompressed)/8 {
+				compressed = uncompressed
+			} else if cap(compressed) > cap(compressedBuf) {
+				compressedBuf = compressed[:cap(compressed)]
+			}
+			stats.CodecSizes[c] += uint64(len(compressed))
+		}
+	}
+	return stats, nil
+}
+
+// DataBlockBoundary describes a data block of an sstable by its extent in the
+// user key space and its size.
+type DataBlockBoundary struct {
+	// Key is the separator key of the block's index entry, which is greater
+	// than or equal to the user keys of the block and less than those of the
+	// next block. It need not be a key within the sstable.
+	Key []byte
+	// Size is the size of the block in the sstable, including its trailer.
+	Size uint64
+}
+
+// DataBlockBoundaries returns the boundaries of the data blocks that may
+// contain keys within the user key range [start, end), in order. A nil end is
+// treated as unbounded.
+func (r *Reader) DataBlockBoundaries(start, end []byte) ([]DataBlockBoundary, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	var boundaries []DataBlockBoundary
+	err := r.visitDataBlocksInRange(start, end, func(key []byte, bh BlockHandle) {
+		boundaries = append(boundaries, DataBlockBoundary{
+			Key:  append([]byte(nil), key...),
+			Size: bh.Length + blockTrailerLen,
+		})
+	})
+	return boundaries, err
+}
+
+// dataBlocksInRange returns the handles of the data blocks that may contain
+// keys within the user key range [start, end), in order. A nil end is treated
+// as unbounded.
+func (r *Reader) dataBlocksInRange(start, end []byte) ([]BlockHandle, error) {
+	var handles []BlockHandle
+	err := r.visitDataBlocksInRange(start, end, func(_ []byte, bh BlockHandle) {
+		handles = append(handles, bh)
+	})
+	return handles, err
+}
+
+// visitDataBlocksInRange calls fn, in order, with the separator user key and
+// the handle of each data block that may contain keys within the user key
+// range [start, end). A nil end is treated as unbounded. The key passed to fn
+// is only valid for the duration of the call.
+func (r *Reader) visitDataBlocksInRange(
+	start, end []byte, fn func(key []byte, bh BlockHandle),
+) error {
+	indexH, err := r.readIndex(true /* fillCache */)
+	if err != nil {
+		return err
+	}
+	defer indexH.Release()
+
+	// visitBlocks visits the data blocks referenced by the index block data,
+	// returning true once a block extending to or beyond end was visited.
+	visitBlocks := func(data []byte) (done bool, err error) {
+		iter, err := newBlockIter(r.Compare, data)
+		if err != nil {
+			return false, err
+		}
+		// Each index entry's key is greater than or equal to the keys of the
+		// block it references, and less than the keys of the next block.
+		for key, value := iter.SeekGE(start, base.SeekGEFlagsNone); key != nil; key, value = iter.Next() {
+			bh, err := decodeBlockHandleWithProperties(value)
+			if err != nil {
+				return false, errCorruptIndexEntry
+			}
+			fn(key.UserKey, bh.BlockHandle)
+			if end != nil && r.Compare(key.UserKey, end) >= 0 {
+				return true, nil
+			}
+		}
+		return false, iter.Close()
+	}
+
+	if r.Properties.IndexPartitions == 0 {
+		_, err := visitBlocks(indexH.Get())
+		return err
+	}
+	topIter, err := newBlockIter(r.Compare, indexH.Get())
+	if err != nil {
+		return err
+	}
+	for key, value := topIter.SeekGE(start, base.SeekGEFlagsNone); key != nil; key, value = topIter.Next() {
+		indexBH, err := decodeBlockHandleWithProperties(value)
+		if err != nil {
+			return errCorruptIndexEntry
+		}
+		subIndex, _, err := r.readBlock(indexBH.BlockHandle, nil /* transform */, nil /* readaheadState */, true /* fillCache */)
+		if err != nil {
+			return err
+		}
+		done, err := visitBlocks(subIndex.Get())
+		subIndex.Release()
+		if done || err != nil {
+			return err
+		}
+	}
+	return topIter.Close()
+}
+
+// LoadMetadataBlocks reads the table's index blocks, including the
+// partitions of a two-level index, along with its filter, range deletion and
+// range key blocks, into the block cache. It returns the total in-memory size
+// of the blocks, which includes the blocks that were already cached.
+func (r *Reader) LoadMetadataBlocks() (size uint64, _ error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	load := func(h cache.Handle, err error) error {
+		if err != nil {
+			return err
+		}
+		size += uint64(len(h.Get()))
+		h.Release()
+		return nil
+	}
+
+	indexH, err := r.readIndex(true /* fillCache */)
+	if err != nil {
+		return 0, err
+	}
+	defer indexH.Release()
+	size += uint64(len(indexH.Get()))
+	if r.Properties.IndexPartitions > 0 {
+		topIter, err := newBlockIter(r.Compare, indexH.Get())
+		if err != nil {
+			return 0, err
+		}
+		for key, value := topIter.First(); key != nil; key, value = topIter.Next() {
+			indexBH, err := decodeBlockHandleWithProperties(value)
+			if err != nil {
+				return 0, errCorruptIndexEntry
+			}
+			h, _, err := r.readBlock(indexBH.BlockHandle, nil /* transform */, nil /* readaheadState */, true /* fillCache */)
+			if err := load(h, err); err != nil {
+				return 0, err
+			}
+		}
+		if err := topIter.Close(); err != nil {
+			return 0, err
+		}
+	}
+	if r.tableFilter != nil {
+		if err := load(r.readFilter(true /* fillCache */)); err != nil {
+			return 0, err
+		}
+	}
+	if r.rangeDelBH.Length > 0 {
+		if err := load(r.readRangeDel()); err != nil {
+			return 0, err
+		}
+	}
+	if r.rangeKeyBH.Length > 0 {
+		if err := load(r.readRangeKey()); err != nil {
+			return 0, err
+		}
+	}
+	return size, nil
+}
+
 // TableFormat returns the format version for the table.
 func (r *Reader) TableFormat() (TableFormat, error) {
 	if r.err != nil {
@@ -3071,9 +3696,10 @@ func NewReader(f ReadableFile, o ReaderOptions, extraOpts ...ReaderOption) (*Rea
 	r.footerBH = footer.footerBH
 
 	if r.Properties.ComparerName == "" || o.Comparer.Name == r.Properties.ComparerName {
-		r.Compare = o.Comparer.Compare
+		r.Compare = base.FixedPrefixCompare(o.Comparer.Compare, o.Comparer.PrefixLen)
 		r.FormatKey = o.Comparer.FormatKey
 		r.Split = o.Comparer.Split
+		r.normalize = o.Comparer.Normalize
 	}
 
 	if o.MergerName == r.Properties.MergerName {
@@ -3104,21 +3730,69 @@ func NewReader(f ReadableFile, o ReaderOptions, extraOpts ...ReaderOption) (*Rea
 	return r, nil
 }
 
+// Readable is the minimal interface required to read an sstable: positional
+// reads, and the sstable's size. Unlike ReadableFile, a Readable needn't be a
+// file on a local filesystem. For example, it may read an sstable held in
+// object storage, issuing a range request for each call to ReadAt.
+type Readable interface {
+	io.ReaderAt
+	io.Closer
+	// Size returns the size of the sstable in bytes.
+	Size() int64
+}
+
+// NewReaderFromReadable returns a new table reader for the sstable read by
+// readable. Closing the reader will close readable.
+//
+// Every block read by the reader that is not present in the block cache is
+// read with a separate call to ReadAt, sized to the block. Opening the reader
+// reads the footer, metaindex and properties blocks, and the index and filter
+// blocks are read on first use. A scan then reads each data block in turn,
+// while a point lookup reads a data block and, for two-level indexes, an index
+// block. There is no OS-level readahead, though compaction iterators coalesce
+// their reads if ReaderOptions.CompactionReadaheadSize is set. When each ReadAt
+// is expensive, as with a remote readable, pair the reader with a block cache
+// large enough to hold at least the index and filter blocks of the tables
+// being read.
+func NewReaderFromReadable(
+	readable Readable, o ReaderOptions, extraOpts ...ReaderOption,
+) (*Reader, error) {
+	if readable == nil {
+		return NewReader(nil, o, extraOpts...)
+	}
+	return NewReader(readableFile{readable}, o, extraOpts...)
+}
+
+// readableFile adapts a Readable to the ReadableFile interface.
+type readableFile struct {
+	Readable
+}
+
+var _ ReadableFile = readableFile{}
+
+// Stat implements ReadableFile. The returned os.FileInfo only supports Size.
+func (f readableFile) Stat() (os.FileInfo, error) {
+	return sizeOnlyStat(f.Size()), nil
+}
+
 // Layout describes the block organization of an sstable.
 type Layout struct {
 	// NOTE: changes to fields in this struct should also be reflected in
 	// ValidateBlockChecksums, which validates a static list of BlockHandles
 	// referenced in this struct.
 
-	Data       []BlockHandleWithProperties
-	Index      []BlockHandle
-	TopIndex   BlockHandle
-	Filter     BlockHandle
-	RangeDel   BlockHandle
-	RangeKey   BlockHandle
-	Properties BlockHandle
-	MetaIndex  BlockHandle
-	Footer     BlockHandle
+	Data            []BlockHandleWithProperties
+	Index           []BlockHandle
+	TopIndex        BlockHandle
+	Filter          BlockHandle
+	RangeDel        BlockHandle
+	RangeKey        BlockHandle
+	CompressionDict BlockHandle
+	Value           []BlockHandle
+	ValueIndex      BlockHandle
+	Properties      BlockHandle
+	MetaIndex       BlockHandle
+	Footer          BlockHandle
 }
 
 // Describe returns a description of the layout. If the verbose parameter is
@@ -3150,6 +3824,15 @@ func (l *Layout) Describe(
 	if l.RangeKey.Length != 0 {
 		blocks = append(blocks, block{l.RangeKey, "range-key"})
 	}
+	if l.CompressionDict.Length != 0 {
+		blocks = append(blocks, block{l.CompressionDict, "compression-dict"})
+	}
+	for i := range l.Value {
+		blocks = append(blocks, block{l.Value[i], "value"})
+	}
+	if l.ValueIndex.Length != 0 {
+		blocks = append(blocks, block{l.ValueIndex, "value-index"})
+	}
 	if l.Properties.Length != 0 {
 		blocks = append(blocks, block{l.Properties, "properties"})
 	}
@@ -3221,7 +3904,7 @@ func (l *Layout) Describe(
 			continue
 		}
 
-		h, _, err := r.readBlock(b.BlockHandle, nil /* transform */, nil /* readaheadState */)
+		h, _, err := r.readBlock(b.BlockHandle, nil /* transform */, nil /* readaheadState */, true /* fillCache */)
 		if err != nil {
 			fmt.Fprintf(w, "  [err: %s]\n", err)
 			continue
@@ -3286,7 +3969,20 @@ func (l *Layout) Describe(
 				formatIsRestart(iter.data, iter.restarts, iter.numRestarts, iter.offset)
 				if fmtRecord != nil {
 					fmt.Fprintf(w, "              ")
-					fmtRecord(key, value)
+					if b.name == "data" && hasValuePrefix(r.tableFormat, key.Kind()) && len(value) > 0 {
+						if value[0] == valuePrefixChunked {
+							if h, err := decodeValueHandle(value[1:]); err != nil {
+								fmt.Fprintf(w, "[err: %s]\n", err)
+							} else {
+								fmt.Fprintf(w, "[chunked value: %d bytes in value blocks [%d,%d)]\n",
+									h.valueLen, h.firstBlock, h.firstBlock+h.numBlocks)
+							}
+						} else {
+							fmtRecord(key, value[1:])
+						}
+					} else {
+						fmtRecord(key, value)
+					}
 				}
 
 				if base.InternalCompare(r.Compare, lastKey, *key) >= 0 {
@@ -3337,6 +4033,8 @@ func (l *Layout) Describe(
 			}
 			formatRestarts(iter.data, iter.restarts, iter.numRestarts)
 			formatTrailer()
+		case "value", "value-index":
+			formatTrailer()
 		}
 
 		h.Release()
@@ -14,6 +14,7 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"testing"
@@ -38,7 +39,7 @@ func (r *Reader) get(key []byte) (value []byte, err error) {
 	}
 
 	if r.tableFilter != nil {
-		dataH, err := r.readFilter()
+		dataH, err := r.readFilter(true /* fillCache */)
 		if err != nil {
 			return nil, err
 		}
@@ -254,6 +255,129 @@ func TestHamletReader(t *testing.T) {
 	}
 }
 
+func TestReaderEstimateCompression(t *testing.T) {
+	for _, prebuiltSST := range []string{
+		"testdata/h.sst",
+		"testdata/h.no-compression.sst",
+		"testdata/h.no-compression.two_level_index.sst",
+		"testdata/h.zstd-compression.sst",
+	} {
+		t.Run(fmt.Sprintf("sst=%s", prebuiltSST), func(t *testing.T) {
+			f, err := os.Open(filepath.FromSlash(prebuiltSST))
+			require.NoError(t, err)
+			r, err := NewReader(f, ReaderOptions{})
+			require.NoError(t, err)
+			defer r.Close()
+
+			all, err := r.EstimateCompression(nil, nil, math.MaxInt32,
+				NoCompression, SnappyCompression, ZstdCompression)
+			require.NoError(t, err)
+			require.EqualValues(t, r.Properties.NumDataBlocks, all.Blocks)
+			require.Equal(t, all.UncompressedSize, all.CodecSizes[NoCompression])
+			require.Equal(t, 1.0, all.CodecRatio(NoCompression))
+			require.Less(t, all.CodecRatio(SnappyCompression), 1.0)
+			require.Less(t, all.CodecRatio(ZstdCompression), 1.0)
+			if strings.Contains(prebuiltSST, "no-compression") {
+				require.Equal(t, 1.0, all.Ratio())
+			} else {
+				require.Less(t, all.Ratio(), 1.0)
+			}
+
+			// Sampling is bounded.
+			sampled, err := r.EstimateCompression(nil, nil, 4)
+			require.NoError(t, err)
+			require.Equal(t, 4, sampled.Blocks)
+			require.Nil(t, sampled.CodecSizes)
+
+			// Only the blocks overlapping the range are sampled.
+			some, err := r.EstimateCompression([]byte("borrower"), []byte("lender"), math.MaxInt32)
+			require.NoError(t, err)
+			require.Less(t, 0, some.Blocks)
+			require.Less(t, some.Blocks, all.Blocks)
+			none, err := r.EstimateCompression([]byte("zzz"), nil, math.MaxInt32)
+			require.NoError(t, err)
+			require.Zero(t, none.Blocks)
+			require.Zero(t, none.Ratio())
+		})
+	}
+}
+
+func TestReaderDataBlockBoundaries(t *testing.T) {
+	for _, prebuiltSST := range []string{
+		"testdata/h.sst",
+		"testdata/h.no-compression.two_level_index.sst",
+	} {
+		t.Run(fmt.Sprintf("sst=%s", prebuiltSST), func(t *testing.T) {
+			f, err := os.Open(filepath.FromSlash(prebuiltSST))
+			require.NoError(t, err)
+			r, err := NewReader(f, ReaderOptions{})
+			require.NoError(t, err)
+			defer r.Close()
+
+			all, err := r.DataBlockBoundaries(nil, nil)
+			require.NoError(t, err)
+			require.EqualValues(t, r.Properties.NumDataBlocks, len(all))
+			var size uint64
+			for i := range all {
+				if i > 0 {
+					require.Less(t, r.Compare(all[i-1].Key, all[i].Key), 0)
+				}
+				size += all[i].Size
+			}
+			require.Equal(t, r.Properties.DataSize, size)
+
+			// Only the blocks overlapping the range are returned.
+			some, err := r.DataBlockBoundaries([]byte("borrower"), []byte("lender"))
+			require.NoError(t, err)
+			require.Less(t, 0, len(some))
+			require.Less(t, len(some), len(all))
+			require.GreaterOrEqual(t, r.Compare(some[0].Key, []byte("borrower")), 0)
+			require.GreaterOrEqual(t, r.Compare(some[len(some)-1].Key, []byte("lender")), 0)
+			none, err := r.DataBlockBoundaries([]byte("zzz"), nil)
+			require.NoError(t, err)
+			require.Empty(t, none)
+		})
+	}
+}
+
+func TestReaderSkipDataBlocks(t *testing.T) {
+	for _, prebuiltSST := range []string{
+		"testdata/h.sst",
+		"testdata/h.no-compression.two_level_index.sst",
+	} {
+		t.Run(fmt.Sprintf("sst=%s", prebuiltSST), func(t *testing.T) {
+			f, err := os.Open(filepath.FromSlash(prebuiltSST))
+			require.NoError(t, err)
+			r, err := NewReader(f, ReaderOptions{})
+			require.NoError(t, err)
+			defer r.Close()
+
+			all, err := r.DataBlockBoundaries(nil, nil)
+			require.NoError(t, err)
+			for _, key := range []string{"", "borrower", "lender", "zzz"} {
+				// b is the data block that may contain key.
+				b := sort.Search(len(all), func(i int) bool {
+					return r.Compare(all[i].Key, []byte(key)) >= 0
+				})
+				for _, n := range []int{0, 1, 7, len(all) - b - 1, len(all) - b, len(all)} {
+					if n < 0 {
+						continue
+					}
+					sep, skipped, err := r.SkipDataBlocks([]byte(key), n)
+					require.NoError(t, err)
+					if b+n < len(all) {
+						require.Equal(t, all[b+n].Key, sep)
+						require.Equal(t, n, skipped)
+					} else {
+						require.Nil(t, sep)
+						require.Equal(t, len(all)-b, skipped)
+					}
+				}
+			}
+		})
+	}
+}
+
 func TestReaderStats(t *testing.T) {
 	tableOpt := WriterOptions{
 		BlockSize:      30,
@@ -565,6 +689,153 @@ func TestCompactionIteratorSetupForCompaction(t *testing.T) {
 	}
 }
 
+// readCountingFile wraps a ReadableFile, counting the calls to ReadAt and
+// optionally delaying each call to simulate storage with high read latency.
+type readCountingFile struct {
+	ReadableFile
+	reads   int
+	latency time.Duration
+}
+
+func (f *readCountingFile) ReadAt(p []byte, off int64) (int, error) {
+	f.reads++
+	if f.latency > 0 {
+		time.Sleep(f.latency)
+	}
+	return f.ReadableFile.ReadAt(p, off)
+}
+
+func buildReadaheadTestTable(t testing.TB, numEntries uint64) []byte {
+	f := &memFile{}
+	w := NewWriter(f, WriterOptions{BlockSize: 4096, IndexBlockSize: math.MaxInt32})
+	var ikey InternalKey
+	for i := uint64(0); i < numEntries; i++ {
+		key := make([]byte, 8)
+		binary.BigEndian.PutUint64(key, i)
+		ikey.UserKey = key
+		require.NoError(t, w.Add(ikey, make([]byte, i%100)))
+	}
+	require.NoError(t, w.Close())
+	return f.Data()
+}
+
+func TestCompactionIteratorReadahead(t *testing.T) {
+	data := buildReadaheadTestTable(t, 1e5)
+	scan := func(readaheadSize int) (keys int, reads int) {
+		f := &readCountingFile{ReadableFile: memReader{data, bytes.NewReader(data), sizeOnlyStat(len(data))}}
+		r, err := NewReader(f, ReaderOptions{CompactionReadaheadSize: readaheadSize})
+		require.NoError(t, err)
+		defer r.Close()
+		i, err := r.NewCompactionIter(new(uint64))
+		require.NoError(t, err)
+		f.reads = 0
+		for k, _ := i.First(); k != nil; k, _ = i.Next() {
+			require.Equal(t, uint64(keys), binary.BigEndian.Uint64(k.UserKey))
+			keys++
+		}
+		require.NoError(t, i.Close())
+		return keys, f.reads
+	}
+
+	keys, reads := scan(0)
+	require.Equal(t, int(1e5), keys)
+	for _, size := range []int{1 << 10, 64 << 10, 1 << 20, 64 << 20} {
+		t.Run(fmt.Sprintf("size=%d", size), func(t *testing.T) {
+			keysWithReadahead, readsWithReadahead := scan(size)
+			require.Equal(t, keys, keysWithReadahead)
+			// A buffer smaller than the blocks reads each block directly.
+			if size < 4096 {
+				require.Equal(t, reads, readsWithReadahead)
+				return
+			}
+			require.Less(t, readsWithReadahead, reads)
+			require.LessOrEqual(t, readsWithReadahead, len(data)/(size-4096)+2)
+		})
+	}
+}
+
+func BenchmarkCompactionIteratorReadahead(b *testing.B) {
+	data := buildReadaheadTestTable(b, 1e5)
+	for _, size := range []int{0, 64 << 10, 256 << 10, 2 << 20} {
+		b.Run(fmt.Sprintf("size=%d", size), func(b *testing.B) {
+			f := &readCountingFile{
+				ReadableFile: memReader{data, bytes.NewReader(data), sizeOnlyStat(len(data))},
+				latency:      100 * time.Microsecond,
+			}
+			r, err := NewReader(f, ReaderOptions{CompactionReadaheadSize: size})
+			require.NoError(b, err)
+			defer r.Close()
+			b.SetBytes(int64(len(data)))
+			b.ResetTimer()
+			for n := 0; n < b.N; n++ {
+				i, err := r.NewCompactionIter(new(uint64))
+				require.NoError(b, err)
+				for k, _ := i.First(); k != nil; k, _ = i.Next() {
+				}
+				require.NoError(b, i.Close())
+			}
+		})
+	}
+}
+
+// countingReadable is a Readable over an in-memory sstable, counting the calls
+// to ReadAt.
+type countingReadable struct {
+	data   []byte
+	reads  int
+	closed bool
+}
+
+func (r *countingReadable) ReadAt(p []byte, off int64) (int, error) {
+	r.reads++
+	return bytes.NewReader(r.data).ReadAt(p, off)
+}
+
+func (r *countingReadable) Close() error {
+	r.closed = true
+	return nil
+}
+
+func (r *countingReadable) Size() int64 { return int64(len(r.data)) }
+
+func TestReaderFromReadable(t *testing.T) {
+	const numEntries = 1e4
+	data := buildReadaheadTestTable(t, numEntries)
+	c := cache.New(1 << 20)
+	defer c.Unref()
+	readable := &countingReadable{data: data}
+	r, err := NewReaderFromReadable(readable, ReaderOptions{Cache: c})
+	require.NoError(t, err)
+
+	scan := func() int {
+		reads := readable.reads
+		iter, err := r.NewIter(nil /* lower */, nil /* upper */)
+		require.NoError(t, err)
+		var keys uint64
+		for k, _ := iter.First(); k != nil; k, _ = iter.Next() {
+			require.Equal(t, keys, binary.BigEndian.Uint64(k.UserKey))
+			keys++
+		}
+		require.NoError(t, iter.Close())
+		require.EqualValues(t, numEntries, keys)
+		return readable.reads - reads
+	}
+
+	// Reading the layout reads the index block, after which a scan reads each
+	// data block once.
+	l, err := r.Layout()
+	require.NoError(t, err)
+	require.Equal(t, len(l.Data), scan())
+	// The blocks are then served from the cache.
+	require.Zero(t, scan())
+
+	require.NoError(t, r.Close())
+	require.True(t, readable.closed)
+
+	_, err = NewReaderFromReadable(nil, ReaderOptions{})
+	require.Error(t, err)
+}
+
 func TestMaybeReadahead(t *testing.T) {
 	var rs readaheadState
 	datadriven.RunTest(t, "testdata/readahead", func(d *datadriven.TestData) string {
@@ -700,6 +971,309 @@ func TestReaderChecksumErrors(t *testing.T) {
 	}
 }
 
+func TestReaderSkipCorruptBlocks(t *testing.T) {
+	for _, twoLevelIndex := range []bool{false, true} {
+		t.Run(fmt.Sprintf("two-level-index=%t", twoLevelIndex), func(t *testing.T) {
+			mem := vfs.NewMem()
+
+			// Create an sstable with 3 data blocks, each holding a single key.
+			const blockSize = 32
+			keys := [][]byte{
+				bytes.Repeat([]byte("a"), blockSize),
+				bytes.Repeat([]byte("b"), blockSize),
+				bytes.Repeat([]byte("c"), blockSize),
+			}
+			{
+				f, err := mem.Create("test")
+				require.NoError(t, err)
+				indexBlockSize := 4096
+				if twoLevelIndex {
+					indexBlockSize = 1
+				}
+				w := NewWriter(f, WriterOptions{
+					BlockSize:      blockSize,
+					IndexBlockSize: indexBlockSize,
+				})
+				for _, k := range keys {
+					require.NoError(t, w.Set(k, nil))
+				}
+				require.NoError(t, w.Close())
+			}
+
+			var layout *Layout
+			{
+				f, err := mem.Open("test")
+				require.NoError(t, err)
+				r, err := NewReader(f, ReaderOptions{})
+				require.NoError(t, err)
+				layout, err = r.Layout()
+				require.NoError(t, err)
+				require.EqualValues(t, len(layout.Data), 3)
+				require.NoError(t, r.Close())
+			}
+
+			// Corrupt each data block in turn and, for two-level indexes, each
+			// second-level index block. Both map to a single key.
+			var blocks []BlockHandle
+			for _, bh := range layout.Data {
+				blocks = append(blocks, bh.BlockHandle)
+			}
+			if twoLevelIndex {
+				require.EqualValues(t, len(layout.Index), 3)
+				blocks = append(blocks, layout.Index...)
+			}
+			for j, bh := range blocks {
+				lost := keys[j%len(keys)]
+
+				orig, err := mem.Open("test")
+				require.NoError(t, err)
+				data, err := ioutil.ReadAll(orig)
+				require.NoError(t, err)
+				require.NoError(t, orig.Close())
+				data[bh.Offset] ^= 0xff
+				corrupted, err := mem.Create("corrupted")
+				require.NoError(t, err)
+				_, err = corrupted.Write(data)
+				require.NoError(t, err)
+				require.NoError(t, corrupted.Close())
+
+				corrupted, err = mem.Open("corrupted")
+				require.NoError(t, err)
+				r, err := NewReader(corrupted, ReaderOptions{})
+				require.NoError(t, err)
+
+				var calls int
+				onCorruption := func(fileNum base.FileNum, offset int64, err error) CorruptionAction {
+					calls++
+					require.EqualValues(t, bh.Offset, offset)
+					require.True(t, errors.Is(err, base.ErrCorruption))
+					var cbe *CorruptBlockError
+					require.True(t, errors.As(err, &cbe))
+					require.LessOrEqual(t, bytes.Compare(lost, cbe.LastKey), 0)
+					return CorruptionSkipBlock
+				}
+
+				var expected [][]byte
+				for _, k := range keys {
+					if !bytes.Equal(k, lost) {
+						expected = append(expected, k)
+					}
+				}
+
+				iter, err := r.NewIterWithBlockPropertyFilters(
+					nil, nil, nil, true /* useFilterBlock */, IterOptions{OnCorruption: onCorruption})
+				require.NoError(t, err)
+				var got [][]byte
+				for k, _ := iter.First(); k != nil; k, _ = iter.Next() {
+					got = append(got, append([]byte(nil), k.UserKey...))
+				}
+				require.NoError(t, iter.Error())
+				require.Equal(t, expected, got)
+				got = got[:0]
+				for k, _ := iter.Last(); k != nil; k, _ = iter.Prev() {
+					got = append([][]byte{append([]byte(nil), k.UserKey...)}, got...)
+				}
+				require.NoError(t, iter.Error())
+				require.Equal(t, expected, got)
+				require.NoError(t, iter.Close())
+				require.Equal(t, 2, calls)
+
+				// Without a handler, or if the handler declines to skip the
+				// block, the corruption is surfaced.
+				iter, err = r.NewIterWithBlockPropertyFilters(nil, nil, nil, true /* useFilterBlock */, IterOptions{
+					OnCorruption: func(base.FileNum, int64, error) CorruptionAction { return CorruptionFail },
+				})
+				require.NoError(t, err)
+				for k, _ := iter.First(); k != nil; k, _ = iter.Next() {
+				}
+				require.Regexp(t, `checksum mismatch`, iter.Error())
+				require.Regexp(t, `checksum mismatch`, iter.Close())
+
+				require.NoError(t, r.Close())
+			}
+		})
+	}
+}
+
+func TestReaderDisableCacheFill(t *testing.T) {
+	for _, twoLevelIndex := range []bool{false, true} {
+		t.Run(fmt.Sprintf("two-level-index=%t", twoLevelIndex), func(t *testing.T) {
+			indexBlockSize := 4096
+			if twoLevelIndex {
+				indexBlockSize = 1
+			}
+			filter := bloom.FilterPolicy(10)
+			f := &memFile{}
+			w := NewWriter(f, WriterOptions{
+				BlockSize:      32,
+				IndexBlockSize: indexBlockSize,
+				FilterPolicy:   filter,
+			})
+			const numKeys = 100
+			for i := 0; i < numKeys; i++ {
+				require.NoError(t, w.Set([]byte(fmt.Sprintf("%04d", i)), []byte("value")))
+			}
+			require.NoError(t, w.Close())
+
+			c := cache.New(1 << 20)
+			defer c.Unref()
+			r, err := NewMemReader(f.Data(), ReaderOptions{
+				Cache:   c,
+				Filters: map[string]FilterPolicy{filter.Name(): filter},
+			})
+			require.NoError(t, err)
+			defer func() { require.NoError(t, r.Close()) }()
+
+			// Scan the table, and perform a prefix seek to read the filter block.
+			scan := func(fillCache bool) {
+				iter, err := r.NewIterWithBlockPropertyFilters(
+					nil, nil, nil, true /* useFilterBlock */, IterOptions{DisableCacheFill: !fillCache})
+				require.NoError(t, err)
+				var n int
+				for k, _ := iter.First(); k != nil; k, _ = iter.Next() {
+					n++
+				}
+				require.Equal(t, numKeys, n)
+				k, _ := iter.SeekPrefixGE([]byte("0042"), []byte("0042"), base.SeekGEFlagsNone)
+				require.NotNil(t, k)
+				require.NoError(t, iter.Close())
+			}
+
+			before := c.Metrics()
+			scan(false /* fillCache */)
+			after := c.Metrics()
+			require.Equal(t, before.Count, after.Count)
+			require.Equal(t, before.Size, after.Size)
+
+			scan(true /* fillCache */)
+			filled := c.Metrics()
+			require.Greater(t, filled.Count, after.Count)
+
+			// Blocks already in the cache are still read from it.
+			scan(false /* fillCache */)
+			m := c.Metrics()
+			require.Equal(t, filled.Count, m.Count)
+			require.Equal(t, filled.Misses, m.Misses)
+			require.Greater(t, m.Hits, filled.Hits)
+		})
+	}
+}
+
+func TestReaderPrefetchBlocks(t *testing.T) {
+	for _, twoLevelIndex := range []bool{false, true} {
+		t.Run(fmt.Sprintf("two-level-index=%t", twoLevelIndex), func(t *testing.T) {
+			indexBlockSize := 4096
+			if twoLevelIndex {
+				indexBlockSize = 128
+			}
+			f := &memFile{}
+			w := NewWriter(f, WriterOptions{
+				BlockSize:      32,
+				IndexBlockSize: indexBlockSize,
+			})
+			const numKeys = 100
+			for i := 0; i < numKeys; i++ {
+				require.NoError(t, w.Set([]byte(fmt.Sprintf("%04d", i)), []byte("value")))
+			}
+			require.NoError(t, w.Close())
+
+			// scan scans the table up to the upper bound with a new cache, and
+			// returns the iterator's stats and the number of cached blocks.
+			scan := func(upper []byte, prefetchBlocks int) (base.InternalIteratorStats, int64) {
+				c := cache.New(1 << 20)
+				defer c.Unref()
+				r, err := NewMemReader(f.Data(), ReaderOptions{Cache: c})
+				require.NoError(t, err)
+				defer func() { require.NoError(t, r.Close()) }()
+				iter, err := r.NewIterWithBlockPropertyFilters(
+					nil, upper, nil, true /* useFilterBlock */, IterOptions{PrefetchBlocks: prefetchBlocks})
+				require.NoError(t, err)
+				var n int
+				for k, _ := iter.First(); k != nil; k, _ = iter.Next() {
+					n++
+				}
+				if upper == nil {
+					require.Equal(t, numKeys, n)
+				}
+				stats := iter.(base.InternalIteratorWithStats).Stats()
+				// Close waits for the prefetches to complete.
+				require.NoError(t, iter.Close())
+				return stats, c.Metrics().Count
+			}
+
+			for _, upper := range [][]byte{nil, []byte("0042")} {
+				stats, count := scan(upper, 0 /* prefetchBlocks */)
+				require.Zero(t, stats.PrefetchHits+stats.PrefetchMisses)
+				prefetchStats, prefetchCount := scan(upper, 4 /* prefetchBlocks */)
+				// Data blocks are prefetched before being loaded, and no block
+				// beyond the upper bound is read.
+				require.Greater(t, prefetchStats.PrefetchHits+prefetchStats.PrefetchMisses, uint64(0))
+				require.Equal(t, stats.BlockReads, prefetchStats.BlockReads)
+				require.Equal(t, count, prefetchCount)
+			}
+		})
+	}
+}
+
+func TestReaderLoadMetadataBlocks(t *testing.T) {
+	for _, twoLevelIndex := range []bool{false, true} {
+		t.Run(fmt.Sprintf("two-level-index=%t", twoLevelIndex), func(t *testing.T) {
+			indexBlockSize := 4096
+			if twoLevelIndex {
+				indexBlockSize = 128
+			}
+			f := &memFile{}
+			w := NewWriter(f, WriterOptions{
+				BlockSize:      32,
+				IndexBlockSize: indexBlockSize,
+				FilterPolicy:   bloom.FilterPolicy(10),
+			})
+			for i := 0; i < 100; i++ {
+				require.NoError(t, w.Set([]byte(fmt.Sprintf("%04d", i)), []byte("value")))
+			}
+			require.NoError(t, w.DeleteRange([]byte("0010"), []byte("0020")))
+			require.NoError(t, w.Close())
+
+			c := cache.New(1 << 20)
+			defer c.Unref()
+			r, err := NewMemReader(f.Data(), ReaderOptions{Cache: c})
+			require.NoError(t, err)
+			defer func() { require.NoError(t, r.Close()) }()
+			if twoLevelIndex {
+				require.Greater(t, r.Properties.IndexPartitions, uint64(1))
+			}
+
+			size, err := r.LoadMetadataBlocks()
+			require.NoError(t, err)
+			require.Greater(t, size, uint64(0))
+			m := c.Metrics()
+
+			// Loading the blocks again finds them in the cache.
+			size2, err := r.LoadMetadataBlocks()
+			require.NoError(t, err)
+			require.Equal(t, size, size2)
+			require.Equal(t, m.Misses, c.Metrics().Misses)
+
+			// Reads only miss the cache for data blocks.
+			iter, err := r.NewIter(nil /* lower */, nil /* upper */)
+			require.NoError(t, err)
+			var n int
+			for k, _ := iter.First(); k != nil; k, _ = iter.Next() {
+				n++
+			}
+			require.Equal(t, 100, n)
+			require.NoError(t, iter.Close())
+			rangeDelIter, err := r.NewRawRangeDelIter()
+			require.NoError(t, err)
+			require.NoError(t, rangeDelIter.Close())
+			filterH, err := r.readFilter(true /* fillCache */)
+			require.NoError(t, err)
+			filterH.Release()
+			require.EqualValues(t, r.Properties.NumDataBlocks, c.Metrics().Misses-m.Misses)
+		})
+	}
+}
+
 func TestValidateBlockChecksums(t *testing.T) {
 	seed := uint64(time.Now().UnixNano())
 	rng := rand.New(rand.NewSource(seed))
@@ -1128,6 +1702,52 @@ func BenchmarkTableIterPrev(b *testing.B) {
 	}
 }
 
+// BenchmarkReaderBlockAlignment measures the latency of reading uncompressed
+// blocks from a file, with and without block alignment. The file is created in
+// the default temporary directory, which may be pointed at the storage of
+// interest with TMPDIR. Reads are served by the OS page cache unless it is
+// dropped or bypassed.
+func BenchmarkReaderBlockAlignment(b *testing.B) {
+	for _, alignment := range []int{0, 4096} {
+		b.Run(fmt.Sprintf("alignment=%d", alignment), func(b *testing.B) {
+			path := filepath.Join(b.TempDir(), "bench")
+			f, err := vfs.Default.Create(path)
+			require.NoError(b, err)
+			w := NewWriter(f, WriterOptions{
+				BlockAlignment: alignment,
+				BlockSize:      4000,
+				Compression:    NoCompression,
+				TableFormat:    TableFormatPebblev2,
+			})
+			var keys [][]byte
+			value := make([]byte, 100)
+			for i := uint64(0); i < 1e5; i++ {
+				key := make([]byte, 8)
+				binary.BigEndian.PutUint64(key, i)
+				keys = append(keys, key)
+				require.NoError(b, w.Set(key, value))
+			}
+			require.NoError(b, w.Close())
+
+			f, err = vfs.Default.Open(path)
+			require.NoError(b, err)
+			// Read every block from the file, without a block cache.
+			r, err := NewReader(f, ReaderOptions{})
+			require.NoError(b, err)
+			defer r.Close()
+			it, err := r.NewIter(nil /* lower */, nil /* upper */)
+			require.NoError(b, err)
+			defer it.Close()
+			rng := rand.New(rand.NewSource(uint64(time.Now().UnixNano())))
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				it.SeekGE(keys[rng.Intn(len(keys))], base.SeekGEFlagsNone)
+			}
+		})
+	}
+}
+
 func BenchmarkLayout(b *testing.B) {
 	r, _ := buildBenchmarkTable(b, WriterOptions{})
 	b.ResetTimer()
@@ -1137,3 +1757,18 @@ func BenchmarkLayout(b *testing.B) {
 	b.StopTimer()
 	r.Close()
 }
+
+func TestDecompressBlockError(t *testing.T) {
+	c := cache.New(1 << 20)
+	defer c.Unref()
+	// A block claiming to decompress to 100 bytes, followed by data that isn't
+	// valid in either compression format.
+	b := binary.AppendUvarint(nil, 100)
+	b = append(b, bytes.Repeat([]byte{0xff}, 16)...)
+	for _, typ := range []blockType{snappyCompressionBlockType, zstdCompressionBlockType} {
+		v, err := decompressBlock(c, typ, b, nil /* dict */)
+		require.Error(t, err)
+		require.True(t, errors.Is(err, base.ErrCorruption))
+		require.Nil(t, v)
+	}
+}
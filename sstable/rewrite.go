@@ -0,0 +1,112 @@
+// Copyright 2026 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package sstable
+
+import (
+	"github.com/cockroachdb/pebble/internal/base"
+	"github.com/cockroachdb/pebble/internal/rangekey"
+)
+
+// Rewrite copies the content of the sstable read by r to a new sstable,
+// written to out using the WriterOptions o. It may be used to change the
+// compression, block sizes, table format, filter policy or property collectors
+// of an existing sstable.
+//
+// Every point key, range deletion and range key (RANGEKEYSET, RANGEKEYUNSET
+// and RANGEKEYDEL) is copied with its original sequence number and kind. If r
+// has a global sequence number, the global sequence number is written
+// explicitly as the sequence number of every key in the rewritten sstable.
+//
+// The Comparer in o must order keys identically to the one used to write the
+// input sstable. Table and block properties are recomputed by the collectors
+// configured in o; user properties of the input sstable are not copied.
+//
+// If an error occurs, out is closed without finishing the sstable.
+func Rewrite(r *Reader, out writeCloseSyncer, o WriterOptions) (_ *WriterMetadata, err error) {
+	w := NewWriter(out, o)
+	defer func() {
+		// The Writer releases out when closed, even if closing fails. If it
+		// hasn't been closed, abandon the partially written sstable: with
+		// w.err set, Close closes out without writing the remaining blocks.
+		if w.syncer != nil {
+			if w.err == nil {
+				w.err = err
+			}
+			_ = w.Close()
+		}
+	}()
+	if err := rewritePointKeys(r, w); err != nil {
+		return nil, err
+	}
+	if err := rewriteRangeDels(r, w); err != nil {
+		return nil, err
+	}
+	if err := rewriteRangeKeys(r, w); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return &w.meta, nil
+}
+
+func rewritePointKeys(r *Reader, w *Writer) error {
+	iter, err := r.NewIter(nil /* lower */, nil /* upper */)
+	if err != nil {
+		return err
+	}
+	for k, v := iter.First(); k != nil; k, v = iter.Next() {
+		if err := w.Add(*k, v); err != nil {
+			_ = iter.Close()
+			return err
+		}
+	}
+	return iter.Close()
+}
+
+func rewriteRangeDels(r *Reader, w *Writer) error {
+	iter, err := r.NewRawRangeDelIter()
+	if err != nil {
+		return err
+	}
+	if iter == nil {
+		// No range deletions.
+		return nil
+	}
+	defer iter.Close()
+
+	for s := iter.First(); s != nil; s = iter.Next() {
+		for _, k := range s.Keys {
+			key := base.InternalKey{UserKey: s.Start, Trailer: k.Trailer}
+			if err := w.Add(key, s.End); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func rewriteRangeKeys(r *Reader, w *Writer) error {
+	iter, err := r.NewRawRangeKeyIter()
+	if err != nil {
+		return err
+	}
+	if iter == nil {
+		// No range keys.
+		return nil
+	}
+	defer iter.Close()
+
+	for s := iter.First(); s != nil; s = iter.Next() {
+		// Calling AddRangeKey instead of addRangeKeySpan bypasses the
+		// fragmenter and coalescing. The raw spans are already fragmented and
+		// coalesced, and must be copied as-is to preserve their sequence
+		// numbers.
+		if err := rangekey.Encode(s, w.AddRangeKey); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+// Copyright 2026 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package sstable
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/cockroachdb/pebble/internal/base"
+	"github.com/cockroachdb/pebble/internal/keyspan"
+	"github.com/cockroachdb/pebble/internal/rangekey"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRewrite(t *testing.T) {
+	ikey := func(s string, seqNum uint64, kind base.InternalKeyKind) InternalKey {
+		return base.MakeInternalKey([]byte(s), seqNum, kind)
+	}
+	encodeRangeKeySet := func(end, suffix, value string) []byte {
+		svs := []rangekey.SuffixValue{{Suffix: []byte(suffix), Value: []byte(value)}}
+		b := make([]byte, rangekey.EncodedSetValueLen([]byte(end), svs))
+		rangekey.EncodeSetValue(b, []byte(end), svs)
+		return b
+	}
+	encodeRangeKeyUnset := func(end, suffix string) []byte {
+		suffixes := [][]byte{[]byte(suffix)}
+		b := make([]byte, rangekey.EncodedUnsetValueLen([]byte(end), suffixes))
+		rangekey.EncodeUnsetValue(b, []byte(end), suffixes)
+		return b
+	}
+	points := []struct {
+		key   InternalKey
+		value string
+	}{
+		{ikey("a", 9, base.InternalKeyKindSet), "a9"},
+		{ikey("a", 3, base.InternalKeyKindDelete), ""},
+		{ikey("b", 8, base.InternalKeyKindMerge), "b8"},
+		{ikey("c", 7, base.InternalKeyKindSingleDelete), ""},
+		{ikey("c", 2, base.InternalKeyKindSet), "c2"},
+		{ikey("d", 6, base.InternalKeyKindSetWithDelete), "d6"},
+		{ikey("e", 5, base.InternalKeyKindSet), "e5"},
+	}
+
+	writeInput := func(t *testing.T) []byte {
+		f := &memFile{}
+		w := NewWriter(f, WriterOptions{TableFormat: TableFormatPebblev2})
+		for _, p := range points {
+			require.NoError(t, w.Add(p.key, []byte(p.value)))
+		}
+		require.NoError(t, w.Add(ikey("b", 10, base.InternalKeyKindRangeDelete), []byte("d")))
+		require.NoError(t, w.Add(ikey("b", 4, base.InternalKeyKindRangeDelete), []byte("d")))
+		require.NoError(t, w.Add(ikey("d", 4, base.InternalKeyKindRangeDelete), []byte("e")))
+		require.NoError(t, w.AddRangeKey(
+			ikey("a", 12, base.InternalKeyKindRangeKeySet), encodeRangeKeySet("c", "@5", "v5")))
+		require.NoError(t, w.AddRangeKey(
+			ikey("a", 11, base.InternalKeyKindRangeKeyUnset), encodeRangeKeyUnset("c", "@3")))
+		require.NoError(t, w.AddRangeKey(
+			ikey("c", 11, base.InternalKeyKindRangeKeyDelete), []byte("f")))
+		require.NoError(t, w.Close())
+		return f.Data()
+	}
+
+	describe := func(t *testing.T, r *Reader) string {
+		var buf bytes.Buffer
+		iter, err := r.NewIter(nil, nil)
+		require.NoError(t, err)
+		for k, v := iter.First(); k != nil; k, v = iter.Next() {
+			fmt.Fprintf(&buf, "%s:%s\n", k, v)
+		}
+		require.NoError(t, iter.Close())
+		for _, newIter := range []func() (keyspan.FragmentIterator, error){
+			r.NewRawRangeDelIter, r.NewRawRangeKeyIter,
+		} {
+			iter, err := newIter()
+			require.NoError(t, err)
+			require.NotNil(t, iter)
+			for s := iter.First(); s != nil; s = iter.Next() {
+				fmt.Fprintf(&buf, "%s\n", s)
+			}
+			require.NoError(t, iter.Close())
+		}
+		return buf.String()
+	}
+
+	in, err := NewMemReader(writeInput(t), ReaderOptions{})
+	require.NoError(t, err)
+	defer in.Close()
+	expected := describe(t, in)
+
+	for _, o := range []WriterOptions{
+		{TableFormat: TableFormatPebblev2, Compression: NoCompression},
+		{TableFormat: TableFormatPebblev2, Compression: ZstdCompression, BlockSize: 1, IndexBlockSize: 1},
+		{TableFormat: TableFormatPebblev2, Checksum: ChecksumTypeXXHash64},
+	} {
+		t.Run(fmt.Sprintf("compression=%s,block-size=%d,checksum=%d", o.Compression, o.BlockSize, o.Checksum), func(t *testing.T) {
+			f := &memFile{}
+			meta, err := Rewrite(in, f, o)
+			require.NoError(t, err)
+			require.Equal(t, uint64(2), meta.SmallestSeqNum)
+			require.Equal(t, uint64(12), meta.LargestSeqNum)
+
+			out, err := NewMemReader(f.Data(), ReaderOptions{})
+			require.NoError(t, err)
+			defer out.Close()
+			require.Equal(t, expected, describe(t, out))
+			require.Equal(t, in.Properties.NumEntries, out.Properties.NumEntries)
+			require.Equal(t, in.Properties.NumRangeDeletions, out.Properties.NumRangeDeletions)
+			require.Equal(t, in.Properties.NumRangeKeySets, out.Properties.NumRangeKeySets)
+			require.Equal(t, in.Properties.NumRangeKeyUnsets, out.Properties.NumRangeKeyUnsets)
+			require.Equal(t, in.Properties.NumRangeKeyDels, out.Properties.NumRangeKeyDels)
+			if o.BlockSize == 1 {
+				require.Equal(t, uint64(len(points)), out.Properties.NumDataBlocks)
+			}
+		})
+	}
+
+	// If the input cannot be read, Rewrite closes the output without writing
+	// a complete sstable.
+	t.Run("error", func(t *testing.T) {
+		data := writeInput(t)
+		data[0] ^= 0xff // Corrupt the first data block.
+		in, err := NewMemReader(data, ReaderOptions{})
+		require.NoError(t, err)
+		defer in.Close()
+
+		f := &closeTrackingFile{}
+		_, err = Rewrite(in, f, WriterOptions{TableFormat: TableFormatPebblev2})
+		require.Regexp(t, `checksum mismatch`, err)
+		require.Equal(t, 1, f.closed)
+		_, err = NewMemReader(f.Data(), ReaderOptions{})
+		require.Error(t, err)
+	})
+}
+
+// closeTrackingFile is a memFile that counts the calls to Close.
+type closeTrackingFile struct {
+	memFile
+	closed int
+}
+
+// Close implements the writeCloseSyncer interface.
+func (f *closeTrackingFile) Close() error {
+	f.closed++
+	return nil
+}
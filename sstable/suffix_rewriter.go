@@ -27,6 +27,11 @@ import (
 //
 // Any block and table property collectors configured in the WriterOptions must
 // implement SuffixReplaceableTableCollector/SuffixReplaceableBlockCollector.
+//
+// Since the values are copied as-is, sstables with values chunked into value
+// blocks can't be rewritten, nor can sstables be rewritten between a table
+// format before TableFormatPebblev4 and one from it onwards. Such sstables
+// may be rewritten with RewriteKeySuffixesViaWriter.
 func RewriteKeySuffixes(
 	sst []byte,
 	rOpts ReaderOptions,
@@ -56,6 +61,16 @@ func rewriteKeySuffixesInBlocks(
 	w := NewWriter(out, o)
 	defer w.Close()
 
+	// The data blocks are copied with their values as-is, so the values must
+	// be stored the same way in both tables.
+	if r.valueIndexBH.Length > 0 {
+		return nil, errors.New("sstables with chunked values must be rewritten with RewriteKeySuffixesViaWriter")
+	}
+	if (r.tableFormat >= TableFormatPebblev4) != (w.tableFormat >= TableFormatPebblev4) {
+		return nil, errors.Errorf("cannot rewrite the data blocks of a table of format %s as format %s",
+			r.tableFormat, w.tableFormat)
+	}
+
 	for _, c := range w.propCollectors {
 		if _, ok := c.(SuffixReplaceableTableCollector); !ok {
 			return nil, errors.Errorf("property collector %s does not support suffix replacement", c.Name())
@@ -112,6 +127,7 @@ func rewriteBlocks(
 	restartInterval int,
 	checksumType ChecksumType,
 	compression Compression,
+	compressionDict []byte,
 	input []BlockHandleWithProperties,
 	output []blockWithSpan,
 	totalWorkers, worker int,
@@ -187,7 +203,7 @@ func rewriteBlocks(
 
 		keyAlloc, output[i].end = cloneKeyWithBuf(scratch, keyAlloc)
 
-		finished := compressAndChecksum(bw.finish(), compression, &buf)
+		finished := compressAndChecksum(bw.finish(), compression, compressionDict, &buf)
 
 		// copy our finished block into the output buffer.
 		sz := len(finished) + blockTrailerLen
@@ -237,6 +253,7 @@ func rewriteDataBlocksToWriter(
 				w.dataBlockBuf.dataBlock.restartInterval,
 				w.blockBuf.checksummer.checksumType,
 				w.compression,
+				w.compressionDict,
 				data,
 				blocks,
 				concurrency,
@@ -446,7 +463,7 @@ func readBlockBuf(r *Reader, bh BlockHandle, buf []byte) ([]byte, []byte, error)
 	if cap(buf) < decompressedLen {
 		buf = make([]byte, decompressedLen)
 	}
-	res, err := decompressInto(typ, raw[prefix:], buf[:decompressedLen])
+	res, err := decompressInto(typ, raw[prefix:], buf[:decompressedLen], r.dictDecoder)
 	return res, buf, err
 }
 
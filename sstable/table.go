@@ -83,6 +83,7 @@ The table file format looks like:
 [data block 1]
 ...
 [data block N-1]
+[meta value index block] (optional)
 [meta filter block] (optional)
 [index block] (for single level index)
 [meta rangedel block] (optional)
@@ -154,6 +155,19 @@ lower-level index blocks.
 The metaindex block also contains block handles as values, with keys being
 the names of the meta blocks.
 
+In tables of TableFormatPebblev4 and later, the value of every SET, SETWITHDEL
+and MERGE entry in a data block is preceded by a 1 byte value prefix. A value
+prefix of 0 is followed by the value itself. A value prefix of 1 is followed by
+a value handle: the varint-encoded length of the value, the number of its first
+value block and the number of value blocks holding it. The value is the
+concatenation of the contents of those value blocks, each of which is a block
+with the usual trailer, holding a chunk of the value without any block suffix.
+Value blocks are interleaved with the data blocks: the value blocks of a value
+are written when the value is added, so they precede the data block holding
+its handle. The meta value index block is an uncompressed array of 16 byte entries, one per
+value block, each holding the little-endian uint64 offset and length of the
+value block.
+
 */
 
 const (
@@ -182,10 +196,12 @@ const (
 	levelDBFormatVersion  = 0
 	rocksDBFormatVersion2 = 2
 
-	metaRangeKeyName   = "pebble.range_key"
-	metaPropertiesName = "rocksdb.properties"
-	metaRangeDelName   = "rocksdb.range_del"
-	metaRangeDelV2Name = "rocksdb.range_del2"
+	metaCompressionDictName = "pebble.compression_dict"
+	metaRangeKeyName        = "pebble.range_key"
+	metaValueIndexName      = "pebble.value_index"
+	metaPropertiesName      = "rocksdb.properties"
+	metaRangeDelName        = "rocksdb.range_del"
+	metaRangeDelV2Name      = "rocksdb.range_del2"
 
 	// Index Types.
 	// A space efficient index block that is optimized for binary-search-based
@@ -420,7 +436,8 @@ func supportsTwoLevelIndex(format TableFormat) bool {
 	switch format {
 	case TableFormatLevelDB:
 		return false
-	case TableFormatRocksDBv2, TableFormatPebblev1, TableFormatPebblev2:
+	case TableFormatRocksDBv2, TableFormatPebblev1, TableFormatPebblev2, TableFormatPebblev3,
+		TableFormatPebblev4:
 		return true
 	default:
 		panic("sstable: unspecified table format version")
@@ -0,0 +1,108 @@
+// Copyright 2026 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package sstable
+
+import (
+	"math"
+
+	"github.com/cockroachdb/pebble/internal/base"
+)
+
+// TimeRangePropertyCollectorName is the name of the block property collector
+// constructed by NewTimeRangePropertyCollector.
+const TimeRangePropertyCollectorName = "pebble.time-range"
+
+// SuffixToTime maps a key suffix, as determined by Comparer.Split, to a
+// timestamp. It is only called for non-empty suffixes.
+type SuffixToTime func(suffix []byte) (uint64, error)
+
+// TimeRange is the inclusive range of timestamps recorded for an sstable by a
+// time-range property collector.
+type TimeRange struct {
+	Min uint64
+	Max uint64
+}
+
+// NewTimeRangePropertyCollector returns a block property collector that
+// records, for each data block, index block and the table, the range of
+// timestamps encoded in the suffixes of the point keys it contains. Keys
+// without a suffix are ignored. Range keys are not tracked.
+//
+// The recorded ranges may be used to skip tables and blocks during
+// point-in-time reads through a filter constructed by NewTimeRangeFilter, and
+// the table-level range may be read through ReadTimeRange.
+//
+// Timestamps are recorded as the half-open interval [Min, Max+1). A Max of
+// math.MaxUint64 cannot be represented and is recorded as math.MaxUint64-1.
+func NewTimeRangePropertyCollector(split Split, suffixToTime SuffixToTime) BlockPropertyCollector {
+	return NewBlockIntervalCollector(TimeRangePropertyCollectorName,
+		&timeRangeDataBlockCollector{split: split, suffixToTime: suffixToTime}, nil)
+}
+
+// NewTimeRangeFilter returns a block property filter that excludes tables and
+// blocks that contain no timestamps within the inclusive range [min, max],
+// according to the properties written by NewTimeRangePropertyCollector.
+// Tables and blocks written without the collector are never excluded.
+func NewTimeRangeFilter(min, max uint64) *BlockIntervalFilter {
+	if max == math.MaxUint64 {
+		max--
+	}
+	return NewBlockIntervalFilter(TimeRangePropertyCollectorName, min, max+1)
+}
+
+// ReadTimeRange returns the table-level time range recorded by the collector
+// constructed by NewTimeRangePropertyCollector. It returns ok=false if the
+// table was not written with the collector or contains no keys with suffixes.
+func ReadTimeRange(props *Properties) (r TimeRange, ok bool, err error) {
+	prop, ok := props.UserProperties[TimeRangePropertyCollectorName]
+	if !ok {
+		return TimeRange{}, false, nil
+	}
+	if len(prop) < 1 {
+		return TimeRange{}, false, base.CorruptionErrorf(
+			"block properties for %s is corrupted", TimeRangePropertyCollectorName)
+	}
+	// The first byte holds the collector's shortID.
+	var i interval
+	if err := i.decode([]byte(prop[1:])); err != nil {
+		return TimeRange{}, false, err
+	}
+	if i.lower >= i.upper {
+		return TimeRange{}, false, nil
+	}
+	return TimeRange{Min: i.lower, Max: i.upper - 1}, true, nil
+}
+
+type timeRangeDataBlockCollector struct {
+	split        Split
+	suffixToTime SuffixToTime
+	block        interval
+}
+
+var _ DataBlockIntervalCollector = (*timeRangeDataBlockCollector)(nil)
+
+// Add implements the DataBlockIntervalCollector interface.
+func (c *timeRangeDataBlockCollector) Add(key InternalKey, value []byte) error {
+	suffix := key.UserKey[c.split(key.UserKey):]
+	if len(suffix) == 0 {
+		return nil
+	}
+	t, err := c.suffixToTime(suffix)
+	if err != nil {
+		return err
+	}
+	if t == math.MaxUint64 {
+		t--
+	}
+	c.block.union(interval{lower: t, upper: t + 1})
+	return nil
+}
+
+// FinishDataBlock implements the DataBlockIntervalCollector interface.
+func (c *timeRangeDataBlockCollector) FinishDataBlock() (lower uint64, upper uint64, err error) {
+	lower, upper = c.block.lower, c.block.upper
+	c.block = interval{}
+	return lower, upper, nil
+}
@@ -0,0 +1,86 @@
+// Copyright 2026 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package sstable
+
+import (
+	"testing"
+
+	"github.com/cockroachdb/pebble/internal/testkeys"
+	"github.com/cockroachdb/pebble/vfs"
+	"github.com/stretchr/testify/require"
+)
+
+func testkeysSuffixToTime(suffix []byte) (uint64, error) {
+	t, err := testkeys.ParseSuffix(suffix)
+	return uint64(t), err
+}
+
+func TestTimeRangePropertyCollector(t *testing.T) {
+	writeTable := func(t *testing.T, keys ...string) *Reader {
+		mem := vfs.NewMem()
+		f, err := mem.Create("test")
+		require.NoError(t, err)
+		w := NewWriter(f, WriterOptions{
+			Comparer:    testkeys.Comparer,
+			BlockSize:   1,
+			TableFormat: TableFormatPebblev1,
+			BlockPropertyCollectors: []func() BlockPropertyCollector{
+				func() BlockPropertyCollector {
+					return NewTimeRangePropertyCollector(testkeys.Comparer.Split, testkeysSuffixToTime)
+				},
+			},
+		})
+		for _, k := range keys {
+			require.NoError(t, w.Set([]byte(k), nil))
+		}
+		require.NoError(t, w.Close())
+
+		f, err = mem.Open("test")
+		require.NoError(t, err)
+		r, err := NewReader(f, ReaderOptions{Comparer: testkeys.Comparer})
+		require.NoError(t, err)
+		return r
+	}
+
+	t.Run("timestamped", func(t *testing.T) {
+		r := writeTable(t, "a@9", "a@3", "b", "c@12", "d@7")
+		defer r.Close()
+		tr, ok, err := ReadTimeRange(&r.Properties)
+		require.NoError(t, err)
+		require.True(t, ok)
+		require.Equal(t, TimeRange{Min: 3, Max: 12}, tr)
+
+		// The table-level property is consulted by the time-range filter.
+		prop := r.Properties.UserProperties[TimeRangePropertyCollectorName]
+		for _, tc := range []struct {
+			min, max   uint64
+			intersects bool
+		}{
+			{0, 2, false},
+			{0, 3, true},
+			{12, 20, true},
+			{13, 20, false},
+			{5, 5, true},
+		} {
+			intersects, err := NewTimeRangeFilter(tc.min, tc.max).Intersects([]byte(prop[1:]))
+			require.NoError(t, err)
+			require.Equal(t, tc.intersects, intersects, "[%d, %d]", tc.min, tc.max)
+		}
+	})
+
+	t.Run("untimestamped", func(t *testing.T) {
+		r := writeTable(t, "a", "b")
+		defer r.Close()
+		_, ok, err := ReadTimeRange(&r.Properties)
+		require.NoError(t, err)
+		require.False(t, ok)
+	})
+
+	t.Run("no-collector", func(t *testing.T) {
+		_, ok, err := ReadTimeRange(&Properties{})
+		require.NoError(t, err)
+		require.False(t, ok)
+	})
+}
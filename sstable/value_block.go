@@ -0,0 +1,425 @@
+// Copyright 2026 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package sstable
+
+import (
+	"encoding/binary"
+	"sync"
+
+	"github.com/cockroachdb/errors"
+	"github.com/cockroachdb/pebble/internal/base"
+)
+
+// The value prefixes of the values of SET, SETWITHDEL and MERGE entries in
+// the data blocks of tables of TableFormatPebblev4 and later.
+const (
+	// valuePrefixInline precedes a value stored in the data block.
+	valuePrefixInline byte = 0
+	// valuePrefixChunked precedes the encoded valueHandle of a value chunked
+	// into value blocks.
+	valuePrefixChunked byte = 1
+)
+
+// valueIndexEntryLen is the length of an entry of the value index block: the
+// uint64 offset and length of a value block.
+const valueIndexEntryLen = 16
+
+// hasValuePrefix returns true if the values of entries of the given kind are
+// preceded by a value prefix in the data blocks of a table of the given
+// format.
+func hasValuePrefix(format TableFormat, kind InternalKeyKind) bool {
+	if format < TableFormatPebblev4 {
+		return false
+	}
+	switch kind {
+	case InternalKeyKindSet, base.InternalKeyKindSetWithDelete, InternalKeyKindMerge:
+		return true
+	default:
+		return false
+	}
+}
+
+// valueHandle locates a value chunked into value blocks. The value is the
+// concatenation of the contents of numBlocks value blocks, starting with the
+// value block numbered firstBlock.
+type valueHandle struct {
+	valueLen   uint64
+	firstBlock uint64
+	numBlocks  uint64
+}
+
+// encode appends the value prefix and encoding of h to dst.
+func (h valueHandle) encode(dst []byte) []byte {
+	var buf [1 + 3*binary.MaxVarintLen64]byte
+	buf[0] = valuePrefixChunked
+	n := 1
+	n += binary.PutUvarint(buf[n:], h.valueLen)
+	n += binary.PutUvarint(buf[n:], h.firstBlock)
+	n += binary.PutUvarint(buf[n:], h.numBlocks)
+	return append(dst, buf[:n]...)
+}
+
+// decodeValueHandle decodes the valueHandle encoded in src, which follows the
+// value prefix.
+func decodeValueHandle(src []byte) (valueHandle, error) {
+	var h valueHandle
+	for _, v := range []*uint64{&h.valueLen, &h.firstBlock, &h.numBlocks} {
+		var n int
+		if *v, n = binary.Uvarint(src); n <= 0 {
+			return valueHandle{}, base.CorruptionErrorf("pebble/table: invalid value handle")
+		}
+		src = src[n:]
+	}
+	return h, nil
+}
+
+// valueBlockBuf holds a value block, compressed and checksummed by the Writer
+// client goroutine, until it's written by the writeQueue.
+type valueBlockBuf struct {
+	// chunk holds a copy of the chunk of the value stored in the block, since
+	// the value passed to the Writer is only valid until the Writer returns.
+	chunk []byte
+	// compressed is the contents of the block, either chunk or its compressed
+	// form in blockBuf.compressedBuf.
+	compressed []byte
+	blockBuf   blockBuf
+}
+
+var valueBlockBufPool = sync.Pool{
+	New: func() interface{} {
+		return &valueBlockBuf{}
+	},
+}
+
+// valueBlockWriter chunks the values that are too large to be stored inline
+// into value blocks. Each value block is added to the Writer's writeQueue as
+// soon as it's filled, so the value blocks are interleaved with the data
+// blocks, immediately preceding the data block holding the handles of their
+// values, and the Writer only holds the value blocks that are waiting to be
+// written.
+type valueBlockWriter struct {
+	blockSize    int
+	compression  Compression
+	checksumType ChecksumType
+	dict         []byte
+	// numBlocks is the number of value blocks for which handles have been
+	// returned by nextHandle.
+	numBlocks uint64
+}
+
+// nextHandle returns the handle of a value of the given length, to be
+// chunked into the value blocks following those of the previous value.
+func (w *valueBlockWriter) nextHandle(valueLen int) valueHandle {
+	h := valueHandle{
+		valueLen:   uint64(valueLen),
+		firstBlock: w.numBlocks,
+		numBlocks:  uint64((valueLen + w.blockSize - 1) / w.blockSize),
+	}
+	w.numBlocks += h.numBlocks
+	return h
+}
+
+// addValueBlocks chunks value, whose handle was returned by the last call to
+// valueBlockWriter.nextHandle, into value blocks, which are added to the
+// writeQueue.
+func (w *Writer) addValueBlocks(value []byte) error {
+	vw := w.valueBlocks
+	for len(value) > 0 {
+		n := len(value)
+		if n > vw.blockSize {
+			n = vw.blockSize
+		}
+		vb := valueBlockBufPool.Get().(*valueBlockBuf)
+		vb.chunk = append(vb.chunk[:0], value[:n]...)
+		vb.blockBuf.checksummer.checksumType = vw.checksumType
+		vb.compressed = compressAndChecksum(vb.chunk, vw.compression, vw.dict, &vb.blockBuf)
+
+		// Schedule a write. Like data blocks, the value block is compressed
+		// already.
+		w.coordination.sizeEstimate.addInflightDataBlock(n)
+		writeTask := writeTaskPool.Get().(*writeTask)
+		writeTask.compressionDone <- true
+		writeTask.valueBlock = vb
+		writeTask.inflightSize = n
+		if w.coordination.parallelismEnabled {
+			w.coordination.writeQueue.add(writeTask)
+		} else if err := w.coordination.writeQueue.addSync(writeTask); err != nil {
+			return err
+		}
+		value = value[n:]
+	}
+	return nil
+}
+
+// writeValueBlock writes a value block, and adds its handle to the value
+// index. It's called from the writeQueue, which writes the value blocks in the
+// order in which they were numbered by valueBlockWriter.nextHandle. inflightSize is the
+// size of the uncompressed block, which was added to the size estimate.
+func (w *Writer) writeValueBlock(vb *valueBlockBuf, inflightSize int) error {
+	start := w.meta.Size
+	bh, err := w.writeCompressedBlock(vb.compressed, vb.blockBuf.tmp[:])
+	if err != nil {
+		return err
+	}
+	w.coordination.sizeEstimate.dataBlockWritten(w.meta.Size, inflightSize, int(bh.Length))
+	w.valueBlocksSize += w.meta.Size - start
+	var e [valueIndexEntryLen]byte
+	binary.LittleEndian.PutUint64(e[:], bh.Offset)
+	binary.LittleEndian.PutUint64(e[8:], bh.Length)
+	w.valueIndex = append(w.valueIndex, e[:]...)
+	return nil
+}
+
+// readChunkedValue appends the value located by h to dst, reading its value
+// blocks through the block cache. The bytes of the value blocks read are added
+// to stats.
+func (r *Reader) readChunkedValue(
+	dst []byte, h valueHandle, fillCache bool, stats *base.InternalIteratorStats,
+) ([]byte, error) {
+	if r.valueIndexBH.Length == 0 {
+		return nil, base.CorruptionErrorf("pebble/table: value handle in table without value blocks")
+	}
+	indexH, _, err := r.readBlock(r.valueIndexBH, nil /* transform */, nil /* readaheadState */, fillCache)
+	if err != nil {
+		return nil, err
+	}
+	defer indexH.Release()
+	index := indexH.Get()
+	numBlocks := uint64(len(index) / valueIndexEntryLen)
+	if h.firstBlock > numBlocks || h.numBlocks > numBlocks-h.firstBlock {
+		return nil, base.CorruptionErrorf("pebble/table: value handle references value blocks [%d,%d) of %d",
+			errors.Safe(h.firstBlock), errors.Safe(h.firstBlock+h.numBlocks), errors.Safe(numBlocks))
+	}
+	start := len(dst)
+	for n := h.firstBlock; n < h.firstBlock+h.numBlocks; n++ {
+		e := index[n*valueIndexEntryLen:]
+		bh := BlockHandle{
+			Offset: binary.LittleEndian.Uint64(e),
+			Length: binary.LittleEndian.Uint64(e[8:]),
+		}
+		b, cacheHit, err := r.readBlock(bh, nil /* transform */, nil /* readaheadState */, fillCache)
+		if err != nil {
+			return nil, err
+		}
+		stats.BlockReads++
+		stats.BlockBytes += bh.Length
+		if cacheHit {
+			stats.BlockBytesInCache += bh.Length
+		}
+		dst = append(dst, b.Get()...)
+		b.Release()
+	}
+	if uint64(len(dst)-start) != h.valueLen {
+		return nil, base.CorruptionErrorf("pebble/table: chunked value has length %d, expected %d",
+			errors.Safe(len(dst)-start), errors.Safe(h.valueLen))
+	}
+	return dst, nil
+}
+
+var valueBlockIterPool = sync.Pool{
+	New: func() interface{} {
+		return &valueBlockIter{}
+	},
+}
+
+// valueBlockIter wraps an iterator over a table of TableFormatPebblev4 or
+// later, removing the value prefixes from the values it returns, and
+// reassembling the values chunked into value blocks. A reassembled value is
+// held in a buffer owned by the iterator, and like other values is only valid
+// until the next positioning call.
+//
+// If lazy is false, chunked values are reassembled when the iterator is
+// positioned at their key, even if the value is never used. If lazy is true,
+// the iterator instead returns the value's handle, prefixed by
+// valuePrefixChunked, as a placeholder, and the value is only reassembled by
+// FetchValue.
+type valueBlockIter struct {
+	Iterator
+	reader    *Reader
+	fillCache bool
+	lazy      bool
+	// handle locates the chunked value at the iterator's position, if
+	// hasHandle is true. fetched is true once the value has been reassembled
+	// into buf.
+	handle    valueHandle
+	hasHandle bool
+	fetched   bool
+	buf       []byte
+	err       error
+	closeHook func(i Iterator) error
+	// stats counts the value blocks read, which are added to the stats of the
+	// wrapped iterator.
+	stats base.InternalIteratorStats
+}
+
+var _ base.InternalIteratorWithStats = (*valueBlockIter)(nil)
+var _ base.LazyValueIterator = (*valueBlockIter)(nil)
+
+// wrapValues returns iter, an iterator over the table of r, wrapped in a
+// valueBlockIter if the values in the table have value prefixes.
+func (r *Reader) wrapValues(iter Iterator, fillCache, lazy bool) Iterator {
+	if r.tableFormat < TableFormatPebblev4 {
+		return iter
+	}
+	return newValueBlockIter(r, iter, fillCache, lazy)
+}
+
+// newValueBlockIter returns an iterator returning the values of iter, an
+// iterator over the table of r.
+func newValueBlockIter(r *Reader, iter Iterator, fillCache, lazy bool) *valueBlockIter {
+	i := valueBlockIterPool.Get().(*valueBlockIter)
+	*i = valueBlockIter{
+		Iterator:  iter,
+		reader:    r,
+		fillCache: fillCache,
+		lazy:      lazy,
+	}
+	return i
+}
+
+// value returns the key and value at the position of the wrapped iterator,
+// given its key k and the value v stored in the data block.
+func (i *valueBlockIter) value(k *InternalKey, v []byte) (*InternalKey, []byte) {
+	i.hasHandle = false
+	if k == nil || !hasValuePrefix(i.reader.tableFormat, k.Kind()) {
+		return k, v
+	}
+	if len(v) == 0 {
+		i.err = base.CorruptionErrorf("pebble/table: missing value prefix")
+		return nil, nil
+	}
+	switch v[0] {
+	case valuePrefixInline:
+		return k, v[1:]
+	case valuePrefixChunked:
+		h, err := decodeValueHandle(v[1:])
+		if err != nil {
+			i.err = err
+			return nil, nil
+		}
+		i.handle, i.hasHandle, i.fetched = h, true, false
+		if i.lazy {
+			return k, v
+		}
+		value, err := i.FetchValue(v)
+		if err != nil {
+			i.err = err
+			return nil, nil
+		}
+		return k, value
+	default:
+		i.err = base.CorruptionErrorf("pebble/table: unknown value prefix %d", errors.Safe(v[0]))
+		return nil, nil
+	}
+}
+
+// SeekGE implements internalIterator.SeekGE, as documented in the pebble
+// package.
+func (i *valueBlockIter) SeekGE(key []byte, flags base.SeekGEFlags) (*InternalKey, []byte) {
+	i.err = nil // clear cached iteration error
+	return i.value(i.Iterator.SeekGE(key, flags))
+}
+
+// SeekPrefixGE implements internalIterator.SeekPrefixGE, as documented in the
+// pebble package.
+func (i *valueBlockIter) SeekPrefixGE(
+	prefix, key []byte, flags base.SeekGEFlags,
+) (*InternalKey, []byte) {
+	i.err = nil // clear cached iteration error
+	return i.value(i.Iterator.SeekPrefixGE(prefix, key, flags))
+}
+
+// SeekLT implements internalIterator.SeekLT, as documented in the pebble
+// package.
+func (i *valueBlockIter) SeekLT(key []byte, flags base.SeekLTFlags) (*InternalKey, []byte) {
+	i.err = nil // clear cached iteration error
+	return i.value(i.Iterator.SeekLT(key, flags))
+}
+
+// First implements internalIterator.First, as documented in the pebble
+// package.
+func (i *valueBlockIter) First() (*InternalKey, []byte) {
+	i.err = nil // clear cached iteration error
+	return i.value(i.Iterator.First())
+}
+
+// Last implements internalIterator.Last, as documented in the pebble package.
+func (i *valueBlockIter) Last() (*InternalKey, []byte) {
+	i.err = nil // clear cached iteration error
+	return i.value(i.Iterator.Last())
+}
+
+// Next implements internalIterator.Next, as documented in the pebble package.
+func (i *valueBlockIter) Next() (*InternalKey, []byte) {
+	if i.err != nil {
+		return nil, nil
+	}
+	return i.value(i.Iterator.Next())
+}
+
+// Prev implements internalIterator.Prev, as documented in the pebble package.
+func (i *valueBlockIter) Prev() (*InternalKey, []byte) {
+	if i.err != nil {
+		return nil, nil
+	}
+	return i.value(i.Iterator.Prev())
+}
+
+// FetchValue implements base.LazyValueIterator. It reassembles the chunked
+// value at the iterator's position, if there is one, and otherwise returns
+// value.
+func (i *valueBlockIter) FetchValue(value []byte) ([]byte, error) {
+	if !i.hasHandle {
+		return value, nil
+	}
+	if !i.fetched {
+		var err error
+		if i.buf, err = i.reader.readChunkedValue(i.buf[:0], i.handle, i.fillCache, &i.stats); err != nil {
+			return nil, err
+		}
+		i.fetched = true
+	}
+	return i.buf, nil
+}
+
+// Error implements internalIterator.Error, as documented in the pebble
+// package.
+func (i *valueBlockIter) Error() error {
+	return firstError(i.err, i.Iterator.Error())
+}
+
+// SetCloseHook sets a function that will be called when the iterator is
+// closed.
+func (i *valueBlockIter) SetCloseHook(fn func(i Iterator) error) {
+	i.closeHook = fn
+}
+
+// Close implements internalIterator.Close, as documented in the pebble
+// package.
+func (i *valueBlockIter) Close() error {
+	var err error
+	if i.closeHook != nil {
+		err = firstError(err, i.closeHook(i))
+	}
+	err = firstError(err, i.Iterator.Close())
+	err = firstError(err, i.err)
+	// The buffer isn't retained, since it may hold a large value.
+	*i = valueBlockIter{}
+	valueBlockIterPool.Put(i)
+	return err
+}
+
+// Stats implements InternalIteratorWithStats.
+func (i *valueBlockIter) Stats() base.InternalIteratorStats {
+	stats := i.Iterator.(base.InternalIteratorWithStats).Stats()
+	stats.Merge(i.stats)
+	return stats
+}
+
+// ResetStats implements InternalIteratorWithStats.
+func (i *valueBlockIter) ResetStats() {
+	i.Iterator.(base.InternalIteratorWithStats).ResetStats()
+	i.stats = base.InternalIteratorStats{}
+}
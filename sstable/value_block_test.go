@@ -0,0 +1,288 @@
+// Copyright 2026 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package sstable
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+	"github.com/cockroachdb/pebble/internal/base"
+	"github.com/cockroachdb/pebble/internal/cache"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValueBlocks(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	keys := make([][]byte, 500)
+	values := make([][]byte, len(keys))
+	for i := range keys {
+		keys[i] = []byte(fmt.Sprintf("%06d", i))
+		// Mostly small values, with some many times the block size.
+		n := rng.Intn(64)
+		if rng.Intn(4) == 0 {
+			n = rng.Intn(10 << 10)
+		}
+		values[i] = bytes.Repeat([]byte{byte('a' + i%26)}, n)
+	}
+
+	c := cache.New(1 << 20)
+	defer c.Unref()
+	for _, compression := range []Compression{NoCompression, SnappyCompression, ZstdCompression} {
+		for _, indexBlockSize := range []int{4096, 64} {
+			t.Run(fmt.Sprintf("%s/index-block-size=%d", compression, indexBlockSize), func(t *testing.T) {
+				f := &memFile{}
+				w := NewWriter(f, WriterOptions{
+					BlockSize:          512,
+					IndexBlockSize:     indexBlockSize,
+					Compression:        compression,
+					MaxInlineValueSize: 256,
+					TableFormat:        TableFormatPebblev4,
+				})
+				var chunked uint64
+				for i := range keys {
+					k := base.MakeInternalKey(keys[i], 1, InternalKeyKindSet)
+					if i%3 == 0 {
+						k = base.MakeInternalKey(keys[i], 1, InternalKeyKindMerge)
+					}
+					require.NoError(t, w.Add(k, values[i]))
+					if len(values[i]) > 256 {
+						chunked++
+					}
+				}
+				require.NoError(t, w.Close())
+
+				r, err := NewMemReader(f.Data(), ReaderOptions{Cache: c})
+				require.NoError(t, err)
+				defer r.Close()
+				require.Equal(t, chunked, r.Properties.NumChunkedValues)
+				require.NotZero(t, r.Properties.ValueBlocksSize)
+				require.NoError(t, r.ValidateBlockChecksums())
+
+				// No data block holds a chunked value.
+				l, err := r.Layout()
+				require.NoError(t, err)
+				for _, bh := range l.Data {
+					require.LessOrEqual(t, bh.Length, uint64(1024))
+				}
+
+				forward := func(iter Iterator) {
+					var n int
+					for k, v := iter.First(); k != nil; k, v = iter.Next() {
+						require.Equal(t, keys[n], k.UserKey)
+						require.Equal(t, values[n], v)
+						n++
+					}
+					require.Equal(t, len(keys), n)
+					require.NoError(t, iter.Error())
+				}
+
+				iter, err := r.NewIter(nil /* lower */, nil /* upper */)
+				require.NoError(t, err)
+				forward(iter)
+				{
+					n := len(keys)
+					for k, v := iter.Last(); k != nil; k, v = iter.Prev() {
+						n--
+						require.Equal(t, keys[n], k.UserKey)
+						require.Equal(t, values[n], v)
+					}
+					require.Equal(t, 0, n)
+					for i := 0; i < 100; i++ {
+						j := rng.Intn(len(keys))
+						k, v := iter.SeekGE(keys[j], base.SeekGEFlagsNone)
+						require.Equal(t, keys[j], k.UserKey)
+						require.Equal(t, values[j], v)
+					}
+				}
+				require.NoError(t, iter.Close())
+
+				// Compactions read chunked values through the same wrapper.
+				var bytesIterated uint64
+				iter, err = r.NewCompactionIter(&bytesIterated)
+				require.NoError(t, err)
+				forward(iter)
+				require.NoError(t, iter.Close())
+			})
+		}
+	}
+}
+
+func TestValueBlocksStats(t *testing.T) {
+	f := &memFile{}
+	w := NewWriter(f, WriterOptions{
+		BlockSize:          64,
+		MaxInlineValueSize: 16,
+		TableFormat:        TableFormatPebblev4,
+	})
+	require.NoError(t, w.Set([]byte("a"), []byte("a")))
+	require.NoError(t, w.Set([]byte("b"), bytes.Repeat([]byte("b"), 200)))
+	require.NoError(t, w.Close())
+
+	r, err := NewMemReader(f.Data(), ReaderOptions{})
+	require.NoError(t, err)
+	defer r.Close()
+	iter, err := r.NewIter(nil /* lower */, nil /* upper */)
+	require.NoError(t, err)
+	defer iter.Close()
+	stats := iter.(base.InternalIteratorWithStats)
+
+	k, _ := iter.SeekGE([]byte("a"), base.SeekGEFlagsNone)
+	require.NotNil(t, k)
+	before := stats.Stats().BlockReads
+	// The value of b is chunked into four value blocks.
+	k, v := iter.Next()
+	require.NotNil(t, k)
+	require.Equal(t, 200, len(v))
+	require.Equal(t, before+4, stats.Stats().BlockReads)
+}
+
+func TestValueBlocksLazy(t *testing.T) {
+	f := &memFile{}
+	w := NewWriter(f, WriterOptions{
+		BlockSize:          64,
+		MaxInlineValueSize: 16,
+		TableFormat:        TableFormatPebblev4,
+	})
+	require.NoError(t, w.Set([]byte("a"), []byte("a")))
+	require.NoError(t, w.Set([]byte("b"), bytes.Repeat([]byte("b"), 200)))
+	require.NoError(t, w.Set([]byte("c"), []byte("c")))
+	require.NoError(t, w.Close())
+
+	r, err := NewMemReader(f.Data(), ReaderOptions{})
+	require.NoError(t, err)
+	defer r.Close()
+	iter, err := r.NewIterWithBlockPropertyFilters(
+		nil /* lower */, nil /* upper */, nil /* filterer */, true, /* useFilterBlock */
+		IterOptions{LazyValues: true})
+	require.NoError(t, err)
+	defer iter.Close()
+	stats := iter.(base.InternalIteratorWithStats)
+
+	k, _ := iter.SeekGE([]byte("a"), base.SeekGEFlagsNone)
+	require.NotNil(t, k)
+	before := stats.Stats().BlockReads
+	// Positioning the iterator at b doesn't read its value blocks.
+	k, v := iter.Next()
+	require.Equal(t, "b", string(k.UserKey))
+	require.Equal(t, valuePrefixChunked, v[0])
+	require.Equal(t, before, stats.Stats().BlockReads)
+	v, err = base.FetchValue(iter, v)
+	require.NoError(t, err)
+	require.Equal(t, bytes.Repeat([]byte("b"), 200), v)
+	require.Equal(t, before+4, stats.Stats().BlockReads)
+	// The value is only fetched once.
+	_, err = base.FetchValue(iter, v)
+	require.NoError(t, err)
+	require.Equal(t, before+4, stats.Stats().BlockReads)
+
+	k, v = iter.Next()
+	require.Equal(t, "c", string(k.UserKey))
+	v, err = base.FetchValue(iter, v)
+	require.NoError(t, err)
+	require.Equal(t, "c", string(v))
+}
+
+func TestValueBlocksLayout(t *testing.T) {
+	f := &memFile{}
+	w := NewWriter(f, WriterOptions{
+		BlockSize:          64,
+		Compression:        NoCompression,
+		MaxInlineValueSize: 16,
+		TableFormat:        TableFormatPebblev4,
+	})
+	require.NoError(t, w.Set([]byte("a"), []byte("a")))
+	require.NoError(t, w.Set([]byte("b"), bytes.Repeat([]byte("b"), 200)))
+	// The value blocks are written as soon as the value is added, rather than
+	// held until Close.
+	require.LessOrEqual(t, uint64(200), w.EstimatedSize())
+	for c := byte('c'); c <= 'z'; c++ {
+		require.NoError(t, w.Set([]byte{c}, bytes.Repeat([]byte{c}, 8)))
+	}
+	require.NoError(t, w.Close())
+
+	r, err := NewMemReader(f.Data(), ReaderOptions{})
+	require.NoError(t, err)
+	defer r.Close()
+	l, err := r.Layout()
+	require.NoError(t, err)
+	require.Equal(t, 4, len(l.Value))
+	require.Less(t, 1, len(l.Data))
+	// The value blocks of b precede the data block holding its handle, which
+	// is the first, and the data blocks are otherwise contiguous.
+	last := l.Value[len(l.Value)-1]
+	require.Equal(t, last.Offset+last.Length+blockTrailerLen, l.Data[0].Offset)
+	for j := 1; j < len(l.Data); j++ {
+		prev := l.Data[j-1]
+		require.Equal(t, prev.Offset+prev.Length+blockTrailerLen, l.Data[j].Offset)
+	}
+}
+
+func TestValueBlocksTableFormat(t *testing.T) {
+	f := &memFile{}
+	w := NewWriter(f, WriterOptions{
+		MaxInlineValueSize: 16,
+		TableFormat:        TableFormatPebblev3,
+	})
+	require.NoError(t, w.Set([]byte("a"), bytes.Repeat([]byte("a"), 32)))
+	require.EqualError(t, w.Close(), "table format version (Pebble,v3) is less than the "+
+		"minimum required version (Pebble,v4) for value blocks")
+}
+
+func TestValueBlocksCorruptHandle(t *testing.T) {
+	f := &memFile{}
+	w := NewWriter(f, WriterOptions{
+		MaxInlineValueSize: 16,
+		TableFormat:        TableFormatPebblev4,
+	})
+	// Write a value handle referencing a value block beyond the last, bypassing
+	// the value prefix the Writer adds.
+	w.valueBlocks.nextHandle(32)
+	require.NoError(t, w.addValueBlocks(bytes.Repeat([]byte("a"), 32)))
+	handle := valueHandle{valueLen: 32, firstBlock: 1, numBlocks: 1}.encode(nil)
+	w.dataBlockBuf.dataBlock.add(base.MakeInternalKey([]byte("a"), 1, InternalKeyKindSet), handle)
+	require.NoError(t, w.Close())
+
+	r, err := NewMemReader(f.Data(), ReaderOptions{})
+	require.NoError(t, err)
+	defer r.Close()
+	iter, err := r.NewIter(nil /* lower */, nil /* upper */)
+	require.NoError(t, err)
+	k, _ := iter.First()
+	require.Nil(t, k)
+	require.True(t, errors.Is(iter.Error(), base.ErrCorruption))
+	require.Error(t, iter.Close())
+}
+
+func TestRewriteKeySuffixesChunkedValues(t *testing.T) {
+	o := WriterOptions{
+		Comparer:           test4bSuffixComparer,
+		MaxInlineValueSize: 16,
+		TableFormat:        TableFormatPebblev4,
+	}
+	f := &memFile{}
+	w := NewWriter(f, o)
+	require.NoError(t, w.Set([]byte("a_123"), bytes.Repeat([]byte("a"), 32)))
+	require.NoError(t, w.Close())
+
+	_, err := RewriteKeySuffixes(f.Data(), ReaderOptions{Comparer: test4bSuffixComparer},
+		&memFile{}, o, []byte("_123"), []byte("_456"), 1)
+	require.EqualError(t, err, "sstables with chunked values must be rewritten with RewriteKeySuffixesViaWriter")
+
+	r, err := NewMemReader(f.Data(), ReaderOptions{Comparer: test4bSuffixComparer})
+	require.NoError(t, err)
+	defer r.Close()
+	out := &memFile{}
+	_, err = RewriteKeySuffixesViaWriter(r, out, o, []byte("_123"), []byte("_456"))
+	require.NoError(t, err)
+	r2, err := NewMemReader(out.Data(), ReaderOptions{Comparer: test4bSuffixComparer})
+	require.NoError(t, err)
+	defer r2.Close()
+	v, err := r2.get([]byte("a_456"))
+	require.NoError(t, err)
+	require.Equal(t, bytes.Repeat([]byte("a"), 32), v)
+}
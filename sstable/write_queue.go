@@ -12,6 +12,9 @@ type writeTask struct {
 	// before adding the writeTask back to the pool.
 	compressionDone chan bool
 	buf             *dataBlockBuf
+	// If this is not nil, then this value block is written instead of the
+	// data block of buf, which is nil.
+	valueBlock *valueBlockBuf
 	// If this is not nil, then this index block will be flushed.
 	flushableIndexBlock *indexBlockBuf
 	// currIndexBlock is the index block on which indexBlock.add must be called.
@@ -64,6 +67,10 @@ func (w *writeQueue) performWrite(task *writeTask) error {
 	var bh BlockHandle
 	var bhp BlockHandleWithProperties
 
+	if task.valueBlock != nil {
+		return w.writer.writeValueBlock(task.valueBlock, task.inflightSize)
+	}
+
 	var err error
 	if bh, err = w.writer.writeCompressedBlock(task.buf.compressed, task.buf.tmp[:]); err != nil {
 		return err
@@ -87,8 +94,12 @@ func (w *writeQueue) performWrite(task *writeTask) error {
 // It is necessary to ensure that none of the buffers in the writeTask,
 // dataBlockBuf, indexBlockBuf, are pointed to by another struct.
 func (w *writeQueue) releaseBuffers(task *writeTask) {
-	task.buf.clear()
-	dataBlockBufPool.Put(task.buf)
+	if task.valueBlock != nil {
+		valueBlockBufPool.Put(task.valueBlock)
+	} else {
+		task.buf.clear()
+		dataBlockBufPool.Put(task.buf)
+	}
 
 	// This index block is no longer used by the Writer, so we can add it back
 	// to the pool.
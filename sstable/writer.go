@@ -137,14 +137,34 @@ type Writer struct {
 	indexBlockSizeThreshold int
 	compare                 Compare
 	split                   Split
+	normalize               func(dst, key []byte) []byte
+	normalizeBuf            []byte
 	formatKey               base.FormatKey
 	compression             Compression
+	compressionDict         []byte
 	separator               Separator
 	successor               Successor
 	tableFormat             TableFormat
 	cache                   *cache.Cache
 	restartInterval         int
 	checksumType            ChecksumType
+	blockAlignment          uint64
+	// alignmentPadding holds blockAlignment zeros, written before unaligned
+	// blocks if blockAlignment is greater than 1.
+	alignmentPadding []byte
+	// maxInlineValueSize is the size above which values are chunked into the
+	// value blocks of valueBlocks, if valueBlocks is non-nil.
+	maxInlineValueSize int
+	valueBlocks        *valueBlockWriter
+	// valueBuf holds the value prefix and value, or value handle, of the last
+	// point key with a value prefix.
+	valueBuf []byte
+	// valueIndex holds the entries of the value index block for the value
+	// blocks written so far, and valueBlocksSize their total size. They must
+	// only be accessed from the writeQueue goroutine, until the writeQueue is
+	// finished.
+	valueIndex      []byte
+	valueBlocksSize uint64
 	// disableKeyOrderChecks disables the checks that keys are added to an
 	// sstable in order. It is intended for internal use only in the construction
 	// of invalid sstables for testing. See tool/make_test_sstables.go.
@@ -176,6 +196,7 @@ type Writer struct {
 	props               Properties
 	propCollectors      []TablePropertyCollector
 	blockPropCollectors []BlockPropertyCollector
+	userProps           map[string]string
 	blockPropsEncoder   blockPropertiesEncoder
 	// filter accumulates the filter block. If populated, the filter ingests
 	// either the output of w.split (i.e. a prefix extractor) if w.split is not
@@ -588,8 +609,8 @@ func (d *dataBlockBuf) finish() {
 	d.uncompressed = d.dataBlock.finish()
 }
 
-func (d *dataBlockBuf) compressAndChecksum(c Compression) {
-	d.compressed = compressAndChecksum(d.uncompressed, c, &d.blockBuf)
+func (d *dataBlockBuf) compressAndChecksum(c Compression, dict []byte) {
+	d.compressed = compressAndChecksum(d.uncompressed, c, dict, &d.blockBuf)
 }
 
 func (d *dataBlockBuf) shouldFlush(
@@ -717,10 +738,26 @@ func (w *Writer) addPoint(key InternalKey, value []byte) error {
 		}
 	}
 
-	if err := w.maybeFlush(key, value); err != nil {
+	storedValue := value
+	var chunked bool
+	if hasValuePrefix(w.tableFormat, key.Kind()) {
+		storedValue, chunked = w.prefixValue(value)
+	}
+
+	if err := w.maybeFlush(key, storedValue); err != nil {
 		return err
 	}
 
+	// The value blocks of a chunked value are written after the preceding
+	// data block has been flushed, so they precede the data block holding the
+	// value's handle.
+	if chunked {
+		if err := w.addValueBlocks(value); err != nil {
+			w.err = err
+			return w.err
+		}
+	}
+
 	for i := range w.propCollectors {
 		if err := w.propCollectors[i].Add(key, value); err != nil {
 			w.err = err
@@ -735,7 +772,7 @@ func (w *Writer) addPoint(key InternalKey, value []byte) error {
 	}
 
 	w.maybeAddToFilter(key.UserKey)
-	w.dataBlockBuf.dataBlock.add(key, value)
+	w.dataBlockBuf.dataBlock.add(key, storedValue)
 
 	w.meta.updateSeqNum(key.SeqNum())
 
@@ -764,6 +801,21 @@ func (w *Writer) addPoint(key InternalKey, value []byte) error {
 	return nil
 }
 
+// prefixValue returns the value to store in the data block for a value with a
+// value prefix: the value preceded by its prefix, or the value handle of the
+// value blocks into which it's chunked if it's too large to store inline, in
+// which case chunked is true and the value must be passed to addValueBlocks.
+// The returned slice is only valid until the next call.
+func (w *Writer) prefixValue(value []byte) (_ []byte, chunked bool) {
+	if w.valueBlocks != nil && len(value) > w.maxInlineValueSize {
+		w.props.NumChunkedValues++
+		w.valueBuf = w.valueBlocks.nextHandle(len(value)).encode(w.valueBuf[:0])
+		return w.valueBuf, true
+	}
+	w.valueBuf = append(append(w.valueBuf[:0], valuePrefixInline), value...)
+	return w.valueBuf, false
+}
+
 func (w *Writer) prettyTombstone(k InternalKey, value []byte) fmt.Formatter {
 	return keyspan.Span{
 		Start: k.UserKey,
@@ -1074,11 +1126,15 @@ func (w *Writer) tempRangeKeyCopy(k []byte) []byte {
 func (w *Writer) maybeAddToFilter(key []byte) {
 	if w.filter != nil {
 		if w.split != nil {
-			prefix := key[:w.split(key)]
-			w.filter.addKey(prefix)
-		} else {
-			w.filter.addKey(key)
+			key = key[:w.split(key)]
 		}
+		// Keys that compare equal under a normalizing comparer must share
+		// their filter entries.
+		if w.normalize != nil {
+			w.normalizeBuf = w.normalize(w.normalizeBuf[:0], key)
+			key = w.normalizeBuf
+		}
+		w.filter.addKey(key)
 	}
 }
 
@@ -1095,7 +1151,7 @@ func (w *Writer) flush(key InternalKey) error {
 	}
 
 	w.dataBlockBuf.finish()
-	w.dataBlockBuf.compressAndChecksum(w.compression)
+	w.dataBlockBuf.compressAndChecksum(w.compression, w.compressionDict)
 
 	// Determine if the index block should be flushed. Since we're accessing the
 	// dataBlockBuf.dataBlock.curKey here, we have to make sure that once we start
@@ -1449,10 +1505,12 @@ func (w *Writer) writeTwoLevelIndex() (BlockHandle, error) {
 	return w.writeBlock(w.topLevelIndexBlock.finish(), w.compression, &w.blockBuf)
 }
 
-func compressAndChecksum(b []byte, compression Compression, blockBuf *blockBuf) []byte {
+func compressAndChecksum(
+	b []byte, compression Compression, dict []byte, blockBuf *blockBuf,
+) []byte {
 	// Compress the buffer, discarding the result if the improvement isn't at
 	// least 12.5%.
-	blockType, compressed := compressBlock(compression, b, blockBuf.compressedBuf)
+	blockType, compressed := compressBlock(compression, b, blockBuf.compressedBuf, dict)
 	if blockType != noCompressionBlockType && cap(compressed) > cap(blockBuf.compressedBuf) {
 		blockBuf.compressedBuf = compressed[:cap(compressed)]
 	}
@@ -1471,6 +1529,15 @@ func compressAndChecksum(b []byte, compression Compression, blockBuf *blockBuf)
 }
 
 func (w *Writer) writeCompressedBlock(block []byte, blockTrailerBuf []byte) (BlockHandle, error) {
+	if w.blockAlignment > 1 {
+		if rem := w.meta.Size % w.blockAlignment; rem != 0 {
+			n, err := w.writer.Write(w.alignmentPadding[:w.blockAlignment-rem])
+			if err != nil {
+				return BlockHandle{}, err
+			}
+			w.meta.Size += uint64(n)
+		}
+	}
 	bh := BlockHandle{Offset: w.meta.Size, Length: uint64(len(block))}
 
 	if w.cacheID != 0 && w.fileNum != 0 {
@@ -1500,7 +1567,7 @@ func (w *Writer) writeCompressedBlock(block []byte, blockTrailerBuf []byte) (Blo
 func (w *Writer) writeBlock(
 	b []byte, compression Compression, blockBuf *blockBuf,
 ) (BlockHandle, error) {
-	b = compressAndChecksum(b, compression, blockBuf)
+	b = compressAndChecksum(b, compression, w.compressionDict, blockBuf)
 	return w.writeCompressedBlock(b, blockBuf.tmp[:])
 }
 
@@ -1523,6 +1590,22 @@ func (w *Writer) assertFormatCompatibility() error {
 		)
 	}
 
+	// PebbleDBv3: compression dictionaries.
+	if w.compressionDict != nil && w.tableFormat < TableFormatPebblev3 {
+		return errors.Newf(
+			"table format version %s is less than the minimum required version %s for compression dictionaries",
+			w.tableFormat, TableFormatPebblev3,
+		)
+	}
+
+	// PebbleDBv4: value blocks.
+	if w.valueBlocks != nil && w.tableFormat < TableFormatPebblev4 {
+		return errors.Newf(
+			"table format version %s is less than the minimum required version %s for value blocks",
+			w.tableFormat, TableFormatPebblev4,
+		)
+	}
+
 	return nil
 }
 
@@ -1584,7 +1667,19 @@ func (w *Writer) Close() (err error) {
 			return err
 		}
 	}
-	w.props.DataSize = w.meta.Size
+	// The value blocks are interleaved with the data blocks.
+	w.props.DataSize = w.meta.Size - w.valueBlocksSize
+	w.props.ValueBlocksSize = w.valueBlocksSize
+
+	// Write the value index block.
+	var valueIndexBH BlockHandle
+	if len(w.valueIndex) > 0 {
+		valueIndexBH, err = w.writeBlock(w.valueIndex, NoCompression, &w.blockBuf)
+		if err != nil {
+			w.err = err
+			return w.err
+		}
+	}
 
 	// Write the filter block.
 	var metaindex rawBlockWriter
@@ -1606,6 +1701,19 @@ func (w *Writer) Close() (err error) {
 		w.props.FilterSize = bh.Length
 	}
 
+	// Write the compression dictionary block. The metaindex block entries must
+	// be sorted, and the compression dictionary block name sorts before the
+	// range key, properties and range deletion block names.
+	if w.compressionDict != nil {
+		bh, err := w.writeBlock(w.compressionDict, NoCompression, &w.blockBuf)
+		if err != nil {
+			w.err = err
+			return w.err
+		}
+		n := encodeBlockHandle(w.blockBuf.tmp[:], bh)
+		metaindex.add(InternalKey{UserKey: []byte(metaCompressionDictName)}, w.blockBuf.tmp[:n])
+	}
+
 	var indexBH BlockHandle
 	if w.twoLevelIndex {
 		w.props.IndexType = twoLevelIndex
@@ -1691,6 +1799,14 @@ func (w *Writer) Close() (err error) {
 		metaindex.add(InternalKey{UserKey: []byte(metaRangeKeyName)}, w.blockBuf.tmp[:n])
 	}
 
+	// Add the value index block handle to the metaindex block. The value index
+	// block name sorts after the range key block name, and before the other
+	// block names.
+	if valueIndexBH.Length > 0 {
+		n := encodeBlockHandle(w.blockBuf.tmp[:], valueIndexBH)
+		metaindex.add(InternalKey{UserKey: []byte(metaValueIndexName)}, w.blockBuf.tmp[:n])
+	}
+
 	{
 		userProps := make(map[string]string)
 		for i := range w.propCollectors {
@@ -1718,6 +1834,13 @@ func (w *Writer) Close() (err error) {
 			// that the block property collector was used when writing.
 			userProps[w.blockPropCollectors[i].Name()] = prop
 		}
+		for k, v := range w.userProps {
+			if _, ok := userProps[k]; ok {
+				w.err = errors.Errorf("pebble: user property %q conflicts with a property collector", k)
+				return w.err
+			}
+			userProps[k] = v
+		}
 		if len(userProps) > 0 {
 			w.props.UserProperties = userProps
 		}
@@ -1829,6 +1952,12 @@ func (w *Writer) EstimatedSize() uint64 {
 		w.indexBlock.estimatedSize()
 }
 
+// NumPointKeys returns the number of point keys added to the sstable,
+// including point tombstones. Range deletions and range keys aren't counted.
+func (w *Writer) NumPointKeys() uint64 {
+	return w.props.NumEntries - w.props.NumRangeDeletions
+}
+
 // Metadata returns the metadata for the finished sstable. Only valid to call
 // after the sstable has been finished.
 func (w *Writer) Metadata() (*WriterMetadata, error) {
@@ -1900,6 +2029,7 @@ func NewWriter(f writeCloseSyncer, o WriterOptions, extraOpts ...WriterOption) *
 		indexBlockSizeThreshold: (o.IndexBlockSize*o.BlockSizeThreshold + 99) / 100,
 		compare:                 o.Comparer.Compare,
 		split:                   o.Comparer.Split,
+		normalize:               o.Comparer.Normalize,
 		formatKey:               o.Comparer.FormatKey,
 		compression:             o.Compression,
 		separator:               o.Comparer.Separator,
@@ -1908,6 +2038,7 @@ func NewWriter(f writeCloseSyncer, o WriterOptions, extraOpts ...WriterOption) *
 		cache:                   o.Cache,
 		restartInterval:         o.BlockRestartInterval,
 		checksumType:            o.Checksum,
+		blockAlignment:          uint64(o.BlockAlignment),
 		indexBlock:              newIndexBlockBuf(o.Parallelism),
 		rangeDelBlock: blockWriter{
 			restartInterval: 1,
@@ -1926,6 +2057,11 @@ func NewWriter(f writeCloseSyncer, o WriterOptions, extraOpts ...WriterOption) *
 
 	w.dataBlockBuf = newDataBlockBuf(w.restartInterval, w.checksumType)
 
+	if w.blockAlignment > 1 {
+		w.alignmentPadding = make([]byte, w.blockAlignment)
+		w.props.BlockAlignment = w.blockAlignment
+	}
+
 	w.blockBuf = blockBuf{
 		checksummer: checksummer{checksumType: o.Checksum},
 	}
@@ -1937,6 +2073,24 @@ func NewWriter(f writeCloseSyncer, o WriterOptions, extraOpts ...WriterOption) *
 		return w
 	}
 
+	if o.Compression == ZstdCompression && len(o.CompressionDict) > 0 {
+		if err := validateCompressionDict(o.CompressionDict); err != nil {
+			w.err = err
+			return w
+		}
+		w.compressionDict = o.CompressionDict
+	}
+
+	if o.MaxInlineValueSize > 0 {
+		w.maxInlineValueSize = o.MaxInlineValueSize
+		w.valueBlocks = &valueBlockWriter{
+			blockSize:    o.BlockSize,
+			compression:  o.Compression,
+			checksumType: o.Checksum,
+			dict:         w.compressionDict,
+		}
+	}
+
 	// Note that WriterOptions are applied in two places; the ones with a
 	// preApply() method are applied here, and the rest are applied after
 	// default properties are set.
@@ -1970,6 +2124,12 @@ func NewWriter(f writeCloseSyncer, o WriterOptions, extraOpts ...WriterOption) *
 	w.props.PropertyCollectorNames = "[]"
 	w.props.ExternalFormatVersion = rocksDBExternalFormatVersion
 
+	if len(o.UserProperties) > 0 {
+		w.userProps = make(map[string]string, len(o.UserProperties))
+		for k, v := range o.UserProperties {
+			w.userProps[k] = v
+		}
+	}
 	if len(o.TablePropertyCollectors) > 0 || len(o.BlockPropertyCollectors) > 0 {
 		var buf bytes.Buffer
 		buf.WriteString("[")
@@ -2020,7 +2180,7 @@ func NewWriter(f writeCloseSyncer, o WriterOptions, extraOpts ...WriterOption) *
 	if _, ok := f.(flusher); ok {
 		w.writer = f
 	} else {
-		w.bufWriter = bufio.NewWriter(f)
+		w.bufWriter = bufio.NewWriterSize(f, o.WriteBufferSize)
 		w.writer = w.bufWriter
 	}
 	return w
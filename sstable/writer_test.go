@@ -277,6 +277,91 @@ func TestDoubleClose(t *testing.T) {
 	require.Equal(t, err, errWriterClosed)
 }
 
+func TestWriterUserProperties(t *testing.T) {
+	fs := vfs.NewMem()
+	f, err := fs.Create("test")
+	require.NoError(t, err)
+	userProps := map[string]string{
+		"producer":       "importer-7",
+		"schema.version": "3",
+	}
+	w := NewWriter(f, WriterOptions{
+		TablePropertyCollectors: []func() TablePropertyCollector{
+			func() TablePropertyCollector { return &keyCountPropertyCollector{} },
+		},
+		UserProperties: userProps,
+	})
+	// Modifying the map after constructing the writer has no effect.
+	userProps["producer"] = "importer-8"
+	require.NoError(t, w.Set([]byte("a"), nil))
+	require.NoError(t, w.Close())
+
+	f, err = fs.Open("test")
+	require.NoError(t, err)
+	r, err := NewReader(f, ReaderOptions{})
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{
+		"producer":       "importer-7",
+		"schema.version": "3",
+		"test.key-count": "1",
+	}, r.Properties.UserProperties)
+	require.NoError(t, r.Close())
+
+	// A user property may not collide with a collected property.
+	f, err = fs.Create("test2")
+	require.NoError(t, err)
+	w = NewWriter(f, WriterOptions{
+		TablePropertyCollectors: []func() TablePropertyCollector{
+			func() TablePropertyCollector { return &keyCountPropertyCollector{} },
+		},
+		UserProperties: map[string]string{"test.key-count": "7"},
+	})
+	require.NoError(t, w.Set([]byte("a"), nil))
+	require.EqualError(t, w.Close(), `pebble: user property "test.key-count" conflicts with a property collector`)
+}
+
+// writeRecordingFile records the lengths of the writes to a file.
+type writeRecordingFile struct {
+	vfs.File
+	writes []int
+}
+
+func (f *writeRecordingFile) Write(p []byte) (int, error) {
+	f.writes = append(f.writes, len(p))
+	return f.File.Write(p)
+}
+
+func TestWriterWriteBufferSize(t *testing.T) {
+	for _, size := range []int{0, 16 << 10} {
+		t.Run(fmt.Sprintf("size=%d", size), func(t *testing.T) {
+			fs := vfs.NewMem()
+			f, err := fs.Create("test")
+			require.NoError(t, err)
+			rf := &writeRecordingFile{File: f}
+			w := NewWriter(rf, WriterOptions{
+				BlockSize:       1 << 10,
+				Compression:     NoCompression,
+				WriteBufferSize: size,
+			})
+			for i := 0; i < 5000; i++ {
+				require.NoError(t, w.Set([]byte(fmt.Sprintf("%05d", i)), bytes.Repeat([]byte("v"), 20)))
+			}
+			require.NoError(t, w.Close())
+
+			// The output is written in chunks of the buffer size, except for
+			// the remainder flushed by Close.
+			if size == 0 {
+				size = 4 << 10
+			}
+			require.Greater(t, len(rf.writes), 2)
+			for _, n := range rf.writes[:len(rf.writes)-1] {
+				require.Equal(t, size, n)
+			}
+			require.LessOrEqual(t, rf.writes[len(rf.writes)-1], size)
+		})
+	}
+}
+
 func TestParallelWriterErrorProp(t *testing.T) {
 	fs := vfs.NewMem()
 	f, err := fs.Create("test")
@@ -295,6 +380,90 @@ func TestParallelWriterErrorProp(t *testing.T) {
 	require.Equal(t, err.Error(), "write queue write error")
 }
 
+// readRecordingFile records the lengths of the reads of a file.
+type readRecordingFile struct {
+	vfs.File
+	reads []int
+}
+
+func (f *readRecordingFile) ReadAt(p []byte, off int64) (int, error) {
+	f.reads = append(f.reads, len(p))
+	return f.File.ReadAt(p, off)
+}
+
+func TestWriterBlockAlignment(t *testing.T) {
+	const alignment = 512
+	for _, parallelism := range []bool{false, true} {
+		t.Run(fmt.Sprintf("parallelism=%t", parallelism), func(t *testing.T) {
+			fs := vfs.NewMem()
+			f, err := fs.Create("test")
+			require.NoError(t, err)
+			w := NewWriter(f, WriterOptions{
+				BlockAlignment: alignment,
+				BlockSize:      1000,
+				FilterPolicy:   bloom.FilterPolicy(10),
+				TableFormat:    TableFormatPebblev2,
+				Compression:    NoCompression,
+				Parallelism:    parallelism,
+			})
+			for i := 0; i < 1000; i++ {
+				require.NoError(t, w.Set([]byte(fmt.Sprintf("%05d", i)), bytes.Repeat([]byte("v"), 10)))
+			}
+			require.NoError(t, w.DeleteRange([]byte("00100"), []byte("00200")))
+			require.NoError(t, w.Close())
+
+			f2, err := fs.Open("test")
+			require.NoError(t, err)
+			rf := &readRecordingFile{File: f2}
+			r, err := NewReader(rf, ReaderOptions{
+				Filters: map[string]FilterPolicy{bloom.FilterPolicy(10).Name(): bloom.FilterPolicy(10)},
+			})
+			require.NoError(t, err)
+			defer r.Close()
+			require.EqualValues(t, alignment, r.Properties.BlockAlignment)
+
+			// Every block is aligned.
+			l, err := r.Layout()
+			require.NoError(t, err)
+			require.Greater(t, len(l.Data), 1)
+			handles := []BlockHandle{l.Filter, l.RangeDel, l.Properties, l.MetaIndex}
+			handles = append(handles, l.Index...)
+			for _, bh := range l.Data {
+				handles = append(handles, bh.BlockHandle)
+			}
+			for _, bh := range handles {
+				require.Zero(t, bh.Offset%alignment, "block at %d", bh.Offset)
+			}
+
+			// The table reads back correctly, and the data blocks are read in
+			// multiples of the alignment.
+			require.NoError(t, r.ValidateBlockChecksums())
+			rf.reads = rf.reads[:0]
+			iter, err := r.NewIter(nil /* lower */, nil /* upper */)
+			require.NoError(t, err)
+			var n int
+			for key, _ := iter.First(); key != nil; key, _ = iter.Next() {
+				require.Equal(t, fmt.Sprintf("%05d", n), string(key.UserKey))
+				n++
+			}
+			require.NoError(t, iter.Close())
+			require.Equal(t, 1000, n)
+			require.NotEmpty(t, rf.reads)
+			for _, n := range rf.reads {
+				require.Zero(t, n%alignment, "read of %d bytes", n)
+			}
+
+			// The padding read along with a block isn't allocated from the
+			// block cache.
+			bh := l.Data[0].BlockHandle
+			h, _, err := r.readBlock(bh, nil /* transform */, nil /* readaheadState */, true /* fillCache */)
+			require.NoError(t, err)
+			require.Equal(t, int(bh.Length+blockTrailerLen), cap(h.Get()))
+			h.Release()
+		})
+	}
+}
+
 func TestSizeEstimate(t *testing.T) {
 	var sizeEstimate sizeEstimate
 	datadriven.RunTest(t, "testdata/size_estimate",
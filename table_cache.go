@@ -413,8 +413,18 @@ func (c *tableCacheShard) newIters(
 	if internalOpts.bytesIterated != nil {
 		iter, err = v.reader.NewCompactionIter(internalOpts.bytesIterated)
 	} else {
+		atomic.AddInt64(&file.Atomic.ReadCount, 1)
+		var iterOpts sstable.IterOptions
+		if opts != nil {
+			iterOpts = sstable.IterOptions{
+				OnCorruption:     opts.OnCorruption,
+				DisableCacheFill: opts.DisableCacheFill,
+				PrefetchBlocks:   opts.PrefetchBlocks,
+			}
+		}
+		iterOpts.LazyValues = internalOpts.lazyValues
 		iter, err = v.reader.NewIterWithBlockPropertyFilters(
-			opts.GetLowerBound(), opts.GetUpperBound(), filterer, useFilter)
+			opts.GetLowerBound(), opts.GetUpperBound(), filterer, useFilter, iterOpts)
 	}
 	if err != nil {
 		if rangeDelIter != nil {
@@ -874,7 +884,7 @@ type tableCacheValue struct {
 func (v *tableCacheValue) load(meta *fileMetadata, c *tableCacheShard, dbOpts *tableCacheOpts) {
 	// Try opening the fileTypeTable first.
 	var f vfs.File
-	v.filename = base.MakeFilepath(dbOpts.fs, dbOpts.dirname, fileTypeTable, meta.FileNum)
+	v.filename = makeTableFilepath(dbOpts.fs, dbOpts.dirname, meta.FileNum, meta.Name)
 	f, v.err = dbOpts.fs.Open(v.filename, vfs.RandomReadsOption)
 	if v.err == nil {
 		cacheOpts := private.SSTableCacheOpts(dbOpts.cacheID, meta.FileNum).(sstable.ReaderOption)
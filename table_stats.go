@@ -272,6 +272,8 @@ func (d *DB) loadTableStats(
 		// additional stats that may provide improved heuristics for compaction
 		// picking.
 		stats.NumRangeKeySets = r.Properties.NumRangeKeySets
+		stats.NumRangeKeys = r.Properties.NumRangeKeys()
+		stats.RangeKeySize = r.Properties.RawRangeKeyKeySize + r.Properties.RawRangeKeyValueSize
 		return
 	})
 	if err != nil {
@@ -553,6 +555,8 @@ func maybeSetStatsFromProperties(meta *fileMetadata, props *sstable.Properties)
 	meta.Stats.NumEntries = props.NumEntries
 	meta.Stats.NumDeletions = props.NumDeletions
 	meta.Stats.NumRangeKeySets = props.NumRangeKeySets
+	meta.Stats.NumRangeKeys = props.NumRangeKeys()
+	meta.Stats.RangeKeySize = props.RawRangeKeyKeySize + props.RawRangeKeyValueSize
 	meta.Stats.PointDeletionsBytesEstimate = pointEstimate
 	meta.Stats.RangeDeletionsBytesEstimate = 0
 	meta.StatsMarkValid()
@@ -622,6 +622,9 @@ func (p *props) update(o props) {
 
 func (d *dbT) addProps(dir string, m *manifest.FileMetadata, p *props) error {
 	path := base.MakeFilepath(d.opts.FS, dir, base.FileTypeTable, m.FileNum)
+	if m.Name != "" {
+		path = d.opts.FS.PathJoin(dir, m.Name)
+	}
 	f, err := d.opts.FS.Open(path)
 	if err != nil {
 		return err
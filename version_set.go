@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"io"
 	"math"
+	"sort"
 	"sync"
 	"sync/atomic"
 
@@ -60,6 +61,10 @@ type versionSet struct {
 		// compactions. This value will be zero if there are no in-progress
 		// compactions. Updated and read atomically.
 		atomicInProgressBytes int64
+
+		// The smallest sequence number at which the versions of keys are
+		// known to be retained, outside of snapshots. See DB.GetAsOf.
+		historyFloorSeqNum uint64
 	}
 
 	// Immutable fields.
@@ -81,6 +86,10 @@ type versionSet struct {
 
 	metrics Metrics
 
+	// versionListeners are invoked with each edit applied by logAndApply. See
+	// DB.RegisterVersionListener.
+	versionListeners []func(VersionEditInfo)
+
 	// A pointer to versionSet.addObsoleteLocked. Avoids allocating a new closure
 	// on the creation of every version.
 	obsoleteFn        func(obsolete []*manifest.FileMetadata)
@@ -332,6 +341,28 @@ func (vs *versionSet) logUnlock() {
 	vs.writerCond.Signal()
 }
 
+// editMayDiscardVersions returns true if applying the version edit may
+// discard versions of keys shadowed by newer versions: if it records a flush,
+// or removes sstables other than by moving them between levels.
+func editMayDiscardVersions(ve *versionEdit) bool {
+	if ve.MinUnflushedLogNum != 0 {
+		return true
+	}
+	for df := range ve.DeletedFiles {
+		moved := false
+		for _, nf := range ve.NewFiles {
+			if nf.Meta.FileNum == df.FileNum {
+				moved = true
+				break
+			}
+		}
+		if !moved {
+			return true
+		}
+	}
+	return false
+}
+
 // logAndApply logs the version edit to the manifest, applies the version edit
 // to the current version, and installs the new version.
 //
@@ -388,6 +419,12 @@ func (vs *versionSet) logAndApply(
 		vs.opts.Logger.Fatalf("logSeqNum must be a positive integer: %d", logSeqNum)
 	}
 
+	// Advance the history floor before the edit is applied, so that a reader
+	// observing the result of the edit also observes the new floor.
+	if editMayDiscardVersions(ve) && atomic.LoadUint64(&vs.atomic.historyFloorSeqNum) < ve.LastSeqNum {
+		atomic.StoreUint64(&vs.atomic.historyFloorSeqNum, ve.LastSeqNum)
+	}
+
 	currentVersion := vs.currentVersion()
 	var newVersion *version
 
@@ -492,6 +529,12 @@ func (vs *versionSet) logAndApply(
 	if ve.MinUnflushedLogNum != 0 {
 		vs.minUnflushedLogNum = ve.MinUnflushedLogNum
 	}
+	if len(vs.versionListeners) > 0 {
+		info := makeVersionEditInfo(jobID, ve)
+		for _, fn := range vs.versionListeners {
+			fn(info)
+		}
+	}
 	if newManifestFileNum != 0 {
 		if vs.manifestFileNum != 0 {
 			vs.obsoleteManifests = append(vs.obsoleteManifests, fileInfo{
@@ -530,6 +573,30 @@ func (vs *versionSet) logAndApply(
 	return nil
 }
 
+// makeVersionEditInfo returns the VersionEditInfo describing the tables added
+// and removed by the version edit.
+func makeVersionEditInfo(jobID int, ve *versionEdit) VersionEditInfo {
+	info := VersionEditInfo{JobID: jobID}
+	var added, deleted [numLevels][]TableInfo
+	for _, nf := range ve.NewFiles {
+		added[nf.Level] = append(added[nf.Level], nf.Meta.TableInfo())
+	}
+	for df, m := range ve.DeletedFiles {
+		deleted[df.Level] = append(deleted[df.Level], m.TableInfo())
+	}
+	for level := range deleted {
+		if len(added[level]) > 0 {
+			info.Added = append(info.Added, LevelInfo{Level: level, Tables: added[level]})
+		}
+		if tables := deleted[level]; len(tables) > 0 {
+			// DeletedFiles is a map, so order the tables deterministically.
+			sort.Slice(tables, func(i, j int) bool { return tables[i].FileNum < tables[j].FileNum })
+			info.Deleted = append(info.Deleted, LevelInfo{Level: level, Tables: tables})
+		}
+	}
+	return info
+}
+
 func (vs *versionSet) incrementCompactions(kind compactionKind, extraLevels []*compactionLevel) {
 	switch kind {
 	case compactionKindDefault:
@@ -558,6 +625,10 @@ func (vs *versionSet) incrementCompactions(kind compactionKind, extraLevels []*c
 	case compactionKindRewrite:
 		vs.metrics.Compact.Count++
 		vs.metrics.Compact.RewriteCount++
+
+	case compactionKindSpaceReclamation:
+		vs.metrics.Compact.Count++
+		vs.metrics.Compact.SpaceReclamationCount++
 	}
 	if len(extraLevels) > 0 {
 		vs.metrics.Compact.MultiLevelCount++
@@ -683,6 +754,25 @@ func (vs *versionSet) addLiveFileNums(m map[FileNum]struct{}) {
 	}
 }
 
+// addLiveTableNames adds the names of the live sstables that were named by
+// Options.SSTablePathFunc to m.
+func (vs *versionSet) addLiveTableNames(m map[string]struct{}) {
+	current := vs.currentVersion()
+	for v := vs.versions.Front(); true; v = v.Next() {
+		for _, lm := range v.Levels {
+			iter := lm.Iter()
+			for f := iter.First(); f != nil; f = iter.Next() {
+				if f.Name != "" {
+					m[f.Name] = struct{}{}
+				}
+			}
+		}
+		if v == current {
+			break
+		}
+	}
+}
+
 func (vs *versionSet) addObsoleteLocked(obsolete []*manifest.FileMetadata) {
 	for _, fileMeta := range obsolete {
 		// Note that the obsolete tables are no longer zombie by the definition of
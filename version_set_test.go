@@ -106,3 +106,57 @@ func TestVersionSetSeqNums(t *testing.T) {
 	// logSeqNum is always one greater than the last assigned sequence number.
 	require.Equal(t, d.mu.versions.atomic.logSeqNum, lastSeqNum+1)
 }
+
+func TestVersionListener(t *testing.T) {
+	mem := vfs.NewMem()
+	require.NoError(t, mem.MkdirAll("ext", 0755))
+	d, err := Open("", &Options{FS: mem})
+	require.NoError(t, err)
+	defer func() { require.NoError(t, d.Close()) }()
+
+	// Maintain the LSM's tables by applying each edit.
+	type levelFile struct {
+		level   int
+		fileNum FileNum
+	}
+	files := make(map[levelFile]bool)
+	var edits []VersionEditInfo
+	d.RegisterVersionListener(func(info VersionEditInfo) {
+		edits = append(edits, info)
+		for _, l := range info.Deleted {
+			for _, t := range l.Tables {
+				delete(files, levelFile{l.Level, t.FileNum})
+			}
+		}
+		for _, l := range info.Added {
+			for _, t := range l.Tables {
+				files[levelFile{l.Level, t.FileNum}] = true
+			}
+		}
+	})
+
+	require.NoError(t, d.Set([]byte("a"), []byte("1"), nil))
+	require.NoError(t, d.Flush())
+	require.Len(t, edits, 1)
+	require.Len(t, edits[0].Added, 1)
+	require.Equal(t, 0, edits[0].Added[0].Level)
+	require.Empty(t, edits[0].Deleted)
+
+	require.NoError(t, d.Set([]byte("b"), []byte("2"), nil))
+	require.NoError(t, d.Flush())
+	writeAndIngest(t, mem, d, base.MakeInternalKey([]byte("c"), 0, InternalKeyKindSet), []byte("3"), "ext1")
+	require.NoError(t, d.Compact([]byte("a"), []byte("d"), false /* parallelize */))
+	require.Greater(t, len(edits), 3)
+
+	expected := make(map[levelFile]bool)
+	d.mu.Lock()
+	v := d.mu.versions.currentVersion()
+	for level := range v.Levels {
+		iter := v.Levels[level].Iter()
+		for m := iter.First(); m != nil; m = iter.Next() {
+			expected[levelFile{level, m.FileNum}] = true
+		}
+	}
+	d.mu.Unlock()
+	require.Equal(t, expected, files)
+}
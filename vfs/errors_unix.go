@@ -21,3 +21,16 @@ var errNotEmpty = syscall.ENOTEMPTY
 func IsNoSpaceError(err error) bool {
 	return errors.Is(err, unix.ENOSPC)
 }
+
+// IsReadOnlyError returns true if the given error indicates that the
+// filesystem is read-only.
+func IsReadOnlyError(err error) bool {
+	return errors.Is(err, unix.EROFS)
+}
+
+// IsTransientError returns true if the given error indicates that the
+// operation was interrupted or the resource was temporarily unavailable, such
+// that retrying the operation may succeed.
+func IsTransientError(err error) bool {
+	return errors.Is(err, unix.EAGAIN) || errors.Is(err, unix.EINTR)
+}
@@ -19,3 +19,14 @@ func TestIsNoSpaceError(t *testing.T) {
 	err := errors.WithStack(unix.ENOSPC)
 	require.True(t, IsNoSpaceError(err))
 }
+
+func TestIsReadOnlyError(t *testing.T) {
+	require.True(t, IsReadOnlyError(errors.Wrap(unix.EROFS, "sync")))
+	require.False(t, IsReadOnlyError(unix.ENOSPC))
+}
+
+func TestIsTransientError(t *testing.T) {
+	require.True(t, IsTransientError(errors.WithStack(unix.EAGAIN)))
+	require.True(t, IsTransientError(errors.Wrap(unix.EINTR, "read")))
+	require.False(t, IsTransientError(unix.ENOSPC))
+}
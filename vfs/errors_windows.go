@@ -20,3 +20,16 @@ func IsNoSpaceError(err error) bool {
 	return errors.Is(err, windows.ERROR_DISK_FULL) ||
 		errors.Is(err, windows.ERROR_HANDLE_DISK_FULL)
 }
+
+// IsReadOnlyError returns true if the given error indicates that the
+// filesystem is read-only.
+func IsReadOnlyError(err error) bool {
+	return errors.Is(err, windows.ERROR_WRITE_PROTECT)
+}
+
+// IsTransientError returns true if the given error indicates that the
+// operation was interrupted or the resource was temporarily unavailable, such
+// that retrying the operation may succeed.
+func IsTransientError(err error) bool {
+	return errors.Is(err, windows.ERROR_RETRY)
+}
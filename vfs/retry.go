@@ -0,0 +1,253 @@
+// Copyright 2026 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package vfs
+
+import (
+	"io"
+	"os"
+	"time"
+)
+
+// RetryPolicy configures the retrying of filesystem operations that fail
+// with transient errors. See WithRetries.
+type RetryPolicy struct {
+	// MaxRetries is the maximum number of times a failed operation is
+	// retried before its error is returned.
+	MaxRetries int
+	// InitialBackoff is the delay before the first retry of an operation. The
+	// delay doubles with each subsequent retry, up to MaxBackoff.
+	InitialBackoff time.Duration
+	// MaxBackoff is the maximum delay between retries. If zero, the delay is
+	// unbounded.
+	MaxBackoff time.Duration
+	// IsRetryable returns true if an operation that failed with err should be
+	// retried. If nil, IsTransientError is used.
+	IsRetryable func(err error) bool
+}
+
+// WithRetries wraps the provided FS with an FS that retries operations
+// failing with a retryable error, as configured by policy, before returning
+// the error. The provided callback, if non-nil, is invoked before each retry
+// with the error that caused it.
+//
+// Operations whose retry could be unsafe are not retried. In particular, a
+// failed Sync is never retried, since a successful Sync after a failed one
+// provides no guarantee about the writes preceding the failed one, and Close
+// is never retried. Writes and reads that fail part way are retried from
+// where they failed, except for Read, which is only retried if it read
+// nothing.
+func WithRetries(fs FS, policy RetryPolicy, onRetry func(err error)) FS {
+	if policy.IsRetryable == nil {
+		policy.IsRetryable = IsTransientError
+	}
+	return &retryingFS{inner: fs, policy: policy, onRetry: onRetry}
+}
+
+type retryingFS struct {
+	inner   FS
+	policy  RetryPolicy
+	onRetry func(err error)
+}
+
+// Unwrap returns the underlying FS. This may be called by vfs.Root to access
+// the underlying filesystem.
+func (fs *retryingFS) Unwrap() FS {
+	return fs.inner
+}
+
+// retry invokes op until it succeeds, fails with an error that isn't
+// retryable, or the retries are exhausted, and returns its last error.
+func (fs *retryingFS) retry(op func() error) error {
+	backoff := fs.policy.InitialBackoff
+	for attempt := 0; ; attempt++ {
+		err := op()
+		if err == nil || attempt >= fs.policy.MaxRetries || !fs.policy.IsRetryable(err) {
+			return err
+		}
+		if fs.onRetry != nil {
+			fs.onRetry(err)
+		}
+		time.Sleep(backoff)
+		if backoff *= 2; fs.policy.MaxBackoff > 0 && backoff > fs.policy.MaxBackoff {
+			backoff = fs.policy.MaxBackoff
+		}
+	}
+}
+
+func (fs *retryingFS) wrapFile(f File) File {
+	if f == nil {
+		return nil
+	}
+	return WithFd(f, retryingFile{fs: fs, inner: f})
+}
+
+func (fs *retryingFS) Create(name string) (File, error) {
+	var f File
+	err := fs.retry(func() (err error) {
+		f, err = fs.inner.Create(name)
+		return err
+	})
+	return fs.wrapFile(f), err
+}
+
+func (fs *retryingFS) Link(oldname, newname string) error {
+	return fs.retry(func() error {
+		return fs.inner.Link(oldname, newname)
+	})
+}
+
+func (fs *retryingFS) Open(name string, opts ...OpenOption) (File, error) {
+	var f File
+	err := fs.retry(func() (err error) {
+		f, err = fs.inner.Open(name, opts...)
+		return err
+	})
+	return fs.wrapFile(f), err
+}
+
+func (fs *retryingFS) OpenDir(name string) (File, error) {
+	var f File
+	err := fs.retry(func() (err error) {
+		f, err = fs.inner.OpenDir(name)
+		return err
+	})
+	return fs.wrapFile(f), err
+}
+
+func (fs *retryingFS) Remove(name string) error {
+	return fs.retry(func() error {
+		return fs.inner.Remove(name)
+	})
+}
+
+func (fs *retryingFS) RemoveAll(name string) error {
+	return fs.retry(func() error {
+		return fs.inner.RemoveAll(name)
+	})
+}
+
+func (fs *retryingFS) Rename(oldname, newname string) error {
+	return fs.retry(func() error {
+		return fs.inner.Rename(oldname, newname)
+	})
+}
+
+func (fs *retryingFS) ReuseForWrite(oldname, newname string) (File, error) {
+	var f File
+	err := fs.retry(func() (err error) {
+		f, err = fs.inner.ReuseForWrite(oldname, newname)
+		return err
+	})
+	return fs.wrapFile(f), err
+}
+
+func (fs *retryingFS) MkdirAll(dir string, perm os.FileMode) error {
+	return fs.retry(func() error {
+		return fs.inner.MkdirAll(dir, perm)
+	})
+}
+
+func (fs *retryingFS) Lock(name string) (io.Closer, error) {
+	var closer io.Closer
+	err := fs.retry(func() (err error) {
+		closer, err = fs.inner.Lock(name)
+		return err
+	})
+	return closer, err
+}
+
+func (fs *retryingFS) List(dir string) ([]string, error) {
+	var names []string
+	err := fs.retry(func() (err error) {
+		names, err = fs.inner.List(dir)
+		return err
+	})
+	return names, err
+}
+
+func (fs *retryingFS) Stat(name string) (os.FileInfo, error) {
+	var info os.FileInfo
+	err := fs.retry(func() (err error) {
+		info, err = fs.inner.Stat(name)
+		return err
+	})
+	return info, err
+}
+
+func (fs *retryingFS) PathBase(path string) string {
+	return fs.inner.PathBase(path)
+}
+
+func (fs *retryingFS) PathJoin(elem ...string) string {
+	return fs.inner.PathJoin(elem...)
+}
+
+func (fs *retryingFS) PathDir(path string) string {
+	return fs.inner.PathDir(path)
+}
+
+func (fs *retryingFS) GetDiskUsage(path string) (DiskUsage, error) {
+	var usage DiskUsage
+	err := fs.retry(func() (err error) {
+		usage, err = fs.inner.GetDiskUsage(path)
+		return err
+	})
+	return usage, err
+}
+
+type retryingFile struct {
+	fs    *retryingFS
+	inner File
+}
+
+func (f retryingFile) Close() error {
+	return f.inner.Close()
+}
+
+func (f retryingFile) Read(p []byte) (n int, err error) {
+	// A read that returned data advanced the file offset, so it's only
+	// retried if it read nothing.
+	var readErr error
+	_ = f.fs.retry(func() error {
+		n, readErr = f.inner.Read(p)
+		if n > 0 || readErr == io.EOF {
+			return nil
+		}
+		return readErr
+	})
+	return n, readErr
+}
+
+func (f retryingFile) ReadAt(p []byte, off int64) (n int, err error) {
+	err = f.fs.retry(func() error {
+		n2, err := f.inner.ReadAt(p[n:], off+int64(n))
+		n += n2
+		return err
+	})
+	return n, err
+}
+
+func (f retryingFile) Write(p []byte) (n int, err error) {
+	err = f.fs.retry(func() error {
+		n2, err := f.inner.Write(p[n:])
+		n += n2
+		return err
+	})
+	return n, err
+}
+
+func (f retryingFile) Stat() (os.FileInfo, error) {
+	var info os.FileInfo
+	err := f.fs.retry(func() (err error) {
+		info, err = f.inner.Stat()
+		return err
+	})
+	return info, err
+}
+
+func (f retryingFile) Sync() error {
+	// NB: It is NOT safe to retry the Sync. See enospcFile.Sync.
+	return f.inner.Sync()
+}
@@ -0,0 +1,114 @@
+// Copyright 2026 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package vfs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/stretchr/testify/require"
+)
+
+// flakyFS fails the next failures file creations and writes with err.
+type flakyFS struct {
+	FS
+	err      error
+	failures int
+}
+
+func (fs *flakyFS) maybeFail() error {
+	if fs.failures > 0 {
+		fs.failures--
+		return fs.err
+	}
+	return nil
+}
+
+func (fs *flakyFS) Create(name string) (File, error) {
+	if err := fs.maybeFail(); err != nil {
+		return nil, err
+	}
+	f, err := fs.FS.Create(name)
+	return &flakyFile{File: f, fs: fs}, err
+}
+
+type flakyFile struct {
+	File
+	fs *flakyFS
+}
+
+func (f *flakyFile) Write(p []byte) (int, error) {
+	if err := f.fs.maybeFail(); err != nil {
+		// Fail part way through the write.
+		n, _ := f.File.Write(p[:len(p)/2])
+		return n, err
+	}
+	return f.File.Write(p)
+}
+
+func (f *flakyFile) Sync() error {
+	if err := f.fs.maybeFail(); err != nil {
+		return err
+	}
+	return f.File.Sync()
+}
+
+func TestWithRetries(t *testing.T) {
+	errTransient := errors.New("transient")
+	inner := &flakyFS{FS: NewMem(), err: errTransient}
+	var retries []error
+	fs := WithRetries(inner, RetryPolicy{
+		MaxRetries:     3,
+		InitialBackoff: time.Microsecond,
+		MaxBackoff:     2 * time.Microsecond,
+		IsRetryable:    func(err error) bool { return errors.Is(err, errTransient) },
+	}, func(err error) { retries = append(retries, err) })
+	require.Equal(t, inner, Root(fs))
+
+	// Operations are retried until they succeed.
+	inner.failures = 3
+	f, err := fs.Create("foo")
+	require.NoError(t, err)
+	require.Equal(t, []error{errTransient, errTransient, errTransient}, retries)
+
+	// Writes are retried from where they failed.
+	retries = nil
+	inner.failures = 1
+	n, err := f.Write([]byte("abcdef"))
+	require.NoError(t, err)
+	require.Equal(t, 6, n)
+	require.Len(t, retries, 1)
+
+	// Syncs are never retried.
+	retries = nil
+	inner.failures = 1
+	require.Equal(t, errTransient, f.Sync())
+	require.Empty(t, retries)
+	require.NoError(t, f.Sync())
+	require.NoError(t, f.Close())
+
+	f, err = fs.Open("foo")
+	require.NoError(t, err)
+	buf := make([]byte, 6)
+	_, err = f.ReadAt(buf, 0)
+	require.NoError(t, err)
+	require.Equal(t, "abcdef", string(buf))
+	require.NoError(t, f.Close())
+
+	// The error is returned once the retries are exhausted.
+	inner.failures = 4
+	_, err = fs.Create("bar")
+	require.Equal(t, errTransient, err)
+	require.Len(t, retries, 3)
+
+	// Errors that aren't retryable are returned immediately.
+	retries = nil
+	inner.err = errors.New("permanent")
+	inner.failures = 1
+	_, err = fs.Create("bar")
+	require.Equal(t, inner.err, err)
+	require.Empty(t, retries)
+}
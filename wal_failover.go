@@ -0,0 +1,301 @@
+// Copyright 2026 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package pebble
+
+import (
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/cockroachdb/pebble/internal/base"
+	"github.com/cockroachdb/pebble/vfs"
+)
+
+// walLocation describes a directory in which WALs are created.
+type walLocation struct {
+	fs      vfs.FS
+	dirname string
+	dir     vfs.File
+	// secondary is true if the directory is the secondary WAL directory
+	// configured by Options.WALFailover.
+	secondary bool
+}
+
+func (d *DB) primaryWALLocation() walLocation {
+	return walLocation{fs: d.opts.FS, dirname: d.walDirname, dir: d.walDir}
+}
+
+func (d *DB) secondaryWALLocation() walLocation {
+	return walLocation{
+		fs:        d.opts.WALFailover.SecondaryFS,
+		dirname:   d.opts.WALFailover.SecondaryDir,
+		dir:       d.walFailoverDir,
+		secondary: true,
+	}
+}
+
+// logLocationLocked returns the location of the WAL with the given file
+// number.
+//
+// d.mu must be held when calling this.
+func (d *DB) logLocationLocked(logNum FileNum) walLocation {
+	if _, ok := d.mu.log.failover.secondaryLogs[logNum]; ok {
+		return d.secondaryWALLocation()
+	}
+	return d.primaryWALLocation()
+}
+
+// walLocationLocked returns the location in which the next WAL should be
+// created. It fails over to the secondary WAL directory if a sync of a WAL in
+// the primary directory was slow, and fails back to the primary directory once
+// Options.WALFailover.FailbackInterval has elapsed.
+//
+// d.mu must be held when calling this.
+func (d *DB) walLocationLocked() walLocation {
+	opts := d.opts.WALFailover
+	if opts == nil {
+		return d.primaryWALLocation()
+	}
+	f := &d.mu.log.failover
+	now := d.timeNow()
+	// A slow sync reported while failed over may have been from the WAL that
+	// was current when failing over, and is ignored.
+	if atomic.SwapUint32(&d.atomic.walUnhealthy, 0) == 1 && !f.active {
+		d.opts.Logger.Infof("pebble: WAL sync in %s exceeded %s; failing over to %s",
+			d.walDirname, opts.UnhealthySyncLatencyThreshold, opts.SecondaryDir)
+		f.active = true
+		f.activatedAt = now
+		f.errors = 0
+	} else if f.active && now.Sub(f.activatedAt) >= opts.FailbackInterval {
+		d.opts.Logger.Infof("pebble: failing back WAL from %s to %s", opts.SecondaryDir, d.walDirname)
+		f.active = false
+		f.errors = 0
+	}
+	if f.active {
+		return d.secondaryWALLocation()
+	}
+	return d.primaryWALLocation()
+}
+
+// failoverWALLocked fails over to the secondary WAL directory after a WAL
+// could not be created in the primary directory, returning the secondary
+// location.
+//
+// d.mu must be held when calling this.
+func (d *DB) failoverWALLocked(err error) walLocation {
+	d.opts.Logger.Infof("pebble: unable to create WAL in %s: %s; failing over to %s",
+		d.walDirname, err, d.opts.WALFailover.SecondaryDir)
+	d.mu.log.failover.active = true
+	d.mu.log.failover.activatedAt = d.timeNow()
+	d.mu.log.failover.errors = 0
+	return d.secondaryWALLocation()
+}
+
+// currentWALSecondaryLocked returns true if the current WAL is in the
+// secondary WAL directory.
+//
+// d.mu must be held when calling this.
+func (d *DB) currentWALSecondaryLocked() bool {
+	_, ok := d.mu.log.failover.secondaryLogs[d.mu.log.queue[len(d.mu.log.queue)-1].fileNum]
+	return ok
+}
+
+// walFailedLocked returns true if a write to, or sync of, the current WAL
+// failed, and the failure is to be recovered from by rotating the WAL: WAL
+// failover is configured, and the WAL is in the primary WAL directory.
+//
+// commitPipeline.mu and d.mu must be held when calling this.
+func (d *DB) walFailedLocked() bool {
+	if d.opts.WALFailover == nil || d.opts.DisableWAL || d.mu.log.writerClosed {
+		return false
+	}
+	return d.mu.log.Err() != nil && !d.currentWALSecondaryLocked()
+}
+
+// walErrorLocked records err, the error of a failed write to, or sync of, a
+// WAL in the primary WAL directory. Once
+// Options.WALFailover.UnhealthyErrorThreshold errors have occurred since the
+// last failover or failback, the WAL fails over to the secondary directory.
+//
+// d.mu must be held when calling this.
+func (d *DB) walErrorLocked(err error) {
+	opts := d.opts.WALFailover
+	f := &d.mu.log.failover
+	if f.active {
+		// The error is from the WAL that was current when failing over.
+		return
+	}
+	f.errors++
+	if f.errors < opts.UnhealthyErrorThreshold {
+		d.opts.Logger.Infof("pebble: WAL write in %s failed: %s; rotating the WAL", d.walDirname, err)
+		return
+	}
+	d.opts.Logger.Infof("pebble: WAL write in %s failed: %s; failing over to %s",
+		d.walDirname, err, opts.SecondaryDir)
+	f.active = true
+	f.activatedAt = d.timeNow()
+	f.errors = 0
+}
+
+// recoverWALSyncError recovers from err, the error syncing the WAL to which an
+// applied batch was written, if the WAL is in the primary WAL directory and
+// WAL failover is configured. The WAL is rotated, and the memtable holding the
+// batch is flushed, after which the batch is durable and nil is returned. If
+// the failure can't be recovered from, the returned error is non-nil.
+func (d *DB) recoverWALSyncError(err error) error {
+	// Rotating the WAL requires commitPipeline.mu, which must be acquired
+	// before d.mu.
+	d.commit.mu.Lock()
+	defer d.commit.mu.Unlock()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.walFailedLocked() {
+		// makeRoomForWrite waits for the rotated memtable to be flushed.
+		return d.makeRoomForWrite(nil)
+	}
+	if d.mu.log.Err() != nil {
+		// The batch may have been written to a WAL in the secondary directory.
+		return err
+	}
+	// The failed WAL has already been rotated by another write, which waited
+	// for its writes to be flushed while holding commitPipeline.mu, unless
+	// background work was paused.
+	return d.mu.compact.backgroundErr
+}
+
+// waitForFlushLocked waits for the flushable whose flushed channel is given to
+// be flushed. It returns the error if background work is paused by an error
+// in the meantime.
+//
+// d.mu must be held when calling this.
+func (d *DB) waitForFlushLocked(flushed chan struct{}) error {
+	for {
+		select {
+		case <-flushed:
+			return nil
+		default:
+		}
+		if err := d.mu.compact.backgroundErr; err != nil {
+			return err
+		}
+		d.maybeScheduleFlush()
+		d.mu.compact.cond.Wait()
+	}
+}
+
+// walFailoverPendingLocked returns true if the current WAL should be rotated
+// in order to fail over to, or fail back from, the secondary WAL directory, or
+// because a write to it failed.
+//
+// commitPipeline.mu and d.mu must be held when calling this.
+func (d *DB) walFailoverPendingLocked() bool {
+	opts := d.opts.WALFailover
+	if opts == nil || d.opts.DisableWAL {
+		return false
+	}
+	if d.walFailedLocked() {
+		return true
+	}
+	if f := &d.mu.log.failover; f.active {
+		return d.timeNow().Sub(f.activatedAt) >= opts.FailbackInterval
+	}
+	return atomic.LoadUint32(&d.atomic.walUnhealthy) == 1
+}
+
+// createWAL creates the WAL with the given name at loc. A recycled WAL is
+// reused if one is available and loc is the primary WAL directory, in which
+// case the recycled WAL is returned. The returned file is nil if an error
+// occurred.
+func (d *DB) createWAL(
+	loc walLocation, logName string,
+) (f vfs.File, size uint64, recycled fileInfo, err error) {
+	// Try to use a recycled log file. Recycling log files is an important
+	// performance optimization as it is faster to sync a file that has
+	// already been written, than one which is being written for the first
+	// time. This is due to the need to sync file metadata when a file is
+	// being written for the first time. Note this is true even if file
+	// preallocation is performed (e.g. fallocate). Only logs in the primary
+	// WAL directory are recycled.
+	var recycleOK bool
+	if !loc.secondary {
+		recycled, recycleOK = d.logRecycler.peek()
+	}
+	if recycleOK {
+		recycleLogName := base.MakeFilepath(loc.fs, loc.dirname, fileTypeLog, recycled.fileNum)
+		f, err = loc.fs.ReuseForWrite(recycleLogName, logName)
+		base.MustExist(loc.fs, logName, d.opts.Logger, err)
+	} else {
+		f, err = loc.fs.Create(logName)
+		base.MustExist(loc.fs, logName, d.opts.Logger, err)
+	}
+
+	if err == nil && recycleOK {
+		// Figure out the recycled WAL size. This Stat is necessary
+		// because ReuseForWrite's contract allows for removing the
+		// old file and creating a new one. We don't know whether the
+		// WAL was actually recycled.
+		// TODO(jackson): Adding a boolean to the ReuseForWrite return
+		// value indicating whether or not the file was actually
+		// reused would allow us to skip the stat and use
+		// recycleLog.fileSize.
+		var finfo os.FileInfo
+		finfo, err = f.Stat()
+		if err == nil {
+			size = uint64(finfo.Size())
+		}
+	}
+
+	deferDirSync := d.opts.Experimental.DirSyncPolicy == DirSyncDeferred
+	if err == nil && !deferDirSync {
+		err = loc.dir.Sync()
+	}
+
+	if err != nil && f != nil {
+		f.Close()
+		f = nil
+	} else if err == nil {
+		f = vfs.NewSyncingFile(f, vfs.SyncingFileOptions{
+			NoSyncOnClose:   d.opts.NoSyncOnClose,
+			BytesPerSync:    d.opts.WALBytesPerSync,
+			PreallocateSize: d.walPreallocateSize(),
+		})
+		if deferDirSync {
+			// Like RocksDB, delay the sync of the WAL directory until the
+			// first time the WAL is synced.
+			f = &deferredDirSyncWAL{File: f, dir: loc.dir}
+		}
+		if opts := d.opts.WALFailover; opts != nil && !loc.secondary {
+			f = &walSyncMonitor{
+				File:      f,
+				threshold: opts.UnhealthySyncLatencyThreshold,
+				unhealthy: &d.atomic.walUnhealthy,
+			}
+		}
+	}
+
+	if recycleOK {
+		err = firstError(err, d.logRecycler.pop(recycled.fileNum))
+	}
+	return f, size, recycled, err
+}
+
+// walSyncMonitor wraps a WAL in the primary WAL directory, flagging the
+// directory as unhealthy when a sync exceeds the configured latency threshold.
+// The flag is consumed when the next WAL is created. It's set atomically, as
+// syncs may be performed while DB.mu is held.
+type walSyncMonitor struct {
+	vfs.File
+	threshold time.Duration
+	unhealthy *uint32
+}
+
+func (f *walSyncMonitor) Sync() error {
+	start := time.Now()
+	err := f.File.Sync()
+	if time.Since(start) > f.threshold {
+		atomic.StoreUint32(f.unhealthy, 1)
+	}
+	return err
+}
@@ -0,0 +1,289 @@
+// Copyright 2026 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package pebble
+
+import (
+	"bytes"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/cockroachdb/pebble/internal/base"
+	"github.com/cockroachdb/pebble/vfs"
+	"github.com/stretchr/testify/require"
+)
+
+// slowSyncFS is a vfs.FS whose files are slow to sync while slow is set.
+type slowSyncFS struct {
+	vfs.FS
+	slow *uint32
+}
+
+func (fs slowSyncFS) Create(name string) (vfs.File, error) {
+	f, err := fs.FS.Create(name)
+	if err != nil {
+		return nil, err
+	}
+	return slowSyncFile{File: f, slow: fs.slow}, nil
+}
+
+func (fs slowSyncFS) ReuseForWrite(oldname, newname string) (vfs.File, error) {
+	f, err := fs.FS.ReuseForWrite(oldname, newname)
+	if err != nil {
+		return nil, err
+	}
+	return slowSyncFile{File: f, slow: fs.slow}, nil
+}
+
+type slowSyncFile struct {
+	vfs.File
+	slow *uint32
+}
+
+func (f slowSyncFile) Sync() error {
+	if atomic.LoadUint32(f.slow) == 1 {
+		time.Sleep(20 * time.Millisecond)
+	}
+	return f.File.Sync()
+}
+
+func TestWALFailover(t *testing.T) {
+	var slow uint32
+	primaryFS := slowSyncFS{FS: vfs.NewMem(), slow: &slow}
+	secondaryFS := vfs.NewMem()
+	var log syncedBuffer
+	opts := &Options{
+		FS:     primaryFS,
+		Logger: &log,
+		WALFailover: &WALFailoverOptions{
+			SecondaryFS:                   secondaryFS,
+			SecondaryDir:                  "secondary",
+			UnhealthySyncLatencyThreshold: 10 * time.Millisecond,
+			FailbackInterval:              time.Hour,
+		},
+	}
+	var now int64
+	open := func() *DB {
+		d, err := Open("", opts)
+		require.NoError(t, err)
+		d.timeNow = func() time.Time { return time.Unix(0, atomic.LoadInt64(&now)) }
+		return d
+	}
+	secondaryLogs := func() int {
+		ls, err := secondaryFS.List("secondary")
+		require.NoError(t, err)
+		var n int
+		for _, filename := range ls {
+			if ft, _, ok := base.ParseFilename(secondaryFS, filename); ok && ft == fileTypeLog {
+				n++
+			}
+		}
+		return n
+	}
+	currentLogSecondary := func(d *DB) bool {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		_, ok := d.mu.log.failover.secondaryLogs[d.mu.log.queue[len(d.mu.log.queue)-1].fileNum]
+		return ok
+	}
+	set := func(d *DB, key string) {
+		require.NoError(t, d.Set([]byte(key), []byte(key), Sync))
+	}
+	get := func(r Reader, key string) {
+		v, closer, err := r.Get([]byte(key))
+		require.NoError(t, err)
+		require.Equal(t, key, string(v))
+		require.NoError(t, closer.Close())
+	}
+
+	d := open()
+	set(d, "a")
+	require.False(t, currentLogSecondary(d))
+	require.Zero(t, secondaryLogs())
+
+	// A slow sync causes the next write to rotate the WAL into the secondary
+	// directory.
+	atomic.StoreUint32(&slow, 1)
+	set(d, "b")
+	atomic.StoreUint32(&slow, 0)
+	set(d, "c")
+	require.True(t, currentLogSecondary(d))
+	require.Equal(t, 1, secondaryLogs())
+	require.Contains(t, log.String(), "failing over to secondary")
+
+	// The WAL in the secondary directory is replayed when reopening the DB,
+	// and deleted once it has been flushed.
+	require.NoError(t, d.Close())
+	d = open()
+	for _, k := range []string{"a", "b", "c"} {
+		get(d, k)
+	}
+	require.False(t, currentLogSecondary(d))
+	require.Zero(t, secondaryLogs())
+
+	atomic.StoreUint32(&slow, 1)
+	set(d, "d")
+	atomic.StoreUint32(&slow, 0)
+	set(d, "e")
+	require.True(t, currentLogSecondary(d))
+
+	// A checkpoint includes the WALs in the secondary directory.
+	require.NoError(t, d.Checkpoint("checkpoint"))
+	ckpt, err := Open("checkpoint", &Options{FS: primaryFS})
+	require.NoError(t, err)
+	get(ckpt, "e")
+	require.NoError(t, ckpt.Close())
+
+	// The WAL fails back to the primary directory once the failback interval
+	// has elapsed.
+	atomic.AddInt64(&now, int64(time.Hour))
+	set(d, "f")
+	require.False(t, currentLogSecondary(d))
+	require.Contains(t, log.String(), "failing back WAL from secondary")
+	require.NoError(t, d.Flush())
+	require.NoError(t, d.Close())
+	d = open()
+	for _, k := range []string{"a", "b", "c", "d", "e", "f"} {
+		get(d, k)
+	}
+	require.Zero(t, secondaryLogs())
+	require.NoError(t, d.Close())
+}
+
+// failingWALFS is a vfs.FS whose WALs fail to be written to and synced while
+// fail is set.
+type failingWALFS struct {
+	vfs.FS
+	fail *uint32
+}
+
+func (fs failingWALFS) wrap(name string, f vfs.File) vfs.File {
+	if strings.HasSuffix(name, ".log") {
+		return failingWALFile{File: f, fail: fs.fail}
+	}
+	return f
+}
+
+func (fs failingWALFS) Create(name string) (vfs.File, error) {
+	f, err := fs.FS.Create(name)
+	if err != nil {
+		return nil, err
+	}
+	return fs.wrap(name, f), nil
+}
+
+func (fs failingWALFS) ReuseForWrite(oldname, newname string) (vfs.File, error) {
+	f, err := fs.FS.ReuseForWrite(oldname, newname)
+	if err != nil {
+		return nil, err
+	}
+	return fs.wrap(newname, f), nil
+}
+
+type failingWALFile struct {
+	vfs.File
+	fail *uint32
+}
+
+func (f failingWALFile) Write(p []byte) (int, error) {
+	if atomic.LoadUint32(f.fail) == 1 {
+		return 0, errors.New("injected WAL write error")
+	}
+	return f.File.Write(p)
+}
+
+func (f failingWALFile) Sync() error {
+	if atomic.LoadUint32(f.fail) == 1 {
+		return errors.New("injected WAL sync error")
+	}
+	return f.File.Sync()
+}
+
+func TestWALFailoverOnError(t *testing.T) {
+	var fail uint32
+	primaryFS := failingWALFS{FS: vfs.NewMem(), fail: &fail}
+	var log syncedBuffer
+	opts := &Options{
+		FS:     primaryFS,
+		Logger: &log,
+		WALFailover: &WALFailoverOptions{
+			SecondaryFS:             vfs.NewMem(),
+			SecondaryDir:            "secondary",
+			UnhealthyErrorThreshold: 2,
+			FailbackInterval:        time.Hour,
+		},
+	}
+	currentLogSecondary := func(d *DB) bool {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		return d.currentWALSecondaryLocked()
+	}
+	get := func(d *DB, key string) {
+		v, closer, err := d.Get([]byte(key))
+		require.NoError(t, err)
+		require.Equal(t, key, string(v))
+		require.NoError(t, closer.Close())
+	}
+
+	d, err := Open("", opts)
+	require.NoError(t, err)
+	require.NoError(t, d.Set([]byte("a"), []byte("a"), Sync))
+
+	// The failed sync of b is recovered from by rotating the WAL, which
+	// remains in the primary directory below the error threshold, and
+	// flushing the memtable holding b.
+	atomic.StoreUint32(&fail, 1)
+	require.NoError(t, d.Set([]byte("b"), []byte("b"), Sync))
+	require.False(t, currentLogSecondary(d))
+	require.Contains(t, log.String(), "rotating the WAL")
+
+	// The second error reaches the threshold, and fails over.
+	require.NoError(t, d.Set([]byte("c"), []byte("c"), Sync))
+	require.True(t, currentLogSecondary(d))
+	require.Contains(t, log.String(), "failing over to secondary")
+	atomic.StoreUint32(&fail, 0)
+	require.NoError(t, d.Set([]byte("d"), []byte("d"), Sync))
+	require.NoError(t, d.Close())
+
+	d, err = Open("", opts)
+	require.NoError(t, err)
+	for _, k := range []string{"a", "b", "c", "d"} {
+		get(d, k)
+	}
+
+	// A failed write of an unsynced batch, large enough to fill a WAL block,
+	// is detected by the next commit, which rotates the WAL before writing its
+	// batch.
+	atomic.StoreUint32(&fail, 1)
+	e := bytes.Repeat([]byte("e"), 64<<10)
+	require.NoError(t, d.Set([]byte("e"), e, NoSync))
+	for {
+		d.commit.mu.Lock()
+		err := d.mu.log.Err()
+		d.commit.mu.Unlock()
+		if err != nil {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	atomic.StoreUint32(&fail, 0)
+	require.NoError(t, d.Set([]byte("f"), []byte("f"), Sync))
+	require.False(t, currentLogSecondary(d))
+	require.Equal(t, 2, strings.Count(log.String(), "rotating the WAL"))
+	require.NoError(t, d.Close())
+
+	d, err = Open("", opts)
+	require.NoError(t, err)
+	for _, k := range []string{"a", "b", "c", "d", "f"} {
+		get(d, k)
+	}
+	v, closer, err := d.Get([]byte("e"))
+	require.NoError(t, err)
+	require.Equal(t, e, v)
+	require.NoError(t, closer.Close())
+	require.NoError(t, d.Close())
+}
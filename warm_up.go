@@ -0,0 +1,107 @@
+// Copyright 2026 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package pebble
+
+import (
+	"context"
+
+	"github.com/cockroachdb/errors"
+	"github.com/cockroachdb/pebble/sstable"
+)
+
+// WarmUpOptions hold the optional parameters for WarmUp.
+type WarmUpOptions struct {
+	// Levels, if positive, restricts the warm-up to the sstables in the top
+	// Levels levels of the LSM, L0 through L(Levels-1). Zero warms up all of
+	// the levels.
+	Levels int
+	// MemoryBudget bounds the total in-memory size of the blocks loaded into
+	// the block cache. The warm-up stops once the blocks loaded reach the
+	// budget, which may be exceeded by the blocks of the last sstable warmed
+	// up. The default, and the maximum, is the capacity of the block cache,
+	// beyond which loading more blocks would evict those already loaded.
+	MemoryBudget int64
+	// Progress, if non-nil, is invoked after each sstable is warmed up.
+	Progress func(WarmUpProgress)
+}
+
+// WarmUpProgress describes the progress of a WarmUp call.
+type WarmUpProgress struct {
+	// TablesWarmed is the number of sstables warmed up so far.
+	TablesWarmed int
+	// TablesTotal is the number of sstables in the levels being warmed up.
+	TablesTotal int
+	// BytesLoaded is the total in-memory size of the blocks of the sstables
+	// warmed up so far, including blocks that were already cached.
+	BytesLoaded uint64
+}
+
+// WarmUp proactively loads the metadata of the DB's sstables, which is
+// otherwise loaded lazily by the first reads to need it, trading the time
+// spent warming up for steady latencies of the reads that follow. It is
+// intended to be called after Open, before the DB starts serving reads.
+//
+// Each sstable is opened and added to the table cache, and its index blocks,
+// including the partitions of a two-level index, and its filter, range
+// deletion and range key blocks are loaded into the block cache. Data blocks
+// are not loaded. The sstables are warmed up level by level, from L0 down,
+// and in key order within each level, until all of the sstables in the levels
+// selected by opts.Levels are warmed up or opts.MemoryBudget is reached.
+// Sstables beyond the table cache's capacity (see MaxOpenFiles) evict the
+// readers opened before them, though their blocks remain in the block cache.
+//
+// WarmUp reads the sstables in the LSM when it's called. Sstables written by
+// flushes and compactions while it runs are not warmed up, and the sstables
+// it warms up may be compacted away. WarmUp may be canceled through ctx, in
+// which case it returns ctx.Err().
+func (d *DB) WarmUp(ctx context.Context, opts *WarmUpOptions) error {
+	if err := d.closed.Load(); err != nil {
+		panic(err)
+	}
+	if opts == nil {
+		opts = &WarmUpOptions{}
+	}
+	levels := opts.Levels
+	if levels <= 0 || levels > numLevels {
+		levels = numLevels
+	}
+	budget := d.opts.Cache.MaxSize()
+	if opts.MemoryBudget > 0 && opts.MemoryBudget < budget {
+		budget = opts.MemoryBudget
+	}
+
+	readState := d.loadReadState()
+	defer readState.unref()
+	current := readState.current
+
+	var progress WarmUpProgress
+	for level := 0; level < levels; level++ {
+		progress.TablesTotal += current.Levels[level].Len()
+	}
+	for level := 0; level < levels; level++ {
+		iter := current.Levels[level].Iter()
+		for f := iter.First(); f != nil; f = iter.Next() {
+			if progress.BytesLoaded >= uint64(budget) {
+				return nil
+			}
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			err := d.tableCache.withReader(f, func(r *sstable.Reader) error {
+				n, err := r.LoadMetadataBlocks()
+				progress.BytesLoaded += n
+				return err
+			})
+			if err != nil {
+				return errors.Wrapf(err, "pebble: warming up L%d file %s", errors.Safe(level), f.FileNum)
+			}
+			progress.TablesWarmed++
+			if opts.Progress != nil {
+				opts.Progress(progress)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+// Copyright 2026 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package pebble
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/cockroachdb/pebble/bloom"
+	"github.com/cockroachdb/pebble/internal/cache"
+	"github.com/cockroachdb/pebble/vfs"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWarmUp(t *testing.T) {
+	mem := vfs.NewMem()
+	open := func() *DB {
+		c := cache.New(1 << 20)
+		defer c.Unref()
+		opts := &Options{FS: mem, Cache: c, DisableAutomaticCompactions: true}
+		opts.Levels = []LevelOptions{{FilterPolicy: bloom.FilterPolicy(10)}}
+		d, err := Open("", opts)
+		require.NoError(t, err)
+		return d
+	}
+
+	// Write sstables into L0 and L6.
+	d := open()
+	for j := 0; j < 4; j++ {
+		for i := 0; i < 100; i++ {
+			k := []byte(fmt.Sprintf("k%03d-%d", i, j))
+			require.NoError(t, d.Set(k, k, nil))
+		}
+		require.NoError(t, d.DeleteRange([]byte(fmt.Sprintf("k%03d", j)), []byte(fmt.Sprintf("k%03d", j+1)), nil))
+		require.NoError(t, d.Flush())
+		if j == 1 {
+			require.NoError(t, d.Compact([]byte("k"), []byte("l"), false))
+		}
+	}
+	require.NoError(t, d.Close())
+
+	warmUp := func(ctx context.Context, opts *WarmUpOptions) ([]WarmUpProgress, error) {
+		var progress []WarmUpProgress
+		if opts == nil {
+			opts = &WarmUpOptions{}
+		}
+		opts.Progress = func(p WarmUpProgress) { progress = append(progress, p) }
+		err := d.WarmUp(ctx, opts)
+		return progress, err
+	}
+
+	// A warm-up of all of the levels opens every sstable, and loads the
+	// blocks it reports into the block cache.
+	d = open()
+	progress, err := warmUp(context.Background(), nil)
+	require.NoError(t, err)
+	require.Len(t, progress, 3)
+	last := progress[len(progress)-1]
+	require.Equal(t, WarmUpProgress{TablesWarmed: 3, TablesTotal: 3, BytesLoaded: last.BytesLoaded}, last)
+	require.Greater(t, last.BytesLoaded, uint64(0))
+	m := d.Metrics()
+	require.EqualValues(t, 3, m.TableCache.Count)
+	require.GreaterOrEqual(t, m.BlockCache.Size, int64(last.BytesLoaded))
+
+	// Warming up again loads nothing new.
+	misses := m.BlockCache.Misses
+	_, err = warmUp(context.Background(), nil)
+	require.NoError(t, err)
+	require.Equal(t, misses, d.Metrics().BlockCache.Misses)
+	require.NoError(t, d.Close())
+
+	// A warm-up may be restricted to the top levels, and stops at the memory
+	// budget.
+	d = open()
+	progress, err = warmUp(context.Background(), &WarmUpOptions{Levels: 1})
+	require.NoError(t, err)
+	require.Len(t, progress, 2)
+	require.Equal(t, 2, progress[1].TablesTotal)
+	progress, err = warmUp(context.Background(), &WarmUpOptions{MemoryBudget: 1})
+	require.NoError(t, err)
+	require.Len(t, progress, 1)
+	require.Equal(t, 3, progress[0].TablesTotal)
+
+	// A canceled warm-up returns the context's error.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	progress, err = warmUp(ctx, nil)
+	require.Equal(t, context.Canceled, err)
+	require.Empty(t, progress)
+	require.NoError(t, d.Close())
+}